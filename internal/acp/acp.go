@@ -0,0 +1,304 @@
+// Package acp lets editors such as Zed use billdozer as the agent behind
+// their assistant panel, over the Agent Client Protocol: JSON-RPC on the
+// agent's stdin and stdout. Replies stream into the panel, tool calls show
+// up with the diffs they made, and confirmations become the editor's
+// permission prompts.
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"agent/internal/confirm"
+	"agent/internal/logging"
+)
+
+// Handler runs the sessions an editor asks for
+type Handler interface {
+	// NewSession starts a conversation in the directory cwd and returns its ID
+	NewSession(ctx context.Context, cwd string) (string, error)
+	// Prompt sends text to a session and returns the stop reason once the
+	// agent has finished. ctx is cancelled when the editor cancels the turn.
+	Prompt(ctx context.Context, sessionID, text string) (string, error)
+}
+
+// Server speaks the Agent Client Protocol with one editor. Prompts run one
+// at a time, since a session's tools, confirmations and workspace are
+// shared by its conversations; tool calls, replies and confirmations are
+// attributed to the running prompt's session.
+type Server struct {
+	conn *conn
+	turn sync.Mutex // Held while a prompt runs
+
+	mutex    sync.Mutex
+	cancels  map[string]context.CancelFunc // Of the pending prompts, by session
+	active   string                        // Session of the running prompt
+	ctx      context.Context               // Of the running prompt; cancelled with it
+	nextCall int
+	call     *toolCall // Running tool call, the subject of permission requests
+	answer   string    // Answer to the confirmation being asked, for ReadLine
+}
+
+// New creates a server talking to the editor over in and out, usually the
+// process's stdin and stdout. Nothing else may write to out.
+func New(in io.Reader, out io.Writer) *Server {
+	return &Server{conn: newConn(in, out), cancels: map[string]context.CancelFunc{}}
+}
+
+// Serve answers the editor's requests until it closes the connection or ctx
+// is done. Running prompts are cancelled when it returns.
+func (s *Server) Serve(ctx context.Context, handler Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var requests sync.WaitGroup
+	defer requests.Wait()
+
+	read := make(chan error, 1)
+	go func() {
+		read <- s.conn.read(func(msg message) {
+			if msg.Method == methodCancel {
+				s.cancel(msg.Params)
+				return
+			}
+			requests.Add(1)
+			go func() {
+				defer requests.Done()
+				result, err := s.dispatch(ctx, handler, msg)
+				if len(msg.ID) > 0 {
+					s.conn.reply(msg.ID, result, err)
+				}
+			}()
+		})
+	}()
+	select {
+	case err := <-read:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// dispatch runs one request from the editor
+func (s *Server) dispatch(ctx context.Context, handler Handler, msg message) (any, error) {
+	switch msg.Method {
+	case methodInitialize:
+		var params initializeParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		logging.For(logging.ACP).Info("editor connected", "protocol_version", params.ProtocolVersion)
+		return initializeResult{
+			ProtocolVersion:   ProtocolVersion,
+			AgentCapabilities: agentCapabilities{PromptCapabilities: promptCapabilities{EmbeddedContext: true}},
+			AuthMethods:       []any{},
+		}, nil
+	case methodAuthenticate:
+		// The API key comes from billdozer's own lookup chain
+		return struct{}{}, nil
+	case methodNewSession:
+		var params newSessionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		id, err := handler.NewSession(ctx, params.Cwd)
+		if err != nil {
+			return nil, err
+		}
+		logging.For(logging.ACP).Info("session started", "session", id, "cwd", params.Cwd)
+		return newSessionResult{SessionID: id}, nil
+	case methodPrompt:
+		var params promptParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		stop, err := s.prompt(ctx, handler, params.SessionID, promptText(params.Prompt))
+		if err != nil {
+			return nil, err
+		}
+		return promptResult{StopReason: stop}, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("unknown method %q", msg.Method)}
+}
+
+// prompt runs a prompt once the previous one has finished
+func (s *Server) prompt(ctx context.Context, handler Handler, sessionID, text string) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mutex.Lock()
+	if _, busy := s.cancels[sessionID]; busy {
+		s.mutex.Unlock()
+		return "", &rpcError{Code: codeInvalidParams, Message: "the session is already running a prompt"}
+	}
+	s.cancels[sessionID] = cancel
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.cancels, sessionID)
+		s.mutex.Unlock()
+	}()
+
+	s.turn.Lock()
+	defer s.turn.Unlock()
+	s.mutex.Lock()
+	s.active, s.ctx = sessionID, ctx
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.active, s.ctx = "", nil
+		s.mutex.Unlock()
+	}()
+
+	stop, err := handler.Prompt(ctx, sessionID, text)
+	if ctx.Err() != nil {
+		// Whatever the turn was doing when it was cancelled, it stopped for that
+		return StopCancelled, nil
+	}
+	return stop, err
+}
+
+// cancel stops the prompt a session/cancel notification names
+func (s *Server) cancel(raw json.RawMessage) {
+	var params cancelParams
+	if decode(raw, &params) != nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if cancel, ok := s.cancels[params.SessionID]; ok {
+		cancel()
+	}
+}
+
+// Text streams a text block of the agent's response to the editor; use it
+// with agent.WithResponseText
+func (s *Server) Text(text string) {
+	s.update(update{SessionUpdate: "agent_message_chunk", Content: textBlock(text)})
+}
+
+// update notifies the editor of a change to the running prompt's session
+func (s *Server) update(u update) {
+	s.mutex.Lock()
+	session := s.active
+	s.mutex.Unlock()
+	if session == "" {
+		return
+	}
+	if err := s.conn.notify(methodUpdate, updateParams{SessionID: session, Update: u}); err != nil {
+		logging.For(logging.ACP).Warn("failed to send an update", "error", err)
+	}
+}
+
+// Confirming asks the editor for permission; use it as the confirm
+// service's before-prompt hook. Its answer is returned by the next
+// ReadLine, and cancelling the prompt declines.
+func (s *Server) Confirming(req confirm.Request) {
+	s.mutex.Lock()
+	session, ctx, call := s.active, s.ctx, s.call
+	s.answer = "no"
+	s.mutex.Unlock()
+	if session == "" {
+		return
+	}
+
+	subject := update{ToolCallID: "confirmation", Title: req.Description()}
+	if call != nil {
+		subject = update{ToolCallID: call.id, Title: call.title}
+	}
+	if req.Preview != "" {
+		subject.Content = []toolCallContent{textContent(fence(req.Preview))}
+	}
+	options := []permissionOption{{OptionID: "yes", Name: "Allow", Kind: "allow_once"}}
+	if !req.Force {
+		options = append(options, permissionOption{OptionID: "session", Name: fmt.Sprintf("Always allow %s", req.Tool), Kind: "allow_always"})
+		if req.Path != "" {
+			options = append(options, permissionOption{OptionID: "path", Name: fmt.Sprintf("Always allow %s on %s", req.Tool, req.Path), Kind: "allow_always"})
+		}
+	}
+	options = append(options, permissionOption{OptionID: "no", Name: "Reject", Kind: "reject_once"})
+
+	var result permissionResult
+	err := s.conn.call(ctx, methodRequestPermission, permissionParams{SessionID: session, ToolCall: subject, Options: options}, &result)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logging.For(logging.ACP).Warn("permission request failed", "error", err)
+		}
+		return
+	}
+	if result.Outcome.Outcome == "selected" {
+		s.mutex.Lock()
+		s.answer = result.Outcome.OptionID
+		s.mutex.Unlock()
+	}
+}
+
+// ReadLine returns the editor's answer to the confirmation being asked; use
+// it as the confirm service's input. Editors send messages as prompts, so
+// there is never a line to read otherwise.
+func (s *Server) ReadLine() (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.answer == "" {
+		return "", false
+	}
+	answer := s.answer
+	s.answer = ""
+	return answer, true
+}
+
+// promptText joins a prompt's blocks into one message. Files the user
+// attached are named by path, with their contents when the editor embedded
+// them.
+func promptText(blocks []contentBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			parts = append(parts, block.Text)
+		case "resource_link":
+			parts = append(parts, "@"+filePath(block.URI))
+		case "resource":
+			if block.Resource != nil {
+				parts = append(parts, fmt.Sprintf("Contents of %s:\n%s", filePath(block.Resource.URI), fence(block.Resource.Text)))
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// filePath turns a file:// URI into a path; other URIs are kept
+func filePath(uri string) string {
+	if parsed, err := url.Parse(uri); err == nil && parsed.Scheme == "file" {
+		return parsed.Path
+	}
+	return uri
+}
+
+// fence wraps text in a markdown code block, as a diff when it is one
+func fence(text string) string {
+	text = strings.TrimRight(text, "\n")
+	ticks := "```"
+	for strings.Contains(text, ticks) {
+		ticks += "`"
+	}
+	language := ""
+	if strings.HasPrefix(text, "--- ") {
+		language = "diff"
+	}
+	return ticks + language + "\n" + text + "\n" + ticks
+}
+
+func decode(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package acp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"agent/internal/jsonrpc"
+	"agent/internal/logging"
+)
+
+// JSON-RPC error codes
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// errClosed is returned by calls when the editor closes the connection
+var errClosed = errors.New("the editor closed the connection")
+
+// message and rpcError are the JSON-RPC types shared with the other
+// connections
+type (
+	message  = jsonrpc.Message
+	rpcError = jsonrpc.Error
+)
+
+// conn exchanges newline-delimited JSON-RPC messages with the editor. Both
+// sides make requests: the editor sends prompts, and the agent asks for
+// permission.
+type conn struct {
+	in         io.Reader
+	out        io.Writer
+	writeMutex sync.Mutex
+	closed     chan struct{}
+	calls      jsonrpc.Calls
+}
+
+func newConn(in io.Reader, out io.Writer) *conn {
+	return &conn{in: in, out: out, closed: make(chan struct{})}
+}
+
+// read hands every request and notification from the editor to handle and
+// routes responses to the waiting calls, until the input ends
+func (c *conn) read(handle func(msg message)) error {
+	defer close(c.closed)
+	scanner := bufio.NewScanner(c.in)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logging.For(logging.ACP).Warn("ignoring malformed message", "error", err)
+			continue
+		}
+		if msg.Method != "" {
+			handle(msg)
+			continue
+		}
+		c.calls.Deliver(msg)
+	}
+	return scanner.Err()
+}
+
+// call sends a request to the editor and decodes its result
+func (c *conn) call(ctx context.Context, method string, params, result any) error {
+	reply, err := c.calls.Call(ctx, method, params, c.send, c.closed)
+	if errors.Is(err, jsonrpc.ErrClosed) {
+		return errClosed
+	}
+	if err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("the editor returned error %d: %s", reply.Error.Code, reply.Error.Message)
+	}
+	return reply.Decode(result)
+}
+
+// notify sends a notification to the editor
+func (c *conn) notify(method string, params any) error {
+	notification, err := jsonrpc.NewRequest(method, params)
+	if err != nil {
+		return err
+	}
+	return c.send(notification)
+}
+
+// reply answers the editor's request id with result, or with err
+func (c *conn) reply(id json.RawMessage, result any, err error) {
+	if err != nil {
+		code := codeInternalError
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			code = rpcErr.Code
+		}
+		c.send(message{ID: id, Error: &rpcError{Code: code, Message: err.Error()}})
+		return
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		c.send(message{ID: id, Error: &rpcError{Code: codeInternalError, Message: err.Error()}})
+		return
+	}
+	c.send(message{ID: id, Result: encoded})
+}
+
+func (c *conn) send(msg message) error {
+	msg.JSONRPC = "2.0"
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_, err = c.out.Write(append(encoded, '\n'))
+	return err
+}
@@ -0,0 +1,147 @@
+package acp
+
+import "encoding/json"
+
+// ProtocolVersion is the ACP revision this agent implements
+const ProtocolVersion = 1
+
+// Methods the editor calls
+const (
+	methodInitialize   = "initialize"
+	methodAuthenticate = "authenticate"
+	methodNewSession   = "session/new"
+	methodPrompt       = "session/prompt"
+	methodCancel       = "session/cancel" // A notification
+)
+
+// Methods the agent calls
+const (
+	methodUpdate            = "session/update" // A notification
+	methodRequestPermission = "session/request_permission"
+)
+
+// Stop reasons of a prompt turn
+const (
+	StopEndTurn   = "end_turn"
+	StopMaxTurns  = "max_turn_requests"
+	StopCancelled = "cancelled"
+)
+
+// Tool call statuses
+const (
+	statusInProgress = "in_progress"
+	statusCompleted  = "completed"
+	statusFailed     = "failed"
+)
+
+type initializeParams struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+type initializeResult struct {
+	ProtocolVersion   int               `json:"protocolVersion"`
+	AgentCapabilities agentCapabilities `json:"agentCapabilities"`
+	AuthMethods       []any             `json:"authMethods"`
+}
+
+type agentCapabilities struct {
+	LoadSession        bool               `json:"loadSession"`
+	PromptCapabilities promptCapabilities `json:"promptCapabilities"`
+}
+
+type promptCapabilities struct {
+	Image           bool `json:"image"`
+	Audio           bool `json:"audio"`
+	EmbeddedContext bool `json:"embeddedContext"`
+}
+
+type newSessionParams struct {
+	Cwd string `json:"cwd"`
+}
+
+type newSessionResult struct {
+	SessionID string `json:"sessionId"`
+}
+
+type promptParams struct {
+	SessionID string         `json:"sessionId"`
+	Prompt    []contentBlock `json:"prompt"`
+}
+
+type promptResult struct {
+	StopReason string `json:"stopReason"`
+}
+
+type cancelParams struct {
+	SessionID string `json:"sessionId"`
+}
+
+// contentBlock is a piece of a prompt or a message: text, a link to a
+// file, or a file's contents embedded by the editor
+type contentBlock struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	URI      string    `json:"uri,omitempty"`  // resource_link
+	Name     string    `json:"name,omitempty"` // resource_link
+	Resource *resource `json:"resource,omitempty"`
+}
+
+type resource struct {
+	URI  string `json:"uri"`
+	Text string `json:"text,omitempty"`
+}
+
+func textBlock(text string) contentBlock {
+	return contentBlock{Type: "text", Text: text}
+}
+
+type updateParams struct {
+	SessionID string `json:"sessionId"`
+	Update    update `json:"update"`
+}
+
+// update is one session/update: a chunk of the agent's message, a new
+// tool call or a change to one
+type update struct {
+	SessionUpdate string             `json:"sessionUpdate,omitempty"`
+	Content       any                `json:"content,omitempty"` // A contentBlock for message chunks, []toolCallContent for tool calls
+	ToolCallID    string             `json:"toolCallId,omitempty"`
+	Title         string             `json:"title,omitempty"`
+	Kind          string             `json:"kind,omitempty"`
+	Status        string             `json:"status,omitempty"`
+	Locations     []toolCallLocation `json:"locations,omitempty"`
+	RawInput      json.RawMessage    `json:"rawInput,omitempty"`
+}
+
+// toolCallContent is shown with a tool call: text, or a diff the editor
+// displays as it would its own edits
+type toolCallContent struct {
+	Type    string        `json:"type"` // "content" or "diff"
+	Content *contentBlock `json:"content,omitempty"`
+	Path    string        `json:"path,omitempty"`
+	OldText *string       `json:"oldText,omitempty"` // nil for new files
+	NewText *string       `json:"newText,omitempty"` // Empty for deleted files
+}
+
+type toolCallLocation struct {
+	Path string `json:"path"`
+}
+
+type permissionParams struct {
+	SessionID string             `json:"sessionId"`
+	ToolCall  update             `json:"toolCall"`
+	Options   []permissionOption `json:"options"`
+}
+
+type permissionOption struct {
+	OptionID string `json:"optionId"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"` // allow_once, allow_always, reject_once or reject_always
+}
+
+type permissionResult struct {
+	Outcome struct {
+		Outcome  string `json:"outcome"` // "selected" or "cancelled"
+		OptionID string `json:"optionId"`
+	} `json:"outcome"`
+}
@@ -0,0 +1,176 @@
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"agent/internal/tools"
+)
+
+// maxShownOutput caps the tool output shown in the editor; the model still
+// gets all of it
+const maxShownOutput = 16 * 1024
+
+// toolKinds tell the editor which icon and layout to use for a tool call.
+// Tools not listed are "edit" when they modify files and "other" otherwise.
+var toolKinds = map[string]string{
+	"read_file":       "read",
+	"list_files":      "search",
+	"glob_search":     "search",
+	"delete_file":     "delete",
+	"execute_command": "execute",
+	"command_status":  "execute",
+	"command_output":  "execute",
+	"stop_command":    "execute",
+}
+
+// toolCall is a tool call the editor was told about
+type toolCall struct {
+	id    string
+	title string
+}
+
+// Middleware reports every tool call to the editor: when it starts, with
+// its input and the file it works on, and when it ends, with the diffs of
+// the files it changed or its output
+func (s *Server) Middleware() tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			if toolCtx == nil || toolCtx.Tool == nil {
+				return next(ctx, toolCtx, input)
+			}
+			tool := toolCtx.Tool
+			s.mutex.Lock()
+			s.nextCall++
+			call := &toolCall{id: fmt.Sprintf("call-%d", s.nextCall), title: strings.TrimSpace(tool.Name + " " + tools.SummarizeInput(input))}
+			s.call = call
+			s.mutex.Unlock()
+			defer func() {
+				s.mutex.Lock()
+				s.call = nil
+				s.mutex.Unlock()
+			}()
+
+			files := snapshot(toolCtx, tool, input)
+			start := update{
+				SessionUpdate: "tool_call",
+				ToolCallID:    call.id,
+				Title:         call.title,
+				Kind:          kindOf(tool),
+				Status:        statusInProgress,
+				RawInput:      input,
+			}
+			for _, file := range files {
+				start.Locations = append(start.Locations, toolCallLocation{Path: file.path})
+			}
+			s.update(start)
+
+			result, err := next(ctx, toolCtx, input)
+
+			done := update{SessionUpdate: "tool_call_update", ToolCallID: call.id, Status: statusCompleted}
+			switch {
+			case err != nil:
+				done.Status = statusFailed
+				done.Content = []toolCallContent{textContent(err.Error())}
+			case result != nil:
+				if result.IsError {
+					done.Status = statusFailed
+				}
+				done.Content = resultContent(toolCtx, files, result)
+			}
+			s.update(done)
+			return result, err
+		}
+	}
+}
+
+func kindOf(tool *tools.ToolDefinition) string {
+	if kind, ok := toolKinds[tool.Name]; ok {
+		return kind
+	}
+	if tool.Mutating && tool.Group == "file" {
+		return "edit"
+	}
+	return "other"
+}
+
+// fileState is a file a tool call names, as it was before the call
+type fileState struct {
+	shown   string // Canonical path, as tool results name the file
+	path    string // Absolute path
+	content *string
+}
+
+// snapshot reads the file named by the input's path before a tool that
+// modifies files runs, so its change can be shown as a diff
+func snapshot(toolCtx *tools.ToolContext, tool *tools.ToolDefinition, input json.RawMessage) []fileState {
+	var fields struct {
+		Path string `json:"path"`
+	}
+	if json.Unmarshal(input, &fields) != nil || fields.Path == "" || toolCtx.Workspace == nil {
+		return nil
+	}
+	// ResolvePath, not Resolve, so files the user is asked about still get
+	// their diff once approved
+	resolved, err := toolCtx.Workspace.ResolvePath(fields.Path)
+	if err != nil {
+		return nil
+	}
+	path := resolved.Abs
+	state := fileState{shown: resolved.Shown, path: path}
+	if tool.Mutating {
+		if data, err := toolCtx.Workspace.FS().ReadFile(path); err == nil {
+			content := string(data)
+			state.content = &content
+		}
+	}
+	return []fileState{state}
+}
+
+// resultContent shows what a call did: diffs of the files it changed, or
+// its text output
+func resultContent(toolCtx *tools.ToolContext, before []fileState, result *tools.ToolResult) []toolCallContent {
+	var content []toolCallContent
+	for _, changed := range result.Metadata.FilesChanged {
+		if toolCtx.Workspace == nil {
+			break
+		}
+		state := fileState{shown: changed}
+		if i := slices.IndexFunc(before, func(file fileState) bool { return file.shown == changed }); i >= 0 {
+			state = before[i]
+		} else if resolved, err := toolCtx.Workspace.ResolvePath(changed); err == nil {
+			state.path = resolved.Abs
+		} else {
+			continue
+		}
+		newText := ""
+		if !slices.Contains(result.Metadata.FilesDeleted, changed) {
+			data, err := toolCtx.Workspace.FS().ReadFile(state.path)
+			if err != nil {
+				continue
+			}
+			newText = string(data)
+		}
+		content = append(content, toolCallContent{Type: "diff", Path: state.path, OldText: state.content, NewText: &newText})
+	}
+	if len(content) > 0 {
+		return content
+	}
+
+	text := result.Text()
+	if len(text) > maxShownOutput {
+		text = text[:maxShownOutput] + fmt.Sprintf("\n... (%d more bytes)", len(text)-maxShownOutput)
+	}
+	if text == "" {
+		return nil
+	}
+	return []toolCallContent{textContent(fence(text))}
+}
+
+func textContent(text string) toolCallContent {
+	block := textBlock(text)
+	return toolCallContent{Type: "content", Content: &block}
+}
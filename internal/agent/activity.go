@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"io"
+	"os"
+)
+
+// Activity shows what the agent is busy with while the user waits, e.g.
+// "thinking…" or "running read_file main.go…"
+type Activity interface {
+	Start(description string)
+	Stop()
+}
+
+// Notifier alerts a user who may have looked away while the agent worked
+type Notifier interface {
+	Started() // The user sent a message
+	Notify(title, body string)
+}
+
+func (a *Agent) startActivity(description string) {
+	if a.activity != nil {
+		a.activity.Start(description)
+	}
+}
+
+func (a *Agent) stopActivity() {
+	if a.activity != nil {
+		a.activity.Stop()
+	}
+}
+
+// toolOutput is where tools print live progress, or nil in quiet mode. The
+// activity display is stopped before anything is written so the two never
+// share a line.
+func (a *Agent) toolOutput() io.Writer {
+	if a.quiet {
+		return nil
+	}
+	if a.activity == nil {
+		return os.Stdout
+	}
+	return stopOnWrite{activity: a.activity, w: os.Stdout}
+}
+
+type stopOnWrite struct {
+	activity Activity
+	w        io.Writer
+}
+
+func (s stopOnWrite) Write(p []byte) (int, error) {
+	s.activity.Stop()
+	return s.w.Write(p)
+}
@@ -4,8 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
+	"agent/internal/backup"
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/filecache"
+	"agent/internal/filelock"
+	"agent/internal/filestate"
+	"agent/internal/logging"
+	"agent/internal/metrics"
+	"agent/internal/models"
+	"agent/internal/theme"
 	"agent/internal/tools"
+	"agent/internal/workspace"
 	"github.com/anthropics/anthropic-sdk-go"
 )
 
@@ -13,94 +27,247 @@ import (
 type Agent struct {
 	client         *anthropic.Client
 	getUserMessage func() (string, bool)
-	tools          []tools.ToolDefinition
+	registry       *tools.Registry
+	workspace      *workspace.Workspace
+	confirmer      confirm.Confirmer
+	metrics        *metrics.Recorder
+	locks          *filelock.Manager
+	backups        *backup.Session
+	files          *filecache.Cache
+	seen           *filestate.Tracker
+	model          string
+	modelAliases   map[string]string
+	maxTokens      int
+	systemPrompt   string
+	maxTurns       int
+	maxCost        float64        // USD the session may spend; 0 means unlimited
+	spent          func() float64 // Estimated USD spent so far, for maxCost
+	notices        func() []string
+	usage          func(model string, usage anthropic.Usage)
+	reply          func(text string)
+	responseText   func(text string)
+	render         func(text string) string
+	activity       Activity
+	resumed        []anthropic.MessageParam
+	checkpoint     func(messages []anthropic.MessageParam)
+	logger         *slog.Logger
+	compose        func(draft string) (string, error)
+	notifier       Notifier
+	status         func(model string) string
+	quiet          bool // Tool calls and their live output are not shown; see /verbosity
+	labels         Labels
 }
 
 // NewAgent creates a new Agent instance
-func NewAgent(client *anthropic.Client, getUserMessage func() (string, bool), toolDefs []tools.ToolDefinition) *Agent {
-	return &Agent{
+func NewAgent(client *anthropic.Client, getUserMessage func() (string, bool), registry *tools.Registry, opts ...Option) *Agent {
+	a := &Agent{
 		client:         client,
 		getUserMessage: getUserMessage,
-		tools:          toolDefs,
+		registry:       registry,
+		locks:          filelock.NewManager(),
+		seen:           filestate.New(),
+		model:          config.DefaultModel,
+		maxTokens:      config.DefaultMaxTokens,
+		logger:         logging.Discard,
+		labels:         DefaultLabels,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.confirmer == nil {
+		a.confirmer = confirm.NewService(getUserMessage, false)
+	}
+	a.setQuiet(a.quiet)
+	return a
 }
 
 // Run starts the main conversation loop
 func (a *Agent) Run(ctx context.Context) error {
-	conversation := []anthropic.MessageParam{}
+	conversation := append([]anthropic.MessageParam{}, a.resumed...)
 
 	fmt.Println("Chat with Claude (use 'ctrl-c' to quit)")
 
 	readUserInput := true
+	turns := 0
 	for {
+		if !readUserInput && a.maxTurns > 0 && turns >= a.maxTurns {
+			fmt.Printf("Stopped after %d turns without user input (limits.max_turns); reply to continue\n", turns)
+			a.printStatus()
+			a.notify("Claude is waiting", fmt.Sprintf("Stopped after %d turns; reply to continue", turns))
+			readUserInput = true
+		}
 		if readUserInput {
-			fmt.Print("\u001b[94mYou\u001b[0m: ")
+			turns = 0
+			fmt.Print(a.prompt())
 			userInput, ok := a.getUserMessage()
 			if !ok {
 				break
 			}
+			if message, isEditor := a.composeMessage(userInput); isEditor {
+				if strings.TrimSpace(message) == "" {
+					continue
+				}
+				userInput = message
+			} else if a.handleSlashCommand(userInput) {
+				continue
+			}
 
-			userMessage := anthropic.NewUserMessage(anthropic.NewTextBlock(userInput))
-			conversation = append(conversation, userMessage)
+			if a.notifier != nil {
+				a.notifier.Started()
+			}
+			blocks := append(a.pendingNotices(), anthropic.NewTextBlock(userInput))
+			// After a turn limit the pending tool results are still the last message; reply in the same turn
+			if last := len(conversation) - 1; last >= 0 && conversation[last].Role == anthropic.MessageParamRoleUser {
+				conversation[last].Content = append(conversation[last].Content, blocks...)
+			} else {
+				conversation = append(conversation, anthropic.NewUserMessage(blocks...))
+			}
+			a.saveCheckpoint(conversation)
 		}
 
+		if err := a.checkSpending(); err != nil {
+			return err
+		}
+		a.startActivity("thinking…")
 		message, err := a.runInference(ctx, conversation)
+		a.stopActivity()
 		if err != nil {
 			return err
 		}
+		if a.usage != nil {
+			a.usage(a.model, message.Usage)
+		}
+		turns++
 		conversation = append(conversation, message.ToParam())
 
 		toolResults := []anthropic.ContentBlockParamUnion{}
+		reply := ""
 		for _, content := range message.Content {
 			switch content.Type {
 			case "text":
-				fmt.Printf("\u001b[93mClaude\u001b[0m: %s\n", content.Text)
+				a.printResponse(content.Text)
+				if a.responseText != nil {
+					a.responseText(content.Text)
+				}
+				reply = content.Text
 			case "tool_use":
-				result := a.executeTool(content.ID, content.Name, content.Input)
+				result := a.executeTool(ctx, content.ID, content.Name, content.Input)
 				toolResults = append(toolResults, result)
 			}
 		}
 		if len(toolResults) == 0 {
+			a.saveCheckpoint(conversation)
+			a.printStatus()
+			a.notify("Claude replied", reply)
+			if a.reply != nil {
+				a.reply(reply)
+			}
 			readUserInput = true
 			continue
 		}
 		readUserInput = false
-		conversation = append(conversation, anthropic.NewUserMessage(toolResults...))
+		conversation = append(conversation, anthropic.NewUserMessage(append(toolResults, a.pendingNotices()...)...))
+		a.saveCheckpoint(conversation)
 	}
 
 	return nil
 }
 
-// executeTool finds and executes the requested tool
-func (a *Agent) executeTool(id, name string, input json.RawMessage) anthropic.ContentBlockParamUnion {
-	var toolDef tools.ToolDefinition
-	var found bool
-	for _, tool := range a.tools {
-		if tool.Name == name {
-			toolDef = tool
-			found = true
-			break
-		}
+// checkSpending fails once the session has spent its limit, before the
+// next request adds to it
+func (a *Agent) checkSpending() error {
+	if a.maxCost <= 0 || a.spent == nil {
+		return nil
 	}
-	if !found {
-		return anthropic.NewToolResultBlock(id, "tool not found", true)
+	if spent := a.spent(); spent >= a.maxCost {
+		return fmt.Errorf("the session has spent an estimated $%.2f, reaching its limit of $%.2f (limits.max_cost)", spent, a.maxCost)
 	}
+	return nil
+}
 
-	fmt.Printf("\u001b[92mtool\u001b[0m: %s(%s)\n", name, input)
+// printStatus prints the status line, if any, before the user is asked
+func (a *Agent) printStatus() {
+	if a.status == nil || a.quiet {
+		return
+	}
+	if line := a.status(a.model); line != "" {
+		fmt.Println(theme.Paint(theme.Faint, line))
+	}
+}
+
+// notify alerts the user through the notifier, if any
+func (a *Agent) notify(title, body string) {
+	if a.notifier != nil {
+		a.notifier.Notify(title, body)
+	}
+}
+
+// saveCheckpoint hands the conversation to the checkpoint callback, if any.
+// A tool call is never saved without its result, so every checkpoint can
+// be sent to the API again.
+func (a *Agent) saveCheckpoint(conversation []anthropic.MessageParam) {
+	if a.checkpoint != nil {
+		a.checkpoint(conversation)
+	}
+}
+
+// printResponse prints assistant text, rendered when a renderer is set
+func (a *Agent) printResponse(text string) {
+	if a.render == nil {
+		fmt.Printf("%s: %s\n", a.label(theme.Assistant, a.labels.Assistant), text)
+		return
+	}
+	fmt.Printf("%s:\n%s\n", a.label(theme.Assistant, a.labels.Assistant), a.render(text))
+}
+
+// pendingNotices returns the notices raised since the last message as text blocks
+func (a *Agent) pendingNotices() []anthropic.ContentBlockParamUnion {
+	if a.notices == nil {
+		return nil
+	}
+	var blocks []anthropic.ContentBlockParamUnion
+	for _, notice := range a.notices() {
+		blocks = append(blocks, anthropic.NewTextBlock("[billdozer] "+notice))
+	}
+	return blocks
+}
+
+// executeTool finds and executes the requested tool
+func (a *Agent) executeTool(ctx context.Context, id, name string, input json.RawMessage) anthropic.ContentBlockParamUnion {
+	toolDef, err := a.registry.Resolve(name)
+	if err != nil {
+		return anthropic.NewToolResultBlock(id, formatToolError(err), true)
+	}
+
+	if !a.quiet {
+		fmt.Printf("%s: %s(%s)\n", a.label(theme.Tool, a.labels.Tool), name, input)
+	}
 	toolCtx := &tools.ToolContext{
 		GetUserInput: a.getUserMessage,
+		Workspace:    a.workspace,
+		Confirmer:    a.confirmer,
+		Locks:        a.locks,
+		Backups:      a.backups,
+		Files:        a.files,
+		Seen:         a.seen,
+		Output:       a.toolOutput(),
 	}
-	response, err := toolDef.Function(toolCtx, input)
+	execCtx, cancel := a.toolExecutionContext(ctx, toolDef)
+	defer cancel()
+
+	a.startActivity(fmt.Sprintf("running %s %s…", name, tools.SummarizeInput(input)))
+	result, err := toolDef.Function(execCtx, toolCtx, input)
+	a.stopActivity()
 	if err != nil {
-		return anthropic.NewToolResultBlock(id, err.Error(), true)
+		return anthropic.NewToolResultBlock(id, formatToolError(err), true)
 	}
-	return anthropic.NewToolResultBlock(id, response, false)
+	return toToolResultBlock(id, result, models.Lookup(a.model).Vision)
 }
 
 // runInference sends messages to the Anthropic API and returns the response
 func (a *Agent) runInference(ctx context.Context, conversation []anthropic.MessageParam) (*anthropic.Message, error) {
 	anthropicTools := []anthropic.ToolUnionParam{}
-	for _, tool := range a.tools {
+	for _, tool := range a.registry.GetAll() {
 		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{
 			OfTool: &anthropic.ToolParam{
 				Name:        tool.Name,
@@ -110,11 +277,38 @@ func (a *Agent) runInference(ctx context.Context, conversation []anthropic.Messa
 		})
 	}
 
-	message, err := a.client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaude4Sonnet20250514,
-		MaxTokens: int64(1024),
+	// limits.max_tokens may exceed what the current model can produce
+	maxTokens := a.maxTokens
+	if limit := models.Lookup(a.model).MaxOutput; limit > 0 && maxTokens > limit {
+		maxTokens = limit
+	}
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(a.model),
+		MaxTokens: int64(maxTokens),
 		Messages:  conversation,
 		Tools:     anthropicTools,
-	})
+	}
+	if a.systemPrompt != "" {
+		params.System = []anthropic.TextBlockParam{{Text: a.systemPrompt}}
+	}
+	a.logger.InfoContext(ctx, "api request", "model", a.model, "messages", len(conversation), "tools", len(anthropicTools), "max_tokens", maxTokens)
+	start := time.Now()
+	message, err := a.client.Messages.New(ctx, params)
+	if err != nil {
+		// The error ends the turn and is reported to the user by the caller
+		a.logger.InfoContext(ctx, "api request failed", "model", a.model, "duration", time.Since(start), "error", err)
+		return message, err
+	}
+	a.logger.InfoContext(ctx, "api response", "id", message.ID, "stop_reason", message.StopReason, "duration", time.Since(start),
+		"input_tokens", message.Usage.InputTokens, "output_tokens", message.Usage.OutputTokens,
+		"cache_read_tokens", message.Usage.CacheReadInputTokens, "cache_write_tokens", message.Usage.CacheCreationInputTokens)
+	for _, content := range message.Content {
+		switch content.Type {
+		case "text":
+			a.logger.DebugContext(ctx, "response text", "text", content.Text)
+		case "tool_use":
+			a.logger.DebugContext(ctx, "tool requested", "id", content.ID, "tool", content.Name)
+		}
+	}
 	return message, err
 }
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/models"
+	"agent/internal/usage"
+)
+
+// handleSlashCommand runs a local "/command" typed by the user.
+// It returns true when the input was consumed and should not be sent to Claude.
+func (a *Agent) handleSlashCommand(input string) bool {
+	command := strings.TrimSpace(input)
+	if !strings.HasPrefix(command, "/") {
+		return false
+	}
+
+	fields := strings.Fields(command)
+	switch fields[0] {
+	case "/readonly":
+		a.registry.SetReadOnly(!a.registry.ReadOnly())
+		if a.registry.ReadOnly() {
+			fmt.Println("Read-only mode enabled: write, edit, delete and command tools are disabled")
+		} else {
+			fmt.Println("Read-only mode disabled: all tools are available")
+		}
+	case "/stats":
+		if a.metrics == nil {
+			fmt.Println("Tool metrics are not being recorded")
+			break
+		}
+		a.metrics.WriteTable(os.Stdout)
+	case "/model":
+		a.switchModel(fields[1:])
+	case "/verbosity":
+		a.switchVerbosity(fields[1:])
+	default:
+		fmt.Printf("Unknown command %s. Available commands: /editor, /model, /readonly, /stats, /verbosity\n", command)
+	}
+	return true
+}
+
+// switchModel shows the current model and aliases, or switches to the given model or alias
+func (a *Agent) switchModel(args []string) {
+	if len(args) == 0 {
+		fmt.Printf("Model: %s\n  %s\n", a.model, describeModel(models.Lookup(a.model)))
+		for _, name := range config.AliasNames(a.modelAliases) {
+			fmt.Printf("  %-10s %s\n", name, a.modelAliases[name])
+		}
+		fmt.Println("Use /model <alias or model ID> to switch")
+		return
+	}
+	a.model = config.ResolveModel(a.modelAliases, args[0])
+	fmt.Printf("Switched to %s for the rest of the session\n  %s\n", a.model, describeModel(models.Lookup(a.model)))
+}
+
+// describeModel summarizes the catalog entry of a model on one line, e.g.
+// "context 200k tokens, output up to 64k, vision, thinking, $3/$15 per Mtok"
+func describeModel(info models.Info) string {
+	if !info.Known {
+		return fmt.Sprintf("not in the model catalog; a %s token context is assumed (describe it under model_info)", usage.Tokens(info.ContextWindow))
+	}
+	parts := []string{"context " + usage.Tokens(info.ContextWindow) + " tokens"}
+	if info.MaxOutput > 0 {
+		parts = append(parts, "output up to "+usage.Tokens(int64(info.MaxOutput)))
+	}
+	if features := info.Features(); features != "none" {
+		parts = append(parts, features)
+	}
+	if info.Price != nil {
+		parts = append(parts, fmt.Sprintf("$%g/$%g per Mtok", info.Price.Input, info.Price.Output))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// composeMessage handles "/editor [draft]", which opens an external editor
+// on the draft and returns what was written there as the message to send.
+// isEditor is false for any other input; message is empty when nothing was
+// written or no editor is available.
+func (a *Agent) composeMessage(input string) (message string, isEditor bool) {
+	command, draft, _ := strings.Cut(strings.TrimSpace(input), " ")
+	if command != "/editor" {
+		return "", false
+	}
+	if a.compose == nil {
+		fmt.Println("/editor is only available in the plain terminal interface")
+		return "", true
+	}
+	message, err := a.compose(strings.TrimSpace(draft))
+	switch {
+	case err != nil:
+		fmt.Println(err)
+	case strings.TrimSpace(message) == "":
+		fmt.Println("Nothing was written; no message sent")
+	default:
+		// Show what is sent, since the editor's screen is gone
+		fmt.Println(message)
+	}
+	return message, true
+}
+
+// switchVerbosity sets quiet or normal output, or toggles it without an argument
+func (a *Agent) switchVerbosity(args []string) {
+	quiet := !a.quiet
+	if len(args) > 0 {
+		switch args[0] {
+		case "quiet":
+			quiet = true
+		case "normal":
+			quiet = false
+		default:
+			fmt.Println("Use /verbosity quiet, /verbosity normal, or /verbosity alone to toggle")
+			return
+		}
+	}
+	a.setQuiet(quiet)
+	if quiet {
+		fmt.Println("Quiet: tool calls and their output are hidden; only replies and confirmations are shown")
+	} else {
+		fmt.Println("Normal: tool calls and their output are shown")
+	}
+}
+
+// setQuiet switches quiet mode for the agent and its confirmer
+func (a *Agent) setQuiet(quiet bool) {
+	a.quiet = quiet
+	if confirmer, ok := a.confirmer.(interface{ SetQuiet(bool) }); ok {
+		confirmer.SetQuiet(quiet)
+	}
+}
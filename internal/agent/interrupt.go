@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"agent/internal/tools"
+)
+
+// toolExecutionContext derives the context a tool runs under. It applies the
+// tool's timeout and cancels the context on Ctrl+C, so an interrupt stops the
+// running tool instead of killing the whole session. The returned cancel
+// function restores default interrupt handling.
+func (a *Agent) toolExecutionContext(ctx context.Context, tool *tools.ToolDefinition) (context.Context, context.CancelFunc) {
+	var cancelTimeout context.CancelFunc = func() {}
+	if tool.Timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, tool.Timeout)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-interrupts:
+			a.stopActivity()
+			fmt.Printf("\nInterrupted: stopping %s\n", tool.Name)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupts)
+		close(done)
+		cancel()
+		cancelTimeout()
+	}
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"time"
+
+	"agent/internal/theme"
+)
+
+// Labels names the speakers in the transcript
+type Labels struct {
+	User       string // Before your messages; "You" when empty
+	Assistant  string // Before Claude's replies; "Claude" when empty
+	Tool       string // Before tool calls; "tool" when empty
+	Prompt     string // Printed to ask for input; the user label and ": " when empty
+	Timestamps bool   // Prefix each label with the time of day
+}
+
+// DefaultLabels are used for labels left empty
+var DefaultLabels = Labels{User: "You", Assistant: "Claude", Tool: "tool"}
+
+// WithDefaults fills empty labels from DefaultLabels
+func (l Labels) WithDefaults() Labels {
+	if l.User == "" {
+		l.User = DefaultLabels.User
+	}
+	if l.Assistant == "" {
+		l.Assistant = DefaultLabels.Assistant
+	}
+	if l.Tool == "" {
+		l.Tool = DefaultLabels.Tool
+	}
+	return l
+}
+
+// label styles a speaker's label for role, after the time when timestamps are on
+func (a *Agent) label(role theme.Role, name string) string {
+	if !a.labels.Timestamps {
+		return theme.Paint(role, name)
+	}
+	return theme.Paint(theme.Faint, time.Now().Format("[15:04:05]")) + " " + theme.Paint(role, name)
+}
+
+// prompt is printed before reading the user's next message
+func (a *Agent) prompt() string {
+	if a.labels.Prompt == "" {
+		return a.label(theme.User, a.labels.User) + ": "
+	}
+	return a.label(theme.User, a.labels.Prompt)
+}
@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"log/slog"
+
+	"agent/internal/backup"
+	"agent/internal/confirm"
+	"agent/internal/filecache"
+	"agent/internal/filestate"
+	"agent/internal/metrics"
+	"agent/internal/workspace"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Option configures optional Agent dependencies
+type Option func(*Agent)
+
+// WithWorkspace confines file tools to the given workspace
+func WithWorkspace(ws *workspace.Workspace) Option {
+	return func(a *Agent) {
+		a.workspace = ws
+	}
+}
+
+// WithConfirmer sets the service used to ask the user before risky operations
+func WithConfirmer(confirmer confirm.Confirmer) Option {
+	return func(a *Agent) {
+		a.confirmer = confirmer
+	}
+}
+
+// WithBackups keeps a copy of every file a tool overwrites or deletes
+func WithBackups(backups *backup.Session) Option {
+	return func(a *Agent) {
+		a.backups = backups
+	}
+}
+
+// WithFileCache sets the cache read tools keep file contents in
+func WithFileCache(files *filecache.Cache) Option {
+	return func(a *Agent) {
+		a.files = files
+	}
+}
+
+// WithFileVersions sets the record of the file versions the agent has
+// seen, for sharing it with tools run outside the agent
+func WithFileVersions(seen *filestate.Tracker) Option {
+	return func(a *Agent) {
+		a.seen = seen
+	}
+}
+
+// WithMetrics sets the recorder reported by /stats
+func WithMetrics(recorder *metrics.Recorder) Option {
+	return func(a *Agent) {
+		a.metrics = recorder
+	}
+}
+
+// WithModel sets the model and the maximum tokens per response
+func WithModel(model string, maxTokens int) Option {
+	return func(a *Agent) {
+		a.model = model
+		a.maxTokens = maxTokens
+	}
+}
+
+// WithSystemPrompt sets the system prompt sent with every request
+func WithSystemPrompt(prompt string) Option {
+	return func(a *Agent) {
+		a.systemPrompt = prompt
+	}
+}
+
+// WithMaxTurns limits consecutive model turns per user message; 0 means unlimited
+func WithMaxTurns(turns int) Option {
+	return func(a *Agent) {
+		a.maxTurns = turns
+	}
+}
+
+// WithNotices sets a source of session notices, such as config reloads.
+// Pending notices are passed to the model with the next message it receives.
+func WithNotices(pending func() []string) Option {
+	return func(a *Agent) {
+		a.notices = pending
+	}
+}
+
+// WithSpendingLimit stops the session before a request once spent, the
+// estimated cost so far in USD, reaches limit; 0 means unlimited
+func WithSpendingLimit(limit float64, spent func() float64) Option {
+	return func(a *Agent) {
+		a.maxCost = limit
+		a.spent = spent
+	}
+}
+
+// WithReply sets a callback that receives Claude's reply whenever a turn
+// ends without further tool calls
+func WithReply(reply func(text string)) Option {
+	return func(a *Agent) {
+		a.reply = reply
+	}
+}
+
+// WithResponseText sets a callback that receives every text block of
+// Claude's responses, including those before tool calls
+func WithResponseText(text func(text string)) Option {
+	return func(a *Agent) {
+		a.responseText = text
+	}
+}
+
+// WithModelAliases sets the aliases the /model command accepts
+func WithModelAliases(aliases map[string]string) Option {
+	return func(a *Agent) {
+		a.modelAliases = aliases
+	}
+}
+
+// WithUsage sets a callback that receives the token usage of every response
+func WithUsage(record func(model string, usage anthropic.Usage)) Option {
+	return func(a *Agent) {
+		a.usage = record
+	}
+}
+
+// WithRenderer sets how assistant text is rendered for the terminal, e.g.
+// as markdown; without one it is printed as is
+func WithRenderer(render func(text string) string) Option {
+	return func(a *Agent) {
+		a.render = render
+	}
+}
+
+// WithActivity sets where the agent reports what it is busy with, such as
+// a spinner, while the user waits on the API or a tool
+func WithActivity(activity Activity) Option {
+	return func(a *Agent) {
+		a.activity = activity
+	}
+}
+
+// WithConversation continues an earlier conversation instead of starting
+// an empty one
+func WithConversation(messages []anthropic.MessageParam) Option {
+	return func(a *Agent) {
+		a.resumed = messages
+	}
+}
+
+// WithCheckpoint sets a callback that receives the conversation whenever it
+// is in a state that can be resumed: after each user message, each final
+// response and each round of tool results
+func WithCheckpoint(save func(messages []anthropic.MessageParam)) Option {
+	return func(a *Agent) {
+		a.checkpoint = save
+	}
+}
+
+// WithLogger sets where API requests are logged for --verbose and --debug
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Agent) {
+		a.logger = logger
+	}
+}
+
+// WithComposer sets the external editor that /editor opens to write a
+// message; without one /editor is unavailable
+func WithComposer(compose func(draft string) (string, error)) Option {
+	return func(a *Agent) {
+		a.compose = compose
+	}
+}
+
+// WithNotifier sets how the user is alerted when a turn ends, e.g. with a
+// terminal bell after a long wait
+func WithNotifier(notifier Notifier) Option {
+	return func(a *Agent) {
+		a.notifier = notifier
+	}
+}
+
+// WithStatus sets the status line printed after each turn, e.g. the
+// context and token usage; it receives the current model
+func WithStatus(status func(model string) string) Option {
+	return func(a *Agent) {
+		a.status = status
+	}
+}
+
+// WithQuiet hides tool calls, their live output and the status line, so
+// only Claude's replies and confirmations are shown
+func WithQuiet(quiet bool) Option {
+	return func(a *Agent) {
+		a.quiet = quiet
+	}
+}
+
+// WithLabels sets how speakers are labeled in the transcript and the
+// prompt printed before input; empty labels keep their defaults
+func WithLabels(labels Labels) Option {
+	return func(a *Agent) {
+		a.labels = labels.WithDefaults()
+	}
+}
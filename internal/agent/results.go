@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"agent/internal/tools"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// toToolResultBlock maps a structured tool result onto Anthropic tool_result
+// content. Images are replaced by a note for models without vision.
+func toToolResultBlock(id string, result *tools.ToolResult, vision bool) anthropic.ContentBlockParamUnion {
+	if result == nil {
+		return anthropic.NewToolResultBlock(id, "(no output)", false)
+	}
+
+	var content []anthropic.ToolResultBlockParamContentUnion
+	for _, block := range result.Content {
+		switch block.Type {
+		case tools.ContentText:
+			content = append(content, anthropic.ToolResultBlockParamContentUnion{
+				OfText: &anthropic.TextBlockParam{Text: block.Text},
+			})
+		case tools.ContentImage:
+			if !vision {
+				content = append(content, anthropic.ToolResultBlockParamContentUnion{
+					OfText: &anthropic.TextBlockParam{Text: fmt.Sprintf("[%s image omitted: the current model does not accept images]", block.MediaType)},
+				})
+				continue
+			}
+			content = append(content, anthropic.ToolResultBlockParamContentUnion{
+				OfImage: &anthropic.ImageBlockParam{
+					Source: anthropic.ImageBlockParamSourceUnion{
+						OfBase64: &anthropic.Base64ImageSourceParam{
+							Data:      base64.StdEncoding.EncodeToString(block.Data),
+							MediaType: anthropic.Base64ImageSourceMediaType(block.MediaType),
+						},
+					},
+				},
+			})
+		}
+	}
+
+	if hint := result.ErrorKind.Hint(); result.IsError && hint != "" {
+		content = append(content, anthropic.ToolResultBlockParamContentUnion{
+			OfText: &anthropic.TextBlockParam{Text: fmt.Sprintf("[%s] %s", result.ErrorKind, hint)},
+		})
+	}
+
+	// The API rejects empty text blocks, so represent empty output explicitly
+	if len(content) == 0 || (len(content) == 1 && content[0].OfText != nil && content[0].OfText.Text == "") {
+		content = []anthropic.ToolResultBlockParamContentUnion{
+			{OfText: &anthropic.TextBlockParam{Text: "(no output)"}},
+		}
+	}
+
+	return anthropic.ContentBlockParamUnion{OfToolResult: &anthropic.ToolResultBlockParam{
+		ToolUseID: id,
+		Content:   content,
+		IsError:   anthropic.Bool(result.IsError),
+	}}
+}
+
+// formatToolError renders a failed call for the model, prefixed with its
+// error kind and followed by a hint when the kind is known
+func formatToolError(err error) string {
+	kind := tools.Classify(err)
+	if kind == tools.ErrorUnknown {
+		return err.Error()
+	}
+	return fmt.Sprintf("[%s] %s\n%s", kind, err.Error(), kind.Hint())
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/secrets"
+)
+
+// EnvVar is the environment variable checked first for the Anthropic API key
+const EnvVar = "ANTHROPIC_API_KEY"
+
+// ProviderEnvVars are checked first for the API keys of the other providers
+var ProviderEnvVars = map[string]string{
+	"azure":      "AZURE_OPENAI_API_KEY",
+	"openrouter": "OPENROUTER_API_KEY",
+	"gemini":     "GEMINI_API_KEY",
+}
+
+// KeychainName is the keychain entry billdozer auth login stores the key under
+const KeychainName = "billdozer-anthropic"
+
+// credentialsFile holds the key in the global config dir when no keychain is available
+const credentialsFile = "credentials"
+
+// Error message constants
+const (
+	errMsgNoKey       = "no Anthropic API key found. Tried, in order:\n%s\nSet %s, add api_key to billdozer.yml, or run \"billdozer auth login\""
+	errMsgNoOtherKey  = "no %s API key found. Tried, in order:\n%s\nSet %s or add api_key to billdozer.yml"
+	errMsgEmptyKey    = "the API key is empty"
+	errMsgNoConfigDir = "cannot locate the global config directory: neither XDG_CONFIG_HOME nor the home directory is set"
+)
+
+// Credential is a resolved API key and a description of where it came from
+type Credential struct {
+	Key    string
+	Source string
+}
+
+// Resolve finds the API key, trying in order: the ANTHROPIC_API_KEY
+// environment variable, api_key in the loaded config, the OS keychain entry
+// written by "auth login", and the credentials file in the global config
+// dir. For other providers only their environment variable (see
+// ProviderEnvVars) and api_key are tried. The error lists every source
+// tried and why it was skipped.
+func Resolve(cfg *config.Config) (*Credential, error) {
+	var tried []string
+
+	envVar, provider := EnvVar, config.DefaultProvider
+	if cfg != nil && ProviderEnvVars[cfg.Provider] != "" {
+		envVar, provider = ProviderEnvVars[cfg.Provider], cfg.Provider
+	}
+	if key := strings.TrimSpace(os.Getenv(envVar)); key != "" {
+		return &Credential{Key: key, Source: "environment variable " + envVar}, nil
+	}
+	tried = append(tried, "environment variable "+envVar+": not set")
+
+	if cfg != nil && cfg.APIKey != "" {
+		return &Credential{Key: cfg.APIKey, Source: "api_key in " + configSource(cfg)}, nil
+	}
+	tried = append(tried, "api_key in billdozer.yml or the global config.yml: not set")
+	if provider != config.DefaultProvider {
+		return nil, fmt.Errorf(errMsgNoOtherKey, provider, numbered(tried), envVar)
+	}
+
+	key, err := secrets.Resolve(secrets.Prefix + "keychain/" + KeychainName)
+	if err == nil {
+		return &Credential{Key: key, Source: "keychain entry " + KeychainName}, nil
+	}
+	tried = append(tried, "keychain entry "+KeychainName+": "+err.Error())
+
+	path, err := CredentialsPath()
+	if err == nil {
+		var data []byte
+		data, err = os.ReadFile(path)
+		if key := strings.TrimSpace(string(data)); err == nil && key != "" {
+			return &Credential{Key: key, Source: "credentials file " + path}, nil
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			err = errors.New("not found")
+		} else if err == nil {
+			err = errors.New("empty")
+		}
+		tried = append(tried, "credentials file "+path+": "+err.Error())
+	}
+
+	return nil, fmt.Errorf(errMsgNoKey, numbered(tried), EnvVar)
+}
+
+// numbered lists the sources tried, one per line
+func numbered(tried []string) string {
+	lines := make([]string, len(tried))
+	for i, attempt := range tried {
+		lines[i] = fmt.Sprintf("  %d. %s", i+1, attempt)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configSource names the highest-precedence config file that was loaded
+func configSource(cfg *config.Config) string {
+	if len(cfg.Sources) == 0 {
+		return "config"
+	}
+	return cfg.Sources[len(cfg.Sources)-1]
+}
+
+// Store saves the key in the OS keychain. When no keychain is available it
+// falls back to the credentials file, readable only by the current user. It
+// returns a description of where the key was stored.
+func Store(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New(errMsgEmptyKey)
+	}
+	keychainErr := secrets.StoreKeychain(KeychainName, key)
+	if keychainErr == nil {
+		return "keychain entry " + KeychainName, nil
+	}
+
+	path, err := CredentialsPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("credentials file %s (keychain unavailable: %v)", path, keychainErr), nil
+}
+
+// Delete removes the key from the keychain and the credentials file. It
+// returns the places a key was removed from.
+func Delete() ([]string, error) {
+	var removed []string
+	if err := secrets.DeleteKeychain(KeychainName); err == nil {
+		removed = append(removed, "keychain entry "+KeychainName)
+	}
+
+	path, err := CredentialsPath()
+	if err != nil {
+		return removed, err
+	}
+	if err := os.Remove(path); err == nil {
+		removed = append(removed, "credentials file "+path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return removed, err
+	}
+	return removed, nil
+}
+
+// CredentialsPath returns the fallback credentials file in the global config dir
+func CredentialsPath() (string, error) {
+	dir := config.GlobalConfigDir()
+	if dir == "" {
+		return "", errors.New(errMsgNoConfigDir)
+	}
+	return filepath.Join(dir, credentialsFile), nil
+}
@@ -0,0 +1,210 @@
+// Package backup keeps copies of files before the agent overwrites or
+// deletes them, so a change that went wrong can be taken back with the
+// restore_backup tool or "billdozer backups restore". Each session backs
+// up into a directory of its own, and directories untouched for longer than
+// the retention period are removed when a session starts.
+package backup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons a file was backed up
+const (
+	ReasonOverwrite = "overwrite"
+	ReasonDelete    = "delete"
+	ReasonRestore   = "restore" // The file a restore replaced
+)
+
+// Entry describes one backed-up file. Its content is kept next to it.
+type Entry struct {
+	ID      string      `json:"id"`             // <session>/<n>
+	Path    string      `json:"path"`           // Absolute path of the file
+	Host    string      `json:"host,omitempty"` // Host of an ssh:// workspace; empty when local
+	Reason  string      `json:"reason"`
+	Mode    fs.FileMode `json:"mode"`
+	Size    int64       `json:"size"`
+	Created time.Time   `json:"created"`
+}
+
+// Store keeps the backups of all sessions in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store in dir, which is created on the first backup
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Prune removes the backups of sessions whose last backup is older than
+// retention
+func (st *Store) Prune(retention time.Duration) error {
+	entries, err := os.ReadDir(st.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-retention)
+	var problems []error
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(st.dir, entry.Name())); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// NewSession starts backing up the files of one session
+func (st *Store) NewSession() *Session {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return &Session{store: st, id: time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)}
+}
+
+// List returns the backups of a session, or of all sessions when session
+// is empty, most recent first
+func (st *Store) List(session string) ([]*Entry, error) {
+	sessions := []string{session}
+	if session == "" {
+		dirs, err := os.ReadDir(st.dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		sessions = nil
+		for _, dir := range dirs {
+			if dir.IsDir() {
+				sessions = append(sessions, dir.Name())
+			}
+		}
+	}
+
+	var found []*Entry
+	for _, session := range sessions {
+		files, err := filepath.Glob(filepath.Join(st.dir, session, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			entry, err := readEntry(file)
+			if err != nil {
+				continue // Being written, or damaged
+			}
+			found = append(found, entry)
+		}
+	}
+	slices.SortFunc(found, func(a, b *Entry) int { return b.Created.Compare(a.Created) })
+	return found, nil
+}
+
+// Load returns a backup and the content it kept
+func (st *Store) Load(id string) (*Entry, []byte, error) {
+	session, n, ok := strings.Cut(id, "/")
+	if _, err := strconv.Atoi(n); !ok || err != nil || session == "" || strings.ContainsAny(session, `/\.`) {
+		return nil, nil, fmt.Errorf("invalid backup ID %q; IDs look like 20240501-093000-ab12/3", id)
+	}
+	base := filepath.Join(st.dir, session, n)
+	entry, err := readEntry(base + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("no backup %q; it may have been removed after backups.retention_days", id)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(base + ".data")
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, data, nil
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+	}
+	return &entry, nil
+}
+
+// Session backs up the files one session changes
+type Session struct {
+	store *Store
+	id    string
+
+	mutex sync.Mutex
+	next  int
+}
+
+// ID names the session's backups, which have IDs starting with it
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Store returns the store the session backs up into
+func (s *Session) Store() *Store {
+	return s.store
+}
+
+// Save keeps a copy of the file at path, on host when it is remote, before
+// it is changed for reason. Backups are only readable by the user, since
+// they may hold anything the workspace did.
+func (s *Session) Save(host, path string, data []byte, mode fs.FileMode, reason string) (*Entry, error) {
+	s.mutex.Lock()
+	s.next++
+	n := s.next
+	s.mutex.Unlock()
+
+	dir := filepath.Join(s.store.dir, s.id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	entry := &Entry{
+		ID:      fmt.Sprintf("%s/%d", s.id, n),
+		Path:    path,
+		Host:    host,
+		Reason:  reason,
+		Mode:    mode.Perm(),
+		Size:    int64(len(data)),
+		Created: time.Now(),
+	}
+	base := filepath.Join(dir, strconv.Itoa(n))
+	if err := os.WriteFile(base+".data", data, 0600); err != nil {
+		return nil, err
+	}
+	// The entry is written last, so a listed backup always has its content
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(base+".json", encoded, 0600); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// List returns the session's backups, most recent first
+func (s *Session) List() ([]*Entry, error) {
+	return s.store.List(s.id)
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"agent/internal/diff"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Cache remembers the results of idempotent read tools for the session so
+// that repeating a call whose answer has not changed costs a short marker
+// instead of the full content. A nil *Cache is valid and caches nothing.
+type Cache struct {
+	mutex   sync.Mutex
+	entries map[string]entry
+	sent    map[string]sentText // Last full output of Diffable calls, kept across Clear
+	hits    int
+}
+
+// entry is the last result returned for one tool call
+type entry struct {
+	hash        [sha256.Size]byte
+	fingerprint map[string]fileState // Keyed by source path
+	at          time.Time
+}
+
+// sentText is the output the model last had for a Diffable call: diffs
+// are made against it
+type sentText struct {
+	text string
+	at   time.Time
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// New creates an empty session cache
+func New() *Cache {
+	return &Cache{entries: make(map[string]entry), sent: make(map[string]sentText)}
+}
+
+// Clear forgets all cached results
+func (c *Cache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[string]entry)
+}
+
+// Hits returns how many calls were answered with an unchanged marker
+func (c *Cache) Hits() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hits
+}
+
+// Middleware short-circuits repeated calls to Cacheable tools. When every
+// source the previous result was derived from still has the same mtime and
+// size, the tool is not run at all; otherwise it runs and its output is
+// compared with the previous one. Either way an unchanged result is replaced
+// with a marker pointing the model at its earlier output. Any call to a
+// mutating tool clears the cache, since commands can change files without
+// reporting them.
+//
+// A changed result of a Diffable tool is sent as a diff against the output
+// the model last got for the same call, mutating calls in between or not,
+// when the diff is the smaller of the two. An input with "full": true gets
+// the whole result.
+func Middleware(c *Cache) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			if c == nil || toolCtx == nil || toolCtx.Tool == nil {
+				return next(ctx, toolCtx, input)
+			}
+			tool := toolCtx.Tool
+			if tool.Mutating {
+				defer c.Clear()
+				return next(ctx, toolCtx, input)
+			}
+			if !tool.Cacheable {
+				return next(ctx, toolCtx, input)
+			}
+
+			// Sources are stat'ed where they live, which is another host for remote workspaces
+			fsys := toolCtx.Workspace.FS()
+			key := cacheKey(tool.Name, input)
+			full := false
+			if tool.Diffable {
+				key, full = withoutFull(tool.Name, input)
+			}
+			if previous, ok := c.lookup(key); !full && ok && len(previous.fingerprint) > 0 && unchanged(fsys, previous.fingerprint) {
+				return c.hit(tool.Name, previous), nil
+			}
+
+			result, err := next(ctx, toolCtx, input)
+			if err != nil || result == nil || result.IsError {
+				return result, err
+			}
+
+			current := entry{hash: hashResult(result), fingerprint: fingerprint(fsys, result.Metadata.Sources), at: time.Now()}
+			previous, ok := c.lookup(key)
+			if !full && ok && previous.hash == current.hash {
+				// Keep the original timestamp so the marker points at the output the model actually has
+				current.at = previous.at
+				c.store(key, current)
+				return c.hit(tool.Name, current), nil
+			}
+			c.store(key, current)
+			if tool.Diffable {
+				return c.diff(key, tool.Name, input, result, full), nil
+			}
+			return result, nil
+		}
+	}
+}
+
+func (c *Cache) lookup(key string) (entry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *Cache) store(key string, e entry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = e
+}
+
+func (c *Cache) hit(toolName string, e entry) *tools.ToolResult {
+	c.mutex.Lock()
+	c.hits++
+	c.mutex.Unlock()
+	return tools.NewTextResult(fmt.Sprintf(
+		"Unchanged since last read: %s returned identical output for this call at %s. Refer to that earlier result.",
+		toolName, e.at.Format("15:04:05")))
+}
+
+// diff remembers a Diffable call's output and returns it, or a diff
+// against the output sent for the call before when that is shorter
+func (c *Cache) diff(key, toolName string, input json.RawMessage, result *tools.ToolResult, full bool) *tools.ToolResult {
+	if len(result.Content) != 1 || result.Content[0].Type != tools.ContentText {
+		return result
+	}
+	text := result.Text()
+	c.mutex.Lock()
+	previous, ok := c.sent[key]
+	c.sent[key] = sentText{text: text, at: time.Now()}
+	c.mutex.Unlock()
+	if full || !ok || !diff.Fits(previous.text, text) {
+		return result
+	}
+
+	var fields struct {
+		Path string `json:"path"`
+	}
+	json.Unmarshal(input, &fields)
+	changes := diff.Unified(cmp.Or(fields.Path, toolName), previous.text, text)
+	if changes == "" || len(changes) >= len(text)/2 {
+		return result
+	}
+	sent := tools.NewTextResult(fmt.Sprintf(
+		"Changed since %s returned this content at %s. This unified diff turns that output into the current one; call again with \"full\": true for the whole content.\n\n%s",
+		toolName, previous.at.Format("15:04:05"), changes))
+	sent.Metadata = result.Metadata
+	return sent
+}
+
+// withoutFull is cacheKey for Diffable tools: the "full" field is left out,
+// so a full read and a later diff are for the same call. It also reports
+// whether the field was set.
+func withoutFull(toolName string, input json.RawMessage) (key string, full bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return cacheKey(toolName, input), false
+	}
+	json.Unmarshal(fields["full"], &full)
+	delete(fields, "full")
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return cacheKey(toolName, input), full
+	}
+	return cacheKey(toolName, stripped), full
+}
+
+// cacheKey identifies a call by tool name and compacted input, so formatting differences do not matter
+func cacheKey(toolName string, input json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, input); err != nil {
+		return toolName + "\x00" + string(input)
+	}
+	return toolName + "\x00" + compact.String()
+}
+
+func hashResult(result *tools.ToolResult) [sha256.Size]byte {
+	h := sha256.New()
+	for _, block := range result.Content {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", block.Type, block.Text, block.MediaType)
+		h.Write(block.Data)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func fingerprint(fsys workspace.FS, paths []string) map[string]fileState {
+	if len(paths) == 0 {
+		return nil
+	}
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		states[path] = stat(fsys, path)
+	}
+	return states
+}
+
+func unchanged(fsys workspace.FS, states map[string]fileState) bool {
+	for path, state := range states {
+		current := stat(fsys, path)
+		if current.exists != state.exists || current.size != state.size || !current.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func stat(fsys workspace.FS, path string) fileState {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
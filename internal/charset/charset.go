@@ -0,0 +1,184 @@
+// Package charset detects the encoding of text files and converts them to
+// and from UTF-8, so the file tools can show UTF-16 and Latin-1 files to
+// the model as text and write them back in the encoding they came in.
+package charset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is how a text file stores its characters
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF8BOM
+	UTF16LE    // Without a byte order mark
+	UTF16BE    // Without a byte order mark
+	UTF16LEBOM // With the byte order mark FF FE
+	UTF16BEBOM // With the byte order mark FE FF
+	Latin1     // ISO 8859-1: one byte per character, the first 256 code points
+)
+
+var names = map[Encoding]string{
+	UTF8:       "UTF-8",
+	UTF8BOM:    "UTF-8 with BOM",
+	UTF16LE:    "UTF-16LE",
+	UTF16BE:    "UTF-16BE",
+	UTF16LEBOM: "UTF-16LE with BOM",
+	UTF16BEBOM: "UTF-16BE with BOM",
+	Latin1:     "Latin-1",
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// sampleSize is how much of a file Detect looks at for UTF-16 without a
+// byte order mark
+const sampleSize = 1024
+
+func (e Encoding) String() string {
+	return names[e]
+}
+
+// Detect guesses the encoding of data from its byte order mark, or else
+// from its bytes: UTF-16 text without one has a zero byte in most of its
+// code units, and text that is not valid UTF-8 but has no zero bytes is
+// taken to be Latin-1. Anything else, binary data included, is UTF-8.
+func Detect(data []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8BOM
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LEBOM
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BEBOM
+	}
+	if utf8.Valid(data) && bytes.IndexByte(data, 0) == -1 {
+		return UTF8
+	}
+	if encoding, ok := detectUTF16(data); ok {
+		return encoding
+	}
+	if !utf8.Valid(data) && bytes.IndexByte(data, 0) == -1 {
+		return Latin1
+	}
+	return UTF8
+}
+
+// DetectPrefix is Detect for the first bytes of a longer file, as read
+// when it is streamed: a character cut off at the end of prefix does not
+// make it invalid UTF-8
+func DetectPrefix(prefix []byte) Encoding {
+	for i := len(prefix) - 1; i >= 0 && i >= len(prefix)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(prefix[i]) {
+			if !utf8.FullRune(prefix[i:]) {
+				prefix = prefix[:i]
+			}
+			break
+		}
+	}
+	return Detect(prefix)
+}
+
+// detectUTF16 recognizes mostly-ASCII UTF-16 text, whose code units have
+// a zero high byte: the odd bytes are zero in little-endian text and the
+// even ones in big-endian text
+func detectUTF16(data []byte) (Encoding, bool) {
+	sample := data[:min(len(data), sampleSize)]
+	if len(data)%2 != 0 || len(sample) < 2 {
+		return 0, false
+	}
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	units := len(sample) / 2
+	switch {
+	case oddZeros*10 >= units*7 && evenZeros*10 <= units:
+		return UTF16LE, true
+	case evenZeros*10 >= units*7 && oddZeros*10 <= units:
+		return UTF16BE, true
+	}
+	return 0, false
+}
+
+// Decode converts data in encoding e to UTF-8 text, without the byte
+// order mark
+func (e Encoding) Decode(data []byte) (string, error) {
+	switch e {
+	case UTF8BOM:
+		return string(bytes.TrimPrefix(data, bomUTF8)), nil
+	case UTF16LE, UTF16LEBOM:
+		return decodeUTF16(bytes.TrimPrefix(data, bomUTF16LE), binary.LittleEndian)
+	case UTF16BE, UTF16BEBOM:
+		return decodeUTF16(bytes.TrimPrefix(data, bomUTF16BE), binary.BigEndian)
+	case Latin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+	return string(data), nil
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) (string, error) {
+	if len(data)%2 != 0 {
+		return "", fmt.Errorf("invalid UTF-16: odd number of bytes")
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// Encode converts UTF-8 text to encoding e, with the byte order mark if e
+// has one. Latin-1 cannot hold characters past U+00FF; text with one is
+// an error rather than being written with it replaced.
+func (e Encoding) Encode(text string) ([]byte, error) {
+	switch e {
+	case UTF8BOM:
+		return append(bytes.Clone(bomUTF8), text...), nil
+	case UTF16LE:
+		return encodeUTF16(nil, text, binary.LittleEndian), nil
+	case UTF16BE:
+		return encodeUTF16(nil, text, binary.BigEndian), nil
+	case UTF16LEBOM:
+		return encodeUTF16(bytes.Clone(bomUTF16LE), text, binary.LittleEndian), nil
+	case UTF16BEBOM:
+		return encodeUTF16(bytes.Clone(bomUTF16BE), text, binary.BigEndian), nil
+	case Latin1:
+		data := make([]byte, 0, len(text))
+		for i, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%q at byte %d cannot be written in Latin-1", r, i)
+			}
+			data = append(data, byte(r))
+		}
+		return data, nil
+	}
+	return []byte(text), nil
+}
+
+func encodeUTF16(data []byte, text string, order binary.AppendByteOrder) []byte {
+	for _, unit := range utf16.Encode([]rune(text)) {
+		data = order.AppendUint16(data, unit)
+	}
+	return data
+}
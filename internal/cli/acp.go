@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+
+	"agent/internal/acp"
+	"agent/internal/agent"
+	"agent/internal/remote"
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/spf13/cobra"
+)
+
+func newACPCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "acp",
+		Short: "Act as the agent of an editor, such as Zed, over the Agent Client Protocol",
+		Long: `Speak the Agent Client Protocol on stdin and stdout, so an editor can run billdozer
+as the agent behind its assistant panel. Replies stream into the panel, tool calls are
+shown with the diffs of the files they change, and confirmations become the editor's
+permission prompts, where "always allow" answers last for the session.
+
+The editor starts this command itself. The project directory of the first conversation
+becomes the workspace and the directory config is read from, unless --workspace is set;
+later conversations share its tools, approvals and workspace, and must be in the same
+directory. Everything billdozer would print goes to stderr, which editors keep as the
+agent's log. Conversations are saved like any other session.`,
+		Example: `  # Zed settings.json
+  "agent_servers": {
+    "billdozer": {
+      "command": "billdozer",
+      "args": ["acp"]
+    }
+  }`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			// The protocol owns stdout
+			protocol := os.Stdout
+			os.Stdout = os.Stderr
+			defer func() { os.Stdout = protocol }()
+
+			opts.acp = acp.New(os.Stdin, protocol)
+			h := &editorSessions{opts: opts, fixedWorkspace: cmd.Flag("workspace").Changed, conversations: map[string]*editorConversation{}}
+			defer h.close()
+			return opts.acp.Serve(ctx, h)
+		},
+	}
+}
+
+// editorSessions runs the conversations an editor starts. They share one
+// session, opened for the first of them, and each has an agent of its own
+// per prompt, continuing the conversation so far.
+type editorSessions struct {
+	opts           *options
+	fixedWorkspace bool // --workspace was given, so the editor's directory is not used
+
+	mutex         sync.Mutex
+	s             *session
+	client        *anthropic.Client
+	reload        *reloader
+	conversations map[string]*editorConversation
+}
+
+// editorConversation is the state of one conversation between prompts
+type editorConversation struct {
+	saved    *savedSession
+	messages []anthropic.MessageParam
+}
+
+func (h *editorSessions) NewSession(ctx context.Context, cwd string) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.s == nil {
+		if err := h.open(ctx, cwd); err != nil {
+			return "", err
+		}
+	} else if err := h.checkDirectory(cwd); err != nil {
+		return "", err
+	}
+
+	saved, err := h.s.conversation()
+	if err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("conversation-%d", len(h.conversations)+1)
+	if saved != nil {
+		id = saved.session.ID
+	}
+	h.conversations[id] = &editorConversation{saved: saved}
+	return id, nil
+}
+
+// open starts the session in the editor's project directory
+func (h *editorSessions) open(ctx context.Context, cwd string) error {
+	if !h.fixedWorkspace && cwd != "" {
+		if err := os.Chdir(cwd); err != nil {
+			return err
+		}
+		h.opts.workspaceRoot = cwd
+	}
+	s, err := newSession(ctx, h.opts)
+	if err != nil {
+		return err
+	}
+	client, err := s.apiClient()
+	if err != nil {
+		s.Close()
+		return err
+	}
+	h.s, h.client, h.reload = s, client, s.watchConfig()
+	return nil
+}
+
+// checkDirectory rejects conversations in another project than the session's
+func (h *editorSessions) checkDirectory(cwd string) error {
+	if h.fixedWorkspace || cwd == "" || remote.IsTarget(h.opts.workspaceRoot) {
+		return nil
+	}
+	resolved, err := filepath.EvalSymlinks(cwd)
+	if err != nil {
+		return err
+	}
+	if resolved != h.s.workspace.Root() {
+		return fmt.Errorf("this billdozer serves %s; start another for %s", h.s.workspace.Root(), cwd)
+	}
+	return nil
+}
+
+// Prompt runs the agent on text until it replies. A turn that ends without
+// a reply stopped at limits.max_turns.
+func (h *editorSessions) Prompt(ctx context.Context, id, text string) (string, error) {
+	h.mutex.Lock()
+	conversation, ok := h.conversations[id]
+	h.mutex.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown session %q", id)
+	}
+
+	sent, replied, responses := false, false, 0
+	once := func() (string, bool) {
+		if sent {
+			return "", false
+		}
+		sent = true
+		return text, true
+	}
+	checkpoint := func(messages []anthropic.MessageParam) {
+		conversation.messages = messages
+		conversation.saved.checkpoint(messages)
+	}
+	runner := agent.NewAgent(h.client, once, h.s.registry, h.s.agentOptions(conversation.messages, checkpoint, h.reload,
+		agent.WithResponseText(h.opts.acp.Text),
+		agent.WithReply(func(string) { replied = true }),
+		agent.WithUsage(func(model string, usage anthropic.Usage) {
+			responses++
+			h.s.recordUsage(model, usage)
+		}))...)
+	if err := runner.Run(ctx); err != nil {
+		return "", err
+	}
+	if responses > 0 && !replied {
+		return acp.StopMaxTurns, nil
+	}
+	return acp.StopEndTurn, nil
+}
+
+func (h *editorSessions) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.s != nil {
+		h.s.Close()
+	}
+}
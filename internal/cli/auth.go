@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"agent/internal/auth"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func newAuthCommand(opts *options) *cobra.Command {
+	authCmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage the Anthropic API key",
+		Long: `The API key is looked up in order from the ANTHROPIC_API_KEY environment variable,
+api_key in billdozer.yml or the global config.yml, the OS keychain entry saved by
+"auth login", and finally ~/.config/billdozer/credentials.`,
+	}
+
+	authCmd.AddCommand(&cobra.Command{
+		Use:   "login",
+		Short: "Save an API key in the OS keychain (or the credentials file when none is available)",
+		Long: `Prompt for an API key and save it in the OS keychain (macOS Keychain, or the Secret
+Service through secret-tool on Linux). Without a keychain the key is written to
+~/.config/billdozer/credentials, readable only by you. When standard input is not
+a terminal the key is read from it, e.g. "pass show anthropic | billdozer auth login".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := readKey(cmd)
+			if err != nil {
+				return err
+			}
+			where, err := auth.Store(key)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Saved the API key to the %s\n", where)
+			if os.Getenv(auth.EnvVar) != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Note: %s is set and takes precedence over the saved key\n", auth.EnvVar)
+			}
+			return nil
+		},
+	})
+
+	authCmd.AddCommand(&cobra.Command{
+		Use:   "logout",
+		Short: "Remove the key saved by auth login",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := auth.Delete()
+			for _, where := range removed {
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed the %s\n", where)
+			}
+			if err != nil {
+				return err
+			}
+			if len(removed) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No saved API key was found")
+			}
+			return nil
+		},
+	})
+
+	authCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show which source the API key would be read from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			credential, err := auth.Resolve(cfg)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Using the API key %s from the %s\n", maskKey(credential.Key), credential.Source)
+			return nil
+		},
+	})
+	return authCmd
+}
+
+// readKey prompts for the key without echo on a terminal, or reads the first line of piped input
+func readKey(cmd *cobra.Command) (string, error) {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(cmd.OutOrStdout(), "Anthropic API key: ")
+		key, err := term.ReadPassword(fd)
+		fmt.Fprintln(cmd.OutOrStdout())
+		if err != nil {
+			return "", fmt.Errorf("failed to read the API key: %w", err)
+		}
+		return string(key), nil
+	}
+
+	scanner := bufio.NewScanner(cmd.InOrStdin())
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("failed to read the API key: %w", err)
+		}
+		return "", fmt.Errorf("no API key was given on standard input")
+	}
+	return scanner.Text(), nil
+}
+
+// maskKey shows only enough of a key to tell keys apart
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:7] + "..." + key[len(key)-4:]
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"agent/internal/backup"
+	"agent/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+func newBackupsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backups",
+		Short: "List and restore the files backed up before the agent changed them",
+		Long: `Before write overwrites a file or delete_file removes one, the file is copied to
+~/.config/billdozer/backups, in a directory per session. The agent can put files back
+itself with the restore_backup tool; these commands do it by hand. Backups are kept for
+backups.retention_days (7 by default) after a session's last one, and are not taken
+when backups.enabled is false.`,
+	}
+	cmd.AddCommand(newBackupsListCommand(), newBackupsRestoreCommand())
+	return cmd
+}
+
+// openBackupStore returns the backup store or an error when there is none
+func openBackupStore() (*backup.Store, error) {
+	store := backupStore()
+	if store == nil {
+		return nil, errors.New("no config directory to keep backups in")
+	}
+	return store, nil
+}
+
+func newBackupsListCommand() *cobra.Command {
+	var session string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List backups, most recent first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openBackupStore()
+			if err != nil {
+				return err
+			}
+			entries, err := store.List(session)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No backups")
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tTAKEN\tBEFORE\tSIZE\tPATH")
+			for _, entry := range entries {
+				path := entry.Path
+				if entry.Host != "" {
+					path = entry.Host + ":" + path
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", entry.ID, entry.Created.Local().Format(dateLayout+" 15:04"), entry.Reason, entry.Size, path)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&session, "session", "", "only the backups of one session, the part of their IDs before the slash")
+	return cmd
+}
+
+func newBackupsRestoreCommand() *cobra.Command {
+	var to string
+	cmd := &cobra.Command{
+		Use:   "restore <id>",
+		Short: "Put a backed-up file back where it was, or at --to",
+		Long: `Write a backup back to the path it was taken from, or to --to. A file already
+there is backed up first, so the restore can be undone the same way. Files of ssh://
+workspaces are put back by the agent with restore_backup, or copied here with --to.`,
+		Example: `  billdozer backups restore 20240501-093000-ab12/3
+  billdozer backups restore 20240501-093000-ab12/3 --to /tmp/old-main.go`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openBackupStore()
+			if err != nil {
+				return err
+			}
+			entry, data, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			path := entry.Path
+			if to != "" {
+				if path, err = filepath.Abs(to); err != nil {
+					return err
+				}
+			} else if entry.Host != "" {
+				return fmt.Errorf("backup %s is of a file on %s; restore it with --to, or from a session in that workspace", entry.ID, entry.Host)
+			}
+
+			note := ""
+			if current, err := os.ReadFile(path); err == nil {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				previous, err := store.NewSession().Save("", path, current, info.Mode(), backup.ReasonRestore)
+				if err != nil {
+					return fmt.Errorf("failed to back up %s, so it was not replaced: %w", path, err)
+				}
+				note = fmt.Sprintf("; its previous content is backup %s", previous.ID)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := workspace.Local.WriteFileAtomic(path, data, entry.Mode); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Restored %s from backup %s, taken %s%s\n", path, entry.ID, entry.Created.Local().Format(time.DateTime), note)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "write the file here instead of its original path")
+	return cmd
+}
@@ -0,0 +1,484 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/internal/agent"
+	"agent/internal/confirm"
+	"agent/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of "ci"
+const (
+	ciExitFailed  = 1 // The run failed: an API error, the spending limit, or git
+	ciExitRefused = 2 // The agent finished, but operations were refused
+)
+
+// Statuses of a CI report
+const (
+	ciSuccess = "success"
+	ciRefused = "refused"
+	ciFailed  = "failed"
+	ciSkipped = "skipped" // The event did not carry the trigger
+)
+
+// ciReport is the machine-readable outcome of "ci"
+type ciReport struct {
+	Status      string          `json:"status"`
+	ExitCode    int             `json:"exit_code"`
+	Prompt      string          `json:"prompt,omitempty"`
+	Reply       string          `json:"reply,omitempty"` // Claude's last reply
+	Error       string          `json:"error,omitempty"`
+	Refused     []string        `json:"refused,omitempty"` // Operations refused without asking
+	Changed     bool            `json:"changed"`           // The working tree differs from HEAD
+	Patch       string          `json:"patch,omitempty"`   // File the changes were written to
+	Branch      string          `json:"branch,omitempty"`
+	Commit      string          `json:"commit,omitempty"`
+	PullRequest string          `json:"pull_request,omitempty"` // URL
+	Session     *metrics.Report `json:"session,omitempty"`
+}
+
+// ciOptions are the flags of "ci"
+type ciOptions struct {
+	event   string
+	trigger string
+	report  string
+	patch   string
+	branch  string
+	pr      bool
+	base    string
+}
+
+func newCICommand(opts *options) *cobra.Command {
+	ci := &ciOptions{}
+	cmd := &cobra.Command{
+		Use:   "ci [prompt]...",
+		Short: "Run one prompt unattended in a CI job and report the outcome",
+		Long: `Run one prompt without a terminal, for workflow steps. Confirmations are
+auto-approved, except for operations that always require a person (commands with
+confirm: true or danger: high), which are refused. The profile named "ci" is applied
+when it exists and --profile is not given, so its tools and permissions can deny
+more.
+
+Without arguments the prompt comes from the GitHub Actions event ($GITHUB_EVENT_PATH):
+the comment of issue_comment events, with the issue as context, or the issue or pull
+request itself. With --trigger the comment must start with it, and the run is skipped
+otherwise.
+
+The changes can be saved as a patch (--patch), committed to a branch (--branch) and
+opened as a pull request with the gh CLI (--pr). --report writes the outcome as JSON,
+and under GitHub Actions the status, changed, pull_request and report step outputs
+and a job summary are written too.
+
+Exit codes: 0 when the agent finished, 1 when the run failed and 2 when it finished
+but operations were refused.`,
+		Example: `  billdozer ci "fix the failing test" --patch fix.patch
+  billdozer ci --trigger /billdozer --pr --report billdozer.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCI(cmd.Context(), cmd.OutOrStdout(), opts, ci, args)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&ci.event, "event", "", "GitHub event file to take the prompt from (default $GITHUB_EVENT_PATH)")
+	flags.StringVar(&ci.trigger, "trigger", "", "prefix a comment must start with, e.g. /billdozer; stripped from the prompt")
+	flags.StringVar(&ci.report, "report", "", "write the outcome as JSON to this file (- for standard output)")
+	flags.StringVar(&ci.patch, "patch", "", "write the changes as a git patch to this file")
+	flags.StringVar(&ci.branch, "branch", "", "commit the changes to this new branch")
+	flags.BoolVar(&ci.pr, "pr", false, "push the branch and open a pull request with gh (implies --branch billdozer/<time>)")
+	flags.StringVar(&ci.base, "base", "", "base branch of the pull request (default the current branch)")
+	return cmd
+}
+
+// runCI runs the prompt and reports the outcome; the returned error carries
+// the exit code
+func runCI(ctx context.Context, stdout io.Writer, opts *options, ci *ciOptions, args []string) error {
+	report := &ciReport{}
+	err := ci.run(ctx, opts, args, report)
+	switch {
+	case err != nil:
+		report.Status, report.ExitCode, report.Error = ciFailed, ciExitFailed, err.Error()
+	case report.Status == ciSkipped:
+	case len(report.Refused) > 0:
+		report.Status, report.ExitCode = ciRefused, ciExitRefused
+		err = fmt.Errorf("the agent finished, but %d operations were refused", len(report.Refused))
+	default:
+		report.Status = ciSuccess
+	}
+	if writeErr := ci.writeReport(stdout, report); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", writeErr)
+	}
+	if err != nil {
+		return &ExitError{Code: report.ExitCode, Err: err}
+	}
+	return nil
+}
+
+func (ci *ciOptions) run(ctx context.Context, opts *options, args []string, report *ciReport) error {
+	prompt, err := ci.prompt(args)
+	if err != nil {
+		return err
+	}
+	if prompt == "" {
+		report.Status = ciSkipped
+		fmt.Fprintf(os.Stderr, "The event does not start with %s; skipping\n", ci.trigger)
+		return nil
+	}
+	report.Prompt = prompt
+
+	if err := requireLocalWorkspace(opts, "ci"); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Profiles["ci"]; ok && opts.profile == "" {
+		opts.profile = "ci"
+		if cfg, err = loadConfig(opts); err != nil {
+			return err
+		}
+	}
+	opts.autoApprove = true
+	s, err := openSession(ctx, opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.confirmer.SetRefused(func(req confirm.Request) {
+		report.Refused = append(report.Refused, req.Description())
+	})
+
+	sent := false
+	once := func() (string, bool) {
+		if sent {
+			return "", false
+		}
+		sent = true
+		return prompt, true
+	}
+	runner, err := s.newAgent(once, agent.WithReply(func(text string) { report.Reply = text }))
+	if err != nil {
+		return err
+	}
+	runErr := runner.Run(ctx)
+	summary := s.report()
+	report.Session = &summary
+	if runErr != nil {
+		return runErr
+	}
+	return ci.publish(ctx, s.workspace.Root(), report)
+}
+
+// prompt joins the arguments ("-" reads standard input), or reads the
+// GitHub event. An empty prompt without an error means the event lacked the
+// trigger.
+func (ci *ciOptions) prompt(args []string) (string, error) {
+	if len(args) > 0 {
+		prompt := strings.Join(args, " ")
+		if prompt == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return "", err
+			}
+			prompt = string(data)
+		}
+		if strings.TrimSpace(prompt) == "" {
+			return "", errors.New("the prompt is empty")
+		}
+		return prompt, nil
+	}
+
+	path := ci.event
+	if path == "" {
+		path = os.Getenv("GITHUB_EVENT_PATH")
+	}
+	if path == "" {
+		return "", errors.New("give a prompt, or run in GitHub Actions (or pass --event) to take it from the event")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := eventPrompt(data, ci.trigger)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the event %s: %w", path, err)
+	}
+	return prompt, nil
+}
+
+// eventPrompt builds a prompt from a GitHub event: a comment, with its issue
+// as context, or an issue or pull request. With a trigger, text that does
+// not start with it gives an empty prompt.
+func eventPrompt(data []byte, trigger string) (string, error) {
+	type thread struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+	}
+	var event struct {
+		Comment *struct {
+			Body string `json:"body"`
+		} `json:"comment"`
+		Issue       *thread `json:"issue"`
+		PullRequest *thread `json:"pull_request"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return "", err
+	}
+	subject := event.Issue
+	if subject == nil {
+		subject = event.PullRequest
+	}
+
+	var request, context string
+	switch {
+	case event.Comment != nil:
+		request = event.Comment.Body
+		if subject != nil {
+			context = fmt.Sprintf("#%d: %s\n\n%s", subject.Number, subject.Title, subject.Body)
+		}
+	case subject != nil:
+		request = subject.Title + "\n\n" + subject.Body
+	default:
+		return "", errors.New("no comment, issue or pull request")
+	}
+
+	request = strings.TrimSpace(request)
+	if trigger != "" {
+		rest, ok := strings.CutPrefix(request, trigger)
+		if !ok {
+			return "", nil
+		}
+		request = strings.TrimSpace(rest)
+	}
+	if request == "" {
+		return "", errors.New("the request is empty")
+	}
+	if context != "" {
+		request += "\n\nThis was asked on " + strings.TrimSpace(context)
+	}
+	return request, nil
+}
+
+// publish saves the changes in the working tree as asked: as a patch, a
+// commit on a new branch and a pull request
+func (ci *ciOptions) publish(ctx context.Context, dir string, report *ciReport) error {
+	patch, err := workingTreePatch(ctx, dir)
+	if err != nil {
+		return err
+	}
+	report.Changed = patch != ""
+	if ci.patch != "" {
+		if err := os.WriteFile(ci.patch, []byte(patch), 0o644); err != nil {
+			return err
+		}
+		report.Patch = ci.patch
+	}
+	if !report.Changed || (ci.branch == "" && !ci.pr) {
+		return nil
+	}
+
+	base := ci.base
+	if base == "" {
+		current, err := git(ctx, dir, nil, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return err
+		}
+		base = current
+	}
+	branch := ci.branch
+	if branch == "" {
+		branch = "billdozer/" + time.Now().UTC().Format("20060102-150405")
+	}
+	title := pullRequestTitle(report.Prompt)
+	if _, err := git(ctx, dir, nil, "checkout", "-b", branch); err != nil {
+		return err
+	}
+	if _, err := git(ctx, dir, nil, "add", "-A"); err != nil {
+		return err
+	}
+	if _, err := git(ctx, dir, nil, append(committer(ctx, dir), "commit", "-m", title)...); err != nil {
+		return err
+	}
+	report.Branch = branch
+	report.Commit, err = git(ctx, dir, nil, "rev-parse", "HEAD")
+	if err != nil || !ci.pr {
+		return err
+	}
+
+	if _, err := git(ctx, dir, nil, "push", "-u", "origin", branch); err != nil {
+		return err
+	}
+	gh := exec.CommandContext(ctx, "gh", "pr", "create", "--base", base, "--head", branch, "--title", title, "--body-file", "-")
+	gh.Dir = dir
+	gh.Stdin = strings.NewReader(pullRequestBody(report))
+	gh.Stderr = os.Stderr
+	out, err := gh.Output()
+	if err != nil {
+		return fmt.Errorf("failed to open the pull request: %w", err)
+	}
+	report.PullRequest = strings.TrimSpace(string(out))
+	return nil
+}
+
+// workingTreePatch returns every change in dir's working tree against HEAD,
+// new files included, as a binary-safe patch. A scratch index keeps the
+// repository's own index untouched.
+func workingTreePatch(ctx context.Context, dir string) (string, error) {
+	scratch, err := os.MkdirTemp("", "billdozer-ci-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(scratch)
+	env := append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(scratch, "index"))
+	// A repository without commits has no HEAD to start from
+	if _, err := git(ctx, dir, env, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		if _, err := git(ctx, dir, env, "read-tree", "HEAD"); err != nil {
+			return "", err
+		}
+	}
+	if _, err := git(ctx, dir, env, "add", "-A"); err != nil {
+		return "", err
+	}
+	patch, err := git(ctx, dir, env, "diff", "--cached", "--binary")
+	if err != nil {
+		return "", err
+	}
+	if patch != "" {
+		patch += "\n"
+	}
+	return patch, nil
+}
+
+// committer names billdozer as the committer when git has no identity
+// configured, as on fresh CI runners
+func committer(ctx context.Context, dir string) []string {
+	if email, _ := git(ctx, dir, nil, "config", "user.email"); email != "" {
+		return nil
+	}
+	return []string{"-c", "user.name=billdozer", "-c", "user.email=billdozer@users.noreply.github.com"}
+}
+
+// git runs a git command in dir and returns its trimmed output; env
+// replaces the environment when set
+func git(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// pullRequestTitle is the first line of the prompt, shortened
+func pullRequestTitle(prompt string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if runes := []rune(title); len(runes) > 72 {
+		title = string(runes[:71]) + "…"
+	}
+	return "billdozer: " + title
+}
+
+// pullRequestBody describes the run: the prompt, Claude's reply and the
+// session summary
+func pullRequestBody(report *ciReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", quote(report.Prompt))
+	if report.Reply != "" {
+		fmt.Fprintf(&b, "%s\n\n", report.Reply)
+	}
+	if report.Session != nil {
+		b.WriteString("<details><summary>Session summary</summary>\n\n```\n")
+		report.Session.Write(&b)
+		b.WriteString("```\n</details>\n")
+	}
+	return b.String()
+}
+
+// quote formats text as a markdown block quote
+func quote(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+}
+
+// writeReport writes the report where asked and, under GitHub Actions,
+// the step outputs and job summary
+func (ci *ciOptions) writeReport(stdout io.Writer, report *ciReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	var problems []error
+	switch ci.report {
+	case "":
+	case "-":
+		fmt.Fprintln(stdout, string(data))
+	default:
+		if err := os.WriteFile(ci.report, append(data, '\n'), 0o644); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		outputs := fmt.Sprintf("status=%s\nchanged=%t\npull_request=%s\n", report.Status, report.Changed, report.PullRequest)
+		if ci.report != "" && ci.report != "-" {
+			outputs += "report=" + ci.report + "\n"
+		}
+		problems = append(problems, appendFile(path, outputs))
+	}
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		problems = append(problems, appendFile(path, stepSummary(report)))
+	}
+	return errors.Join(problems...)
+}
+
+// stepSummary is the job summary shown on the workflow run
+func stepSummary(report *ciReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### billdozer: %s\n\n", report.Status)
+	if report.Prompt != "" {
+		fmt.Fprintf(&b, "%s\n\n", quote(report.Prompt))
+	}
+	if report.Error != "" {
+		fmt.Fprintf(&b, "**Error:** %s\n\n", report.Error)
+	}
+	for _, refused := range report.Refused {
+		fmt.Fprintf(&b, "- Refused: %s\n", refused)
+	}
+	if report.PullRequest != "" {
+		fmt.Fprintf(&b, "\nPull request: %s\n", report.PullRequest)
+	}
+	if report.Session != nil {
+		b.WriteString("\n```\n")
+		report.Session.Write(&b)
+		b.WriteString("```\n")
+	}
+	return b.String()
+}
+
+func appendFile(path, text string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/logging"
+	"agent/internal/sessions"
+	"github.com/spf13/cobra"
+)
+
+// registerCompletions completes the values of root's persistent flags.
+// Cobra's completion command turns these, and the ValidArgsFunction of
+// each subcommand, into bash, zsh, fish and PowerShell scripts.
+func registerCompletions(root *cobra.Command) {
+	root.RegisterFlagCompletionFunc("profile", completeProfiles)
+	root.RegisterFlagCompletionFunc("model", completeModels)
+	root.MarkPersistentFlagDirname("workspace")
+	root.MarkPersistentFlagFilename("record", "jsonl")
+	root.MarkPersistentFlagFilename("log-file")
+	root.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions([]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+	root.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions([]string{logging.FormatText, logging.FormatJSON}, cobra.ShellCompDirectiveNoFileComp))
+	root.MarkPersistentFlagFilename("record-api", "jsonl")
+	root.MarkPersistentFlagFilename("replay-api", "jsonl")
+}
+
+// completeProfiles offers the profile names defined in the config
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadConfig(&options{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeModels offers the model aliases
+func completeModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadConfig(&options{})
+	if err != nil {
+		return config.AliasNames(config.DefaultModelAliases), cobra.ShellCompDirectiveNoFileComp
+	}
+	aliases := cfg.ModelAliases()
+	var completions []string
+	for _, name := range config.AliasNames(aliases) {
+		completions = append(completions, name+"\t"+aliases[name])
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSessionIDs offers saved session IDs, most recent first, described
+// by their titles. Sessions already named on the command line are skipped.
+func completeSessionIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	store := sessionStore()
+	if store == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	found, err := store.List(sessions.Filter{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, s := range found {
+		if strings.HasPrefix(s.ID, toComplete) && !slices.Contains(args, s.ID) {
+			completions = append(completions, s.ID+"\t"+s.Title)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// completeSessionID completes the single session ID of show and resume
+func completeSessionID(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeSessionIDs(cmd, args, toComplete)
+}
+
+// completeSince offers common --since and --until durations
+func completeSince(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"1d\tlast day", "7d\tlast week", "30d\tlast month"}, cobra.ShellCompDirectiveNoFileComp
+}
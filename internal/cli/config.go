@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"agent/internal/config"
+	"agent/internal/secrets"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newConfigCommand(opts *options) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the effective configuration",
+	}
+
+	var origin bool
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the merged config after layering, profiles and environment overrides",
+		Long: `Print the merged config after layering, profiles and environment overrides.
+With --origin, print one line per effective value with the layer that set it:
+default, a file and line, an environment variable, a profile or a flag.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			shown := *cfg
+			if shown.APIKey != "" {
+				shown.APIKey = "[secret]"
+			}
+			if proxyURL, err := url.Parse(shown.Network.Proxy); err == nil && shown.Network.Proxy != "" {
+				shown.Network.Proxy = proxyURL.Redacted()
+			}
+			shown.Anthropic.Headers = hideCredentialHeaders(shown.Anthropic.Headers)
+			shown.Server.Principals = slices.Clone(shown.Server.Principals)
+			for i := range shown.Server.Principals {
+				if shown.Server.Principals[i].APIKey != "" {
+					shown.Server.Principals[i].APIKey = "[secret]"
+				}
+			}
+			if origin {
+				return printOrigins(cmd.OutOrStdout(), &shown)
+			}
+			out, err := yaml.Marshal(&shown)
+			if err != nil {
+				return err
+			}
+			for _, source := range cfg.Sources {
+				fmt.Fprintf(cmd.OutOrStdout(), "# from %s\n", source)
+			}
+			fmt.Fprint(cmd.OutOrStdout(), hideSecrets(string(out)))
+			return nil
+		},
+	}
+	showCmd.Flags().BoolVar(&origin, "origin", false, "print each effective value and where it came from")
+	configCmd.AddCommand(showCmd)
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "path",
+		Short: "List the config files that were found, lowest precedence first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			if len(cfg.Sources) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No config files found; defaults are in use")
+			}
+			for _, source := range cfg.Sources {
+				fmt.Fprintln(cmd.OutOrStdout(), source)
+			}
+			return nil
+		},
+	})
+	configCmd.AddCommand(newValidateCommand(opts))
+	return configCmd
+}
+
+// printOrigins prints the effective settings of cfg as a KEY/VALUE/ORIGIN table
+func printOrigins(out io.Writer, cfg *config.Config) error {
+	settings, err := cfg.Settings()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tORIGIN")
+	for _, setting := range settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, hideSecrets(setting.Value), setting.Origin)
+	}
+	return w.Flush()
+}
+
+// hideCredentialHeaders masks the values of headers named like credentials,
+// such as Authorization or X-Gateway-Key
+func hideCredentialHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return headers
+	}
+	shown := make(map[string]string, len(headers))
+	for name, value := range headers {
+		lower := strings.ToLower(name)
+		if strings.Contains(lower, "auth") || strings.Contains(lower, "key") || strings.Contains(lower, "token") || strings.Contains(lower, "secret") {
+			value = "[secret]"
+		}
+		shown[name] = value
+	}
+	return shown
+}
+
+// hideSecrets masks values that were resolved from secret:// references
+func hideSecrets(text string) string {
+	for _, value := range secrets.Values() {
+		text = strings.ReplaceAll(text, value, "[secret]")
+	}
+	return text
+}
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/ignore"
+	"github.com/spf13/cobra"
+)
+
+// instructionsFile is the starter system prompt written by init
+const instructionsFile = "BILLDOZER.md"
+
+// initCommandOrder is the order detected commands are written in
+var initCommandOrder = []string{"build", "test", "lint"}
+
+// scaffoldFile is one file init offers to create
+type scaffoldFile struct {
+	name    string
+	content string
+}
+
+func newInitCommand() *cobra.Command {
+	var yes, force bool
+	cmd := &cobra.Command{
+		Use:   "init [dir]",
+		Short: "Create billdozer.yml, .agent-commands.yml, BILLDOZER.md and .billdozerignore",
+		Long: `Scaffold the billdozer files for a project. The build, test and lint commands are
+detected from the Makefile, package.json, go.mod or Cargo.toml and written to
+.agent-commands.yml so they can be reviewed and edited. Existing files are kept
+unless you confirm overwriting them (or pass --force).`,
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			}
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+
+			p := &prompter{in: bufio.NewScanner(cmd.InOrStdin()), out: cmd.OutOrStdout(), yes: yes}
+
+			model := p.ask("Model", config.DefaultModel)
+			commands, detectedFrom := config.DetectCommands(dir)
+			useDetected := false
+			if len(commands) > 0 {
+				fmt.Fprintf(p.out, "Detected %s from %s\n", strings.Join(detectedNames(commands), ", "), strings.Join(baseNames(detectedFrom), ", "))
+				useDetected = p.confirm("Add them to .agent-commands.yml?", true)
+			}
+			if !useDetected {
+				commands = nil
+			}
+
+			files := []scaffoldFile{
+				{config.ProjectConfigFile, projectConfigTemplate(model)},
+				{config.DefaultCommandsPath, commandsTemplate(commands)},
+				{instructionsFile, instructionsTemplate},
+				{ignore.FileName, ignoreTemplate},
+			}
+			for _, file := range files {
+				path := filepath.Join(dir, file.name)
+				if _, err := os.Stat(path); err == nil && !force {
+					if !p.confirm(file.name+" already exists. Overwrite?", false) {
+						fmt.Fprintf(p.out, "Kept %s\n", path)
+						continue
+					}
+				}
+				if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+					return fmt.Errorf("failed to write %s: %w", path, err)
+				}
+				fmt.Fprintf(p.out, "Wrote %s\n", path)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "accept the defaults without prompting (existing files are kept)")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite existing files without asking")
+	return cmd
+}
+
+// prompter asks questions on the command's input. With yes set every
+// question takes its default answer.
+type prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+	yes bool
+}
+
+// ask returns the entered answer, or def when the answer is empty
+func (p *prompter) ask(question, def string) string {
+	if p.yes {
+		return def
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	if !p.in.Scan() {
+		fmt.Fprintln(p.out)
+		return def
+	}
+	if answer := strings.TrimSpace(p.in.Text()); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// confirm asks a yes/no question
+func (p *prompter) confirm(question string, def bool) bool {
+	if p.yes {
+		return def
+	}
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, hint)
+	if !p.in.Scan() {
+		fmt.Fprintln(p.out)
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(p.in.Text())) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// detectedNames lists the detected commands in the order they are written
+func detectedNames(commands map[string]config.CommandSpec) []string {
+	var names []string
+	for _, name := range initCommandOrder {
+		if _, ok := commands[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func baseNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = filepath.Base(path)
+	}
+	return names
+}
+
+func projectConfigTemplate(model string) string {
+	return fmt.Sprintf(`# billdozer project settings. See the Configuration section of the README
+# for every key; ~/.config/billdozer/config.yml holds personal defaults and
+# BILLDOZER_* environment variables override both.
+
+model: %s
+
+# Project instructions sent as the system prompt
+system_prompt: %s
+
+limits:
+  max_tokens: %d
+  # max_turns: 25
+
+# Commands live in .agent-commands.yml; they can also be defined here under
+# "commands:" and "groups:".
+
+# permissions:
+#   default: allow
+#   rules:
+#     - tool: delete_file
+#       action: confirm
+
+# profiles:
+#   review:
+#     tools:
+#       disabled: [command, write, edit_file, delete_file]
+`, model, instructionsFile, config.DefaultMaxTokens)
+}
+
+// commandsTemplate renders the detected commands, or a commented example when there are none
+func commandsTemplate(commands map[string]config.CommandSpec) string {
+	var b strings.Builder
+	b.WriteString("# Commands the agent may run with the execute_command tool\n")
+	if len(commands) == 0 {
+		b.WriteString(`commands: {}
+#  test:
+#    command: go test ./...
+#    description: Run the test suite
+#    timeout_seconds: 600
+`)
+		return b.String()
+	}
+
+	b.WriteString("commands:\n")
+	for _, name := range detectedNames(commands) {
+		spec := commands[name]
+		fmt.Fprintf(&b, "  %s:\n", name)
+		fmt.Fprintf(&b, "    command: %s\n", yamlString(spec.Command))
+		fmt.Fprintf(&b, "    description: %s\n", yamlString("Run "+spec.Command))
+		fmt.Fprintf(&b, "    timeout_seconds: %d\n", spec.TimeoutSeconds)
+	}
+	if _, ok := commands["lint"]; ok {
+		if _, ok := commands["test"]; ok {
+			b.WriteString("\n# Run lint and test in parallel with execute_command name \"check\"\ngroups:\n  check: [lint, test]\n")
+		}
+	}
+	return b.String()
+}
+
+// yamlString quotes a scalar when plain style would change its meaning
+func yamlString(value string) string {
+	if strings.ContainsAny(value, ":#{}[]&*!|>'\"%@`") || strings.HasPrefix(value, "-") {
+		return fmt.Sprintf("%q", value)
+	}
+	return value
+}
+
+const instructionsTemplate = `# Project instructions
+
+You are working in {{.ProjectName}} through billdozer's tools. Describe the
+project here so every session starts with the right context, for example:
+
+- What the project does and how the code is organized
+- The commands to build, test and lint it (see .agent-commands.yml)
+- Coding conventions: naming, error handling, test layout
+- Anything the agent must not touch
+`
+
+const ignoreTemplate = `# Paths hidden from billdozer's file listings and searches (gitignore syntax)
+.git/
+node_modules/
+vendor/
+dist/
+build/
+target/
+*.log
+.env
+.env.*
+`
@@ -0,0 +1,268 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/models"
+	"agent/internal/permissions"
+	"agent/internal/theme"
+)
+
+// reloadInterval is how often the config files are checked for changes
+const reloadInterval = 2 * time.Second
+
+// reloader watches the config files during a session and applies the
+// changes that are safe to make mid-conversation: tool enablement and
+// descriptions, permission rules, path rules and commands. Other changes
+// are announced as needing a restart. Every reload is printed for the user
+// and queued as a notice for the model.
+type reloader struct {
+	s        *session
+	applied  *config.Config // Last config whose safe sections were applied; s.cfg is the one the session started with
+	commands map[string]config.CommandSpec
+	stamps   map[string]string
+
+	mutex   sync.Mutex
+	notices []string
+}
+
+// restartFields are the settings that only take effect in a new session
+var restartFields = []struct {
+	key string
+	get func(*config.Config) any
+}{
+	{"model", func(c *config.Config) any { return c.Model }},
+	{"provider", func(c *config.Config) any { return c.Provider }},
+	{"system_prompt", func(c *config.Config) any { return c.SystemPrompt }},
+	{"api_key", func(c *config.Config) any { return c.APIKey }},
+	{"limits", func(c *config.Config) any { return c.Limits }},
+	{"redaction", func(c *config.Config) any { return c.Redaction }},
+	{"confirmation", func(c *config.Config) any { return c.Confirmation }},
+	{"cache", func(c *config.Config) any { return c.Cache }},
+	{"go_checks", func(c *config.Config) any { return c.GoChecks }},
+	{"kubernetes", func(c *config.Config) any { return c.Kubernetes }},
+	{"databases", func(c *config.Config) any { return c.Databases }},
+	{"migrations", func(c *config.Config) any { return c.Migrations }},
+	{"openapi", func(c *config.Config) any { return c.OpenAPI }},
+	{"protobuf", func(c *config.Config) any { return c.Protobuf }},
+	{"benchmarks", func(c *config.Config) any { return c.Benchmarks }},
+	{"licenses", func(c *config.Config) any { return c.Licenses }},
+	{"backups", func(c *config.Config) any { return c.Backups }},
+	{"plugins", func(c *config.Config) any { return c.Plugins }},
+	{"mcp_servers", func(c *config.Config) any { return c.MCPServers }},
+	{"network", func(c *config.Config) any { return c.Network }},
+	{"logging", func(c *config.Config) any { return c.Logging }},
+	{"server", func(c *config.Config) any { return c.Server }},
+	{"watch", func(c *config.Config) any { return c.Watch }},
+	{"ssh", func(c *config.Config) any { return c.SSH }},
+	{"anthropic", func(c *config.Config) any { return c.Anthropic }},
+	{"azure", func(c *config.Config) any { return c.Azure }},
+	{"openrouter", func(c *config.Config) any { return c.OpenRouter }},
+	{"gemini", func(c *config.Config) any { return c.Gemini }},
+}
+
+// watchConfig starts polling the config files until the session is closed
+func (s *session) watchConfig() *reloader {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.closers = append(s.closers, cancel)
+
+	r := &reloader{s: s, applied: s.cfg, stamps: currentStamps(s)}
+	if commands, err := config.DiscoverCommandsConfig(s.localRoot()); err == nil {
+		r.commands = commands.Commands
+	}
+
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.check()
+			}
+		}
+	}()
+	return r
+}
+
+// currentStamps records the state of the watched files
+func currentStamps(s *session) map[string]string {
+	stamps := map[string]string{}
+	for _, path := range watchedFiles(s) {
+		stamps[path] = fileStamp(path)
+	}
+	return stamps
+}
+
+// watchedFiles lists the config files for the current directory and the workspace root
+func watchedFiles(s *session) []string {
+	var paths []string
+	for _, dir := range []string{".", s.localRoot()} {
+		for _, path := range config.WatchedFiles(dir) {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// fileStamp identifies a version of a file by size and modification time
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "missing"
+	}
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+}
+
+// check reloads the config when any watched file was created, changed or removed
+func (r *reloader) check() {
+	var changed []string
+	stamps := map[string]string{}
+	for _, path := range watchedFiles(r.s) {
+		stamps[path] = fileStamp(path)
+		if previous, seen := r.stamps[path]; !seen || previous != stamps[path] {
+			if seen || stamps[path] != "missing" {
+				changed = append(changed, filepath.Base(path))
+			}
+		}
+	}
+	r.stamps = stamps
+	if len(changed) == 0 {
+		return
+	}
+
+	applied, err := r.reload()
+	source := strings.Join(changed, ", ")
+	switch {
+	case err != nil:
+		r.announce(fmt.Sprintf("config reload after changes to %s failed; the previous settings are still in effect: %v", source, err))
+	case applied != "":
+		r.announce(fmt.Sprintf("config reloaded from %s: %s", source, applied))
+	}
+}
+
+// reload loads the config again and applies the safe changes. It returns a
+// summary of what changed, or "" when nothing relevant did.
+func (r *reloader) reload() (string, error) {
+	cfg, err := loadConfig(r.s.opts)
+	if err != nil {
+		return "", err
+	}
+	commands, err := config.DiscoverCommandsConfig(r.s.localRoot())
+	if err != nil {
+		return "", err
+	}
+
+	// Build everything first so an invalid section changes nothing
+	policy, err := permissions.NewPolicy(cfg.Permissions)
+	if err != nil {
+		return "", fmt.Errorf("invalid permissions config: %w", err)
+	}
+	pathRules, err := permissions.NewPathRules(cfg.Paths)
+	if err != nil {
+		return "", fmt.Errorf("invalid paths config: %w", err)
+	}
+	catalog, err := models.New(cfg.ModelInfo)
+	if err != nil {
+		return "", err
+	}
+
+	var changes []string
+	if !reflect.DeepEqual(cfg.Tools, r.applied.Tools) {
+		if err := r.s.applyTools(cfg.Tools); err != nil {
+			return "", err
+		}
+		changes = append(changes, "tools updated")
+	}
+	if !reflect.DeepEqual(cfg.Permissions, r.applied.Permissions) {
+		r.s.policy.Replace(policy)
+		changes = append(changes, "permissions updated")
+	}
+	if !reflect.DeepEqual(cfg.Paths, r.applied.Paths) {
+		r.s.pathRules.Replace(pathRules)
+		r.s.workspace.SetPathPolicy(pathPolicy(cfg.Paths))
+		changes = append(changes, "path rules updated")
+	}
+	if !reflect.DeepEqual(cfg.ModelInfo, r.applied.ModelInfo) {
+		models.Use(catalog)
+		changes = append(changes, "model catalog updated")
+	}
+	changes = append(changes, diffCommands(r.commands, commands.Commands)...)
+
+	var restart []string
+	for _, field := range restartFields {
+		if !reflect.DeepEqual(field.get(cfg), field.get(r.s.cfg)) {
+			restart = append(restart, field.key)
+		}
+	}
+	if len(restart) > 0 {
+		changes = append(changes, "changes to "+strings.Join(restart, ", ")+" take effect after a restart")
+	}
+
+	r.applied = cfg
+	r.commands = commands.Commands
+	return strings.Join(changes, "; "), nil
+}
+
+// diffCommands describes added, removed and changed commands
+func diffCommands(before, after map[string]config.CommandSpec) []string {
+	var added, removed, changed []string
+	for name, spec := range after {
+		previous, existed := before[name]
+		switch {
+		case !existed:
+			added = append(added, name)
+		case !reflect.DeepEqual(previous, spec):
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, exists := after[name]; !exists {
+			removed = append(removed, name)
+		}
+	}
+
+	var changes []string
+	for _, group := range []struct {
+		label string
+		names []string
+	}{{"new commands", added}, {"changed commands", changed}, {"removed commands", removed}} {
+		if len(group.names) > 0 {
+			slices.Sort(group.names)
+			changes = append(changes, group.label+" "+strings.Join(group.names, ", "))
+		}
+	}
+	return changes
+}
+
+// announce prints a reload message and queues it for the model
+func (r *reloader) announce(message string) {
+	fmt.Printf("\n%s: %s\n", theme.Paint(theme.Notice, "config"), message)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.notices = append(r.notices, message)
+}
+
+// pending returns and clears the notices queued since the last call
+func (r *reloader) pending() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	notices := r.notices
+	r.notices = nil
+	return notices
+}
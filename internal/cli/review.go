@@ -0,0 +1,294 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agent/internal/agent"
+	"agent/internal/theme"
+	"github.com/spf13/cobra"
+)
+
+// Review severities, most severe first
+var severities = []string{"critical", "major", "minor", "info"}
+
+// maxReviewDiff caps the diff sent for review; Claude reads the files
+// for the rest
+const maxReviewDiff = 256 * 1024
+
+// reviewHook is the pre-commit hook written by --install-hook
+const reviewHook = `#!/bin/sh
+# Installed by "billdozer review --install-hook"; skip it with git commit --no-verify
+exec '%s' review --staged --fail-on critical
+`
+
+// reviewPrompt asks for the review; the diff and guidelines are appended
+const reviewPrompt = `Review the changes below, the output of "git diff %s", for the project's guidelines.
+Use the read-only tools to look at the surrounding code when the diff is not enough.
+Report only real problems in the changed lines: bugs, security issues, leaked secrets,
+violations of the guidelines and missing tests. Do not praise or restate the change.
+
+Severities:
+- critical: must not be committed, e.g. a broken build, data loss, a security hole or a secret
+- major: a bug or guideline violation that should be fixed before merging
+- minor: worth fixing, but not a problem
+- info: a remark
+
+Reply with only this JSON object and no other text:
+{"summary": "one sentence", "findings": [{"severity": "critical", "file": "path/to/file.go", "line": 12, "message": "what is wrong and how to fix it"}]}`
+
+// reviewFinding is one problem the review found
+type reviewFinding struct {
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// reviewResult is Claude's review, and whether it blocks the commit
+type reviewResult struct {
+	Summary  string          `json:"summary"`
+	Findings []reviewFinding `json:"findings"`
+	Blocking int             `json:"blocking"` // Findings at or above --fail-on
+}
+
+func newReviewCommand(opts *options) *cobra.Command {
+	var staged, asJSON, installHook, force bool
+	var failOn, guidelines string
+	cmd := &cobra.Command{
+		Use:   "review",
+		Short: "Review the changes in the working tree, or the staged ones, with a read-only agent",
+		Long: `Review a diff with a read-only agent and print its findings by severity:
+critical, major, minor or info. Without --staged the working tree is compared to HEAD.
+
+Claude judges the changes against the project's guidelines: the system prompt (such as
+BILLDOZER.md) and any --guidelines file. With --fail-on the command fails when a
+finding is at least that severe, so as a pre-commit hook it blocks the commit.
+--install-hook installs such a hook in the current repository.`,
+		Example: `  billdozer review
+  billdozer review --staged --fail-on critical --guidelines CONTRIBUTING.md
+  billdozer review --install-hook`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if failOn != "" && !slices.Contains(severities, failOn) {
+				return fmt.Errorf("unknown severity %q (available: %s)", failOn, strings.Join(severities, ", "))
+			}
+			if installHook {
+				return installReviewHook(cmd.Context(), cmd.OutOrStdout(), force)
+			}
+			result, err := runReview(cmd.Context(), opts, staged, guidelines)
+			if err != nil {
+				return err
+			}
+			if result == nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Nothing to review")
+				return nil
+			}
+			if failOn != "" {
+				for _, finding := range result.Findings {
+					if severityRank(finding.Severity) <= severityRank(failOn) {
+						result.Blocking++
+					}
+				}
+			}
+			if asJSON {
+				data, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			} else {
+				printReview(cmd.OutOrStdout(), result)
+			}
+			if result.Blocking > 0 {
+				return fmt.Errorf("the review found %d issues rated %s or worse; fix them, or skip the review with git commit --no-verify", result.Blocking, failOn)
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVar(&staged, "staged", false, "review the staged changes (git diff --cached), as a pre-commit hook does")
+	flags.StringVar(&failOn, "fail-on", "", "fail when a finding is this severe or worse: "+strings.Join(severities, ", "))
+	flags.StringVar(&guidelines, "guidelines", "", "file of review guidelines, e.g. CONTRIBUTING.md, besides the system prompt")
+	flags.BoolVar(&asJSON, "json", false, "print the findings as JSON")
+	flags.BoolVar(&installHook, "install-hook", false, "install a pre-commit hook that runs review --staged --fail-on critical")
+	flags.BoolVar(&force, "force", false, "with --install-hook, replace an existing pre-commit hook")
+	cmd.RegisterFlagCompletionFunc("fail-on", cobra.FixedCompletions(severities, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+// runReview has a read-only agent review the diff; the result is nil when
+// there are no changes
+func runReview(ctx context.Context, opts *options, staged bool, guidelines string) (*reviewResult, error) {
+	if err := requireLocalWorkspace(opts, "review"); err != nil {
+		return nil, err
+	}
+	opts.readOnly = true
+	opts.quiet = true
+	s, err := newSession(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	against := "HEAD"
+	if staged {
+		against = "--cached"
+	}
+	diff, err := git(ctx, s.workspace.Root(), nil, "diff", against)
+	if err != nil {
+		return nil, err
+	}
+	if diff == "" {
+		return nil, nil
+	}
+	if len(diff) > maxReviewDiff {
+		diff = diff[:maxReviewDiff] + "\n[diff truncated; read the changed files for the rest]"
+	}
+
+	prompt := fmt.Sprintf(reviewPrompt, against)
+	if guidelines != "" {
+		text, err := os.ReadFile(guidelines)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the guidelines: %w", err)
+		}
+		prompt += fmt.Sprintf("\n\nProject guidelines (%s):\n\n%s", filepath.Base(guidelines), text)
+	}
+	prompt += "\n\n```diff\n" + diff + "\n```"
+
+	// Reviews are not conversations worth resuming
+	save := false
+	s.cfg.Sessions.Save = &save
+
+	sent := false
+	once := func() (string, bool) {
+		if sent {
+			return "", false
+		}
+		sent = true
+		return prompt, true
+	}
+	reply := ""
+	runner, err := s.newAgent(once, agent.WithReply(func(text string) { reply = text }))
+	if err != nil {
+		return nil, err
+	}
+	// The transcript is not shown: only the findings are printed
+	if !opts.verbose && !opts.debug {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer devNull.Close()
+		original := os.Stdout
+		os.Stdout = devNull
+		defer func() { os.Stdout = original }()
+	}
+	if err := runner.Run(ctx); err != nil {
+		return nil, err
+	}
+	return parseReview(reply)
+}
+
+// parseReview reads the JSON object of Claude's reply, tolerating text or
+// a code fence around it
+func parseReview(reply string) (*reviewResult, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the review was not in the expected format:\n%s", reply)
+	}
+	var result reviewResult
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("the review was not in the expected format (%v):\n%s", err, reply)
+	}
+	for i, finding := range result.Findings {
+		if severity := strings.ToLower(finding.Severity); slices.Contains(severities, severity) {
+			result.Findings[i].Severity = severity
+		} else {
+			result.Findings[i].Severity = "info"
+		}
+	}
+	slices.SortStableFunc(result.Findings, func(a, b reviewFinding) int {
+		return severityRank(a.Severity) - severityRank(b.Severity)
+	})
+	return &result, nil
+}
+
+// severityRank orders severities, 0 being the most severe
+func severityRank(severity string) int {
+	return slices.Index(severities, severity)
+}
+
+// severityColors paints each severity like the diff and notice colors
+var severityColors = map[string]theme.Role{
+	"critical": theme.DiffDelete,
+	"major":    theme.DiffDelete,
+	"minor":    theme.Notice,
+	"info":     theme.Faint,
+}
+
+func printReview(w io.Writer, result *reviewResult) {
+	counts := map[string]int{}
+	for _, finding := range result.Findings {
+		counts[finding.Severity]++
+		location := finding.File
+		if location != "" && finding.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, finding.Line)
+		}
+		if location != "" {
+			location = theme.Paint(theme.Path, location) + ": "
+		}
+		severity := theme.Paint(severityColors[finding.Severity], fmt.Sprintf("%-8s", strings.ToUpper(finding.Severity)))
+		fmt.Fprintf(w, "%s %s%s\n", severity, location, finding.Message)
+	}
+	if len(result.Findings) > 0 {
+		fmt.Fprintln(w)
+	}
+	if result.Summary != "" {
+		fmt.Fprintln(w, result.Summary)
+	}
+	var totals []string
+	for _, severity := range severities {
+		if counts[severity] > 0 {
+			totals = append(totals, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	if len(totals) == 0 {
+		totals = []string{"no findings"}
+	}
+	fmt.Fprintln(w, strings.Join(totals, ", "))
+}
+
+// installReviewHook writes the pre-commit hook of the repository in the
+// current directory, honoring core.hooksPath
+func installReviewHook(ctx context.Context, w io.Writer, force bool) error {
+	path, err := git(ctx, ".", nil, "rev-parse", "--git-path", "hooks/pre-commit")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists; pass --force to replace it", path)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(reviewHook, executable)), 0o755); err != nil {
+		return fmt.Errorf("failed to install the hook: %w", err)
+	}
+	// An existing hook keeps its mode when replaced
+	if err := os.Chmod(path, 0o755); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Installed %s\n", path)
+	return nil
+}
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Version is the release version, set at build time with
+// -ldflags "-X agent/internal/cli.Version=v1.2.3"
+var Version = "dev"
+
+// Execute runs the command line and returns the error that ended it
+func Execute() error {
+	return newRootCommand().Execute()
+}
+
+// ExitError ends the program with a specific exit code, for commands whose
+// callers tell outcomes apart by it
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// newRootCommand builds the command tree. Running billdozer without a
+// subcommand starts an interactive chat, as before subcommands existed.
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:   "billdozer",
+		Short: "A coding agent that works in your project through tools",
+		Long: `billdozer chats with Claude, which reads, edits and searches files and runs
+your project's configured commands through tools.
+
+Settings are read from billdozer.yml (found in the current directory or a parent),
+layered over ~/.config/billdozer/config.yml and BILLDOZER_* environment variables.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		Version:      Version,
+		RunE:         func(cmd *cobra.Command, args []string) error { return runChat(cmd, opts) },
+	}
+	addChatFlags(root, opts)
+
+	flags := root.PersistentFlags()
+	flags.BoolVar(&opts.readOnly, "read-only", false, "disable tools that modify files or run commands")
+	flags.StringVar(&opts.workspaceRoot, "workspace", ".", "root directory that file tools are confined to, or ssh://[user@]host[:port]/path for one on another machine")
+	flags.BoolVar(&opts.allowOutside, "allow-outside-workspace", false, "allow file tools to access paths outside the workspace root")
+	flags.BoolVar(&opts.autoApprove, "auto-approve", false, "approve all confirmations without prompting (for headless runs)")
+	flags.StringVar(&opts.profile, "profile", "", "named profile from billdozer.yml to apply")
+	flags.StringVar(&opts.model, "model", "", "model ID or alias (fast, smart, or one defined under models:) for this session")
+	flags.StringVar(&opts.recordPath, "record", "", "record every tool call and result to this session file")
+	flags.StringVar(&opts.recordAPI, "record-api", "", "save every API request and response to this fixture file")
+	flags.StringVar(&opts.replayAPI, "replay-api", "", "answer API requests from a fixture saved with --record-api, offline")
+	root.MarkFlagsMutuallyExclusive("record-api", "replay-api")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "show only Claude's replies and confirmations, not tool calls and their output")
+	flags.BoolVarP(&opts.verbose, "verbose", "v", false, "log API requests and tool calls with timing to stderr")
+	flags.BoolVar(&opts.debug, "debug", false, "like --verbose, plus full tool inputs, outputs and responses")
+	flags.StringVar(&opts.logFile, "log-file", "", "write the --verbose (or --debug) log to this file instead of stderr")
+	flags.StringVar(&opts.logLevel, "log-level", "", "log level: debug, info, warn or error (default warn, or logging.level)")
+	flags.StringVar(&opts.logFormat, "log-format", "", "log format: text or json (default text, or logging.format)")
+	registerCompletions(root)
+
+	root.AddCommand(
+		newChatCommand(opts),
+		newRunCommand(opts),
+		newCICommand(opts),
+		newReviewCommand(opts),
+		newServeCommand(opts),
+		newACPCommand(opts),
+		newWatchCommand(opts),
+		newSessionsCommand(opts),
+		newBackupsCommand(),
+		newReplayAlias(opts),
+		newConfigCommand(opts),
+		newInitCommand(),
+		newAuthCommand(opts),
+		newToolsCommand(opts),
+		newVersionCommand(),
+	)
+	return root
+}
+
+func newChatCommand(opts *options) *cobra.Command {
+	chat := &cobra.Command{
+		Use:   "chat",
+		Short: "Start an interactive conversation (the default)",
+		Args:  cobra.NoArgs,
+		RunE:  func(cmd *cobra.Command, args []string) error { return runChat(cmd, opts) },
+	}
+	addChatFlags(chat, opts)
+	return chat
+}
+
+// addChatFlags adds the flags of interactive chats, which run both as
+// "billdozer chat" and as plain "billdozer"
+func addChatFlags(cmd *cobra.Command, opts *options) {
+	cmd.Flags().BoolVar(&opts.tui, "tui", false, "use the full-screen interface (transcript, tool panel and status bar)")
+}
+
+// runChat runs the interactive conversation loop
+func runChat(cmd *cobra.Command, opts *options) error {
+	s, err := newSession(cmd.Context(), opts)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if s.ui != nil {
+		agent, err := s.newAgent(s.ui.ReadLine)
+		if err != nil {
+			return err
+		}
+		return s.ui.Run(cmd.Context(), agent.Run)
+	}
+	agent, err := s.newAgent(s.editor.ReadLine)
+	if err != nil {
+		return err
+	}
+	return agent.Run(cmd.Context())
+}
+
+func newRunCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "run <prompt>...",
+		Short: "Send one prompt, let the agent work until it replies, then exit",
+		Long: `Send a single prompt and exit once the agent answers without calling more tools.
+The arguments are joined into the prompt; use "-" to read it from standard input.
+Confirmations are still asked on standard input unless --auto-approve is set, so a
+prompt read from standard input should be combined with --auto-approve.`,
+		Example: `  billdozer run "fix the failing test in internal/config"
+  git diff | billdozer run -`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := newSession(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			prompt := strings.Join(args, " ")
+			if prompt == "-" {
+				prompt = readAll(s.readLine)
+			}
+			if strings.TrimSpace(prompt) == "" {
+				return fmt.Errorf("the prompt is empty")
+			}
+
+			sent := false
+			once := func() (string, bool) {
+				if sent {
+					return "", false
+				}
+				sent = true
+				return prompt, true
+			}
+			agent, err := s.newAgent(once)
+			if err != nil {
+				return err
+			}
+			return agent.Run(cmd.Context())
+		},
+	}
+}
+
+// readAll joins every remaining input line
+func readAll(readLine func() (string, bool)) string {
+	var lines []string
+	for {
+		line, ok := readLine()
+		if !ok {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintf(cmd.OutOrStdout(), "billdozer %s (%s, %s/%s)\n", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		},
+	}
+}
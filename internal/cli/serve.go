@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/logging"
+	"agent/internal/network"
+	"agent/internal/remote"
+	"agent/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func newServeCommand(opts *options) *cobra.Command {
+	listen := config.DefaultServerListen
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a session for remote frontends, streaming its events over SSE or WebSocket",
+		Long: `Run one session whose output, tool calls, activity, token usage and confirmation
+requests are streamed to clients as JSON events, and whose messages and confirmation
+answers come from them:
+
+  GET  /events  server-sent events; Last-Event-ID or ?after=N resumes a stream
+  GET  /ws      WebSocket: the same events out, {"text": ...} input in
+  POST /input   {"text": ...} answers the pending message or confirmation
+
+Every event is kept, so a client that connects late sees the session from the start.
+Without server.principals the server listens on localhost only, and anyone who can
+reach it controls the session.
+
+With server.principals every request must carry an API key or OIDC token as an
+"Authorization: Bearer" header (or ?access_token= for browsers), and each principal
+gets a session of its own, restricted by its profile. Interrupt the server to end
+its sessions.`,
+		Example: `  billdozer serve
+  curl -N localhost:8765/events
+  curl -d '{"text": "run the tests"}' -H 'Content-Type: application/json' localhost:8765/input
+  curl -N -H "Authorization: Bearer $BILLDOZER_KEY" https://billdozer.internal:8765/events`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			cfg, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			if err := cfg.ValidateServer(); err != nil {
+				return fmt.Errorf("invalid server config:\n%w", err)
+			}
+			if !cmd.Flags().Changed("listen") {
+				listen = cmp.Or(cfg.Server.Listen, config.DefaultServerListen)
+			}
+
+			// Sessions started by a gateway are reached through it alone
+			token := os.Getenv(server.TokenEnv)
+			if token != "" {
+				return serveSession(ctx, opts, cfg, listen, token)
+			}
+			if len(cfg.Server.Principals) > 0 {
+				return serveGateway(ctx, opts, cfg, listen)
+			}
+			if !isLoopback(listen) {
+				return fmt.Errorf("refusing to serve an unauthenticated session on %s; configure server.principals or listen on localhost", listen)
+			}
+			return serveSession(ctx, opts, cfg, listen, "")
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", listen, "address to listen on, host:port (default server.listen)")
+	return cmd
+}
+
+// serveSession runs one session until it ends or ctx is done. With a
+// token, only requests carrying it are served.
+func serveSession(ctx context.Context, opts *options, cfg *config.Config, listen, token string) error {
+	opts.serve = true
+	s, err := openSession(ctx, opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	agent, err := s.newAgent(s.remote.ReadLine)
+	if err != nil {
+		return err
+	}
+
+	handler := s.remote.Handler()
+	tls := cfg.Server
+	if token != "" {
+		handler = server.RequireToken(token, handler)
+		// The gateway terminates TLS
+		tls = config.ServerConfig{}
+	}
+	httpServer, served, err := startHTTP(listen, handler, tls, server.ReadyMessage)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Streams end with the session; give them a moment to send the last events
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		s.remote.Drain(ctx)
+		httpServer.Shutdown(ctx)
+	}()
+
+	runErr := s.remote.Run(ctx, agent.Run)
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return errors.Join(runErr, err)
+		}
+	default:
+	}
+	return runErr
+}
+
+// serveGateway authenticates requests and serves each principal a session
+// of its own until ctx is done
+func serveGateway(ctx context.Context, opts *options, cfg *config.Config, listen string) error {
+	logger, closeLog, err := logging.Open(loggingOptions(opts, cfg.Logging))
+	if err != nil {
+		return fmt.Errorf("invalid logging config: %w", err)
+	}
+	defer closeLog()
+	logging.Use(logger)
+	client, err := network.NewClient(cfg.Network)
+	if err != nil {
+		return err
+	}
+
+	auth := server.NewAuthenticator(cfg.Server, client)
+	gateway := server.NewGateway(auth, func(principal config.PrincipalConfig) *exec.Cmd {
+		return principalCommand(opts, principal)
+	}, logging.For(logging.Server))
+	ready := fmt.Sprintf("Serving sessions of %d principals on", len(cfg.Server.Principals))
+	httpServer, served, err := startHTTP(listen, gateway.Handler(), cfg.Server, ready)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-served:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		gateway.Shutdown(ctx)
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	gateway.Shutdown(shutdown)
+	httpServer.Shutdown(shutdown)
+	return nil
+}
+
+// startHTTP serves handler on listen, over TLS when the config has a
+// certificate, and prints ready followed by the URL to standard error
+func startHTTP(listen string, handler http.Handler, cfg config.ServerConfig, ready string) (*http.Server, <-chan error, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpServer := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	scheme := "http"
+	if cfg.TLSCert != "" {
+		scheme = "https"
+		go func() { served <- httpServer.ServeTLS(listener, cfg.TLSCert, cfg.TLSKey) }()
+	} else {
+		go func() { served <- httpServer.Serve(listener) }()
+	}
+	fmt.Fprintf(os.Stderr, "%s %s://%s\n", ready, scheme, listener.Addr())
+	return httpServer, served, nil
+}
+
+// principalCommand runs the session of a principal: "serve" on a free
+// local port with the principal's profile and workspace, and the server's
+// other session flags
+func principalCommand(opts *options, principal config.PrincipalConfig) *exec.Cmd {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+	workspace := opts.workspaceRoot
+	if principal.Workspace != "" {
+		workspace = principal.Workspace
+		switch {
+		case filepath.IsAbs(workspace) || remote.IsTarget(workspace):
+		case remote.IsTarget(opts.workspaceRoot):
+			workspace = strings.TrimSuffix(opts.workspaceRoot, "/") + "/" + workspace
+		default:
+			workspace = filepath.Join(opts.workspaceRoot, workspace)
+		}
+	}
+	args := []string{"serve", "--listen", "127.0.0.1:0", "--workspace", workspace}
+	if profile := cmp.Or(principal.Profile, opts.profile); profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	for flag, set := range map[string]bool{
+		"--read-only":               opts.readOnly,
+		"--allow-outside-workspace": opts.allowOutside,
+		"--auto-approve":            opts.autoApprove,
+		"--quiet":                   opts.quiet,
+		"--verbose":                 opts.verbose,
+		"--debug":                   opts.debug,
+	} {
+		if set {
+			args = append(args, flag)
+		}
+	}
+	for flag, value := range map[string]string{
+		"--model":      opts.model,
+		"--replay-api": opts.replayAPI,
+		"--log-level":  opts.logLevel,
+		"--log-format": opts.logFormat,
+	} {
+		if value != "" {
+			args = append(args, flag, value)
+		}
+	}
+	return exec.Command(executable, args...)
+}
+
+// isLoopback reports whether listen only accepts local connections
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,794 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"agent/internal/acp"
+	"agent/internal/agent"
+	"agent/internal/auth"
+	"agent/internal/backup"
+	"agent/internal/cache"
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/filecache"
+	"agent/internal/filestate"
+	"agent/internal/gocheck"
+	"agent/internal/ignore"
+	"agent/internal/lineedit"
+	"agent/internal/logging"
+	"agent/internal/mcp"
+	"agent/internal/metrics"
+	"agent/internal/mockapi"
+	"agent/internal/models"
+	"agent/internal/network"
+	"agent/internal/notify"
+	"agent/internal/permissions"
+	"agent/internal/plugin"
+	"agent/internal/prompt"
+	"agent/internal/provider"
+	"agent/internal/redact"
+	"agent/internal/remote"
+	"agent/internal/render"
+	"agent/internal/replay"
+	"agent/internal/retry"
+	"agent/internal/server"
+	"agent/internal/sessions"
+	"agent/internal/spinner"
+	"agent/internal/theme"
+	"agent/internal/tools"
+	"agent/internal/tools/benchmark"
+	"agent/internal/tools/command"
+	"agent/internal/tools/database"
+	"agent/internal/tools/file"
+	"agent/internal/tools/kubernetes"
+	"agent/internal/tools/licenses"
+	"agent/internal/tools/openapi"
+	"agent/internal/tools/protobuf"
+	"agent/internal/tui"
+	"agent/internal/usage"
+	"agent/internal/workspace"
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// options are the flags shared by every command that starts a session
+type options struct {
+	readOnly      bool
+	workspaceRoot string
+	allowOutside  bool
+	autoApprove   bool
+	profile       string
+	model         string
+	recordPath    string
+	recordAPI     string // Fixture file that API exchanges are saved to
+	replayAPI     string // Fixture file that answers API requests instead of the API
+	tui           bool
+	serve         bool        // Set by "serve": remote clients stand in for the terminal
+	acp           *acp.Server // Set by "acp": an editor stands in for the terminal
+	resume        string      // ID of a saved session to continue; set by "sessions resume"
+	verbose       bool
+	debug         bool
+	logFile       string
+	logLevel      string
+	logFormat     string
+	quiet         bool
+}
+
+// session holds everything a command needs to run tools or the agent:
+// the loaded config, the registry with its middleware installed, and the
+// services tools depend on. Close releases plugins, MCP servers, background
+// commands and the session recording.
+type session struct {
+	opts         *options
+	cfg          *config.Config
+	systemPrompt string
+	registry     *tools.Registry
+	workspace    *workspace.Workspace
+	confirmer    *confirm.Service
+	recorder     *metrics.Recorder
+	editor       *lineedit.Editor // Plain mode input; nil when ui is set
+	ui           *tui.UI          // Full-screen interface with --tui
+	remote       *server.Server   // Event stream and input of "serve"
+	usage        *usage.Tracker
+	readLine     func() (string, bool)
+	httpClient   *http.Client
+	backups      *backup.Session  // nil when backups are off
+	files        *filecache.Cache // nil when caching is off
+	seen         *filestate.Tracker
+	policy       *permissions.Policy
+	pathRules    *permissions.PathRules
+	descriptions map[string]string // Built-in descriptions of tools whose description the config edits
+	logger       *slog.Logger      // Diagnostics of the session component
+	started      time.Time         // When the agent was created, for the session report
+	closers      []func()
+}
+
+// loadConfig loads the layered config and applies the selected profile and --model
+func loadConfig(opts *options) (*config.Config, error) {
+	cfg, err := config.Load(".")
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyProfile(opts.profile); err != nil {
+		return nil, err
+	}
+	if opts.model != "" {
+		cfg.Model = opts.model
+		cfg.SetOrigin("model", "flag --model")
+	}
+	return cfg, nil
+}
+
+// loggingOptions layers the logging flags over the config: --debug and
+// --verbose pick the level, then --log-level; a log file without a level
+// logs at info
+func loggingOptions(opts *options, cfg config.LoggingConfig) logging.Options {
+	out := logging.Options{Level: cfg.Level, Format: cfg.Format, File: cfg.File, Components: cfg.Components}
+	if opts.logFile != "" {
+		out.File = opts.logFile
+	}
+	if opts.logFormat != "" {
+		out.Format = opts.logFormat
+	}
+	switch {
+	case opts.debug:
+		out.Level = "debug"
+	case opts.verbose:
+		out.Level = "info"
+	case opts.logLevel != "":
+		out.Level = opts.logLevel
+	case out.Level == "" && out.File != "":
+		out.Level = "info"
+	}
+	return out
+}
+
+// historyPath is where the line editor keeps input history across sessions
+func historyPath() string {
+	if dir := config.GlobalConfigDir(); dir != "" {
+		return filepath.Join(dir, "history")
+	}
+	return ""
+}
+
+// backupStore is where files are backed up before tools overwrite or
+// delete them; nil when there is no global config directory
+func backupStore() *backup.Store {
+	if dir := config.GlobalConfigDir(); dir != "" {
+		return backup.NewStore(filepath.Join(dir, "backups"))
+	}
+	return nil
+}
+
+// sessionStore is where conversations are saved; nil when there is no
+// global config directory
+func sessionStore() *sessions.Store {
+	if dir := config.GlobalConfigDir(); dir != "" {
+		return sessions.NewStore(filepath.Join(dir, "sessions"))
+	}
+	return nil
+}
+
+// newSession loads the config and wires up the tool registry
+func newSession(ctx context.Context, opts *options) (*session, error) {
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return openSession(ctx, opts, cfg)
+}
+
+// openSession wires up the tool registry for a config already loaded
+func openSession(ctx context.Context, opts *options, cfg *config.Config) (*session, error) {
+	if err := cfg.ValidateProvider(); err != nil {
+		return nil, err
+	}
+	systemPrompt, err := cfg.ReadSystemPrompt()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{opts: opts, cfg: cfg, systemPrompt: systemPrompt, registry: tools.DefaultRegistry, descriptions: map[string]string{}}
+	fail := func(err error) (*session, error) {
+		s.Close()
+		return nil, err
+	}
+
+	logger, closeLog, err := logging.Open(loggingOptions(opts, cfg.Logging))
+	if err != nil {
+		return fail(fmt.Errorf("invalid logging config: %w", err))
+	}
+	logging.Use(logger)
+	s.logger = logging.For(logging.Session)
+	s.closers = append(s.closers, func() { closeLog() })
+	s.logger.Debug("config loaded", "sources", cfg.Sources, "model", cfg.ModelOrDefault(), "profile", opts.profile)
+
+	color := cfg.Theme.Color
+	if opts.serve && color != theme.ColorAlways {
+		// Output is streamed to clients, which may not understand escape codes
+		color = theme.ColorNever
+	}
+	colors, err := theme.New(cfg.Theme.Scheme, cfg.Theme.Colors, color)
+	if err != nil {
+		return fail(fmt.Errorf("invalid theme config: %w", err))
+	}
+	theme.Use(colors)
+	catalog, err := models.New(cfg.ModelInfo)
+	if err != nil {
+		return fail(err)
+	}
+	models.Use(catalog)
+	if !colors.Enabled() {
+		// The TUI styles itself with lipgloss, which must agree
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	// Plugins and MCP servers register their tools before the tools config is applied so their groups can be referenced
+	plugins, err := plugin.LoadAll(ctx, cfg.Plugins, s.registry)
+	if err != nil {
+		return fail(err)
+	}
+	s.closers = append(s.closers, func() { plugin.CloseAll(plugins) })
+
+	// Every outbound HTTP call honors the proxy and CA settings
+	s.httpClient, err = network.NewClient(cfg.Network)
+	if err != nil {
+		return fail(err)
+	}
+
+	mcpServers, err := mcp.LoadAll(ctx, cfg.MCPServers, s.registry, s.httpClient)
+	if err != nil {
+		return fail(err)
+	}
+	s.closers = append(s.closers, func() { mcp.CloseAll(mcpServers) })
+
+	if err := s.applyTools(cfg.Tools); err != nil {
+		return fail(err)
+	}
+	file.SetMaxReadBytes(cfg.Limits.MaxReadBytes)
+	kubernetes.Configure(cfg.Kubernetes)
+	database.Configure(cfg.Databases)
+	database.ConfigureMigrations(cfg.Migrations)
+	openapi.SetHTTPClient(s.httpClient)
+	openapi.Configure(cfg.OpenAPI)
+	protobuf.Configure(cfg.Protobuf)
+	benchmark.Configure(cfg.Benchmarks)
+	licenses.Configure(cfg.Licenses)
+
+	s.policy, err = permissions.NewPolicy(cfg.Permissions)
+	if err != nil {
+		return fail(fmt.Errorf("invalid permissions config: %w", err))
+	}
+
+	s.workspace, err = openWorkspace(ctx, opts, cfg.SSH, s)
+	if err != nil {
+		return fail(err)
+	}
+	// Dev servers and watchers started by the agent must not outlive the
+	// session; remote ones are stopped before the connection closes
+	s.closers = append(s.closers, command.StopBackground)
+
+	s.pathRules, err = permissions.NewPathRules(cfg.Paths)
+	if err != nil {
+		return fail(fmt.Errorf("invalid paths config: %w", err))
+	}
+	s.workspace.SetPathChecker(s.pathRules)
+	s.workspace.SetPathPolicy(pathPolicy(cfg.Paths))
+
+	ignored, err := ignore.LoadWith(s.workspace.FS().ReadFile, s.workspace.Root())
+	if err != nil {
+		return fail(err)
+	}
+	s.workspace.SetIgnore(ignored)
+	s.logger.Debug("workspace ready", "root", s.workspace.Root(), "host", s.workspace.Host(), "read_only", opts.readOnly)
+
+	redactor, err := redact.New(cfg.Redaction.IsEnabled(), cfg.Redaction.Patterns)
+	if err != nil {
+		return fail(fmt.Errorf("invalid redaction config: %w", err))
+	}
+
+	// User messages go through the line editor's history; confirmation answers do not.
+	// The TUI reads both from its input box, and "serve" from its clients.
+	s.usage = usage.New()
+	switch {
+	case opts.serve:
+		s.remote = server.New(s.usage)
+		s.readLine = s.remote.ReadLine
+		s.registry.Use(s.remote.Middleware())
+	case opts.acp != nil:
+		s.readLine = opts.acp.ReadLine
+		s.registry.Use(opts.acp.Middleware())
+	case opts.tui && tui.Supported():
+		s.ui = tui.New(cfg.ModelOrDefault(), s.usage)
+		s.readLine = s.ui.ReadLine
+		s.registry.Use(s.ui.Middleware())
+	default:
+		if opts.tui {
+			fmt.Println("--tui needs an interactive terminal; using plain mode")
+		}
+		s.editor = lineedit.New(historyPath())
+		s.readLine = s.editor.ReadAnswer
+	}
+
+	s.confirmer = confirm.NewService(s.readLine, opts.autoApprove || cfg.Confirmation.AutoApprove)
+	switch {
+	case s.remote != nil:
+		s.confirmer.SetBeforePrompt(s.remote.Confirming)
+	case opts.acp != nil:
+		s.confirmer.SetBeforePrompt(opts.acp.Confirming)
+	}
+
+	// Tools are looked up from the registry so read-only mode can be toggled at runtime
+	s.registry.SetReadOnly(opts.readOnly)
+
+	var resultCache *cache.Cache
+	if cfg.Cache.IsEnabled() {
+		resultCache = cache.New()
+		s.files = filecache.New(filecache.DefaultMaxBytes)
+	}
+
+	s.recorder = metrics.New()
+	s.seen = filestate.New()
+
+	if store := backupStore(); store != nil && cfg.Backups.IsEnabled() {
+		if err := store.Prune(cfg.Backups.Retention()); err != nil {
+			s.logger.Warn("failed to remove old backups", "error", err)
+		}
+		s.backups = store.NewSession()
+	}
+
+	var sessionLog *replay.Log
+	if opts.recordPath != "" {
+		sessionLog, err = replay.Create(opts.recordPath)
+		if err != nil {
+			return fail(err)
+		}
+		s.closers = append(s.closers, func() { sessionLog.Close() })
+	}
+
+	// Cross-cutting concerns wrap every tool. The session recording and the debug log are outermost
+	// so they capture exactly what the model saw; redaction comes next so it also scrubs policy errors,
+	// and the cache sits inside the permission check so denied calls are never answered from it.
+	// Metrics are recorded after confirmation so latencies exclude time spent waiting on the user,
+	// and outside retries so a retried call counts once.
+	s.registry.Use(
+		replay.Middleware(sessionLog),
+		logging.Middleware(logging.For(logging.Tools)),
+		redact.Middleware(redactor),
+		permissions.Middleware(s.policy, s.confirmer),
+		metrics.Middleware(s.recorder),
+		retry.Middleware(retry.DefaultAttempts, retry.DefaultBaseDelay),
+		cache.Middleware(resultCache),
+	)
+	// Innermost, so their findings are part of the result everything above sees
+	if cfg.GoChecks.Enabled {
+		s.registry.Use(gocheck.Middleware(cfg.GoChecks.VetEnabled()))
+	}
+
+	// The prompt is rendered once the tool list is final
+	s.systemPrompt, err = prompt.Render(filepath.Base(cfg.SystemPrompt), s.systemPrompt,
+		prompt.NewData(s.workspace.Root(), cfg.ModelOrDefault(), s.registry))
+	if err != nil {
+		return fail(err)
+	}
+	return s, nil
+}
+
+// applyTools enables tools and edits their descriptions as configured. The
+// config is checked before anything changes, so a bad reload leaves the
+// registry as it was. Edits always start from the built-in description.
+func (s *session) applyTools(cfg config.ToolsConfig) error {
+	edited := map[string]string{}
+	for name, edit := range cfg.Descriptions {
+		base, saved := s.descriptions[name]
+		if !saved {
+			tool := s.registry.GetByName(name)
+			if tool == nil {
+				return fmt.Errorf("invalid tools config: descriptions: unknown tool %q", name)
+			}
+			base = tool.Description
+		}
+		edited[name] = edit.Apply(base)
+		if strings.TrimSpace(edited[name]) == "" {
+			return fmt.Errorf("invalid tools config: descriptions: the description of %q is empty", name)
+		}
+		s.descriptions[name] = base
+	}
+	if err := s.registry.SetEnabled(cfg.Enabled, cfg.Disabled); err != nil {
+		return fmt.Errorf("invalid tools config: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.descriptions)) {
+		description, ok := edited[name]
+		if !ok {
+			description = s.descriptions[name]
+		}
+		if err := s.registry.SetDescription(name, description); err != nil {
+			return fmt.Errorf("invalid tools config: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close releases the session's resources in reverse order of acquisition
+func (s *session) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.closers = nil
+}
+
+// openWorkspace opens the --workspace root. An ssh:// root is dialed with
+// the ssh config, and the connection is closed with the session.
+func openWorkspace(ctx context.Context, opts *options, cfg config.SSHConfig, s *session) (*workspace.Workspace, error) {
+	if !remote.IsTarget(opts.workspaceRoot) {
+		return workspace.New(opts.workspaceRoot, opts.allowOutside)
+	}
+	target, err := remote.ParseTarget(opts.workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("connecting to remote workspace", "host", target.String(), "jump", cfg.Jump)
+	host, err := remote.Dial(ctx, target, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.closers = append(s.closers, func() { host.Close() })
+	return workspace.NewRemote(host.String(), host.Abs(target.Path), host, host, opts.allowOutside)
+}
+
+// requireLocalWorkspace rejects ssh:// workspaces for commands that read
+// the workspace's files or run git next to them directly
+func requireLocalWorkspace(opts *options, command string) error {
+	if remote.IsTarget(opts.workspaceRoot) {
+		return fmt.Errorf("%s needs a local workspace; %s is remote", command, opts.workspaceRoot)
+	}
+	return nil
+}
+
+// localRoot is the local directory project config is discovered from: the
+// workspace root, or the current directory for remote workspaces
+func (s *session) localRoot() string {
+	if s.workspace.Host() != "" {
+		return "."
+	}
+	return s.workspace.Root()
+}
+
+// pathPolicy is how the workspace treats absolute paths and paths outside it
+func pathPolicy(cfg config.PathsConfig) workspace.PathPolicy {
+	return workspace.PathPolicy{Absolute: cfg.Absolute, Outside: cfg.Outside}
+}
+
+// toolContext returns the dependencies for running tools outside the agent loop
+func (s *session) toolContext() *tools.ToolContext {
+	return &tools.ToolContext{GetUserInput: s.readLine, Workspace: s.workspace, Confirmer: s.confirmer, Backups: s.backups, Files: s.files, Seen: s.seen}
+}
+
+// recordUsage tracks the tokens of each response, on the status bar in the TUI
+func (s *session) recordUsage(model string, response anthropic.Usage) {
+	switch {
+	case s.ui != nil:
+		s.ui.RecordUsage(model, response)
+	case s.remote != nil:
+		s.remote.RecordUsage(model, response)
+	default:
+		s.usage.Add(model, response)
+	}
+}
+
+// activity is where the agent shows what it is busy with: the status bar
+// of the TUI, activity events of "serve", a spinner on terminals, or
+// nowhere when output is piped or goes to an editor
+func (s *session) activity() agent.Activity {
+	switch {
+	case s.ui != nil:
+		return s.ui
+	case s.remote != nil:
+		return s.remote
+	case s.opts.acp != nil:
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	progress := spinner.New(os.Stdout)
+	s.confirmer.SetBeforeOutput(progress.Stop)
+	return progress
+}
+
+// statusLine summarizes model, context and token usage after each turn on
+// terminals. The TUI shows the same in its status bar instead, and "serve"
+// sends usage events.
+func (s *session) statusLine() func(model string) string {
+	if s.ui != nil || s.remote != nil || s.opts.acp != nil || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+	return func(model string) string {
+		return s.usage.Totals().StatusLine(model)
+	}
+}
+
+// notifier alerts the user after long waits, as configured under
+// notifications. The bell rings on stderr, which the TUI leaves alone,
+// and only when stderr is a terminal. Clients of "serve" are told through
+// events and editors through permission requests instead.
+func (s *session) notifier() *notify.Notifier {
+	if s.remote != nil || s.opts.acp != nil {
+		return nil
+	}
+	cfg := s.cfg.Notify
+	var bell io.Writer
+	if cfg.BellEnabled() && term.IsTerminal(int(os.Stderr.Fd())) {
+		bell = os.Stderr
+	}
+	if bell == nil && !cfg.Desktop {
+		return nil
+	}
+	notifier := notify.New(bell, cfg.Desktop, cfg.After())
+	s.confirmer.SetBeforePrompt(func(req confirm.Request) {
+		notifier.Notify("Claude needs confirmation", req.Description())
+	})
+	return notifier
+}
+
+// composer opens an external editor for /editor in plain terminal mode;
+// the TUI and piped input have none
+func (s *session) composer() func(draft string) (string, error) {
+	if s.editor == nil || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	return s.editor.Compose
+}
+
+// markdownRenderer renders responses as markdown on terminals (including
+// the TUI) and returns nil, printing them as is, for pipes, dumb terminals
+// and the clients of "serve" and editors, which render markdown themselves
+func (s *session) markdownRenderer() func(string) string {
+	fd := int(os.Stdout.Fd())
+	if s.remote != nil || s.opts.acp != nil || !term.IsTerminal(fd) || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	width, _, err := term.GetSize(fd)
+	if err != nil {
+		width = 0
+	}
+	style := render.StyleLight
+	switch {
+	case !theme.Enabled() || s.cfg.Theme.Scheme == "mono":
+		style = render.StylePlain
+	case s.cfg.Theme.Scheme != "light" && lipgloss.HasDarkBackground():
+		style = render.StyleDark
+	}
+	markdown, err := render.NewMarkdown(width, style)
+	if err != nil {
+		return nil
+	}
+	return markdown.Render
+}
+
+// apiClient connects to the Anthropic API, or to the configured provider
+// through a translating transport. With --replay-api responses come
+// from a fixture instead and no API key is needed; with --record-api every
+// exchange is also saved to a fixture.
+func (s *session) apiClient() (*anthropic.Client, error) {
+	if s.opts.replayAPI != "" {
+		exchanges, err := mockapi.Load(s.opts.replayAPI)
+		if err != nil {
+			return nil, err
+		}
+		return mockapi.Client(mockapi.NewReplayer(exchanges)), nil
+	}
+
+	key := ""
+	if provider.NeedsKey(s.cfg) {
+		credential, err := auth.Resolve(s.cfg)
+		if err != nil {
+			return nil, err
+		}
+		key = credential.Key
+	}
+	httpClient := s.httpClient
+	backend, err := provider.Transport(s.cfg, key, s.httpClient.Transport)
+	if err != nil {
+		return nil, err
+	}
+	if backend != nil {
+		httpClient = &http.Client{Transport: backend}
+	}
+	clientOpts := append(anthropicOptions(s.cfg.Anthropic), option.WithAPIKey(key), option.WithHTTPClient(httpClient))
+	if s.opts.recordAPI != "" {
+		recorder, err := mockapi.Record(s.opts.recordAPI)
+		if err != nil {
+			return nil, err
+		}
+		s.closers = append(s.closers, func() { recorder.Close() })
+		clientOpts = append(clientOpts, option.WithMiddleware(recorder.Middleware()))
+	}
+	client := anthropic.NewClient(clientOpts...)
+	return &client, nil
+}
+
+// anthropicOptions route the Anthropic client through a configured gateway
+func anthropicOptions(cfg config.AnthropicConfig) []option.RequestOption {
+	var opts []option.RequestOption
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Headers)) {
+		opts = append(opts, option.WithHeader(name, cfg.Headers[name]))
+	}
+	if cfg.TimeoutSeconds > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.Timeout()))
+	}
+	return opts
+}
+
+// transcriptLabels converts the transcript config into agent labels
+func transcriptLabels(cfg config.TranscriptConfig) agent.Labels {
+	return agent.Labels{
+		User:       cfg.User,
+		Assistant:  cfg.Assistant,
+		Tool:       cfg.Tool,
+		Prompt:     cfg.Prompt,
+		Timestamps: cfg.Timestamps,
+	}.WithDefaults()
+}
+
+// newAgent creates an agent that reads user messages from getUserMessage;
+// extra options are applied last. It fails when no API key can be found.
+func (s *session) newAgent(getUserMessage func() (string, bool), extra ...agent.Option) (*agent.Agent, error) {
+	client, err := s.apiClient()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.conversation()
+	if err != nil {
+		return nil, err
+	}
+	reload := s.watchConfig()
+	s.started = time.Now()
+	s.closers = append(s.closers, func() { s.summarize(saved) })
+	return agent.NewAgent(client, getUserMessage, s.registry, s.agentOptions(saved.messages(), saved.checkpoint, reload, extra...)...), nil
+}
+
+// agentOptions configure an agent of the session that continues messages,
+// hands its checkpoints to checkpoint and is told about reloads; extra
+// options are applied last
+func (s *session) agentOptions(messages []anthropic.MessageParam, checkpoint func([]anthropic.MessageParam), reload *reloader, extra ...agent.Option) []agent.Option {
+	return append([]agent.Option{
+		agent.WithConversation(messages),
+		agent.WithCheckpoint(checkpoint),
+		agent.WithNotices(reload.pending),
+		agent.WithWorkspace(s.workspace),
+		agent.WithConfirmer(s.confirmer),
+		agent.WithBackups(s.backups),
+		agent.WithFileCache(s.files),
+		agent.WithFileVersions(s.seen),
+		agent.WithMetrics(s.recorder),
+		agent.WithModel(s.cfg.ModelOrDefault(), s.cfg.MaxTokensOrDefault()),
+		agent.WithModelAliases(s.cfg.ModelAliases()),
+		agent.WithSystemPrompt(s.systemPrompt),
+		agent.WithUsage(s.recordUsage),
+		agent.WithRenderer(s.markdownRenderer()),
+		agent.WithActivity(s.activity()),
+		agent.WithLogger(logging.For(logging.Agent)),
+		agent.WithComposer(s.composer()),
+		agent.WithNotifier(s.notifier()),
+		agent.WithStatus(s.statusLine()),
+		agent.WithQuiet(s.opts.quiet),
+		agent.WithLabels(transcriptLabels(s.cfg.Transcript)),
+		agent.WithSpendingLimit(s.cfg.Limits.MaxCost, func() float64 { return s.usage.Totals().Cost }),
+		agent.WithMaxTurns(s.cfg.Limits.MaxTurns)}, extra...)
+}
+
+// report summarizes what the session did since the agent was created
+func (s *session) report() metrics.Report {
+	totals := s.usage.Totals()
+	return s.recorder.Report(metrics.Report{
+		Started:      s.started,
+		DurationMS:   time.Since(s.started).Milliseconds(),
+		Turns:        totals.Responses,
+		InputTokens:  totals.InputTokens,
+		OutputTokens: totals.OutputTokens,
+		Cost:         totals.Cost,
+		Unpriced:     totals.Unpriced,
+	})
+}
+
+// summarize prints the session's report and keeps it with the saved
+// conversation. Sessions that never reached the model report nothing.
+func (s *session) summarize(saved *savedSession) {
+	report := s.report()
+	if report.Turns == 0 && report.Calls() == 0 {
+		return
+	}
+	if !s.opts.quiet {
+		fmt.Println("\nSession summary:")
+		report.Write(os.Stdout)
+	}
+	saved.addRun(report)
+}
+
+// savedSession records the agent's conversation in the session store. A
+// nil *savedSession saves nothing, for sessions with saving turned off.
+type savedSession struct {
+	store   *sessions.Store // nil when a resumed session is not saved again
+	session *sessions.Session
+	warned  bool
+}
+
+// conversation loads the session to resume, or starts a new saved session.
+// A resumed session keeps being saved unless saving is turned off.
+func (s *session) conversation() (*savedSession, error) {
+	store := sessionStore()
+	save := s.cfg.Sessions.SaveEnabled() && store != nil
+	if s.opts.resume == "" {
+		if !save {
+			return nil, nil
+		}
+		return &savedSession{store: store, session: store.New(s.workspace.String(), s.cfg.ModelOrDefault())}, nil
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("cannot resume %s: no config directory to read sessions from", s.opts.resume)
+	}
+	resumed, err := store.Load(s.opts.resume)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Resuming %s %q (%d messages)\n", resumed.ID, resumed.Title, len(resumed.Messages))
+	if resumed.Dir != s.workspace.String() {
+		fmt.Printf("note: the session ran in %s; the workspace is now %s\n", resumed.Dir, s.workspace)
+	}
+	if !save {
+		// The conversation continues but the saved file stays as it was
+		return &savedSession{session: resumed}, nil
+	}
+	return &savedSession{store: store, session: resumed}, nil
+}
+
+// addRun saves the report of a finished run with the conversation
+func (c *savedSession) addRun(report metrics.Report) {
+	if c == nil || c.store == nil || len(c.session.Messages) == 0 {
+		return
+	}
+	c.session.Runs = append(c.session.Runs, report)
+	if err := c.store.Save(c.session); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to save the session summary: %v\n", err)
+	}
+}
+
+// messages returns the conversation to continue
+func (c *savedSession) messages() []anthropic.MessageParam {
+	if c == nil {
+		return nil
+	}
+	return c.session.Messages
+}
+
+// checkpoint saves the conversation; it is an agent.WithCheckpoint callback.
+// Saving is best effort: a failure is reported once and the chat goes on.
+func (c *savedSession) checkpoint(messages []anthropic.MessageParam) {
+	if c == nil || c.store == nil {
+		return
+	}
+	c.session.Messages = messages
+	if err := c.store.Save(c.session); err != nil && !c.warned {
+		c.warned = true
+		fmt.Fprintf(os.Stderr, "warning: %v; this conversation will not be resumable\n", err)
+	}
+}
@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agent/internal/agent"
+	"agent/internal/replay"
+	"agent/internal/sessions"
+	"agent/internal/tools"
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/spf13/cobra"
+)
+
+// dateLayout is how --since and --until dates and listed times are written
+const dateLayout = "2006-01-02"
+
+func newSessionsCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List, show, delete and resume saved conversations",
+		Long: `Every chat is saved to ~/.config/billdozer/sessions as it goes, titled after its
+first prompt, unless sessions.save is false. Sessions are named by ID, and any
+unique prefix of an ID works. "replay" walks through a tool call recording made
+with --record instead.`,
+	}
+	cmd.AddCommand(
+		newSessionsListCommand(),
+		newSessionsShowCommand(),
+		newSessionsRemoveCommand(),
+		newSessionsResumeCommand(opts),
+		newReplayCommand(opts, "replay"),
+	)
+	return cmd
+}
+
+// openSessionStore returns the session store or an error when there is none
+func openSessionStore() (*sessions.Store, error) {
+	store := sessionStore()
+	if store == nil {
+		return nil, errors.New("no config directory to keep sessions in")
+	}
+	return store, nil
+}
+
+func newSessionsListCommand() *cobra.Command {
+	var dir, since, until string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions, most recent first",
+		Example: `  billdozer sessions list --dir .
+  billdozer sessions list --since 2024-05-01 --until 2024-06-01
+  billdozer sessions list --since 48h`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openSessionStore()
+			if err != nil {
+				return err
+			}
+			filter := sessions.Filter{}
+			if dir != "" {
+				if filter.Dir, err = filepath.Abs(dir); err != nil {
+					return err
+				}
+			}
+			if filter.Since, err = parseSince(since); err != nil {
+				return fmt.Errorf("--since: %w", err)
+			}
+			if filter.Until, err = parseSince(until); err != nil {
+				return fmt.Errorf("--until: %w", err)
+			}
+
+			found, err := store.List(filter)
+			if err != nil {
+				return err
+			}
+			if len(found) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No saved sessions")
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tUPDATED\tPROMPTS\tDIR\tTITLE")
+			for _, s := range found {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.ID, s.Updated.Local().Format(dateLayout+" 15:04"), s.Prompts(), s.Dir, s.Title)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", "", "only sessions whose workspace is this directory")
+	cmd.Flags().StringVar(&since, "since", "", "only sessions updated on or after a date (YYYY-MM-DD) or within a duration (e.g. 48h, 7d)")
+	cmd.Flags().StringVar(&until, "until", "", "only sessions last updated before a date (YYYY-MM-DD) or duration ago")
+	cmd.MarkFlagDirname("dir")
+	cmd.RegisterFlagCompletionFunc("since", completeSince)
+	cmd.RegisterFlagCompletionFunc("until", completeSince)
+	return cmd
+}
+
+// parseSince reads a date in local time, or a duration before now such as
+// 90m, 48h or 7d. Empty means no bound.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+		return date, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a YYYY-MM-DD date nor a duration like 48h or 7d", value)
+}
+
+func newSessionsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "show <id>",
+		Short:             "Print a saved session's details and conversation",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSessionID,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openSessionStore()
+			if err != nil {
+				return err
+			}
+			s, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "ID:      %s\nTitle:   %s\nDir:     %s\nModel:   %s\nCreated: %s\nUpdated: %s\n",
+				s.ID, s.Title, s.Dir, s.Model, s.Created.Local().Format(time.DateTime), s.Updated.Local().Format(time.DateTime))
+			for i, run := range s.Runs {
+				fmt.Fprintf(out, "\nRun %d, %s:\n", i+1, run.Started.Local().Format(time.DateTime))
+				run.Write(out)
+			}
+			labels := agent.DefaultLabels
+			if cfg, err := loadConfig(&options{}); err == nil {
+				labels = transcriptLabels(cfg.Transcript)
+			}
+			printConversation(out, s.Messages, labels)
+			return nil
+		},
+	}
+}
+
+// printConversation prints the text of each message, with tool calls and
+// results summarized on one line each
+func printConversation(out io.Writer, messages []anthropic.MessageParam, labels agent.Labels) {
+	for _, message := range messages {
+		speaker := labels.User
+		if message.Role == anthropic.MessageParamRoleAssistant {
+			speaker = labels.Assistant
+		}
+		for _, block := range message.Content {
+			switch {
+			case block.OfText != nil:
+				fmt.Fprintf(out, "\n%s: %s\n", speaker, block.OfText.Text)
+			case block.OfToolUse != nil:
+				input, _ := json.Marshal(block.OfToolUse.Input)
+				fmt.Fprintf(out, "\n%s: %s %s\n", labels.Tool, block.OfToolUse.Name, tools.SummarizeInput(input))
+			case block.OfToolResult != nil:
+				fmt.Fprintf(out, "  %s\n", summarizeToolResult(block.OfToolResult))
+			}
+		}
+	}
+}
+
+// summarizeToolResult describes a tool result by its first line and size
+func summarizeToolResult(result *anthropic.ToolResultBlockParam) string {
+	var text strings.Builder
+	for _, content := range result.Content {
+		if content.OfText != nil {
+			text.WriteString(content.OfText.Text)
+		}
+	}
+	label := "result"
+	if result.IsError.Value {
+		label = "error"
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(text.String()), "\n")
+	lines := strings.Count(strings.TrimSpace(text.String()), "\n") + 1
+	if lines > 1 {
+		return fmt.Sprintf("%s: %s (%d lines)", label, first, lines)
+	}
+	return fmt.Sprintf("%s: %s", label, first)
+}
+
+func newSessionsRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rm <id>...",
+		Aliases:           []string{"delete"},
+		Short:             "Delete saved sessions",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeSessionIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openSessionStore()
+			if err != nil {
+				return err
+			}
+			for _, id := range args {
+				removed, err := store.Remove(id)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s\n", removed)
+			}
+			return nil
+		},
+	}
+}
+
+func newSessionsResumeCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume <id>",
+		Short: "Continue a saved conversation where it left off",
+		Long: `Start a chat with the messages of a saved session, so Claude has the earlier
+conversation as context. New messages are saved to the same session. The chat
+uses the current directory's config and workspace; a note is printed when the
+session ran in another directory.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSessionID,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.resume = args[0]
+			return runChat(cmd, opts)
+		},
+	}
+	addChatFlags(cmd, opts)
+	return cmd
+}
+
+// newReplayAlias keeps "billdozer replay" working from before subcommands existed
+func newReplayAlias(opts *options) *cobra.Command {
+	alias := newReplayCommand(opts, "replay")
+	alias.Hidden = true
+	return alias
+}
+
+func newReplayCommand(opts *options, use string) *cobra.Command {
+	var execute bool
+	cmd := &cobra.Command{
+		Use:   use + " <session-file>",
+		Short: "Print or re-run the tool calls of a recorded session",
+		Long: `Walk through the tool calls recorded with --record. By default each call is
+printed with its recorded result. With --execute every call is run again through
+the current tools (confirmations and permissions still apply) and compared with
+the recording; the command fails if any result differs.`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"jsonl"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := replay.Load(args[0])
+			if err != nil {
+				return err
+			}
+
+			s, err := newSession(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			summary, err := replay.Run(cmd.Context(), entries, s.registry, s.toolContext(), replay.Options{Execute: execute}, os.Stdout)
+			if err != nil {
+				return err
+			}
+			if summary.Differed > 0 {
+				return fmt.Errorf("%d of %d replayed calls differed from the recording", summary.Differed, summary.Calls)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&execute, "execute", false, "re-run each recorded call and compare the result with the recording")
+	return cmd
+}
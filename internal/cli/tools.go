@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"agent/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+func newToolsCommand(opts *options) *cobra.Command {
+	toolsCmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Inspect the tools offered to the model",
+	}
+
+	toolsCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List every registered tool with its group and whether it is available",
+		Long: `List built-in, plugin and MCP tools. STATUS shows whether the tool is offered to
+the model with the current config, profile and --read-only setting.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := newSession(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tGROUP\tSTATUS\tFLAGS\tDESCRIPTION")
+			for _, tool := range s.registry.All() {
+				status := "available"
+				if _, err := s.registry.Resolve(tool.Name); err != nil {
+					status = "disabled"
+					if tool.Mutating && s.registry.ReadOnly() {
+						status = "read-only"
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tool.Name, tool.Group, status, toolFlags(tool), firstLine(tool.Description))
+			}
+			return w.Flush()
+		},
+	})
+	return toolsCmd
+}
+
+// toolFlags summarizes a tool's behavior flags
+func toolFlags(tool tools.ToolDefinition) string {
+	var flags []string
+	if tool.Mutating {
+		flags = append(flags, "mutating")
+	}
+	if tool.Confirms {
+		flags = append(flags, "confirms")
+	}
+	if tool.Cacheable {
+		flags = append(flags, "cacheable")
+	}
+	if tool.Diffable {
+		flags = append(flags, "diffable")
+	}
+	if len(flags) == 0 {
+		return "-"
+	}
+	return strings.Join(flags, ",")
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
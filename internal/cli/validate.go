@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agent/internal/auth"
+	"agent/internal/config"
+	"agent/internal/logging"
+	"agent/internal/models"
+	"agent/internal/network"
+	"agent/internal/permissions"
+	"agent/internal/prompt"
+	"agent/internal/provider"
+	"agent/internal/redact"
+	"agent/internal/remote"
+	"agent/internal/theme"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/spf13/cobra"
+)
+
+// credentialCheckTimeout bounds the API call that tests the credentials
+const credentialCheckTimeout = 15 * time.Second
+
+// Check outcomes, in the order they are reported
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// validation collects the outcome of each check for the report
+type validation struct {
+	lines  [][3]string
+	failed int
+}
+
+func (v *validation) add(status, name, detail string) {
+	if status == checkFail {
+		v.failed++
+	}
+	v.lines = append(v.lines, [3]string{status, name, detail})
+}
+
+// check records PASS with detail when err is nil and FAIL with the error otherwise
+func (v *validation) check(name string, err error, detail string) bool {
+	if err != nil {
+		detail := strings.ReplaceAll(err.Error(), ":\n", ": ")
+		v.add(checkFail, name, strings.ReplaceAll(detail, "\n", "; "))
+		return false
+	}
+	v.add(checkPass, name, detail)
+	return true
+}
+
+func newValidateCommand(opts *options) *cobra.Command {
+	var offline bool
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config, commands, referenced files and credentials",
+		Long: `Check every config file for unknown keys, load and validate the merged config and
+commands, verify that referenced files and executables exist, and test the API key
+with a lightweight request that looks up the configured model. Prints a PASS/FAIL
+report and exits non-zero when any check fails. --offline skips the API request.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := &validation{}
+			runValidation(cmd.Context(), v, opts, offline)
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			for _, line := range v.lines {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", line[0], line[1], line[2])
+			}
+			w.Flush()
+
+			if v.failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", v.failed, len(v.lines))
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "\nAll %d checks passed\n", len(v.lines))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&offline, "offline", false, "skip the API request that tests the credentials")
+	return cmd
+}
+
+// runValidation runs every check. Checks that depend on the config are
+// skipped when it cannot be loaded.
+func runValidation(ctx context.Context, v *validation, opts *options, offline bool) {
+	for _, path := range config.WatchedFiles(".") {
+		if _, err := os.Stat(path); err != nil || filepath.Base(path) == config.DefaultCommandsPath || filepath.Base(path) == "commands.yml" {
+			continue
+		}
+		v.check("keys", config.CheckKeys(path), path)
+	}
+
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		v.check("config", err, "")
+		v.add(checkSkip, "remaining checks", "the config must load first")
+		return
+	}
+	v.add(checkPass, "config", "loaded from "+orNone(strings.Join(cfg.Sources, ", ")))
+	v.check("provider", cfg.ValidateProvider(), cfg.ProviderOrDefault())
+	v.add(checkPass, "model", modelDetail(cfg))
+
+	root := opts.workspaceRoot
+	if remote.IsTarget(root) {
+		// Connecting is left to sessions; project config is read locally
+		_, err := remote.ParseTarget(root)
+		v.check("workspace", err, root+" (not connected)")
+		root = "."
+	} else if ws, err := workspace.New(opts.workspaceRoot, opts.allowOutside); v.check("workspace", err, rootOf(ws)) {
+		root = ws.Root()
+	}
+
+	if commands, err := config.DiscoverCommandsConfig(root); v.check("commands", err, commandsDetail(commands)) {
+		checkCommandPaths(v, root, commands)
+	}
+
+	systemPrompt, err := cfg.ReadSystemPrompt()
+	if v.check("system_prompt", err, orNone(cfg.SystemPrompt)) && systemPrompt != "" {
+		_, err := prompt.Render(filepath.Base(cfg.SystemPrompt), systemPrompt, prompt.NewData(root, cfg.ModelOrDefault(), tools.DefaultRegistry))
+		v.check("system_prompt template", err, "renders")
+	}
+
+	_, err = permissions.NewPolicy(cfg.Permissions)
+	v.check("permissions", err, fmt.Sprintf("%d rules", len(cfg.Permissions.Rules)))
+	_, err = permissions.NewPathRules(cfg.Paths)
+	v.check("paths", err, fmt.Sprintf("%d allow, %d deny", len(cfg.Paths.Allow), len(cfg.Paths.Deny)))
+	_, err = redact.New(cfg.Redaction.IsEnabled(), cfg.Redaction.Patterns)
+	v.check("redaction", err, fmt.Sprintf("%d custom patterns", len(cfg.Redaction.Patterns)))
+
+	_, err = theme.New(cfg.Theme.Scheme, cfg.Theme.Colors, cfg.Theme.Color)
+	v.check("theme", err, orNone(cfg.Theme.Scheme))
+	_, err = models.New(cfg.ModelInfo)
+	v.check("model_info", err, fmt.Sprintf("%d entries", len(cfg.ModelInfo)))
+
+	// The file is left out so validating does not create it
+	_, _, err = logging.Open(logging.Options{Level: cfg.Logging.Level, Format: cfg.Logging.Format, Components: cfg.Logging.Components})
+	v.check("logging", err, loggingDetail(cfg.Logging))
+	v.check("server", cfg.ValidateServer(), serverDetail(cfg.Server))
+	v.check("watch", cfg.ValidateWatch(), fmt.Sprintf("%d tasks", len(cfg.Watch.Tasks)))
+	v.check("databases", cfg.ValidateDatabases(), fmt.Sprintf("%d databases", len(cfg.Databases)))
+	v.check("protobuf", cfg.ValidateProtobuf(), fmt.Sprintf("%d generators", len(cfg.Protobuf.Generate)))
+	v.check("benchmarks", cfg.ValidateBenchmarks(), strings.Join(cfg.Benchmarks.CommandLine(), " "))
+	v.check("licenses", cfg.ValidateLicenses(), fmt.Sprintf("%d allowed, %d denied", len(cfg.Licenses.Allow), len(cfg.Licenses.Deny)))
+
+	checkTools(v, cfg)
+
+	httpClient, err := network.NewClient(cfg.Network)
+	v.check("network", err, networkDetail(cfg.Network))
+
+	for _, plugin := range cfg.Plugins {
+		checkExecutable(v, "plugin "+plugin.Name, plugin.Command, "")
+	}
+	for _, server := range cfg.MCPServers {
+		if len(server.Command) > 0 {
+			checkExecutable(v, "mcp_server "+server.Name, server.Command, "")
+		}
+	}
+
+	if !provider.NeedsKey(cfg) {
+		v.add(checkSkip, "api key", "azure.auth is azure-ad")
+		return
+	}
+	credential, err := auth.Resolve(cfg)
+	if !v.check("api key", err, sourceOf(credential)) {
+		return
+	}
+	if offline || httpClient == nil {
+		v.add(checkSkip, "api request", "--offline")
+		return
+	}
+	if cfg.ProviderOrDefault() != config.DefaultProvider {
+		v.add(checkSkip, "api request", "only checked for "+config.DefaultProvider)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, credentialCheckTimeout)
+	defer cancel()
+	client := anthropic.NewClient(append(anthropicOptions(cfg.Anthropic), option.WithAPIKey(credential.Key), option.WithHTTPClient(httpClient))...)
+	info, err := client.Models.Get(ctx, cfg.ModelOrDefault(), anthropic.ModelGetParams{})
+	if err == nil {
+		v.add(checkPass, "api request", fmt.Sprintf("key accepted; %s is available", info.ID))
+		return
+	}
+	v.check("api request", fmt.Errorf("looking up model %s: %w", cfg.ModelOrDefault(), err), "")
+}
+
+// checkCommandPaths verifies command working directories and executables
+func checkCommandPaths(v *validation, root string, commands *config.CommandsConfig) {
+	for _, name := range slices.Sorted(maps.Keys(commands.Commands)) {
+		spec := commands.Commands[name]
+		base := spec.Dir
+		if base == "" {
+			base = root
+		}
+		if spec.Workdir != "" && !strings.Contains(spec.Workdir, "$") {
+			workdir := spec.Workdir
+			if !filepath.IsAbs(workdir) {
+				workdir = filepath.Join(base, workdir)
+			}
+			if info, err := os.Stat(workdir); err != nil || !info.IsDir() {
+				v.add(checkFail, "command "+name, "workdir "+workdir+" does not exist")
+				continue
+			}
+		}
+		if !spec.Shell {
+			checkExecutable(v, "command "+name, strings.Fields(spec.Command), base)
+		}
+	}
+}
+
+// checkExecutable warns when the program of argv cannot be found. Paths
+// containing a separator are resolved against dir; templated or
+// variable-based programs are not checked.
+func checkExecutable(v *validation, name string, argv []string, dir string) {
+	if len(argv) == 0 || strings.ContainsAny(argv[0], "{$") {
+		return
+	}
+	program := argv[0]
+	if strings.ContainsAny(program, `/\`) {
+		if !filepath.IsAbs(program) && dir != "" {
+			program = filepath.Join(dir, program)
+		}
+		if _, err := os.Stat(program); err != nil {
+			v.add(checkWarn, name, program+" does not exist")
+		}
+		return
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		v.add(checkWarn, name, program+" is not on PATH")
+	}
+}
+
+// checkTools validates tool enable lists and description edits against the
+// built-in tools. Plugin and MCP tools are only known once they start, so
+// names that may belong to them are reported as warnings.
+func checkTools(v *validation, cfg *config.Config) {
+	err := tools.DefaultRegistry.SetEnabled(cfg.Tools.Enabled, cfg.Tools.Disabled)
+	if err == nil {
+		for name := range cfg.Tools.Descriptions {
+			if tools.DefaultRegistry.GetByName(name) == nil {
+				err = fmt.Errorf("descriptions: unknown tool %q", name)
+				break
+			}
+		}
+	}
+	switch {
+	case err == nil:
+		v.add(checkPass, "tools", fmt.Sprintf("%d enabled entries, %d disabled, %d description edits", len(cfg.Tools.Enabled), len(cfg.Tools.Disabled), len(cfg.Tools.Descriptions)))
+	case len(cfg.Plugins) > 0 || len(cfg.MCPServers) > 0:
+		v.add(checkWarn, "tools", err.Error()+" (it may be provided by a plugin or MCP server)")
+	default:
+		v.add(checkFail, "tools", err.Error())
+	}
+}
+
+func modelDetail(cfg *config.Config) string {
+	model := cfg.ModelOrDefault()
+	if cfg.Model != "" && cfg.Model != model {
+		return cfg.Model + " -> " + model
+	}
+	return model
+}
+
+func commandsDetail(commands *config.CommandsConfig) string {
+	if commands == nil {
+		return ""
+	}
+	sources := append(append([]string{}, commands.Sources...), commands.Detected...)
+	if len(sources) == 0 {
+		return fmt.Sprintf("%d commands", len(commands.Commands))
+	}
+	return fmt.Sprintf("%d commands, %d groups from %s", len(commands.Commands), len(commands.Groups), strings.Join(sources, ", "))
+}
+
+func loggingDetail(cfg config.LoggingConfig) string {
+	level, format := cmp.Or(cfg.Level, "warn"), cmp.Or(cfg.Format, logging.FormatText)
+	detail := fmt.Sprintf("%s %s to %s", level, format, cmp.Or(cfg.File, "stderr"))
+	if len(cfg.Components) > 0 {
+		detail += fmt.Sprintf(", %d component levels", len(cfg.Components))
+	}
+	return detail
+}
+
+func serverDetail(cfg config.ServerConfig) string {
+	detail := cmp.Or(cfg.Listen, config.DefaultServerListen)
+	if cfg.TLSCert != "" {
+		detail += " over TLS"
+	}
+	if len(cfg.Principals) == 0 {
+		return detail + ", unauthenticated"
+	}
+	detail += fmt.Sprintf(", %d principals", len(cfg.Principals))
+	if cfg.OIDC.Issuer != "" {
+		detail += ", OIDC from " + cfg.OIDC.Issuer
+	}
+	return detail
+}
+
+func networkDetail(cfg config.NetworkConfig) string {
+	var parts []string
+	if cfg.Proxy != "" {
+		parts = append(parts, "proxy set")
+	}
+	if cfg.CABundle != "" {
+		parts = append(parts, "CA bundle "+cfg.CABundle)
+	}
+	if len(parts) == 0 {
+		return "direct (proxy environment variables apply)"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func rootOf(ws *workspace.Workspace) string {
+	if ws == nil {
+		return ""
+	}
+	return ws.Root()
+}
+
+func sourceOf(credential *auth.Credential) string {
+	if credential == nil {
+		return ""
+	}
+	return "from the " + credential.Source
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -0,0 +1,296 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"agent/internal/config"
+	"agent/internal/theme"
+	"agent/internal/tools"
+	"agent/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+// maxListedChanges caps the changed files named in notices; prompts list them all
+const maxListedChanges = 5
+
+// watchPrompt is a triggered task's message for the agent; done is closed
+// once the agent has finished with it
+type watchPrompt struct {
+	text string
+	done chan struct{}
+}
+
+// watcher runs the tasks of "billdozer watch" in a session. Prompts go to
+// one agent, which keeps its conversation between them; commands run
+// through the execute_command tool, with the usual confirmations.
+type watcher struct {
+	s       *session
+	ctx     context.Context
+	prompts chan watchPrompt
+	current chan struct{} // done of the prompt the agent is working on; used by the agent's goroutine only
+
+	mutex sync.Mutex
+	own   map[string]string // Stamps of the files the agent changed, by path relative to the root
+}
+
+func newWatchCommand(opts *options) *cobra.Command {
+	var only []string
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Run configured prompts or commands whenever matching files change",
+		Long: `Watch the workspace and run the tasks configured under watch.tasks when files
+matching their paths change. A task runs a prompt, a command or command group from
+the commands config, or both: then the prompt only runs when the command fails, and
+is given its output. Prompts go to one agent, which remembers earlier runs.
+
+Changes are collected until none has been seen for watch.debounce_ms, and at most
+watch.max_concurrent tasks run at once. Changes made while a task runs trigger it
+once more when it finishes. Files the agent changes do not trigger tasks, nor do
+paths in .billdozerignore or .git. Interrupt to stop watching.`,
+		Example: `  billdozer watch
+  billdozer watch --task test --auto-approve`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			if err := requireLocalWorkspace(opts, "watch"); err != nil {
+				return err
+			}
+			cfg, err := loadConfig(opts)
+			if err != nil {
+				return err
+			}
+			if err := cfg.ValidateWatch(); err != nil {
+				return fmt.Errorf("invalid watch config:\n%w", err)
+			}
+			tasks := cfg.Watch.Tasks
+			for _, name := range only {
+				if !slices.ContainsFunc(tasks, func(task config.WatchTask) bool { return task.Name == name }) {
+					return fmt.Errorf("unknown watch task %q", name)
+				}
+			}
+			if len(only) > 0 {
+				tasks = slices.DeleteFunc(slices.Clone(tasks), func(task config.WatchTask) bool { return !slices.Contains(only, task.Name) })
+			}
+			if len(tasks) == 0 {
+				return errors.New("no watch tasks are configured; add them under watch.tasks in billdozer.yml")
+			}
+
+			s, err := openSession(ctx, opts, cfg)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+			return runWatch(ctx, s, tasks)
+		},
+	}
+	cmd.Flags().StringSliceVar(&only, "task", nil, "run only these watch tasks (repeatable)")
+	return cmd
+}
+
+// runWatch runs tasks as files change until ctx is done or the agent ends
+func runWatch(ctx context.Context, s *session, tasks []config.WatchTask) error {
+	if err := checkWatchCommands(s.workspace.Root(), tasks); err != nil {
+		return err
+	}
+	w := &watcher{s: s, ctx: ctx, prompts: make(chan watchPrompt), own: map[string]string{}}
+	s.registry.Use(w.recordChanges)
+
+	// Tasks that only run commands need no agent, nor an API key
+	agentDone := make(chan error, 1)
+	if slices.ContainsFunc(tasks, func(task config.WatchTask) bool { return task.Prompt != "" }) {
+		agent, err := s.newAgent(w.nextPrompt)
+		if err != nil {
+			return err
+		}
+		go func() { agentDone <- agent.Run(ctx) }()
+	}
+
+	root := s.workspace.Root()
+	changes := make(chan []string)
+	files := watch.New(root, func(rel string, isDir bool) bool {
+		return s.workspace.Ignored(filepath.Join(root, filepath.FromSlash(rel)), isDir)
+	})
+	go files.Run(ctx, s.cfg.Watch.DebounceOrDefault(), changes)
+	scheduler := watch.NewScheduler(tasks, s.cfg.Watch.MaxConcurrentOrDefault(), w.runTask)
+	defer scheduler.Wait()
+
+	names := make([]string, len(tasks))
+	for i, task := range tasks {
+		names[i] = task.Name
+	}
+	fmt.Printf("Watching %s for %s; interrupt to stop\n", theme.Paint(theme.Path, root), strings.Join(names, ", "))
+
+	for {
+		select {
+		case batch := <-changes:
+			if batch = w.withoutOwnChanges(batch); len(batch) > 0 {
+				s.logger.Debug("files changed", "paths", batch)
+				scheduler.Trigger(ctx, batch)
+			}
+		case err := <-agentDone:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// checkWatchCommands reports tasks whose command is neither a command nor
+// a group of the commands config
+func checkWatchCommands(root string, tasks []config.WatchTask) error {
+	commands, err := config.DiscoverCommandsConfig(root)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.Command == "" {
+			continue
+		}
+		if _, ok := commands.Commands[task.Command]; ok {
+			continue
+		}
+		if _, ok := commands.Groups[task.Command]; !ok {
+			return fmt.Errorf("watch task %q: unknown command %q", task.Name, task.Command)
+		}
+	}
+	return nil
+}
+
+// runTask runs a triggered task: its command, then its prompt when there
+// is no command or the command failed
+func (w *watcher) runTask(ctx context.Context, task config.WatchTask, changed []string) {
+	w.notice(task.Name, "triggered by changes to "+listChanges(changed))
+	var failure string
+	if task.Command != "" {
+		output, passed, err := w.runCommand(ctx, task.Command)
+		switch {
+		case err != nil:
+			w.notice(task.Name, fmt.Sprintf("the %s command could not run: %v", task.Command, err))
+			return
+		case passed:
+			w.notice(task.Name, fmt.Sprintf("the %s command passed", task.Command))
+			return
+		}
+		w.notice(task.Name, fmt.Sprintf("the %s command failed", task.Command))
+		if task.Prompt == "" {
+			return
+		}
+		failure = fmt.Sprintf("\n\nThe %q command failed:\n\n```\n%s\n```", task.Command, strings.TrimSpace(output))
+	}
+
+	text := fmt.Sprintf("[watch task %q] These files changed: %s\n\n%s%s", task.Name, strings.Join(changed, ", "), task.Prompt, failure)
+	prompt := watchPrompt{text: text, done: make(chan struct{})}
+	select {
+	case w.prompts <- prompt:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-prompt.done:
+		w.notice(task.Name, "done")
+	case <-ctx.Done():
+	}
+}
+
+// runCommand runs a command or group through execute_command, streaming
+// its output unless the session is quiet
+func (w *watcher) runCommand(ctx context.Context, name string) (output string, passed bool, err error) {
+	tool, err := w.s.registry.Resolve("execute_command")
+	if err != nil {
+		return "", false, err
+	}
+	toolCtx := w.s.toolContext()
+	if !w.s.opts.quiet {
+		toolCtx.Output = os.Stdout
+	}
+	input, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return "", false, err
+	}
+	result, err := tool.Function(ctx, toolCtx, input)
+	if err != nil {
+		return "", false, err
+	}
+	return result.Text(), !result.IsError, nil
+}
+
+// nextPrompt hands the agent the next triggered prompt, marking the
+// previous one done. It ends the conversation when watching stops.
+func (w *watcher) nextPrompt() (string, bool) {
+	if w.current != nil {
+		close(w.current)
+		w.current = nil
+	}
+	select {
+	case prompt := <-w.prompts:
+		w.current = prompt.done
+		// The agent printed the "You" label; show what it is answering
+		fmt.Println(firstLine(prompt.text))
+		return prompt.text, true
+	case <-w.ctx.Done():
+		return "", false
+	}
+}
+
+// recordChanges remembers the files the agent changes, so its own edits do
+// not trigger tasks
+func (w *watcher) recordChanges(next tools.ToolFunc) tools.ToolFunc {
+	return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+		result, err := next(ctx, toolCtx, input)
+		if err != nil || result == nil {
+			return result, err
+		}
+		root := w.s.workspace.Root()
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		for _, path := range result.Metadata.FilesChanged {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(root, path)
+			}
+			rel, err := filepath.Rel(root, filepath.Clean(path))
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			w.own[filepath.ToSlash(rel)] = fileStamp(path)
+		}
+		return result, nil
+	}
+}
+
+// withoutOwnChanges drops the files that are as the agent left them
+func (w *watcher) withoutOwnChanges(batch []string) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	root := w.s.workspace.Root()
+	return slices.DeleteFunc(batch, func(rel string) bool {
+		stamp, ok := w.own[rel]
+		if !ok {
+			return false
+		}
+		delete(w.own, rel)
+		return stamp == fileStamp(filepath.Join(root, filepath.FromSlash(rel)))
+	})
+}
+
+func (w *watcher) notice(task, message string) {
+	fmt.Printf("\n%s %s: %s\n", theme.Paint(theme.Notice, "watch"), task, message)
+}
+
+// listChanges names the first few changed files
+func listChanges(changed []string) string {
+	if len(changed) <= maxListedChanges {
+		return strings.Join(changed, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(changed[:maxListedChanges], ", "), len(changed)-maxListedChanges)
+}
@@ -1,31 +1,101 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
-	"gopkg.in/yaml.v3"
+	"io"
 	"os"
+
+	"gopkg.in/yaml.v3"
 )
 
 type CommandsConfig struct {
-	Commands map[string]CommandSpec `yaml:"commands"`
+	Commands       map[string]CommandSpec `yaml:"commands"`
+	Groups         map[string][]string    `yaml:"groups"`           // Named sets of commands run in parallel, e.g. check: [lint, test]
+	MaxOutputBytes int                    `yaml:"max_output_bytes"` // Default cap on output returned to the model
+	Sources        []string               `yaml:"-"`                // Files the commands were loaded from
+	Detected       []string               `yaml:"-"`                // Build files default commands were detected from
 }
 
 type CommandSpec struct {
-	Command        string `yaml:"command"`
-	Description    string `yaml:"description"`
-	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	Command        string             `yaml:"command"`
+	Description    string             `yaml:"description"`
+	TimeoutSeconds int                `yaml:"timeout_seconds"`
+	Parameters     []CommandParameter `yaml:"parameters"`
+	Workdir        string             `yaml:"workdir"`          // Relative to Dir (or the workspace root); $VARS are expanded
+	Env            map[string]string  `yaml:"env"`              // Added to the inherited environment; $VARS are expanded
+	MaxOutputBytes int                `yaml:"max_output_bytes"` // Overrides the file-level cap for this command
+	Shell          bool               `yaml:"shell"`            // Run through sh -c (cmd /C on Windows) for pipes, && and redirects
+	ShellProgram   string             `yaml:"shell_program"`    // The shell for shell: true instead: sh, bash, zsh, cmd, powershell or pwsh
+	Background     bool               `yaml:"background"`       // Start without waiting and return a handle for the status/output/stop tools
+	Confirm        bool               `yaml:"confirm"`          // Always ask before running, even when auto-approved or allowed by policy
+	Danger         string             `yaml:"danger"`           // low, medium or high; high implies confirm
+	Dir            string             `yaml:"-"`                // Directory of the project file that defined the command
+}
+
+// RequiresConfirmation reports whether the command must be confirmed by the user on every run
+func (s CommandSpec) RequiresConfirmation() bool {
+	return s.Confirm || s.Danger == "high"
+}
+
+// CommandParameter declares a typed {{placeholder}} in a command template
+type CommandParameter struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type"` // string (default), integer, number or boolean
+	Description string   `yaml:"description"`
+	Required    bool     `yaml:"required"`
+	Default     string   `yaml:"default"`
+	Enum        []string `yaml:"enum"`
+	Pattern     string   `yaml:"pattern"` // Regular expression the whole value must match
+	Flag        string   `yaml:"flag"`    // For booleans: argument inserted when true, e.g. "-v"
 }
 
+// LoadCommandsConfig reads and validates a commands file. Syntax errors,
+// unknown keys, duplicate commands and invalid definitions are all reported
+// together, each prefixed with the file and line it was found at.
 func LoadCommandsConfig(path string) (*CommandsConfig, error) {
+	return loadCommandsFile(path, false)
+}
+
+// loadCommandsFile reads a commands file. With embedded set, the file is a
+// config file and only its commands, groups and max_output_bytes sections
+// are read; other top-level keys are left to the config loader.
+func loadCommandsFile(path string, embedded bool) (*CommandsConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", describeYAMLError(path, err))
+	}
+
 	var config CommandsConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	var target any = &config
+	if embedded {
+		target = &struct {
+			*CommandsConfig `yaml:",inline"`
+			Other           map[string]yaml.Node `yaml:",inline"`
+		}{CommandsConfig: &config}
+	}
+	// Check for unknown keys against the file as written, so line numbers match
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := unknownKeys(decoder.Decode(target)); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse config: %w", describeYAMLError(path, err))
 	}
 
+	if err := expandNode(path, &root); err != nil {
+		return nil, fmt.Errorf("failed to resolve config values:\n%w", err)
+	}
+	config = CommandsConfig{}
+	if err := root.Decode(target); err != nil && root.Kind != 0 {
+		return nil, fmt.Errorf("failed to parse config: %w", describeYAMLError(path, err))
+	}
+
+	if err := validateCommands(path, &root, &config); err != nil {
+		return nil, fmt.Errorf("invalid command configuration:\n%w", err)
+	}
 	return &config, nil
 }
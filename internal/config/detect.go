@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// detectedTimeoutSeconds bounds auto-detected commands, which have no configured timeout
+const detectedTimeoutSeconds = 600
+
+// detectedNames are the only commands synthesized from build files. They
+// are expected to be safe to run at any time; anything else (deploys,
+// formatters that rewrite files) has to be configured explicitly.
+var detectedNames = []string{"build", "test", "lint"}
+
+// projectMarkers are the build files commands are detected from, in priority order
+var projectMarkers = []string{"Makefile", "package.json", "go.mod", "Cargo.toml"}
+
+// makeTargetPattern matches a rule line such as "test: build" but not "VAR := value"
+var makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*:([^=]|$)`)
+
+// DetectCommands synthesizes build, test and lint commands for the nearest
+// project directory at or above dir, from Makefile targets, package.json
+// scripts, go.mod or Cargo.toml. When several build files provide the same
+// command, the earlier one in that list wins. It returns the commands and
+// the build files they came from.
+func DetectCommands(dir string) (map[string]CommandSpec, []string) {
+	marker := FindUp(dir, projectMarkers...)
+	if marker == "" {
+		return map[string]CommandSpec{}, nil
+	}
+	projectDir := filepath.Dir(marker)
+
+	commands := map[string]CommandSpec{}
+	var sources []string
+	for _, name := range projectMarkers {
+		path := filepath.Join(projectDir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		var detected map[string]string
+		switch name {
+		case "Makefile":
+			detected = makeCommands(path)
+		case "package.json":
+			detected = packageCommands(projectDir, path)
+		case "go.mod":
+			detected = map[string]string{"build": "go build ./...", "test": "go test ./...", "lint": "go vet ./..."}
+		case "Cargo.toml":
+			detected = map[string]string{"build": "cargo build", "test": "cargo test", "lint": "cargo clippy --all-targets"}
+		}
+
+		used := false
+		for _, command := range detectedNames {
+			line, ok := detected[command]
+			if _, taken := commands[command]; !ok || taken {
+				continue
+			}
+			commands[command] = CommandSpec{
+				Command:        line,
+				Description:    line + " (auto-detected from " + name + ")",
+				TimeoutSeconds: detectedTimeoutSeconds,
+				Dir:            projectDir,
+			}
+			used = true
+		}
+		if used {
+			sources = append(sources, path)
+		}
+	}
+	return commands, sources
+}
+
+// makeCommands returns "make <target>" for each detected name the Makefile defines
+func makeCommands(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	commands := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		match := makeTargetPattern.FindStringSubmatch(scanner.Text())
+		if match != nil && contains(detectedNames, match[1]) {
+			commands[match[1]] = "make " + match[1]
+		}
+	}
+	return commands
+}
+
+// packageCommands returns a run command for each detected name package.json
+// has a script for, using the package manager whose lockfile is present
+func packageCommands(projectDir, path string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+
+	manager := "npm"
+	for lockfile, name := range map[string]string{"pnpm-lock.yaml": "pnpm", "yarn.lock": "yarn", "bun.lockb": "bun"} {
+		if _, err := os.Stat(filepath.Join(projectDir, lockfile)); err == nil {
+			manager = name
+		}
+	}
+
+	commands := map[string]string{}
+	for _, name := range detectedNames {
+		if _, ok := pkg.Scripts[name]; ok {
+			commands[name] = manager + " run " + name
+		}
+	}
+	return commands
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
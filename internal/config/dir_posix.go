@@ -0,0 +1,19 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// userConfigBase is where per-user settings live without XDG_CONFIG_HOME.
+// It is ~/.config on macOS too, rather than ~/Library/Application Support,
+// so dotfiles carry over between machines.
+func userConfigBase() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config"), nil
+}
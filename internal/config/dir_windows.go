@@ -0,0 +1,9 @@
+package config
+
+import "os"
+
+// userConfigBase is where per-user settings live without XDG_CONFIG_HOME:
+// %AppData%, the roaming profile directory
+func userConfigBase() (string, error) {
+	return os.UserConfigDir()
+}
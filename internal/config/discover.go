@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCommandsPath is the project-level command definitions file
+const DefaultCommandsPath = ".agent-commands.yml"
+
+// globalCommandsFile is the per-user command definitions file inside GlobalConfigDir
+const globalCommandsFile = "commands.yml"
+
+// FindUp looks for a file with one of the given names in dir and then in
+// each parent directory, stopping after the repository root (the first
+// directory that contains .git) or the filesystem root. Within a directory,
+// earlier names win. It returns "" when nothing is found.
+func FindUp(dir string, names ...string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range names {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// GlobalConfigDir returns the per-user settings directory:
+// $XDG_CONFIG_HOME/billdozer, falling back to ~/.config/billdozer, or
+// %AppData%\billdozer on Windows
+func GlobalConfigDir() string {
+	if base := os.Getenv("XDG_CONFIG_HOME"); base != "" {
+		return filepath.Join(base, "billdozer")
+	}
+	base, err := userConfigBase()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(base, "billdozer")
+}
+
+// commandsFile is one layer of command definitions
+type commandsFile struct {
+	path     string
+	embedded bool // A config file whose commands sections sit alongside other settings
+	project  bool // Commands run from the file's directory rather than the workspace root
+}
+
+// DiscoverCommandsConfig loads and merges command definitions from, in
+// increasing precedence: the global commands.yml, the commands sections of
+// the global config.yml, the project's billdozer.yml and the project's
+// .agent-commands.yml (both found from dir upwards). Later files replace
+// commands and groups with the same name, and their max_output_bytes
+// replaces earlier ones. Any file may be missing; when no project file
+// defines commands, commands detected from the project's build files are
+// used underneath the global ones.
+func DiscoverCommandsConfig(dir string) (*CommandsConfig, error) {
+	merged := &CommandsConfig{Commands: map[string]CommandSpec{}, Groups: map[string][]string{}}
+
+	var files []commandsFile
+	if globalDir := GlobalConfigDir(); globalDir != "" {
+		files = append(files,
+			commandsFile{path: filepath.Join(globalDir, globalCommandsFile)},
+			commandsFile{path: filepath.Join(globalDir, globalConfigFile), embedded: true})
+	}
+	if path := FindUp(dir, ProjectConfigFile); path != "" {
+		files = append(files, commandsFile{path: path, embedded: true, project: true})
+	}
+	if path := FindUp(dir, DefaultCommandsPath); path != "" {
+		files = append(files, commandsFile{path: path, project: true})
+	}
+
+	projectCommands := false
+	for _, file := range files {
+		loaded, err := loadCommandsFile(file.path, file.embedded)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(loaded.Commands) == 0 && len(loaded.Groups) == 0 && loaded.MaxOutputBytes == 0 {
+			continue
+		}
+		merged.Sources = append(merged.Sources, file.path)
+		if loaded.MaxOutputBytes != 0 {
+			merged.MaxOutputBytes = loaded.MaxOutputBytes
+		}
+		for name, spec := range loaded.Commands {
+			if file.project {
+				spec.Dir = filepath.Dir(file.path)
+				projectCommands = true
+			}
+			merged.Commands[name] = spec
+		}
+		for name, members := range loaded.Groups {
+			merged.Groups[name] = members
+		}
+	}
+
+	if !projectCommands {
+		detected, sources := DetectCommands(dir)
+		for name, spec := range detected {
+			if _, ok := merged.Commands[name]; !ok {
+				merged.Commands[name] = spec
+			}
+		}
+		merged.Detected = sources
+	}
+
+	if err := merged.CheckGroups(); err != nil {
+		return nil, fmt.Errorf("invalid command configuration:\n%w", err)
+	}
+	return merged, nil
+}
+
+// WatchedFiles returns every config file Load and DiscoverCommandsConfig
+// read for dir, including the project files that would be picked up if they
+// were created there, so a watcher can notice new files as well as edits
+func WatchedFiles(dir string) []string {
+	var paths []string
+	if globalDir := GlobalConfigDir(); globalDir != "" {
+		paths = append(paths, filepath.Join(globalDir, globalConfigFile), filepath.Join(globalDir, globalCommandsFile))
+	}
+	for _, names := range [][]string{{ProjectConfigFile, DefaultConfigPath}, {DefaultCommandsPath}} {
+		path := FindUp(dir, names...)
+		if path == "" {
+			path = filepath.Join(dir, names[0])
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"agent/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// variablePattern matches ${NAME} and ${NAME:-default}; $${...} is an escaped literal
+var variablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandNode resolves ${VAR} references and secret:// values in every
+// scalar value of a parsed document, in place. Keys are left alone. Unset
+// variables without a default and failed secret lookups are errors,
+// reported with the line they appear on.
+func expandNode(path string, node *yaml.Node) error {
+	var problems []error
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.ScalarNode:
+			value, err := expandValue(node.Value)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s:%d: %w", path, node.Line, err))
+				return
+			}
+			if value != node.Value {
+				// Let the expanded value resolve to its own type, so ${TIMEOUT} can fill an integer field
+				node.Value, node.Tag, node.Style = value, "", 0
+			}
+		}
+	}
+	walk(node)
+	return errors.Join(problems...)
+}
+
+// expandValue expands variables in one config value and then resolves it
+// if it is a secret reference
+func expandValue(value string) (string, error) {
+	if !strings.Contains(value, "${") && !secrets.IsRef(value) {
+		return value, nil
+	}
+
+	var missing []string
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		parts := variablePattern.FindStringSubmatch(match)
+		if current, ok := os.LookupEnv(parts[1]); ok && current != "" {
+			return current
+		}
+		if strings.Contains(match, ":-") {
+			return parts[2]
+		}
+		missing = append(missing, parts[1])
+		return match
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set (use ${%s:-default} to make it optional)", strings.Join(missing, ", "), missing[0])
+	}
+
+	if secrets.IsRef(expanded) {
+		return secrets.Resolve(expanded)
+	}
+	return expanded, nil
+}
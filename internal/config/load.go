@@ -0,0 +1,188 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the unified project config, found by searching parent directories
+const ProjectConfigFile = "billdozer.yml"
+
+// globalConfigFile is the per-user config inside GlobalConfigDir
+const globalConfigFile = "config.yml"
+
+// Default model settings used when no config or environment variable sets them
+const (
+	DefaultModel     = "claude-sonnet-4-20250514"
+	DefaultProvider  = "anthropic"
+	DefaultMaxTokens = 1024
+)
+
+// Load builds the effective config for a session started in dir. Layers
+// apply in increasing precedence: built-in defaults, the global config
+// ($XDG_CONFIG_HOME/billdozer/config.yml, ~/.config/billdozer/config.yml
+// when XDG_CONFIG_HOME is unset), the project's billdozer.yml (or the
+// older .agent-config.yml when there is no billdozer.yml) and finally
+// BILLDOZER_* environment variables. Profiles and CLI flags are applied
+// on top by the caller. Keys present in a later layer replace earlier
+// values; maps such as commands and profiles are merged. The origin of
+// every value is recorded for Settings.
+func Load(dir string) (*Config, error) {
+	var config Config
+
+	var paths []string
+	if globalDir := GlobalConfigDir(); globalDir != "" {
+		paths = append(paths, filepath.Join(globalDir, globalConfigFile))
+	}
+	if project := FindUp(dir, ProjectConfigFile, DefaultConfigPath); project != "" {
+		paths = append(paths, project)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", describeYAMLError(path, err))
+		}
+		if err := expandNode(path, &root); err != nil {
+			return nil, fmt.Errorf("failed to resolve config values:\n%w", err)
+		}
+
+		previousPrompt, previousBundle, previousLog := config.SystemPrompt, config.Network.CABundle, config.Logging.File
+		previousCert, previousKey := config.Server.TLSCert, config.Server.TLSKey
+		if err := root.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", describeYAMLError(path, err))
+		}
+		// Relative file paths belong to the file that set them
+		resolveRelative(&config.SystemPrompt, previousPrompt, path)
+		resolveRelative(&config.Network.CABundle, previousBundle, path)
+		resolveRelative(&config.Logging.File, previousLog, path)
+		resolveRelative(&config.Server.TLSCert, previousCert, path)
+		resolveRelative(&config.Server.TLSKey, previousKey, path)
+		config.recordFileOrigins(path, &root)
+		config.Sources = append(config.Sources, path)
+	}
+
+	if err := config.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// resolveRelative joins a path newly set by the config file at source to that file's directory
+func resolveRelative(value *string, previous, source string) {
+	if *value != previous && *value != "" && !filepath.IsAbs(*value) {
+		*value = filepath.Join(filepath.Dir(source), *value)
+	}
+}
+
+// ApplyEnv overrides settings from BILLDOZER_MODEL, BILLDOZER_PROVIDER,
+// BILLDOZER_SYSTEM_PROMPT, BILLDOZER_MAX_TOKENS and BILLDOZER_MAX_TURNS
+func (c *Config) ApplyEnv() error {
+	for _, setting := range []struct {
+		env, key string
+		target   *string
+	}{
+		{"BILLDOZER_MODEL", "model", &c.Model},
+		{"BILLDOZER_PROVIDER", "provider", &c.Provider},
+		{"BILLDOZER_SYSTEM_PROMPT", "system_prompt", &c.SystemPrompt},
+	} {
+		if value := os.Getenv(setting.env); value != "" {
+			*setting.target = value
+			c.SetOrigin(setting.key, "env "+setting.env)
+		}
+	}
+	for _, setting := range []struct {
+		env, key string
+		target   *int
+	}{
+		{"BILLDOZER_MAX_TOKENS", "limits.max_tokens", &c.Limits.MaxTokens},
+		{"BILLDOZER_MAX_TURNS", "limits.max_turns", &c.Limits.MaxTurns},
+	} {
+		value := os.Getenv(setting.env)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s must be a non-negative integer, got %q", setting.env, value)
+		}
+		*setting.target = n
+		c.SetOrigin(setting.key, "env "+setting.env)
+	}
+	return nil
+}
+
+// ModelOrDefault returns the configured model with aliases resolved, or DefaultModel
+func (c *Config) ModelOrDefault() string {
+	if c.Model == "" {
+		return DefaultModel
+	}
+	return ResolveModel(c.ModelAliases(), c.Model)
+}
+
+// MaxTokensOrDefault returns the configured response token limit, or DefaultMaxTokens
+func (c *Config) MaxTokensOrDefault() int {
+	if c.Limits.MaxTokens <= 0 {
+		return DefaultMaxTokens
+	}
+	return c.Limits.MaxTokens
+}
+
+// Providers lists the model providers this build can talk to
+var Providers = []string{DefaultProvider, "azure", "openrouter", "gemini"}
+
+// ValidateProvider reports an error for providers this build cannot talk
+// to, or whose settings are incomplete
+func (c *Config) ValidateProvider() error {
+	switch c.Provider {
+	case "", DefaultProvider:
+		return c.Anthropic.Validate()
+	case "openrouter", "gemini":
+		return nil
+	case "azure":
+		if c.Azure.Endpoint == "" {
+			return errors.New("provider azure needs azure.endpoint, e.g. https://myorg.openai.azure.com")
+		}
+		switch c.Azure.Auth {
+		case "", AzureAuthKey, AzureAuthAD:
+			return nil
+		}
+		return fmt.Errorf("azure.auth must be %s or %s, not %q", AzureAuthKey, AzureAuthAD, c.Azure.Auth)
+	}
+	return fmt.Errorf("unsupported provider %q (supported: %s)", c.Provider, strings.Join(Providers, ", "))
+}
+
+// ProviderOrDefault returns the configured provider, or DefaultProvider
+func (c *Config) ProviderOrDefault() string {
+	if c.Provider == "" {
+		return DefaultProvider
+	}
+	return c.Provider
+}
+
+// ReadSystemPrompt returns the contents of the configured system prompt
+// file, or "" when none is configured
+func (c *Config) ReadSystemPrompt() (string, error) {
+	if c.SystemPrompt == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(c.SystemPrompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read system prompt: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"maps"
+	"slices"
+)
+
+// maxAliasDepth bounds alias chains such as "default" -> "smart" -> a model ID
+const maxAliasDepth = 8
+
+// DefaultModelAliases are available in every config; entries under models
+// in billdozer.yml or the global config.yml replace them by name
+var DefaultModelAliases = map[string]string{
+	"fast":   "claude-3-5-haiku-latest",
+	"smart":  "claude-opus-4-1-20250805",
+	"haiku":  "claude-3-5-haiku-latest",
+	"sonnet": "claude-sonnet-4-20250514",
+	"opus":   "claude-opus-4-1-20250805",
+}
+
+// ModelAliases returns the built-in aliases merged with the configured ones
+func (c *Config) ModelAliases() map[string]string {
+	aliases := maps.Clone(DefaultModelAliases)
+	for name, model := range c.Models {
+		aliases[name] = model
+	}
+	return aliases
+}
+
+// ResolveModel maps an alias to a model ID. Names that are not aliases are
+// returned unchanged, so full model IDs can always be used directly.
+func ResolveModel(aliases map[string]string, name string) string {
+	for range maxAliasDepth {
+		model, ok := aliases[name]
+		if !ok || model == "" || model == name {
+			return name
+		}
+		name = model
+	}
+	return name
+}
+
+// AliasNames returns the alias names in sorted order, e.g. for completion
+func AliasNames(aliases map[string]string) []string {
+	return slices.Sorted(maps.Keys(aliases))
+}
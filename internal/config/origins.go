@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OriginDefault marks settings that come from the built-in defaults
+const OriginDefault = "default"
+
+// Setting is one effective config value and the layer that set it
+type Setting struct {
+	Key    string // Dotted path, e.g. limits.max_tokens or permissions.rules[0].tool
+	Value  string
+	Origin string // "path:line", "env BILLDOZER_MODEL", "flag --model", "profile NAME" or OriginDefault
+}
+
+// SetOrigin records that key (a dotted path) was last set by origin. Later
+// calls replace earlier ones, so layers must record in precedence order.
+func (c *Config) SetOrigin(key, origin string) {
+	if c.origins == nil {
+		c.origins = map[string]string{}
+	}
+	// A layer that sets a key replaces everything an earlier layer set below it
+	for existing := range c.origins {
+		if strings.HasPrefix(existing, key+".") || strings.HasPrefix(existing, key+"[") {
+			delete(c.origins, existing)
+		}
+	}
+	c.origins[key] = origin
+}
+
+// entryMaps are the settings whose entries a layer replaces whole, rather
+// than field by field: decoding a map entry or a list always starts from
+// an empty value, so nothing set by an earlier layer survives inside it
+var entryMaps = []string{"commands", "groups", "profiles", "models", "tools.descriptions"}
+
+// recordFileOrigins records every value set in a parsed config file
+func (c *Config) recordFileOrigins(path string, root *yaml.Node) {
+	c.recordNode(documentNode(root), "", false, path)
+}
+
+// recordNode records the origin of node and everything below it under key.
+// replaced marks entries of entryMaps, which wipe what earlier layers set.
+func (c *Config) recordNode(node *yaml.Node, key string, replaced bool, path string) {
+	if node == nil {
+		return
+	}
+	origin := fmt.Sprintf("%s:%d", path, node.Line)
+	if replaced || node.Kind == yaml.SequenceNode {
+		c.SetOrigin(key, origin)
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 && key != "" {
+			c.SetOrigin(key, origin)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			child := node.Content[i].Value
+			if key != "" {
+				child = key + "." + child
+			}
+			c.recordNode(node.Content[i+1], child, slices.Contains(entryMaps, key), path)
+		}
+	case yaml.SequenceNode:
+		if !scalarItems(node) {
+			for i, item := range node.Content {
+				c.recordNode(item, fmt.Sprintf("%s[%d]", key, i), false, path)
+			}
+		}
+	case yaml.AliasNode:
+		c.recordNode(node.Alias, key, false, path)
+	default:
+		if key != "" {
+			c.SetOrigin(key, origin)
+		}
+	}
+}
+
+// Settings lists the effective values that are set, along with where each
+// came from. Built-in defaults are included for settings no layer set.
+func (c *Config) Settings() ([]Setting, error) {
+	effective := *c
+	defaults := map[string]bool{}
+	if effective.Model == "" {
+		effective.Model = DefaultModel
+		defaults["model"] = true
+	}
+	if effective.Provider == "" {
+		effective.Provider = DefaultProvider
+		defaults["provider"] = true
+	}
+	if effective.Limits.MaxTokens <= 0 {
+		effective.Limits.MaxTokens = DefaultMaxTokens
+		defaults["limits.max_tokens"] = true
+	}
+
+	var root yaml.Node
+	if err := root.Encode(&effective); err != nil {
+		return nil, err
+	}
+
+	var settings []Setting
+	walkLeaves(&root, "", func(key string, node *yaml.Node) {
+		origin := c.originOf(key)
+		if defaults[key] {
+			origin = OriginDefault
+		}
+		// Zero values are noise unless a layer set that exact key
+		if _, explicit := c.origins[key]; isZero(node) && !explicit && !defaults[key] {
+			return
+		}
+		if origin == "" {
+			origin = OriginDefault
+		}
+		settings = append(settings, Setting{Key: key, Value: leafValue(node), Origin: origin})
+	})
+	return settings, nil
+}
+
+// originOf finds the origin of key, or of the nearest parent that was set as a whole
+func (c *Config) originOf(key string) string {
+	for {
+		if origin, ok := c.origins[key]; ok {
+			return origin
+		}
+		cut := strings.LastIndexAny(key, ".[")
+		if cut <= 0 {
+			return ""
+		}
+		key = key[:cut]
+	}
+}
+
+// walkLeaves calls visit for each scalar, empty collection or list of
+// scalars under node. Lists of mappings are descended into by index.
+func walkLeaves(node *yaml.Node, prefix string, visit func(key string, node *yaml.Node)) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkLeaves(child, prefix, visit)
+		}
+	case yaml.MappingNode:
+		if len(node.Content) == 0 && prefix != "" {
+			visit(prefix, node)
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			walkLeaves(node.Content[i+1], key, visit)
+		}
+	case yaml.SequenceNode:
+		if scalarItems(node) {
+			visit(prefix, node)
+			return
+		}
+		for i, item := range node.Content {
+			walkLeaves(item, fmt.Sprintf("%s[%d]", prefix, i), visit)
+		}
+	case yaml.AliasNode:
+		walkLeaves(node.Alias, prefix, visit)
+	default:
+		if prefix != "" {
+			visit(prefix, node)
+		}
+	}
+}
+
+// scalarItems reports whether every item of a sequence is a scalar
+func scalarItems(node *yaml.Node) bool {
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+// isZero reports whether a leaf holds an empty or zero value
+func isZero(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Value {
+		case "", "0", "false", "null":
+			return true
+		}
+		return node.Tag == "!!null"
+	default:
+		return len(node.Content) == 0
+	}
+}
+
+// leafValue renders a leaf on one line: scalars as written, lists in flow style
+func leafValue(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	items := make([]string, len(node.Content))
+	for i, item := range node.Content {
+		items[i] = item.Value
+	}
+	if node.Kind == yaml.MappingNode {
+		return "{}"
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
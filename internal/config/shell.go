@@ -0,0 +1,42 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PlaceholderPattern matches {{name}} in command templates
+var PlaceholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// QuotedPlaceholder returns the name of the first placeholder of a
+// shell: true command that sits inside quotes or backticks, or "" when
+// there is none. Substituted values are quoted for the shell, and that
+// quoting only holds outside quotes: in `echo "{{msg}}"` the quotes around
+// the value are literal and the shell still expands $(...) inside it.
+func QuotedPlaceholder(command, shell string) string {
+	quotes, escape := `'"`+"`", byte('\\')
+	switch shell {
+	case "cmd":
+		quotes, escape = `"`, 0
+	case "powershell", "pwsh":
+		quotes, escape = `'"`, '`'
+	}
+
+	var quote byte // The quote the scan is inside, 0 outside
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != '\'' && escape != 0 && c == escape:
+			i++ // The escaped character is literal
+		case quote == 0 && strings.IndexByte(quotes, c) >= 0:
+			quote = c
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0 && c == '{':
+			if match := PlaceholderPattern.FindStringSubmatchIndex(command[i:]); match != nil && match[0] == 0 {
+				return command[i+match[2] : i+match[3]]
+			}
+		}
+	}
+	return ""
+}
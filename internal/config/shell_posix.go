@@ -0,0 +1,6 @@
+//go:build !windows
+
+package config
+
+// DefaultShell runs commands with shell: true and no shell_program
+const DefaultShell = "sh"
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuotedPlaceholder(t *testing.T) {
+	tests := []struct {
+		command, shell, want string
+	}{
+		{`echo {{msg}}`, "sh", ""},
+		{`echo "{{msg}}"`, "sh", "msg"},
+		{`echo "prefix {{ msg }} suffix"`, "bash", "msg"},
+		{`echo '{{msg}}'`, "sh", "msg"},
+		{"echo `cat {{file}}`", "zsh", "file"},
+		{`echo "done" {{msg}} "again"`, "sh", ""},
+		{`echo \"{{msg}}\"`, "sh", ""},
+		{`echo 'it''s' {{msg}}`, "sh", ""},
+		{`echo "{{msg}}"`, "powershell", "msg"},
+		{"echo `\"{{msg}}", "pwsh", ""},
+		{`echo "{{msg}}"`, "cmd", "msg"},
+		{`echo it's {{msg}}`, "cmd", ""},
+	}
+	for _, tt := range tests {
+		if got := QuotedPlaceholder(tt.command, tt.shell); got != tt.want {
+			t.Errorf("QuotedPlaceholder(%q, %s) = %q, want %q", tt.command, tt.shell, got, tt.want)
+		}
+	}
+}
+
+func TestLoadCommandsConfigRejectsQuotedPlaceholders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".agent-commands.yml")
+	data := "commands:\n  say:\n    command: 'echo \"{{msg}}\"'\n    shell: true\n    timeout_seconds: 5\n    parameters:\n      - name: msg\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadCommandsConfig(path)
+	if err == nil || !strings.Contains(err.Error(), ":3: command \"say\": placeholder {{msg}} is inside quotes") {
+		t.Fatalf("LoadCommandsConfig error = %v, want one for the quoted placeholder on line 3", err)
+	}
+}
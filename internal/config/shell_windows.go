@@ -0,0 +1,4 @@
+package config
+
+// DefaultShell runs commands with shell: true and no shell_program
+const DefaultShell = "cmd"
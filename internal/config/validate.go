@@ -0,0 +1,300 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownFieldPattern matches the message yaml.v3 reports for keys that do not map to a struct field
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type \S+$`)
+
+// yamlLinePattern matches the "yaml: line N: " prefix of yaml.v3 syntax errors
+var yamlLinePattern = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// describeYAMLError rewrites yaml.v3 errors as one "path:line: message" entry per problem
+func describeYAMLError(path string, err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+			return fmt.Errorf("%s:%s: %s", path, match[1], strings.TrimPrefix(err.Error(), match[0]))
+		}
+		return fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	problems := make([]error, 0, len(typeErr.Errors))
+	for _, message := range typeErr.Errors {
+		if match := unknownFieldPattern.FindStringSubmatch(message); match != nil {
+			problems = append(problems, fmt.Errorf("%s:%s: unknown key %q", path, match[1], match[2]))
+			continue
+		}
+		if line, rest, ok := strings.Cut(strings.TrimPrefix(message, "line "), ": "); ok {
+			problems = append(problems, fmt.Errorf("%s:%s: %s", path, line, rest))
+			continue
+		}
+		problems = append(problems, fmt.Errorf("%s: %s", path, message))
+	}
+	return errors.Join(problems...)
+}
+
+// unknownKeys reduces a strict decoding error to its unknown-key problems.
+// Type mismatches are dropped because values may still change type once
+// ${VAR} references are expanded; they are caught when the expanded
+// document is decoded.
+func unknownKeys(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	var unknown []string
+	for _, message := range typeErr.Errors {
+		if unknownFieldPattern.MatchString(message) {
+			unknown = append(unknown, message)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	return &yaml.TypeError{Errors: unknown}
+}
+
+// CheckKeys reports keys in a billdozer.yml or global config.yml that no
+// setting uses, such as misspelled section names, with their line numbers.
+// Loading ignores them, so they would otherwise go unnoticed. A missing
+// file is not an error.
+func CheckKeys(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := unknownKeys(decoder.Decode(&config)); err != nil && err != io.EOF {
+		return describeYAMLError(path, err)
+	}
+	return nil
+}
+
+// validateCommands checks each command definition for mistakes that would
+// otherwise only show up when the command runs. Problems are reported as
+// "path:line: message" using the positions in root.
+func validateCommands(path string, root *yaml.Node, config *CommandsConfig) error {
+	nodes := commandNodes(root)
+	var problems []error
+	report := func(line int, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s:%d: %s", path, line, fmt.Sprintf(format, args...)))
+	}
+
+	if config.MaxOutputBytes < -1 {
+		report(keyLine(documentNode(root), "max_output_bytes", 1), "max_output_bytes must be positive, or -1 to disable the cap")
+	}
+
+	for _, name := range namesInFileOrder(config.Commands, nodes) {
+		spec := config.Commands[name]
+		node := nodes[name]
+		line := 1
+		if node != nil {
+			line = node.Line
+		}
+
+		if strings.TrimSpace(spec.Command) == "" {
+			report(line, "command %q has no command string; add e.g. command: \"make %s\"", name, name)
+		}
+
+		switch {
+		case spec.TimeoutSeconds < 0 || (spec.TimeoutSeconds == 0 && keyLine(node, "timeout_seconds", 0) != 0):
+			report(keyLine(node, "timeout_seconds", line), "command %q: timeout_seconds must be a positive number of seconds", name)
+		case spec.TimeoutSeconds == 0 && !spec.Background:
+			report(line, "command %q has no timeout_seconds; add e.g. timeout_seconds: 300 (without one the command would be cancelled immediately)", name)
+		}
+
+		switch spec.Danger {
+		case "", "low", "medium", "high":
+		default:
+			report(keyLine(node, "danger", line), "command %q: danger must be low, medium or high, not %q", name, spec.Danger)
+		}
+
+		switch {
+		case spec.ShellProgram != "" && !spec.Shell:
+			report(keyLine(node, "shell_program", line), "command %q: shell_program only applies with shell: true", name)
+		case !slices.Contains([]string{"", "sh", "bash", "zsh", "cmd", "powershell", "pwsh"}, spec.ShellProgram):
+			report(keyLine(node, "shell_program", line), "command %q: shell_program must be sh, bash, zsh, cmd, powershell or pwsh, not %q", name, spec.ShellProgram)
+		}
+
+		if spec.Shell {
+			shell := spec.ShellProgram
+			if shell == "" {
+				shell = DefaultShell
+			}
+			if param := QuotedPlaceholder(spec.Command, shell); param != "" {
+				report(keyLine(node, "command", line), "command %q: placeholder {{%s}} is inside quotes, where the shell still interprets the value; remove the quotes around it, since values are quoted for the shell already", name, param)
+			}
+		}
+
+		if spec.MaxOutputBytes < -1 {
+			report(keyLine(node, "max_output_bytes", line), "command %q: max_output_bytes must be positive, or -1 to disable the cap", name)
+		}
+
+		paramNodes := mappingValue(node, "parameters")
+		seen := make(map[string]bool, len(spec.Parameters))
+		for i, param := range spec.Parameters {
+			paramLine := keyLine(node, "parameters", line)
+			if paramNodes != nil && i < len(paramNodes.Content) {
+				paramLine = paramNodes.Content[i].Line
+			}
+			switch {
+			case param.Name == "":
+				report(paramLine, "command %q has a parameter without a name", name)
+			case seen[param.Name]:
+				report(paramLine, "command %q declares parameter %q more than once", name, param.Name)
+			}
+			seen[param.Name] = true
+
+			switch param.Type {
+			case "", "string", "integer", "number", "boolean":
+			default:
+				report(paramLine, "command %q parameter %q: type must be string, integer, number or boolean, not %q", name, param.Name, param.Type)
+			}
+			if param.Pattern != "" {
+				if _, err := regexp.Compile(param.Pattern); err != nil {
+					report(paramLine, "command %q parameter %q: invalid pattern: %v", name, param.Name, err)
+				}
+			}
+		}
+	}
+
+	groups := mappingValue(documentNode(root), "groups")
+	for _, name := range sortedKeys(config.Groups) {
+		line := keyLine(groups, name, 1)
+		if len(config.Groups[name]) == 0 {
+			report(line, "command group %q has no members", name)
+		}
+		if _, clash := config.Commands[name]; clash {
+			report(line, "command group %q has the same name as a command", name)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// CheckGroups verifies that every group member is a defined foreground
+// command that can run without arguments. Groups may use commands from any
+// loaded file, so this runs once the files are merged.
+func (c *CommandsConfig) CheckGroups() error {
+	var problems []error
+	for _, group := range sortedKeys(c.Groups) {
+		for _, member := range c.Groups[group] {
+			spec, ok := c.Commands[member]
+			switch {
+			case !ok:
+				if _, nested := c.Groups[member]; nested {
+					problems = append(problems, fmt.Errorf("command group %q: member %q is a group; list its commands instead", group, member))
+				} else {
+					problems = append(problems, fmt.Errorf("command group %q: unknown command %q", group, member))
+				}
+			case spec.Background:
+				problems = append(problems, fmt.Errorf("command group %q: %q is a background command and cannot be part of a group", group, member))
+			default:
+				for _, param := range spec.Parameters {
+					if param.Required && param.Default == "" {
+						problems = append(problems, fmt.Errorf("command group %q: %q needs argument %q, but group members run without arguments", group, member, param.Name))
+					}
+				}
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// documentNode returns the top-level mapping of a parsed document
+func documentNode(root *yaml.Node) *yaml.Node {
+	if root != nil && root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		return root.Content[0]
+	}
+	return root
+}
+
+// commandNodes maps each command name to the key node that defines it
+func commandNodes(root *yaml.Node) map[string]*yaml.Node {
+	nodes := map[string]*yaml.Node{}
+	commands := mappingValue(documentNode(root), "commands")
+	if commands == nil || commands.Kind != yaml.MappingNode {
+		return nodes
+	}
+	for i := 0; i+1 < len(commands.Content); i += 2 {
+		key := commands.Content[i]
+		// The value node carries the command's own keys; keep its line from the key
+		value := *commands.Content[i+1]
+		value.Line = key.Line
+		nodes[key.Value] = &value
+	}
+	return nodes
+}
+
+// mappingValue returns the value for key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyLine returns the line of key within a mapping node, or fallback when it is absent
+func keyLine(node *yaml.Node, key string, fallback int) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return fallback
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i].Line
+		}
+	}
+	return fallback
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namesInFileOrder returns the command names sorted by the line that defines them
+func namesInFileOrder(commands map[string]CommandSpec, nodes map[string]*yaml.Node) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	line := func(name string) int {
+		if node := nodes[name]; node != nil {
+			return node.Line
+		}
+		return 0
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if line(names[i]) != line(names[j]) {
+			return line(names[i]) < line(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,258 @@
+package confirm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"agent/internal/diff"
+	"agent/internal/theme"
+)
+
+// maxPreviewLines limits how much of a preview is printed before the prompt
+const maxPreviewLines = 200
+
+// Request describes an operation that needs user approval
+type Request struct {
+	Tool    string // Tool requesting confirmation, used for session approvals
+	Action  string // Human-readable description, e.g. "delete the file"
+	Path    string // Optional target path, enables "always for this path" answers
+	Preview string // Optional diff, command line or other detail shown before asking
+	Force   bool   // Always ask the user: ignores session approvals, allow rules and auto-approve
+}
+
+// Confirmer approves or rejects operations
+type Confirmer interface {
+	Confirm(req Request) bool
+}
+
+// Prompter is implemented by confirmers that can tell in advance whether a
+// request will be shown to the user, so callers know whether the user has
+// seen its preview
+type Prompter interface {
+	Prompts(req Request) bool
+}
+
+// WillPrompt reports whether confirmer will ask the user about req
+func WillPrompt(confirmer Confirmer, req Request) bool {
+	prompter, ok := confirmer.(Prompter)
+	return ok && prompter.Prompts(req)
+}
+
+// AutoApprove approves every request without prompting. Used when the
+// permission policy already allows a call. Forced requests are passed to
+// Fallback instead, and rejected when there is none.
+type AutoApprove struct {
+	Fallback Confirmer
+}
+
+// Confirm returns true unless the request is forced
+func (a AutoApprove) Confirm(req Request) bool {
+	if !req.Force {
+		return true
+	}
+	return a.Fallback != nil && a.Fallback.Confirm(req)
+}
+
+// Prompts reports whether a forced request will be passed to the fallback, which asks
+func (a AutoApprove) Prompts(req Request) bool {
+	return req.Force && a.Fallback != nil && WillPrompt(a.Fallback, req)
+}
+
+// Service prompts the user for confirmation and remembers "always" answers
+// for the rest of the session
+type Service struct {
+	getUserInput func() (string, bool)
+	autoApprove  bool
+	toolAllowed  map[string]bool
+	pathAllowed  map[string]bool
+	beforeOutput func()
+	beforePrompt func(req Request)
+	refused      func(req Request)
+	quiet        bool // Auto-approvals are not announced
+	mutex        sync.Mutex
+}
+
+// NewService creates a confirmation service. With autoApprove set every
+// request is approved without prompting, for headless runs.
+func NewService(getUserInput func() (string, bool), autoApprove bool) *Service {
+	return &Service{
+		getUserInput: getUserInput,
+		autoApprove:  autoApprove,
+		toolAllowed:  make(map[string]bool),
+		pathAllowed:  make(map[string]bool),
+	}
+}
+
+// SetBeforeOutput sets a hook that runs before the service prints, e.g. to
+// clear a progress spinner from the line the prompt will use
+func (s *Service) SetBeforeOutput(hook func()) {
+	s.beforeOutput = hook
+}
+
+// SetBeforePrompt sets a hook that runs when the user is about to be asked,
+// e.g. to notify a user who is not watching
+func (s *Service) SetBeforePrompt(hook func(req Request)) {
+	s.beforePrompt = hook
+}
+
+// SetRefused sets a hook that runs when a request is refused without
+// asking the user: forced requests under auto-approve, or any request when
+// there is no input to ask on
+func (s *Service) SetRefused(hook func(req Request)) {
+	s.refused = hook
+}
+
+// SetQuiet stops or resumes announcing auto-approved requests; refusals
+// and prompts are always shown
+func (s *Service) SetQuiet(quiet bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.quiet = quiet
+}
+
+// Prompts reports whether Confirm will ask the user about req rather than
+// answering from session approvals or auto-approve
+func (s *Service) Prompts(req Request) bool {
+	if !req.Force && s.isRemembered(req) {
+		return false
+	}
+	return !s.autoApprove && s.getUserInput != nil
+}
+
+// Confirm shows the request and asks the user for an answer
+func (s *Service) Confirm(req Request) bool {
+	if !req.Force && s.isRemembered(req) {
+		return true
+	}
+	if s.beforeOutput != nil {
+		s.beforeOutput()
+	}
+
+	if s.autoApprove && req.Force {
+		fmt.Printf("Refused: %s requires interactive confirmation, even with auto-approve\n", describe(req))
+		s.refuse(req)
+		return false
+	}
+	if s.autoApprove {
+		s.mutex.Lock()
+		quiet := s.quiet
+		s.mutex.Unlock()
+		if !quiet {
+			fmt.Printf("Auto-approved: %s\n", describe(req))
+		}
+		return true
+	}
+
+	if s.getUserInput == nil {
+		fmt.Printf("Warning: user input not available, cannot confirm: %s\n", describe(req))
+		s.refuse(req)
+		return false
+	}
+
+	if s.beforePrompt != nil {
+		s.beforePrompt(req)
+	}
+	fmt.Printf("⚠️ Billdozer wants to %s\n", describe(req))
+	if req.Preview != "" {
+		fmt.Println(FormatPreview(req.Preview))
+	}
+	fmt.Print(promptText(req))
+
+	response, ok := s.getUserInput()
+	if !ok {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(response)) {
+	case "y", "yes":
+		return true
+	case "s", "session":
+		if req.Force {
+			return false
+		}
+		s.remember(req, false)
+		return true
+	case "p", "path":
+		if req.Path == "" || req.Force {
+			return false
+		}
+		s.remember(req, true)
+		return true
+	}
+	return false
+}
+
+func (s *Service) refuse(req Request) {
+	if s.refused != nil {
+		s.refused(req)
+	}
+}
+
+func (s *Service) isRemembered(req Request) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.toolAllowed[req.Tool] || (req.Path != "" && s.pathAllowed[pathKey(req)])
+}
+
+func (s *Service) remember(req Request, forPath bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if forPath {
+		s.pathAllowed[pathKey(req)] = true
+		return
+	}
+	s.toolAllowed[req.Tool] = true
+}
+
+func pathKey(req Request) string {
+	return req.Tool + "\x00" + req.Path
+}
+
+// Description says what the request asks for in plain text, e.g.
+// "delete the file: notes.txt"
+func (r Request) Description() string {
+	if r.Path == "" {
+		return r.action()
+	}
+	return r.action() + ": " + r.Path
+}
+
+func (r Request) action() string {
+	if r.Action == "" {
+		return "run " + r.Tool
+	}
+	return r.Action
+}
+
+func describe(req Request) string {
+	if req.Path == "" {
+		return req.action()
+	}
+	return fmt.Sprintf("%s: %s", req.action(), theme.Paint(theme.Path, req.Path))
+}
+
+func promptText(req Request) string {
+	if req.Force {
+		return "Proceed? (y)es, (n)o: "
+	}
+	prompt := fmt.Sprintf("Proceed? (y)es, (n)o, (s) always allow %s this session", req.Tool)
+	if req.Path != "" {
+		prompt += fmt.Sprintf(", (p) always allow %s on this path", req.Tool)
+	}
+	return prompt + ": "
+}
+
+// FormatPreview prepares a preview for the terminal: long previews are
+// truncated and diffs are colored
+func FormatPreview(preview string) string {
+	lines := strings.Split(strings.TrimRight(preview, "\n"), "\n")
+	if len(lines) > maxPreviewLines {
+		lines = append(lines[:maxPreviewLines], fmt.Sprintf("... (%d more lines)", len(lines)-maxPreviewLines))
+	}
+	text := strings.Join(lines, "\n")
+	if diff.IsUnified(text) {
+		return diff.Colorize(text)
+	}
+	return text
+}
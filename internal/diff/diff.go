@@ -0,0 +1,211 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"agent/internal/theme"
+)
+
+// Constants for diff generation
+const (
+	contextLines = 3
+	// maxCells bounds the LCS table so huge files don't exhaust memory
+	maxCells = 4_000_000
+)
+
+// opKind identifies a line operation in an edit script
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single line in an edit script
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff between oldText and newText labeled with path.
+// It returns an empty string when the texts are identical.
+func Unified(path, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+	if len(oldLines)*len(newLines) > maxCells {
+		return fmt.Sprintf("--- a/%s\n+++ b/%s\n(diff too large to display: %d -> %d lines)\n", path, path, len(oldLines), len(newLines))
+	}
+
+	ops := editScript(oldLines, newLines)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", path, path)
+	for _, h := range hunks(ops) {
+		out.WriteString(h)
+	}
+	return out.String()
+}
+
+// Fits reports whether Unified can show the changes between oldText and
+// newText line by line, rather than only saying the diff is too large
+func Fits(oldText, newText string) bool {
+	return len(splitLines(oldText))*len(splitLines(newText)) <= maxCells
+}
+
+// IsUnified reports whether text looks like the output of Unified
+func IsUnified(text string) bool {
+	return strings.HasPrefix(text, "--- ")
+}
+
+// Colorize renders a unified diff for the terminal with the current theme:
+// by default added lines green, removed lines red, hunk headers cyan and
+// file headers bold. Context lines are left as they are, and the diff is
+// returned unchanged when colors are off.
+func Colorize(unified string) string {
+	lines := strings.Split(unified, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			lines[i] = theme.Paint(theme.DiffHeader, line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = theme.Paint(theme.DiffHunk, line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = theme.Paint(theme.DiffAdd, line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = theme.Paint(theme.DiffDelete, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// editScript computes a minimal line edit script using a longest common subsequence table
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
+
+// hunks groups an edit script into unified diff hunks with surrounding context
+func hunks(ops []op) []string {
+	var result []string
+	oldLine, newLine := 1, 1
+
+	for start := 0; start < len(ops); {
+		// Find the next change
+		first := start
+		for first < len(ops) && ops[first].kind == opEqual {
+			first++
+			oldLine++
+			newLine++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		// Extend the hunk until a run of unchanged lines longer than the context window
+		hunkStart := max(first-contextLines, start)
+		oldStart := oldLine - (first - hunkStart)
+		newStart := newLine - (first - hunkStart)
+
+		end := first
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end = min(end+contextLines, len(ops))
+				break
+			}
+			end = run
+		}
+
+		var body strings.Builder
+		oldCount, newCount := 0, 0
+		for _, o := range ops[hunkStart:end] {
+			switch o.kind {
+			case opEqual:
+				body.WriteString(" " + o.line + "\n")
+				oldCount++
+				newCount++
+			case opDelete:
+				body.WriteString("-" + o.line + "\n")
+				oldCount++
+			case opInsert:
+				body.WriteString("+" + o.line + "\n")
+				newCount++
+			}
+		}
+		result = append(result, fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", oldStart, oldCount, newStart, newCount, body.String()))
+
+		// Advance line counters past the hunk
+		for _, o := range ops[first:end] {
+			if o.kind != opInsert {
+				oldLine++
+			}
+			if o.kind != opDelete {
+				newLine++
+			}
+		}
+		start = end
+	}
+	return result
+}
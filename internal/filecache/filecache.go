@@ -0,0 +1,114 @@
+package filecache
+
+import (
+	"container/list"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/internal/workspace"
+)
+
+// DefaultMaxBytes is how much file content a cache holds before it drops
+// the least recently read files
+const DefaultMaxBytes = 64 << 20
+
+// Cache keeps the contents of files read during a session, so reading a
+// hot file again costs a stat instead of a read. Contents are keyed by
+// path, size and modification time: a file that changed in either is read
+// again. A nil *Cache is valid and reads every file from its file system.
+type Cache struct {
+	mutex    sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element // Keyed by cleaned path
+	order    *list.List               // Of *entry, most recently read first
+}
+
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+	data    []byte
+}
+
+// New creates a cache holding up to maxBytes of file content
+func New(maxBytes int64) *Cache {
+	return &Cache{maxBytes: maxBytes, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// ReadFile returns the content of the file at path, which info describes
+// as it is now. The cached content is used when the file has the size and
+// modification time it had when it was read; otherwise it is read from
+// fsys and kept.
+func (c *Cache) ReadFile(fsys workspace.FS, path string, info fs.FileInfo) ([]byte, error) {
+	if data, ok := c.Get(path, info); ok {
+		return data, nil
+	}
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c.put(path, info, data)
+	return data, nil
+}
+
+// Get returns the cached content of the file at path if info still
+// matches it. Callers must not modify the content.
+func (c *Cache) Get(path string, info fs.FileInfo) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[filepath.Clean(path)]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if e.size != info.Size() || !e.modTime.Equal(info.ModTime()) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return e.data, true
+}
+
+// Forget drops the cached content of a file, for callers that have just
+// changed it: a write that keeps the size within the file system's
+// timestamp resolution would otherwise go unnoticed
+func (c *Cache) Forget(path string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[filepath.Clean(path)]; ok {
+		c.remove(element)
+	}
+}
+
+func (c *Cache) put(path string, info fs.FileInfo, data []byte) {
+	if c == nil || int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	path = filepath.Clean(path)
+	if element, ok := c.entries[path]; ok {
+		c.remove(element)
+	}
+	c.entries[path] = c.order.PushFront(&entry{path: path, size: info.Size(), modTime: info.ModTime(), data: data})
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an element; the mutex must be held
+func (c *Cache) remove(element *list.Element) {
+	e := c.order.Remove(element).(*entry)
+	delete(c.entries, e.path)
+	c.size -= int64(len(e.data))
+}
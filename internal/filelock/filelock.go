@@ -0,0 +1,84 @@
+package filelock
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Manager hands out per-path locks so concurrent tool calls that modify the
+// same file run one after another. Paths are locked by their cleaned form;
+// callers should pass resolved absolute paths. A nil *Manager locks nothing.
+type Manager struct {
+	mutex sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock is a mutex plus the number of callers holding or waiting for it,
+// so idle entries can be dropped from the map
+type pathLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// NewManager creates an empty lock manager
+func NewManager() *Manager {
+	return &Manager{locks: make(map[string]*pathLock)}
+}
+
+// Lock blocks until every given path is locked and returns a function that
+// releases them. Paths are acquired in sorted order so two callers locking
+// overlapping sets cannot deadlock.
+func (m *Manager) Lock(paths ...string) (unlock func()) {
+	if m == nil {
+		return func() {}
+	}
+
+	unique := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		unique[filepath.Clean(path)] = true
+	}
+	sorted := make([]string, 0, len(unique))
+	for path := range unique {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	held := make([]*pathLock, 0, len(sorted))
+	for _, path := range sorted {
+		lock := m.acquire(path)
+		lock.mutex.Lock()
+		held = append(held, lock)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for i := len(held) - 1; i >= 0; i-- {
+				held[i].mutex.Unlock()
+				m.release(sorted[i], held[i])
+			}
+		})
+	}
+}
+
+func (m *Manager) acquire(path string) *pathLock {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	lock, ok := m.locks[path]
+	if !ok {
+		lock = &pathLock{}
+		m.locks[path] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+func (m *Manager) release(path string, lock *pathLock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(m.locks, path)
+	}
+}
@@ -0,0 +1,86 @@
+// Package filestate remembers which version of each file the agent last
+// saw, so the file tools can refuse to change a file that was modified
+// since, by the user's editor or anything else outside the session.
+package filestate
+
+import (
+	"crypto/sha256"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Tracker records the version of each file the agent last read or wrote.
+// Files read whole are known by a hash of their content, so saving a file
+// unchanged does not count as a change; files only read in part, without
+// their whole content at hand, by size and modification time. Paths are
+// keyed by their cleaned form; callers should pass resolved absolute paths.
+// A nil *Tracker records nothing and reports no changes.
+type Tracker struct {
+	mutex    sync.Mutex
+	versions map[string]version
+}
+
+type version struct {
+	hashed  bool
+	sum     [sha256.Size]byte
+	size    int64
+	modTime time.Time
+}
+
+// New creates a tracker that has seen no files
+func New() *Tracker {
+	return &Tracker{versions: make(map[string]version)}
+}
+
+// SawContent records that the agent has seen data as the content of path,
+// by reading it whole or by writing it
+func (t *Tracker) SawContent(path string, data []byte) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.versions[filepath.Clean(path)] = version{hashed: true, sum: sha256.Sum256(data)}
+}
+
+// SawStat records that the agent has read part of path, which info
+// describes as it was then
+func (t *Tracker) SawStat(path string, info fs.FileInfo) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.versions[filepath.Clean(path)] = version{size: info.Size(), modTime: info.ModTime()}
+}
+
+// Changed reports whether path, which info describes and which holds data
+// now, differs from the version the agent last saw. A file the agent has
+// not seen has not changed.
+func (t *Tracker) Changed(path string, info fs.FileInfo, data []byte) bool {
+	if t == nil {
+		return false
+	}
+	t.mutex.Lock()
+	seen, ok := t.versions[filepath.Clean(path)]
+	t.mutex.Unlock()
+	switch {
+	case !ok:
+		return false
+	case seen.hashed:
+		return seen.sum != sha256.Sum256(data)
+	}
+	return seen.size != info.Size() || !seen.modTime.Equal(info.ModTime())
+}
+
+// Forget drops what the agent saw of path, for a file it has deleted
+func (t *Tracker) Forget(path string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.versions, filepath.Clean(path))
+}
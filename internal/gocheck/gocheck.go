@@ -0,0 +1,185 @@
+// Package gocheck runs gofmt and go vet on the Go files a tool call
+// changed and adds what they report to its result, so the model fixes
+// syntax errors and vet findings in the same turn instead of at the next
+// build.
+package gocheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+const (
+	// timeout bounds each check; go vet type-checks the whole package and
+	// may build its dependencies first
+	timeout = 60 * time.Second
+	// maxLines is how many lines of a check's output are kept
+	maxLines = 30
+)
+
+// Middleware checks the .go files a successful tool call reports having
+// created or changed, with gofmt and, when vet is set, go vet on their
+// packages. Findings are added to the result as a text block; clean files
+// add nothing. The checks run where the workspace is, which is its host
+// for a remote one, and are skipped when gofmt or go cannot be started.
+func Middleware(vet bool) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			result, err := next(ctx, toolCtx, input)
+			if err != nil || result == nil || result.IsError || toolCtx == nil {
+				return result, err
+			}
+			files := goFiles(result.Metadata)
+			if len(files) == 0 {
+				return result, nil
+			}
+			if report := Check(ctx, toolCtx.Workspace, files, vet); report != "" {
+				result.Content = append(result.Content, tools.ContentBlock{Type: tools.ContentText, Text: report})
+			}
+			return result, nil
+		}
+	}
+}
+
+// goFiles lists the changed .go files that still exist
+func goFiles(metadata tools.ResultMetadata) []string {
+	deleted := make(map[string]bool, len(metadata.FilesDeleted))
+	for _, path := range metadata.FilesDeleted {
+		deleted[path] = true
+	}
+	var files []string
+	for _, path := range metadata.FilesChanged {
+		if filepath.Ext(path) == ".go" && !deleted[path] {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Check runs gofmt on the Go files at paths, relative to the workspace
+// root, and go vet on their packages unless one has a syntax error. It
+// returns what they report, or "" when they find nothing.
+func Check(ctx context.Context, ws *workspace.Workspace, paths []string, vet bool) string {
+	root := ""
+	if ws != nil {
+		root = ws.Root()
+	}
+	runner := ws.Runner()
+
+	var reports []string
+	syntaxErrors := false
+	for _, path := range paths {
+		// gofmt -d prints the changes it would make, and -e every syntax error
+		stdout, stderr, err := run(ctx, runner, root, "gofmt", "-d", "-e", path)
+		var exit workspace.ExitCoder
+		switch {
+		case err != nil && !errors.As(err, &exit):
+			continue
+		case stderr != "":
+			syntaxErrors = true
+			reports = append(reports, fmt.Sprintf("gofmt found syntax errors in %s:\n%s", path, firstLines(stderr)))
+		case stdout != "":
+			reports = append(reports, fmt.Sprintf("%s is not formatted as gofmt formats it; the changes gofmt would make:\n%s", path, firstLines(stdout)))
+		}
+	}
+
+	if vet && !syntaxErrors {
+		for _, shownDir := range packageDirs(paths) {
+			dir := shownDir
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			_, stderr, err := run(ctx, runner, dir, "go", "vet", ".")
+			var exit workspace.ExitCoder
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				reports = append(reports, fmt.Sprintf("go vet did not finish checking the package in %s within %s", displayDir(shownDir), timeout))
+				continue
+			case err == nil || !errors.As(err, &exit) || stderr == "":
+				continue
+			}
+			reports = append(reports, fmt.Sprintf("go vet reports for the package in %s:\n%s", displayDir(shownDir), firstLines(vetOutput(stderr, shownDir))))
+		}
+	}
+	return strings.Join(reports, "\n\n")
+}
+
+// packageDirs lists the directories of paths, each once
+func packageDirs(paths []string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func displayDir(dir string) string {
+	if dir == "." {
+		return "the workspace root"
+	}
+	return dir
+}
+
+// vetOutput drops the "# package" headers from go vet's output and names
+// files by their path from the workspace root rather than from dir
+func vetOutput(output, dir string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "vet: ")
+		if rest, ok := strings.CutPrefix(line, "./"); ok {
+			line = filepath.Join(dir, rest)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// firstLines keeps the first maxLines lines of output
+func firstLines(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:maxLines], "\n") + fmt.Sprintf("\n... %d more lines", len(lines)-maxLines)
+}
+
+// run runs a command in dir and returns its output. The error of a
+// command that ran and failed implements workspace.ExitCoder; any other
+// error means it could not be run.
+func run(ctx context.Context, runner workspace.Runner, dir string, argv ...string) (stdout, stderr string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out, errOut bytes.Buffer
+	process, err := runner.Start(workspace.Cmd{Argv: argv, Dir: dir, Stdout: &out, Stderr: &errOut, Group: true})
+	if err != nil {
+		return "", "", err
+	}
+	done := make(chan error, 1)
+	go func() { done <- process.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		process.Kill()
+		<-done
+		return "", "", ctx.Err()
+	}
+	return out.String(), errOut.String(), err
+}
@@ -0,0 +1,186 @@
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileName is the ignore file read from the workspace root
+const FileName = ".billdozerignore"
+
+// rule is one compiled line of an ignore file
+type rule struct {
+	pattern *regexp.Regexp
+	negate  bool // "!pattern" re-includes paths an earlier rule ignored
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// Matcher decides whether workspace-relative paths are ignored, using
+// gitignore syntax: "#" comments, "!" negation, a trailing "/" for
+// directories only, a leading or inner "/" to anchor a pattern to the root,
+// and "*", "?", "[...]" and "**" wildcards. As in git, a path inside an
+// ignored directory is ignored even if a later rule re-includes it.
+type Matcher struct {
+	rules []rule
+}
+
+// Load reads the ignore file in dir. A missing file yields a matcher that ignores nothing.
+func Load(dir string) (*Matcher, error) {
+	return LoadWith(os.ReadFile, dir)
+}
+
+// LoadWith is Load for files that are not on the local disk, reading the
+// ignore file with readFile
+func LoadWith(readFile func(path string) ([]byte, error), dir string) (*Matcher, error) {
+	path := filepath.Join(dir, FileName)
+	data, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Matcher{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	matcher, err := Parse(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return matcher, nil
+}
+
+// Parse compiles ignore file lines
+func Parse(lines []string) (*Matcher, error) {
+	var matcher Matcher
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		// Trailing spaces are ignored unless escaped with a backslash
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A slash anywhere but the end anchors the pattern to the root;
+		// otherwise it matches a name at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		expr := translate(line)
+		if anchored {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(?:.*/)?" + expr + "$"
+		}
+
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q: %w", i+1, lines[i], err)
+		}
+		r.pattern = pattern
+		matcher.rules = append(matcher.rules, r)
+	}
+	return &matcher, nil
+}
+
+// translate converts a gitignore glob to a regular expression
+func translate(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			// Leading or inner "**/" matches zero or more directories
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**") && i+2 == len(pattern):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Match reports whether a slash-separated path relative to the workspace
+// root is ignored, either itself or through one of its parent directories
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	if path == "" || path == "." {
+		return false
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && m.matchOne(path[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(path, isDir)
+}
+
+// matchOne applies the rules to a single path; the last matching rule wins
+func (m *Matcher) matchOne(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.pattern.MatchString(path) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,130 @@
+// Package jsonrpc holds the JSON-RPC 2.0 plumbing shared by the plugin, MCP
+// and ACP connections: the message format, and matching responses to the
+// calls waiting for them. Each connection keeps its own transport.
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClosed is returned by calls when the connection closes before the
+// response arrives
+var ErrClosed = errors.New("connection closed")
+
+// Message is a JSON-RPC 2.0 request, notification or response
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is the error of a response
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewRequest builds a request for method. Without an ID, which Call
+// assigns, it is a notification. Params are left out when nil.
+func NewRequest(method string, params any) (Message, error) {
+	msg := Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return Message{}, err
+		}
+		msg.Params = encoded
+	}
+	return msg, nil
+}
+
+// Calls tracks the requests a connection has sent and is waiting on. The
+// zero value is ready to use.
+type Calls struct {
+	mutex   sync.Mutex
+	nextID  int64
+	pending map[int64]chan Message
+}
+
+// Call sends a request for method with the next id, and waits for the
+// response Deliver hands over, for ctx to end or for closed to be closed,
+// whichever comes first. Errors from send are returned as they are.
+func (c *Calls) Call(ctx context.Context, method string, params any, send func(Message) error, closed <-chan struct{}) (Message, error) {
+	request, err := NewRequest(method, params)
+	if err != nil {
+		return Message{}, err
+	}
+
+	c.mutex.Lock()
+	if c.pending == nil {
+		c.pending = make(map[int64]chan Message)
+	}
+	c.nextID++
+	id := c.nextID
+	replies := make(chan Message, 1)
+	c.pending[id] = replies
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+	}()
+
+	request.ID = json.RawMessage(fmt.Sprint(id))
+	if err := send(request); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-closed:
+		return Message{}, ErrClosed
+	}
+}
+
+// Deliver hands a response to the call waiting on its id. Responses to
+// calls that were cancelled or timed out are dropped, and so are repeated
+// responses to a call, which would otherwise block the reader.
+func (c *Calls) Deliver(response Message) {
+	var id int64
+	if err := json.Unmarshal(response.ID, &id); err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	replies, ok := c.pending[id]
+	c.mutex.Unlock()
+	if ok {
+		select {
+		case replies <- response:
+		default:
+		}
+	}
+}
+
+// Decode returns the error of a response, or decodes its result into
+// result unless result is nil
+func (m Message) Decode(result any) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(m.Result, result)
+}
@@ -0,0 +1,85 @@
+package lineedit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// defaultEditor is run when neither VISUAL nor EDITOR is set
+const defaultEditor = "vi"
+
+// Compose opens the user's editor ($VISUAL, then $EDITOR, then vi) on a
+// temporary file holding draft and returns what was saved, without
+// trailing whitespace. An empty result means the user wrote nothing.
+func (e *Editor) Compose(draft string) (string, error) {
+	if e.scanner != nil {
+		return "", errors.New("an external editor needs an interactive terminal")
+	}
+	command := os.Getenv("VISUAL")
+	if command == "" {
+		command = os.Getenv("EDITOR")
+	}
+	if command == "" {
+		command = defaultEditor
+	}
+	// Editors such as "code --wait" come with arguments
+	argv := strings.Fields(command)
+
+	// A .md name gets prompts markdown highlighting in most editors
+	file, err := os.CreateTemp("", "billdozer-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create the message file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(draft)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write the message file: %w", err)
+	}
+
+	cmd := exec.Command(argv[0], append(argv[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = e.in, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", argv[0], err)
+	}
+	text, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read the message file: %w", err)
+	}
+	return strings.TrimRight(string(text), " \t\r\n"), nil
+}
+
+// composeLine opens the external editor on the line being edited, for
+// Ctrl+X Ctrl+E. The terminal leaves raw mode while the editor runs. The
+// bool reports whether the editor produced a message to submit; on
+// failure the error is shown and editing continues on a fresh line.
+func (l *line) composeLine() (string, bool) {
+	fd := int(l.e.in.Fd())
+	fmt.Fprint(l.e.out, "\x1b[?2004l")
+	term.Restore(fd, l.state)
+	text, err := l.e.Compose(string(l.buf))
+	if state, rawErr := term.MakeRaw(fd); rawErr == nil {
+		l.state = state
+	}
+	fmt.Fprint(l.e.out, "\x1b[?2004h")
+
+	if err == nil && strings.TrimSpace(text) != "" {
+		l.buf = []rune(text)
+		l.pos = len(l.buf)
+		l.redraw()
+		l.finish()
+		return text, true
+	}
+	if err != nil {
+		fmt.Fprintf(l.e.out, "\r\n%v\r\n", err)
+		l.startCol, l.rows = 0, 0
+	}
+	return "", false
+}
@@ -0,0 +1,699 @@
+package lineedit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// MaxHistory is how many entries are kept in memory and in the history file
+const MaxHistory = 1000
+
+// Editor reads lines from the terminal with readline-style editing and a
+// history that persists across sessions. When stdin is not a terminal it
+// reads plain lines, so piped input keeps working.
+type Editor struct {
+	in          *os.File
+	out         io.Writer
+	reader      *bufio.Reader
+	scanner     *bufio.Scanner
+	history     []string
+	historyPath string
+}
+
+// New creates an editor on stdin and stdout that loads and appends to the
+// history file at historyPath. An empty path keeps history in memory only.
+func New(historyPath string) *Editor {
+	e := &Editor{in: os.Stdin, out: os.Stdout, historyPath: historyPath}
+	// Dumb terminals cannot report the cursor position or move the cursor
+	if term.IsTerminal(int(e.in.Fd())) && os.Getenv("TERM") != "dumb" {
+		e.reader = bufio.NewReader(e.in)
+	} else {
+		e.scanner = bufio.NewScanner(e.in)
+	}
+	e.history = loadHistory(historyPath)
+	return e
+}
+
+// ReadLine reads a user message and, when typed at a terminal, records it
+// in the history. A message may span several lines: pasted text keeps its
+// newlines, Alt+Enter or Ctrl+J starts a new line, and a message that opens
+// with """ continues until a line ending in """. The bool is false at end
+// of input or when the user presses Ctrl+C or Ctrl+D on an empty line.
+func (e *Editor) ReadLine() (string, bool) {
+	line, ok := e.read()
+	if ok {
+		line, ok = e.readBlock(line)
+	}
+	if ok && e.scanner == nil {
+		e.remember(line)
+	}
+	return line, ok
+}
+
+// blockQuote opens and closes a multi-line message
+const blockQuote = `"""`
+
+// readBlock completes a message that opens with """ by reading lines up to
+// the closing """. End of input closes the block.
+func (e *Editor) readBlock(first string) (string, bool) {
+	text, opened := strings.CutPrefix(strings.TrimSpace(first), blockQuote)
+	if !opened {
+		return first, true
+	}
+	lines := []string{}
+	for {
+		if body, closed := strings.CutSuffix(strings.TrimRight(text, " \t"), blockQuote); closed {
+			lines = append(lines, body)
+			break
+		}
+		lines = append(lines, text)
+		if e.scanner == nil {
+			fmt.Fprint(e.out, "... ")
+		}
+		next, ok := e.read()
+		if !ok {
+			break
+		}
+		text = next
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n"), true
+}
+
+// ReadAnswer reads a line, such as a confirmation answer, without
+// recording it in the history
+func (e *Editor) ReadAnswer() (string, bool) {
+	return e.read()
+}
+
+func (e *Editor) read() (string, bool) {
+	if e.scanner != nil {
+		if !e.scanner.Scan() {
+			return "", false
+		}
+		return e.scanner.Text(), true
+	}
+
+	fd := int(e.in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	defer term.Restore(fd, state)
+
+	// Bracketed paste marks pasted text, so its newlines do not send the message
+	fmt.Fprint(e.out, "\x1b[?2004h")
+	defer fmt.Fprint(e.out, "\x1b[?2004l")
+
+	l := &line{e: e, state: state, startCol: e.cursorColumn(), width: terminalWidth(fd), historyIndex: len(e.history)}
+	return l.edit()
+}
+
+// readPlain reads a line without editing when raw mode is unavailable
+func (e *Editor) readPlain() (string, bool) {
+	text, err := e.reader.ReadString('\n')
+	if err != nil && text == "" {
+		return "", false
+	}
+	return strings.TrimRight(text, "\r\n"), true
+}
+
+// cursorColumn asks the terminal for the cursor column, which is where the
+// caller's prompt ended, so redraws never touch the prompt
+func (e *Editor) cursorColumn() int {
+	fmt.Fprint(e.out, "\x1b[6n")
+	var reply []byte
+	for {
+		b, err := e.reader.ReadByte()
+		if err != nil {
+			return 0
+		}
+		reply = append(reply, b)
+		if b == 'R' || len(reply) > 32 {
+			break
+		}
+	}
+	// The reply is ESC [ row ; col R; keys typed ahead may precede it
+	text := string(reply)
+	if start := strings.LastIndex(text, "\x1b["); start >= 0 {
+		text = text[start:]
+	}
+	_, col, found := strings.Cut(strings.TrimSuffix(text, "R"), ";")
+	n, err := strconv.Atoi(col)
+	if !found || err != nil || n < 1 {
+		return 0
+	}
+	return n - 1
+}
+
+func terminalWidth(fd int) int {
+	width, _, err := term.GetSize(fd)
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
+
+// remember adds a line to the history, skipping blanks and repeats of the
+// previous entry, and appends it to the history file
+func (e *Editor) remember(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == text {
+		return
+	}
+	e.history = append(e.history, text)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+	if e.historyPath == "" {
+		return
+	}
+	// History is best effort: a read-only home directory must not break input
+	if err := os.MkdirAll(filepath.Dir(e.historyPath), 0o700); err != nil {
+		return
+	}
+	file, err := os.OpenFile(e.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, encodeEntry(text))
+}
+
+// encodeEntry fits a history entry on one line of the history file.
+// Multi-line entries, and entries that would read back as one, are quoted.
+func encodeEntry(text string) string {
+	if strings.Contains(text, "\n") || strings.HasPrefix(text, `"`) {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// decodeEntry reverses encodeEntry; lines that are not valid quoted
+// strings are taken as written
+func decodeEntry(entry string) string {
+	if strings.HasPrefix(entry, `"`) {
+		if text, err := strconv.Unquote(entry); err == nil {
+			return text
+		}
+	}
+	return entry
+}
+
+// loadHistory reads the most recent entries of a history file and trims
+// the file when it has grown past MaxHistory
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, entry := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(entry) != "" {
+			history = append(history, decodeEntry(entry))
+		}
+	}
+	if len(history) > MaxHistory {
+		history = history[len(history)-MaxHistory:]
+		var b strings.Builder
+		for _, entry := range history {
+			b.WriteString(encodeEntry(entry) + "\n")
+		}
+		os.WriteFile(path, []byte(b.String()), 0o600)
+	}
+	return history
+}
+
+// line is the state of one ReadLine call
+type line struct {
+	e            *Editor
+	state        *term.State // Terminal state before raw mode, restored while an external editor runs
+	buf          []rune
+	pos          int
+	startCol     int // Column where input starts, after the caller's prompt
+	width        int
+	rows         int // Row of the cursor relative to the first input row
+	historyIndex int // len(history) while editing a new line
+	draft        []rune
+	killed       []rune // Last text removed with Ctrl+K, Ctrl+U or Ctrl+W, for Ctrl+Y
+}
+
+// Key codes
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyNewline   = 10 // Ctrl+J; Alt+Enter is decoded to it too
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlT     = 20
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyCtrlX     = 24
+	keyCtrlY     = 25
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// Named keys decoded from escape sequences
+const (
+	keyUp = iota + 0x110000
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyDeleteWordRight
+	keyPasteStart
+	keyUnknown
+)
+
+// pasteEnd closes a bracketed paste
+const pasteEnd = "\x1b[201~"
+
+// edit runs the key loop until the line is submitted or abandoned
+func (l *line) edit() (string, bool) {
+	for {
+		key, err := l.readKey()
+		if err != nil {
+			l.finish()
+			return "", false
+		}
+		switch key {
+		case keyEnter:
+			// More input already waiting means a paste without bracketed paste support
+			if l.e.reader.Buffered() > 0 {
+				l.insert('\n')
+				break
+			}
+			l.finish()
+			return string(l.buf), true
+		case keyNewline:
+			l.insert('\n')
+		case keyPasteStart:
+			l.paste()
+		case keyCtrlC:
+			l.finish()
+			return "", false
+		case keyCtrlD:
+			if len(l.buf) == 0 {
+				l.finish()
+				return "", false
+			}
+			l.deleteRange(l.pos, l.pos+1)
+		case keyCtrlX:
+			// Ctrl+X Ctrl+E edits the line in an external editor, as in bash
+			if next, err := l.readKey(); err == nil && next == keyCtrlE {
+				if text, ok := l.composeLine(); ok {
+					return text, true
+				}
+			}
+		case keyCtrlR:
+			if text, ok, submit := l.search(); ok {
+				l.buf = []rune(text)
+				l.pos = len(l.buf)
+				if submit {
+					l.redraw()
+					l.finish()
+					return text, true
+				}
+			}
+		default:
+			l.handle(key)
+		}
+		l.redraw()
+	}
+}
+
+// handle applies an editing key to the buffer
+func (l *line) handle(key rune) {
+	switch key {
+	case keyLeft, keyCtrlB:
+		l.pos = max(l.pos-1, 0)
+	case keyRight, keyCtrlF:
+		l.pos = min(l.pos+1, len(l.buf))
+	case keyHome, keyCtrlA:
+		l.pos = 0
+	case keyEnd, keyCtrlE:
+		l.pos = len(l.buf)
+	case keyWordLeft:
+		l.pos = l.wordStart()
+	case keyWordRight:
+		l.pos = l.wordEnd()
+	case keyBackspace, keyCtrlH:
+		l.deleteRange(l.pos-1, l.pos)
+	case keyDelete:
+		l.deleteRange(l.pos, l.pos+1)
+	case keyCtrlK:
+		l.kill(l.pos, len(l.buf))
+	case keyCtrlU:
+		l.kill(0, l.pos)
+	case keyCtrlW:
+		l.kill(l.wordStart(), l.pos)
+	case keyDeleteWordRight:
+		l.kill(l.pos, l.wordEnd())
+	case keyCtrlY:
+		l.insert(l.killed...)
+	case keyCtrlT:
+		if l.pos > 0 && len(l.buf) > 1 {
+			if l.pos == len(l.buf) {
+				l.pos--
+			}
+			l.buf[l.pos-1], l.buf[l.pos] = l.buf[l.pos], l.buf[l.pos-1]
+			l.pos++
+		}
+	case keyUp, keyCtrlP:
+		l.recall(l.historyIndex - 1)
+	case keyDown, keyCtrlN:
+		l.recall(l.historyIndex + 1)
+	case keyCtrlL:
+		// The caller's prompt is cleared too, so input restarts at the left edge
+		fmt.Fprint(l.e.out, "\x1b[H\x1b[2J")
+		l.rows, l.startCol = 0, 0
+	case keyTab:
+		l.insert(' ', ' ', ' ', ' ')
+	default:
+		if key < keyUp && unicode.IsPrint(key) {
+			l.insert(key)
+		}
+	}
+}
+
+func (l *line) insert(runes ...rune) {
+	buf := make([]rune, 0, len(l.buf)+len(runes))
+	buf = append(append(append(buf, l.buf[:l.pos]...), runes...), l.buf[l.pos:]...)
+	l.buf = buf
+	l.pos += len(runes)
+}
+
+func (l *line) deleteRange(from, to int) {
+	from, to = max(from, 0), min(to, len(l.buf))
+	if from >= to {
+		return
+	}
+	l.buf = append(l.buf[:from], l.buf[to:]...)
+	if l.pos > to {
+		l.pos -= to - from
+	} else if l.pos > from {
+		l.pos = from
+	}
+}
+
+// kill deletes a range and keeps it for Ctrl+Y
+func (l *line) kill(from, to int) {
+	if from < to {
+		l.killed = append([]rune{}, l.buf[from:to]...)
+		l.deleteRange(from, to)
+	}
+}
+
+func (l *line) wordStart() int {
+	i := l.pos
+	for i > 0 && unicode.IsSpace(l.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(l.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (l *line) wordEnd() int {
+	i := l.pos
+	for i < len(l.buf) && unicode.IsSpace(l.buf[i]) {
+		i++
+	}
+	for i < len(l.buf) && !unicode.IsSpace(l.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// recall replaces the buffer with history entry index; len(history) is the
+// line that was being typed before browsing started
+func (l *line) recall(index int) {
+	history := l.e.history
+	if index < 0 || index > len(history) || index == l.historyIndex {
+		return
+	}
+	if l.historyIndex == len(history) {
+		l.draft = append([]rune{}, l.buf...)
+	}
+	l.historyIndex = index
+	if index == len(history) {
+		l.buf = append([]rune{}, l.draft...)
+	} else {
+		l.buf = []rune(history[index])
+	}
+	l.pos = len(l.buf)
+}
+
+// search runs a reverse incremental history search (Ctrl+R). It returns
+// the chosen entry, whether one was chosen, and whether Enter submitted it.
+// Ctrl+R again finds an older match; Ctrl+G or Ctrl+C cancels.
+func (l *line) search() (string, bool, bool) {
+	original, originalPos := l.buf, l.pos
+	var query []rune
+	match, from := "", len(l.e.history)
+	find := func(before int) {
+		for i := min(before, len(l.e.history)) - 1; i >= 0; i-- {
+			if strings.Contains(l.e.history[i], string(query)) {
+				match, from = l.e.history[i], i
+				return
+			}
+		}
+	}
+	for {
+		l.buf = []rune(fmt.Sprintf("(reverse-i-search)`%s': %s", string(query), match))
+		l.pos = len(l.buf)
+		l.redraw()
+
+		key, err := l.readKey()
+		if err != nil {
+			l.buf, l.pos = original, originalPos
+			return "", false, false
+		}
+		switch {
+		case key == keyCtrlR:
+			find(from)
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match, from = "", len(l.e.history)
+				find(from)
+			}
+		case key == keyCtrlG || key == keyCtrlC:
+			l.buf, l.pos = original, originalPos
+			return "", false, false
+		case key == keyEnter || key == '\n':
+			return match, match != "", match != ""
+		case key < keyUp && unicode.IsPrint(key):
+			query = append(query, key)
+			match, from = "", len(l.e.history)
+			find(from)
+		default:
+			// Any other key accepts the match for editing
+			if match == "" {
+				l.buf, l.pos = original, originalPos
+				return "", false, false
+			}
+			return match, true, false
+		}
+	}
+}
+
+// readKey reads one key press, decoding escape sequences for arrows and
+// other named keys
+func (l *line) readKey() (rune, error) {
+	r, _, err := l.e.reader.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+	next, _, err := l.e.reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch next {
+	case 'b', 'B':
+		return keyWordLeft, nil
+	case 'f', 'F':
+		return keyWordRight, nil
+	case 'd', 'D':
+		return keyDeleteWordRight, nil
+	case keyBackspace:
+		return keyCtrlW, nil
+	case keyEnter:
+		return keyNewline, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+
+	// CSI: parameter bytes, then a final byte in @..~
+	var params []rune
+	for {
+		b, _, err := l.e.reader.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if b >= '@' && b <= '~' {
+			return csiKey(string(params), b), nil
+		}
+		params = append(params, b)
+	}
+}
+
+// csiKey maps a CSI sequence such as "1;5" + 'D' to a key
+func csiKey(params string, final rune) rune {
+	modified := strings.HasSuffix(params, ";5") || strings.HasSuffix(params, ";3")
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		if modified {
+			return keyWordRight
+		}
+		return keyRight
+	case 'D':
+		if modified {
+			return keyWordLeft
+		}
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		case "200":
+			return keyPasteStart
+		}
+	}
+	return keyUnknown
+}
+
+// paste inserts bracketed paste text up to its end marker. Line breaks
+// are kept as newlines and other control characters are dropped.
+func (l *line) paste() {
+	var text []rune
+	for {
+		r, _, err := l.e.reader.ReadRune()
+		if err != nil {
+			break
+		}
+		text = append(text, r)
+		if strings.HasSuffix(string(text), pasteEnd) {
+			text = text[:len(text)-len(pasteEnd)]
+			break
+		}
+	}
+	pasted := strings.ReplaceAll(strings.ReplaceAll(string(text), "\r\n", "\n"), "\r", "\n")
+	var runes []rune
+	for _, r := range pasted {
+		if r == '\n' || r == '\t' || unicode.IsPrint(r) {
+			runes = append(runes, r)
+		}
+	}
+	l.insert(runes...)
+}
+
+// position is the row and column, relative to the first input row, at
+// which the rune at index i of the buffer is drawn. It follows the same
+// rules as redraw: a newline or reaching the right edge starts a new row.
+func (l *line) position(i int) (row, col int) {
+	col = l.startCol
+	for _, r := range l.buf[:i] {
+		if r == '\n' {
+			row, col = row+1, 0
+			continue
+		}
+		if col++; col == l.width {
+			row, col = row+1, 0
+		}
+	}
+	return row, col
+}
+
+// redraw repaints the input after the prompt and places the cursor. Long
+// lines wrap and the input may hold newlines, so positions are tracked as
+// rows and columns from the start.
+func (l *line) redraw() {
+	var b strings.Builder
+	if l.rows > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", l.rows)
+	}
+	b.WriteString("\r")
+	if l.startCol > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", l.startCol)
+	}
+	b.WriteString("\x1b[J")
+
+	// Rows are broken explicitly; terminals differ in how they defer wrapping at the last column
+	col := l.startCol
+	for _, r := range l.buf {
+		if r == '\n' {
+			b.WriteString("\r\n")
+			col = 0
+			continue
+		}
+		b.WriteRune(r)
+		if col++; col == l.width {
+			b.WriteString("\r\n")
+			col = 0
+		}
+	}
+
+	endRow, _ := l.position(len(l.buf))
+	cursorRow, cursorCol := l.position(l.pos)
+	if up := endRow - cursorRow; up > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", up)
+	}
+	b.WriteString("\r")
+	if cursorCol > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", cursorCol)
+	}
+	l.rows = cursorRow
+	fmt.Fprint(l.e.out, b.String())
+}
+
+// finish moves the cursor below the input, leaving it on screen
+func (l *line) finish() {
+	endRow, _ := l.position(len(l.buf))
+	if down := endRow - l.rows; down > 0 {
+		fmt.Fprintf(l.e.out, "\x1b[%dB", down)
+	}
+	fmt.Fprint(l.e.out, "\r\n")
+}
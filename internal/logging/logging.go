@@ -0,0 +1,217 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/tools"
+)
+
+// maxLoggedOutput caps how much of each tool result a debug log holds
+const maxLoggedOutput = 8 * 1024
+
+// Discard is the logger used when logging is off
+var Discard = slog.New(slog.DiscardHandler)
+
+// Components that log through For
+const (
+	Agent    = "agent"    // API requests and responses
+	Tools    = "tools"    // Tool calls, their duration and outcome
+	Provider = "provider" // Requests translated for non-Anthropic providers
+	MCP      = "mcp"      // MCP servers
+	Plugin   = "plugin"   // Tool plugins
+	Session  = "session"  // Config, workspace, saved sessions and notifications
+	Server   = "server"   // Server mode: authentication and per-principal sessions
+	ACP      = "acp"      // Editors connected over the Agent Client Protocol
+)
+
+// Components lists every component, in the order they are documented
+var Components = []string{Agent, Tools, Provider, MCP, Plugin, Session, Server, ACP}
+
+// Options configure the central logger
+type Options struct {
+	Level      string            // debug, info, warn (the default) or error
+	Format     string            // text (the default) or json
+	File       string            // Appended to instead of stderr
+	Components map[string]string // Levels of single components, overriding Level
+}
+
+// Formats of log records
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel accepts debug, info, warn and error; "" is warn
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "", "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("log level must be debug, info, warn or error, not %q", name)
+}
+
+// Logger hands out per-component loggers that share one handler. Records
+// carry a component attribute, and each component may have its own level.
+type Logger struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level
+}
+
+// Open builds the central logger. Records are written to stderr, or
+// appended to opts.File, as text or JSON; close releases the file.
+func Open(opts Options) (logger *Logger, close func() error, err error) {
+	close = func() error { return nil }
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := &Logger{level: level, levels: map[string]slog.Level{}}
+	lowest := level
+	for _, component := range slices.Sorted(maps.Keys(opts.Components)) {
+		if !slices.Contains(Components, component) {
+			return nil, nil, fmt.Errorf("unknown log component %q (available: %s)", component, strings.Join(Components, ", "))
+		}
+		componentLevel, err := ParseLevel(opts.Components[component])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", component, err)
+		}
+		l.levels[component] = componentLevel
+		lowest = min(lowest, componentLevel)
+	}
+
+	var out io.Writer = os.Stderr
+	if opts.File != "" {
+		// Logs can hold file contents and command output, so only the user may read them
+		file, err := os.OpenFile(opts.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out, close = file, file.Close
+	}
+	handlerOpts := &slog.HandlerOptions{Level: lowest}
+	switch opts.Format {
+	case "", FormatText:
+		l.handler = slog.NewTextHandler(out, handlerOpts)
+	case FormatJSON:
+		l.handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		close()
+		return nil, nil, fmt.Errorf("log format must be text or json, not %q", opts.Format)
+	}
+	return l, close, nil
+}
+
+// For returns the logger of component
+func (l *Logger) For(component string) *slog.Logger {
+	level, ok := l.levels[component]
+	if !ok {
+		level = l.level
+	}
+	return slog.New(&levelFilter{
+		next:  l.handler.WithAttrs([]slog.Attr{slog.String("component", component)}),
+		level: level,
+	})
+}
+
+// levelFilter drops records below the level of one component
+type levelFilter struct {
+	next  slog.Handler
+	level slog.Level
+}
+
+func (f *levelFilter) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= f.level && f.next.Enabled(ctx, level)
+}
+
+func (f *levelFilter) Handle(ctx context.Context, record slog.Record) error {
+	return f.next.Handle(ctx, record)
+}
+
+func (f *levelFilter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelFilter{next: f.next.WithAttrs(attrs), level: f.level}
+}
+
+func (f *levelFilter) WithGroup(name string) slog.Handler {
+	return &levelFilter{next: f.next.WithGroup(name), level: f.level}
+}
+
+var (
+	mutex   sync.RWMutex
+	current = &Logger{handler: slog.NewTextHandler(os.Stderr, nil), level: slog.LevelWarn}
+)
+
+// Use makes l the logger of For
+func Use(l *Logger) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	current = l
+}
+
+// For returns the logger of component from the logger set with Use, or
+// one writing warnings and errors to stderr before then
+func For(component string) *slog.Logger {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return current.For(component)
+}
+
+// Middleware logs every tool call with its duration and outcome. At debug
+// level the full input and (capped) output are included as well.
+func Middleware(logger *slog.Logger) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			if toolCtx == nil || toolCtx.Tool == nil || !logger.Enabled(ctx, slog.LevelInfo) {
+				return next(ctx, toolCtx, input)
+			}
+			name := toolCtx.Tool.Name
+			logger.DebugContext(ctx, "tool call", "tool", name, "input", string(input))
+
+			start := time.Now()
+			result, err := next(ctx, toolCtx, input)
+			attrs := []any{"tool", name, "target", tools.SummarizeInput(input), "duration", time.Since(start)}
+			switch {
+			case err != nil:
+				// Failed calls are reported to the model, which usually recovers
+				logger.InfoContext(ctx, "tool failed", append(attrs, "error", err, "kind", tools.Classify(err))...)
+				return result, err
+			case result == nil:
+				logger.InfoContext(ctx, "tool done", attrs...)
+				return result, err
+			}
+
+			text := result.Text()
+			attrs = append(attrs, "is_error", result.IsError, "output_bytes", len(text))
+			if len(result.Metadata.FilesChanged) > 0 {
+				attrs = append(attrs, "files_changed", result.Metadata.FilesChanged)
+			}
+			logger.InfoContext(ctx, "tool done", attrs...)
+			logger.DebugContext(ctx, "tool output", "tool", name, "output", truncate(text))
+			return result, err
+		}
+	}
+}
+
+// truncate shortens text to maxLoggedOutput bytes, noting what was cut
+func truncate(text string) string {
+	if len(text) <= maxLoggedOutput {
+		return text
+	}
+	return text[:maxLoggedOutput] + fmt.Sprintf("... (%d more bytes)", len(text)-maxLoggedOutput)
+}
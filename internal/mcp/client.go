@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"agent/internal/jsonrpc"
+	"agent/internal/logging"
+	"agent/internal/tools"
+)
+
+// protocolVersion is the MCP revision this client implements
+const protocolVersion = "2025-03-26"
+
+// defaultTimeout applies when a server does not configure timeout_seconds
+const defaultTimeout = 60 * time.Second
+
+// Error message constants
+const (
+	errMsgConnectFailed = "failed to connect to MCP server %q: %w"
+	errMsgClosed        = "MCP server %q closed the connection"
+	errMsgRPCError      = "MCP server %q returned error %d: %s"
+	errMsgTimeout       = "MCP server %q did not respond to %s within %s"
+)
+
+// JSON-RPC error codes
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// transport moves JSON-RPC messages between the client and a server. Every
+// message received from the server is handed to the deliver callback passed
+// to connect, whichever way it arrived.
+type transport interface {
+	connect(ctx context.Context, deliver func(message []byte)) error
+	send(ctx context.Context, message []byte) error
+	done() <-chan struct{}
+	close() error
+}
+
+// message and rpcError are the JSON-RPC types shared with the other
+// connections
+type (
+	message  = jsonrpc.Message
+	rpcError = jsonrpc.Error
+)
+
+// Client is a connection to one MCP server
+type Client struct {
+	name      string
+	timeout   time.Duration
+	transport transport
+
+	calls jsonrpc.Calls
+
+	capabilities serverCapabilities
+}
+
+type serverCapabilities struct {
+	Tools     *struct{} `json:"tools"`
+	Resources *struct{} `json:"resources"`
+}
+
+// Connect opens the transport and performs the MCP initialize handshake
+func Connect(ctx context.Context, name string, t transport, timeout time.Duration) (*Client, error) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	c := &Client{
+		name:      name,
+		timeout:   timeout,
+		transport: t,
+	}
+	if err := t.connect(ctx, c.deliver); err != nil {
+		return nil, fmt.Errorf(errMsgConnectFailed, name, err)
+	}
+
+	var result struct {
+		Capabilities serverCapabilities `json:"capabilities"`
+	}
+	params := map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "billdozer", "version": "0.1.0"},
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		t.close()
+		return nil, fmt.Errorf(errMsgConnectFailed, name, err)
+	}
+	c.capabilities = result.Capabilities
+
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		t.close()
+		return nil, fmt.Errorf(errMsgConnectFailed, name, err)
+	}
+	return c, nil
+}
+
+// Name returns the configured server name
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Close shuts down the connection
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	send := func(request message) error {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		return c.transport.send(ctx, encoded)
+	}
+
+	reply, err := c.calls.Call(ctx, method, params, send, c.transport.done())
+	if err != nil {
+		if errors.Is(err, jsonrpc.ErrClosed) {
+			return fmt.Errorf(errMsgClosed, c.name)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return tools.Transient(fmt.Errorf(errMsgTimeout, c.name, method, c.timeout))
+		}
+		return err
+	}
+	if reply.Error != nil {
+		err := fmt.Errorf(errMsgRPCError, c.name, reply.Error.Code, reply.Error.Message)
+		if reply.Error.Code == codeInvalidParams {
+			return tools.InvalidInput(err)
+		}
+		return err
+	}
+	return reply.Decode(result)
+}
+
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	notification, err := jsonrpc.NewRequest(method, params)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return c.transport.send(ctx, encoded)
+}
+
+// deliver routes one incoming message. Responses go to the waiting caller
+// (late responses to cancelled calls are dropped); server requests are
+// answered so the server is never left waiting; notifications are ignored.
+func (c *Client) deliver(raw []byte) {
+	var msg message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		logging.For(logging.MCP).Warn("ignoring malformed message", "server", c.name, "error", err)
+		return
+	}
+
+	if msg.Method != "" {
+		if len(msg.ID) > 0 {
+			go c.answer(msg)
+		}
+		return
+	}
+	c.calls.Deliver(msg)
+}
+
+// answer replies to a request initiated by the server. Only ping is supported.
+func (c *Client) answer(req message) {
+	reply := message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &rpcError{Code: codeMethodNotFound, Message: "method not supported by client: " + req.Method}
+	}
+
+	encoded, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.transport.send(ctx, encoded)
+}
@@ -0,0 +1,310 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/tools"
+)
+
+const sessionHeader = "Mcp-Session-Id"
+
+// httpTransport implements the streamable HTTP transport: each message is
+// POSTed to the server URL, and the response body is either a single JSON
+// message or an SSE stream of messages
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	deliver func(message []byte)
+
+	mutex     sync.Mutex
+	sessionID string
+	finished  chan struct{}
+	closeOnce sync.Once
+}
+
+func newHTTPTransport(url string, headers map[string]string, client *http.Client) *httpTransport {
+	return &httpTransport{url: url, headers: headers, client: client, finished: make(chan struct{})}
+}
+
+func (t *httpTransport) connect(ctx context.Context, deliver func(message []byte)) error {
+	t.deliver = deliver
+	return nil
+}
+
+func (t *httpTransport) send(ctx context.Context, message []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	t.mutex.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(sessionHeader, t.sessionID)
+	}
+	t.mutex.Unlock()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return tools.Transient(err)
+	}
+	defer resp.Body.Close()
+
+	if err := statusError(resp); err != nil {
+		return err
+	}
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.mutex.Lock()
+		t.sessionID = id
+		t.mutex.Unlock()
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/event-stream":
+		return readEvents(resp.Body, func(event, data string) {
+			if event == "" || event == "message" {
+				t.deliver([]byte(data))
+			}
+		})
+	case "application/json":
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		deliverJSON(body, t.deliver)
+	}
+	return nil
+}
+
+func (t *httpTransport) done() <-chan struct{} {
+	return t.finished
+}
+
+func (t *httpTransport) close() error {
+	t.closeOnce.Do(func() {
+		close(t.finished)
+		t.mutex.Lock()
+		sessionID := t.sessionID
+		t.mutex.Unlock()
+		if sessionID == "" {
+			return
+		}
+		// Tell the server the session is over; failures are not interesting at shutdown
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set(sessionHeader, sessionID)
+		if resp, err := t.client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+	return nil
+}
+
+// sseTransport implements the older HTTP+SSE transport: the client holds a
+// GET event stream open, the server announces a message endpoint on it, and
+// responses to POSTed messages arrive as events on the stream
+type sseTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	endpoint string
+	cancel   context.CancelFunc
+	finished chan struct{}
+}
+
+func newSSETransport(url string, headers map[string]string, client *http.Client) *sseTransport {
+	return &sseTransport{url: url, headers: headers, client: client, finished: make(chan struct{})}
+}
+
+func (t *sseTransport) connect(ctx context.Context, deliver func(message []byte)) error {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, t.url, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		cancel()
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return fmt.Errorf("HTTP %d opening event stream", resp.StatusCode)
+	}
+
+	endpoints := make(chan string, 1)
+	go func() {
+		defer close(t.finished)
+		defer resp.Body.Close()
+		readEvents(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				select {
+				case endpoints <- data:
+				default:
+				}
+			case "", "message":
+				deliver([]byte(data))
+			}
+		})
+	}()
+
+	select {
+	case endpoint := <-endpoints:
+		resolved, err := resolveEndpoint(t.url, endpoint)
+		if err != nil {
+			cancel()
+			return err
+		}
+		t.endpoint = resolved
+		return nil
+	case <-t.finished:
+		cancel()
+		return errors.New("event stream closed before the server announced its endpoint")
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+}
+
+// resolveEndpoint resolves the endpoint an SSE server announces against
+// the URL of its event stream. Messages carry the configured headers, which
+// may hold credentials, so the endpoint must be on the same scheme and host.
+func resolveEndpoint(streamURL, endpoint string) (string, error) {
+	base, err := url.Parse(streamURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	resolved := base.ResolveReference(ref)
+	if !strings.EqualFold(resolved.Scheme, base.Scheme) || !strings.EqualFold(resolved.Host, base.Host) {
+		return "", fmt.Errorf("the server announced endpoint %s, which is not on %s://%s", resolved.Redacted(), base.Scheme, base.Host)
+	}
+	return resolved.String(), nil
+}
+
+func (t *sseTransport) send(ctx context.Context, message []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return tools.Transient(err)
+	}
+	defer resp.Body.Close()
+	return statusError(resp)
+}
+
+func (t *sseTransport) done() <-chan struct{} {
+	return t.finished
+}
+
+func (t *sseTransport) close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return nil
+}
+
+// statusError converts a non-2xx response into an error. Rate limiting and
+// server errors are marked transient so read-only calls are retried.
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	err := fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return tools.Transient(err)
+	}
+	return err
+}
+
+// readEvents parses a server-sent event stream, calling fn for each event
+func readEvents(r io.Reader, fn func(event, data string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// Comment or keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(data) > 0 {
+		fn(event, strings.Join(data, "\n"))
+	}
+	return scanner.Err()
+}
+
+// deliverJSON hands a JSON message, or each message of a JSON batch, to deliver
+func deliverJSON(body []byte, deliver func(message []byte)) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return
+	}
+	if body[0] != '[' {
+		deliver(body)
+		return
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		deliver(body)
+		return
+	}
+	for _, msg := range batch {
+		deliver(msg)
+	}
+}
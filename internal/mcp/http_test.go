@@ -0,0 +1,30 @@
+package mcp
+
+import "testing"
+
+func TestResolveEndpoint(t *testing.T) {
+	const stream = "https://mcp.example.com/sse"
+	tests := []struct {
+		endpoint, want string
+	}{
+		{"/messages?session=1", "https://mcp.example.com/messages?session=1"},
+		{"messages", "https://mcp.example.com/messages"},
+		{"https://MCP.example.com/messages", "https://MCP.example.com/messages"},
+		{"https://attacker.example.net/collect", ""},
+		{"//attacker.example.net/collect", ""},
+		{"http://mcp.example.com/messages", ""},
+		{"https://mcp.example.com:8443/messages", ""},
+	}
+	for _, tt := range tests {
+		got, err := resolveEndpoint(stream, tt.endpoint)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("resolveEndpoint(%q) = %q, want an error", tt.endpoint, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveEndpoint(%q) = %q, %v; want %q", tt.endpoint, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/tools"
+)
+
+// LoadAll connects to every configured MCP server and registers its tools.
+// HTTP and SSE servers are reached through httpClient. On error, servers
+// that were already connected are closed before returning.
+func LoadAll(ctx context.Context, servers []config.MCPServerConfig, registry *tools.Registry, httpClient *http.Client) ([]*Client, error) {
+	var clients []*Client
+	fail := func(err error) ([]*Client, error) {
+		CloseAll(clients)
+		return nil, err
+	}
+
+	for _, cfg := range servers {
+		t, err := newTransport(cfg, httpClient)
+		if err != nil {
+			return fail(err)
+		}
+
+		client, err := Connect(ctx, cfg.Name, t, time.Duration(cfg.TimeoutSeconds)*time.Second)
+		if err != nil {
+			return fail(err)
+		}
+		clients = append(clients, client)
+
+		defs, err := client.Definitions(ctx)
+		if err != nil {
+			return fail(fmt.Errorf("MCP server %q: %w", cfg.Name, err))
+		}
+		for _, def := range defs {
+			if err := registry.Register(def); err != nil {
+				return fail(fmt.Errorf("MCP server %q: %w", cfg.Name, err))
+			}
+		}
+	}
+	return clients, nil
+}
+
+// CloseAll closes the given connections
+func CloseAll(clients []*Client) {
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+// newTransport picks the transport for a server. It defaults to stdio when a
+// command is given and to streamable HTTP when a URL is given.
+func newTransport(cfg config.MCPServerConfig, httpClient *http.Client) (transport, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("MCP server with command %v and url %q has no name", cfg.Command, cfg.URL)
+	}
+
+	kind := cfg.Transport
+	if kind == "" {
+		kind = "stdio"
+		if cfg.URL != "" {
+			kind = "http"
+		}
+	}
+
+	switch kind {
+	case "stdio":
+		if len(cfg.Command) == 0 {
+			return nil, fmt.Errorf("MCP server %q: stdio transport needs a command", cfg.Name)
+		}
+		return newStdioTransport(cfg.Command, cfg.Env), nil
+	case "http", "sse":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("MCP server %q: %s transport needs a url", cfg.Name, kind)
+		}
+		if kind == "sse" {
+			return newSSETransport(cfg.URL, cfg.Headers, httpClient), nil
+		}
+		return newHTTPTransport(cfg.URL, cfg.Headers, httpClient), nil
+	}
+	return nil, fmt.Errorf("MCP server %q: unknown transport %q (expected stdio, http or sse)", cfg.Name, kind)
+}
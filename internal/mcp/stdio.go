@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// stdioTransport runs the server as a subprocess and exchanges
+// newline-delimited JSON-RPC messages over its stdin and stdout
+type stdioTransport struct {
+	command []string
+	env     []string
+
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	writeMutex sync.Mutex
+	finished   chan struct{}
+}
+
+func newStdioTransport(command, env []string) *stdioTransport {
+	return &stdioTransport{command: command, env: env, finished: make(chan struct{})}
+}
+
+func (t *stdioTransport) connect(ctx context.Context, deliver func(message []byte)) error {
+	if len(t.command) == 0 {
+		return errors.New("empty command")
+	}
+
+	t.cmd = exec.Command(t.command[0], t.command[1:]...)
+	t.cmd.Env = append(os.Environ(), t.env...)
+	t.cmd.Stderr = os.Stderr
+
+	stdin, err := t.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := t.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := t.cmd.Start(); err != nil {
+		return err
+	}
+	t.stdin = stdin
+
+	go func() {
+		defer close(t.finished)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			deliver(append([]byte(nil), scanner.Bytes()...))
+		}
+	}()
+	return nil
+}
+
+func (t *stdioTransport) send(ctx context.Context, message []byte) error {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	_, err := t.stdin.Write(append(message, '\n'))
+	return err
+}
+
+func (t *stdioTransport) done() <-chan struct{} {
+	return t.finished
+}
+
+func (t *stdioTransport) close() error {
+	if t.cmd == nil || t.cmd.Process == nil {
+		return nil
+	}
+	t.stdin.Close()
+	select {
+	case <-t.finished:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+	}
+	return t.cmd.Wait()
+}
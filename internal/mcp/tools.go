@@ -0,0 +1,263 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/internal/schema"
+	"agent/internal/tools"
+)
+
+// Tool is a tool advertised by an MCP server
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Annotations struct {
+		ReadOnlyHint bool `json:"readOnlyHint"`
+	} `json:"annotations"`
+}
+
+// Resource is a resource advertised by an MCP server
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+// content is one block of a tools/call result or resources/read result
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Data     string `json:"data"`
+	MimeType string `json:"mimeType"`
+	URI      string `json:"uri"`
+	Blob     string `json:"blob"`
+	Resource *struct {
+		URI      string `json:"uri"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Blob     string `json:"blob"`
+	} `json:"resource"`
+}
+
+// ListTools returns every tool the server offers, following pagination
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", pageParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ListResources returns every resource the server offers, following pagination
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var page struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "resources/list", pageParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Resources...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool invokes a tool on the server
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*tools.ToolResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	var result struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	params := map[string]any{"name": name, "arguments": arguments}
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+
+	converted, err := toToolResult(result.Content)
+	if err != nil {
+		return nil, err
+	}
+	converted.IsError = result.IsError
+	return converted, nil
+}
+
+// ReadResource fetches the contents of a resource
+func (c *Client) ReadResource(ctx context.Context, uri string) (*tools.ToolResult, error) {
+	var result struct {
+		Contents []content `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]any{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+
+	// Resource contents carry text or blob directly rather than a type field
+	for i := range result.Contents {
+		if result.Contents[i].Blob != "" && strings.HasPrefix(result.Contents[i].MimeType, "image/") {
+			result.Contents[i].Type, result.Contents[i].Data = "image", result.Contents[i].Blob
+		} else if result.Contents[i].Blob != "" {
+			result.Contents[i].Type = "resource_link"
+		} else {
+			result.Contents[i].Type = "text"
+		}
+	}
+	return toToolResult(result.Contents)
+}
+
+func pageParams(cursor string) any {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]any{"cursor": cursor}
+}
+
+func toToolResult(blocks []content) (*tools.ToolResult, error) {
+	result := &tools.ToolResult{}
+	addText := func(text string) {
+		result.Content = append(result.Content, tools.ContentBlock{Type: tools.ContentText, Text: text})
+	}
+
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			addText(block.Text)
+		case "image":
+			data, err := base64.StdEncoding.DecodeString(block.Data)
+			if err != nil {
+				return nil, fmt.Errorf("MCP server returned invalid image data: %w", err)
+			}
+			result.Content = append(result.Content, tools.ContentBlock{Type: tools.ContentImage, MediaType: block.MimeType, Data: data})
+		case "resource":
+			if block.Resource != nil && block.Resource.Text != "" {
+				addText(block.Resource.Text)
+			} else if block.Resource != nil {
+				addText(fmt.Sprintf("[binary resource %s (%s)]", block.Resource.URI, block.Resource.MimeType))
+			}
+		case "resource_link":
+			addText(fmt.Sprintf("[resource %s]", block.URI))
+		default:
+			addText(fmt.Sprintf("[unsupported %s content]", block.Type))
+		}
+	}
+	return result, nil
+}
+
+// Definitions imports the server's tools (and, if it offers resources, tools
+// to list and read them) as registry definitions. Tool names are prefixed
+// with the server name so servers cannot shadow each other or built-in
+// tools, and each tool is placed in a group named after the server.
+func (c *Client) Definitions(ctx context.Context) ([]tools.ToolDefinition, error) {
+	var defs []tools.ToolDefinition
+
+	if c.capabilities.Tools != nil {
+		serverTools, err := c.ListTools(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, tool := range serverTools {
+			inputSchema, err := schema.FromJSON(tool.InputSchema)
+			if err != nil {
+				return nil, fmt.Errorf("MCP server %q tool %q: invalid inputSchema: %w", c.name, tool.Name, err)
+			}
+
+			remoteName := tool.Name
+			defs = append(defs, tools.ToolDefinition{
+				Name:        c.name + "_" + tool.Name,
+				Description: tool.Description,
+				Group:       c.name,
+				InputSchema: inputSchema,
+				Mutating:    !tool.Annotations.ReadOnlyHint,
+				Function: func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+					return c.CallTool(ctx, remoteName, input)
+				},
+			})
+		}
+	}
+
+	if c.capabilities.Resources != nil {
+		defs = append(defs, c.resourceDefinitions()...)
+	}
+	return defs, nil
+}
+
+// ReadResourceInput is the input for the <server>_read_resource tool
+type ReadResourceInput struct {
+	URI string `json:"uri" jsonschema:"required" jsonschema_description:"URI of the resource, as returned by the list resources tool"`
+}
+
+type listResourcesInput struct{}
+
+func (c *Client) resourceDefinitions() []tools.ToolDefinition {
+	return []tools.ToolDefinition{
+		{
+			Name:        c.name + "_list_resources",
+			Description: fmt.Sprintf("List the resources (documents, records, files) available from the %s MCP server.", c.name),
+			Group:       c.name,
+			InputSchema: schema.GenerateSchema[listResourcesInput](),
+			Function: func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+				resources, err := c.ListResources(ctx)
+				if err != nil {
+					return nil, err
+				}
+				if len(resources) == 0 {
+					return tools.NewTextResult("No resources available"), nil
+				}
+
+				var b strings.Builder
+				for _, r := range resources {
+					fmt.Fprintf(&b, "%s", r.URI)
+					if r.Name != "" {
+						fmt.Fprintf(&b, " - %s", r.Name)
+					}
+					if r.MimeType != "" {
+						fmt.Fprintf(&b, " (%s)", r.MimeType)
+					}
+					if r.Description != "" {
+						fmt.Fprintf(&b, ": %s", r.Description)
+					}
+					b.WriteString("\n")
+				}
+				return tools.NewTextResult(b.String()), nil
+			},
+		},
+		{
+			Name:        c.name + "_read_resource",
+			Description: fmt.Sprintf("Read a resource from the %s MCP server by URI.", c.name),
+			Group:       c.name,
+			InputSchema: schema.GenerateSchema[ReadResourceInput](),
+			Function: func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+				var readInput ReadResourceInput
+				if err := json.Unmarshal(input, &readInput); err != nil {
+					return nil, fmt.Errorf("invalid JSON input: %w", err)
+				}
+				return c.ReadResource(ctx, readInput.URI)
+			},
+		},
+	}
+}
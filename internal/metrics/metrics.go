@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"agent/internal/tools"
+)
+
+// Recorder collects per-tool call counts, failures and latencies for the
+// session, along with the files the tools changed and the commands they
+// ran. A nil *Recorder is valid and records nothing.
+type Recorder struct {
+	mutex    sync.Mutex
+	tools    map[string]*toolStats
+	files    map[string]FileChange
+	commands []string
+}
+
+type toolStats struct {
+	calls     int
+	errors    int
+	durations []time.Duration
+}
+
+// ToolSummary is the aggregated view of one tool's calls
+type ToolSummary struct {
+	Name   string
+	Calls  int
+	Errors int
+	Total  time.Duration
+	P50    time.Duration
+	P95    time.Duration
+	Max    time.Duration
+}
+
+// ErrorRate returns the fraction of calls that failed
+func (s ToolSummary) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// New creates an empty recorder
+func New() *Recorder {
+	return &Recorder{tools: make(map[string]*toolStats), files: make(map[string]FileChange)}
+}
+
+// Record adds one call to the tool's statistics
+func (r *Recorder) Record(tool string, duration time.Duration, failed bool) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats, ok := r.tools[tool]
+	if !ok {
+		stats = &toolStats{}
+		r.tools[tool] = stats
+	}
+	stats.calls++
+	if failed {
+		stats.errors++
+	}
+	stats.durations = append(stats.durations, duration)
+}
+
+// Summary returns per-tool statistics, busiest tools (by total time) first
+func (r *Recorder) Summary() []ToolSummary {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	summaries := make([]ToolSummary, 0, len(r.tools))
+	for name, stats := range r.tools {
+		sorted := append([]time.Duration(nil), stats.durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		var total time.Duration
+		for _, d := range sorted {
+			total += d
+		}
+		summaries = append(summaries, ToolSummary{
+			Name:   name,
+			Calls:  stats.calls,
+			Errors: stats.errors,
+			Total:  total,
+			P50:    percentile(sorted, 50),
+			P95:    percentile(sorted, 95),
+			Max:    sorted[len(sorted)-1],
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Total != summaries[j].Total {
+			return summaries[i].Total > summaries[j].Total
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// WriteTable prints the summary as an aligned table
+func (r *Recorder) WriteTable(w io.Writer) {
+	summaries := r.Summary()
+	if len(summaries) == 0 {
+		fmt.Fprintln(w, "No tool calls yet")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Tool\tCalls\tErrors\tError rate\tTotal\tp50\tp95\tMax\t")
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f%%\t%s\t%s\t%s\t%s\t\n",
+			s.Name, s.Calls, s.Errors, s.ErrorRate()*100,
+			round(s.Total), round(s.P50), round(s.P95), round(s.Max))
+	}
+	tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// Middleware records every tool call that reaches the chain. A call counts as
+// failed when it returns an error or an error result.
+func Middleware(r *Recorder) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, toolCtx, input)
+			if toolCtx != nil && toolCtx.Tool != nil {
+				r.Record(toolCtx.Tool.Name, time.Since(start), err != nil || (result != nil && result.IsError))
+			}
+			if result != nil {
+				r.RecordEffects(result.Metadata)
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"agent/internal/tools"
+	"agent/internal/usage"
+)
+
+// FileChange is what a session did to a file, all calls considered
+type FileChange string
+
+const (
+	FileCreated  FileChange = "created"
+	FileModified FileChange = "modified"
+	FileDeleted  FileChange = "deleted"
+)
+
+// maxListed caps how many files and commands the printed report names
+const maxListed = 20
+
+// RecordEffects adds the files a tool call changed and the commands it ran.
+// A file created and later deleted in the same session is forgotten; one
+// deleted and then written again counts as modified.
+func (r *Recorder) RecordEffects(metadata tools.ResultMetadata) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, path := range metadata.FilesChanged {
+		change := FileModified
+		switch {
+		case slices.Contains(metadata.FilesCreated, path):
+			change = FileCreated
+		case slices.Contains(metadata.FilesDeleted, path):
+			change = FileDeleted
+		}
+		switch previous, seen := r.files[path]; {
+		case !seen:
+			r.files[path] = change
+		case previous == FileCreated && change == FileDeleted:
+			delete(r.files, path)
+		case previous == FileCreated:
+		case previous == FileDeleted && change == FileCreated:
+			r.files[path] = FileModified
+		default:
+			r.files[path] = change
+		}
+	}
+	r.commands = append(r.commands, metadata.Commands...)
+}
+
+// Report summarizes what a session did; it is printed on exit and kept
+// with the saved session
+type Report struct {
+	Started      time.Time   `json:"started"`
+	DurationMS   int64       `json:"duration_ms"`
+	Turns        int         `json:"turns"` // Model responses
+	InputTokens  int64       `json:"input_tokens"`
+	OutputTokens int64       `json:"output_tokens"`
+	Cost         float64     `json:"cost_usd"`
+	Unpriced     bool        `json:"unpriced,omitempty"` // Some usage was for a model without a known price
+	Tools        []ToolCount `json:"tools,omitempty"`
+	Created      []string    `json:"created,omitempty"`
+	Modified     []string    `json:"modified,omitempty"`
+	Deleted      []string    `json:"deleted,omitempty"`
+	Commands     []string    `json:"commands,omitempty"`
+}
+
+// ToolCount is how often one tool was called in a Report
+type ToolCount struct {
+	Name   string `json:"name"`
+	Calls  int    `json:"calls"`
+	Errors int    `json:"errors,omitempty"`
+}
+
+// Report fills in the tool calls, file changes and commands of report,
+// whose timing and usage the caller sets
+func (r *Recorder) Report(report Report) Report {
+	summaries := r.Summary()
+	slices.SortStableFunc(summaries, func(a, b ToolSummary) int { return b.Calls - a.Calls })
+	for _, s := range summaries {
+		report.Tools = append(report.Tools, ToolCount{Name: s.Name, Calls: s.Calls, Errors: s.Errors})
+	}
+	if r == nil {
+		return report
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, path := range slices.Sorted(maps.Keys(r.files)) {
+		switch r.files[path] {
+		case FileCreated:
+			report.Created = append(report.Created, path)
+		case FileModified:
+			report.Modified = append(report.Modified, path)
+		case FileDeleted:
+			report.Deleted = append(report.Deleted, path)
+		}
+	}
+	report.Commands = append([]string(nil), r.commands...)
+	return report
+}
+
+// Duration returns how long the session ran
+func (r Report) Duration() time.Duration {
+	return time.Duration(r.DurationMS) * time.Millisecond
+}
+
+// Calls returns the number of tool calls
+func (r Report) Calls() int {
+	calls := 0
+	for _, tool := range r.Tools {
+		calls += tool.Calls
+	}
+	return calls
+}
+
+// Write prints the report
+func (r Report) Write(w io.Writer) {
+	cost := usage.Totals{Cost: r.Cost, Unpriced: r.Unpriced}.CostText()
+	fmt.Fprintf(w, "Duration:  %s\n", r.Duration().Round(time.Second))
+	fmt.Fprintf(w, "Turns:     %d (%s tokens in, %s out, %s)\n", r.Turns, usage.Tokens(r.InputTokens), usage.Tokens(r.OutputTokens), cost)
+
+	var counts []string
+	for _, tool := range r.Tools {
+		counts = append(counts, fmt.Sprintf("%s %d", tool.Name, tool.Calls))
+	}
+	if len(counts) == 0 {
+		counts = []string{"none"}
+	}
+	fmt.Fprintf(w, "Tools:     %d calls: %s\n", r.Calls(), strings.Join(counts, ", "))
+
+	fmt.Fprintf(w, "Files:     %d created, %d modified, %d deleted\n", len(r.Created), len(r.Modified), len(r.Deleted))
+	var files []string
+	for _, group := range []struct {
+		mark  string
+		paths []string
+	}{{"+", r.Created}, {"~", r.Modified}, {"-", r.Deleted}} {
+		for _, path := range group.paths {
+			files = append(files, group.mark+" "+path)
+		}
+	}
+	writeList(w, files)
+
+	fmt.Fprintf(w, "Commands:  %d run\n", len(r.Commands))
+	writeList(w, r.Commands)
+}
+
+// writeList prints indented lines, up to maxListed of them
+func writeList(w io.Writer, lines []string) {
+	for i, line := range lines {
+		if i == maxListed {
+			fmt.Fprintf(w, "           … and %d more\n", len(lines)-maxListed)
+			return
+		}
+		fmt.Fprintf(w, "           %s\n", line)
+	}
+}
@@ -0,0 +1,149 @@
+// Package mockapi stands in for the Anthropic Messages API so the agent
+// loop and tools can run offline and deterministically. A Recorder saves
+// real exchanges to a fixture file; a Replayer serves a fixture, or a
+// Script of canned turns, back in order.
+package mockapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// Exchange is one recorded API call. Fixture files hold one exchange per line.
+type Exchange struct {
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Load reads the exchanges of a fixture file
+func Load(path string) ([]Exchange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixture: %w", err)
+	}
+	defer file.Close()
+
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	return exchanges, nil
+}
+
+// Replayer is an http.RoundTripper that answers each request with the next
+// exchange, ignoring what was asked. Requests it received are kept for
+// inspection, e.g. to check the tool results the agent sent back.
+type Replayer struct {
+	mutex     sync.Mutex
+	exchanges []Exchange
+	next      int
+	requests  []json.RawMessage
+}
+
+// NewReplayer serves exchanges in order
+func NewReplayer(exchanges []Exchange) *Replayer {
+	return &Replayer{exchanges: exchanges}
+}
+
+// RoundTrip implements http.RoundTripper. Once the exchanges run out every
+// request fails, so a test that makes more calls than it scripted stops.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests = append(r.requests, body)
+	if r.next >= len(r.exchanges) {
+		return nil, fmt.Errorf("mockapi: unexpected request %d to %s; only %d exchanges recorded", r.next+1, req.URL.Path, len(r.exchanges))
+	}
+	exchange := r.exchanges[r.next]
+	r.next++
+
+	status := exchange.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	// Responses that were not JSON, such as streams, are recorded as strings
+	body = exchange.Response
+	var text string
+	if json.Unmarshal(exchange.Response, &text) == nil {
+		body = []byte(text)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Requests returns the bodies of the requests received so far
+func (r *Replayer) Requests() []json.RawMessage {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]json.RawMessage(nil), r.requests...)
+}
+
+// Remaining reports how many exchanges have not been served
+func (r *Replayer) Remaining() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.exchanges) - r.next
+}
+
+// Client returns an Anthropic client whose requests are answered by
+// transport. Retries are off so every request uses up one exchange.
+func Client(transport http.RoundTripper) *anthropic.Client {
+	client := anthropic.NewClient(
+		option.WithAPIKey("mockapi"),
+		option.WithHTTPClient(&http.Client{Transport: transport}),
+		option.WithMaxRetries(0),
+	)
+	return &client
+}
+
+// readBody returns the request body and leaves it readable for the next handler
+func readBody(req *http.Request) (json.RawMessage, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("mockapi: failed to read request: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
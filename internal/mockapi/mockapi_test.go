@@ -0,0 +1,136 @@
+package mockapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+
+	"agent/internal/agent"
+	"agent/internal/mockapi"
+	"agent/internal/tools"
+	"agent/internal/tools/file"
+	"agent/internal/workspace"
+)
+
+const mainGo = "package main\n\nfunc main() {}\n"
+
+// runAgent runs one prompt through the agent with read_file in a workspace
+// holding main.go, and returns the agent's last reply
+func runAgent(t *testing.T, client *anthropic.Client) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(mainGo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := workspace.New(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registry tools.Registry
+	registry.RegisterTool(file.ReadFileTool{})
+
+	prompts := []string{"What does main.go do?"}
+	input := func() (string, bool) {
+		if len(prompts) == 0 {
+			return "", false
+		}
+		prompt := prompts[0]
+		prompts = prompts[1:]
+		return prompt, true
+	}
+	reply := ""
+	a := agent.NewAgent(client, input, &registry, agent.WithWorkspace(ws), agent.WithQuiet(true),
+		agent.WithReply(func(text string) { reply = text }))
+	if err := a.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return reply
+}
+
+// toolResult returns the tool result block of a request's last message
+func toolResult(t *testing.T, request json.RawMessage) (id, content string) {
+	t.Helper()
+	var params struct {
+		Messages []struct {
+			Content []struct {
+				Type      string `json:"type"`
+				ToolUseID string `json:"tool_use_id"`
+				Content   []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(request, &params); err != nil {
+		t.Fatal(err)
+	}
+	last := params.Messages[len(params.Messages)-1]
+	for _, block := range last.Content {
+		if block.Type == "tool_result" && len(block.Content) > 0 {
+			return block.ToolUseID, block.Content[0].Text
+		}
+	}
+	t.Fatalf("the last message of %s holds no tool result", request)
+	return "", ""
+}
+
+func TestScriptRunsToolCalls(t *testing.T) {
+	api := mockapi.Script(
+		mockapi.CallTool("read_file", map[string]any{"path": "main.go"}),
+		mockapi.Reply("main.go defines an empty main function."),
+	)
+	if reply := runAgent(t, mockapi.Client(api)); reply != "main.go defines an empty main function." {
+		t.Errorf("reply = %q", reply)
+	}
+	if api.Remaining() != 0 {
+		t.Errorf("%d scripted turns were not requested", api.Remaining())
+	}
+
+	requests := api.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("the agent made %d requests, want 2", len(requests))
+	}
+	id, content := toolResult(t, requests[1])
+	if id != "toolu_mock_1" || content != mainGo {
+		t.Errorf("tool result for %s is %q, want main.go for toolu_mock_1", id, content)
+	}
+}
+
+func TestFixtureReplaysRecording(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.jsonl")
+	recorder, err := mockapi.Record(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := mockapi.Script(
+		mockapi.CallTool("read_file", map[string]any{"path": "main.go"}),
+		mockapi.Reply("Done."),
+	)
+	recorded := anthropic.NewClient(option.WithAPIKey("mockapi"), option.WithMaxRetries(0),
+		option.WithHTTPClient(&http.Client{Transport: api}), option.WithMiddleware(recorder.Middleware()))
+	runAgent(t, &recorded)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exchanges, err := mockapi.Load(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("recorded %d exchanges, want 2", len(exchanges))
+	}
+	replayer := mockapi.NewReplayer(exchanges)
+	if reply := runAgent(t, mockapi.Client(replayer)); reply != "Done." {
+		t.Errorf("replayed reply = %q", reply)
+	}
+	if id, content := toolResult(t, replayer.Requests()[1]); id != "toolu_mock_1" || content != mainGo {
+		t.Errorf("replayed tool result for %s is %q", id, content)
+	}
+}
@@ -0,0 +1,85 @@
+package mockapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// Recorder appends every API exchange that passes through its middleware
+// to a fixture file, for a Replayer to serve later
+type Recorder struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Record creates a fixture file, truncating any existing content
+func Record(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fixture: %w", err)
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Close closes the fixture file
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// Middleware records each request and its response. Responses the SDK
+// retries (408, 409, 429 and 5xx) are left out, so a replay without
+// retries sees only the attempt that counted.
+func (r *Recorder) Middleware() option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		body, err := readBody(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := next(req)
+		if err != nil || retried(resp.StatusCode) {
+			return resp, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("mockapi: failed to read response: %w", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+
+		exchange := Exchange{Method: req.Method, Path: req.URL.Path, Status: resp.StatusCode, Response: data}
+		if json.Valid(body) {
+			exchange.Request = body
+		}
+		if !json.Valid(data) {
+			exchange.Response, _ = json.Marshal(string(data))
+		}
+		if err := r.append(exchange); err != nil {
+			return nil, fmt.Errorf("mockapi: failed to record exchange: %w", err)
+		}
+		return resp, nil
+	}
+}
+
+func (r *Recorder) append(exchange Exchange) error {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, err = r.file.Write(append(line, '\n'))
+	return err
+}
+
+// retried reports whether the SDK retries a response with status
+func retried(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusConflict ||
+		status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,100 @@
+package mockapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Turn is one canned model response
+type Turn struct {
+	content    []map[string]any
+	stopReason string
+	status     int
+}
+
+// Reply is a turn in which the model answers with text
+func Reply(text string) Turn {
+	return Turn{stopReason: "end_turn"}.Say(text)
+}
+
+// CallTool is a turn in which the model calls a tool with input, which is
+// marshaled to JSON. Chain calls with Call to request several at once.
+func CallTool(name string, input any) Turn {
+	return Turn{stopReason: "tool_use"}.Call(name, input)
+}
+
+// Fail is a turn in which the API answers with an HTTP error, e.g.
+// Fail(http.StatusTooManyRequests, "rate_limit_error")
+func Fail(status int, errorType string) Turn {
+	return Turn{status: status, stopReason: errorType}
+}
+
+// Say adds a text block to the turn
+func (t Turn) Say(text string) Turn {
+	t.content = append(t.content, map[string]any{"type": "text", "text": text})
+	return t
+}
+
+// Call adds a tool call to the turn and makes it stop for tool results
+func (t Turn) Call(name string, input any) Turn {
+	if input == nil {
+		input = map[string]any{}
+	}
+	t.content = append(t.content, map[string]any{"type": "tool_use", "name": name, "input": input})
+	t.stopReason = "tool_use"
+	return t
+}
+
+// Script returns a Replayer that answers requests with turns, in order.
+// Tool call IDs are numbered toolu_mock_1, toolu_mock_2, ... across the
+// script, so tests can match tool results to calls.
+func Script(turns ...Turn) *Replayer {
+	exchanges := make([]Exchange, 0, len(turns))
+	calls := 0
+	for i, turn := range turns {
+		if turn.status != 0 {
+			exchanges = append(exchanges, Exchange{
+				Method:   http.MethodPost,
+				Path:     "/v1/messages",
+				Status:   turn.status,
+				Response: mustMarshal(map[string]any{"type": "error", "error": map[string]any{"type": turn.stopReason, "message": "scripted failure"}}),
+			})
+			continue
+		}
+		content := make([]map[string]any, len(turn.content))
+		for j, block := range turn.content {
+			content[j] = block
+			if block["type"] == "tool_use" {
+				calls++
+				content[j] = map[string]any{"type": "tool_use", "id": fmt.Sprintf("toolu_mock_%d", calls), "name": block["name"], "input": block["input"]}
+			}
+		}
+		exchanges = append(exchanges, Exchange{
+			Method: http.MethodPost,
+			Path:   "/v1/messages",
+			Status: http.StatusOK,
+			Response: mustMarshal(map[string]any{
+				"id":            fmt.Sprintf("msg_mock_%d", i+1),
+				"type":          "message",
+				"role":          "assistant",
+				"model":         "mock",
+				"content":       content,
+				"stop_reason":   turn.stopReason,
+				"stop_sequence": nil,
+				"usage":         map[string]any{"input_tokens": 10, "output_tokens": 10},
+			}),
+		})
+	}
+	return NewReplayer(exchanges)
+}
+
+// mustMarshal marshals values built by this package, which cannot fail
+// unless a tool input given to CallTool cannot be marshaled
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("mockapi: %v", err))
+	}
+	return data
+}
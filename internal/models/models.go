@@ -0,0 +1,151 @@
+// Package models describes what each model can do and what it costs: its
+// context window, output limit, features and price. Built-in entries cover
+// the Claude models; model_info in the config corrects them or adds others
+// without a new release.
+package models
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"agent/internal/config"
+)
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Info describes a model
+type Info struct {
+	Known         bool  // Some entry matched the model; the defaults below apply otherwise
+	ContextWindow int64 // Tokens that fit in the context
+	MaxOutput     int   // Most tokens one response may have; 0 when unknown
+	Vision        bool  // Accepts images
+	Thinking      bool  // Supports extended thinking
+	PromptCaching bool  // Supports cache_control breakpoints
+	Price         *Price
+}
+
+// DefaultContextWindow is assumed for models without a known context window
+const DefaultContextWindow = 200_000
+
+// builtin entries are matched against model IDs by prefix
+var builtin = []struct {
+	prefix string
+	info   Info
+}{
+	{"claude-3-5-haiku", Info{ContextWindow: 200_000, MaxOutput: 8192, Vision: true, PromptCaching: true, Price: &Price{0.80, 4}}},
+	{"claude-3-haiku", Info{ContextWindow: 200_000, MaxOutput: 4096, Vision: true, PromptCaching: true, Price: &Price{0.25, 1.25}}},
+	{"claude-haiku-4", Info{ContextWindow: 200_000, MaxOutput: 64_000, Vision: true, Thinking: true, PromptCaching: true, Price: &Price{1, 5}}},
+	{"claude-3-5-sonnet", Info{ContextWindow: 200_000, MaxOutput: 8192, Vision: true, PromptCaching: true, Price: &Price{3, 15}}},
+	{"claude-3-7-sonnet", Info{ContextWindow: 200_000, MaxOutput: 64_000, Vision: true, Thinking: true, PromptCaching: true, Price: &Price{3, 15}}},
+	{"claude-sonnet-4", Info{ContextWindow: 200_000, MaxOutput: 64_000, Vision: true, Thinking: true, PromptCaching: true, Price: &Price{3, 15}}},
+	{"claude-3-opus", Info{ContextWindow: 200_000, MaxOutput: 4096, Vision: true, PromptCaching: true, Price: &Price{15, 75}}},
+	{"claude-opus-4", Info{ContextWindow: 200_000, MaxOutput: 32_000, Vision: true, Thinking: true, PromptCaching: true, Price: &Price{15, 75}}},
+}
+
+// Catalog looks up models in the built-in entries, overlaid with
+// configured ones
+type Catalog struct {
+	overrides map[string]config.ModelInfoConfig // Keyed by model ID or prefix
+}
+
+// New creates a catalog in which overrides, keyed by model ID or ID
+// prefix, replace the fields they set. It fails on negative sizes or prices.
+func New(overrides map[string]config.ModelInfoConfig) (*Catalog, error) {
+	for _, key := range slices.Sorted(maps.Keys(overrides)) {
+		if err := overrides[key].Validate(); err != nil {
+			return nil, fmt.Errorf("model_info.%s: %w", key, err)
+		}
+	}
+	return &Catalog{overrides: overrides}, nil
+}
+
+// Lookup describes model. The most specific built-in entry is the base;
+// configured entries matching the model apply over it, shorter prefixes
+// first, so an exact ID wins over a family prefix.
+func (c *Catalog) Lookup(model string) Info {
+	info := Info{ContextWindow: DefaultContextWindow}
+	for _, entry := range builtin {
+		if strings.HasPrefix(model, entry.prefix) {
+			info = entry.info
+			info.Known = true
+			break
+		}
+	}
+
+	keys := slices.SortedFunc(maps.Keys(c.overrides), func(a, b string) int { return len(a) - len(b) })
+	for _, key := range keys {
+		if strings.HasPrefix(model, key) {
+			info = apply(info, c.overrides[key])
+		}
+	}
+	return info
+}
+
+// apply overlays the fields set in override
+func apply(info Info, override config.ModelInfoConfig) Info {
+	info.Known = true
+	if override.ContextWindow != nil {
+		info.ContextWindow = *override.ContextWindow
+	}
+	if override.MaxOutput != nil {
+		info.MaxOutput = *override.MaxOutput
+	}
+	if override.Vision != nil {
+		info.Vision = *override.Vision
+	}
+	if override.Thinking != nil {
+		info.Thinking = *override.Thinking
+	}
+	if override.PromptCaching != nil {
+		info.PromptCaching = *override.PromptCaching
+	}
+	if override.Price != nil {
+		info.Price = &Price{Input: override.Price.Input, Output: override.Price.Output}
+	}
+	return info
+}
+
+// Features lists the optional features of a model, e.g. "vision, thinking"
+func (i Info) Features() string {
+	var features []string
+	for _, feature := range []struct {
+		name string
+		on   bool
+	}{{"vision", i.Vision}, {"thinking", i.Thinking}, {"prompt caching", i.PromptCaching}} {
+		if feature.on {
+			features = append(features, feature.name)
+		}
+	}
+	if len(features) == 0 {
+		return "none"
+	}
+	return strings.Join(features, ", ")
+}
+
+var (
+	mutex   sync.RWMutex
+	current = &Catalog{}
+)
+
+// Use makes c the catalog of Lookup
+func Use(c *Catalog) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	current = c
+}
+
+// Lookup describes model with the catalog set with Use, or the built-in
+// entries before then
+func Lookup(model string) Info {
+	mutex.RLock()
+	c := current
+	mutex.RUnlock()
+	return c.Lookup(model)
+}
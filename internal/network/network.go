@@ -0,0 +1,103 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"agent/internal/config"
+)
+
+// Error message constants
+const (
+	errMsgInvalidProxy = "invalid network.proxy %q: %w"
+	errMsgProxyScheme  = "invalid network.proxy %q: scheme must be http, https or socks5"
+	errMsgReadBundle   = "failed to read network.ca_bundle: %w"
+	errMsgEmptyBundle  = "network.ca_bundle %s contains no PEM certificates"
+)
+
+// NewClient returns an HTTP client for every outbound call: the model
+// provider and MCP servers. Requests go through network.proxy when set
+// (except for no_proxy hosts) and otherwise through the standard
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables. Certificates
+// in network.ca_bundle are trusted in addition to the system roots.
+func NewClient(cfg config.NetworkConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf(errMsgInvalidProxy, cfg.Proxy, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf(errMsgProxyScheme, cfg.Proxy)
+		}
+		noProxy := cfg.NoProxy
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	if cfg.CABundle != "" {
+		pool, err := certPool(cfg.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// certPool returns the system roots plus the certificates in the PEM file at path
+func certPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgReadBundle, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf(errMsgEmptyBundle, path)
+	}
+	return pool, nil
+}
+
+// bypassProxy reports whether host matches a no_proxy entry: "*", an exact
+// host or IP, or a domain that also covers its subdomains ("corp.example"
+// and ".corp.example" both match "api.corp.example")
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
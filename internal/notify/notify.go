@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/logging"
+)
+
+// maxBodyLength keeps notification bodies to what notification popups show
+const maxBodyLength = 120
+
+// Notifier gets the user's attention when billdozer finishes a long turn or
+// needs an answer: it rings the terminal bell and can show a desktop
+// notification. Only waits longer than a threshold notify, since a user
+// who just sent a message is still watching. A nil *Notifier does nothing.
+type Notifier struct {
+	bell    io.Writer // nil when the bell is off
+	desktop bool
+	after   time.Duration
+
+	mutex   sync.Mutex
+	started time.Time // When the user last sent a message
+	warned  bool      // A desktop notification failure has been reported
+}
+
+// New creates a notifier that rings the bell on bell (nil for none) and,
+// with desktop set, shows OS notifications, for waits of at least after
+func New(bell io.Writer, desktop bool, after time.Duration) *Notifier {
+	return &Notifier{bell: bell, desktop: desktop, after: after, started: time.Now()}
+}
+
+// Started records that the user sent a message and is waiting for the reply
+func (n *Notifier) Started() {
+	if n == nil {
+		return
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.started = time.Now()
+}
+
+// Notify alerts the user when they have waited long enough to have looked away
+func (n *Notifier) Notify(title, body string) {
+	if n == nil {
+		return
+	}
+	n.mutex.Lock()
+	waited := time.Since(n.started)
+	n.mutex.Unlock()
+	if waited < n.after {
+		return
+	}
+	if n.bell != nil {
+		fmt.Fprint(n.bell, "\a")
+	}
+	if n.desktop {
+		n.show(title, summarize(body))
+	}
+}
+
+// show starts the OS notification command without waiting for it
+func (n *Notifier) show(title, body string) {
+	argv := desktopCommand(title, body)
+	if argv == nil {
+		n.warn(fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS))
+		return
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	if err := cmd.Start(); err != nil {
+		n.warn(err)
+		return
+	}
+	go cmd.Wait()
+}
+
+func (n *Notifier) warn(err error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if !n.warned {
+		n.warned = true
+		logging.For(logging.Session).Warn("desktop notification failed", "error", err)
+	}
+}
+
+// desktopCommand returns the command that shows a notification on this OS
+func desktopCommand(title, body string) []string {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return []string{"osascript", "-e", script}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return []string{"notify-send", "--app-name=billdozer", title, body}
+	}
+	return nil
+}
+
+// appleScriptString quotes text as an AppleScript string literal
+func appleScriptString(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// summarize reduces text to its first line, shortened for a popup
+func summarize(text string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	runes := []rune(first)
+	if len(runes) > maxBodyLength {
+		return string(runes[:maxBodyLength-1]) + "…"
+	}
+	return first
+}
@@ -0,0 +1,99 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agent/internal/confirm"
+	"agent/internal/tools"
+)
+
+// defaultPaths reads the conventional "path" parameter shared by file tools
+var defaultPaths = tools.PathParams("path")
+
+// Middleware evaluates the policy before every tool call. Allowed calls run
+// with an auto-approving confirmer (forced confirmations still reach the
+// user); calls that need approval either confirm
+// inside the tool (with a preview) or are confirmed here with the raw input.
+// A call on several paths is denied if any of them is, and asks if any does.
+func Middleware(policy *Policy, confirmer confirm.Confirmer) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			tool := toolCtx.Tool
+			pathsOf := tool.Paths
+			if pathsOf == nil {
+				pathsOf = defaultPaths
+			}
+			paths := pathsOf(toolCtx, input)
+
+			// Rules match the paths the tool will use, relative to the root, so
+			// an absolute path or one through ".." cannot slip past them. A
+			// path may be a directory to list or search, so the workspace's
+			// path rules only reject it here when nothing inside is
+			// accessible; the tools check every file they touch.
+			if toolCtx.Workspace != nil {
+				for i, path := range paths {
+					resolved, err := toolCtx.Workspace.ResolveDir(path)
+					if err != nil {
+						return nil, tools.PermissionDenied(fmt.Errorf("permission denied: %s: %w", describeCall(tool.Name, path), err))
+					}
+					paths[i] = resolved.Shown
+				}
+			}
+
+			action, path := evaluate(policy, tool, paths)
+			switch action {
+			case Allow:
+				toolCtx.Confirmer = confirm.AutoApprove{Fallback: confirmer}
+				return next(ctx, toolCtx, input)
+			case Deny:
+				return nil, tools.PermissionDenied(fmt.Errorf("permission denied: %s is not allowed by the permission policy", describeCall(tool.Name, path)))
+			}
+
+			if tool.Confirms {
+				toolCtx.Confirmer = confirmer
+				return next(ctx, toolCtx, input)
+			}
+
+			approved := confirmer.Confirm(confirm.Request{
+				Tool:    tool.Name,
+				Action:  "run " + tool.Name,
+				Path:    path,
+				Preview: string(input),
+			})
+			if !approved {
+				return nil, tools.PermissionDenied(fmt.Errorf("permission denied: user declined %s", describeCall(tool.Name, path)))
+			}
+			toolCtx.Confirmer = confirm.AutoApprove{Fallback: confirmer}
+			return next(ctx, toolCtx, input)
+		}
+	}
+}
+
+// evaluate returns the strictest action of the policy over every path of a
+// call, and the path it is for. A call without paths is evaluated once.
+func evaluate(policy *Policy, tool *tools.ToolDefinition, paths []string) (Action, string) {
+	if len(paths) == 0 {
+		return policy.Evaluate(tool.Name, "", tool.Mutating), ""
+	}
+	action, actionPath := Allow, paths[0]
+	for _, path := range paths {
+		switch policy.Evaluate(tool.Name, path, tool.Mutating) {
+		case Deny:
+			return Deny, path
+		case Ask:
+			if action == Allow {
+				action, actionPath = Ask, path
+			}
+		}
+	}
+	return action, actionPath
+}
+
+func describeCall(name, path string) string {
+	if path == "" {
+		return name
+	}
+	return fmt.Sprintf("%s on %s", name, path)
+}
@@ -0,0 +1,179 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// recordingConfirmer answers every request with approve and keeps them
+type recordingConfirmer struct {
+	approve  bool
+	requests []confirm.Request
+}
+
+func (c *recordingConfirmer) Confirm(req confirm.Request) bool {
+	c.requests = append(c.requests, req)
+	return c.approve
+}
+
+// callTool runs a tool that does nothing through the permission middleware
+// in a workspace holding main.go, and reports whether it ran
+func callTool(t *testing.T, cfg config.PermissionsConfig, confirmer confirm.Confirmer, def tools.ToolDefinition, input string) (bool, error) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := workspace.New(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran := false
+	def.Function = func(context.Context, *tools.ToolContext, json.RawMessage) (*tools.ToolResult, error) {
+		ran = true
+		return tools.NewTextResult("done"), nil
+	}
+	var registry tools.Registry
+	registry.Use(Middleware(policy, confirmer))
+	if err := registry.Register(def); err != nil {
+		t.Fatal(err)
+	}
+	tool, err := registry.Resolve(def.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tool.Function(context.Background(), &tools.ToolContext{Workspace: ws}, json.RawMessage(input))
+	return ran, err
+}
+
+func TestMiddlewareChecksPath(t *testing.T) {
+	cfg := config.PermissionsConfig{Rules: []config.PermissionRule{{Tool: "*", Path: "secrets/**", Action: "deny"}}}
+	readFile := tools.ToolDefinition{Name: "read_file"}
+	confirmer := &recordingConfirmer{}
+
+	for _, input := range []string{`{"path": "secrets/key.pem"}`, `{"path": "docs/../secrets/key.pem"}`} {
+		ran, err := callTool(t, cfg, confirmer, readFile, input)
+		if ran || tools.Classify(err) != tools.ErrorPermissionDenied {
+			t.Errorf("%s: ran = %v, err = %v; want permission denied", input, ran, err)
+		}
+	}
+	if ran, err := callTool(t, cfg, confirmer, readFile, `{"path": "main.go"}`); !ran || err != nil {
+		t.Errorf("main.go: ran = %v, err = %v", ran, err)
+	}
+	if ran, err := callTool(t, cfg, confirmer, readFile, `{"path": "../outside.txt"}`); ran || err == nil {
+		t.Errorf("a path outside the workspace ran, err = %v", err)
+	}
+	if len(confirmer.requests) != 0 {
+		t.Errorf("allowed and denied calls asked %d times", len(confirmer.requests))
+	}
+}
+
+func TestMiddlewareChecksDeclaredPaths(t *testing.T) {
+	cfg := config.PermissionsConfig{Rules: []config.PermissionRule{
+		{Tool: "*", Path: "secrets/**", Action: "deny"},
+		{Tool: "copy", Path: "vendor/**", Action: "ask"},
+	}}
+	copyTool := tools.ToolDefinition{Name: "copy", Paths: tools.PathParams("from", "to")}
+
+	tests := []struct {
+		input    string
+		ran      bool
+		asked    string
+		approved bool
+	}{
+		{`{"from": "main.go", "to": "cmd/main.go"}`, true, "", false},
+		{`{"from": "main.go", "to": "secrets/main.go"}`, false, "", true},
+		{`{"from": "secrets/key.pem", "to": "main.go"}`, false, "", true},
+		{`{"from": "main.go", "to": "vendor/main.go"}`, true, "vendor/main.go", true},
+		{`{"from": "main.go", "to": "vendor/main.go"}`, false, "vendor/main.go", false},
+		{`{"from": "vendor/a.go", "to": "secrets/a.go"}`, false, "", true},
+	}
+	for _, tt := range tests {
+		confirmer := &recordingConfirmer{approve: tt.approved}
+		ran, err := callTool(t, cfg, confirmer, copyTool, tt.input)
+		if ran != tt.ran {
+			t.Errorf("%s: ran = %v (err %v), want %v", tt.input, ran, err, tt.ran)
+		}
+		if !ran && tools.Classify(err) != tools.ErrorPermissionDenied {
+			t.Errorf("%s: err = %v, want permission denied", tt.input, err)
+		}
+		asked := ""
+		if len(confirmer.requests) > 0 {
+			asked = confirmer.requests[0].Path
+		}
+		if asked != tt.asked {
+			t.Errorf("%s: asked about %q, want %q", tt.input, asked, tt.asked)
+		}
+	}
+}
+
+func TestMiddlewareDefaultDeny(t *testing.T) {
+	cfg := config.PermissionsConfig{Default: "deny", Rules: []config.PermissionRule{{Tool: "read_file", Action: "allow"}}}
+	confirmer := &recordingConfirmer{approve: true}
+
+	_, err := callTool(t, cfg, confirmer, tools.ToolDefinition{Name: "write", Mutating: true}, `{"path": "main.go"}`)
+	if err == nil || !strings.Contains(err.Error(), "not allowed by the permission policy") {
+		t.Errorf("write under default deny: err = %v", err)
+	}
+	if ran, err := callTool(t, cfg, confirmer, tools.ToolDefinition{Name: "read_file"}, `{"path": "main.go"}`); !ran {
+		t.Errorf("read_file under default deny did not run: %v", err)
+	}
+	if len(confirmer.requests) != 0 {
+		t.Errorf("default deny asked %d times", len(confirmer.requests))
+	}
+}
+
+func TestMiddlewareAllowsDirectoriesHoldingAllowedPaths(t *testing.T) {
+	rules, err := NewPathRules(config.PathsConfig{Allow: []string{"docs/api/*.md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPolicy(config.PermissionsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := workspace.New(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.SetPathChecker(rules)
+
+	ran := false
+	var registry tools.Registry
+	registry.Use(Middleware(policy, &recordingConfirmer{}))
+	err = registry.Register(tools.ToolDefinition{Name: "list_files", Function: func(context.Context, *tools.ToolContext, json.RawMessage) (*tools.ToolResult, error) {
+		ran = true
+		return tools.NewTextResult("[]"), nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool, err := registry.Resolve("list_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path string
+		ran  bool
+	}{{"docs", true}, {"src", false}} {
+		ran = false
+		_, err := tool.Function(context.Background(), &tools.ToolContext{Workspace: ws}, json.RawMessage(`{"path": "`+tt.path+`"}`))
+		if ran != tt.ran {
+			t.Errorf("list_files %s: ran = %v (err %v), want %v", tt.path, ran, err, tt.ran)
+		}
+	}
+}
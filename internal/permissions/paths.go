@@ -0,0 +1,104 @@
+package permissions
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"agent/internal/config"
+)
+
+// Error message constants for path rules
+const (
+	errMsgPathDenied     = "access to %q is denied by protected path rule %q"
+	errMsgPathNotAllowed = "access to %q is denied: it does not match any allowed path (%s)"
+	errMsgInvalidGlob    = "invalid path pattern %q: %w"
+	errMsgInvalidPolicy  = "%s must be allow, ask or deny, not %q"
+)
+
+// PathRules restricts which paths tools may read or write. Deny rules take
+// precedence over allow rules; an empty allow list allows everything not denied.
+type PathRules struct {
+	mutex sync.RWMutex
+	allow []string
+	deny  []string
+}
+
+// NewPathRules builds path rules from configuration, validating every pattern
+func NewPathRules(cfg config.PathsConfig) (*PathRules, error) {
+	for _, pattern := range append(append([]string{}, cfg.Allow...), cfg.Deny...) {
+		if _, err := filepath.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf(errMsgInvalidGlob, pattern, err)
+		}
+	}
+	for _, setting := range []struct{ key, value string }{{"absolute", cfg.Absolute}, {"outside", cfg.Outside}} {
+		switch Action(setting.value) {
+		case "", Allow, Ask, Deny:
+		default:
+			return nil, fmt.Errorf(errMsgInvalidPolicy, setting.key, setting.value)
+		}
+	}
+	return &PathRules{allow: cfg.Allow, deny: cfg.Deny}, nil
+}
+
+// Replace adopts the patterns of other, e.g. after the config is reloaded
+func (r *PathRules) Replace(other *PathRules) {
+	other.mutex.RLock()
+	allow, deny := other.allow, other.deny
+	other.mutex.RUnlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.allow, r.deny = allow, deny
+}
+
+// Check returns an error explaining why path may not be accessed, or nil
+func (r *PathRules) Check(path string) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, pattern := range r.deny {
+		if MatchPath(pattern, path) {
+			return fmt.Errorf(errMsgPathDenied, path, pattern)
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return nil
+	}
+	for _, pattern := range r.allow {
+		if MatchPath(pattern, path) {
+			return nil
+		}
+	}
+	return fmt.Errorf(errMsgPathNotAllowed, path, strings.Join(r.allow, ", "))
+}
+
+// CheckDir returns an error when nothing beneath directory path may be
+// accessed: a deny rule covers it, or no allow pattern matches anything
+// inside it. Walks use it to decide which directories to skip.
+func (r *PathRules) CheckDir(path string) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, pattern := range r.deny {
+		if MatchPath(pattern, path) {
+			return fmt.Errorf(errMsgPathDenied, path, pattern)
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return nil
+	}
+	for _, pattern := range r.allow {
+		if MatchPath(pattern, path) || matchesBelow(pattern, path) {
+			return nil
+		}
+	}
+	return fmt.Errorf(errMsgPathNotAllowed, path, strings.Join(r.allow, ", "))
+}
+
+// matchesBelow reports whether pattern could match a path inside dir
+func matchesBelow(pattern, dir string) bool {
+	// Patterns without a directory separator match base names at any depth
+	return !strings.Contains(filepath.ToSlash(filepath.Clean(pattern)), "/") || MatchGlobBelow(pattern, dir)
+}
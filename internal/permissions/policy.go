@@ -0,0 +1,188 @@
+package permissions
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"agent/internal/config"
+)
+
+// Action is the outcome of evaluating a tool call against the policy
+type Action string
+
+const (
+	Allow Action = "allow"
+	Deny  Action = "deny"
+	Ask   Action = "ask"
+)
+
+// Error message constants
+const (
+	errMsgInvalidAction = "invalid permission action %q (must be allow, deny or ask)"
+	errMsgInvalidRule   = "permission rule %d: %w"
+	errMsgMissingTool   = "tool is required (use \"*\" to match every tool)"
+)
+
+// Rule matches a tool name and optional path glob to an action
+type Rule struct {
+	Tool   string
+	Path   string
+	Action Action
+}
+
+// matches reports whether the rule applies to the given tool call
+func (r Rule) matches(toolName, path string) bool {
+	if r.Tool != "*" && r.Tool != toolName {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+	return MatchPath(r.Path, path)
+}
+
+// Policy evaluates tool calls against configured rules. Its rules can be
+// swapped with Replace while tool calls are being evaluated.
+type Policy struct {
+	mutex         sync.RWMutex
+	rules         []Rule
+	defaultAction Action
+}
+
+// NewPolicy builds a policy from configuration, validating every rule
+func NewPolicy(cfg config.PermissionsConfig) (*Policy, error) {
+	defaultAction := Allow
+	if cfg.Default != "" {
+		action, err := parseAction(cfg.Default)
+		if err != nil {
+			return nil, err
+		}
+		defaultAction = action
+	}
+
+	var rules []Rule
+	for i, ruleCfg := range cfg.Rules {
+		if ruleCfg.Tool == "" {
+			return nil, fmt.Errorf(errMsgInvalidRule, i+1, fmt.Errorf("%s", errMsgMissingTool))
+		}
+		action, err := parseAction(ruleCfg.Action)
+		if err != nil {
+			return nil, fmt.Errorf(errMsgInvalidRule, i+1, err)
+		}
+		rules = append(rules, Rule{Tool: ruleCfg.Tool, Path: ruleCfg.Path, Action: action})
+	}
+
+	return &Policy{
+		rules:         rules,
+		defaultAction: defaultAction,
+	}, nil
+}
+
+// Replace adopts the rules of other, e.g. after the config is reloaded
+func (p *Policy) Replace(other *Policy) {
+	other.mutex.RLock()
+	rules, defaultAction := other.rules, other.defaultAction
+	other.mutex.RUnlock()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.rules, p.defaultAction = rules, defaultAction
+}
+
+// Evaluate returns the action for a tool call. The first matching rule
+// wins; without one, the call takes the default action, except that tools
+// that modify files or run commands ask when the default is to allow.
+func (p *Policy) Evaluate(toolName, path string, mutating bool) Action {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, rule := range p.rules {
+		if rule.matches(toolName, path) {
+			return rule.Action
+		}
+	}
+	if mutating && p.defaultAction == Allow {
+		return Ask
+	}
+	return p.defaultAction
+}
+
+func parseAction(value string) (Action, error) {
+	action := Action(strings.ToLower(strings.TrimSpace(value)))
+	switch action {
+	case Allow, Deny, Ask:
+		return action, nil
+	}
+	return "", fmt.Errorf(errMsgInvalidAction, value)
+}
+
+// MatchPath reports whether path matches a glob pattern. In addition to
+// filepath.Match syntax, "**" matches any number of directories.
+func MatchPath(pattern, path string) bool {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	// Patterns without a directory separator match the base name anywhere (like .gitignore)
+	if !strings.Contains(pattern, "/") {
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return true
+		}
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+// MatchGlob reports whether path matches a glob pattern segment by segment,
+// with filepath.Match syntax and "**" matching any number of directories.
+// Unlike MatchPath, a pattern without a directory separator only matches
+// top-level names.
+func MatchGlob(pattern, path string) bool {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	path = filepath.ToSlash(filepath.Clean(path))
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+// MatchGlobBelow reports whether MatchGlob could match a path inside
+// directory dir, so walks know which directories to descend into
+func MatchGlobBelow(pattern, dir string) bool {
+	patternSegments := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	for _, segment := range strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/") {
+		if len(patternSegments) == 0 {
+			return false
+		}
+		if patternSegments[0] == "**" {
+			return true
+		}
+		if matched, _ := filepath.Match(patternSegments[0], segment); !matched {
+			return false
+		}
+		patternSegments = patternSegments[1:]
+	}
+	return len(patternSegments) > 0
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(rest, path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if matched, _ := filepath.Match(pattern[0], path[0]); !matched {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
@@ -0,0 +1,62 @@
+package permissions
+
+import (
+	"testing"
+
+	"agent/internal/config"
+)
+
+func TestEvaluate(t *testing.T) {
+	rules := []config.PermissionRule{
+		{Tool: "write", Path: "docs/**", Action: "allow"},
+		{Tool: "*", Path: "secrets/**", Action: "deny"},
+		{Tool: "execute_command", Action: "ask"},
+	}
+	tests := []struct {
+		defaultAction string
+		tool, path    string
+		mutating      bool
+		want          Action
+	}{
+		{"", "write", "docs/api/index.md", true, Allow},
+		{"", "read_file", "secrets/key.pem", false, Deny},
+		{"", "write", "secrets/key.pem", true, Deny},
+		{"", "execute_command", "", true, Ask},
+		{"", "read_file", "main.go", false, Allow},
+		{"", "write", "main.go", true, Ask},
+		{"allow", "delete_file", "main.go", true, Ask},
+		{"deny", "write", "main.go", true, Deny},
+		{"deny", "read_file", "main.go", false, Deny},
+		{"deny", "write", "docs/index.md", true, Allow},
+		{"ask", "write", "main.go", true, Ask},
+		{"ask", "read_file", "main.go", false, Ask},
+	}
+	for _, tt := range tests {
+		policy, err := NewPolicy(config.PermissionsConfig{Default: tt.defaultAction, Rules: rules})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := policy.Evaluate(tt.tool, tt.path, tt.mutating); got != tt.want {
+			t.Errorf("default %q: Evaluate(%s, %q, %v) = %s, want %s", tt.defaultAction, tt.tool, tt.path, tt.mutating, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"secrets/**", "secrets/key.pem", true},
+		{"secrets/**", "secrets/prod/key.pem", true},
+		{"secrets/**", "src/secrets.go", false},
+		{"*.pem", "certs/server.pem", true},
+		{"docs/*.md", "docs/api/index.md", false},
+		{"db/**", "db/migrations", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
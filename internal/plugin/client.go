@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"agent/internal/jsonrpc"
+	"agent/internal/logging"
+	"agent/internal/tools"
+)
+
+// Error message constants
+const (
+	errMsgStartFailed  = "failed to start plugin %q: %w"
+	errMsgPluginExited = "plugin %q exited"
+	errMsgRPCError     = "plugin %q returned error %d: %s"
+	errMsgTimeout      = "plugin %q did not respond to %s within %s"
+)
+
+// codeInvalidParams is the JSON-RPC error code plugins return for bad tool input
+const codeInvalidParams = -32602
+
+// defaultTimeout applies when a plugin does not configure timeout_seconds
+const defaultTimeout = 60 * time.Second
+
+// Client talks to one running plugin process
+type Client struct {
+	name    string
+	timeout time.Duration
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+
+	writeMutex sync.Mutex
+	calls      jsonrpc.Calls
+	done       chan struct{}
+}
+
+// Start launches the plugin executable and begins reading its responses
+func Start(name string, command []string, env []string, timeout time.Duration) (*Client, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf(errMsgStartFailed, name, fmt.Errorf("empty command"))
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf(errMsgStartFailed, name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf(errMsgStartFailed, name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf(errMsgStartFailed, name, err)
+	}
+
+	c := &Client{
+		name:    name,
+		timeout: timeout,
+		cmd:     cmd,
+		stdin:   stdin,
+		done:    make(chan struct{}),
+	}
+	go c.readResponses(stdout)
+	return c, nil
+}
+
+// Name returns the configured plugin name
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Describe asks the plugin for the tools it provides
+func (c *Client) Describe(ctx context.Context) ([]ToolSpec, error) {
+	var result describeResult
+	if err := c.call(ctx, "describe", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Tools, nil
+}
+
+// Execute runs one tool call inside the plugin
+func (c *Client) Execute(ctx context.Context, tool string, input json.RawMessage) (*ExecuteResult, error) {
+	var result ExecuteResult
+	params := executeParams{Name: tool, Input: input}
+	if err := c.call(ctx, "execute", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close stops the plugin process
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+	}
+	return c.cmd.Wait()
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	send := func(request jsonrpc.Message) error {
+		line, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		c.writeMutex.Lock()
+		defer c.writeMutex.Unlock()
+		if _, err := c.stdin.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf(errMsgPluginExited, c.name)
+		}
+		return nil
+	}
+
+	reply, err := c.calls.Call(ctx, method, params, send, c.done)
+	if err != nil {
+		if errors.Is(err, jsonrpc.ErrClosed) {
+			return fmt.Errorf(errMsgPluginExited, c.name)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return tools.Transient(fmt.Errorf(errMsgTimeout, c.name, method, c.timeout))
+		}
+		return err
+	}
+	if reply.Error != nil {
+		err := fmt.Errorf(errMsgRPCError, c.name, reply.Error.Code, reply.Error.Message)
+		if reply.Error.Code == codeInvalidParams {
+			return tools.InvalidInput(err)
+		}
+		return err
+	}
+	return reply.Decode(result)
+}
+
+// readResponses hands each response line to the call waiting on its id
+func (c *Client) readResponses(stdout io.Reader) {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var reply jsonrpc.Message
+		if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+			logging.For(logging.Plugin).Warn("ignoring malformed response", "plugin", c.name, "error", err)
+			continue
+		}
+		c.calls.Deliver(reply)
+	}
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/tools"
+)
+
+// LoadAll starts every configured plugin and registers its tools. On error,
+// plugins that were already started are stopped before returning.
+func LoadAll(ctx context.Context, plugins []config.PluginConfig, registry *tools.Registry) ([]*Client, error) {
+	var clients []*Client
+	fail := func(err error) ([]*Client, error) {
+		CloseAll(clients)
+		return nil, err
+	}
+
+	for _, cfg := range plugins {
+		if cfg.Name == "" {
+			return fail(fmt.Errorf("plugin with command %v has no name", cfg.Command))
+		}
+
+		client, err := Start(cfg.Name, cfg.Command, cfg.Env, time.Duration(cfg.TimeoutSeconds)*time.Second)
+		if err != nil {
+			return fail(err)
+		}
+		clients = append(clients, client)
+
+		defs, err := client.Definitions(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		for _, def := range defs {
+			if err := registry.Register(def); err != nil {
+				return fail(fmt.Errorf("plugin %q: %w", cfg.Name, err))
+			}
+		}
+	}
+	return clients, nil
+}
+
+// CloseAll stops the given plugins
+func CloseAll(clients []*Client) {
+	for _, client := range clients {
+		client.Close()
+	}
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"agent/internal/schema"
+	"agent/internal/tools"
+)
+
+// ToolSpec is a tool definition returned by a plugin's describe method
+type ToolSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+	ReadOnly    bool            `json:"read_only"` // Tools are treated as mutating unless marked read-only
+}
+
+type describeResult struct {
+	Tools []ToolSpec `json:"tools"`
+}
+
+type executeParams struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// ExecuteResult is the result of a plugin's execute method
+type ExecuteResult struct {
+	Content []ContentBlock `json:"content"`
+	IsError bool           `json:"is_error"`
+}
+
+// ContentBlock mirrors tools.ContentBlock on the wire. Image data is base64-encoded.
+type ContentBlock struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+// Definitions converts the plugin's tool specs into registry definitions that
+// route calls to the plugin process. Each tool is placed in a group named after the plugin.
+func (c *Client) Definitions(ctx context.Context) ([]tools.ToolDefinition, error) {
+	specs, err := c.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []tools.ToolDefinition
+	for _, spec := range specs {
+		inputSchema, err := schema.FromJSON(spec.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %q tool %q: invalid input_schema: %w", c.name, spec.Name, err)
+		}
+
+		toolName := spec.Name
+		defs = append(defs, tools.ToolDefinition{
+			Name:        toolName,
+			Description: spec.Description,
+			Group:       c.name,
+			InputSchema: inputSchema,
+			Mutating:    !spec.ReadOnly,
+			Function: func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+				result, err := c.Execute(ctx, toolName, input)
+				if err != nil {
+					return nil, err
+				}
+				return toToolResult(result)
+			},
+		})
+	}
+	return defs, nil
+}
+
+func toToolResult(result *ExecuteResult) (*tools.ToolResult, error) {
+	converted := &tools.ToolResult{IsError: result.IsError}
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text", "":
+			converted.Content = append(converted.Content, tools.ContentBlock{Type: tools.ContentText, Text: block.Text})
+		case "image":
+			data, err := base64.StdEncoding.DecodeString(block.Data)
+			if err != nil {
+				return nil, fmt.Errorf("plugin returned invalid image data: %w", err)
+			}
+			converted.Content = append(converted.Content, tools.ContentBlock{Type: tools.ContentImage, MediaType: block.MediaType, Data: data})
+		default:
+			return nil, fmt.Errorf("plugin returned unsupported content type %q", block.Type)
+		}
+	}
+	return converted, nil
+}
@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"agent/internal/tools"
+)
+
+// Data holds the variables available to a system prompt template
+type Data struct {
+	ProjectName string   // Base name of the workspace root
+	Workspace   string   // Absolute workspace root
+	OS          string   // runtime.GOOS, e.g. linux or darwin
+	Arch        string   // runtime.GOARCH, e.g. amd64 or arm64
+	Date        string   // Session start date, YYYY-MM-DD
+	GitBranch   string   // Current branch of the workspace, "" outside a git repository
+	Model       string   // Model the session talks to
+	ToolList    string   // One "- name: description" line per available tool
+	Tools       []string // Names of the available tools
+}
+
+// NewData collects the template variables for a session in workspaceRoot.
+// Only tools that are enabled (and allowed in read-only mode) are listed.
+func NewData(workspaceRoot, model string, registry *tools.Registry) Data {
+	data := Data{
+		ProjectName: filepath.Base(workspaceRoot),
+		Workspace:   workspaceRoot,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Date:        time.Now().Format(time.DateOnly),
+		GitBranch:   gitBranch(workspaceRoot),
+		Model:       model,
+	}
+
+	var lines []string
+	for _, tool := range registry.GetAll() {
+		description, _, _ := strings.Cut(strings.TrimSpace(tool.Description), "\n")
+		lines = append(lines, fmt.Sprintf("- %s: %s", tool.Name, description))
+		data.Tools = append(data.Tools, tool.Name)
+	}
+	data.ToolList = strings.Join(lines, "\n")
+	return data
+}
+
+// Render executes a system prompt as a Go text/template. name identifies
+// the prompt file in errors. Unknown variables are errors rather than
+// silently rendering as "<no value>".
+func Render(name, text string, data Data) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// gitBranch returns the checked-out branch in dir, or "" when there is none
+func gitBranch(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/config"
+)
+
+// azureADResource is the audience of tokens for Azure OpenAI
+const azureADResource = "https://cognitiveservices.azure.com"
+
+// azure sends chat completions to the deployment serving the requested
+// model, authenticated by an api-key header or an Azure AD token
+type azure struct {
+	cfg   config.AzureConfig
+	key   string
+	token *adToken // Set with auth: azure-ad
+}
+
+func newAzure(cfg config.AzureConfig, key string, base http.RoundTripper) http.RoundTripper {
+	a := &azure{cfg: cfg, key: key}
+	if cfg.Auth == config.AzureAuthAD {
+		a.token = &adToken{}
+	}
+	return &transport{name: "azure", base: base, translator: a}
+}
+
+func (a *azure) request(req *http.Request, body messagesRequest) (*http.Request, error) {
+	version := a.cfg.APIVersion
+	if version == "" {
+		version = config.DefaultAzureAPIVersion
+	}
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		strings.TrimRight(a.cfg.Endpoint, "/"), url.PathEscape(a.cfg.Deployment(body.Model)), url.QueryEscape(version))
+
+	chat := toChatRequest(body)
+	chat.Model = ""
+	data, err := json.Marshal(chat)
+	if err != nil {
+		return nil, err
+	}
+	out, err := http.NewRequestWithContext(req.Context(), http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out.Header.Set("Content-Type", "application/json")
+	if a.token != nil {
+		token, err := a.token.get(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		out.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		out.Header.Set("api-key", a.key)
+	}
+	return out, nil
+}
+
+func (a *azure) response(model string, data []byte) ([]byte, error) {
+	return fromChatResponse(model, data)
+}
+
+// adToken caches an Azure AD access token from the Azure CLI, which
+// handles login, managed identities and refresh
+type adToken struct {
+	mutex   sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// get returns a token valid for at least another minute
+func (t *adToken) get(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.value != "" && time.Until(t.expires) > time.Minute {
+		return t.value, nil
+	}
+
+	out, err := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", azureADResource, "--output", "json").Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok && len(exit.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exit.Stderr)))
+		}
+		return "", fmt.Errorf("azure-ad: failed to get a token with the Azure CLI (run \"az login\"): %w", err)
+	}
+	var token struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresOn   int64  `json:"expires_on"`
+	}
+	if err := json.Unmarshal(out, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("azure-ad: unexpected output from az account get-access-token")
+	}
+	t.value = token.AccessToken
+	t.expires = time.Unix(token.ExpiresOn, 0)
+	if token.ExpiresOn == 0 {
+		// Older Azure CLIs only print a local time; tokens last at least an hour
+		t.expires = time.Now().Add(50 * time.Minute)
+	}
+	return t.value, nil
+}
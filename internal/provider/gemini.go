@@ -0,0 +1,320 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"agent/internal/config"
+)
+
+// gemini sends generateContent requests to the Gemini API. Tool schemas are
+// reduced to the OpenAPI subset Gemini accepts, tool calls and results
+// become functionCall and functionResponse parts.
+type gemini struct {
+	cfg   config.GeminiConfig
+	key   string
+	calls atomic.Int64 // Numbers tool call IDs, which Gemini may leave out
+
+	mutex      sync.Mutex
+	signatures map[string]string // Thought signature of each tool call, sent back with it
+}
+
+func newGemini(cfg config.GeminiConfig, key string, base http.RoundTripper) http.RoundTripper {
+	return &transport{name: "gemini", base: base, translator: &gemini{cfg: cfg, key: key, signatures: map[string]string{}}}
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+	ThoughtSignature string                  `json:"thoughtSignature,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunction `json:"functionDeclarations"`
+}
+
+type geminiFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int64 `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+	ResponseID string `json:"responseId"`
+}
+
+// geminiFinishReasons maps Gemini finish reasons to Anthropic stop reasons;
+// the safety and policy reasons not listed become refusal
+var geminiFinishReasons = map[string]string{
+	"STOP":       "end_turn",
+	"MAX_TOKENS": "max_tokens",
+}
+
+func (g *gemini) request(req *http.Request, body messagesRequest) (*http.Request, error) {
+	baseURL := g.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = config.DefaultGeminiURL
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", strings.TrimRight(baseURL, "/"), url.PathEscape(body.Model))
+
+	data, err := json.Marshal(g.toGeminiRequest(body))
+	if err != nil {
+		return nil, err
+	}
+	out, err := http.NewRequestWithContext(req.Context(), http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out.Header.Set("Content-Type", "application/json")
+	out.Header.Set("x-goog-api-key", g.key)
+	return out, nil
+}
+
+// toGeminiRequest converts a Messages API request. Function responses are
+// matched to their calls by name, so the name of every tool_use is kept.
+func (g *gemini) toGeminiRequest(body messagesRequest) geminiRequest {
+	out := geminiRequest{GenerationConfig: geminiGenerationConfig{MaxOutputTokens: body.MaxTokens}}
+	if system := body.systemText(); system != "" {
+		out.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+
+	names := map[string]string{}
+	for _, msg := range body.Messages {
+		content := geminiContent{Role: "user"}
+		if msg.Role == "assistant" {
+			content.Role = "model"
+		}
+		var results []geminiPart
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				if block.Text != "" {
+					content.Parts = append(content.Parts, geminiPart{Text: block.Text})
+				}
+			case "image":
+				if block.Source != nil && block.Source.Type == "base64" {
+					content.Parts = append(content.Parts, geminiPart{InlineData: &geminiBlob{MimeType: block.Source.MediaType, Data: block.Source.Data}})
+				}
+			case "tool_use":
+				names[block.ID] = block.Name
+				content.Parts = append(content.Parts, geminiPart{
+					FunctionCall:     &geminiFunctionCall{Name: block.Name, Args: toolInput(string(block.Input))},
+					ThoughtSignature: g.signature(block.ID),
+				})
+			case "tool_result":
+				key := "result"
+				if block.IsError {
+					key = "error"
+				}
+				results = append(results, geminiPart{FunctionResponse: &geminiFunctionResponse{
+					Name:     names[block.ToolUseID],
+					Response: map[string]any{key: block.resultText()},
+				}})
+			}
+		}
+		// Function responses must come first, answering the calls in order
+		content.Parts = append(results, content.Parts...)
+		if len(content.Parts) > 0 {
+			out.Contents = append(out.Contents, content)
+		}
+	}
+
+	var functions []geminiFunction
+	for _, tool := range body.Tools {
+		function := geminiFunction{Name: tool.Name, Description: tool.Description}
+		var schema map[string]any
+		if json.Unmarshal(tool.InputSchema, &schema) == nil {
+			if properties, _ := schema["properties"].(map[string]any); len(properties) > 0 {
+				function.Parameters = geminiSchema(schema)
+			}
+		}
+		functions = append(functions, function)
+	}
+	if len(functions) > 0 {
+		out.Tools = []geminiTool{{FunctionDeclarations: functions}}
+	}
+	return out
+}
+
+// geminiSchemaKeys are the JSON schema keywords Gemini accepts; others,
+// such as additionalProperties and $schema, are rejected by the API
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"properties": true, "required": true, "items": true, "anyOf": true,
+	"minimum": true, "maximum": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+}
+
+// geminiSchema reduces a JSON schema to the subset Gemini accepts. A type
+// list such as ["string", "null"] becomes a nullable string.
+func geminiSchema(schema map[string]any) map[string]any {
+	out := map[string]any{}
+	for key, value := range schema {
+		if !geminiSchemaKeys[key] {
+			continue
+		}
+		switch key {
+		case "type":
+			if types, ok := value.([]any); ok {
+				for _, t := range types {
+					if t == "null" {
+						out["nullable"] = true
+					} else if _, set := out["type"]; !set {
+						out["type"] = t
+					}
+				}
+				continue
+			}
+		case "properties":
+			if properties, ok := value.(map[string]any); ok {
+				converted := map[string]any{}
+				for name, property := range properties {
+					if property, ok := property.(map[string]any); ok {
+						converted[name] = geminiSchema(property)
+					}
+				}
+				value = converted
+			}
+		case "items":
+			if items, ok := value.(map[string]any); ok {
+				value = geminiSchema(items)
+			}
+		case "anyOf":
+			if options, ok := value.([]any); ok {
+				var converted []any
+				for _, option := range options {
+					if option, ok := option.(map[string]any); ok {
+						converted = append(converted, geminiSchema(option))
+					}
+				}
+				value = converted
+			}
+		}
+		out[key] = value
+	}
+	return out
+}
+
+func (g *gemini) response(model string, data []byte) ([]byte, error) {
+	var resp geminiResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	out := responseMessage{
+		ID:      resp.ResponseID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   model,
+		Content: []contentBlock{},
+		Usage: responseUsage{
+			InputTokens:  resp.UsageMetadata.PromptTokenCount,
+			OutputTokens: resp.UsageMetadata.CandidatesTokenCount + resp.UsageMetadata.ThoughtsTokenCount,
+		},
+	}
+	if out.ID == "" {
+		out.ID = fmt.Sprintf("gemini_%d", time.Now().UnixNano())
+	}
+	if len(resp.Candidates) == 0 {
+		if reason := resp.PromptFeedback.BlockReason; reason != "" {
+			out.StopReason = "refusal"
+			out.Content = append(out.Content, contentBlock{Type: "text", Text: "Gemini blocked the prompt: " + reason})
+			return json.Marshal(out)
+		}
+		return nil, errors.New("no candidates in the response")
+	}
+
+	candidate := resp.Candidates[0]
+	out.StopReason = geminiFinishReasons[candidate.FinishReason]
+	if out.StopReason == "" {
+		out.StopReason = "refusal"
+	}
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part.Thought:
+		case part.FunctionCall != nil:
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_gemini_%d", g.calls.Add(1))
+			}
+			g.remember(id, part.ThoughtSignature)
+			out.Content = append(out.Content, contentBlock{Type: "tool_use", ID: id, Name: part.FunctionCall.Name, Input: toolInput(string(part.FunctionCall.Args))})
+			out.StopReason = "tool_use"
+		case part.Text != "":
+			out.Content = append(out.Content, contentBlock{Type: "text", Text: part.Text})
+		}
+	}
+	return json.Marshal(out)
+}
+
+// remember keeps the thought signature of a tool call; Gemini's thinking
+// models expect it back with the call in later requests
+func (g *gemini) remember(id, signature string) {
+	if signature == "" {
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.signatures[id] = signature
+}
+
+func (g *gemini) signature(id string) string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.signatures[id]
+}
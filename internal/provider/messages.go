@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// messagesRequest is the part of a Messages API request that the agent
+// sends and backends can express
+type messagesRequest struct {
+	Model     string     `json:"model"`
+	MaxTokens int        `json:"max_tokens"`
+	System    blocks     `json:"system"`
+	Messages  []message  `json:"messages"`
+	Tools     []toolSpec `json:"tools"`
+}
+
+// blocks is content given as a string or as a list of blocks
+type blocks []contentBlock
+
+func (b *blocks) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*b = blocks{{Type: "text", Text: text}}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]contentBlock)(b))
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content blocks `json:"content"`
+}
+
+// contentBlock is a text, image, tool_use or tool_result block
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *imageSource    `json:"source,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   blocks          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type toolSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// systemText joins the system prompt blocks
+func (r messagesRequest) systemText() string {
+	var parts []string
+	for _, block := range r.System {
+		parts = append(parts, block.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// resultText flattens a tool result to text; images, which backends do not
+// accept in tool results, are mentioned instead
+func (b contentBlock) resultText() string {
+	var parts []string
+	for _, block := range b.Content {
+		switch block.Type {
+		case "text":
+			parts = append(parts, block.Text)
+		case "image":
+			parts = append(parts, "[image omitted: this provider does not accept images in tool results]")
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if b.IsError {
+		return "Error: " + text
+	}
+	return text
+}
+
+// responseMessage is a Messages API response
+type responseMessage struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      responseUsage  `json:"usage"`
+}
+
+type responseUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// toolInput makes arguments a JSON object, as tool_use input must be
+func toolInput(arguments string) json.RawMessage {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" || !json.Valid([]byte(arguments)) || arguments[0] != '{' {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OpenAI chat completions, as spoken by Azure OpenAI and OpenRouter
+
+type chatRequest struct {
+	Model     string        `json:"model,omitempty"` // Azure routes by deployment instead
+	MaxTokens int           `json:"max_tokens,omitempty"`
+	Messages  []chatMessage `json:"messages"`
+	Tools     []chatTool    `json:"tools,omitempty"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    any            `json:"content"` // string, []chatPart or nil
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *chatImageURL `json:"image_url,omitempty"`
+}
+
+type chatImageURL struct {
+	URL string `json:"url"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content   *string        `json:"content"`
+			ToolCalls []chatToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// finishReasons maps OpenAI finish reasons to Anthropic stop reasons
+var finishReasons = map[string]string{
+	"stop":           "end_turn",
+	"length":         "max_tokens",
+	"tool_calls":     "tool_use",
+	"function_call":  "tool_use",
+	"content_filter": "refusal",
+}
+
+// toChatRequest converts a Messages API request. Tool results become
+// "tool" messages, which must directly follow the assistant message that
+// called the tools, so they are sent before any text of the same turn.
+func toChatRequest(body messagesRequest) chatRequest {
+	out := chatRequest{Model: body.Model, MaxTokens: body.MaxTokens}
+	if system := body.systemText(); system != "" {
+		out.Messages = append(out.Messages, chatMessage{Role: "system", Content: system})
+	}
+
+	for _, msg := range body.Messages {
+		if msg.Role == "assistant" {
+			out.Messages = append(out.Messages, toChatAssistant(msg))
+			continue
+		}
+		var parts []chatPart
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "tool_result":
+				out.Messages = append(out.Messages, chatMessage{Role: "tool", ToolCallID: block.ToolUseID, Content: block.resultText()})
+			case "text":
+				parts = append(parts, chatPart{Type: "text", Text: block.Text})
+			case "image":
+				if block.Source != nil && block.Source.Type == "base64" {
+					url := "data:" + block.Source.MediaType + ";base64," + block.Source.Data
+					parts = append(parts, chatPart{Type: "image_url", ImageURL: &chatImageURL{URL: url}})
+				}
+			}
+		}
+		if len(parts) > 0 {
+			out.Messages = append(out.Messages, chatMessage{Role: "user", Content: parts})
+		}
+	}
+
+	for _, tool := range body.Tools {
+		out.Tools = append(out.Tools, chatTool{
+			Type:     "function",
+			Function: chatFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.InputSchema},
+		})
+	}
+	return out
+}
+
+func toChatAssistant(msg message) chatMessage {
+	out := chatMessage{Role: "assistant"}
+	text := ""
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			text += block.Text
+		case "tool_use":
+			call := chatToolCall{ID: block.ID, Type: "function"}
+			call.Function.Name = block.Name
+			call.Function.Arguments = string(toolInput(string(block.Input)))
+			out.ToolCalls = append(out.ToolCalls, call)
+		}
+	}
+	if text != "" || len(out.ToolCalls) == 0 {
+		out.Content = text
+	}
+	return out
+}
+
+// fromChatResponse converts a chat completion into a Messages API response
+func fromChatResponse(model string, data []byte) ([]byte, error) {
+	var resp chatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("no choices in the response")
+	}
+	choice := resp.Choices[0]
+
+	out := responseMessage{
+		ID:         resp.ID,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      model,
+		Content:    []contentBlock{},
+		StopReason: finishReasons[choice.FinishReason],
+		Usage:      responseUsage{InputTokens: resp.Usage.PromptTokens, OutputTokens: resp.Usage.CompletionTokens},
+	}
+	if out.StopReason == "" {
+		out.StopReason = "end_turn"
+	}
+	if content := choice.Message.Content; content != nil && *content != "" {
+		out.Content = append(out.Content, contentBlock{Type: "text", Text: *content})
+	}
+	for i, call := range choice.Message.ToolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		out.Content = append(out.Content, contentBlock{Type: "tool_use", ID: id, Name: call.Function.Name, Input: toolInput(call.Function.Arguments)})
+	}
+	if len(choice.Message.ToolCalls) > 0 {
+		out.StopReason = "tool_use"
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"agent/internal/config"
+)
+
+// openRouter sends chat completions to OpenRouter, where models are named
+// by vendor, e.g. anthropic/claude-sonnet-4 or openai/gpt-4o
+type openRouter struct {
+	cfg config.OpenRouterConfig
+	key string
+}
+
+func newOpenRouter(cfg config.OpenRouterConfig, key string, base http.RoundTripper) http.RoundTripper {
+	return &transport{name: "openrouter", base: base, translator: &openRouter{cfg: cfg, key: key}}
+}
+
+func (o *openRouter) request(req *http.Request, body messagesRequest) (*http.Request, error) {
+	baseURL := o.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = config.DefaultOpenRouterURL
+	}
+	data, err := json.Marshal(toChatRequest(body))
+	if err != nil {
+		return nil, err
+	}
+	out, err := http.NewRequestWithContext(req.Context(), http.MethodPost, strings.TrimRight(baseURL, "/")+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out.Header.Set("Content-Type", "application/json")
+	out.Header.Set("Authorization", "Bearer "+o.key)
+	if o.cfg.Referer != "" {
+		out.Header.Set("HTTP-Referer", o.cfg.Referer)
+	}
+	if o.cfg.Title != "" {
+		out.Header.Set("X-Title", o.cfg.Title)
+	}
+	return out, nil
+}
+
+func (o *openRouter) response(model string, data []byte) ([]byte, error) {
+	return fromChatResponse(model, data)
+}
@@ -0,0 +1,157 @@
+// Package provider lets billdozer talk to model APIs other than Anthropic's.
+// Each backend is an http.RoundTripper under the Anthropic client: it turns
+// Messages API requests into the backend's format and its responses back,
+// so the agent loop, tools and usage tracking are the same for every
+// provider.
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/logging"
+)
+
+// Transport returns the round tripper that carries the Anthropic client's
+// requests to the configured provider over base, or nil for Anthropic
+// itself. key is the provider's API key; it may be empty when the provider
+// authenticates otherwise, such as Azure with azure-ad.
+func Transport(cfg *config.Config, key string, base http.RoundTripper) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	switch cfg.ProviderOrDefault() {
+	case config.DefaultProvider:
+		return nil, nil
+	case "azure":
+		return newAzure(cfg.Azure, key, base), nil
+	case "openrouter":
+		return newOpenRouter(cfg.OpenRouter, key, base), nil
+	case "gemini":
+		return newGemini(cfg.Gemini, key, base), nil
+	}
+	return nil, cfg.ValidateProvider()
+}
+
+// NeedsKey reports whether the configured provider authenticates with an API key
+func NeedsKey(cfg *config.Config) bool {
+	return !(cfg.ProviderOrDefault() == "azure" && cfg.Azure.Auth == config.AzureAuthAD)
+}
+
+// translator converts one Messages API request into a backend request
+// and the backend's response into a Messages API response body
+type translator interface {
+	request(req *http.Request, body messagesRequest) (*http.Request, error)
+	response(model string, data []byte) ([]byte, error)
+}
+
+// transport routes Messages API calls through a translator
+type transport struct {
+	name       string // Provider name for errors
+	base       http.RoundTripper
+	translator translator
+}
+
+// RoundTrip implements http.RoundTripper for POST .../v1/messages, the
+// only endpoint the agent calls
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/messages") {
+		return errorResponse(req, http.StatusNotFound, fmt.Sprintf("%s does not support %s %s", t.name, req.Method, req.URL.Path)), nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var body messagesRequest
+	if err := json.Unmarshal(data, &body); err != nil {
+		return errorResponse(req, http.StatusBadRequest, "invalid request: "+err.Error()), nil
+	}
+
+	out, err := t.translator.request(req, body)
+	if err != nil {
+		return errorResponse(req, http.StatusBadRequest, err.Error()), nil
+	}
+	logger := logging.For(logging.Provider).With("provider", t.name, "model", body.Model)
+	logger.DebugContext(req.Context(), "backend request", "url", out.URL.Redacted(), "bytes", out.ContentLength)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		logger.InfoContext(req.Context(), "backend request failed", "error", err, "duration", time.Since(start))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	logger.DebugContext(req.Context(), "backend response", "status", resp.StatusCode, "bytes", len(data), "duration", time.Since(start))
+	if resp.StatusCode >= 300 {
+		logger.InfoContext(req.Context(), "backend error", "status", resp.StatusCode, "error", backendError(data))
+		return errorResponse(req, resp.StatusCode, fmt.Sprintf("%s: %s", t.name, backendError(data))), nil
+	}
+	converted, err := t.translator.response(body.Model, data)
+	if err != nil {
+		return errorResponse(req, http.StatusBadGateway, fmt.Sprintf("%s: unexpected response: %v", t.name, err)), nil
+	}
+	return jsonResponse(req, http.StatusOK, converted), nil
+}
+
+// errorTypes are the Anthropic error types for HTTP statuses
+var errorTypes = map[int]string{
+	http.StatusBadRequest:      "invalid_request_error",
+	http.StatusUnauthorized:    "authentication_error",
+	http.StatusForbidden:       "permission_error",
+	http.StatusNotFound:        "not_found_error",
+	http.StatusRequestTimeout:  "timeout_error",
+	http.StatusTooManyRequests: "rate_limit_error",
+}
+
+// errorResponse answers with an Anthropic-style error, so the client
+// reports it (and retries 429 and 5xx) as it would for Anthropic
+func errorResponse(req *http.Request, status int, message string) *http.Response {
+	errorType, ok := errorTypes[status]
+	if !ok {
+		errorType = "api_error"
+	}
+	data, _ := json.Marshal(map[string]any{"type": "error", "error": map[string]string{"type": errorType, "message": message}})
+	return jsonResponse(req, status, data)
+}
+
+func jsonResponse(req *http.Request, status int, data []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+}
+
+// backendError extracts the message of a backend's error response; both
+// OpenAI and Gemini nest it as {"error": {"message": ...}}
+func backendError(data []byte) string {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+	text := strings.TrimSpace(string(data))
+	if len(text) > 500 {
+		text = text[:500] + "…"
+	}
+	return text
+}
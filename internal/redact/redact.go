@@ -0,0 +1,160 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"agent/internal/secrets"
+	"agent/internal/tools"
+)
+
+// Error message constants
+const (
+	errMsgInvalidPattern = "invalid redaction pattern %q: %w"
+)
+
+// minSecretLength skips resolved secrets too short to redact without mangling ordinary text
+const minSecretLength = 6
+
+// pattern is a named secret detector. When the expression has a "secret"
+// capture group only that part is replaced, so surrounding keys stay readable.
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// builtinPatterns detect common credential formats
+var builtinPatterns = []pattern{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"anthropic-key", regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{"openai-key", regexp.MustCompile(`sk-(?:proj-)?[A-Za-z0-9_\-]{32,}`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b|\bgithub_pat_[A-Za-z0-9_]{22,}\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}\b`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\b`)},
+	// Assignments to credential-like names are redacted only where the value
+	// is a literal: quoted, or on a .env or ini line (NAME=value, with no
+	// space around the =) or a YAML line (name: value). Unquoted values run
+	// to white space or a comment; quoted ones are left to the first form. Source code puts spaces around =, and its
+	// key: value fields end in a comma, so Password: cfg.Password, or
+	// token = os.Getenv(...) reach the model as written, or edits copying
+	// them back would not match the file.
+	{"credential-assignment", regexp.MustCompile(`(?i)\b[A-Z0-9_.\-]*` + credentialName + `[A-Z0-9_.\-]*["']?\s*(?::=|=>|[:=])\s*["'](?P<secret>[^\s"']{8,})["']`)},
+	{"credential-assignment", regexp.MustCompile(`(?im)^[ \t]*(?:export[ \t]+)?[A-Z0-9_.\-]*` + credentialName + `[A-Z0-9_.\-]*=(?P<secret>[^\s#"'][^\s#]{7,})`)},
+	{"credential-assignment", regexp.MustCompile(`(?im)^[ \t]*(?:-[ \t]+)?[A-Z0-9_.\-]*` + credentialName + `[A-Z0-9_.\-]*:[ \t]+(?P<secret>[^\s#"'][^\s#]{6,}[^\s#,])[ \t]*(?:#.*)?$`)},
+}
+
+// credentialName matches the part of a key that makes its value a credential
+const credentialName = `(?:api[_\-]?key|secret|token|passw(?:or)?d|credentials?)`
+
+// Redactor replaces secrets in text before it is sent to the model provider
+type Redactor struct {
+	patterns []pattern
+	enabled  atomic.Bool
+	count    atomic.Int64
+}
+
+// New creates a redactor with the built-in patterns plus any custom expressions
+func New(enabled bool, customPatterns []string) (*Redactor, error) {
+	patterns := append([]pattern{}, builtinPatterns...)
+	for i, expr := range customPatterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf(errMsgInvalidPattern, expr, err)
+		}
+		patterns = append(patterns, pattern{name: fmt.Sprintf("custom-%d", i+1), re: re})
+	}
+
+	r := &Redactor{patterns: patterns}
+	r.enabled.Store(enabled)
+	return r, nil
+}
+
+// Enabled reports whether redaction is active
+func (r *Redactor) Enabled() bool {
+	return r.enabled.Load()
+}
+
+// Count returns how many secrets have been redacted so far
+func (r *Redactor) Count() int64 {
+	return r.count.Load()
+}
+
+// Redact returns text with every detected secret replaced by a labeled marker
+func (r *Redactor) Redact(text string) string {
+	if r == nil || !r.Enabled() || text == "" {
+		return text
+	}
+
+	// Values resolved from secret:// config references are removed verbatim first
+	for _, value := range secrets.Values() {
+		if len(value) < minSecretLength || !strings.Contains(text, value) {
+			continue
+		}
+		r.count.Add(int64(strings.Count(text, value)))
+		text = strings.ReplaceAll(text, value, "[REDACTED:config-secret]")
+	}
+
+	for _, p := range r.patterns {
+		text = r.replace(p, text)
+	}
+	return text
+}
+
+func (r *Redactor) replace(p pattern, text string) string {
+	secretGroup := p.re.SubexpIndex("secret")
+	matches := p.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if secretGroup > 0 && m[2*secretGroup] >= 0 {
+			start, end = m[2*secretGroup], m[2*secretGroup+1]
+		}
+		// Skip values that are already redacted
+		if strings.HasPrefix(text[start:end], "[REDACTED") {
+			continue
+		}
+		result.WriteString(text[last:start])
+		result.WriteString("[REDACTED:" + p.name + "]")
+		last = end
+		r.count.Add(1)
+	}
+	result.WriteString(text[last:])
+	return result.String()
+}
+
+// Middleware redacts secrets from every tool result and error message
+func Middleware(r *Redactor) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			result, err := next(ctx, toolCtx, input)
+			if result != nil {
+				for i, block := range result.Content {
+					if block.Type == tools.ContentText {
+						result.Content[i].Text = r.Redact(block.Text)
+					}
+				}
+			}
+			if err != nil {
+				// Keep the classification so retries and hints still apply to the scrubbed error
+				redacted := errors.New(r.Redact(err.Error()))
+				if kind := tools.Classify(err); kind != tools.ErrorUnknown {
+					return result, &tools.ToolError{Kind: kind, Err: redacted}
+				}
+				return result, redacted
+			}
+			return result, nil
+		}
+	}
+}
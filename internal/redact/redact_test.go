@@ -0,0 +1,42 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactCredentialAssignments(t *testing.T) {
+	tests := []struct {
+		text     string
+		redacted bool
+	}{
+		{"DB_PASSWORD=hunter2hunter2", true},
+		{"DB_PASSWORD=p4ss.w0rd.XYZ", true},
+		{"API_SECRET=abc,def;ghi123", true},
+		{"export GITHUB_TOKEN=abc(def)ghi", true},
+		{"DB_PASSWORD=p4ss.w0rd.XYZ # production", true},
+		{"  password: hunter2.hunter2", true},
+		{"- api_key: k3y{with}[brackets]  # rotated monthly", true},
+		{`DB_PASSWORD="p4ss.w0rd.XYZ"`, true},
+		{`"password": "hunter2hunter2",`, true},
+		{"DB_PASSWORD=short", false},
+		{"\tPassword: cfg.Password,", false},
+		{"\ttokenCount: totalTokens,", false},
+		{"\tapiKey = os.Getenv(\"API_KEY\")", false},
+		{"\ttoken := loadToken(ctx)", false},
+		{"The password: see the runbook for details", false},
+	}
+	r, err := New(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got := r.Redact(tt.text)
+		if redacted := strings.Contains(got, "[REDACTED:credential-assignment]"); redacted != tt.redacted {
+			t.Errorf("Redact(%q) = %q, redacted %v, want %v", tt.text, got, redacted, tt.redacted)
+		}
+		if strings.Count(got, "[REDACTED") > 1 {
+			t.Errorf("Redact(%q) = %q, redacted twice", tt.text, got)
+		}
+	}
+}
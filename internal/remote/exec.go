@@ -0,0 +1,200 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/workspace"
+	"golang.org/x/crypto/ssh"
+)
+
+// pidTimeout is how long to wait for a started command to report its PID
+const pidTimeout = 5 * time.Second
+
+// Start runs a command on the host through the user's login shell, making
+// the Host a workspace.Runner. The script first prints its PID, which the
+// command keeps since the shell execs it; sshd makes it the leader of a
+// new session, so Terminate and Kill signal its whole process group from
+// another SSH session.
+func (h *Host) Start(c workspace.Cmd) (workspace.Process, error) {
+	session, err := h.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open an SSH session on %s: %w", h.target, err)
+	}
+	p := &process{host: h, session: session, pid: make(chan int, 1)}
+	session.Stdout = &pidWriter{next: orDiscard(c.Stdout), found: p.pid}
+	session.Stderr = orDiscard(c.Stderr)
+	if err := session.Start(script(c)); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start the command on %s: %w", h.target, err)
+	}
+	return p, nil
+}
+
+// script is the shell command line that runs c: print the PID, change to
+// the working directory, export the variables and exec the command.
+// Variable values are double-quoted so they can reference the host's
+// variables, e.g. "$PATH:./bin".
+func script(c workspace.Cmd) string {
+	parts := []string{"echo $$"}
+	if c.Dir != "" {
+		parts = append(parts, "cd "+quote(c.Dir))
+	}
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("export %s=%s", name, doubleQuote(c.Env[name])))
+	}
+	words := make([]string, len(c.Argv))
+	for i, word := range c.Argv {
+		words[i] = quote(word)
+	}
+	parts = append(parts, "exec "+strings.Join(words, " "))
+	return strings.Join(parts, " && ")
+}
+
+// quote makes a POSIX shell word of value, with no expansion
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// doubleQuote makes a POSIX shell word of value that still expands $VARS
+func doubleQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(value) + `"`
+}
+
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// process is a command running on the host
+type process struct {
+	host    *Host
+	session *ssh.Session
+	pid     chan int // Receives the PID once the script prints it
+
+	mutex sync.Mutex
+	known int
+}
+
+// Wait waits for the command; its exit code is reported through
+// workspace.ExitCoder
+func (p *process) Wait() error {
+	err := p.session.Wait()
+	p.session.Close()
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitStatus()
+		if exitErr.Signal() != "" {
+			code = -1
+		}
+		return &exitError{code: code, msg: exitErr.Error()}
+	}
+	return err
+}
+
+// Terminate sends SIGTERM to the command's process group
+func (p *process) Terminate() {
+	p.signal("TERM")
+}
+
+// Kill sends SIGKILL to the command's process group and closes its session
+func (p *process) Kill() {
+	p.signal("KILL")
+	p.session.Close()
+}
+
+func (p *process) ID() string {
+	if pid := p.lookupPID(pidTimeout); pid > 0 {
+		return fmt.Sprintf("pid %d on %s", pid, p.host.target.Host)
+	}
+	return "on " + p.host.target.Host
+}
+
+// signal signals the process group, or the process if it leads none, from
+// a session of its own. Without a PID the session is closed instead.
+func (p *process) signal(name string) {
+	pid := p.lookupPID(pidTimeout)
+	if pid <= 0 {
+		p.session.Close()
+		return
+	}
+	session, err := p.host.client.NewSession()
+	if err != nil {
+		p.session.Close()
+		return
+	}
+	defer session.Close()
+	session.Run(fmt.Sprintf("kill -%s -- -%d 2>/dev/null || kill -%s %d", name, pid, name, pid))
+}
+
+// lookupPID returns the command's PID, waiting up to timeout for it
+func (p *process) lookupPID(timeout time.Duration) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.known > 0 {
+		return p.known
+	}
+	select {
+	case p.known = <-p.pid:
+	default:
+		select {
+		case p.known = <-p.pid:
+		case <-time.After(timeout):
+		}
+	}
+	return p.known
+}
+
+// exitError is the error of a command that exited unsuccessfully
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string { return e.msg }
+func (e *exitError) ExitCode() int { return e.code }
+
+// pidWriter takes the PID from the first line of output and passes the
+// rest through
+type pidWriter struct {
+	next  io.Writer
+	found chan<- int
+	line  []byte
+	done  bool
+}
+
+func (w *pidWriter) Write(data []byte) (int, error) {
+	if w.done {
+		return w.next.Write(data)
+	}
+	n := len(data)
+	w.line = append(w.line, data...)
+	end := bytes.IndexByte(w.line, '\n')
+	if end < 0 {
+		return n, nil
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(w.line[:end])))
+	w.found <- pid
+	w.done = true
+	if rest := w.line[end+1:]; len(rest) > 0 {
+		if _, err := w.next.Write(rest); err != nil {
+			return n, err
+		}
+	}
+	w.line = nil
+	return n, nil
+}
@@ -0,0 +1,171 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+
+	"agent/internal/workspace"
+	"github.com/pkg/sftp"
+)
+
+// The Host methods below make it a workspace.FS over SFTP. Errors are
+// wrapped in *fs.PathError, as the os package's are.
+
+func (h *Host) ReadFile(path string) ([]byte, error) {
+	file, err := h.files.Open(path)
+	if err != nil {
+		return nil, pathError("open", path, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, pathError("read", path, err)
+	}
+	return data, nil
+}
+
+func (h *Host) Open(path string) (io.ReadCloser, error) {
+	file, err := h.files.Open(path)
+	if err != nil {
+		return nil, pathError("open", path, err)
+	}
+	return file, nil
+}
+
+// WriteFile truncates or creates path. New files get the server's default
+// mode, usually 0644, rather than perm.
+func (h *Host) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	file, err := h.files.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return pathError("open", path, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return pathError("write", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return pathError("close", path, err)
+	}
+	return nil
+}
+
+// WriteFileAtomic writes a temporary file next to path and renames it
+// over path. The file is synced when the server supports fsync@openssh.com;
+// servers without posix-rename@openssh.com cannot rename over a file, so
+// there the old file is removed first and the replacement is not atomic.
+func (h *Host) WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	original, err := h.files.Stat(path)
+	if err == nil {
+		perm = original.Mode().Perm()
+	}
+	temp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp-%d", filepath.Base(path), rand.Int64()))
+	file, err := h.files.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return pathError("open", temp, err)
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			file.Close()
+			h.files.Remove(temp)
+		}
+	}()
+	if _, err := file.Write(data); err != nil {
+		return pathError("write", temp, err)
+	}
+	if _, ok := h.files.HasExtension("fsync@openssh.com"); ok {
+		if err := file.Sync(); err != nil {
+			return pathError("sync", temp, err)
+		}
+	}
+	if err := file.Chmod(perm); err != nil {
+		return pathError("chmod", temp, err)
+	}
+	if original != nil {
+		// Only allowed when the login user may give the file away; otherwise
+		// it is owned by the login user, as with any other write
+		if stat, ok := original.Sys().(*sftp.FileStat); ok {
+			file.Chown(int(stat.UID), int(stat.GID))
+		}
+	}
+	if err := file.Close(); err != nil {
+		return pathError("close", temp, err)
+	}
+	if _, ok := h.files.HasExtension("posix-rename@openssh.com"); ok {
+		err = h.files.PosixRename(temp, path)
+	} else {
+		if err = h.files.Remove(path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			err = h.files.Rename(temp, path)
+		}
+	}
+	if err != nil {
+		return pathError("rename", path, err)
+	}
+	renamed = true
+	return nil
+}
+
+func (h *Host) MkdirAll(path string, perm fs.FileMode) error {
+	return pathError("mkdir", path, h.files.MkdirAll(path))
+}
+
+func (h *Host) Remove(path string) error {
+	return pathError("remove", path, h.files.Remove(path))
+}
+
+func (h *Host) Stat(path string) (fs.FileInfo, error) {
+	info, err := h.files.Stat(path)
+	return info, pathError("stat", path, err)
+}
+
+func (h *Host) Lstat(path string) (fs.FileInfo, error) {
+	info, err := h.files.Lstat(path)
+	return info, pathError("lstat", path, err)
+}
+
+// EvalSymlinks asks the server for the canonical path, which resolves
+// every symlink in it
+func (h *Host) EvalSymlinks(path string) (string, error) {
+	resolved, err := h.files.RealPath(path)
+	return resolved, pathError("realpath", path, err)
+}
+
+// Walk visits the tree under root in lexical order, like filepath.Walk.
+// Several directories are listed at once, since each listing is a round
+// trip to the server.
+func (h *Host) Walk(root string, fn filepath.WalkFunc) error {
+	return workspace.WalkParallel(root, h.Lstat, h.readDir, fn)
+}
+
+func (h *Host) readDir(path string) ([]fs.DirEntry, error) {
+	infos, err := h.files.ReadDir(path)
+	if err != nil {
+		return nil, pathError("readdir", path, err)
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+func (h *Host) Glob(pattern string) ([]string, error) {
+	return h.files.Glob(pattern)
+}
+
+// pathError adds the operation and path to an SFTP error, keeping
+// errors.Is(err, fs.ErrNotExist) working
+func pathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*fs.PathError); ok {
+		return err
+	}
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
@@ -0,0 +1,308 @@
+// Package remote reaches workspaces on other machines, such as a dev box
+// behind a jump host: files over SFTP and commands over SSH. A Host is
+// both the workspace.FS and the workspace.Runner of a remote workspace.
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Scheme prefixes workspace roots on another machine
+const Scheme = "ssh://"
+
+// defaultIdentityFiles are tried when ssh.identity_files is not set
+var defaultIdentityFiles = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+
+// Target is a host to connect to, and for workspaces the directory on it
+type Target struct {
+	User string
+	Host string
+	Port string
+	Path string // Absolute, or starting with ~ for the user's home directory
+}
+
+// IsTarget reports whether a workspace root names a remote workspace
+func IsTarget(root string) bool {
+	return strings.HasPrefix(root, Scheme)
+}
+
+// ParseTarget parses ssh://[user@]host[:port][/path]. The user defaults to
+// the local one and the port to 22.
+func ParseTarget(spec string) (Target, error) {
+	parsed, err := url.Parse(spec)
+	if err != nil || parsed.Scheme != "ssh" || parsed.Hostname() == "" {
+		return Target{}, fmt.Errorf("invalid remote %q: expected ssh://[user@]host[:port]/path", spec)
+	}
+	target := Target{User: parsed.User.Username(), Host: parsed.Hostname(), Port: parsed.Port(), Path: parsed.Path}
+	if target.User == "" {
+		target.User = localUser()
+	}
+	if target.Port == "" {
+		target.Port = "22"
+	}
+	// ssh://host/~/src is relative to the home directory
+	if rest, ok := strings.CutPrefix(target.Path, "/~"); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+		target.Path = "~" + rest
+	}
+	return target, nil
+}
+
+// String returns user@host, with the port when it is not 22
+func (t Target) String() string {
+	if t.Port != "22" {
+		return fmt.Sprintf("%s@%s:%s", t.User, t.Host, t.Port)
+	}
+	return t.User + "@" + t.Host
+}
+
+func (t Target) address() string {
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+func localUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Host is an SSH connection to a machine with an SFTP session over it
+type Host struct {
+	target Target
+	client *ssh.Client
+	jump   *ssh.Client // nil without a jump host
+	files  *sftp.Client
+	home   string
+}
+
+// Dial connects to target, through the configured jump host if any.
+// Hosts are authenticated against the known_hosts files and users with the
+// SSH agent's keys and the identity files.
+func Dial(ctx context.Context, target Target, cfg config.SSHConfig) (*Host, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.TimeoutOrDefault())
+	defer cancel()
+
+	auth, err := authMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+	host := &Host{target: target}
+	var conn net.Conn
+	if cfg.Jump != "" {
+		jump, err := ParseTarget(cfg.Jump)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh.jump: %w", err)
+		}
+		host.jump, err = dialClient(ctx, jump, nil, cfg, auth)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = host.jump.DialContext(ctx, "tcp", target.address())
+		if err != nil {
+			host.Close()
+			return nil, fmt.Errorf("failed to reach %s through %s: %w", target.Host, jump.Host, err)
+		}
+	}
+	host.client, err = dialClient(ctx, target, conn, cfg, auth)
+	if err != nil {
+		host.Close()
+		return nil, err
+	}
+	host.files, err = sftp.NewClient(host.client)
+	if err != nil {
+		host.Close()
+		return nil, fmt.Errorf("failed to start SFTP on %s: %w", target, err)
+	}
+	host.home, err = host.files.Getwd()
+	if err != nil {
+		host.Close()
+		return nil, fmt.Errorf("failed to find the home directory on %s: %w", target, err)
+	}
+	return host, nil
+}
+
+// dialClient opens an SSH connection to target over conn, or over a new
+// TCP connection when conn is nil
+func dialClient(ctx context.Context, target Target, conn net.Conn, cfg config.SSHConfig, auth []ssh.AuthMethod) (*ssh.Client, error) {
+	hostKeys, algorithms, err := hostKeyCheck(target, cfg.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig := &ssh.ClientConfig{
+		User:              target.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeys,
+		HostKeyAlgorithms: algorithms,
+		Timeout:           cfg.TimeoutOrDefault(),
+	}
+	if conn == nil {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", target.address())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+		}
+	}
+	// The handshake has no context of its own
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, target.address(), clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
+
+// hostKeyCheck verifies host keys against the known_hosts files. It also
+// returns the algorithms of the keys known for target, so the server is
+// asked for one of those rather than a type that would not match.
+func hostKeyCheck(target Target, files []string) (ssh.HostKeyCallback, []string, error) {
+	if len(files) == 0 {
+		files = []string{"~/.ssh/known_hosts"}
+	}
+	var existing []string
+	for _, file := range files {
+		if path := expandHome(file); fileExists(path) {
+			existing = append(existing, path)
+		}
+	}
+	if len(existing) == 0 {
+		return nil, nil, fmt.Errorf("no known_hosts file found (%s); connect once with ssh %s to trust the host", strings.Join(files, ", "), target)
+	}
+	callback, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	// Checking a key no host has reveals which keys are known for this one
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil, nil, err
+	}
+	var keyErr *knownhosts.KeyError
+	if err := callback(target.address(), &net.TCPAddr{}, probe); !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return nil, nil, fmt.Errorf("%s is not in known_hosts; connect once with ssh %s to trust it", target.Host, target)
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		keyType := known.Key.Type()
+		if keyType == ssh.KeyAlgoRSA {
+			// RSA keys are also used with the SHA-2 signature algorithms
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		if !slices.Contains(algorithms, keyType) {
+			algorithms = append(algorithms, keyType)
+		}
+	}
+	return callback, algorithms, nil
+}
+
+// authMethods offers the SSH agent's keys, then the identity files.
+// Encrypted keys are skipped; add them to the agent instead.
+func authMethods(cfg config.SSHConfig) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	files := cfg.IdentityFiles
+	if len(files) == 0 {
+		files = defaultIdentityFiles
+	}
+	var signers []ssh.Signer
+	for _, file := range files {
+		data, err := os.ReadFile(expandHome(file))
+		if os.IsNotExist(err) && len(cfg.IdentityFiles) == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the identity file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity file %s: %w", file, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no SSH keys available: start ssh-agent and add a key, or set ssh.identity_files")
+	}
+	return methods, nil
+}
+
+// Abs resolves a path on the host: ~ is the user's home directory and
+// relative paths are relative to it
+func (h *Host) Abs(path string) string {
+	switch {
+	case path == "" || path == "~":
+		return h.home
+	case strings.HasPrefix(path, "~/"):
+		return filepath.Join(h.home, path[2:])
+	case !filepath.IsAbs(path):
+		return filepath.Join(h.home, path)
+	}
+	return path
+}
+
+// String names the host as user@host
+func (h *Host) String() string {
+	return h.target.String()
+}
+
+// Close ends the SFTP session and the connections
+func (h *Host) Close() error {
+	var errs []error
+	if h.files != nil {
+		errs = append(errs, h.files.Close())
+	}
+	if h.client != nil {
+		errs = append(errs, h.client.Close())
+	}
+	if h.jump != nil {
+		errs = append(errs, h.jump.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// expandHome replaces a leading ~ with the local home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+)
+
+// maxWidth keeps rendered paragraphs readable on very wide terminals
+const maxWidth = 120
+
+// Markdown renders model responses for the terminal: headings, lists,
+// emphasis and tables are styled, and fenced code blocks are syntax
+// highlighted according to their language
+type Markdown struct {
+	renderer *glamour.TermRenderer
+}
+
+// Styles for NewMarkdown
+const (
+	StyleDark  = styles.DarkStyle  // Colors for dark terminal backgrounds
+	StyleLight = styles.LightStyle // Colors for light terminal backgrounds
+	StylePlain = styles.NoTTYStyle // Structure and wrapping without colors
+)
+
+// NewMarkdown creates a renderer that wraps text at width columns in one
+// of the styles above
+func NewMarkdown(width int, style string) (*Markdown, error) {
+	if width <= 0 || width > maxWidth {
+		width = maxWidth
+	}
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(style),
+		glamour.WithWordWrap(width),
+		glamour.WithEmoji(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Markdown{renderer: renderer}, nil
+}
+
+// Render returns text rendered for the terminal, or text unchanged when it
+// cannot be rendered
+func (m *Markdown) Render(text string) string {
+	out, err := m.renderer.Render(text)
+	if err != nil {
+		return text
+	}
+	return strings.Trim(out, "\n")
+}
@@ -0,0 +1,166 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"agent/internal/logging"
+	"agent/internal/tools"
+)
+
+// Entry is one recorded tool call. Session files hold one entry per line.
+type Entry struct {
+	Time       time.Time       `json:"time"`
+	Tool       string          `json:"tool"`
+	Input      json.RawMessage `json:"input"`
+	Result     *Result         `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	ErrorKind  tools.ErrorKind `json:"error_kind,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// Result is the serialized form of a tools.ToolResult
+type Result struct {
+	Content      []Block         `json:"content"`
+	IsError      bool            `json:"is_error,omitempty"`
+	ErrorKind    tools.ErrorKind `json:"error_kind,omitempty"`
+	FilesChanged []string        `json:"files_changed,omitempty"`
+}
+
+// Block is the serialized form of a tools.ContentBlock; image data is base64-encoded
+type Block struct {
+	Type      tools.ContentType `json:"type"`
+	Text      string            `json:"text,omitempty"`
+	MediaType string            `json:"media_type,omitempty"`
+	Data      []byte            `json:"data,omitempty"`
+}
+
+// Log appends recorded tool calls to a session file. A nil *Log records nothing.
+type Log struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Create opens a session file for recording, truncating any existing content
+func Create(path string) (*Log, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session file: %w", err)
+	}
+	return &Log{file: file}, nil
+}
+
+// Close flushes and closes the session file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Append writes one entry as a JSON line
+func (l *Log) Append(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// Load reads every entry from a session file
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("session file line %d: %w", lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	return entries, nil
+}
+
+// Middleware records every tool call and its outcome. Install it outside the
+// redaction middleware so session files never contain the secrets that
+// redaction removes.
+func Middleware(l *Log) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, toolCtx, input)
+			if l == nil || toolCtx == nil || toolCtx.Tool == nil {
+				return result, err
+			}
+
+			entry := Entry{
+				Time:       start,
+				Tool:       toolCtx.Tool.Name,
+				Input:      input,
+				Result:     toResult(result),
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				entry.Error = err.Error()
+				entry.ErrorKind = tools.Classify(err)
+			}
+			if appendErr := l.Append(entry); appendErr != nil {
+				logging.For(logging.Tools).Warn("failed to record tool call", "tool", entry.Tool, "error", appendErr)
+			}
+			return result, err
+		}
+	}
+}
+
+func toResult(result *tools.ToolResult) *Result {
+	if result == nil {
+		return nil
+	}
+	converted := &Result{
+		IsError:      result.IsError,
+		ErrorKind:    result.ErrorKind,
+		FilesChanged: result.Metadata.FilesChanged,
+	}
+	for _, block := range result.Content {
+		converted.Content = append(converted.Content, Block(block))
+	}
+	return converted
+}
+
+// ToolResult converts a recorded result back into a tools.ToolResult
+func (r *Result) ToolResult() *tools.ToolResult {
+	if r == nil {
+		return nil
+	}
+	result := &tools.ToolResult{IsError: r.IsError, ErrorKind: r.ErrorKind}
+	result.Metadata.FilesChanged = r.FilesChanged
+	for _, block := range r.Content {
+		result.Content = append(result.Content, tools.ContentBlock(block))
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"agent/internal/tools"
+)
+
+// maxPreviewLines limits how much of each result is printed during replay
+const maxPreviewLines = 20
+
+// Options controls a replay run
+type Options struct {
+	Execute bool // Re-run each call against the registry instead of only printing the recording
+}
+
+// Summary counts the outcomes of a replay
+type Summary struct {
+	Calls     int
+	Matched   int // Re-executed calls whose result matched the recording
+	Differed  int // Re-executed calls whose result or error differed
+	Unhandled int // Calls to tools that are no longer available
+}
+
+// Run walks through recorded calls in order. By default it only simulates
+// them, printing each call with its recorded result. With Execute set, each
+// call is run again through the registry (including its middleware chain,
+// so confirmations and permissions still apply) and compared with the
+// recording, which makes a session file usable as a regression check.
+func Run(ctx context.Context, entries []Entry, registry *tools.Registry, toolCtx *tools.ToolContext, opts Options, out io.Writer) (Summary, error) {
+	var summary Summary
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		summary.Calls++
+
+		fmt.Fprintf(out, "#%d %s %s(%s)\n", i+1, entry.Time.Format("15:04:05"), entry.Tool, entry.Input)
+		recorded := describe(entry.Result.ToolResult(), entry.Error)
+		if !opts.Execute {
+			fmt.Fprintln(out, indent(recorded))
+			continue
+		}
+
+		tool, err := registry.Resolve(entry.Tool)
+		if err != nil {
+			summary.Unhandled++
+			fmt.Fprintf(out, "  skipped: %v\n", err)
+			continue
+		}
+
+		callCtx := *toolCtx
+		callCtx.Tool = nil
+		result, err := tool.Function(ctx, &callCtx, entry.Input)
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+
+		if matches(entry, result, errText) {
+			summary.Matched++
+			fmt.Fprintln(out, "  result matches recording")
+			continue
+		}
+		summary.Differed++
+		fmt.Fprintf(out, "  result differs from recording\n  recorded:\n%s\n  now:\n%s\n",
+			indent(indent(recorded)), indent(indent(describe(result, errText))))
+	}
+
+	if opts.Execute {
+		fmt.Fprintf(out, "\n%d calls: %d matched, %d differed, %d skipped\n", summary.Calls, summary.Matched, summary.Differed, summary.Unhandled)
+	}
+	return summary, nil
+}
+
+func matches(entry Entry, result *tools.ToolResult, errText string) bool {
+	if entry.Error != "" || errText != "" {
+		return entry.Error == errText
+	}
+	recorded := entry.Result
+	current := toResult(result)
+	if recorded == nil || current == nil {
+		return recorded == nil && current == nil
+	}
+	return recorded.IsError == current.IsError && reflect.DeepEqual(recorded.Content, current.Content)
+}
+
+func describe(result *tools.ToolResult, errText string) string {
+	if errText != "" {
+		return "error: " + errText
+	}
+	if result == nil {
+		return "(no output)"
+	}
+
+	var parts []string
+	if result.IsError {
+		parts = append(parts, "error result:")
+	}
+	for _, block := range result.Content {
+		if block.Type == tools.ContentImage {
+			parts = append(parts, fmt.Sprintf("[image %s, %d bytes]", block.MediaType, len(block.Data)))
+			continue
+		}
+		parts = append(parts, truncate(block.Text))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func truncate(text string) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= maxPreviewLines {
+		return text
+	}
+	return strings.Join(lines[:maxPreviewLines], "\n") + fmt.Sprintf("\n... (%d more lines)", len(lines)-maxPreviewLines)
+}
+
+func indent(text string) string {
+	return "  " + strings.ReplaceAll(text, "\n", "\n  ")
+}
@@ -0,0 +1,52 @@
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"agent/internal/tools"
+)
+
+// Default retry settings used by main
+const (
+	DefaultAttempts  = 3
+	DefaultBaseDelay = 500 * time.Millisecond
+)
+
+// Middleware retries tool calls that fail with a transient error, waiting
+// baseDelay before the second attempt and doubling it each time after.
+// Mutating tools are never retried, because a failure part way through a
+// write or command may already have had side effects; their transient
+// errors are returned to the model with a hint instead.
+func Middleware(attempts int, baseDelay time.Duration) tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			if toolCtx != nil && toolCtx.Tool != nil && toolCtx.Tool.Mutating {
+				return next(ctx, toolCtx, input)
+			}
+
+			delay := baseDelay
+			for attempt := 1; ; attempt++ {
+				result, err := next(ctx, toolCtx, input)
+				if attempt >= attempts || !isTransient(result, err) || ctx.Err() != nil {
+					return result, err
+				}
+
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return result, err
+				}
+				delay *= 2
+			}
+		}
+	}
+}
+
+func isTransient(result *tools.ToolResult, err error) bool {
+	if err != nil {
+		return tools.Classify(err) == tools.ErrorTransient
+	}
+	return result != nil && result.IsError && result.ErrorKind == tools.ErrorTransient
+}
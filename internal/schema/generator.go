@@ -1,15 +1,23 @@
 package schema
 
 import (
+	"encoding/json"
+
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/invopop/jsonschema"
 )
 
-// GenerateSchema creates a JSON schema for the given type T
+// GenerateSchema creates a JSON schema for the given type T. Struct tags
+// control the output:
+//   - `jsonschema:"required"` adds the field to the required list
+//   - `jsonschema:"enum=a,enum=b"` restricts values
+//   - `jsonschema:"default=x"` documents the default
+//   - nested structs, slices and maps are emitted inline
 func GenerateSchema[T any]() anthropic.ToolInputSchemaParam {
 	reflector := jsonschema.Reflector{
-		AllowAdditionalProperties: false,
-		DoNotReference:            true,
+		AllowAdditionalProperties:  false,
+		DoNotReference:             true,
+		RequiredFromJSONSchemaTags: true,
 	}
 	var v T
 
@@ -17,5 +25,33 @@ func GenerateSchema[T any]() anthropic.ToolInputSchemaParam {
 
 	return anthropic.ToolInputSchemaParam{
 		Properties: schema.Properties,
+		Required:   schema.Required,
+		ExtraFields: map[string]any{
+			"additionalProperties": false,
+		},
+	}
+}
+
+// FromJSON converts a JSON schema object received from an external tool
+// provider (a plugin or MCP server) into a tool input schema. An explicit
+// "additionalProperties": false is preserved so validation rejects unknown
+// parameters; otherwise the schema stays open.
+func FromJSON(raw json.RawMessage) (anthropic.ToolInputSchemaParam, error) {
+	if len(raw) == 0 {
+		return anthropic.ToolInputSchemaParam{}, nil
+	}
+
+	var s jsonschema.Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return anthropic.ToolInputSchemaParam{}, err
+	}
+
+	param := anthropic.ToolInputSchemaParam{
+		Properties: s.Properties,
+		Required:   s.Required,
+	}
+	if isFalseSchema(s.AdditionalProperties) {
+		param.ExtraFields = map[string]any{"additionalProperties": false}
 	}
+	return param, nil
 }
@@ -0,0 +1,246 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/invopop/jsonschema"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// ValidationError lists every problem found in a tool input, formatted so
+// the model can correct its call in one attempt
+type ValidationError struct {
+	Problems []string
+	Expected string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid tool input:\n")
+	for _, problem := range e.Problems {
+		b.WriteString("- " + problem + "\n")
+	}
+	if e.Expected != "" {
+		b.WriteString("Expected parameters: " + e.Expected)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ValidateInput checks a raw tool input against the tool's input schema:
+// it must be a JSON object, contain every required property, and use the
+// declared types and enum values. Unknown properties are rejected when the
+// schema sets additionalProperties to false (as GenerateSchema does).
+func ValidateInput(inputSchema anthropic.ToolInputSchemaParam, input json.RawMessage) error {
+	if len(bytes.TrimSpace(input)) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Problems: []string{fmt.Sprintf("input is not valid JSON: %v", err)}}
+	}
+
+	properties, _ := inputSchema.Properties.(*orderedmap.OrderedMap[string, *jsonschema.Schema])
+	root := &jsonschema.Schema{
+		Type:       "object",
+		Properties: properties,
+		Required:   inputSchema.Required,
+	}
+	if allowed, ok := inputSchema.ExtraFields["additionalProperties"].(bool); ok && !allowed {
+		root.AdditionalProperties = jsonschema.FalseSchema
+	}
+
+	var problems []string
+	validateValue(root, value, "", &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems, Expected: describeProperties(root)}
+}
+
+func validateValue(s *jsonschema.Schema, value any, path string, problems *[]string) {
+	if s == nil {
+		return
+	}
+
+	if s.Type != "" && !matchesType(s.Type, value) {
+		*problems = append(*problems, fmt.Sprintf("%s must be %s, got %s", label(path), article(s.Type), jsonType(value)))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s must be one of %s", label(path), formatEnum(s.Enum)))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("missing required parameter %q", join(path, name)))
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := v[name]
+			var propSchema *jsonschema.Schema
+			if s.Properties != nil {
+				propSchema, _ = s.Properties.Get(name)
+			}
+			if propSchema == nil {
+				if isFalseSchema(s.AdditionalProperties) {
+					*problems = append(*problems, fmt.Sprintf("unknown parameter %q", join(path, name)))
+				}
+				continue
+			}
+			validateValue(propSchema, child, join(path, name), problems)
+		}
+	case []any:
+		for i, item := range v {
+			validateValue(s.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	}
+}
+
+func matchesType(schemaType string, value any) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "an integer"
+		}
+		return "a number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func article(schemaType string) string {
+	switch schemaType {
+	case "object", "array", "integer":
+		return "an " + schemaType
+	}
+	return "a " + schemaType
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if n, ok := value.(json.Number); ok {
+			if fmt.Sprint(allowed) == n.String() {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []any) string {
+	values := make([]string, len(enum))
+	for i, v := range enum {
+		values[i] = fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+	return strings.Join(values, ", ")
+}
+
+// describeProperties summarizes the top-level parameters, e.g. "path (string, required), offset (integer)"
+func describeProperties(s *jsonschema.Schema) string {
+	if s.Properties == nil {
+		return ""
+	}
+
+	required := make(map[string]bool)
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	var parts []string
+	for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		detail := pair.Value.Type
+		if required[pair.Key] {
+			detail += ", required"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", pair.Key, detail))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func label(path string) string {
+	if path == "" {
+		return "input"
+	}
+	return fmt.Sprintf("parameter %q", path)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// isFalseSchema reports whether s rejects every value, i.e. "additionalProperties": false
+func isFalseSchema(s *jsonschema.Schema) bool {
+	if s == nil {
+		return false
+	}
+	if s == jsonschema.FalseSchema {
+		return true
+	}
+	// Boolean schemas are unexported inside jsonschema.Schema, so compare the encoding
+	encoded, err := json.Marshal(s)
+	return err == nil && string(encoded) == "false"
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Prefix marks a config value as a secret reference, e.g. secret://keychain/github-token
+const Prefix = "secret://"
+
+// Error message constants
+const (
+	errMsgMalformedRef   = "malformed secret reference %q (expected secret://<store>/<name>)"
+	errMsgUnknownStore   = "unknown secret store %q in %q (supported: keychain, env, file)"
+	errMsgLookupFailed   = "failed to read secret %q from %s: %w"
+	errMsgNoKeychain     = "no keychain is available on %s"
+	errMsgEmptySecret    = "secret %q is empty"
+	errMsgEnvSecretUnset = "secret %q: environment variable %s is not set"
+)
+
+// resolved remembers every secret value looked up, so output can be scrubbed of them
+var resolved struct {
+	mutex  sync.Mutex
+	values []string
+}
+
+// IsRef reports whether a config value is a secret reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Resolve looks up a secret reference. Supported stores:
+//
+//	secret://keychain/NAME  the OS keychain: macOS Keychain (generic password
+//	                        with service NAME) or the Secret Service on Linux
+//	                        (secret-tool attribute service=NAME)
+//	secret://env/NAME       the environment variable NAME
+//	secret://file/PATH      the contents of PATH, trailing newline removed
+func Resolve(ref string) (string, error) {
+	store, name, ok := strings.Cut(strings.TrimPrefix(ref, Prefix), "/")
+	if !IsRef(ref) || !ok || name == "" {
+		return "", fmt.Errorf(errMsgMalformedRef, ref)
+	}
+
+	var value string
+	var err error
+	switch store {
+	case "keychain":
+		value, err = keychain(name)
+	case "env":
+		var set bool
+		value, set = os.LookupEnv(name)
+		if !set {
+			return "", fmt.Errorf(errMsgEnvSecretUnset, ref, name)
+		}
+	case "file":
+		var data []byte
+		data, err = os.ReadFile(name)
+		value = strings.TrimRight(string(data), "\r\n")
+	default:
+		return "", fmt.Errorf(errMsgUnknownStore, store, ref)
+	}
+	if err != nil {
+		return "", fmt.Errorf(errMsgLookupFailed, ref, store, err)
+	}
+	if value == "" {
+		return "", fmt.Errorf(errMsgEmptySecret, ref)
+	}
+
+	resolved.mutex.Lock()
+	if !slices.Contains(resolved.values, value) {
+		resolved.values = append(resolved.values, value)
+	}
+	resolved.mutex.Unlock()
+	return value, nil
+}
+
+// Values returns every secret resolved so far
+func Values() []string {
+	resolved.mutex.Lock()
+	defer resolved.mutex.Unlock()
+	return append([]string(nil), resolved.values...)
+}
+
+// keychain reads a secret from the platform keychain through its command line tool
+func keychain(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", name, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", name)
+	default:
+		return "", fmt.Errorf(errMsgNoKeychain, runtime.GOOS)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// StoreKeychain saves a secret in the platform keychain under name,
+// replacing any existing entry, so secret://keychain/NAME resolves to it
+func StoreKeychain(name, value string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", name, "-a", name, "-w", value)
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "store", "--label", name, "service", name)
+		cmd.Stdin = strings.NewReader(value)
+	default:
+		return fmt.Errorf(errMsgNoKeychain, runtime.GOOS)
+	}
+	return runKeychain(cmd)
+}
+
+// DeleteKeychain removes the keychain entry stored under name
+func DeleteKeychain(name string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", name)
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "clear", "service", name)
+	default:
+		return fmt.Errorf(errMsgNoKeychain, runtime.GOOS)
+	}
+	return runKeychain(cmd)
+}
+
+// runKeychain runs a keychain command, including its stderr in the error
+func runKeychain(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%w: %s", err, message)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"agent/internal/config"
+)
+
+// TokenEnv passes a session server the token it requires from every
+// request. The gateway sets it for the sessions it starts, so only the
+// gateway can reach them.
+const TokenEnv = "BILLDOZER_SERVE_TOKEN"
+
+// errUnauthenticated is returned when a request carries no credentials
+var errUnauthenticated = errors.New("authentication required")
+
+// Authenticator maps bearer tokens to the principals they belong to: API
+// keys directly, OIDC tokens by their subject or verified email
+type Authenticator struct {
+	principals []config.PrincipalConfig
+	oidc       *oidcVerifier
+}
+
+// NewAuthenticator authenticates the configured principals. OIDC tokens
+// are accepted when an issuer is configured; client fetches its keys.
+func NewAuthenticator(cfg config.ServerConfig, client *http.Client) *Authenticator {
+	auth := &Authenticator{principals: cfg.Principals}
+	if cfg.OIDC.Issuer != "" {
+		auth.oidc = newOIDCVerifier(cfg.OIDC.Issuer, cfg.OIDC.Audience, client)
+	}
+	return auth
+}
+
+// Authenticate returns the principal of the request's bearer token
+func (a *Authenticator) Authenticate(r *http.Request) (*config.PrincipalConfig, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, errUnauthenticated
+	}
+	// Every key is compared, so timing does not reveal which one nearly matched
+	var match *config.PrincipalConfig
+	for i := range a.principals {
+		if key := a.principals[i].APIKey; key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			match = &a.principals[i]
+		}
+	}
+	if match != nil {
+		return match, nil
+	}
+	if a.oidc == nil || strings.Count(token, ".") != 2 {
+		return nil, errors.New("invalid API key")
+	}
+
+	claims, err := a.oidc.verify(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	for i, principal := range a.principals {
+		if principal.Subject != "" && principal.Subject == claims.Subject {
+			return &a.principals[i], nil
+		}
+		if principal.Email != "" && claims.EmailVerified && strings.EqualFold(principal.Email, claims.Email) {
+			return &a.principals[i], nil
+		}
+	}
+	return nil, errors.New("the token's subject is not a configured principal")
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, or
+// of the access_token query parameter, since browsers cannot set headers
+// on EventSource and WebSocket requests
+func bearerToken(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// RequireToken refuses requests that do not carry token as their bearer token
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(token)) != 1 {
+			unauthorized(w, errUnauthenticated)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="billdozer"`)
+	writeError(w, http.StatusUnauthorized, err.Error())
+}
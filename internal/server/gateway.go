@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/config"
+)
+
+// ReadyMessage starts the line a session server prints to standard error,
+// followed by its URL, once it accepts requests
+const ReadyMessage = "Serving the session on"
+
+// startTimeout is how long a principal's session may take to start serving
+const startTimeout = 30 * time.Second
+
+// errShuttingDown is returned for requests that arrive during shutdown
+var errShuttingDown = errors.New("the server is shutting down")
+
+// Launcher returns the command that runs the session server of a
+// principal: "billdozer serve" on a free local port, restricted by the
+// principal's profile and workspace
+type Launcher func(principal config.PrincipalConfig) *exec.Cmd
+
+// Gateway serves a session per principal. Requests are authenticated, and
+// those of each principal are proxied to a session server of their own,
+// started on first use. Sessions are separate processes, so the tools,
+// permissions and workspace of one principal never leak into another's.
+type Gateway struct {
+	auth    *Authenticator
+	launch  Launcher
+	logger  *slog.Logger
+	running sync.WaitGroup
+
+	mutex    sync.Mutex
+	sessions map[string]*principalSession // By principal name
+	closed   bool
+}
+
+// principalSession is the session server of one principal
+type principalSession struct {
+	ready   chan struct{} // Closed once proxy or err is set
+	proxy   *httputil.ReverseProxy
+	err     error
+	process *os.Process // Guarded by the gateway's mutex
+}
+
+// NewGateway authenticates requests with auth and starts sessions with launch
+func NewGateway(auth *Authenticator, launch Launcher, logger *slog.Logger) *Gateway {
+	return &Gateway{auth: auth, launch: launch, logger: logger, sessions: map[string]*principalSession{}}
+}
+
+// Handler serves the same endpoints as a session server, each principal
+// seeing only their own session
+func (g *Gateway) Handler() http.Handler {
+	return originCheck(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := g.auth.Authenticate(r)
+		if err != nil {
+			g.logger.Warn("authentication failed", "remote", r.RemoteAddr, "path", r.URL.Path, "error", err)
+			unauthorized(w, err)
+			return
+		}
+		g.logger.Debug("request", "principal", principal.Name, "method", r.Method, "path", r.URL.Path)
+		session, err := g.session(r.Context(), *principal)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		session.proxy.ServeHTTP(w, r)
+	}))
+}
+
+// session returns the running session of principal, starting one if needed
+func (g *Gateway) session(ctx context.Context, principal config.PrincipalConfig) (*principalSession, error) {
+	g.mutex.Lock()
+	if g.closed {
+		g.mutex.Unlock()
+		return nil, errShuttingDown
+	}
+	session, ok := g.sessions[principal.Name]
+	if !ok {
+		session = &principalSession{ready: make(chan struct{})}
+		g.sessions[principal.Name] = session
+		g.running.Add(1)
+		go g.run(session, principal)
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-session.ready:
+		return session, session.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run starts the session server of principal and waits for it to end.
+// Its standard error is copied to ours, prefixed with the principal.
+func (g *Gateway) run(session *principalSession, principal config.PrincipalConfig) {
+	defer g.running.Done()
+	defer g.forget(principal.Name, session)
+	ready := false
+	fail := func(err error) {
+		if !ready {
+			ready = true
+			session.err = fmt.Errorf("failed to start the session of %s: %w", principal.Name, err)
+			close(session.ready)
+		}
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		fail(err)
+		return
+	}
+	cmd := g.launch(principal)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, TokenEnv+"="+token)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		fail(err)
+		return
+	}
+	g.mutex.Lock()
+	session.process = cmd.Process
+	if g.closed {
+		// Shutdown began while the session was starting
+		cmd.Process.Kill()
+	}
+	g.mutex.Unlock()
+	g.logger.Info("session started", "principal", principal.Name, "pid", cmd.Process.Pid)
+	timeout := time.AfterFunc(startTimeout, func() { cmd.Process.Kill() })
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if address, ok := strings.CutPrefix(line, ReadyMessage+" "); ok && !ready {
+			timeout.Stop()
+			target, err := url.Parse(address)
+			if err != nil {
+				fail(err)
+				cmd.Process.Kill()
+				continue
+			}
+			session.proxy = newProxy(target, token)
+			ready = true
+			close(session.ready)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", principal.Name, line)
+	}
+	err = cmd.Wait()
+	timeout.Stop()
+	fail(fmt.Errorf("it exited before serving (%v)", err))
+	g.logger.Info("session ended", "principal", principal.Name, "error", err)
+}
+
+// forget removes a session that ended, so the principal's next request
+// starts a new one
+func (g *Gateway) forget(name string, session *principalSession) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.sessions[name] == session {
+		delete(g.sessions, name)
+	}
+}
+
+// newProxy forwards requests to a session server, authenticated with its
+// token instead of the principal's credentials
+func newProxy(target *url.URL, token string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			// The session server checks the Origin of browser requests against the host they were sent to
+			r.Out.Host = r.In.Host
+			r.Out.Header.Set("Authorization", "Bearer "+token)
+			query := r.Out.URL.Query()
+			if query.Has("access_token") {
+				query.Del("access_token")
+				r.Out.URL.RawQuery = query.Encode()
+			}
+		},
+		FlushInterval: -1, // Events are sent as they happen
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, http.StatusBadGateway, "the session is not available: "+err.Error())
+		},
+	}
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Shutdown interrupts every session, so each ends as if its terminal were
+// interrupted, and waits for them to exit. Sessions still running when ctx
+// is done are killed.
+func (g *Gateway) Shutdown(ctx context.Context) {
+	g.mutex.Lock()
+	g.closed = true
+	for _, session := range g.sessions {
+		// Interrupts cannot be sent on Windows
+		if session.process != nil && session.process.Signal(os.Interrupt) != nil {
+			session.process.Kill()
+		}
+	}
+	g.mutex.Unlock()
+
+	exited := make(chan struct{})
+	go func() {
+		g.running.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+		return
+	case <-ctx.Done():
+	}
+	g.mutex.Lock()
+	for _, session := range g.sessions {
+		if session.process != nil {
+			session.process.Kill()
+		}
+	}
+	g.mutex.Unlock()
+	<-exited
+}
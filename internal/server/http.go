@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// keepAlive is how often an idle stream sends a comment, so proxies keep it open
+const keepAlive = 15 * time.Second
+
+// maxInput caps the size of a submitted message
+const maxInput = 1 << 20
+
+// inputRequest is the body of POST /input and of WebSocket messages
+type inputRequest struct {
+	Text string `json:"text"`
+}
+
+// Handler serves the session:
+//
+//	GET  /events  server-sent events; Last-Event-ID or ?after=N resumes
+//	GET  /ws      WebSocket: events out, {"text": ...} input in; ?after=N resumes
+//	POST /input   {"text": ...} answers the pending message or confirmation
+//
+// Requests from web pages of another origin are refused, so a page the
+// user visits cannot drive the session.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", s.serveEvents)
+	mux.Handle("GET /ws", websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if !sameOrigin(r) {
+				return errors.New("cross-origin request")
+			}
+			return nil
+		},
+		Handler: s.serveWebSocket,
+	})
+	mux.HandleFunc("POST /input", s.serveInput)
+	return originCheck(mux)
+}
+
+// originCheck refuses browser requests whose Origin is another host
+func originCheck(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sameOrigin(r) {
+			writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin accepts requests without an Origin, as sent by non-browser
+// clients, and those from a page served by the same host
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	after, err := resumeAfter(r, r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	past, events, cancel := s.subscribe(after)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range past {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			writeEvent(w, event)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one server-sent event; its data is the event as JSON
+func writeEvent(w io.Writer, event Event) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+func (s *Server) serveWebSocket(conn *websocket.Conn) {
+	defer conn.Close()
+	after, err := resumeAfter(conn.Request(), "")
+	if err != nil {
+		websocket.JSON.Send(conn, map[string]string{"type": "error", "error": err.Error()})
+		return
+	}
+	past, events, cancel := s.subscribe(after)
+	defer cancel()
+
+	var sending sync.Mutex
+	send := func(value any) error {
+		sending.Lock()
+		defer sending.Unlock()
+		return websocket.JSON.Send(conn, value)
+	}
+
+	// Input arrives on the same connection; reading ends when the client leaves
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.MaxPayloadBytes = maxInput
+		for {
+			var input inputRequest
+			if err := websocket.JSON.Receive(conn, &input); err != nil {
+				if !errors.Is(err, io.EOF) {
+					send(map[string]string{"type": "error", "error": "invalid input: " + err.Error()})
+				}
+				return
+			}
+			if err := s.Submit(input.Text); err != nil {
+				send(map[string]string{"type": "error", "error": err.Error()})
+			}
+		}
+	}()
+
+	for _, event := range past {
+		if send(event) != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok || send(event) != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (s *Server) serveInput(w http.ResponseWriter, r *http.Request) {
+	var input inputRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInput)).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid input: "+err.Error())
+		return
+	}
+	if err := s.Submit(input.Text); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resumeAfter returns the ID of the last event a client saw, from the
+// after query parameter or header; 0 replays the whole history
+func resumeAfter(r *http.Request, header string) (int, error) {
+	value := r.URL.Query().Get("after")
+	if value == "" {
+		value = header
+	}
+	if value == "" {
+		return 0, nil
+	}
+	after, err := strconv.Atoi(value)
+	if err != nil || after < 0 {
+		return 0, fmt.Errorf("invalid event ID %q", value)
+	}
+	return after, nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is how far token times may be off from ours
+const clockSkew = time.Minute
+
+// refetchAfter is how long after fetching keys an unknown key ID triggers
+// another fetch, so rotated keys are picked up without hammering the issuer
+const refetchAfter = time.Minute
+
+// Claims are the token claims principals are matched on
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// oidcVerifier checks JWTs signed by an OpenID Connect provider, with keys
+// discovered from the issuer
+type oidcVerifier struct {
+	issuer   string
+	audience string
+	client   *http.Client
+
+	mutex   sync.Mutex
+	keys    map[string]crypto.PublicKey // By key ID
+	fetched time.Time
+}
+
+func newOIDCVerifier(issuer, audience string, client *http.Client) *oidcVerifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &oidcVerifier{issuer: strings.TrimRight(issuer, "/"), audience: audience, client: client}
+}
+
+// verify checks the signature, issuer, audience and lifetime of token
+func (v *oidcVerifier) verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	var payload struct {
+		Claims
+		Issuer    string   `json:"iss"`
+		Audience  audience `json:"aud"`
+		Expires   int64    `json:"exp"`
+		NotBefore int64    `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case strings.TrimRight(payload.Issuer, "/") != v.issuer:
+		return nil, fmt.Errorf("token issued by %q, not %q", payload.Issuer, v.issuer)
+	case !slices.Contains(payload.Audience, v.audience):
+		return nil, fmt.Errorf("token is not for audience %q", v.audience)
+	case payload.Expires == 0 || now.After(time.Unix(payload.Expires, 0).Add(clockSkew)):
+		return nil, errors.New("token has expired")
+	case payload.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(payload.NotBefore, 0)):
+		return nil, errors.New("token is not valid yet")
+	}
+	return &payload.Claims, nil
+}
+
+// audience is the aud claim, which may be a string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the issuer's signing key with id, fetching the key set when
+// the key is unknown
+func (v *oidcVerifier) key(ctx context.Context, id string) (crypto.PublicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if key, ok := v.keys[id]; ok {
+		return key, nil
+	}
+	if time.Since(v.fetched) < refetchAfter {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the signing keys of %s: %w", v.issuer, err)
+	}
+	v.keys, v.fetched = keys, time.Now()
+	if key, ok := v.keys[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", id)
+}
+
+// fetchKeys discovers the issuer's JWKS URL and reads the keys there
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("the discovery document has no jwks_uri")
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped; tokens signed with them fail
+		if key, err := k.publicKey(); err == nil {
+			keys[k.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// jwk is one JSON Web Key of a key set
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Curve]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// verifySignature checks a JWS signature over signed with key
+func verifySignature(algorithm string, key crypto.PublicKey, signed string, signature []byte) error {
+	var digest hash.Hash
+	var hashID crypto.Hash
+	switch algorithm[min(2, len(algorithm)):] {
+	case "256":
+		digest, hashID = sha256.New(), crypto.SHA256
+	case "384":
+		digest, hashID = sha512.New384(), crypto.SHA384
+	case "512":
+		digest, hashID = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	digest.Write([]byte(signed))
+	sum := digest.Sum(nil)
+
+	switch public := key.(type) {
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(algorithm, "RS"):
+			if rsa.VerifyPKCS1v15(public, hashID, sum, signature) == nil {
+				return nil
+			}
+		case strings.HasPrefix(algorithm, "PS"):
+			if rsa.VerifyPSS(public, hashID, sum, signature, nil) == nil {
+				return nil
+			}
+		default:
+			return fmt.Errorf("algorithm %q does not match an RSA key", algorithm)
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "ES") {
+			return fmt.Errorf("algorithm %q does not match an EC key", algorithm)
+		}
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(public, sum, r, s) {
+				return nil
+			}
+		}
+	}
+	return errors.New("invalid token signature")
+}
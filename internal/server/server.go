@@ -0,0 +1,361 @@
+// Package server runs a session for remote frontends. Everything the
+// agent does becomes an Event, streamed over server-sent events or a
+// WebSocket, and the prompts and confirmations it would read from the
+// terminal are answered by clients instead.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"agent/internal/confirm"
+	"agent/internal/tools"
+	"agent/internal/usage"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Event types
+const (
+	EventOutput       = "output"       // Text the session printed: replies, tool calls, command output
+	EventActivity     = "activity"     // What the agent is busy with; empty text when idle
+	EventToolStart    = "tool_start"   // A tool call began
+	EventToolDone     = "tool_done"    // A tool call finished
+	EventUsage        = "usage"        // Token usage after a model response
+	EventConfirmation = "confirmation" // An operation needs approval; an input event follows
+	EventInput        = "input"        // The session waits for a message or a confirmation answer
+	EventDone         = "done"         // The session ended
+)
+
+// maxHistory is how many events are kept for clients that connect late or
+// reconnect; older ones are dropped
+const maxHistory = 10_000
+
+// subscriberBuffer is how many events a client may fall behind before it
+// is disconnected, so a stalled client never blocks the agent
+const subscriberBuffer = 1024
+
+// Event is one thing that happened in the session. IDs increase by one, so
+// a reconnecting client can ask for the events after the last it saw.
+type Event struct {
+	ID           int           `json:"id"`
+	Type         string        `json:"type"`
+	Time         time.Time     `json:"time"`
+	Text         string        `json:"text,omitempty"`  // output and activity
+	Input        string        `json:"input,omitempty"` // input: "message" or "confirmation"
+	Call         *ToolCall     `json:"call,omitempty"`
+	Confirmation *Confirmation `json:"confirmation,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+	Error        string        `json:"error,omitempty"` // done: why the session ended, if it failed
+}
+
+// ToolCall describes a tool call in tool_start and tool_done events
+type ToolCall struct {
+	ID         int    `json:"id"`
+	Tool       string `json:"tool"`
+	Target     string `json:"target,omitempty"` // Summary of the input, e.g. the file path
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Failed     bool   `json:"failed,omitempty"`
+}
+
+// Confirmation describes an operation waiting for approval
+type Confirmation struct {
+	Tool        string   `json:"tool"`
+	Description string   `json:"description"`
+	Path        string   `json:"path,omitempty"`
+	Preview     string   `json:"preview,omitempty"`
+	Answers     []string `json:"answers"` // Accepted answers; anything else declines
+}
+
+// Usage is the session's token usage so far
+type Usage struct {
+	Model        string  `json:"model"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Context      int64   `json:"context_tokens"`
+	Cost         float64 `json:"cost_usd"`
+}
+
+// Input kinds of input events
+const (
+	InputMessage      = "message"
+	InputConfirmation = "confirmation"
+)
+
+// ErrNotWaiting is returned by Submit when the session is not waiting for input
+var ErrNotWaiting = errors.New("the session is not waiting for input")
+
+// Server streams one session to any number of clients. Input from any of
+// them answers the session's pending read.
+type Server struct {
+	usage   *usage.Tracker
+	input   chan string
+	quit    chan struct{}
+	ended   sync.Once
+	streams sync.WaitGroup // Clients still being sent events
+
+	mutex       sync.Mutex
+	history     []Event
+	nextEvent   int
+	nextCall    int
+	subscribers map[chan Event]struct{}
+	waiting     string // Input kind the session waits for, or ""
+	confirming  bool   // A confirmation was announced and its answer is next
+}
+
+// New creates a server whose usage events report tracker, which may be
+// shared with other reports
+func New(tracker *usage.Tracker) *Server {
+	return &Server{
+		usage:       tracker,
+		input:       make(chan string),
+		quit:        make(chan struct{}),
+		subscribers: map[chan Event]struct{}{},
+	}
+}
+
+// publish numbers an event, keeps it in the history and hands it to every
+// subscriber
+func (s *Server) publish(event Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextEvent++
+	event.ID = s.nextEvent
+	event.Time = time.Now()
+	s.history = append(s.history, event)
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(s.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// subscribe returns the events after the one with ID after, and a channel
+// of those still to come. The channel is closed when the client falls too
+// far behind or the session ends; cancel stops the subscription.
+func (s *Server) subscribe(after int) (past []Event, events chan Event, cancel func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, event := range s.history {
+		if event.ID > after {
+			past = append(past, event)
+		}
+	}
+	events = make(chan Event, subscriberBuffer)
+	select {
+	case <-s.quit:
+		close(events)
+		return past, events, func() {}
+	default:
+	}
+	s.subscribers[events] = struct{}{}
+	s.streams.Add(1)
+	var once sync.Once
+	return past, events, func() {
+		once.Do(func() {
+			defer s.streams.Done()
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if _, ok := s.subscribers[events]; ok {
+				delete(s.subscribers, events)
+				close(events)
+			}
+		})
+	}
+}
+
+// ReadLine announces that the session waits for input and returns the next
+// line a client submits. It reads both messages and confirmation answers;
+// the bool is false once the server shuts down.
+func (s *Server) ReadLine() (string, bool) {
+	s.mutex.Lock()
+	kind := InputMessage
+	if s.confirming {
+		kind, s.confirming = InputConfirmation, false
+	}
+	s.waiting = kind
+	s.mutex.Unlock()
+	s.publish(Event{Type: EventInput, Input: kind})
+
+	select {
+	case line := <-s.input:
+		return line, true
+	case <-s.quit:
+		return "", false
+	}
+}
+
+// Submit answers the pending read with line. Only the first of several
+// clients answering at once gets through.
+func (s *Server) Submit(line string) error {
+	s.mutex.Lock()
+	waiting := s.waiting != ""
+	s.waiting = ""
+	s.mutex.Unlock()
+	if !waiting {
+		return ErrNotWaiting
+	}
+	select {
+	case s.input <- line:
+		return nil
+	case <-s.quit:
+		return ErrNotWaiting
+	}
+}
+
+// Confirming announces a confirmation; use it as the confirm service's
+// before-prompt hook
+func (s *Server) Confirming(req confirm.Request) {
+	answers := []string{"yes", "no"}
+	if !req.Force {
+		answers = append(answers, "session")
+		if req.Path != "" {
+			answers = append(answers, "path")
+		}
+	}
+	s.mutex.Lock()
+	s.confirming = true
+	s.mutex.Unlock()
+	s.publish(Event{Type: EventConfirmation, Confirmation: &Confirmation{
+		Tool:        req.Tool,
+		Description: req.Description(),
+		Path:        req.Path,
+		Preview:     req.Preview,
+		Answers:     answers,
+	}})
+}
+
+// Start reports what the session is busy with
+func (s *Server) Start(description string) {
+	s.publish(Event{Type: EventActivity, Text: description})
+}
+
+// Stop reports that the session is idle
+func (s *Server) Stop() {
+	s.publish(Event{Type: EventActivity})
+}
+
+// RecordUsage adds the token usage of a response; use it with agent.WithUsage
+func (s *Server) RecordUsage(model string, response anthropic.Usage) {
+	s.usage.Add(model, response)
+	totals := s.usage.Totals()
+	s.publish(Event{Type: EventUsage, Usage: &Usage{
+		Model:        model,
+		InputTokens:  totals.InputTokens,
+		OutputTokens: totals.OutputTokens,
+		Context:      totals.Context,
+		Cost:         totals.Cost,
+	}})
+}
+
+// Middleware reports the start and end of every tool call
+func (s *Server) Middleware() tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			if toolCtx == nil || toolCtx.Tool == nil {
+				return next(ctx, toolCtx, input)
+			}
+			s.mutex.Lock()
+			s.nextCall++
+			call := ToolCall{ID: s.nextCall, Tool: toolCtx.Tool.Name, Target: tools.SummarizeInput(input)}
+			s.mutex.Unlock()
+
+			s.publish(Event{Type: EventToolStart, Call: &call})
+			start := time.Now()
+			result, err := next(ctx, toolCtx, input)
+			done := call
+			done.DurationMS = time.Since(start).Milliseconds()
+			done.Failed = err != nil || (result != nil && result.IsError)
+			s.publish(Event{Type: EventToolDone, Call: &done})
+			return result, err
+		}
+	}
+}
+
+// Run streams the session while run executes. Standard output is captured
+// into output events for the duration and still copied to the terminal.
+// Shutting down the server cancels the context passed to run.
+func (s *Server) Run(ctx context.Context, run func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	original := os.Stdout
+	os.Stdout = writer
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		buf := make([]byte, 4096)
+		for {
+			n, err := reader.Read(buf)
+			if n > 0 {
+				original.Write(buf[:n])
+				s.publish(Event{Type: EventOutput, Text: string(buf[:n])})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		<-s.quit
+		cancel()
+	}()
+	runErr := run(ctx)
+
+	os.Stdout = original
+	writer.Close()
+	<-copied
+	reader.Close()
+
+	done := Event{Type: EventDone}
+	if runErr != nil && !errors.Is(runErr, context.Canceled) {
+		done.Error = runErr.Error()
+	}
+	s.publish(done)
+	s.Shutdown()
+	if errors.Is(runErr, context.Canceled) {
+		return nil
+	}
+	return runErr
+}
+
+// Drain waits until every client has been sent the events up to shutdown,
+// or ctx is done
+func (s *Server) Drain(ctx context.Context) {
+	drained := make(chan struct{})
+	go func() {
+		s.streams.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+	}
+}
+
+// Shutdown ends pending and future reads and disconnects every client
+func (s *Server) Shutdown() {
+	s.ended.Do(func() {
+		close(s.quit)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for events := range s.subscribers {
+			close(events)
+		}
+		clear(s.subscribers)
+	})
+}
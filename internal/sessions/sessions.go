@@ -0,0 +1,252 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"agent/internal/metrics"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// maxTitleLength is how many characters of the first prompt become the title
+const maxTitleLength = 60
+
+// noticePrefix marks the notices the agent adds to user messages; they never become titles
+const noticePrefix = "[billdozer] "
+
+// Session is a saved conversation. Each session is stored as one JSON file
+// named after its ID.
+type Session struct {
+	ID       string                   `json:"id"`
+	Title    string                   `json:"title"` // Generated from the first prompt
+	Dir      string                   `json:"dir"`   // Workspace root the session ran in; host:root when remote
+	Model    string                   `json:"model"`
+	Created  time.Time                `json:"created"`
+	Updated  time.Time                `json:"updated"`
+	Messages []anthropic.MessageParam `json:"messages"`
+	Runs     []metrics.Report         `json:"runs,omitempty"` // What each run of the session did, oldest first
+}
+
+// Prompts counts the messages the user typed, leaving out tool results
+func (s *Session) Prompts() int {
+	count := 0
+	for _, message := range s.Messages {
+		if message.Role == anthropic.MessageParamRoleUser && firstPrompt(message) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// Filter selects sessions in List. Zero fields match everything.
+type Filter struct {
+	Dir   string    // Workspace root, compared as a cleaned absolute path
+	Since time.Time // Last updated at or after
+	Until time.Time // Last updated before
+}
+
+func (f Filter) matches(s *Session) bool {
+	if f.Dir != "" && filepath.Clean(s.Dir) != filepath.Clean(f.Dir) {
+		return false
+	}
+	if !f.Since.IsZero() && s.Updated.Before(f.Since) {
+		return false
+	}
+	return f.Until.IsZero() || s.Updated.Before(f.Until)
+}
+
+// Store keeps saved sessions in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store in dir, which is created on the first save
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// New starts a session for a workspace; it is written on the first Save
+func (st *Store) New(dir, model string) *Session {
+	now := time.Now()
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return &Session{
+		ID:      now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Dir:     dir,
+		Model:   model,
+		Created: now,
+	}
+}
+
+// Save writes a session, titling it from its first prompt if it has no
+// title yet. The file is replaced atomically so an interrupted save never
+// leaves a truncated session behind.
+func (st *Store) Save(s *Session) error {
+	s.Updated = time.Now()
+	if s.Title == "" {
+		s.Title = Title(s.Messages)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	// Conversations can hold file contents and command output, so only the user may read them
+	if err := os.MkdirAll(st.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	file, err := os.CreateTemp(st.dir, s.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := os.Rename(file.Name(), st.path(s.ID)); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// List returns the sessions that match filter, most recently updated first.
+// Files that cannot be read are skipped.
+func (st *Store) List(filter Filter) ([]*Session, error) {
+	entries, err := os.ReadDir(st.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+	var found []*Session
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		s, err := st.read(id)
+		if err != nil {
+			continue
+		}
+		if filter.matches(s) {
+			found = append(found, s)
+		}
+	}
+	slices.SortFunc(found, func(a, b *Session) int { return b.Updated.Compare(a.Updated) })
+	return found, nil
+}
+
+// Load reads the session with the given ID or unique ID prefix
+func (st *Store) Load(id string) (*Session, error) {
+	full, err := st.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return st.read(full)
+}
+
+// Remove deletes the session with the given ID or unique ID prefix and
+// returns its full ID
+func (st *Store) Remove(id string) (string, error) {
+	full, err := st.resolve(id)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(st.path(full)); err != nil {
+		return "", fmt.Errorf("failed to remove session %s: %w", full, err)
+	}
+	return full, nil
+}
+
+// resolve expands an ID prefix to the one session it names
+func (st *Store) resolve(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	if _, err := os.Stat(st.path(id)); err == nil {
+		return id, nil
+	}
+	entries, err := os.ReadDir(st.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read session directory: %w", err)
+	}
+	var matches []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && strings.HasPrefix(name, id) {
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no session %q (see billdozer sessions list)", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("session ID %q is ambiguous: it matches %s", id, strings.Join(matches, ", "))
+	}
+}
+
+func (st *Store) read(id string) (*Session, error) {
+	data, err := os.ReadFile(st.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+func (st *Store) path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+// Title derives a session title from the first line of the first prompt
+func Title(messages []anthropic.MessageParam) string {
+	for _, message := range messages {
+		if message.Role != anthropic.MessageParamRoleUser {
+			continue
+		}
+		prompt := firstPrompt(message)
+		if prompt == "" {
+			continue
+		}
+		title, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+		runes := []rune(strings.TrimSpace(title))
+		if len(runes) <= maxTitleLength {
+			return string(runes)
+		}
+		cut := string(runes[:maxTitleLength])
+		if space := strings.LastIndex(cut, " "); space > maxTitleLength/2 {
+			cut = cut[:space]
+		}
+		return cut + "…"
+	}
+	return ""
+}
+
+// firstPrompt returns the text the user typed in a message, skipping notices
+func firstPrompt(message anthropic.MessageParam) string {
+	for _, block := range message.Content {
+		if block.OfText == nil || strings.HasPrefix(block.OfText.Text, noticePrefix) {
+			continue
+		}
+		if text := strings.TrimSpace(block.OfText.Text); text != "" {
+			return text
+		}
+	}
+	return ""
+}
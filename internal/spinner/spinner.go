@@ -0,0 +1,102 @@
+package spinner
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"agent/internal/theme"
+)
+
+const (
+	// delay hides the spinner for waits too short to notice
+	delay    = 300 * time.Millisecond
+	interval = 100 * time.Millisecond
+)
+
+var frames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Spinner shows an animated activity line with the elapsed time, e.g.
+// "⠹ thinking… 4s", on the current terminal line. It draws only between
+// Start and Stop and erases itself when stopped, so other output must wait
+// for Stop. A nil *Spinner is valid and shows nothing.
+type Spinner struct {
+	out io.Writer
+
+	mutex   sync.Mutex
+	drawn   bool // The spinner line is on screen
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// New creates a spinner that draws to out, which should be a terminal
+func New(out io.Writer) *Spinner {
+	return &Spinner{out: out}
+}
+
+// Start shows activity, replacing whatever the spinner showed before
+func (s *Spinner) Start(activity string) {
+	if s == nil {
+		return
+	}
+	s.Stop()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stop, s.stopped = make(chan struct{}), make(chan struct{})
+	go s.run(activity, time.Now(), s.stop, s.stopped)
+}
+
+// Stop hides the spinner until the next Start
+func (s *Spinner) Stop() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	stop, stopped := s.stop, s.stopped
+	s.stop, s.stopped = nil, nil
+	s.mutex.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-stopped
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.drawn {
+		fmt.Fprint(s.out, "\r\x1b[K")
+		s.drawn = false
+	}
+}
+
+func (s *Spinner) run(activity string, started time.Time, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	select {
+	case <-stop:
+		return
+	case <-time.After(delay):
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		s.mutex.Lock()
+		fmt.Fprint(s.out, "\r\x1b[K"+theme.Paint(theme.Faint, fmt.Sprintf("%s %s %s", frames[frame%len(frames)], activity, elapsed(time.Since(started)))))
+		s.drawn = true
+		s.mutex.Unlock()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// elapsed formats a duration as 4s or 2m05s
+func elapsed(d time.Duration) string {
+	seconds := int(d.Seconds())
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm%02ds", seconds/60, seconds%60)
+}
@@ -0,0 +1,22 @@
+package theme
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableEscapes turns on virtual terminal processing for a Windows console,
+// without which it prints ANSI escape sequences instead of acting on them.
+// Consoles older than Windows 10 cannot, and get no colors.
+func enableEscapes(out *os.File) bool {
+	handle := windows.Handle(out.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
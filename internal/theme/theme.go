@@ -0,0 +1,187 @@
+package theme
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// Role names a kind of text that billdozer colors
+type Role string
+
+const (
+	User       Role = "user"      // The "You" prompt
+	Assistant  Role = "assistant" // The "Claude" label before responses
+	Tool       Role = "tool"      // The label of tool calls
+	Notice     Role = "notice"    // The label of config reload notices
+	Path       Role = "path"      // Paths in confirmation prompts
+	Faint      Role = "faint"     // Secondary text such as the spinner
+	DiffHeader Role = "diff_header"
+	DiffHunk   Role = "diff_hunk"
+	DiffAdd    Role = "diff_add"
+	DiffDelete Role = "diff_delete"
+)
+
+// Roles lists every role, in the order they are documented
+var Roles = []Role{User, Assistant, Tool, Notice, Path, Faint, DiffHeader, DiffHunk, DiffAdd, DiffDelete}
+
+// Scheme maps roles to ANSI SGR parameters, e.g. "94" for bright blue.
+// Roles missing from a scheme are left uncolored.
+type Scheme map[Role]string
+
+// DefaultScheme is the scheme used when none is configured
+const DefaultScheme = "default"
+
+// Schemes are the built-in color schemes
+var Schemes = map[string]Scheme{
+	DefaultScheme: {
+		User: "94", Assistant: "93", Tool: "92", Notice: "95", Path: "93", Faint: "90",
+		DiffHeader: "1", DiffHunk: "36", DiffAdd: "32", DiffDelete: "31",
+	},
+	// Light terminal backgrounds wash out bright yellow, so darker colors are used
+	"light": {
+		User: "34", Assistant: "35", Tool: "32", Notice: "35", Path: "34;1", Faint: "90",
+		DiffHeader: "1", DiffHunk: "36", DiffAdd: "32", DiffDelete: "31",
+	},
+	// Mono uses only weight and underline, for terminals with unreadable or no colors
+	"mono": {
+		User: "1", Assistant: "1", Tool: "1", Notice: "1", Path: "4", Faint: "2",
+		DiffHeader: "1", DiffHunk: "2", DiffAdd: "1", DiffDelete: "2",
+	},
+}
+
+// colorNames are the values accepted in per-role overrides, besides raw SGR parameters
+var colorNames = map[string]string{
+	"none": "", "bold": "1", "faint": "2", "italic": "3", "underline": "4",
+	"black": "30", "red": "31", "green": "32", "yellow": "33", "blue": "34", "magenta": "35", "cyan": "36", "white": "37",
+	"gray": "90", "bright-red": "91", "bright-green": "92", "bright-yellow": "93", "bright-blue": "94",
+	"bright-magenta": "95", "bright-cyan": "96", "bright-white": "97",
+}
+
+// Color modes
+const (
+	ColorAuto   = "auto"   // Color terminals only, unless NO_COLOR is set
+	ColorAlways = "always" // Color even when output is piped
+	ColorNever  = "never"
+)
+
+// Theme decides how text of each role is styled, and whether at all
+type Theme struct {
+	scheme  Scheme
+	enabled bool
+}
+
+// New builds a theme from a scheme name (DefaultScheme when empty),
+// per-role overrides given as color names or SGR parameters, and a color
+// mode. In ColorAuto mode colors are used only when stdout is a terminal,
+// TERM is not dumb and NO_COLOR is unset.
+func New(scheme string, overrides map[string]string, color string) (*Theme, error) {
+	if scheme == "" {
+		scheme = DefaultScheme
+	}
+	base, ok := Schemes[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheme %q (available: %s)", scheme, strings.Join(slices.Sorted(maps.Keys(Schemes)), ", "))
+	}
+	t := &Theme{scheme: maps.Clone(base)}
+	for _, name := range slices.Sorted(maps.Keys(overrides)) {
+		if !slices.Contains(Roles, Role(name)) {
+			return nil, fmt.Errorf("colors: unknown role %q", name)
+		}
+		code, err := parseColor(overrides[name])
+		if err != nil {
+			return nil, fmt.Errorf("colors: %s: %w", name, err)
+		}
+		t.scheme[Role(name)] = code
+	}
+
+	switch color {
+	case "", ColorAuto:
+		t.enabled = Detect(os.Stdout)
+	case ColorAlways:
+		t.enabled = true
+	case ColorNever:
+	default:
+		return nil, fmt.Errorf("color must be auto, always or never, not %q", color)
+	}
+	return t, nil
+}
+
+// parseColor accepts a color name, or SGR parameters such as "38;5;208"
+func parseColor(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if code, ok := colorNames[value]; ok {
+		return code, nil
+	}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return "", fmt.Errorf("%q is neither a color name nor ANSI SGR parameters", value)
+		}
+	}
+	return value, nil
+}
+
+// Detect reports whether colors suit out: it must be a terminal that takes
+// ANSI escape sequences, TERM must not be dumb and NO_COLOR
+// (https://no-color.org) must be unset or empty
+func Detect(out *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(out.Fd())) && enableEscapes(out)
+}
+
+// Enabled reports whether the theme colors text
+func (t *Theme) Enabled() bool {
+	return t.enabled
+}
+
+// Paint styles text for role, or returns it unchanged when colors are off
+func (t *Theme) Paint(role Role, text string) string {
+	code := t.scheme[role]
+	if !t.enabled || code == "" || text == "" {
+		return text
+	}
+	return "\u001b[" + code + "m" + text + "\u001b[0m"
+}
+
+var (
+	mutex   sync.RWMutex
+	current *Theme
+)
+
+// Use makes t the theme of Paint and Enabled
+func Use(t *Theme) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	current = t
+}
+
+// Current returns the theme set with Use, or the default scheme in
+// ColorAuto mode before then
+func Current() *Theme {
+	mutex.RLock()
+	t := current
+	mutex.RUnlock()
+	if t != nil {
+		return t
+	}
+	t, _ = New(DefaultScheme, nil, ColorAuto)
+	Use(t)
+	return t
+}
+
+// Paint styles text for role with the current theme
+func Paint(role Role, text string) string {
+	return Current().Paint(role, text)
+}
+
+// Enabled reports whether the current theme colors text
+func Enabled() bool {
+	return Current().Enabled()
+}
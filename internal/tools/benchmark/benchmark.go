@@ -0,0 +1,223 @@
+// Package benchmark provides a tool that runs a project's Go benchmarks,
+// summarizes them as benchstat does, and compares them with a saved
+// baseline so performance work is judged by numbers
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam    = "parameter %q is required"
+	errMsgInvalidBaseline = "baseline names may have letters, digits, '.', '-' and '_', not %q"
+	errMsgUnknownBaseline = "no baseline is named %q in %s; save one with {\"action\": \"save\", \"baseline\": %q}"
+	errMsgNoBenchmarks    = "no benchmarks matched -bench %q"
+)
+
+// Actions of the benchmark tool
+const (
+	actionRun  = "run"
+	actionSave = "save"
+	actionList = "list"
+)
+
+const (
+	// defaultBaseline names the baseline used when the input names none
+	defaultBaseline = "baseline"
+	// maxRuns bounds the runs the model may ask for
+	maxRuns = 50
+	// maxOutputBytes is how much of a report or command output is returned
+	maxOutputBytes = 30000
+)
+
+// baselineName matches the names baselines may be saved under
+var baselineName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// settings is the benchmarks config in effect; see Configure
+var settings config.BenchmarksConfig
+
+// Configure sets how benchmarks are run and where baselines are saved.
+// Call it before tools run.
+func Configure(cfg config.BenchmarksConfig) {
+	settings = cfg
+}
+
+// BenchmarkInput represents the input parameters for the benchmark tool
+type BenchmarkInput struct {
+	Action    string   `json:"action" jsonschema:"required,enum=run,enum=save,enum=list" jsonschema_description:"run runs benchmarks and compares them with the baseline; save runs them and saves the results as the baseline; list shows the saved baselines"`
+	Packages  []string `json:"packages,omitempty" jsonschema_description:"Packages to benchmark, e.g. ./internal/parser; the configured ones, or ./..., when omitted"`
+	Bench     string   `json:"bench,omitempty" jsonschema_description:"Regular expression of the benchmarks to run, as go test -bench takes it, e.g. BenchmarkParse or Parse/large; all when omitted"`
+	Count     int      `json:"count,omitempty" jsonschema_description:"Runs of each benchmark; at least 6 to tell changes from noise. The configured count when omitted"`
+	Benchtime string   `json:"benchtime,omitempty" jsonschema_description:"How long each run lasts, e.g. 2s, or how many iterations it does, e.g. 1000x"`
+	Baseline  string   `json:"baseline,omitempty" jsonschema_description:"Name of the baseline to compare with or save as (default baseline)"`
+}
+
+// Validate implements input validation
+func (i *BenchmarkInput) Validate() error {
+	switch i.Action {
+	case "":
+		return fmt.Errorf(errMsgMissingParam, "action")
+	case actionRun, actionSave:
+	case actionList:
+		if len(i.Packages) > 0 || i.Bench != "" || i.Count != 0 || i.Benchtime != "" || i.Baseline != "" {
+			return fmt.Errorf("list takes no other parameters")
+		}
+		return nil
+	default:
+		return fmt.Errorf("action must be run, save or list, not %q", i.Action)
+	}
+	for _, pkg := range i.Packages {
+		if pkg == "" || strings.HasPrefix(pkg, "-") {
+			return fmt.Errorf("packages must be package paths or patterns such as ./internal/..., not %q", pkg)
+		}
+	}
+	if i.Count < 0 || i.Count > maxRuns {
+		return fmt.Errorf("parameter \"count\" must be between 1 and %d", maxRuns)
+	}
+	if i.Benchtime != "" && !validBenchtime(i.Benchtime) {
+		return fmt.Errorf("parameter \"benchtime\" must be a duration such as 2s or a number of iterations such as 1000x, not %q", i.Benchtime)
+	}
+	if i.Baseline != "" && !baselineName.MatchString(i.Baseline) {
+		return fmt.Errorf(errMsgInvalidBaseline, i.Baseline)
+	}
+	return nil
+}
+
+// validBenchtime reports whether go test accepts a -benchtime value
+func validBenchtime(value string) bool {
+	if iterations, ok := strings.CutSuffix(value, "x"); ok {
+		n, err := strconv.Atoi(iterations)
+		return err == nil && n > 0
+	}
+	d, err := time.ParseDuration(value)
+	return err == nil && d > 0
+}
+
+type BenchmarkTool struct{}
+
+func (t BenchmarkTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "benchmark",
+		Group:    "benchmark",
+		Mutating: true,
+		Confirms: true,
+		Description: `Run Go benchmarks several times each and report their medians with a 95% confidence
+interval, compared with a saved baseline as benchstat does: the change of each benchmark's
+time, memory and allocations, whether it is more than noise (p < 0.05), and the
+regressions beyond the configured threshold.
+
+Usage Examples:
+- {"action": "save", "packages": ["./internal/parser"]} // Before changing code, record where it stands
+- {"action": "run", "packages": ["./internal/parser"]} // After changing it, compare with the saved baseline
+- {"action": "run", "bench": "BenchmarkParse/large", "count": 10, "benchtime": "2s"}
+- {"action": "save", "baseline": "before-cache"} // Several baselines may be kept by name
+- {"action": "list"}
+
+Save a baseline before optimizing, then run after each change. A change marked ~ is noise;
+do not claim an improvement from it. Running with the same packages and bench as the
+baseline keeps the comparison complete. The user confirms each run.`,
+		InputSchema: schema.GenerateSchema[BenchmarkInput](),
+	}
+}
+
+func (t BenchmarkTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var benchmarkInput BenchmarkInput
+	if err := tools.DecodeInput(input, &benchmarkInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := benchmarkInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	dir, err := resolveBaselineDir(toolCtx)
+	if err != nil {
+		return nil, err
+	}
+	if benchmarkInput.Action == actionList {
+		return listBaselines(toolCtx, dir)
+	}
+	return runBenchmarks(ctx, toolCtx, &benchmarkInput, dir)
+}
+
+// baselineDir is where baselines are saved, and how it is shown
+type baselineDir struct {
+	abs, shown string
+}
+
+// file returns the path of a baseline and how it is shown
+func (d baselineDir) file(name string) (path, shown string) {
+	return filepath.Join(d.abs, name+".txt"), filepath.Join(d.shown, name+".txt")
+}
+
+// resolveBaselineDir resolves the configured baseline directory
+func resolveBaselineDir(toolCtx *tools.ToolContext) (baselineDir, error) {
+	abs, shown, err := resolvePath(toolCtx, settings.Baselines())
+	if err != nil {
+		return baselineDir{}, err
+	}
+	return baselineDir{abs: abs, shown: shown}, nil
+}
+
+// resolvePath resolves a path within the workspace, asking the user first
+// when the path policy says to
+func resolvePath(toolCtx *tools.ToolContext, path string) (resolved, shown string, err error) {
+	ws := workspaceOf(toolCtx)
+	if ws == nil {
+		return path, filepath.Clean(path), nil
+	}
+	p, err := ws.ResolvePath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "run benchmarks or keep baselines outside the workspace", Path: p.Abs}) {
+		return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+	}
+	return p.Abs, p.Shown, nil
+}
+
+// count writes a number of things, e.g. "1 benchmark" or "3 benchmarks"
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of output within maxOutputBytes, up to the
+// end of a line
+func firstBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(output[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted; run fewer packages or benchmarks] ...", output[:cut], len(output)-cut)
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(BenchmarkTool{})
+}
@@ -0,0 +1,31 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// Execute runs go test, so the tool is fuzzed up to the command it would
+// run
+func FuzzBenchmarkInput(f *testing.F) {
+	toolstest.Seed(f, BenchmarkTool{}, `{"action": "run", "packages": ["-exec=sh"]}`, `{"action": "save", "baseline": "../escape"}`,
+		`{"action": "run", "count": 1000}`, `{"action": "run", "benchtime": "-1s"}`, `{"action": "list", "count": 6}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		var benchmarkInput BenchmarkInput
+		if toolstest.Decode(t, BenchmarkTool{}, input, &benchmarkInput) != nil || benchmarkInput.Validate() != nil || benchmarkInput.Action == actionList {
+			return
+		}
+		if benchmarkInput.Baseline != "" && strings.ContainsAny(benchmarkInput.Baseline, `/\`) {
+			t.Fatalf("baseline %q is accepted but leaves the baseline directory", benchmarkInput.Baseline)
+		}
+		argv := benchCommand(benchmarkInput.Bench, benchmarkInput.Count, benchmarkInput.Benchtime, benchmarkInput.Packages)
+		packages := argv[len(argv)-len(benchmarkInput.Packages):]
+		for _, pkg := range packages {
+			if strings.HasPrefix(pkg, "-") {
+				t.Fatalf("command %q takes package %q for an option", argv, pkg)
+			}
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package benchmark
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// configLine matches the key: value lines go test prints before results,
+// such as goos: linux and pkg: example.com/parser
+var configLine = regexp.MustCompile(`^([a-z][^\s:A-Z]*):(?:\s+(.*))?$`)
+
+// benchmark is one benchmark's results over several runs
+type benchmark struct {
+	pkg, name string
+	units     []string             // In the order the output gives them
+	samples   map[string][]float64 // Values by unit, one per run
+}
+
+// results is what a run of benchmarks printed, in the Go benchmark format
+// that benchstat reads
+type results struct {
+	config     map[string]string // Last value of each key, e.g. cpu
+	benchmarks []*benchmark      // In the order they first ran
+	byKey      map[string]*benchmark
+	lines      []string // The config and result lines, which are what a baseline saves
+}
+
+// key identifies a benchmark across runs
+func key(pkg, name string) string {
+	return pkg + " " + name
+}
+
+// parseResults reads the config and result lines of benchmark output,
+// ignoring the rest, such as PASS and the tests' own output
+func parseResults(output string) *results {
+	r := &results{config: map[string]string{}, byKey: map[string]*benchmark{}}
+	pkg := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := configLine.FindStringSubmatch(line); m != nil {
+			r.config[m[1]] = strings.TrimSpace(m[2])
+			if m[1] == "pkg" {
+				pkg = r.config["pkg"]
+			}
+			r.lines = append(r.lines, line)
+			continue
+		}
+		name, values, ok := parseResult(line)
+		if !ok {
+			continue
+		}
+		r.lines = append(r.lines, line)
+		b := r.byKey[key(pkg, name)]
+		if b == nil {
+			b = &benchmark{pkg: pkg, name: name, samples: map[string][]float64{}}
+			r.byKey[key(pkg, name)] = b
+			r.benchmarks = append(r.benchmarks, b)
+		}
+		for _, v := range values {
+			if _, ok := b.samples[v.unit]; !ok {
+				b.units = append(b.units, v.unit)
+			}
+			b.samples[v.unit] = append(b.samples[v.unit], v.value)
+		}
+	}
+	return r
+}
+
+// measurement is one value of a result line
+type measurement struct {
+	value float64
+	unit  string
+}
+
+// parseResult reads a result line:
+//
+//	BenchmarkParse-8   	  418215	      2841 ns/op	     912 B/op	      12 allocs/op
+func parseResult(line string) (name string, values []measurement, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return "", nil, false
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return "", nil, false
+	}
+	for i := 2; i+1 < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			break
+		}
+		values = append(values, measurement{value: value, unit: fields[i+1]})
+	}
+	return fields[0], values, len(values) > 0
+}
+
+// packages returns the packages of the benchmarks in the order they ran
+func (r *results) packages() []string {
+	var pkgs []string
+	seen := map[string]bool{}
+	for _, b := range r.benchmarks {
+		if !seen[b.pkg] {
+			seen[b.pkg] = true
+			pkgs = append(pkgs, b.pkg)
+		}
+	}
+	return pkgs
+}
@@ -0,0 +1,281 @@
+package benchmark
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+// change is how a benchmark's values in one unit differ from a baseline's
+type change struct {
+	b             *benchmark
+	unit          string
+	base, current summary
+	delta         float64 // Change of the median, as a fraction of the baseline's
+	p             float64
+}
+
+// significant reports whether the change is more than noise
+func (c change) significant() bool {
+	return c.p < alpha && c.delta != 0
+}
+
+// worse reports whether the change is for the worse: more time, memory or
+// allocations, or less throughput
+func (c change) worse() bool {
+	if higherIsBetter(c.unit) {
+		return c.delta < 0
+	}
+	return c.delta > 0
+}
+
+// higherIsBetter reports whether larger values of a unit are better, as
+// they are for rates such as MB/s
+func higherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s")
+}
+
+// compare returns the changes of the benchmarks that ran in both current
+// and baseline, in the order they ran
+func compare(current, baseline *results) []change {
+	var changes []change
+	for _, b := range current.benchmarks {
+		old := baseline.byKey[key(b.pkg, b.name)]
+		if old == nil {
+			continue
+		}
+		for _, unit := range b.units {
+			before, ok := old.samples[unit]
+			if !ok {
+				continue
+			}
+			c := change{b: b, unit: unit, base: summarize(before), current: summarize(b.samples[unit]), p: uTest(before, b.samples[unit])}
+			if c.base.median != 0 {
+				c.delta = (c.current.median - c.base.median) / math.Abs(c.base.median)
+			}
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// report writes the results of a run, compared with baseline when there is
+// one: first the regressions and improvements beyond threshold percent,
+// then a table of every benchmark for each unit. against names the
+// baseline, e.g. baseline "main".
+func report(current, baseline *results, against string, threshold float64) string {
+	var text strings.Builder
+	for _, name := range []string{"goos", "goarch", "cpu"} {
+		if value := current.config[name]; value != "" {
+			fmt.Fprintf(&text, "%s: %s\n", name, value)
+		}
+	}
+
+	var changes []change
+	byBenchmark := map[*benchmark]map[string]change{}
+	if baseline != nil {
+		changes = compare(current, baseline)
+		for _, c := range changes {
+			if byBenchmark[c.b] == nil {
+				byBenchmark[c.b] = map[string]change{}
+			}
+			byBenchmark[c.b][c.unit] = c
+		}
+		if cpu := baseline.config["cpu"]; cpu != "" && current.config["cpu"] != "" && cpu != current.config["cpu"] {
+			fmt.Fprintf(&text, "The baseline ran on a different CPU (%s), so the changes may not be the code's\n", cpu)
+		}
+		text.WriteString("\n")
+		writeChanges(&text, changes, against, threshold)
+	}
+
+	footnote := false
+	for _, unit := range units(current) {
+		text.WriteString("\n")
+		if writeTable(&text, current, unit, baseline != nil, byBenchmark) {
+			footnote = true
+		}
+	}
+	if footnote {
+		fmt.Fprintf(&text, "\n± ∞: too few runs for a %.0f%% confidence interval; run each benchmark at least 6 times\n", confidence*100)
+	}
+
+	if baseline != nil {
+		notRun := 0
+		for _, b := range baseline.benchmarks {
+			if current.byKey[key(b.pkg, b.name)] == nil {
+				notRun++
+			}
+		}
+		if notRun > 0 {
+			fmt.Fprintf(&text, "\n%s of the baseline did not run this time\n", count(notRun, "benchmark"))
+		}
+	}
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// writeChanges lists the significant changes of at least threshold percent,
+// regressions first
+func writeChanges(text *strings.Builder, changes []change, against string, threshold float64) {
+	var regressions, improvements []change
+	for _, c := range changes {
+		if !c.significant() || math.Abs(c.delta)*100 < threshold {
+			continue
+		}
+		if c.worse() {
+			regressions = append(regressions, c)
+		} else {
+			improvements = append(improvements, c)
+		}
+	}
+	if len(regressions) == 0 {
+		fmt.Fprintf(text, "No regressions of %g%% or more against %s\n", threshold, against)
+	} else {
+		fmt.Fprintf(text, "Regressions of %g%% or more against %s:\n", threshold, against)
+		for _, c := range regressions {
+			writeChange(text, c)
+		}
+	}
+	if len(improvements) > 0 {
+		fmt.Fprintf(text, "Improvements of %g%% or more:\n", threshold)
+		for _, c := range improvements {
+			writeChange(text, c)
+		}
+	}
+}
+
+func writeChange(text *strings.Builder, c change) {
+	fmt.Fprintf(text, "  %s %s: %s %+.2f%% (%s → %s, p=%.3f)\n", c.b.pkg, c.b.name, unitName(c.unit), c.delta*100,
+		formatValue(c.base.median, c.unit), formatValue(c.current.median, c.unit), c.p)
+}
+
+// units returns the units of a run's results in the order they appear
+func units(r *results) []string {
+	var all []string
+	seen := map[string]bool{}
+	for _, b := range r.benchmarks {
+		for _, unit := range b.units {
+			if !seen[unit] {
+				seen[unit] = true
+				all = append(all, unit)
+			}
+		}
+	}
+	return all
+}
+
+// writeTable writes each benchmark's median and its spread in one unit,
+// grouped by package, with the baseline's and the change when compared.
+// It reports whether a spread was infinite.
+func writeTable(text *strings.Builder, current *results, unit string, compared bool, byBenchmark map[*benchmark]map[string]change) (infinite bool) {
+	table := tabwriter.NewWriter(text, 0, 0, 3, ' ', 0)
+	if compared {
+		fmt.Fprintf(table, "%s\tbaseline\tthis run\tchange\n", unitName(unit))
+	} else {
+		fmt.Fprintf(table, "%s\tmedian\truns\n", unitName(unit))
+	}
+	var bases, currents []float64
+	for _, pkg := range current.packages() {
+		header := false
+		for _, b := range current.benchmarks {
+			values, ok := b.samples[unit]
+			if b.pkg != pkg || !ok {
+				continue
+			}
+			if !header && pkg != "" {
+				// Trailing cells keep the package row in the table's columns
+				fmt.Fprintf(table, "%s\t\t\t\n", pkg)
+				header = true
+			}
+			s := summarize(values)
+			infinite = infinite || math.IsInf(s.spread, 1)
+			if !compared {
+				fmt.Fprintf(table, "  %s\t%s\t%d\n", b.name, formatSummary(s, unit), s.n)
+				continue
+			}
+			c, ok := byBenchmark[b][unit]
+			if !ok {
+				fmt.Fprintf(table, "  %s\t-\t%s\t(new)\n", b.name, formatSummary(s, unit))
+				continue
+			}
+			infinite = infinite || math.IsInf(c.base.spread, 1)
+			fmt.Fprintf(table, "  %s\t%s\t%s\t%s\n", b.name, formatSummary(c.base, unit), formatSummary(s, unit), formatDelta(c))
+			if c.base.median > 0 && c.current.median > 0 {
+				bases = append(bases, c.base.median)
+				currents = append(currents, c.current.median)
+			}
+		}
+	}
+	if len(bases) > 1 {
+		base, now := geomean(bases), geomean(currents)
+		fmt.Fprintf(table, "geomean\t%s\t%s\t%+.2f%%\n", formatValue(base, unit), formatValue(now, unit), (now-base)/base*100)
+	}
+	table.Flush()
+	return infinite
+}
+
+// formatSummary writes a median and its spread, e.g. 2.841µs ± 3%
+func formatSummary(s summary, unit string) string {
+	if math.IsInf(s.spread, 1) {
+		return formatValue(s.median, unit) + " ± ∞"
+	}
+	return fmt.Sprintf("%s ± %.0f%%", formatValue(s.median, unit), s.spread*100)
+}
+
+// formatDelta writes a change as benchstat does: ~ when it is no more than
+// noise, with the p-value and the number of runs on each side
+func formatDelta(c change) string {
+	n := fmt.Sprint(c.base.n)
+	if c.current.n != c.base.n {
+		n = fmt.Sprintf("%d+%d", c.base.n, c.current.n)
+	}
+	if !c.significant() {
+		return fmt.Sprintf("~ (p=%.3f n=%s)", c.p, n)
+	}
+	return fmt.Sprintf("%+.2f%% (p=%.3f n=%s)", c.delta*100, c.p, n)
+}
+
+// unitName names a unit in headings; times are written in whichever of s,
+// ms, µs and ns suits them, so ns/op is time/op
+func unitName(unit string) string {
+	if unit == "ns/op" {
+		return "time/op"
+	}
+	return unit
+}
+
+// formatValue writes a value to four significant digits: times with their
+// unit, sizes in B, KiB or MiB, and other values with k, M or G
+func formatValue(v float64, unit string) string {
+	switch unit {
+	case "ns/op":
+		switch abs := math.Abs(v); {
+		case abs >= 1e9:
+			return fmt.Sprintf("%.4gs", v/1e9)
+		case abs >= 1e6:
+			return fmt.Sprintf("%.4gms", v/1e6)
+		case abs >= 1e3:
+			return fmt.Sprintf("%.4gµs", v/1e3)
+		}
+		return fmt.Sprintf("%.4gns", v)
+	case "B/op":
+		switch abs := math.Abs(v); {
+		case abs >= 1<<30:
+			return fmt.Sprintf("%.4g GiB", v/(1<<30))
+		case abs >= 1<<20:
+			return fmt.Sprintf("%.4g MiB", v/(1<<20))
+		case abs >= 1<<10:
+			return fmt.Sprintf("%.4g KiB", v/(1<<10))
+		}
+		return fmt.Sprintf("%.4g B", v)
+	}
+	switch abs := math.Abs(v); {
+	case abs >= 1e9:
+		return fmt.Sprintf("%.4gG", v/1e9)
+	case abs >= 1e6:
+		return fmt.Sprintf("%.4gM", v/1e6)
+	case abs >= 1e4:
+		return fmt.Sprintf("%.4gk", v/1e3)
+	}
+	return fmt.Sprintf("%.4g", v)
+}
@@ -0,0 +1,266 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/confirm"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// timeout bounds a run, which may build the packages first. go test is
+// given the same, in place of its own 10 minutes.
+const timeout = 30 * time.Minute
+
+// runBenchmarks runs the benchmarks after the user confirms it, reports
+// them compared with the baseline, and for save saves them as the baseline
+func runBenchmarks(ctx context.Context, toolCtx *tools.ToolContext, input *BenchmarkInput, dir baselineDir) (*tools.ToolResult, error) {
+	name := input.Baseline
+	if name == "" {
+		name = defaultBaseline
+	}
+	path, shownPath := dir.file(name)
+	baseline, err := loadBaseline(toolCtx, path, shownPath)
+	if err != nil {
+		return nil, err
+	}
+	if baseline == nil && input.Baseline != "" && input.Action == actionRun {
+		return nil, tools.NotFound(fmt.Errorf(errMsgUnknownBaseline, name, dir.shown, name))
+	}
+
+	root, _, err := resolvePath(toolCtx, ".")
+	if err != nil {
+		return nil, err
+	}
+	packages := input.Packages
+	if len(packages) == 0 {
+		packages = settings.Packages
+	}
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	// Package directories are checked against the path policy; import
+	// paths are go's to find
+	for _, pkg := range packages {
+		if strings.HasPrefix(pkg, ".") || filepath.IsAbs(pkg) {
+			if _, _, err := resolvePath(toolCtx, strings.TrimSuffix(pkg, "/...")); err != nil {
+				return nil, err
+			}
+		}
+	}
+	runs := input.Count
+	if runs == 0 {
+		runs = settings.Runs()
+	}
+	bench := input.Bench
+	if bench == "" {
+		bench = "."
+	}
+	argv := benchCommand(bench, runs, input.Benchtime, packages)
+	commandLine := strings.Join(argv, " ")
+
+	action, preview := "run benchmarks", "$ "+commandLine
+	if input.Action == actionSave {
+		action = fmt.Sprintf("run benchmarks and save them as baseline %q", name)
+		if baseline != nil {
+			preview += fmt.Sprintf("\n(the results replace %s)", shownPath)
+		} else {
+			preview += fmt.Sprintf("\n(the results are saved to %s)", shownPath)
+		}
+	}
+	if !toolCtx.Confirm(confirm.Request{Tool: "benchmark", Action: action, Path: root, Preview: preview}) {
+		return tools.NewTextResult("Benchmark run cancelled by user"), nil
+	}
+
+	output, exitCode, err := run(ctx, toolCtx, argv, root)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return tools.NewErrorResult(fmt.Sprintf("%s failed (exit code %d):\n%s", commandLine, exitCode, lastBytes(output))).WithCommands(commandLine), nil
+	}
+	current := parseResults(output)
+	if len(current.benchmarks) == 0 {
+		text := fmt.Sprintf(errMsgNoBenchmarks+" in %s", bench, strings.Join(packages, " "))
+		if output = lastBytes(output); output != "" {
+			text += "\n\nOutput:\n" + output
+		}
+		return tools.NewErrorResult(text).WithCommands(commandLine), nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Ran %s in %s, %s each\n", count(len(current.benchmarks), "benchmark"), count(len(current.packages()), "package"), count(runs, "time"))
+	against := fmt.Sprintf("baseline %q", name)
+	if input.Action == actionSave {
+		against = fmt.Sprintf("the baseline %q this run replaces", name)
+	}
+	text.WriteString(report(current, baseline, against, settings.RegressionThreshold()))
+
+	if input.Action == actionRun {
+		if baseline == nil {
+			fmt.Fprintf(&text, "\n\nNo baseline %q to compare with; save one with {\"action\": \"save\"}", name)
+		}
+		return tools.NewTextResult(firstBytes(text.String())).WithCommands(commandLine), nil
+	}
+
+	fsys := workspaceOf(toolCtx).FS()
+	data := []byte(strings.Join(current.lines, "\n") + "\n")
+	if err := fsys.MkdirAll(dir.abs, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir.shown, err)
+	}
+	if err := fsys.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", shownPath, err)
+	}
+	if toolCtx != nil {
+		toolCtx.Seen.SawContent(path, data)
+	}
+	fmt.Fprintf(&text, "\n\nSaved as baseline %q in %s", name, shownPath)
+	result := tools.NewTextResult(firstBytes(text.String())).WithCommands(commandLine)
+	if baseline != nil {
+		return result.WithFilesChanged(shownPath), nil
+	}
+	return result.WithFilesCreated(shownPath), nil
+}
+
+// benchCommand returns the configured command with the flags that run only
+// the benchmarks matching bench, runs times each, with their allocations
+func benchCommand(bench string, runs int, benchtime string, packages []string) []string {
+	argv := slices.Clone(settings.CommandLine())
+	argv = append(argv, "-run=^$", "-bench="+bench, "-benchmem", fmt.Sprintf("-count=%d", runs))
+	if benchtime != "" {
+		argv = append(argv, "-benchtime="+benchtime)
+	}
+	if !slices.ContainsFunc(argv, func(arg string) bool { return strings.HasPrefix(arg, "-timeout") }) {
+		argv = append(argv, fmt.Sprintf("-timeout=%dm", int(timeout.Minutes())))
+	}
+	return append(argv, packages...)
+}
+
+// loadBaseline reads a saved baseline, nil when there is none
+func loadBaseline(toolCtx *tools.ToolContext, path, shown string) (*results, error) {
+	data, err := workspaceOf(toolCtx).FS().ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", shown, err)
+	}
+	return parseResults(string(data)), nil
+}
+
+// listBaselines lists the saved baselines with when they were saved and
+// what they hold
+func listBaselines(toolCtx *tools.ToolContext, dir baselineDir) (*tools.ToolResult, error) {
+	fsys := workspaceOf(toolCtx).FS()
+	paths, err := fsys.Glob(filepath.Join(dir.abs, "*.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir.shown, err)
+	}
+	if len(paths) == 0 {
+		return tools.NewTextResult(fmt.Sprintf("No baselines saved in %s; save one with {\"action\": \"save\"}", dir.shown)), nil
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "Baselines in %s:", dir.shown)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		baseline, err := loadBaseline(toolCtx, path, filepath.Join(dir.shown, filepath.Base(path)))
+		if err != nil || baseline == nil {
+			continue
+		}
+		fmt.Fprintf(&text, "\n%s  %s in %s", name, count(len(baseline.benchmarks), "benchmark"), count(len(baseline.packages()), "package"))
+		if info, err := fsys.Stat(path); err == nil {
+			fmt.Fprintf(&text, ", saved %s", info.ModTime().Format("2006-01-02 15:04"))
+		}
+	}
+	return tools.NewTextResult(firstBytes(text.String())), nil
+}
+
+// run runs the benchmarks in dir, showing their output to the user as it
+// arrives. A command that ran is returned whatever its exit code; errors
+// mean it could not be run or was stopped.
+func run(ctx context.Context, toolCtx *tools.ToolContext, argv []string, dir string) (output string, exitCode int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var progress io.Writer
+	if toolCtx != nil && toolCtx.Output != nil {
+		progress = toolCtx.Output
+	}
+	buffer := &outputBuffer{progress: progress}
+	process, err := workspaceOf(toolCtx).Runner().Start(workspace.Cmd{Argv: argv, Dir: dir, Stdout: buffer, Stderr: buffer, Group: true})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to run %s (is it installed and on the PATH?): %w", argv[0], err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+	select {
+	case err = <-exited:
+	case <-ctx.Done():
+		process.Kill()
+		<-exited
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", 0, fmt.Errorf("%s timed out after %s", argv[0], timeout)
+		}
+		return "", 0, fmt.Errorf("%s cancelled: %w", argv[0], ctx.Err())
+	}
+
+	var exit workspace.ExitCoder
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		exitCode = exit.ExitCode()
+	default:
+		return "", 0, fmt.Errorf("%s failed: %w", argv[0], err)
+	}
+	return buffer.String(), exitCode, nil
+}
+
+// outputBuffer collects a command's output while copying it to a live
+// display. Display errors are ignored so a closed terminal never fails
+// the command.
+type outputBuffer struct {
+	mutex    sync.Mutex
+	buffer   bytes.Buffer
+	progress io.Writer
+}
+
+// Write implements io.Writer
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.buffer.Write(p)
+	if b.progress != nil {
+		b.progress.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *outputBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// lastBytes keeps the end of output within maxOutputBytes, from the start
+// of a line
+func lastBytes(output string) string {
+	output = strings.TrimRight(strings.TrimLeft(output, "\n"), " \n")
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := len(output) - maxOutputBytes
+	if i := strings.IndexByte(output[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return fmt.Sprintf("... [%d bytes of output omitted] ...\n%s", cut, output[cut:])
+}
@@ -0,0 +1,189 @@
+package benchmark
+
+import (
+	"math"
+	"slices"
+)
+
+const (
+	// alpha is the p-value below which a change is taken to be real rather
+	// than noise, as benchstat does
+	alpha = 0.05
+	// confidence is the level of the interval around each median
+	confidence = 0.95
+	// maxExactSamples is the largest sample for which the U test's p-value
+	// is counted exactly; larger ones use the normal approximation
+	maxExactSamples = 50
+)
+
+// summary describes a benchmark's values in one unit
+type summary struct {
+	median float64
+	// spread is how far from the median the confidence interval reaches,
+	// as a fraction of it; infinite with too few runs for one
+	spread float64
+	n      int
+}
+
+// summarize returns the median of values and the distribution-free
+// confidence interval around it that benchstat reports
+func summarize(values []float64) summary {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	s := summary{median: median(sorted), spread: math.Inf(1), n: n}
+
+	// The interval between the j-th smallest and j-th largest values holds
+	// the true median with probability 1 - 2 P(B <= j), B ~ Binomial(n, 1/2);
+	// the narrowest one at the confidence level is used
+	j := -1
+	for k := 0; k < n/2; k++ {
+		if 1-2*binomialCDF(k, n) < confidence {
+			break
+		}
+		j = k
+	}
+	if j >= 0 {
+		low, high := sorted[j], sorted[n-1-j]
+		switch {
+		case low == high:
+			s.spread = 0
+		case s.median != 0:
+			s.spread = math.Max(s.median-low, high-s.median) / math.Abs(s.median)
+		}
+	}
+	return s
+}
+
+// median returns the middle of sorted values
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// binomialCDF returns P(B <= k) for B ~ Binomial(n, 1/2)
+func binomialCDF(k, n int) float64 {
+	total, term := 0.0, math.Pow(0.5, float64(n))
+	for i := 0; i <= k; i++ {
+		total += term
+		term *= float64(n-i) / float64(i+1)
+	}
+	return total
+}
+
+// uTest returns the two-sided p-value of the Mann-Whitney U test, the
+// chance of samples as different as a and b if they came from the same
+// distribution. It is exact for small samples without ties.
+func uTest(a, b []float64) float64 {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 1
+	}
+	// U counts the pairs in which a's value is the larger, ties as halves
+	u := 0.0
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case x > y:
+				u++
+			case x == y:
+				u += 0.5
+			}
+		}
+	}
+
+	ties := tieCounts(a, b)
+	if len(ties) == 0 && n <= maxExactSamples && m <= maxExactSamples {
+		counts := uCounts(n, m)
+		total, below, above := 0.0, 0.0, 0.0
+		for k, c := range counts {
+			total += c
+			if float64(k) <= u {
+				below += c
+			}
+			if float64(k) >= u {
+				above += c
+			}
+		}
+		return math.Min(1, 2*math.Min(below, above)/total)
+	}
+
+	// The normal approximation, with the variance corrected for ties
+	N := float64(n + m)
+	correction := 0.0
+	for _, t := range ties {
+		correction += float64(t*t*t - t)
+	}
+	variance := float64(n*m) / 12 * (N + 1 - correction/(N*(N-1)))
+	if variance <= 0 {
+		return 1 // Every value is the same
+	}
+	z := math.Max(0, math.Abs(u-float64(n*m)/2)-0.5) / math.Sqrt(variance)
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// tieCounts returns how many times each value repeats among a and b
+// together, for the values that do
+func tieCounts(a, b []float64) []int {
+	all := slices.Concat(a, b)
+	slices.Sort(all)
+	var ties []int
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j] == all[i] {
+			j++
+		}
+		if j-i > 1 {
+			ties = append(ties, j-i)
+		}
+		i = j
+	}
+	return ties
+}
+
+// uCounts returns, for each value k of U, how many of the orderings of n
+// and m distinct values give U = k
+func uCounts(n, m int) []float64 {
+	// prev[j] holds the counts for i-1 values of a and j of b, and
+	// current[j] those for i and j
+	prev := make([][]float64, m+1)
+	for j := range prev {
+		prev[j] = []float64{1} // Without values of a, U is 0
+	}
+	for i := 1; i <= n; i++ {
+		current := make([][]float64, m+1)
+		current[0] = []float64{1}
+		for j := 1; j <= m; j++ {
+			counts := make([]float64, i*j+1)
+			// The largest value is either a's, larger than all j of b's,
+			// or b's, larger than none of a's
+			for k, c := range prev[j] {
+				counts[k+j] += c
+			}
+			for k, c := range current[j-1] {
+				counts[k] += c
+			}
+			current[j] = counts
+		}
+		prev = current
+	}
+	return prev[m]
+}
+
+// geomean returns the geometric mean of positive values
+func geomean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += math.Log(v)
+	}
+	return math.Exp(sum / float64(len(values)))
+}
@@ -0,0 +1,387 @@
+// Package codemetrics provides a tool that maps where a codebase's
+// technical debt is: the size of each Go package, its most complex
+// functions, and the TODO and FIXME comments left in its source
+package codemetrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgNotDirectory = "%s is a file, not a directory; measure the directory it is in"
+	errMsgUnknownTag   = "tag must be FIXME, BUG, HACK, XXX or TODO, not %q"
+)
+
+// Sections of the report
+const (
+	sectionAll        = "all"
+	sectionSize       = "size"
+	sectionComplexity = "complexity"
+	sectionTodos      = "todos"
+)
+
+const (
+	// defaultMinComplexity is where complexity starts to be listed, as
+	// gocyclo's usual -over 10 has it
+	defaultMinComplexity = 10
+	// maxFiles is how many files are read
+	maxFiles = 20000
+	// maxFileBytes is the size of the largest file read; larger ones are
+	// usually generated or data
+	maxFileBytes = 1 << 20
+	// maxPackages, maxFunctions and maxTodos are how many of each are listed
+	maxPackages  = 40
+	maxFunctions = 30
+	maxTodos     = 150
+	// maxTodoBytes is how much of a TODO comment is shown
+	maxTodoBytes = 160
+	// maxOutputBytes is how much of the report is returned
+	maxOutputBytes = 30000
+)
+
+// skippedDirs are not measured: they hold other projects' code, test
+// fixtures, or none
+var skippedDirs = []string{".git", "node_modules", "vendor", "testdata"}
+
+// CodeMetricsInput represents the input parameters for the code_metrics tool
+type CodeMetricsInput struct {
+	Path          string `json:"path,omitempty" jsonschema_description:"Directory to measure; the workspace when omitted"`
+	Section       string `json:"section,omitempty" jsonschema:"enum=all,enum=size,enum=complexity,enum=todos" jsonschema_description:"Part of the report: size of each Go package, complexity hot spots, the TODO inventory, or all (the default)"`
+	MinComplexity int    `json:"min_complexity,omitempty" jsonschema_description:"Smallest cyclomatic complexity listed among the hot spots (default 10)"`
+	Tag           string `json:"tag,omitempty" jsonschema:"enum=FIXME,enum=BUG,enum=HACK,enum=XXX,enum=TODO" jsonschema_description:"List only the comments with this tag"`
+	IncludeTests  bool   `json:"include_tests,omitempty" jsonschema_description:"Include the functions and TODO comments of _test.go files; their size is always shown apart"`
+}
+
+// Validate implements input validation
+func (i *CodeMetricsInput) Validate() error {
+	switch i.Section {
+	case "", sectionAll, sectionSize, sectionComplexity, sectionTodos:
+	default:
+		return fmt.Errorf("section must be all, size, complexity or todos, not %q", i.Section)
+	}
+	if i.MinComplexity < 0 {
+		return fmt.Errorf("parameter \"min_complexity\" must be positive")
+	}
+	if i.Tag != "" && !slices.Contains(todoTags, i.Tag) {
+		return fmt.Errorf(errMsgUnknownTag, i.Tag)
+	}
+	return nil
+}
+
+// wants reports whether the report includes a section
+func (i *CodeMetricsInput) wants(section string) bool {
+	return i.Section == "" || i.Section == sectionAll || i.Section == section
+}
+
+type CodeMetricsTool struct{}
+
+func (t CodeMetricsTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:      "code_metrics",
+		Group:     "codemetrics",
+		Cacheable: true,
+		Description: `Map where a codebase's technical debt is: lines of code, comments and tests of each Go
+package, the functions with the highest cyclomatic complexity, and the TODO, FIXME, HACK,
+XXX and BUG comments in its source files with where they are.
+
+Usage Examples:
+- {} // The whole workspace
+- {"path": "internal/agent", "section": "complexity", "min_complexity": 15}
+- {"section": "todos", "tag": "FIXME"}
+
+Start cleanup where complexity and TODOs gather, and read a function before judging it:
+complexity counts branches (if, for, case, && and ||), so a long flat switch scores high
+but reads easily. Generated files, vendor and testdata are left out.`,
+		InputSchema: schema.GenerateSchema[CodeMetricsInput](),
+	}
+}
+
+func (t CodeMetricsTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var metricsInput CodeMetricsInput
+	if err := tools.DecodeInput(input, &metricsInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := metricsInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if metricsInput.MinComplexity == 0 {
+		metricsInput.MinComplexity = defaultMinComplexity
+	}
+
+	path := metricsInput.Path
+	if path == "" {
+		path = "."
+	}
+	root, shown, err := resolvePath(toolCtx, path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := workspaceOf(toolCtx).FS().Stat(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, tools.NotFound(fmt.Errorf("%s does not exist", shown))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", shown, err)
+	}
+	if !info.IsDir() {
+		return nil, tools.InvalidInput(fmt.Errorf(errMsgNotDirectory, shown))
+	}
+
+	m, err := measure(ctx, toolCtx, root, shown, &metricsInput)
+	if err != nil {
+		return nil, err
+	}
+	where := shown
+	if where == "." {
+		where = "the workspace"
+	}
+
+	var sections []string
+	if metricsInput.wants(sectionSize) {
+		sections = append(sections, m.sizeReport(where))
+	}
+	if metricsInput.wants(sectionComplexity) {
+		sections = append(sections, m.complexityReport(where, metricsInput.MinComplexity))
+	}
+	if metricsInput.wants(sectionTodos) {
+		sections = append(sections, m.todoReport(where, metricsInput.Tag))
+	}
+	if notes := m.notes(); notes != "" {
+		sections = append(sections, notes)
+	}
+	return tools.NewTextResult(firstBytes(strings.Join(sections, "\n\n"))), nil
+}
+
+// metrics is what measuring a directory found
+type metrics struct {
+	packages  map[string]*packageStats // By directory, as shown
+	functions []hotSpot
+	todos     []todo
+	generated int      // Go files left out for being generated
+	unparsed  []string // Go files with syntax errors
+	tooLarge  int      // Files past maxFileBytes
+	truncated bool     // maxFiles were read before the walk ended
+}
+
+// packageStats is the size of a Go package
+type packageStats struct {
+	dir, name      string
+	files          int
+	code, comments int
+	testFiles      int
+	testCode       int
+	funcs          int
+	complexity     int // Sum over the package's functions
+	maxComplexity  int
+}
+
+// hotSpot is a function and the file it is in
+type hotSpot struct {
+	file string
+	function
+}
+
+// measure walks root, analyzing Go files and searching source files for
+// tagged comments, leaving out ignored and protected paths
+func measure(ctx context.Context, toolCtx *tools.ToolContext, root, shown string, input *CodeMetricsInput) (*metrics, error) {
+	ws := workspaceOf(toolCtx)
+	fsys := ws.FS()
+	wantsGo := input.wants(sectionSize) || input.wants(sectionComplexity)
+	wantsTodos := input.wants(sectionTodos)
+	m := &metrics{packages: map[string]*packageStats{}}
+	read := 0
+
+	err := fsys.Walk(root, func(file string, info fs.FileInfo, err error) error {
+		if err != nil {
+			if file == root {
+				return err
+			}
+			return nil // Unreadable entries are left out
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file != root && ws != nil {
+			if info.IsDir() && (ws.CheckDirAccess(file) != nil || ws.Ignored(file, true)) {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && (ws.CheckAccess(file) != nil || ws.Ignored(file, false)) {
+				return nil
+			}
+		}
+		if info.IsDir() {
+			if file != root && slices.Contains(skippedDirs, info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		isGo := filepath.Ext(file) == ".go"
+		if !(isGo && wantsGo) && !(isSource(file) && wantsTodos) {
+			return nil
+		}
+		if info.Size() > maxFileBytes {
+			m.tooLarge++
+			return nil
+		}
+		if read >= maxFiles {
+			m.truncated = true
+			return filepath.SkipAll
+		}
+		data, err := fsys.ReadFile(file)
+		if err != nil {
+			return nil
+		}
+		read++
+
+		shownFile := displayPath(file, shown, root)
+		test := strings.HasSuffix(file, "_test.go")
+		if isGo {
+			g, err := analyzeGo(file, data)
+			switch {
+			case err != nil:
+				m.unparsed = append(m.unparsed, shownFile)
+			case g.generated:
+				m.generated++
+				return nil
+			default:
+				m.addGoFile(shownFile, g, test, input.IncludeTests)
+			}
+		}
+		if wantsTodos && (!test || input.IncludeTests) {
+			m.todos = append(m.todos, findTodos(shownFile, data)...)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, tools.NotFound(fmt.Errorf("%s does not exist", shown))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", shown, err)
+	}
+	return m, nil
+}
+
+// addGoFile adds a Go file to its package, and its functions to the hot
+// spots unless it is a test left out
+func (m *metrics) addGoFile(file string, g *goFile, test, includeTests bool) {
+	dir := filepath.ToSlash(filepath.Dir(file))
+	p := m.packages[dir]
+	if p == nil {
+		p = &packageStats{dir: dir}
+		m.packages[dir] = p
+	}
+	if test {
+		p.testFiles++
+		p.testCode += g.code
+		if !includeTests {
+			return
+		}
+	} else {
+		p.files++
+		p.code += g.code
+		p.comments += g.comments
+		// External test packages are named after the package, so the
+		// package's own files name it
+		p.name = g.pkg
+	}
+	for _, fn := range g.funcs {
+		p.funcs++
+		p.complexity += fn.complexity
+		p.maxComplexity = max(p.maxComplexity, fn.complexity)
+		m.functions = append(m.functions, hotSpot{file: file, function: fn})
+	}
+}
+
+// notes reports what was left out of the report
+func (m *metrics) notes() string {
+	var notes []string
+	if m.generated > 0 {
+		notes = append(notes, fmt.Sprintf("%s left out", count(m.generated, "generated Go file")))
+	}
+	if len(m.unparsed) > 0 {
+		notes = append(notes, fmt.Sprintf("%s with syntax errors left out: %s", count(len(m.unparsed), "Go file"), strings.Join(m.unparsed[:min(len(m.unparsed), 10)], ", ")))
+	}
+	if m.tooLarge > 0 {
+		notes = append(notes, fmt.Sprintf("%s over %d MB left out", count(m.tooLarge, "file"), maxFileBytes>>20))
+	}
+	if m.truncated {
+		notes = append(notes, fmt.Sprintf("Stopped after %d files; measure a subdirectory for complete numbers", maxFiles))
+	}
+	return strings.Join(notes, "\n")
+}
+
+// resolvePath resolves a path within the workspace, asking the user first
+// when the path policy says to
+func resolvePath(toolCtx *tools.ToolContext, path string) (resolved, shown string, err error) {
+	ws := workspaceOf(toolCtx)
+	if ws == nil {
+		return path, filepath.Clean(path), nil
+	}
+	p, err := ws.ResolvePath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "measure code outside the workspace", Path: p.Abs}) {
+		return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+	}
+	return p.Abs, p.Shown, nil
+}
+
+// displayPath names a file found under root, which is shown as shown
+func displayPath(file, shown, root string) string {
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == "." {
+		return shown
+	}
+	if shown == "." {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filepath.Join(shown, rel))
+}
+
+// count writes a number of things, e.g. "1 package" or "3 packages"
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of output within maxOutputBytes, up to the
+// end of a line
+func firstBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(output[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted; measure a subdirectory or one section] ...", output[:cut], len(output)-cut)
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(CodeMetricsTool{})
+}
@@ -0,0 +1,39 @@
+package codemetrics
+
+import (
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// sourceFiles is the workspace the code_metrics tool is fuzzed in
+var sourceFiles = map[string]string{
+	"internal/agent/agent.go": `package agent
+
+// FIXME(joel): retry on 503
+func Run(n int) int {
+	switch {
+	case n > 1 && n < 10:
+		return Run(n - 1)
+	case n == 0:
+		return 0
+	}
+	for i := range n {
+		if i%2 == 0 || i%3 == 0 {
+			n--
+		}
+	}
+	return n
+}
+`,
+	"internal/agent/agent_test.go": "package agent\n\n// TODO: cover Run\n",
+	"web/app.js":                   "// HACK: works around a browser bug\nfunction main() {}\n",
+	"broken.go":                    "package broken\n\nfunc {",
+}
+
+func FuzzCodeMetrics(f *testing.F) {
+	toolstest.Seed(f, CodeMetricsTool{}, `{"section": "size", "include_tests": true}`, `{"path": "broken.go"}`, `{"section": "todos", "tag": "todo"}`, `{"min_complexity": -1}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, CodeMetricsTool{}, toolstest.Context(t, sourceFiles), input)
+	})
+}
@@ -0,0 +1,146 @@
+package codemetrics
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+)
+
+// goFile is what a Go file adds to its package's metrics
+type goFile struct {
+	pkg       string // Name in the package clause
+	generated bool   // Marked "Code generated ... DO NOT EDIT."
+	code      int    // Lines with code, comments or not
+	comments  int    // Lines with only comments
+	blank     int
+	funcs     []function
+}
+
+// function is a function or method and its cyclomatic complexity
+type function struct {
+	name       string // Name, e.g. Parse or (*Parser).next
+	line       int
+	complexity int
+}
+
+// analyzeGo counts a Go file's lines and the complexity of its functions.
+// It fails for files that do not parse.
+func analyzeGo(path string, src []byte) (*goFile, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	g := &goFile{pkg: file.Name.Name, generated: ast.IsGenerated(file)}
+	g.code, g.comments, g.blank = countLines(src)
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		g.funcs = append(g.funcs, function{name: funcName(fn), line: fset.Position(fn.Pos()).Line, complexity: complexity(fn.Body)})
+	}
+	return g, nil
+}
+
+// countLines sorts a Go file's lines into those with code, those with only
+// comments and blank ones, by the tokens on each
+func countLines(src []byte) (code, comments, blank int) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	codeLines, commentLines := map[int]bool{}, map[int]bool{}
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // Inserted at line ends, not written
+		}
+		// Comments and raw strings may span lines
+		first, last := file.Line(pos), file.Line(pos)
+		if len(lit) > 1 {
+			last = file.Line(pos + token.Pos(len(lit)-1))
+		}
+		lines := codeLines
+		if tok == token.COMMENT {
+			lines = commentLines
+		}
+		for line := first; line <= last; line++ {
+			lines[line] = true
+		}
+	}
+
+	total := bytes.Count(src, []byte("\n"))
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		total++
+	}
+	for line := range commentLines {
+		if !codeLines[line] {
+			comments++
+		}
+	}
+	code = len(codeLines)
+	return code, comments, total - code - comments
+}
+
+// complexity returns a function's cyclomatic complexity as gocyclo counts
+// it: 1, plus 1 for each if, for, case and && or ||. Function literals
+// count towards the function they are in.
+func complexity(body *ast.BlockStmt) int {
+	c := 1
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if n.List != nil { // Not default
+				c++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+	return c
+}
+
+// funcName names a function, and a method with its receiver's type:
+// (*Parser).next or Set.Add
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	receiver := fn.Recv.List[0].Type
+	pointer := ""
+	if star, ok := receiver.(*ast.StarExpr); ok {
+		pointer, receiver = "*", star.X
+	}
+	// Type parameters are left out: (*Cache[K, V]).Get is (*Cache).Get
+	switch r := receiver.(type) {
+	case *ast.IndexExpr:
+		receiver = r.X
+	case *ast.IndexListExpr:
+		receiver = r.X
+	}
+	name := "?"
+	if ident, ok := receiver.(*ast.Ident); ok {
+		name = ident.Name
+	}
+	if pointer != "" {
+		return "(*" + name + ")." + fn.Name.Name
+	}
+	return name + "." + fn.Name.Name
+}
@@ -0,0 +1,171 @@
+package codemetrics
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// sizeReport writes the size of each Go package, largest first:
+//
+//	package                 files   code   comments   tests   funcs   complexity
+//	internal/agent          12      3410   612        1890    140     4.1 avg, 38 max
+func (m *metrics) sizeReport(where string) string {
+	if len(m.packages) == 0 {
+		return fmt.Sprintf("No Go packages in %s", where)
+	}
+	packages := make([]*packageStats, 0, len(m.packages))
+	var files, code, comments, testCode int
+	for _, p := range m.packages {
+		packages = append(packages, p)
+		files += p.files
+		code += p.code
+		comments += p.comments
+		testCode += p.testCode
+	}
+	slices.SortFunc(packages, func(a, b *packageStats) int {
+		if c := cmp.Compare(b.code, a.code); c != 0 {
+			return c
+		}
+		return strings.Compare(a.dir, b.dir)
+	})
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Go code in %s: %s, %s, %d lines of code, %d of comments and %d of tests\n",
+		where, count(len(packages), "package"), count(files, "file"), code, comments, testCode)
+	table := tabwriter.NewWriter(&text, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(table, "package\tfiles\tcode\tcomments\ttests\tfuncs\tcomplexity")
+	for _, p := range packages[:min(len(packages), maxPackages)] {
+		name := p.dir
+		if p.name != "" && p.name != path.Base(p.dir) {
+			name += " (" + p.name + ")"
+		}
+		complexity := "-"
+		if p.funcs > 0 {
+			complexity = fmt.Sprintf("%.1f avg, %d max", float64(p.complexity)/float64(p.funcs), p.maxComplexity)
+		}
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", name, p.files, p.code, p.comments, p.testCode, p.funcs, complexity)
+	}
+	table.Flush()
+	if rest := packages[min(len(packages), maxPackages):]; len(rest) > 0 {
+		lines := 0
+		for _, p := range rest {
+			lines += p.code
+		}
+		fmt.Fprintf(&text, "... and %s with %d lines of code\n", count(len(rest), "more package"), lines)
+	}
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// complexityReport lists the functions of at least minComplexity, most
+// complex first
+func (m *metrics) complexityReport(where string, minComplexity int) string {
+	if len(m.functions) == 0 {
+		return fmt.Sprintf("No Go functions in %s", where)
+	}
+	total := 0
+	var hot []hotSpot
+	for _, fn := range m.functions {
+		total += fn.complexity
+		if fn.complexity >= minComplexity {
+			hot = append(hot, fn)
+		}
+	}
+	slices.SortFunc(hot, func(a, b hotSpot) int {
+		if c := cmp.Compare(b.complexity, a.complexity); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.file, b.file); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.line, b.line)
+	})
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Cyclomatic complexity of %s in %s: %.1f on average", count(len(m.functions), "function"), where, float64(total)/float64(len(m.functions)))
+	if len(hot) == 0 {
+		fmt.Fprintf(&text, "; none reach %d", minComplexity)
+		return text.String()
+	}
+	fmt.Fprintf(&text, "; %d of %d or more:", len(hot), minComplexity)
+	for _, fn := range hot[:min(len(hot), maxFunctions)] {
+		fmt.Fprintf(&text, "\n%4d  %s:%d  %s", fn.complexity, fn.file, fn.line, fn.name)
+	}
+	if len(hot) > maxFunctions {
+		fmt.Fprintf(&text, "\n... and %d more", len(hot)-maxFunctions)
+	}
+	return text.String()
+}
+
+// todoReport counts the TODO comments by tag and directory, then lists
+// them by tag, most pressing first
+func (m *metrics) todoReport(where, tag string) string {
+	todos := m.todos
+	tags := todoTags
+	if tag != "" {
+		todos = slices.DeleteFunc(slices.Clone(todos), func(t todo) bool { return t.tag != tag })
+		tags = []string{tag}
+	}
+	if len(todos) == 0 {
+		return fmt.Sprintf("No %s comments in %s", strings.Join(tags, ", "), where)
+	}
+
+	byTag := map[string][]todo{}
+	byDir := map[string]int{}
+	for _, t := range todos {
+		byTag[t.tag] = append(byTag[t.tag], t)
+		byDir[path.Dir(t.file)]++
+	}
+	var counts []string
+	for _, tag := range tags {
+		if n := len(byTag[tag]); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, tag))
+		}
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s in %s: %s", count(len(todos), "TODO comment"), where, strings.Join(counts, ", "))
+
+	if len(byDir) > 1 {
+		dirs := make([]string, 0, len(byDir))
+		for dir := range byDir {
+			dirs = append(dirs, dir)
+		}
+		slices.SortFunc(dirs, func(a, b string) int {
+			if c := cmp.Compare(byDir[b], byDir[a]); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		})
+		var most []string
+		for _, dir := range dirs[:min(len(dirs), 10)] {
+			most = append(most, fmt.Sprintf("%s (%d)", dir, byDir[dir]))
+		}
+		fmt.Fprintf(&text, "\nMost in: %s", strings.Join(most, ", "))
+	}
+
+	listed := 0
+	for _, tag := range tags {
+		if len(byTag[tag]) == 0 || listed == maxTodos {
+			continue
+		}
+		text.WriteString("\n\n" + tag)
+		for _, t := range byTag[tag] {
+			if listed == maxTodos {
+				break
+			}
+			listed++
+			fmt.Fprintf(&text, "\n  %s:%d  ", t.file, t.line)
+			if t.owner != "" {
+				fmt.Fprintf(&text, "(%s) ", t.owner)
+			}
+			text.WriteString(t.text)
+		}
+	}
+	if listed < len(todos) {
+		fmt.Fprintf(&text, "\n... and %d more; list one tag or directory at a time", len(todos)-listed)
+	}
+	return text.String()
+}
@@ -0,0 +1,80 @@
+package codemetrics
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Tags of the comments the inventory lists, most pressing first
+var todoTags = []string{"FIXME", "BUG", "HACK", "XXX", "TODO"}
+
+// todoComment matches a tag at the start of a comment, after //, #, /*,
+// --, ; or <!--, with an optional owner or issue in parentheses and a
+// colon, as in TODO(joel): retry on 503. The comment marker must follow the
+// start of the line or a space, so URLs such as http://example.com/TODO
+// are not taken for comments.
+var todoComment = regexp.MustCompile(`(?:^|\s)(?://+|#+|/\*+|\*|--|;+|<!--)\s*(FIXME|BUG|HACK|XXX|TODO)\b(?:\(([^)]*)\))?:?\s*(.*)`)
+
+// sourceExtensions are the files searched for TODO comments: source code
+// and the configuration that lives with it
+var sourceExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".mjs": true, ".ts": true, ".tsx": true,
+	".rb": true, ".java": true, ".kt": true, ".scala": true, ".c": true, ".h": true, ".cc": true,
+	".cpp": true, ".hpp": true, ".rs": true, ".swift": true, ".cs": true, ".php": true,
+	".sh": true, ".bash": true, ".sql": true, ".proto": true, ".tf": true, ".lua": true,
+	".ex": true, ".exs": true, ".erl": true, ".hs": true, ".vue": true, ".svelte": true,
+	".css": true, ".scss": true, ".html": true, ".yml": true, ".yaml": true, ".toml": true,
+}
+
+// sourceNames are source files known by name rather than extension
+var sourceNames = map[string]bool{"Makefile": true, "Dockerfile": true, "Justfile": true}
+
+// isSource reports whether a file is searched for TODO comments
+func isSource(path string) bool {
+	return sourceExtensions[filepath.Ext(path)] || sourceNames[filepath.Base(path)]
+}
+
+// todo is a TODO comment and where it is
+type todo struct {
+	file  string
+	line  int
+	tag   string
+	owner string // What the parentheses after the tag hold, e.g. a name or issue
+	text  string
+}
+
+// findTodos returns the TODO comments of a file
+func findTodos(file string, src []byte) []todo {
+	var todos []todo
+	lines := bufio.NewScanner(bytes.NewReader(src))
+	lines.Buffer(nil, maxFileBytes)
+	for number := 1; lines.Scan(); number++ {
+		line := lines.Text()
+		if !strings.Contains(line, "TODO") && !strings.Contains(line, "FIXME") && !strings.Contains(line, "HACK") &&
+			!strings.Contains(line, "XXX") && !strings.Contains(line, "BUG") {
+			continue
+		}
+		m := todoComment.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		text := strings.TrimSpace(m[3])
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(text, "*/"), "-->"))
+		todos = append(todos, todo{file: file, line: number, tag: m[1], owner: m[2], text: truncate(text, maxTodoBytes)})
+	}
+	return todos
+}
+
+// truncate shortens text to at most n bytes, on a character boundary
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && text[n]&0xC0 == 0x80 {
+		n--
+	}
+	return text[:n] + "..."
+}
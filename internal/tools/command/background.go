@@ -0,0 +1,239 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants for background commands
+const errMsgUnknownHandle = "no background command with handle %q (running: %s)"
+
+// maxBufferedBytes bounds how much unread output is kept per background
+// command; older output is dropped first
+const maxBufferedBytes = 1 << 20
+
+// stopGracePeriod is how long a stopped command may take to exit before it is killed
+const stopGracePeriod = 5 * time.Second
+
+// backgroundProcess is a command started with background: true
+type backgroundProcess struct {
+	handle    string
+	name      string
+	process   workspace.Process
+	started   time.Time
+	maxOutput int
+	done      chan struct{} // Closed once the process has exited
+
+	mutex    sync.Mutex
+	output   []byte // Buffered output, starting at offset dropped
+	dropped  int    // Bytes discarded because the buffer was full
+	cursor   int    // Offset up to which output has been read
+	finished time.Time
+	exitErr  error
+	stopped  bool
+}
+
+// Write implements io.Writer for the process's combined stdout and stderr
+func (p *backgroundProcess) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.output = append(p.output, data...)
+	if excess := len(p.output) - maxBufferedBytes; excess > 0 {
+		p.output = append([]byte(nil), p.output[excess:]...)
+		p.dropped += excess
+	}
+	return len(data), nil
+}
+
+// readNew returns output written since the previous read and how many
+// unread bytes were dropped before they could be read
+func (p *backgroundProcess) readNew() (data []byte, lost int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.cursor < p.dropped {
+		lost = p.dropped - p.cursor
+		p.cursor = p.dropped
+	}
+	data = append([]byte(nil), p.output[p.cursor-p.dropped:]...)
+	p.cursor = p.dropped + len(p.output)
+	return data, lost
+}
+
+// status describes the process state in one line
+func (p *backgroundProcess) status() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	unread := p.dropped + len(p.output) - p.cursor
+	select {
+	case <-p.done:
+	default:
+		return fmt.Sprintf("%s: %q running for %s (%s), %d bytes of unread output",
+			p.handle, p.name, time.Since(p.started).Round(time.Second), p.process.ID(), unread)
+	}
+
+	outcome := "exited successfully"
+	var exitErr workspace.ExitCoder
+	switch {
+	case p.stopped:
+		outcome = "was stopped"
+	case errors.As(p.exitErr, &exitErr):
+		outcome = fmt.Sprintf("exited with code %d", exitErr.ExitCode())
+	case p.exitErr != nil:
+		outcome = "failed: " + p.exitErr.Error()
+	}
+	return fmt.Sprintf("%s: %q %s after %s, %d bytes of unread output",
+		p.handle, p.name, outcome, p.finished.Sub(p.started).Round(time.Millisecond), unread)
+}
+
+// processTable tracks background commands for the session
+type processTable struct {
+	mutex     sync.Mutex
+	processes map[string]*backgroundProcess
+	next      int
+}
+
+// background holds every background command started in this session
+var background = &processTable{processes: map[string]*backgroundProcess{}}
+
+// start launches cmd with runner without waiting for it. A positive
+// timeout stops the process once it has run that long.
+func (t *processTable) start(name string, runner workspace.Runner, cmd workspace.Cmd, timeout time.Duration, maxOutput int) (*backgroundProcess, error) {
+	t.mutex.Lock()
+	t.next++
+	handle := fmt.Sprintf("bg-%d", t.next)
+	t.mutex.Unlock()
+
+	process := &backgroundProcess{
+		handle:    handle,
+		name:      name,
+		maxOutput: maxOutput,
+		done:      make(chan struct{}),
+	}
+	cmd.Stdout = process
+	cmd.Stderr = process
+	cmd.Group = true
+
+	started, err := runner.Start(cmd)
+	if err != nil {
+		return nil, err
+	}
+	process.process = started
+	process.started = time.Now()
+
+	go func() {
+		err := started.Wait()
+		process.mutex.Lock()
+		process.finished = time.Now()
+		process.exitErr = err
+		process.mutex.Unlock()
+		close(process.done)
+	}()
+	if timeout > 0 {
+		go func() {
+			select {
+			case <-process.done:
+			case <-time.After(timeout):
+				process.stop()
+			}
+		}()
+	}
+
+	t.mutex.Lock()
+	t.processes[handle] = process
+	t.mutex.Unlock()
+	return process, nil
+}
+
+// lookup returns the process for a handle
+func (t *processTable) lookup(handle string) (*backgroundProcess, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	process, ok := t.processes[handle]
+	if !ok {
+		return nil, tools.NotFound(fmt.Errorf(errMsgUnknownHandle, handle, t.handlesLocked()))
+	}
+	return process, nil
+}
+
+// list returns every process in the order it was started
+func (t *processTable) list() []*backgroundProcess {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	processes := make([]*backgroundProcess, 0, len(t.processes))
+	for _, process := range t.processes {
+		processes = append(processes, process)
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].started.Before(processes[j].started) })
+	return processes
+}
+
+func (t *processTable) handlesLocked() string {
+	var handles []string
+	for handle, process := range t.processes {
+		select {
+		case <-process.done:
+		default:
+			handles = append(handles, handle)
+		}
+	}
+	if len(handles) == 0 {
+		return "none"
+	}
+	sort.Strings(handles)
+	return strings.Join(handles, ", ")
+}
+
+// stop asks the process (and anything it started) to exit, killing it if it
+// is still running after the grace period
+func (p *backgroundProcess) stop() {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	p.mutex.Lock()
+	p.stopped = true
+	p.mutex.Unlock()
+
+	p.process.Terminate()
+	select {
+	case <-p.done:
+	case <-time.After(stopGracePeriod):
+		p.process.Kill()
+		<-p.done
+	}
+}
+
+// StopBackground stops every background command that is still running.
+// Call it before exiting so dev servers and watchers do not outlive the session.
+func StopBackground() {
+	var wg sync.WaitGroup
+	for _, process := range background.list() {
+		wg.Add(1)
+		go func(process *backgroundProcess) {
+			defer wg.Done()
+			process.stop()
+		}(process)
+	}
+	wg.Wait()
+}
+
+// startBackground runs an approved command in the background and returns its handle
+func startBackground(commandName string, runner workspace.Runner, cmd workspace.Cmd, timeout time.Duration, maxOutput int) (*tools.ToolResult, error) {
+	process, err := background.start(commandName, runner, cmd, timeout, maxOutput)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgCommandFailed, commandName, err)
+	}
+	return tools.NewTextResult(fmt.Sprintf(
+		"Started %q in the background with handle %s (%s).\n"+
+			"Use command_status to check on it, command_output to read new output, and stop_command to stop it.",
+		commandName, process.handle, process.process.ID())), nil
+}
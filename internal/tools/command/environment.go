@@ -0,0 +1,65 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/tools"
+)
+
+// resolveWorkdir expands variables in a command's workdir and resolves it.
+// Commands run from the directory of the project file that defined them
+// (relative workdirs included), so they behave the same from any
+// subdirectory; global commands use the workspace root instead, as do all
+// commands of remote workspaces, whose project files are local.
+func resolveWorkdir(toolCtx *tools.ToolContext, spec config.CommandSpec) (string, error) {
+	ws := workspaceOf(toolCtx)
+	workdir := os.ExpandEnv(spec.Workdir)
+	base := spec.Dir
+	if (base == "" || ws.Host() != "") && ws != nil {
+		base = ws.Root()
+	}
+	if workdir == "" {
+		return base, nil
+	}
+	if !filepath.IsAbs(workdir) && base != "" {
+		workdir = filepath.Join(base, workdir)
+	}
+
+	info, err := ws.FS().Stat(workdir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", spec.Workdir)
+	}
+	return workdir, nil
+}
+
+// commandPreview shows what will run, including where and with which extra variables
+func commandPreview(workdir string, vars map[string]string, commandLine string) string {
+	var b strings.Builder
+	if workdir != "" {
+		if cwd, err := os.Getwd(); err != nil || filepath.Clean(workdir) != cwd {
+			fmt.Fprintf(&b, "(in %s)\n", workdir)
+		}
+	}
+	for _, name := range sortedKeys(vars) {
+		fmt.Fprintf(&b, "%s=%s\n", name, quoteCommand([]string{os.ExpandEnv(vars[name])}))
+	}
+	b.WriteString("$ " + commandLine)
+	return b.String()
+}
+
+func sortedKeys[V any](vars map[string]V) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
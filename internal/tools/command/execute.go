@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io"
+	"sort"
 	"strings"
 	"time"
 
 	"agent/internal/config"
+	"agent/internal/confirm"
 	"agent/internal/schema"
 	"agent/internal/tools"
+	"agent/internal/workspace"
 )
 
 // Error message constants
@@ -23,7 +26,8 @@ const (
 )
 
 type CommandInput struct {
-	Name string `json:"name" jsonschema:"required" jsonschema_description:"Name of the command to execute from project configuration, or 'list' to show available commands"`
+	Name string         `json:"name" jsonschema:"required" jsonschema_description:"Name of the command to execute from project configuration, or 'list' to show available commands"`
+	Args map[string]any `json:"args,omitempty" jsonschema_description:"Arguments for the command's declared parameters, e.g. {\"package\": \"./internal/...\"}. Use 'list' to see each command's parameters."`
 }
 
 // Validate implements input validation
@@ -38,7 +42,10 @@ type CommandTool struct{}
 
 func (t CommandTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name: "execute_command",
+		Name:     "execute_command",
+		Group:    "command",
+		Mutating: true,
+		Confirms: true,
 		Description: `Execute predefined commands for code validation (lint, test, build).
 
 Usage Examples:
@@ -46,43 +53,53 @@ Usage Examples:
 - {"name": "lint"} // Run linter
 - {"name": "test"} // Run tests  
 - {"name": "build"} // Build application
+- {"name": "test_package", "args": {"package": "./internal/config"}} // Command with parameters
+- {"name": "check"} // Command group: runs its members (e.g. lint, test, vet) in parallel
+
+Commands configured with background: true (dev servers, watchers) return a handle such as
+"bg-1" right away; use command_status, command_output and stop_command with it.
 
 Security:
-- Only commands defined in .agent-commands.yml can be executed
+- Only commands defined in .agent-commands.yml (or the user's global commands.yml) can be executed
 - Commands have timeouts to prevent hanging processes
-- No arbitrary command execution allowed
+- No arbitrary command execution allowed (commands marked shell: true run their configured
+  string through the system shell)
+- Arguments are validated against each command's declared parameters and substituted into
+  single words of the command line (no shell), so they cannot add options or shell syntax
+
+Results are JSON with exit_code, duration_ms, timed_out, stdout and stderr.
 
 Use this tool after making code changes to validate they work correctly.`,
 		InputSchema: schema.GenerateSchema[CommandInput](),
 	}
 }
 
-func (t CommandTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t CommandTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	commandInput, err := t.parseAndValidateInput(input)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
 	}
 
 	// Load config each time to pick up changes
-	config, err := config.LoadCommandsConfig(".agent-commands.yml")
+	config, err := config.DiscoverCommandsConfig(commandsSearchDir(toolCtx))
 	if err != nil {
-		return "", fmt.Errorf("failed to load command configuration: %w", err)
+		return nil, fmt.Errorf("failed to load command configuration: %w", err)
 	}
 
 	// Handle list command
 	if commandInput.Name == "list" {
-		return t.listCommands(config), nil
+		return tools.NewTextResult(t.listCommands(config)), nil
 	}
 
 	// Execute specific command
-	return t.executeCommand(config, commandInput.Name)
+	return t.executeCommand(ctx, toolCtx, config, commandInput.Name, commandInput.Args)
 }
 
 // Helper methods for better separation of concerns
 func (t CommandTool) parseAndValidateInput(input json.RawMessage) (*CommandInput, error) {
 	var commandInput CommandInput
-	if err := json.Unmarshal(input, &commandInput); err != nil {
-		return nil, fmt.Errorf("invalid JSON input: %w", err)
+	if err := tools.DecodeInput(input, &commandInput); err != nil {
+		return nil, err
 	}
 
 	if err := commandInput.Validate(); err != nil {
@@ -94,51 +111,213 @@ func (t CommandTool) parseAndValidateInput(input json.RawMessage) (*CommandInput
 
 func (t CommandTool) listCommands(config *config.CommandsConfig) string {
 	if len(config.Commands) == 0 {
-		return "No commands available. Create .agent-commands.yml file with command definitions."
+		return "No commands available. Create .agent-commands.yml (or ~/.config/billdozer/commands.yml) with command definitions."
 	}
 
 	var result strings.Builder
+	if len(config.Detected) > 0 {
+		fmt.Fprintf(&result, "No .agent-commands.yml found; commands were auto-detected from %s (create .agent-commands.yml to override them).\n",
+			strings.Join(config.Detected, ", "))
+	}
 	result.WriteString("Available commands:\n")
-	for name, spec := range config.Commands {
-		result.WriteString(fmt.Sprintf("- %s: %s\n", name, spec.Description))
+	for _, name := range t.sortedCommandNames(config) {
+		spec := config.Commands[name]
+		result.WriteString(fmt.Sprintf("- %s: %s", name, spec.Description))
+		if spec.RequiresConfirmation() {
+			result.WriteString(" [asks the user before every run]")
+		}
+		result.WriteString("\n")
+		if len(spec.Parameters) > 0 {
+			result.WriteString("  parameters:\n")
+			result.WriteString(describeParameters(spec))
+		}
+	}
+	if len(config.Groups) > 0 {
+		result.WriteString("Command groups (members run in parallel):\n")
+		for _, name := range sortedKeys(config.Groups) {
+			result.WriteString(fmt.Sprintf("- %s: %s\n", name, strings.Join(config.Groups[name], ", ")))
+		}
 	}
 	return result.String()
 }
 
-func (t CommandTool) executeCommand(config *config.CommandsConfig, commandName string) (string, error) {
+func (t CommandTool) executeCommand(ctx context.Context, toolCtx *tools.ToolContext, config *config.CommandsConfig, commandName string, args map[string]any) (*tools.ToolResult, error) {
+	if members, ok := config.Groups[commandName]; ok {
+		return t.executeGroup(ctx, toolCtx, config, commandName, members, args)
+	}
+
+	command, err := t.prepareCommand(toolCtx, config, commandName, args)
+	if err != nil {
+		return nil, err
+	}
+	spec := command.spec
+
+	action := fmt.Sprintf("run the %q command", commandName)
+	if spec.Danger != "" {
+		action = fmt.Sprintf("run the %q command (danger: %s)", commandName, spec.Danger)
+	}
+	approved := toolCtx.Confirm(confirm.Request{
+		Tool:    "execute_command",
+		Action:  action,
+		Path:    spec.Workdir,
+		Preview: command.preview(),
+		Force:   spec.RequiresConfirmation(),
+	})
+	if !approved {
+		return tools.NewTextResult("Command execution cancelled by user"), nil
+	}
+
+	if spec.Background {
+		// Background commands outlive this tool call; the timeout, if any, bounds their lifetime
+		cmd := workspace.Cmd{Argv: command.argv, Dir: command.workdir, Env: spec.Env, Group: true}
+		result, err := startBackground(commandName, command.runner, cmd, time.Duration(spec.TimeoutSeconds)*time.Second, command.maxOutput)
+		if err != nil {
+			return nil, err
+		}
+		return result.WithCommands(command.display), nil
+	}
+
+	// Stream output to the user as it arrives while collecting each stream for the result
+	var progress io.Writer
+	if toolCtx != nil && toolCtx.Output != nil {
+		progress = newLockedWriter(toolCtx.Output)
+	}
+	result := command.run(ctx, progress)
+
+	// Failures keep their output so the agent can see error details
+	if result.Failed() {
+		return tools.NewErrorResult(result.String()).WithCommands(command.display), nil
+	}
+	return tools.NewTextResult(result.String()).WithCommands(command.display), nil
+}
+
+// preparedCommand is a configured command with its arguments substituted,
+// ready to be confirmed and run
+type preparedCommand struct {
+	name      string
+	spec      config.CommandSpec
+	argv      []string
+	display   string
+	workdir   string
+	maxOutput int
+	runner    workspace.Runner
+}
+
+func (t CommandTool) prepareCommand(toolCtx *tools.ToolContext, config *config.CommandsConfig, commandName string, args map[string]any) (*preparedCommand, error) {
 	spec, exists := config.Commands[commandName]
 	if !exists {
-		return "", fmt.Errorf(errMsgCommandNotFound, commandName, t.getCommandNames(config))
+		return nil, tools.NotFound(fmt.Errorf(errMsgCommandNotFound, commandName, t.getCommandNames(config)))
 	}
 
-	// Parse command and args
-	parts := strings.Fields(spec.Command)
+	// Substitute arguments into the command template
+	parts, display, err := expandCommand(commandName, spec, args)
+	if err != nil {
+		return nil, err
+	}
 	if len(parts) == 0 {
-		return "", fmt.Errorf(errMsgEmptyCommand, commandName)
+		return nil, fmt.Errorf(errMsgEmptyCommand, commandName)
+	}
+
+	workdir, err := resolveWorkdir(toolCtx, spec)
+	if err != nil {
+		return nil, fmt.Errorf("command %q: invalid workdir: %w", commandName, err)
 	}
 
-	// Create command with timeout
-	ctx, cancel := context.WithTimeout(context.Background(),
-		time.Duration(spec.TimeoutSeconds)*time.Second)
+	return &preparedCommand{
+		name:      commandName,
+		spec:      spec,
+		argv:      parts,
+		display:   display,
+		workdir:   workdir,
+		maxOutput: maxOutputBytes(config, spec),
+		runner:    workspaceOf(toolCtx).Runner(),
+	}, nil
+}
+
+// preview shows the command line, workdir and variables for confirmation
+func (c *preparedCommand) preview() string {
+	return commandPreview(c.workdir, c.spec.Env, c.display)
+}
+
+// run executes the command in the foreground within its timeout, copying
+// output to progress (when set) as it arrives
+func (c *preparedCommand) run(ctx context.Context, progress io.Writer) *CommandResult {
+	ctx, cancel := context.WithTimeout(ctx,
+		time.Duration(c.spec.TimeoutSeconds)*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	stdout := newOutputWriter(progress)
+	stderr := newOutputWriter(progress)
+	start := time.Now()
+	err := runUntilDone(ctx, c.runner, workspace.Cmd{Argv: c.argv, Dir: c.workdir, Env: c.spec.Env, Stdout: stdout, Stderr: stderr})
+	result := newCommandResult(c.name, err, time.Since(start), stdout.Bytes(), stderr.Bytes(), c.maxOutput)
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		result.TimedOut = true
+		result.Error = fmt.Sprintf("timed out after %ds", c.spec.TimeoutSeconds)
+	case context.Canceled:
+		result.Cancelled = true
+		result.Error = "cancelled"
+	}
+	return result
+}
 
-	output, err := cmd.CombinedOutput()
+// runUntilDone runs cmd, killing it if ctx is done first
+func runUntilDone(ctx context.Context, runner workspace.Runner, cmd workspace.Cmd) error {
+	process, err := runner.Start(cmd)
 	if err != nil {
-		// Return output even on failure so agent can see error details
-		return string(output), fmt.Errorf(errMsgCommandFailed, commandName, err)
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+		process.Kill()
+		return <-exited
 	}
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
 
-	return string(output), nil
+// commandsSearchDir is where discovery of the project commands file starts:
+// the workspace root, or for remote workspaces the local current directory
+func commandsSearchDir(toolCtx *tools.ToolContext) string {
+	if ws := workspaceOf(toolCtx); ws != nil && ws.Host() == "" {
+		return ws.Root()
+	}
+	return "."
+}
+
+// maxOutputBytes returns the output cap for a command: its own setting, then the file default
+func maxOutputBytes(config *config.CommandsConfig, spec config.CommandSpec) int {
+	if spec.MaxOutputBytes != 0 {
+		return spec.MaxOutputBytes
+	}
+	if config.MaxOutputBytes != 0 {
+		return config.MaxOutputBytes
+	}
+	return defaultMaxOutputBytes
 }
 
 func (t CommandTool) getCommandNames(config *config.CommandsConfig) string {
+	return strings.Join(t.sortedCommandNames(config), ", ")
+}
+
+func (t CommandTool) sortedCommandNames(config *config.CommandsConfig) []string {
 	var names []string
 	for name := range config.Commands {
 		names = append(names, name)
 	}
-	return strings.Join(names, ", ")
+	sort.Strings(names)
+	return names
 }
 
 func init() {
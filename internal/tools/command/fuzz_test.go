@@ -0,0 +1,40 @@
+package command
+
+import (
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// Execute would run whatever commands the user's global config defines, so
+// execute_command is fuzzed up to the point it looks them up
+func FuzzCommandInput(f *testing.F) {
+	toolstest.Seed(f, CommandTool{}, `{"name": "test", "args": {"package": "./...; rm -rf /"}}`, `{"name": ""}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		var commandInput CommandInput
+		if toolstest.Decode(t, CommandTool{}, input, &commandInput) == nil {
+			commandInput.Validate()
+		}
+	})
+}
+
+func FuzzCommandStatus(f *testing.F) {
+	toolstest.Seed(f, CommandStatusTool{}, `{"handle": "bg-0"}`, `{"handle": "pid 1"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, CommandStatusTool{}, nil, input)
+	})
+}
+
+func FuzzCommandOutput(f *testing.F) {
+	toolstest.Seed(f, CommandOutputTool{}, `{"handle": "bg-1"}`, `{"handle": ""}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, CommandOutputTool{}, nil, input)
+	})
+}
+
+func FuzzStopCommand(f *testing.F) {
+	toolstest.Seed(f, StopCommandTool{}, `{"handle": "bg-1"}`, `{"handle": "bg-"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, StopCommandTool{}, nil, input)
+	})
+}
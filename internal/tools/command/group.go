@@ -0,0 +1,111 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/tools"
+)
+
+// Error message constants for command groups
+const errMsgGroupArgs = "command group %q does not take arguments; its members run with their defaults"
+
+// GroupResult is the outcome of running a command group: one result per
+// member, in the order the group lists them
+type GroupResult struct {
+	Group   string           `json:"group"`
+	Passed  bool             `json:"passed"`
+	Failed  []string         `json:"failed,omitempty"`
+	Results []*CommandResult `json:"results"`
+}
+
+// String returns the result as indented JSON
+func (r *GroupResult) String() string {
+	jsonResult, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error formatting results: %v", err)
+	}
+	return string(jsonResult)
+}
+
+// executeGroup confirms every member of a group at once, runs them in
+// parallel and aggregates their results. Live output is prefixed with the
+// member's name so interleaved lines stay readable.
+func (t CommandTool) executeGroup(ctx context.Context, toolCtx *tools.ToolContext, config *config.CommandsConfig, groupName string, members []string, args map[string]any) (*tools.ToolResult, error) {
+	if len(args) > 0 {
+		return nil, tools.InvalidInput(fmt.Errorf(errMsgGroupArgs, groupName))
+	}
+
+	commands := make([]*preparedCommand, 0, len(members))
+	previews := make([]string, 0, len(members))
+	force := false
+	for _, member := range members {
+		command, err := t.prepareCommand(toolCtx, config, member, nil)
+		if err != nil {
+			return nil, fmt.Errorf("command group %q: %w", groupName, err)
+		}
+		commands = append(commands, command)
+		previews = append(previews, fmt.Sprintf("[%s]\n%s", member, command.preview()))
+		force = force || command.spec.RequiresConfirmation()
+	}
+
+	approved := toolCtx.Confirm(confirm.Request{
+		Tool:    "execute_command",
+		Action:  fmt.Sprintf("run the %q command group (%s)", groupName, strings.Join(members, ", ")),
+		Preview: strings.Join(previews, "\n"),
+		Force:   force,
+	})
+	if !approved {
+		return tools.NewTextResult("Command execution cancelled by user"), nil
+	}
+
+	var display io.Writer
+	if toolCtx != nil && toolCtx.Output != nil {
+		display = newLockedWriter(toolCtx.Output)
+	}
+
+	result := &GroupResult{Group: groupName, Passed: true, Results: make([]*CommandResult, len(commands))}
+	var wg sync.WaitGroup
+	for i, command := range commands {
+		wg.Add(1)
+		go func(i int, command *preparedCommand) {
+			defer wg.Done()
+			var progress *prefixWriter
+			if display != nil {
+				progress = newPrefixWriter(display, "["+command.name+"] ")
+				defer progress.Flush()
+			}
+			result.Results[i] = command.run(ctx, writerOrNil(progress))
+		}(i, command)
+	}
+	wg.Wait()
+
+	for _, member := range result.Results {
+		if member.Failed() {
+			result.Passed = false
+			result.Failed = append(result.Failed, member.Command)
+		}
+	}
+	lines := make([]string, len(commands))
+	for i, command := range commands {
+		lines[i] = command.display
+	}
+	if !result.Passed {
+		return tools.NewErrorResult(result.String()).WithCommands(lines...), nil
+	}
+	return tools.NewTextResult(result.String()).WithCommands(lines...), nil
+}
+
+// writerOrNil avoids handing os/exec a non-nil interface holding a nil pointer
+func writerOrNil(w *prefixWriter) io.Writer {
+	if w == nil {
+		return nil
+	}
+	return w
+}
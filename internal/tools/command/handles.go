@@ -0,0 +1,143 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/internal/schema"
+	"agent/internal/tools"
+)
+
+// HandleInput identifies a background command
+type HandleInput struct {
+	Handle string `json:"handle" jsonschema_description:"Handle returned when the background command was started, e.g. \"bg-1\""`
+}
+
+func parseHandleInput(input json.RawMessage, required bool) (*HandleInput, error) {
+	var handleInput HandleInput
+	if err := tools.DecodeInput(input, &handleInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if required && handleInput.Handle == "" {
+		return nil, tools.InvalidInput(fmt.Errorf(errMsgMissingParam, "handle"))
+	}
+	return &handleInput, nil
+}
+
+type CommandStatusTool struct{}
+
+func (t CommandStatusTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:  "command_status",
+		Group: "command",
+		Description: `Check on commands started in the background (those configured with background: true).
+
+Usage Examples:
+- {"handle": "bg-1"} // Is it still running? How did it exit?
+- {} // Status of every background command in this session`,
+		InputSchema: schema.GenerateSchema[HandleInput](),
+	}
+}
+
+func (t CommandStatusTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	handleInput, err := parseHandleInput(input, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if handleInput.Handle != "" {
+		process, err := background.lookup(handleInput.Handle)
+		if err != nil {
+			return nil, err
+		}
+		return tools.NewTextResult(process.status()), nil
+	}
+
+	processes := background.list()
+	if len(processes) == 0 {
+		return tools.NewTextResult("No background commands have been started"), nil
+	}
+	var result strings.Builder
+	for _, process := range processes {
+		result.WriteString(process.status() + "\n")
+	}
+	return tools.NewTextResult(result.String()), nil
+}
+
+type CommandOutputTool struct{}
+
+func (t CommandOutputTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:  "command_output",
+		Group: "command",
+		Description: `Read output a background command has produced since the last read.
+
+Each call returns only new output, so call it repeatedly to follow a dev server or watcher.
+Usage: {"handle": "bg-1"}`,
+		InputSchema: schema.GenerateSchema[HandleInput](),
+	}
+}
+
+func (t CommandOutputTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	handleInput, err := parseHandleInput(input, true)
+	if err != nil {
+		return nil, err
+	}
+	process, err := background.lookup(handleInput.Handle)
+	if err != nil {
+		return nil, err
+	}
+
+	data, lost := process.readNew()
+	var result strings.Builder
+	if lost > 0 {
+		fmt.Fprintf(&result, "... [%d bytes of earlier output were discarded before being read] ...\n", lost)
+	}
+	if len(data) == 0 {
+		result.WriteString("(no new output)\n")
+	} else {
+		result.WriteString(truncateOutput(data, process.maxOutput))
+		if !strings.HasSuffix(result.String(), "\n") {
+			result.WriteString("\n")
+		}
+	}
+	result.WriteString(process.status())
+	return tools.NewTextResult(result.String()), nil
+}
+
+type StopCommandTool struct{}
+
+func (t StopCommandTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "stop_command",
+		Group:    "command",
+		Mutating: true,
+		Description: `Stop a background command and any processes it started.
+
+The process is asked to exit and killed if it is still running after a few seconds.
+Usage: {"handle": "bg-1"}`,
+		InputSchema: schema.GenerateSchema[HandleInput](),
+	}
+}
+
+func (t StopCommandTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	handleInput, err := parseHandleInput(input, true)
+	if err != nil {
+		return nil, err
+	}
+	process, err := background.lookup(handleInput.Handle)
+	if err != nil {
+		return nil, err
+	}
+
+	process.stop()
+	return tools.NewTextResult(process.status()), nil
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(CommandStatusTool{})
+	tools.DefaultRegistry.RegisterTool(CommandOutputTool{})
+	tools.DefaultRegistry.RegisterTool(StopCommandTool{})
+}
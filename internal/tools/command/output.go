@@ -0,0 +1,129 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// defaultMaxOutputBytes caps command output returned to the model when the
+// config does not set max_output_bytes
+const defaultMaxOutputBytes = 30000
+
+// headShare is the fraction of the output budget kept from the start; the
+// rest goes to the tail, where compilers and test runners report failures
+const headShare = 0.25
+
+// outputWriter collects one of a command's output streams while also
+// copying it to a live display. The mutex keeps Bytes safe to call while the
+// command is still running.
+type outputWriter struct {
+	mutex    sync.Mutex
+	buffer   bytes.Buffer
+	progress io.Writer
+}
+
+func newOutputWriter(progress io.Writer) *outputWriter {
+	return &outputWriter{progress: progress}
+}
+
+// Write implements io.Writer. Display errors are ignored so a closed
+// terminal never fails the command.
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buffer.Write(p)
+	if w.progress != nil {
+		w.progress.Write(p)
+	}
+	return len(p), nil
+}
+
+// Bytes returns everything written so far
+func (w *outputWriter) Bytes() []byte {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return append([]byte(nil), w.buffer.Bytes()...)
+}
+
+// lockedWriter serializes writes to a display shared by stdout and stderr
+type lockedWriter struct {
+	mutex sync.Mutex
+	out   io.Writer
+}
+
+func newLockedWriter(out io.Writer) *lockedWriter {
+	return &lockedWriter{out: out}
+}
+
+// Write implements io.Writer
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.out.Write(p)
+}
+
+// prefixWriter copies complete lines to out with a prefix, holding back a
+// partial line until it is finished or Flush is called
+type prefixWriter struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	prefix  string
+	pending []byte
+}
+
+func newPrefixWriter(out io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{out: out, prefix: prefix}
+}
+
+// Write implements io.Writer
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.out.Write(append([]byte(w.prefix), w.pending[:i+1]...))
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any unfinished last line
+func (w *prefixWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.pending) > 0 {
+		w.out.Write(append([]byte(w.prefix), append(w.pending, '\n')...))
+		w.pending = nil
+	}
+}
+
+// truncateOutput keeps the head and tail of output within maxBytes, cutting
+// at line boundaries and marking what was left out. maxBytes <= 0 disables
+// truncation.
+func truncateOutput(output []byte, maxBytes int) string {
+	if maxBytes <= 0 || len(output) <= maxBytes {
+		return string(output)
+	}
+
+	headBudget := int(float64(maxBytes) * headShare)
+	tailBudget := maxBytes - headBudget
+
+	head := output[:headBudget]
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	tail := output[len(output)-tailBudget:]
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	omitted := output[len(head) : len(output)-len(tail)]
+	marker := fmt.Sprintf("\n... [%d bytes, %d lines of output omitted] ...\n\n", len(omitted), bytes.Count(omitted, []byte("\n")))
+	return string(head) + marker + string(tail)
+}
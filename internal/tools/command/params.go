@@ -0,0 +1,266 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/tools"
+)
+
+// Error message constants for command parameters
+const (
+	errMsgUnknownArg       = "unknown argument %q for command %q (parameters: %s)"
+	errMsgMissingArg       = "command %q requires argument %q"
+	errMsgInvalidArg       = "argument %q for command %q %s"
+	errMsgUndeclaredParam  = "command %q uses placeholder {{%s}} but declares no parameter with that name"
+	errMsgInvalidParamSpec = "command %q parameter %q: %s"
+)
+
+// placeholderPattern matches {{name}} in command templates
+var placeholderPattern = config.PlaceholderPattern
+
+// expandCommand turns a command template and the model's arguments into an
+// argv, plus the command line to show the user.
+//
+// By default the template is split into words (honoring quotes) before
+// substitution and no shell is involved, so an argument always stays inside
+// the word it was placed in and cannot inject extra arguments or shell
+// syntax. A word that consists only of a placeholder whose value is empty
+// (an omitted optional parameter or a false boolean flag) is dropped.
+//
+// With shell: true the template is run by the system shell, and every
+// substituted value is quoted for that shell first. Placeholders inside
+// quotes are refused, because there the quoting would not hold.
+func expandCommand(name string, spec config.CommandSpec, args map[string]any) (argv []string, display string, err error) {
+	declared := make(map[string]config.CommandParameter, len(spec.Parameters))
+	for _, param := range spec.Parameters {
+		declared[param.Name] = param
+	}
+
+	for arg := range args {
+		if _, ok := declared[arg]; !ok {
+			return nil, "", tools.InvalidInput(fmt.Errorf(errMsgUnknownArg, arg, name, parameterNames(spec)))
+		}
+	}
+
+	values := make(map[string]string, len(spec.Parameters))
+	for _, param := range spec.Parameters {
+		value, err := argumentValue(name, param, args)
+		if err != nil {
+			return nil, "", err
+		}
+		values[param.Name] = value
+	}
+
+	if spec.Shell {
+		shell := shellProgram(spec)
+		// Loading rejects these too, for commands that were not loaded from a file
+		if param := config.QuotedPlaceholder(spec.Command, shell); param != "" {
+			return nil, "", fmt.Errorf("command %q: placeholder {{%s}} is inside quotes, where the shell would interpret its value", name, param)
+		}
+		script, err := substitute(name, spec.Command, values, shellQuoter(shell))
+		if err != nil {
+			return nil, "", err
+		}
+		return shellCommand(shell, script), script, nil
+	}
+
+	words, err := splitWords(spec.Command)
+	if err != nil {
+		return nil, "", fmt.Errorf("command %q: %w", name, err)
+	}
+	for _, word := range words {
+		expanded, err := substitute(name, word, values, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		if expanded == "" && placeholderPattern.MatchString(word) {
+			continue
+		}
+		argv = append(argv, expanded)
+	}
+	return argv, quoteCommand(argv), nil
+}
+
+// substitute replaces every placeholder in text with its value, passing
+// non-empty values through quote when one is given
+func substitute(name, text string, values map[string]string, quote func(string) (string, error)) (string, error) {
+	var failure error
+	expanded := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		param := placeholderPattern.FindStringSubmatch(match)[1]
+		value, ok := values[param]
+		if !ok {
+			failure = fmt.Errorf(errMsgUndeclaredParam, name, param)
+			return ""
+		}
+		if quote == nil || value == "" {
+			return value
+		}
+		quoted, err := quote(value)
+		if err != nil {
+			failure = tools.InvalidInput(fmt.Errorf(errMsgInvalidArg, param, name, err.Error()))
+		}
+		return quoted
+	})
+	return expanded, failure
+}
+
+// argumentValue validates one argument (or its default) and formats it as a command word
+func argumentValue(name string, param config.CommandParameter, args map[string]any) (string, error) {
+	raw, supplied := args[param.Name]
+	if !supplied || raw == nil {
+		if param.Default != "" {
+			raw = param.Default
+		} else if param.Required {
+			return "", tools.InvalidInput(fmt.Errorf(errMsgMissingArg, name, param.Name))
+		} else {
+			return "", nil
+		}
+	}
+
+	invalid := func(reason string) error {
+		return tools.InvalidInput(fmt.Errorf(errMsgInvalidArg, param.Name, name, reason))
+	}
+
+	var value string
+	switch param.Type {
+	case "", "string":
+		s, ok := raw.(string)
+		if !ok {
+			return "", invalid("must be a string")
+		}
+		if strings.ContainsAny(s, "\x00\n\r") {
+			return "", invalid("must not contain newlines or NUL bytes")
+		}
+		// Without an explicit pattern, refuse values that would be parsed as options
+		if param.Pattern == "" && len(param.Enum) == 0 && strings.HasPrefix(s, "-") {
+			return "", invalid("must not start with '-'")
+		}
+		value = s
+	case "integer":
+		n, err := toNumber(raw)
+		if err != nil || n != float64(int64(n)) {
+			return "", invalid("must be an integer")
+		}
+		value = strconv.FormatInt(int64(n), 10)
+	case "number":
+		n, err := toNumber(raw)
+		if err != nil {
+			return "", invalid("must be a number")
+		}
+		value = strconv.FormatFloat(n, 'f', -1, 64)
+	case "boolean":
+		b, err := toBool(raw)
+		if err != nil {
+			return "", invalid("must be a boolean")
+		}
+		if param.Flag != "" {
+			if b {
+				return param.Flag, nil
+			}
+			return "", nil
+		}
+		value = strconv.FormatBool(b)
+	default:
+		return "", fmt.Errorf(errMsgInvalidParamSpec, name, param.Name, fmt.Sprintf("unknown type %q", param.Type))
+	}
+
+	if len(param.Enum) > 0 && !contains(param.Enum, value) {
+		return "", invalid("must be one of " + strings.Join(param.Enum, ", "))
+	}
+	if param.Pattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + param.Pattern + `)$`)
+		if err != nil {
+			return "", fmt.Errorf(errMsgInvalidParamSpec, name, param.Name, "invalid pattern: "+err.Error())
+		}
+		if !pattern.MatchString(value) {
+			return "", invalid(fmt.Sprintf("must match %s", param.Pattern))
+		}
+	}
+	return value, nil
+}
+
+func toNumber(raw any) (float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number")
+}
+
+func toBool(raw any) (bool, error) {
+	switch v := raw.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("not a boolean")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parameterNames(spec config.CommandSpec) string {
+	if len(spec.Parameters) == 0 {
+		return "none"
+	}
+	names := make([]string, len(spec.Parameters))
+	for i, param := range spec.Parameters {
+		names[i] = param.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// describeParameters renders a command's parameters for the command listing
+func describeParameters(spec config.CommandSpec) string {
+	var b strings.Builder
+	for _, param := range spec.Parameters {
+		paramType := param.Type
+		if paramType == "" {
+			paramType = "string"
+		}
+		fmt.Fprintf(&b, "    - %s (%s", param.Name, paramType)
+		if param.Required {
+			b.WriteString(", required")
+		}
+		if param.Default != "" {
+			fmt.Fprintf(&b, ", default %q", param.Default)
+		}
+		if len(param.Enum) > 0 {
+			fmt.Fprintf(&b, ", one of %s", strings.Join(param.Enum, "|"))
+		}
+		b.WriteString(")")
+		if param.Description != "" {
+			b.WriteString(": " + param.Description)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// quoteCommand renders an argv for display, quoting words that need it
+func quoteCommand(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " \t'\"\\$`*?[]{}()<>|&;#~!") {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		} else {
+			quoted[i] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
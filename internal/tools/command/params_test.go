@@ -0,0 +1,36 @@
+package command
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"agent/internal/config"
+)
+
+func TestExpandCommandShellQuoting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	args := map[string]any{"msg": "$(echo injected)"}
+	params := []config.CommandParameter{{Name: "msg"}}
+
+	quoted := config.CommandSpec{Command: `echo "{{msg}}"`, Shell: true, Parameters: params}
+	if argv, _, err := expandCommand("say", quoted, args); err == nil {
+		t.Fatalf("expandCommand accepted a placeholder inside double quotes: %q", argv)
+	}
+
+	plain := config.CommandSpec{Command: `echo {{msg}}`, Shell: true, Parameters: params}
+	argv, _, err := expandCommand("say", plain, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(argv[0], argv[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "$(echo injected)" {
+		t.Errorf("the shell printed %q, want the argument unexpanded", got)
+	}
+}
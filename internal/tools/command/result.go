@@ -0,0 +1,80 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"agent/internal/workspace"
+)
+
+// CommandResult is the structured outcome of a command run, returned to the
+// model as JSON so it can branch on the exit code instead of parsing output
+type CommandResult struct {
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"` // -1 when the process did not exit normally
+	DurationMS int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out"`
+	Cancelled  bool   `json:"cancelled,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"` // Output was cut to max_output_bytes
+	Error      string `json:"error,omitempty"`     // Why the process could not run or was killed
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+}
+
+// Failed reports whether the command should be treated as an error result
+func (r *CommandResult) Failed() bool {
+	return r.ExitCode != 0 || r.TimedOut || r.Cancelled || r.Error != ""
+}
+
+// String returns the result as indented JSON
+func (r *CommandResult) String() string {
+	jsonResult, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error formatting results: %v", err)
+	}
+	return string(jsonResult)
+}
+
+// newCommandResult builds the result of a finished run, cutting stdout and
+// stderr to share the output budget
+func newCommandResult(commandName string, runErr error, duration time.Duration, stdout, stderr []byte, maxBytes int) *CommandResult {
+	result := &CommandResult{Command: commandName, DurationMS: duration.Milliseconds()}
+
+	var exitErr workspace.ExitCoder
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		if result.ExitCode == -1 {
+			result.Error = runErr.Error() // Killed by a signal
+		}
+	default:
+		result.ExitCode = -1
+		result.Error = runErr.Error()
+	}
+
+	stdoutBudget, stderrBudget := splitBudget(len(stdout), len(stderr), maxBytes)
+	result.Stdout = truncateOutput(stdout, stdoutBudget)
+	result.Stderr = truncateOutput(stderr, stderrBudget)
+	result.Truncated = len(result.Stdout) != len(stdout) || len(result.Stderr) != len(stderr)
+	return result
+}
+
+// splitBudget divides maxBytes between two streams: each gets half, and a
+// stream that needs less than its half passes the rest to the other.
+// maxBytes <= 0 means no limit.
+func splitBudget(first, second, maxBytes int) (int, int) {
+	if maxBytes <= 0 || first+second <= maxBytes {
+		return 0, 0
+	}
+	half := maxBytes / 2
+	switch {
+	case first < half:
+		return first, maxBytes - first
+	case second < half:
+		return maxBytes - second, second
+	}
+	return half, maxBytes - half
+}
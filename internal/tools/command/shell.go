@@ -0,0 +1,133 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent/internal/config"
+)
+
+// Shells commands with shell: true can run through
+const (
+	shellCmd        = "cmd"
+	shellPowerShell = "powershell"
+	shellPwsh       = "pwsh" // PowerShell 7
+)
+
+// shellProgram is the shell a command runs through: its shell_program,
+// or the system's
+func shellProgram(spec config.CommandSpec) string {
+	if spec.ShellProgram != "" {
+		return spec.ShellProgram
+	}
+	return config.DefaultShell
+}
+
+// shellCommand returns the argv that runs script with shell
+func shellCommand(shell, script string) []string {
+	switch shell {
+	case shellCmd:
+		return []string{"cmd", "/C", script}
+	case shellPowerShell, shellPwsh:
+		return []string{shell, "-NoProfile", "-NonInteractive", "-Command", script}
+	}
+	return []string{shell, "-c", script}
+}
+
+// shellQuoter returns the function that quotes a value so shell passes it
+// through as a single literal argument
+func shellQuoter(shell string) func(string) (string, error) {
+	switch shell {
+	case shellCmd:
+		return func(value string) (string, error) {
+			// cmd.exe has no quoting that disables every metacharacter, so refuse the risky ones
+			if strings.ContainsAny(value, `"%^!&|<>`) {
+				return "", errors.New(`must not contain any of " % ^ ! & | < > in cmd shell commands`)
+			}
+			return `"` + value + `"`, nil
+		}
+	case shellPowerShell, shellPwsh:
+		return func(value string) (string, error) {
+			// PowerShell also ends single-quoted strings at typographic single quotes
+			if strings.ContainsAny(value, "\u2018\u2019\u201A\u201B") {
+				return "", errors.New("must not contain typographic single quotes in PowerShell commands")
+			}
+			return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+		}
+	}
+	return func(value string) (string, error) {
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+	}
+}
+
+// splitWords splits a command template into words the way a POSIX shell
+// would, without performing any expansion: whitespace separates words,
+// single quotes preserve everything literally, double quotes allow \" \\ \$
+// and \` escapes, and a backslash outside quotes escapes the next character.
+func splitWords(command string) ([]string, error) {
+	const (
+		unquoted = iota
+		single
+		double
+	)
+
+	var words []string
+	var word strings.Builder
+	inWord := false
+	state := unquoted
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch state {
+		case single:
+			if r == '\'' {
+				state = unquoted
+			} else {
+				word.WriteRune(r)
+			}
+		case double:
+			switch {
+			case r == '"':
+				state = unquoted
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(r)
+			}
+		default:
+			switch r {
+			case '\'':
+				state, inWord = single, true
+			case '"':
+				state, inWord = double, true
+			case '\\':
+				if i+1 >= len(runes) {
+					return nil, errors.New("command ends with an unfinished escape")
+				}
+				i++
+				word.WriteRune(runes[i])
+				inWord = true
+			case ' ', '\t', '\n':
+				if inWord {
+					words = append(words, word.String())
+					word.Reset()
+					inWord = false
+				}
+			default:
+				word.WriteRune(r)
+				inWord = true
+			}
+		}
+	}
+
+	if state != unquoted {
+		return nil, fmt.Errorf("unterminated quote in %q (set shell: true to use shell syntax)", command)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
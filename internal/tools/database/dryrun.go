@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/tools"
+)
+
+// stepMarker starts the line a dry run prints before each step, so the
+// last one printed names the step that failed
+const stepMarker = "== billdozer step: "
+
+// dryRunStep is one migration applied in one direction
+type dryRunStep struct {
+	label string // e.g. 000003_add_index.up.sql, or 20240101120000_add_index.sql (down)
+	up    bool
+	sql   string
+}
+
+// dryRun applies every migration up in version order and then down in
+// reverse order on the scratch database. PostgreSQL and SQLite run it all
+// in one transaction that is rolled back; MySQL commits schema changes as
+// it goes, so there the down migrations are what restores the database.
+func (s *migrationSet) dryRun(ctx context.Context, toolCtx *tools.ToolContext) (*tools.ToolResult, error) {
+	if migrationSettings.Scratch == "" {
+		return nil, tools.NotFound(errors.New("no scratch database is configured; set migrations.scratch to the name of a database in databases that dry runs may change"))
+	}
+	database, err := findDatabase(migrationSettings.Scratch)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.migrations) == 0 {
+		return tools.NewTextResult(fmt.Sprintf("No migrations in %s to dry-run", s.shown)), nil
+	}
+
+	steps, skipped, err := s.steps(toolCtx)
+	if err != nil {
+		return nil, err
+	}
+	ws := workspaceOf(toolCtx)
+	root := ""
+	if ws != nil {
+		root = ws.Root()
+	}
+	argv, err := dryRunCommand(database, root, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := run(ctx, ws.Runner(), argv, nil)
+	command := fmt.Sprintf("%s (dry run of %s on database %s)", argv[0], s.shown, database.Name)
+	if err != nil {
+		if failed := lastStep(stdout); failed != "" {
+			text := fmt.Sprintf("Dry run failed at %s on database %s:\n%s", failed, database.Name, strings.TrimSpace(stderr))
+			if database.Driver == config.DriverMySQL {
+				text += "\n\nMySQL commits schema changes as they run: the migrations before it were applied and stay applied."
+			}
+			return tools.NewErrorResult(text).WithCommands(command), nil
+		}
+		return nil, clientError(argv[0], err, stderr)
+	}
+
+	applied := 0
+	for _, step := range steps {
+		if step.up {
+			applied++
+		}
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "Dry run on database %s (%s) passed: %d migrations applied up, then down in reverse order.", database.Name, database.Driver, applied)
+	if database.Driver == config.DriverMySQL {
+		text.WriteString(" MySQL commits schema changes, so the database holds what the down migrations left.")
+	} else {
+		text.WriteString(" Everything ran in one transaction, which was rolled back.")
+	}
+	for _, note := range skipped {
+		fmt.Fprintf(&text, "\nNot run: %s", note)
+	}
+	return tools.NewTextResult(text.String()).WithCommands(command), nil
+}
+
+// steps reads the SQL of every migration: the up steps in version order
+// followed by the down steps in reverse. Migrations that cannot be run as
+// SQL are left out and described in skipped.
+func (s *migrationSet) steps(toolCtx *tools.ToolContext) (steps []dryRunStep, skipped []string, err error) {
+	fsys := workspaceOf(toolCtx).FS()
+	read := func(shown string) (string, error) {
+		data, err := fsys.ReadFile(filepath.Join(s.dir, filepath.Base(shown)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", shown, err)
+		}
+		return string(data), nil
+	}
+
+	var downs []dryRunStep
+	for _, m := range s.migrations {
+		switch {
+		case m.goCode:
+			skipped = append(skipped, m.label()+" is written in Go")
+			continue
+		case m.upFile == "":
+			skipped = append(skipped, m.label()+" has no up file")
+			continue
+		}
+		up, err := read(m.upFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		down := ""
+		if m.downFile != "" && m.downFile != m.upFile {
+			if down, err = read(m.downFile); err != nil {
+				return nil, nil, err
+			}
+		}
+		if s.layout == config.LayoutGoose {
+			up, down = gooseSections(up)
+		}
+		steps = append(steps, dryRunStep{label: s.stepLabel(m.upFile, "up"), up: true, sql: withoutTransactions(up)})
+		if strings.TrimSpace(down) == "" {
+			skipped = append(skipped, m.label()+" has no down migration")
+			continue
+		}
+		downs = append(downs, dryRunStep{label: s.stepLabel(m.downFile, "down"), sql: withoutTransactions(down)})
+	}
+	for i := len(downs) - 1; i >= 0; i-- {
+		steps = append(steps, downs[i])
+	}
+	return steps, skipped, nil
+}
+
+// stepLabel names a step by its file, and for goose files, whose one file
+// holds both, by its direction too
+func (s *migrationSet) stepLabel(file, direction string) string {
+	if s.layout == config.LayoutGoose {
+		return fmt.Sprintf("%s (%s)", filepath.Base(file), direction)
+	}
+	return filepath.Base(file)
+}
+
+// gooseSections splits a goose file into its up and down SQL
+func gooseSections(content string) (up, down string) {
+	sections := gooseSection.FindAllStringSubmatchIndex(content, -1)
+	for i, section := range sections {
+		end := len(content)
+		if i+1 < len(sections) {
+			end = sections[i+1][0]
+		}
+		sql := content[section[1]:end]
+		if strings.EqualFold(content[section[2]:section[3]], "up") {
+			up += sql
+		} else {
+			down += sql
+		}
+	}
+	return up, down
+}
+
+// withoutTransactions drops the statements that start and end
+// transactions, which would commit the dry run's own. Only whole
+// statements go: the END of a trigger body, or a COMMIT in a function's
+// dollar-quoted body, is part of a larger statement and stays.
+func withoutTransactions(sql string) string {
+	var out strings.Builder
+	scanner := statementScanner{src: sql}
+	dropped := false
+	for {
+		gap, statement := scanner.next()
+		if dropped {
+			// The rest of a dropped statement's line goes with it
+			gap = strings.TrimLeft(gap, " \t")
+			gap = strings.TrimPrefix(strings.TrimPrefix(gap, "\r"), "\n")
+		}
+		dropped = statement != "" && transactionStatement.MatchString(strings.TrimSpace(statement))
+		if dropped {
+			out.WriteString(strings.TrimRight(gap, " \t"))
+			continue
+		}
+		out.WriteString(gap)
+		if statement == "" {
+			return out.String()
+		}
+		out.WriteString(statement)
+	}
+}
+
+// dryRunCommand returns the command line that runs the steps on a
+// database, printing a marker before each
+func dryRunCommand(database config.DatabaseConfig, root string, steps []dryRunStep) ([]string, error) {
+	var options []string
+	if database.Driver == config.DriverMySQL {
+		options = []string{"--skip-column-names"}
+	}
+	argv, err := connect(database, root, options...)
+	if err != nil {
+		return nil, err
+	}
+	switch database.Driver {
+	case config.DriverPostgres:
+		// Each --command is sent on its own, all in one session
+		argv = append(argv, "--command=BEGIN")
+		for _, step := range steps {
+			argv = append(argv, `--command=\echo `+stepMarker+step.label, "--command="+step.sql)
+		}
+		return append(argv, "--command=ROLLBACK"), nil
+	case config.DriverMySQL:
+		// One script, as mysql runs only one --execute; an empty statement
+		// would be an error, so a step only gets a terminator it lacks
+		var script strings.Builder
+		for _, step := range steps {
+			fmt.Fprintf(&script, "SELECT '%s%s';\n", stepMarker, strings.ReplaceAll(step.label, "'", "''"))
+			if sql := strings.TrimSpace(step.sql); sql != "" {
+				script.WriteString(sql)
+				if !strings.HasSuffix(sql, ";") {
+					script.WriteString(";")
+				}
+				script.WriteString("\n")
+			}
+		}
+		return append(argv, "--execute="+script.String()), nil
+	default:
+		// Arguments after the database file are run in order, SQL and dot
+		// commands alike
+		argv = append(argv, "BEGIN")
+		for _, step := range steps {
+			argv = append(argv, ".print "+stepMarker+step.label, step.sql)
+		}
+		return append(argv, "ROLLBACK"), nil
+	}
+}
+
+// lastStep returns the label of the last step a dry run started
+func lastStep(output string) string {
+	i := strings.LastIndex(output, stepMarker)
+	if i < 0 {
+		return ""
+	}
+	label, _, _ := strings.Cut(output[i+len(stepMarker):], "\n")
+	return strings.TrimSpace(label)
+}
@@ -0,0 +1,51 @@
+package database
+
+import "testing"
+
+func TestWithoutTransactions(t *testing.T) {
+	tests := []struct {
+		name, sql, want string
+	}{
+		{
+			name: "transaction",
+			sql:  "BEGIN;\nCREATE TABLE users (id integer);\nCOMMIT;\n",
+			want: "CREATE TABLE users (id integer);\n",
+		},
+		{
+			name: "one line",
+			sql:  "START TRANSACTION; DROP TABLE users; commit work;",
+			want: "DROP TABLE users;",
+		},
+		{
+			name: "sqlite trigger",
+			sql: "BEGIN TRANSACTION;\nCREATE TRIGGER touch AFTER UPDATE ON users\nBEGIN\n  UPDATE users SET flag = CASE WHEN new.id > 0 THEN 1 ELSE 0 END;\n" +
+				"  UPDATE users SET updated_at = now() WHERE id = new.id;\nEND;\nEND TRANSACTION;\n",
+			want: "CREATE TRIGGER touch AFTER UPDATE ON users\nBEGIN\n  UPDATE users SET flag = CASE WHEN new.id > 0 THEN 1 ELSE 0 END;\n" +
+				"  UPDATE users SET updated_at = now() WHERE id = new.id;\nEND;\n",
+		},
+		{
+			name: "plpgsql function",
+			sql: "CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql;\n" +
+				"DO $$\nBEGIN\n  COMMIT;\nEND;\n$$;\n",
+			want: "CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql;\n" +
+				"DO $$\nBEGIN\n  COMMIT;\nEND;\n$$;\n",
+		},
+		{
+			name: "mysql procedure",
+			sql:  "CREATE PROCEDURE tidy()\nBEGIN\n  IF (SELECT COUNT(*) FROM users) > 0 THEN\n    DELETE FROM users;\n  END IF;\n  COMMIT;\nEND;\nCOMMIT;\n",
+			want: "CREATE PROCEDURE tidy()\nBEGIN\n  IF (SELECT COUNT(*) FROM users) > 0 THEN\n    DELETE FROM users;\n  END IF;\n  COMMIT;\nEND;\n",
+		},
+		{
+			name: "quoted and commented",
+			sql:  "-- BEGIN;\nINSERT INTO notes VALUES ('it''s;\nEND;');\n/* COMMIT; */\n",
+			want: "-- BEGIN;\nINSERT INTO notes VALUES ('it''s;\nEND;');\n/* COMMIT; */\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withoutTransactions(tt.sql); got != tt.want {
+				t.Errorf("withoutTransactions(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// migrationFiles is the workspace the database tools are fuzzed in
+var migrationFiles = map[string]string{
+	"migrations/000001_create_users.up.sql":   "CREATE TABLE users (id integer PRIMARY KEY);\n",
+	"migrations/000001_create_users.down.sql": "DROP TABLE users;\n",
+}
+
+// No database is configured, so Execute fails before it would run a client
+func FuzzSchema(f *testing.F) {
+	toolstest.Seed(f, SchemaTool{}, `{"database": "-h evil"}`, `{"table": "users; DROP TABLE users"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, SchemaTool{}, toolstest.Context(t, migrationFiles), input)
+	})
+}
+
+// dry_run runs a database client, so it is fuzzed only up to Validate
+func FuzzMigrations(f *testing.F) {
+	toolstest.Seed(f, MigrationsTool{}, `{"action": "create", "name": "../../escape", "up": "x", "down": "y"}`,
+		`{"action": "list", "dir": "../"}`, `{"action": "create", "name": "", "up": ""}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		var migrationsInput MigrationsInput
+		if toolstest.Decode(t, MigrationsTool{}, input, &migrationsInput) == nil && migrationsInput.Validate() == nil && migrationsInput.Action == actionDryRun {
+			return
+		}
+		toolstest.Execute(t, MigrationsTool{}, toolstest.Context(t, migrationFiles), input)
+	})
+}
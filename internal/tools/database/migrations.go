@@ -0,0 +1,442 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Actions of the migrations tool
+const (
+	actionList   = "list"
+	actionCreate = "create"
+	actionDryRun = "dry_run"
+)
+
+// migrationDirs are where migrations are looked for when migrations.dir is
+// not set
+var migrationDirs = []string{"migrations", "db/migrations", "database/migrations", "sql/migrations", "internal/db/migrations"}
+
+var (
+	// migrateFile matches golang-migrate files: 000001_create_users.up.sql
+	migrateFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	// gooseFile matches goose files: 20240101120000_create_users.sql, or .go
+	// for migrations written in Go
+	gooseFile = regexp.MustCompile(`^(\d+)_(.+)\.(sql|go)$`)
+	// gooseSection matches the annotations that start a goose file's up and
+	// down migrations
+	gooseSection = regexp.MustCompile(`(?im)^--\s*\+goose\s+(up|down)\b.*$`)
+	// transactionStatement matches a statement that starts or ends a
+	// transaction, which dry runs drop so everything runs in theirs
+	transactionStatement = regexp.MustCompile(`(?is)^(begin|start\s+transaction|commit|end)(\s+(transaction|work))?\s*;?$`)
+	// invalidName matches what a migration name cannot hold
+	invalidName = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// timestampVersion is the layout of time-based versions, which both tools
+// create by default
+const timestampVersion = "20060102150405"
+
+// migrationSettings is the migrations config in effect; see ConfigureMigrations
+var migrationSettings config.MigrationsConfig
+
+// ConfigureMigrations sets where migrations are and the scratch database
+// they are dry-run against. Call it before tools run.
+func ConfigureMigrations(cfg config.MigrationsConfig) {
+	migrationSettings = cfg
+}
+
+// MigrationsInput represents the input parameters for the migrations tool
+type MigrationsInput struct {
+	Action string `json:"action" jsonschema:"required,enum=list,enum=create,enum=dry_run" jsonschema_description:"list shows the migrations, create adds a migration with up and down SQL, dry_run applies every migration up and then down on the scratch database"`
+	Dir    string `json:"dir,omitempty" jsonschema_description:"Migrations directory relative to the workspace root; the configured or detected one when omitted"`
+	Name   string `json:"name,omitempty" jsonschema_description:"create: what the migration does, e.g. add_users_email"`
+	Up     string `json:"up,omitempty" jsonschema_description:"create: SQL that applies the change"`
+	Down   string `json:"down,omitempty" jsonschema_description:"create: SQL that reverts exactly what up does"`
+}
+
+// Validate implements input validation
+func (i *MigrationsInput) Validate() error {
+	switch i.Action {
+	case "":
+		return fmt.Errorf(errMsgMissingParam, "action")
+	case actionCreate:
+		switch {
+		case i.Name == "":
+			return fmt.Errorf(errMsgMissingParam, "name")
+		case strings.TrimSpace(i.Up) == "":
+			return fmt.Errorf(errMsgMissingParam, "up")
+		case strings.TrimSpace(i.Down) == "":
+			return fmt.Errorf("parameter \"down\" is required: every migration needs SQL that reverts it")
+		}
+		return nil
+	case actionList, actionDryRun:
+		if i.Name != "" || i.Up != "" || i.Down != "" {
+			return fmt.Errorf("parameters \"name\", \"up\" and \"down\" apply only to create")
+		}
+		return nil
+	}
+	return fmt.Errorf("action must be list, create or dry_run, not %q", i.Action)
+}
+
+// migration is one version in a migrations directory
+type migration struct {
+	version  string
+	name     string
+	upFile   string // Paths from the workspace root; the same file for goose
+	downFile string // Empty when golang-migrate has no down file
+	goCode   bool   // A goose migration written in Go
+}
+
+// label names the migration as its files do
+func (m migration) label() string {
+	return m.version + "_" + m.name
+}
+
+// migrationSet is the migrations of a directory
+type migrationSet struct {
+	dir        string // Resolved
+	shown      string
+	layout     string
+	migrations []migration // In version order
+}
+
+type MigrationsTool struct{}
+
+func (t MigrationsTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "migrations",
+		Group:    "database",
+		Mutating: true,
+		Confirms: true,
+		Paths:    migrationPaths,
+		Description: `Work with SQL migrations in golang-migrate (NNN_name.up.sql and NNN_name.down.sql)
+or goose (NNN_name.sql with -- +goose Up and -- +goose Down sections) layouts.
+
+Usage Examples:
+- {"action": "list"} // Migrations in version order, and the layout in use
+- {"action": "create", "name": "add_users_email", "up": "ALTER TABLE users ADD COLUMN email text;", "down": "ALTER TABLE users DROP COLUMN email;"}
+- {"action": "dry_run"} // Apply every up migration, then every down migration in reverse, on the scratch database
+
+create numbers the new migration after the existing ones, in their style (sequential or
+timestamp), and writes the files of the directory's layout. Use db_schema to check the current
+schema first, and dry_run after creating a migration to check that it applies and reverts.`,
+		InputSchema: schema.GenerateSchema[MigrationsInput](),
+	}
+}
+
+func (t MigrationsTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var migrationsInput MigrationsInput
+	if err := tools.DecodeInput(input, &migrationsInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := migrationsInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	set, err := loadMigrations(toolCtx, migrationsInput.Dir, migrationsInput.Action == actionCreate)
+	if err != nil {
+		return nil, err
+	}
+	switch migrationsInput.Action {
+	case actionList:
+		return tools.NewTextResult(set.String()), nil
+	case actionCreate:
+		return set.create(toolCtx, migrationsInput.Name, migrationsInput.Up, migrationsInput.Down)
+	default:
+		return set.dryRun(ctx, toolCtx)
+	}
+}
+
+// migrationPaths returns the migrations directory a call names, or the
+// configured one. A directory detected at run time is not known up front.
+func migrationPaths(_ *tools.ToolContext, input json.RawMessage) []string {
+	var migrationsInput MigrationsInput
+	_ = json.Unmarshal(input, &migrationsInput)
+	if migrationsInput.Dir == "" {
+		migrationsInput.Dir = migrationSettings.Dir
+	}
+	if migrationsInput.Dir == "" {
+		return nil
+	}
+	return []string{migrationsInput.Dir}
+}
+
+// loadMigrations finds the migrations directory and reads its file names.
+// A directory that does not exist yet is only accepted when creating.
+func loadMigrations(toolCtx *tools.ToolContext, dir string, creating bool) (*migrationSet, error) {
+	ws := workspaceOf(toolCtx)
+	fsys := ws.FS()
+	if dir == "" {
+		dir = migrationSettings.Dir
+	}
+	if dir == "" {
+		for _, candidate := range migrationDirs {
+			resolved, _, err := resolveDir(toolCtx, candidate)
+			if err != nil {
+				return nil, err
+			}
+			if info, err := fsys.Stat(resolved); err == nil && info.IsDir() {
+				dir = candidate
+				break
+			}
+		}
+	}
+	if dir == "" {
+		if !creating {
+			return nil, tools.NotFound(fmt.Errorf("no migrations directory found in %s; pass dir or set migrations.dir", strings.Join(migrationDirs, ", ")))
+		}
+		dir = migrationDirs[0]
+	}
+
+	resolved, shown, err := resolveDir(toolCtx, dir)
+	if err != nil {
+		return nil, err
+	}
+	set := &migrationSet{dir: resolved, shown: shown}
+	info, err := fsys.Stat(resolved)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && creating:
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, tools.NotFound(fmt.Errorf("migrations directory %q not found", shown))
+	case err != nil:
+		return nil, err
+	case !info.IsDir():
+		return nil, tools.InvalidInput(fmt.Errorf("%s is not a directory", shown))
+	default:
+		if err := set.read(fsys); err != nil {
+			return nil, err
+		}
+	}
+	if set.layout == "" {
+		set.layout = migrationSettings.Layout
+	}
+	if set.layout == "" {
+		set.layout = config.LayoutGolangMigrate
+	}
+	return set, nil
+}
+
+// resolveDir resolves a directory within the workspace
+func resolveDir(toolCtx *tools.ToolContext, dir string) (resolved, shown string, err error) {
+	ws := workspaceOf(toolCtx)
+	if ws == nil {
+		return dir, filepath.Clean(dir), nil
+	}
+	p, err := ws.ResolvePath(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "use a directory outside the workspace", Path: p.Abs}) {
+		return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+	}
+	return p.Abs, p.Shown, nil
+}
+
+// read collects the migrations of the directory and detects its layout:
+// golang-migrate when there are .up.sql or .down.sql files, goose when a
+// .sql file has goose annotations
+func (s *migrationSet) read(fsys workspace.FS) error {
+	paths, err := fsys.Glob(filepath.Join(s.dir, "*"))
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[string]*migration)
+	var versions []string
+	add := func(version, name string) *migration {
+		if byVersion[version] == nil {
+			byVersion[version] = &migration{version: version, name: name}
+			versions = append(versions, version)
+		}
+		return byVersion[version]
+	}
+
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if match := migrateFile.FindStringSubmatch(base); match != nil {
+			s.layout = config.LayoutGolangMigrate
+			m := add(match[1], match[2])
+			if match[3] == "up" {
+				m.upFile = filepath.Join(s.shown, base)
+			} else {
+				m.downFile = filepath.Join(s.shown, base)
+			}
+		}
+	}
+	if s.layout == "" {
+		for _, path := range paths {
+			base := filepath.Base(path)
+			match := gooseFile.FindStringSubmatch(base)
+			if match == nil {
+				continue
+			}
+			if match[3] == "sql" {
+				data, err := fsys.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if !gooseSection.Match(data) {
+					continue
+				}
+			}
+			s.layout = config.LayoutGoose
+			m := add(match[1], match[2])
+			m.upFile = filepath.Join(s.shown, base)
+			m.downFile = m.upFile
+			m.goCode = match[3] == "go"
+		}
+	}
+
+	slices.SortFunc(versions, compareVersions)
+	for _, version := range versions {
+		s.migrations = append(s.migrations, *byVersion[version])
+	}
+	return nil
+}
+
+// compareVersions orders versions by number, however many digits they have
+func compareVersions(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// String lists the migrations
+func (s *migrationSet) String() string {
+	if len(s.migrations) == 0 {
+		return fmt.Sprintf("No migrations in %s yet; new ones will use the %s layout", s.shown, s.layout)
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d migrations in %s (%s layout):\n", len(s.migrations), s.shown, s.layout)
+	for _, m := range s.migrations {
+		var notes []string
+		switch {
+		case m.goCode:
+			notes = append(notes, "written in Go")
+		case m.upFile == "":
+			notes = append(notes, "no up file")
+		case m.downFile == "":
+			notes = append(notes, "no down file")
+		}
+		fmt.Fprintf(&text, "- %s", m.label())
+		if len(notes) > 0 {
+			fmt.Fprintf(&text, " (%s)", strings.Join(notes, ", "))
+		}
+		text.WriteString("\n")
+	}
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// nextVersion numbers a new migration after the existing ones: with a
+// timestamp when they use timestamps or there are none, otherwise with the
+// next number at the same width
+func (s *migrationSet) nextVersion(now time.Time) string {
+	timestamp := now.UTC().Format(timestampVersion)
+	if len(s.migrations) == 0 {
+		return timestamp
+	}
+	last := s.migrations[len(s.migrations)-1].version
+	if len(last) == len(timestampVersion) && strings.HasPrefix(last, "20") {
+		if compareVersions(timestamp, last) <= 0 {
+			return incrementVersion(last)
+		}
+		return timestamp
+	}
+	return incrementVersion(last)
+}
+
+// incrementVersion adds one to a version, keeping its zero padding
+func incrementVersion(version string) string {
+	digits := []byte(version)
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] < '9' {
+			digits[i]++
+			return string(digits)
+		}
+		digits[i] = '0'
+	}
+	return "1" + string(digits)
+}
+
+// migrationName turns a description into the name part of a file name
+func migrationName(name string) string {
+	return strings.Trim(invalidName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// create writes a new migration with its up and down SQL, after the user
+// approves the files
+func (s *migrationSet) create(toolCtx *tools.ToolContext, name, up, down string) (*tools.ToolResult, error) {
+	name = migrationName(name)
+	if name == "" {
+		return nil, tools.InvalidInput(fmt.Errorf("parameter \"name\" must contain letters or digits"))
+	}
+	m := migration{version: s.nextVersion(time.Now()), name: name}
+
+	files := map[string]string{} // Content by file name
+	switch s.layout {
+	case config.LayoutGoose:
+		files[m.label()+".sql"] = fmt.Sprintf("-- +goose Up\n-- +goose StatementBegin\n%s\n-- +goose StatementEnd\n\n-- +goose Down\n-- +goose StatementBegin\n%s\n-- +goose StatementEnd\n",
+			strings.TrimSpace(up), strings.TrimSpace(down))
+	default:
+		files[m.label()+".up.sql"] = strings.TrimSpace(up) + "\n"
+		files[m.label()+".down.sql"] = strings.TrimSpace(down) + "\n"
+	}
+	names := make([]string, 0, len(files))
+	for fileName := range files {
+		names = append(names, fileName)
+	}
+	slices.Sort(names)
+	slices.Reverse(names) // up before down
+
+	fsys := workspaceOf(toolCtx).FS()
+	var preview strings.Builder
+	var shown []string
+	for _, fileName := range names {
+		path := filepath.Join(s.dir, fileName)
+		if _, err := fsys.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s already exists", filepath.Join(s.shown, fileName))
+		}
+		shown = append(shown, filepath.Join(s.shown, fileName))
+		fmt.Fprintf(&preview, "%s:\n%s\n", filepath.Join(s.shown, fileName), files[fileName])
+	}
+
+	approved := toolCtx.Confirm(confirm.Request{
+		Tool:    "migrations",
+		Action:  fmt.Sprintf("create migration %s in %s", m.label(), s.shown),
+		Path:    s.dir,
+		Preview: strings.TrimRight(preview.String(), "\n"),
+	})
+	if !approved {
+		return tools.NewTextResult("Migration creation cancelled by user"), nil
+	}
+
+	if err := fsys.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", s.shown, err)
+	}
+	for _, fileName := range names {
+		path := filepath.Join(s.dir, fileName)
+		if err := fsys.WriteFile(path, []byte(files[fileName]), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", filepath.Join(s.shown, fileName), err)
+		}
+		if toolCtx != nil {
+			toolCtx.Seen.SawContent(path, []byte(files[fileName]))
+		}
+	}
+	result := tools.NewTextResult(fmt.Sprintf("Created migration %s (%s layout): %s", m.label(), s.layout, strings.Join(shown, ", ")))
+	return result.WithFilesCreated(shown...), nil
+}
@@ -0,0 +1,367 @@
+// Package database provides a tool that reads the schema of the databases
+// configured for a project: tables, columns, constraints and indexes, never
+// the data in them
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam    = "parameter %q is required"
+	errMsgNoDatabases     = "no databases are configured; add them under databases in billdozer.yml"
+	errMsgUnknownDatabase = "unknown database %q. Configured databases: %s"
+	errMsgChooseDatabase  = "parameter \"database\" is required when more than one database is configured: %s"
+	errMsgUnknownTable    = "database %q has no table or view %q. Tables: %s"
+)
+
+const (
+	// timeout bounds each schema query, connecting included
+	timeout = 30 * time.Second
+	// maxOutputBytes is how much of a schema is returned
+	maxOutputBytes = 30000
+)
+
+// databases are the configured databases; see Configure
+var databases []config.DatabaseConfig
+
+// Configure sets the databases the tool can read the schemas of. Call it
+// before tools run.
+func Configure(cfg []config.DatabaseConfig) {
+	databases = cfg
+}
+
+// SchemaInput represents the input parameters for the db_schema tool
+type SchemaInput struct {
+	Database string `json:"database,omitempty" jsonschema_description:"Name of the configured database; may be omitted when only one is configured"`
+	Table    string `json:"table,omitempty" jsonschema_description:"Table or view to describe, optionally qualified with its schema (e.g. billing.invoices); all tables when omitted"`
+}
+
+type SchemaTool struct{}
+
+func (t SchemaTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:      "db_schema",
+		Group:     "database",
+		Cacheable: true,
+		Description: `Show the schema of a configured database: its tables and views with their columns
+(type, NOT NULL, default), primary, unique, check and foreign key constraints, and indexes.
+No rows are read.
+
+Usage Examples:
+- {} // Every table of the only configured database
+- {"database": "app", "table": "users"} // One table
+- {"database": "app", "table": "billing.invoices"} // A table in another schema
+
+Read the schema before writing migrations or queries instead of guessing column names and types.`,
+		InputSchema: schema.GenerateSchema[SchemaInput](),
+	}
+}
+
+func (t SchemaTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var schemaInput SchemaInput
+	if err := tools.DecodeInput(input, &schemaInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	database, err := findDatabase(schemaInput.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := workspaceOf(toolCtx)
+	root := ""
+	if ws != nil {
+		root = ws.Root()
+	}
+	client, err := newClient(database, root)
+	if err != nil {
+		return nil, err
+	}
+	output, err := client.run(ctx, ws.Runner())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema of database %q: %w", database.Name, err)
+	}
+
+	tables := parseSchema(client.rows(output))
+	if schemaInput.Table != "" {
+		found := findTable(tables, schemaInput.Table)
+		if found == nil {
+			return nil, tools.NotFound(fmt.Errorf(errMsgUnknownTable, database.Name, schemaInput.Table, tableNames(tables)))
+		}
+		tables = []*table{found}
+	}
+	// The command line holds the connection URL, so only the client is named
+	command := fmt.Sprintf("%s (schema of database %s)", client.argv[0], database.Name)
+	return tools.NewTextResult(formatSchema(database, tables)).WithCommands(command), nil
+}
+
+// findDatabase returns the database of the given name, or the only one
+func findDatabase(name string) (config.DatabaseConfig, error) {
+	names := make([]string, len(databases))
+	for i, database := range databases {
+		names[i] = database.Name
+	}
+	switch {
+	case len(databases) == 0:
+		return config.DatabaseConfig{}, tools.NotFound(errors.New(errMsgNoDatabases))
+	case name == "" && len(databases) == 1:
+		return databases[0], nil
+	case name == "":
+		return config.DatabaseConfig{}, tools.InvalidInput(fmt.Errorf(errMsgChooseDatabase, strings.Join(names, ", ")))
+	}
+	for _, database := range databases {
+		if database.Name == name {
+			return database, nil
+		}
+	}
+	return config.DatabaseConfig{}, tools.NotFound(fmt.Errorf(errMsgUnknownDatabase, name, strings.Join(names, ", ")))
+}
+
+// table is a table or view with what the schema says about it
+type table struct {
+	schema, name string
+	kind         string // table, view, materialized view...
+	columns      []column
+	constraints  []definition
+	indexes      []definition
+}
+
+type column struct {
+	name, dataType string
+	position       int
+	notNull        bool
+	defaultValue   string
+}
+
+// definition is a named constraint or index
+type definition struct {
+	name, text string
+}
+
+// parseSchema groups the rows of a schema query by table, in name order
+func parseSchema(rows [][]string) []*table {
+	byName := make(map[string]*table)
+	lookup := func(schemaName, name string) *table {
+		key := schemaName + "." + name
+		if byName[key] == nil {
+			byName[key] = &table{schema: schemaName, name: name, kind: "table"}
+		}
+		return byName[key]
+	}
+	for _, row := range rows {
+		if len(row) < 8 {
+			continue
+		}
+		t := lookup(row[1], row[2])
+		switch row[0] {
+		case "table":
+			t.kind = strings.ToLower(row[3])
+			if t.kind == "base table" {
+				t.kind = "table"
+			}
+		case "column":
+			position, _ := strconv.Atoi(row[4])
+			t.columns = append(t.columns, column{name: row[3], position: position, dataType: row[5], notNull: row[6] == "true" || row[6] == "t", defaultValue: row[7]})
+		case "constraint":
+			t.constraints = append(t.constraints, definition{name: row[3], text: row[5]})
+		case "index":
+			t.indexes = append(t.indexes, definition{name: row[3], text: row[5]})
+		}
+	}
+
+	tables := make([]*table, 0, len(byName))
+	for _, t := range byName {
+		sort.Slice(t.columns, func(i, j int) bool { return t.columns[i].position < t.columns[j].position })
+		sort.SliceStable(t.constraints, func(i, j int) bool { return t.constraints[i].name < t.constraints[j].name })
+		sort.SliceStable(t.indexes, func(i, j int) bool { return t.indexes[i].name < t.indexes[j].name })
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].schema != tables[j].schema {
+			return tables[i].schema < tables[j].schema
+		}
+		return tables[i].name < tables[j].name
+	})
+	return tables
+}
+
+// findTable finds a table by name or schema-qualified name
+func findTable(tables []*table, name string) *table {
+	schemaName, bare, qualified := strings.Cut(name, ".")
+	for _, t := range tables {
+		if (qualified && t.schema == schemaName && t.name == bare) || (!qualified && t.name == name) {
+			return t
+		}
+	}
+	return nil
+}
+
+func tableNames(tables []*table) string {
+	if len(tables) == 0 {
+		return "none"
+	}
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.schema + "." + t.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// formatSchema describes tables, naming their schema when they are not all
+// in the same one
+func formatSchema(database config.DatabaseConfig, tables []*table) string {
+	if len(tables) == 0 {
+		return fmt.Sprintf("Database %s (%s) has no tables", database.Name, database.Driver)
+	}
+	qualify := false
+	for _, t := range tables {
+		qualify = qualify || t.schema != tables[0].schema
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Database %s (%s)", database.Name, database.Driver)
+	if len(tables) > 1 {
+		fmt.Fprintf(&text, ", %d tables and views", len(tables))
+	}
+	if !qualify {
+		fmt.Fprintf(&text, " in schema %s", tables[0].schema)
+	}
+	text.WriteString("\n")
+	for _, t := range tables {
+		name := t.name
+		if qualify {
+			name = t.schema + "." + t.name
+		}
+		fmt.Fprintf(&text, "\n%s %s\n", t.kind, name)
+		writeColumns(&text, t.columns)
+		writeDefinitions(&text, "constraints", t.constraints)
+		writeDefinitions(&text, "indexes", t.indexes)
+	}
+	return firstBytes(strings.TrimRight(text.String(), "\n"))
+}
+
+// writeColumns writes one line per column, names and types aligned
+func writeColumns(text *strings.Builder, columns []column) {
+	nameWidth, typeWidth := 0, 0
+	for _, c := range columns {
+		nameWidth = max(nameWidth, len(c.name))
+		typeWidth = max(typeWidth, len(c.dataType))
+	}
+	for _, c := range columns {
+		line := fmt.Sprintf("  %-*s  %-*s", nameWidth, c.name, typeWidth, c.dataType)
+		if c.notNull {
+			line += "  NOT NULL"
+		}
+		if c.defaultValue != "" {
+			line += "  DEFAULT " + c.defaultValue
+		}
+		text.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+}
+
+func writeDefinitions(text *strings.Builder, title string, definitions []definition) {
+	if len(definitions) == 0 {
+		return
+	}
+	fmt.Fprintf(text, "  %s:\n", title)
+	for _, d := range definitions {
+		if d.name == "" || strings.Contains(d.text, d.name) {
+			fmt.Fprintf(text, "    %s\n", d.text)
+		} else {
+			fmt.Fprintf(text, "    %s: %s\n", d.name, d.text)
+		}
+	}
+}
+
+// run runs the client and returns its output; a client that fails is an
+// error carrying what it printed
+func (c *client) run(ctx context.Context, runner workspace.Runner) (string, error) {
+	stdout, stderr, err := run(ctx, runner, c.argv, c.env)
+	if err != nil {
+		return "", clientError(c.argv[0], err, stderr)
+	}
+	return stdout, nil
+}
+
+// run runs a database client and returns its output. The error of a client
+// that ran and failed implements workspace.ExitCoder; any other error means
+// it could not be run or did not finish in time.
+func run(ctx context.Context, runner workspace.Runner, argv []string, env map[string]string) (stdout, stderr string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out, errOut bytes.Buffer
+	// PGCONNECT_TIMEOUT keeps an unreachable server from using up the
+	// timeout; the other clients take it as an option
+	env = maps.Clone(env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+	env["PGCONNECT_TIMEOUT"] = "10"
+	process, err := runner.Start(workspace.Cmd{Argv: argv, Env: env, Stdout: &out, Stderr: &errOut, Group: true})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to run %s (is it installed and on the PATH?): %w", argv[0], err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- process.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		process.Kill()
+		<-done
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", "", fmt.Errorf("%s timed out after %s", argv[0], timeout)
+		}
+		return "", "", ctx.Err()
+	}
+	return out.String(), errOut.String(), err
+}
+
+// clientError describes a failed run of a client with what it printed
+func clientError(client string, err error, stderr string) error {
+	var exit workspace.ExitCoder
+	if !errors.As(err, &exit) {
+		return err
+	}
+	return fmt.Errorf("%s failed: %w: %s", client, err, strings.TrimSpace(stderr))
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of a schema within maxOutputBytes, up to the
+// end of a line
+func firstBytes(text string) string {
+	if len(text) <= maxOutputBytes {
+		return text
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(text[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted; ask for one table at a time]", text[:cut], len(text)-cut)
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(SchemaTool{})
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dollarQuote matches the opening of a PostgreSQL dollar-quoted string,
+// $$ or $tag$, which function bodies are usually written in
+var dollarQuote = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// blockKinds are the objects whose definitions may hold BEGIN ... END
+// blocks of statements, whose semicolons do not end the definition
+var blockKinds = map[string]bool{"TRIGGER": true, "PROCEDURE": true, "FUNCTION": true, "EVENT": true}
+
+// statementScanner splits SQL into statements at the semicolons that end
+// them: those outside quotes, comments, dollar-quoted strings and the
+// BEGIN ... END blocks of triggers and procedures
+type statementScanner struct {
+	src string
+	pos int
+}
+
+// next returns the comments and space before the next statement, and the
+// statement up to and including its semicolon. The statement is empty at
+// the end of src.
+func (s *statementScanner) next() (gap, statement string) {
+	start := s.pos
+	s.skipSpace()
+	gap = s.src[start:s.pos]
+	start = s.pos
+
+	var words []string
+	depth := 0
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == ';' && depth == 0:
+			s.pos++
+			return gap, s.src[start:s.pos]
+		case c == '\'' || c == '"' || c == '`':
+			s.skipQuoted(c)
+		case strings.HasPrefix(s.src[s.pos:], "--") || strings.HasPrefix(s.src[s.pos:], "/*"):
+			s.skipComment()
+		case c == '$' && (s.pos == start || !isWordByte(s.src[s.pos-1])) && dollarQuote.MatchString(s.src[s.pos:]):
+			tag := dollarQuote.FindString(s.src[s.pos:])
+			end := strings.Index(s.src[s.pos+len(tag):], tag)
+			if end < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += 2*len(tag) + end
+			}
+		case isWordByte(c):
+			word := strings.ToUpper(s.word())
+			if len(words) < 8 {
+				words = append(words, word)
+			}
+			if len(words) == 0 || words[0] != "CREATE" || !hasBlocks(words) {
+				continue
+			}
+			switch word {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				// END IF, END LOOP and the like close blocks that were not
+				// counted; END CASE closes a CASE
+				s.skipSpace()
+				switch strings.ToUpper(s.peekWord()) {
+				case "IF", "LOOP", "WHILE", "REPEAT", "FOR":
+					s.word()
+				case "CASE":
+					s.word()
+					depth--
+				default:
+					depth--
+				}
+				depth = max(depth, 0)
+			}
+		default:
+			s.pos++
+		}
+	}
+	return gap, s.src[start:]
+}
+
+// hasBlocks reports whether the first words of a CREATE statement define
+// a trigger, procedure, function or event
+func hasBlocks(words []string) bool {
+	for _, word := range words {
+		if blockKinds[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// skipSpace skips white space and comments
+func (s *statementScanner) skipSpace() {
+	for s.pos < len(s.src) {
+		switch {
+		case strings.ContainsRune(" \t\r\n\f", rune(s.src[s.pos])):
+			s.pos++
+		case strings.HasPrefix(s.src[s.pos:], "--") || strings.HasPrefix(s.src[s.pos:], "/*"):
+			s.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+// skipComment skips a -- or /* */ comment
+func (s *statementScanner) skipComment() {
+	if strings.HasPrefix(s.src[s.pos:], "--") {
+		if end := strings.IndexByte(s.src[s.pos:], '\n'); end >= 0 {
+			s.pos += end + 1
+		} else {
+			s.pos = len(s.src)
+		}
+		return
+	}
+	if end := strings.Index(s.src[s.pos+2:], "*/"); end >= 0 {
+		s.pos += end + 4
+	} else {
+		s.pos = len(s.src)
+	}
+}
+
+// skipQuoted skips a string or quoted identifier, where a doubled quote
+// stands for itself
+func (s *statementScanner) skipQuoted(quote byte) {
+	s.pos++
+	for s.pos < len(s.src) {
+		if s.src[s.pos] == quote {
+			if s.pos+1 < len(s.src) && s.src[s.pos+1] == quote {
+				s.pos += 2
+				continue
+			}
+			s.pos++
+			return
+		}
+		s.pos++
+	}
+}
+
+// word reads the word at the current position
+func (s *statementScanner) word() string {
+	start := s.pos
+	for s.pos < len(s.src) && isWordByte(s.src[s.pos]) {
+		s.pos++
+	}
+	return s.src[start:s.pos]
+}
+
+// peekWord returns the word at the current position without reading it
+func (s *statementScanner) peekWord() string {
+	end := s.pos
+	for end < len(s.src) && isWordByte(s.src[end]) {
+		end++
+	}
+	return s.src[s.pos:end]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+
+	"agent/internal/schema"
+	"agent/internal/workspace"
+)
+
+// ErrorKind classifies a tool failure so the agent can decide whether to
+// retry it and the model gets a hint about how to correct it
+type ErrorKind string
+
+const (
+	ErrorUnknown          ErrorKind = ""
+	ErrorInvalidInput     ErrorKind = "invalid-input"
+	ErrorNotFound         ErrorKind = "not-found"
+	ErrorTransient        ErrorKind = "transient"
+	ErrorPermissionDenied ErrorKind = "permission-denied"
+)
+
+// Hint returns guidance for the model on how to respond to this kind of failure
+func (k ErrorKind) Hint() string {
+	switch k {
+	case ErrorInvalidInput:
+		return "Fix the arguments according to the tool's input schema and call it again."
+	case ErrorNotFound:
+		return "Check the path or name (e.g. with list_files or glob_search) before calling again."
+	case ErrorTransient:
+		return "This failure is likely temporary; the call may succeed if repeated."
+	case ErrorPermissionDenied:
+		return "Do not repeat this call. Choose a different approach or ask the user."
+	}
+	return ""
+}
+
+// ToolError is an error with an explicit classification
+type ToolError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+// Error implements the error interface
+func (e *ToolError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidInput marks err as caused by bad tool arguments
+func InvalidInput(err error) error {
+	return &ToolError{Kind: ErrorInvalidInput, Err: err}
+}
+
+// NotFound marks err as caused by a missing file, command or other target
+func NotFound(err error) error {
+	return &ToolError{Kind: ErrorNotFound, Err: err}
+}
+
+// Transient marks err as a temporary failure worth retrying
+func Transient(err error) error {
+	return &ToolError{Kind: ErrorTransient, Err: err}
+}
+
+// PermissionDenied marks err as a refusal by policy or by the user
+func PermissionDenied(err error) error {
+	return &ToolError{Kind: ErrorPermissionDenied, Err: err}
+}
+
+// Classify returns the kind of a tool error. Explicitly classified errors
+// take precedence; otherwise common standard library errors are recognized.
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return ErrorUnknown
+	}
+
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr.Kind
+	}
+
+	var validationErr *schema.ValidationError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &validationErr):
+		return ErrorInvalidInput
+	case errors.Is(err, workspace.ErrAccessDenied), errors.Is(err, os.ErrPermission):
+		return ErrorPermissionDenied
+	case errors.Is(err, os.ErrNotExist):
+		return ErrorNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTransient
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTransient
+	}
+	return ErrorUnknown
+}
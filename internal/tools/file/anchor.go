@@ -0,0 +1,330 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"agent/internal/confirm"
+	"agent/internal/diff"
+	"agent/internal/schema"
+	"agent/internal/tools"
+)
+
+// Anchors and positions of insert_at_anchor
+const (
+	anchorImports    = "imports"
+	anchorLastImport = "last_import"
+	anchorFile       = "file"
+
+	positionBefore = "before"
+	positionAfter  = "after"
+	positionStart  = "start"
+	positionEnd    = "end"
+)
+
+// InsertAtAnchorInput represents the input parameters for inserting lines
+// relative to a structural anchor
+type InsertAtAnchorInput struct {
+	Path     string `json:"path" jsonschema:"required" jsonschema_description:"Path to the existing text file to insert into"`
+	Anchor   string `json:"anchor" jsonschema:"required,enum=function,enum=type,enum=imports,enum=last_import,enum=file" jsonschema_description:"What to insert relative to: a function or method, a type (struct, class, interface, enum...), the import statements as a whole, the last import statement, or the whole file"`
+	Name     string `json:"name,omitempty" jsonschema_description:"Name of the function or type anchor. Name a method with its type, as Server.Serve, when other types have one of the same name."`
+	Position string `json:"position" jsonschema:"required,enum=before,enum=after,enum=start,enum=end" jsonschema_description:"before or after the anchor; start or end of the body of a function or type, or of the file"`
+	Content  string `json:"content" jsonschema:"required" jsonschema_description:"Lines to insert, indented like the code around the anchor"`
+}
+
+// Validate implements input validation
+func (i *InsertAtAnchorInput) Validate() error {
+	switch {
+	case i.Path == "":
+		return fmt.Errorf(errMsgMissingParam, "path")
+	case i.Content == "":
+		return fmt.Errorf(errMsgMissingParam, "content")
+	}
+	switch i.Anchor {
+	case kindFunction, kindType:
+		if i.Name == "" {
+			return fmt.Errorf("parameter \"name\" is required with a %s anchor", i.Anchor)
+		}
+	case anchorImports, anchorLastImport:
+		if i.Name != "" {
+			return fmt.Errorf("parameter \"name\" applies only to function and type anchors")
+		}
+		if i.Position != positionBefore && i.Position != positionAfter {
+			return fmt.Errorf("position must be before or after for the %s anchor", i.Anchor)
+		}
+	case anchorFile:
+		if i.Name != "" {
+			return fmt.Errorf("parameter \"name\" applies only to function and type anchors")
+		}
+	default:
+		return fmt.Errorf("anchor must be function, type, imports, last_import or file, not %q", i.Anchor)
+	}
+	switch i.Position {
+	case positionBefore, positionAfter, positionStart, positionEnd:
+		return nil
+	}
+	return fmt.Errorf("position must be before, after, start or end, not %q", i.Position)
+}
+
+// describe names the place the input inserts at, for the result
+func (i *InsertAtAnchorInput) describe() string {
+	anchor := strings.ReplaceAll(i.Anchor, "_", " ")
+	if i.Name != "" {
+		anchor += " " + i.Name
+	}
+	switch i.Position {
+	case positionStart, positionEnd:
+		return fmt.Sprintf("at the %s of %s", i.Position, anchor)
+	}
+	return i.Position + " " + anchor
+}
+
+// InsertAtAnchorTool inserts lines next to functions, types and imports
+// found by a lightweight outline of the file, so generated and templated
+// files can be extended without quoting the text around the insertion
+type InsertAtAnchorTool struct{}
+
+// Definition returns the tool definition for the insert at anchor tool
+func (t InsertAtAnchorTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "insert_at_anchor",
+		Group:    "file",
+		Mutating: true,
+		Confirms: true,
+		Description: `Insert lines into an existing text file relative to a function, type or the imports, without quoting the surrounding text as edit_file needs.
+
+Usage Examples:
+- {"path": "server.go", "anchor": "function", "name": "Serve", "position": "after", "content": "func (s *Server) Stop() {\n}"}
+- {"path": "config.go", "anchor": "type", "name": "Config", "position": "end", "content": "\tTimeout time.Duration"}
+- {"path": "app.py", "anchor": "last_import", "position": "after", "content": "import json"}
+
+- anchor: function, type (struct, class, interface, enum...), imports (all of them), last_import, or file
+- position: before or after the anchor, or start or end of the body of a function, type or the file
+- Definitions are found by their keyword (func, def, fn, function, class, struct, interface...) or, in C-like languages, a header followed by a braced body; bodies end at the matching brace, or where the indentation of a Python body ends
+- A definition's comments, decorators and attributes go with it, so "before" inserts above them
+- Content is inserted as whole lines, as written: indent it like the code around it. A blank line is kept between a definition and content inserted before or after it
+- Use edit_file when the anchor is ambiguous or not recognized`,
+		InputSchema: schema.GenerateSchema[InsertAtAnchorInput](),
+	}
+}
+
+// Execute performs the insertion
+func (t InsertAtAnchorTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var insertInput InsertAtAnchorInput
+	if err := tools.DecodeInput(input, &insertInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := insertInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	path, shown, err := resolvePath(toolCtx, insertInput.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockPath(toolCtx, path)
+	defer unlock()
+	defer fileCache(toolCtx).Forget(path)
+
+	fsys := fileSystem(toolCtx)
+	content, err := fsys.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, tools.NotFound(fmt.Errorf("file does not exist. Use write for new files"))
+		}
+		return nil, err
+	}
+	if err := ensureSeen(toolCtx, fsys, shown, path, content); err != nil {
+		return nil, err
+	}
+
+	oldContent, encoding, err := decodeText(shown, content)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary([]byte(oldContent)) {
+		return nil, fmt.Errorf("cannot insert into binary file %s", shown)
+	}
+
+	// As with edit_file, CRLF files are worked on as LF and converted back
+	crlf := usesCRLF(oldContent)
+	text, insert := oldContent, insertInput.Content
+	if crlf {
+		text, insert = toLF(text), toLF(insert)
+	}
+
+	var lines []string
+	if text != "" {
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+	at, separate, err := locateAnchor(newOutline(path, lines), shown, &insertInput)
+	if err != nil {
+		return nil, err
+	}
+	edited, line := insertLines(lines, at, strings.Split(strings.TrimSuffix(insert, "\n"), "\n"), separate)
+	newText := strings.Join(edited, "\n")
+	if text == "" || strings.HasSuffix(text, "\n") {
+		newText += "\n"
+	}
+
+	newContent := newText
+	if crlf {
+		newContent = strings.ReplaceAll(newText, "\n", "\r\n")
+	}
+	encoded, err := encodeText(shown, newContent, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := diff.Unified(shown, text, newText)
+	approved, displayed := confirmChange(toolCtx, confirm.Request{
+		Tool:    "insert_at_anchor",
+		Action:  "insert into the file " + insertInput.describe(),
+		Path:    shown,
+		Preview: preview,
+	})
+	if !approved {
+		return tools.NewTextResult("Insertion cancelled by user"), nil
+	}
+
+	if err := ensureUnchanged(fsys, shown, path, content, true); err != nil {
+		return nil, err
+	}
+	if err := fsys.WriteFileAtomic(path, encoded, defaultFilePermissions); err != nil {
+		return nil, fmt.Errorf(errMsgOperationFailed, "write file", err)
+	}
+	fileVersions(toolCtx).SawContent(path, encoded)
+	if !displayed {
+		showChange(toolCtx, preview)
+	}
+
+	inserted := strings.Count(strings.TrimSuffix(insert, "\n"), "\n") + 1
+	return tools.NewTextResult(fmt.Sprintf("Inserted %d lines into %s %s, at line %d", inserted, shown, insertInput.describe(), line)).WithFilesChanged(shown), nil
+}
+
+// locateAnchor returns the index of the line the content goes before, and
+// whether it is to be kept apart from the code around it by blank lines
+func locateAnchor(o *outline, path string, input *InsertAtAnchorInput) (at int, separate bool, err error) {
+	switch input.Anchor {
+	case anchorFile:
+		if input.Position == positionBefore || input.Position == positionStart {
+			return 0, false, nil
+		}
+		return len(o.lines), false, nil
+
+	case anchorImports, anchorLastImport:
+		imports := o.imports()
+		if len(imports) == 0 {
+			return 0, false, tools.NotFound(fmt.Errorf("no import statements found in %s; use edit_file, or the file anchor", path))
+		}
+		first, last := imports[0].start, imports[len(imports)-1]
+		if input.Anchor == anchorImports {
+			if input.Position == positionBefore {
+				return first, false, nil
+			}
+			return last.end + 1, false, nil
+		}
+		start, end := last.start, last.end
+		if last.block {
+			// The last import in a Go import block is its last line of code
+			start = -1
+			for i := last.end - 1; i > last.start; i-- {
+				if strings.TrimSpace(o.code[i]) != "" {
+					start, end = i, i
+					break
+				}
+			}
+			if start < 0 {
+				return 0, false, fmt.Errorf("the import block on line %d of %s is empty; use edit_file", last.start+1, path)
+			}
+		}
+		if input.Position == positionBefore {
+			return start, false, nil
+		}
+		return end + 1, false, nil
+	}
+
+	d, err := o.lookup(path, input.Anchor, input.Name)
+	if err != nil {
+		return 0, false, err
+	}
+	switch input.Position {
+	case positionBefore:
+		return d.start, true, nil
+	case positionAfter:
+		return d.end + 1, true, nil
+	}
+
+	noBody := fmt.Errorf("%s %s on line %d of %s has no body spanning lines, so nothing can go at its %s; insert before or after it, or use edit_file",
+		input.Anchor, input.Name, d.header+1, path, input.Position)
+	switch {
+	case d.open < 0 || d.end <= d.open:
+		return 0, false, noBody
+	case !d.braced && input.Position == positionEnd:
+		return d.end + 1, false, nil
+	case !d.braced:
+		return o.afterDocstring(d.open + 1), false, nil
+	case input.Position == positionStart:
+		if !strings.HasSuffix(strings.TrimSpace(o.code[d.open]), "{") {
+			return 0, false, noBody
+		}
+		return d.open + 1, false, nil
+	}
+	if !strings.HasPrefix(strings.TrimSpace(o.code[d.end]), "}") {
+		return 0, false, noBody
+	}
+	return d.end, false, nil
+}
+
+// afterDocstring skips the docstring a Python body may start with at line
+// i, which must stay first to document the function or class
+func (o *outline) afterDocstring(i int) int {
+	if i >= len(o.lines) {
+		return i
+	}
+	trimmed := strings.TrimSpace(o.lines[i])
+	if !strings.HasPrefix(trimmed, `"`) && !strings.HasPrefix(trimmed, "'") {
+		return i
+	}
+	for i++; i < len(o.lines) && o.inString[i]; i++ {
+	}
+	return i
+}
+
+// insertLines inserts content before lines[at], returning the lines and
+// the 1-based line number content starts on. When separate is set, a blank
+// line keeps content apart from code right before and after it, but not
+// from the brace or colon opening a body or the brace closing one.
+func insertLines(lines []string, at int, content []string, separate bool) ([]string, int) {
+	isBlank := func(line string) bool { return strings.TrimSpace(line) == "" }
+	start := at + 1
+	if separate {
+		if previous := at - 1; previous >= 0 && !isBlank(lines[previous]) && !isBlank(content[0]) && !opensBody(lines[previous]) {
+			content = append([]string{""}, content...)
+			start++
+		}
+		if at < len(lines) && !isBlank(lines[at]) && !isBlank(content[len(content)-1]) && !closesBody(lines[at]) {
+			content = append(content, "")
+		}
+	}
+	return slices.Concat(lines[:at], content, lines[at:]), start
+}
+
+func opensBody(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, ":") || strings.HasSuffix(trimmed, "(")
+}
+
+func closesBody(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "}") || strings.HasPrefix(trimmed, ")") || strings.HasPrefix(trimmed, "]")
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(InsertAtAnchorTool{})
+}
@@ -0,0 +1,27 @@
+package file
+
+import (
+	"fmt"
+
+	"agent/internal/confirm"
+	"agent/internal/diff"
+	"agent/internal/tools"
+)
+
+// confirmChange asks to approve a file change whose preview is a diff.
+// shown reports whether the user was prompted and so has seen the diff.
+func confirmChange(toolCtx *tools.ToolContext, req confirm.Request) (approved, shown bool) {
+	if toolCtx != nil {
+		shown = confirm.WillPrompt(toolCtx.Confirmer, req)
+	}
+	return toolCtx.Confirm(req), shown
+}
+
+// showChange prints the colored diff of an applied change so the user can
+// follow edits that were approved without a prompt
+func showChange(toolCtx *tools.ToolContext, preview string) {
+	if toolCtx == nil || toolCtx.Output == nil || !diff.IsUnified(preview) {
+		return
+	}
+	fmt.Fprintln(toolCtx.Output, confirm.FormatPreview(preview))
+}
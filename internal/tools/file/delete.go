@@ -1,13 +1,16 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 
+	"agent/internal/backup"
+	"agent/internal/confirm"
 	"agent/internal/schema"
 	"agent/internal/tools"
+	"agent/internal/workspace"
 )
 
 // Error message constants specific to delete operations
@@ -32,13 +35,16 @@ type DeleteFileTool struct{}
 
 func (t DeleteFileTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name: "delete_file",
+		Name:     "delete_file",
+		Group:    "file",
+		Mutating: true,
+		Confirms: true,
 		Description: `Delete a file from the filesystem.
 		
 Requirements:
 - File must exist (will fail if file doesn't exist)
 - Only deletes files, not directories  
-- Cannot be undone
+- Backed up first, unless backups are off; restore_backup puts the file back
 
 Safety:
 - Requires explicit user confirmation before deletion
@@ -49,33 +55,57 @@ Safety:
 	}
 }
 
-func (t DeleteFileTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t DeleteFileTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	deleteInput, err := t.parseAndValidateInput(input)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
 	}
 
-	if err := t.validateFileExists(deleteInput.Path); err != nil {
-		return "", err
+	path, shown, err := resolvePath(toolCtx, deleteInput.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockPath(toolCtx, path)
+	defer unlock()
+	defer fileCache(toolCtx).Forget(path)
+
+	fsys := fileSystem(toolCtx)
+	if err := t.validateFileExists(fsys, path); err != nil {
+		return nil, err
+	}
+	content, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgOperationFailed, "read file", err)
 	}
 
 	// Ask for user confirmation before deletion
-	if !t.confirmDeletion(ctx, deleteInput.Path) {
-		return "File deletion cancelled by user", nil
+	if !t.confirmDeletion(toolCtx, shown, path) {
+		return tools.NewTextResult("File deletion cancelled by user"), nil
 	}
 
-	if err := os.Remove(deleteInput.Path); err != nil {
-		return "", fmt.Errorf(errMsgOperationFailed, "delete file", err)
+	if err := ensureUnchanged(fsys, shown, path, content, true); err != nil {
+		return nil, err
 	}
 
-	return fmt.Sprintf("Successfully deleted file %s", deleteInput.Path), nil
+	backupID, err := backUp(toolCtx, path, content, backup.ReasonDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fsys.Remove(path); err != nil {
+		return nil, fmt.Errorf(errMsgOperationFailed, "delete file", err)
+	}
+	fileVersions(toolCtx).Forget(path)
+
+	return tools.NewTextResult(fmt.Sprintf("Successfully deleted file %s%s", shown, backupNote(backupID))).WithFilesDeleted(shown), nil
 }
 
 // Helper methods for better separation of concerns
 func (t DeleteFileTool) parseAndValidateInput(input json.RawMessage) (*DeleteFileInput, error) {
 	var deleteInput DeleteFileInput
-	if err := json.Unmarshal(input, &deleteInput); err != nil {
-		return nil, fmt.Errorf("invalid JSON input: %w", err)
+	if err := tools.DecodeInput(input, &deleteInput); err != nil {
+		return nil, err
 	}
 
 	if err := deleteInput.Validate(); err != nil {
@@ -85,8 +115,8 @@ func (t DeleteFileTool) parseAndValidateInput(input json.RawMessage) (*DeleteFil
 	return &deleteInput, nil
 }
 
-func (t DeleteFileTool) validateFileExists(path string) error {
-	info, err := os.Stat(path)
+func (t DeleteFileTool) validateFileExists(fsys workspace.FS, path string) error {
+	info, err := fsys.Stat(path)
 	if os.IsNotExist(err) {
 		return fmt.Errorf(errMsgFileNotFound, path)
 	}
@@ -102,24 +132,18 @@ func (t DeleteFileTool) validateFileExists(path string) error {
 }
 
 // confirmDeletion asks the user to confirm file deletion
-func (t DeleteFileTool) confirmDeletion(ctx *tools.ToolContext, path string) bool {
-	// Check if user input function is available
-	if ctx.GetUserInput == nil {
-		fmt.Printf("Warning: User input not available, proceeding with deletion\n")
-		return true
-	}
-
-	// Ask for user confirmation
-	fmt.Printf("⚠️ Billdozer wants to delete the file: \u001b[93m%s\u001b[0m\n", path)
-	fmt.Printf("Do you want to proceed? (yes/y to confirm, anything else to cancel): ")
-
-	response, ok := ctx.GetUserInput()
-	if !ok {
-		return false
+func (t DeleteFileTool) confirmDeletion(toolCtx *tools.ToolContext, path, resolvedPath string) bool {
+	var preview string
+	if info, err := fileSystem(toolCtx).Stat(resolvedPath); err == nil {
+		preview = fmt.Sprintf("(%d bytes, last modified %s)", info.Size(), info.ModTime().Format("2006-01-02 15:04"))
 	}
 
-	response = strings.ToLower(strings.TrimSpace(response))
-	return response == "yes" || response == "y"
+	return toolCtx.Confirm(confirm.Request{
+		Tool:    "delete_file",
+		Action:  "delete the file",
+		Path:    path,
+		Preview: preview,
+	})
 }
 
 func init() {
@@ -2,20 +2,25 @@ package file
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"agent/internal/confirm"
+	"agent/internal/diff"
 	"agent/internal/schema"
 	"agent/internal/tools"
 )
 
 // EditFileInput represents the input parameters for editing a file
 type EditFileInput struct {
-	Path   string `json:"path" jsonschema_description:"Path to existing file to edit"`
-	OldStr string `json:"old_str" jsonschema_description:"Exact text to find and replace (must appear exactly once)"`
-	NewStr string `json:"new_str" jsonschema_description:"Replacement text (must differ from old_str)"`
+	Path   string `json:"path" jsonschema:"required" jsonschema_description:"Path to existing file to edit"`
+	OldStr string `json:"old_str" jsonschema:"required" jsonschema_description:"Exact text to find and replace (must appear exactly once)"`
+	NewStr string `json:"new_str" jsonschema:"required" jsonschema_description:"Replacement text (must differ from old_str)"`
 }
 
 // EditFileTool implements the file editing functionality
@@ -24,10 +29,13 @@ type EditFileTool struct{}
 // Definition returns the tool definition for the edit file tool
 func (t EditFileTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name: "edit_file",
+		Name:     "edit_file",
+		Group:    "file",
+		Mutating: true,
+		Confirms: true,
 		Description: `Edit an existing text file by replacing text.
 
-- File must already exist (use create_file or write_file for new files)
+- File must already exist (use write for new files)
 - Replaces 'old_str' with 'new_str' in the given file
 - 'old_str' must exist exactly once in the file
 - 'old_str' and 'new_str' must be different`,
@@ -36,59 +44,118 @@ func (t EditFileTool) Definition() tools.ToolDefinition {
 }
 
 // Execute performs the file editing operation
-func (t EditFileTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t EditFileTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	var editFileInput EditFileInput
-	err := json.Unmarshal(input, &editFileInput)
+	err := tools.DecodeInput(input, &editFileInput)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
 	}
 
 	if editFileInput.Path == "" {
-		return "", fmt.Errorf("path cannot be empty. Provide a file path to edit")
+		return nil, tools.InvalidInput(fmt.Errorf("path cannot be empty. Provide a file path to edit"))
 	}
 
 	if editFileInput.OldStr == "" {
-		return "", fmt.Errorf("old_str cannot be empty. Use create_file or write_file for new files")
+		return nil, tools.InvalidInput(fmt.Errorf("old_str cannot be empty. Use write for new files"))
 	}
 
 	if editFileInput.OldStr == editFileInput.NewStr {
-		return "", fmt.Errorf("old_str and new_str must be different")
+		return nil, tools.InvalidInput(fmt.Errorf("old_str and new_str must be different"))
 	}
 
+	path, shown, err := resolvePath(toolCtx, editFileInput.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockPath(toolCtx, path)
+	defer unlock()
+	defer fileCache(toolCtx).Forget(path)
+
 	// Read existing file
-	content, err := os.ReadFile(editFileInput.Path)
+	fsys := fileSystem(toolCtx)
+	content, err := fsys.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", fmt.Errorf("file does not exist. Use create_file or write_file for new files")
+			return nil, tools.NotFound(fmt.Errorf("file does not exist. Use write for new files"))
 		}
-		return "", err
+		return nil, err
+	}
+	if err := ensureSeen(toolCtx, fsys, shown, path, content); err != nil {
+		return nil, err
+	}
+
+	// Edited as UTF-8 and written back in the file's own encoding
+	oldContent, encoding, err := decodeText(shown, content)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if file is binary to prevent corruption
-	if isBinary(content) {
-		return "", fmt.Errorf("cannot edit binary file %s. Use write_file to replace binary files entirely", editFileInput.Path)
+	if isBinary([]byte(oldContent)) {
+		return nil, fmt.Errorf("cannot edit binary file %s. Use write to replace binary files entirely", shown)
 	}
 
-	oldContent := string(content)
+	// Files with CRLF line endings are edited as LF, which is what the model
+	// writes, and converted back, so an edit neither misses text over line
+	// endings nor mixes them
+	crlf := usesCRLF(oldContent)
+	text, oldStr, newStr := oldContent, editFileInput.OldStr, editFileInput.NewStr
+	if crlf {
+		text = toLF(text)
+		oldStr, newStr = toLF(oldStr), toLF(newStr)
+		if oldStr == newStr {
+			return nil, tools.InvalidInput(fmt.Errorf("old_str and new_str differ only in line endings, which always follow the file's"))
+		}
+	}
 
 	// Check that old_str exists exactly once
-	count := strings.Count(oldContent, editFileInput.OldStr)
+	count := strings.Count(text, oldStr)
 	if count == 0 {
-		return "", fmt.Errorf("old_str '%s' not found in file", editFileInput.OldStr)
+		return nil, fmt.Errorf("old_str %s not found in file%s", excerpt(editFileInput.OldStr), notFoundHint(text, oldStr))
 	}
 	if count > 1 {
-		return "", fmt.Errorf("old_str '%s' found %d times in file, must exist exactly once", editFileInput.OldStr, count)
+		return nil, fmt.Errorf("old_str %s found %d times in file, starting on lines %s; it must exist exactly once, so include more of the text around the one to replace",
+			excerpt(editFileInput.OldStr), count, matchLines(text, oldStr))
 	}
 
 	// Perform replacement
-	newContent := strings.Replace(oldContent, editFileInput.OldStr, editFileInput.NewStr, 1)
+	edited := keepFinalNewline(text, strings.Replace(text, oldStr, newStr, 1))
+	newContent := edited
+	if crlf {
+		newContent = strings.ReplaceAll(edited, "\n", "\r\n")
+	}
+	encoded, err := encodeText(shown, newContent, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := diff.Unified(shown, text, edited)
+	approved, displayed := confirmChange(toolCtx, confirm.Request{
+		Tool:    "edit_file",
+		Action:  "edit the file",
+		Path:    shown,
+		Preview: preview,
+	})
+	if !approved {
+		return tools.NewTextResult("File edit cancelled by user"), nil
+	}
 
-	err = os.WriteFile(editFileInput.Path, []byte(newContent), 0644)
+	if err := ensureUnchanged(fsys, shown, path, content, true); err != nil {
+		return nil, err
+	}
+
+	// Replaced atomically, so a crash or a full disk never leaves the file truncated
+	err = fsys.WriteFileAtomic(path, encoded, 0644)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	fileVersions(toolCtx).SawContent(path, encoded)
+	if !displayed {
+		showChange(toolCtx, preview)
 	}
 
-	return fmt.Sprintf("Successfully edited file %s", editFileInput.Path), nil
+	return tools.NewTextResult(fmt.Sprintf("Successfully edited file %s", shown)).WithFilesChanged(shown), nil
 }
 
 // isBinary detects if a file contains binary data to prevent text editing corruption
@@ -101,6 +168,78 @@ func isBinary(data []byte) bool {
 	return bytes.IndexByte(data[:checkLen], 0) != -1
 }
 
+// usesCRLF reports whether every line of content ends with CRLF
+func usesCRLF(content string) bool {
+	lines := strings.Count(content, "\n")
+	return lines > 0 && strings.Count(content, "\r\n") == lines
+}
+
+func toLF(text string) string {
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+// maxExcerpt is how much of old_str error messages quote
+const maxExcerpt = 80
+
+// excerpt quotes the start of s for an error message
+func excerpt(s string) string {
+	if len(s) <= maxExcerpt {
+		return strconv.Quote(s)
+	}
+	cut := maxExcerpt
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return strconv.Quote(s[:cut]) + "..."
+}
+
+// notFoundHint explains, when it can, why old_str did not match: text the
+// model copies back often differs from the file only in whitespace, or in
+// the line endings of a file that mixes CRLF and LF
+func notFoundHint(text, oldStr string) string {
+	switch {
+	case strings.Contains(toLF(text), toLF(oldStr)):
+		return "; it matches if line endings are ignored, but the file mixes CRLF and LF. Replace text within one line, or rewrite the file with write"
+	case strings.Contains(strings.Join(strings.Fields(text), " "), strings.Join(strings.Fields(oldStr), " ")):
+		return "; it matches if whitespace is ignored, so compare its indentation (tabs or spaces), line breaks and trailing spaces with the file"
+	}
+	return "; read the file again and copy the text to replace exactly"
+}
+
+// matchLines lists the lines the first few occurrences of oldStr start on
+func matchLines(text, oldStr string) string {
+	const shown = 5
+	var lines []string
+	line, offset := 1, 0
+	for len(lines) < shown {
+		i := strings.Index(text[offset:], oldStr)
+		if i < 0 {
+			break
+		}
+		line += strings.Count(text[offset:offset+i], "\n")
+		lines = append(lines, strconv.Itoa(line))
+		line += strings.Count(oldStr, "\n")
+		offset += i + len(oldStr)
+	}
+	if strings.Count(text[offset:], oldStr) > 0 {
+		lines = append(lines, "...")
+	}
+	return strings.Join(lines, ", ")
+}
+
+// keepFinalNewline makes edited end with a newline if and only if original
+// did, so replacing the last line does not add or drop one
+func keepFinalNewline(original, edited string) string {
+	had, has := strings.HasSuffix(original, "\n"), strings.HasSuffix(edited, "\n")
+	switch {
+	case had && !has && edited != "":
+		return edited + "\n"
+	case !had && has:
+		return strings.TrimSuffix(edited, "\n")
+	}
+	return edited
+}
+
 func init() {
 	tools.DefaultRegistry.RegisterTool(EditFileTool{})
 }
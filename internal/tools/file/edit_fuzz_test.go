@@ -0,0 +1,127 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"agent/internal/tools/toolstest"
+)
+
+func FuzzEditFile(f *testing.F) {
+	f.Add("package main\n\nfunc main() {}\n", "func main() {}", "func main() {\n\tprintln(1)\n}")
+	f.Add("a\nb\na\n", "a", "c")
+	f.Add("x = 1\r\ny = 2\r\n", "y = 2\n", "y = 3\n")
+	f.Add("no newline", "newline", "final newline\n")
+	f.Add("\tindented\n", "  indented", "dedented")
+	f.Add("last line\n", "last line\n", "")
+	f.Fuzz(func(t *testing.T, content, oldStr, newStr string) {
+		toolCtx := toolstest.Context(t, map[string]string{"file.txt": content})
+		input, _ := json.Marshal(EditFileInput{Path: "file.txt", OldStr: oldStr, NewStr: newStr})
+		result, err := toolstest.Execute(t, EditFileTool{}, toolCtx, string(input))
+
+		// Plain UTF-8 text, where the edit is a replacement and nothing else
+		plain := utf8.ValidString(content) && utf8.ValidString(newStr) && !strings.ContainsAny(content+oldStr, "\r\x00") &&
+			!strings.HasPrefix(content, "\ufeff")
+		if !plain || oldStr == "" || oldStr == newStr {
+			return
+		}
+		switch count := strings.Count(content, oldStr); {
+		case count == 0:
+			if err == nil || !strings.Contains(err.Error(), "not found") {
+				t.Fatalf("old_str is not in the file, got %v, %v", result, err)
+			}
+		case count > 1:
+			if err == nil || !strings.Contains(err.Error(), "found "+strconv.Itoa(count)+" times") {
+				t.Fatalf("old_str is in the file %d times, got %v, %v", count, result, err)
+			}
+		default:
+			if err != nil {
+				t.Fatalf("old_str is in the file once, got %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(toolCtx.Workspace.Root(), "file.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := keepFinalNewline(content, strings.Replace(content, oldStr, newStr, 1))
+			if !bytes.Equal(data, []byte(want)) {
+				t.Fatalf("edited file is %q, want %q", data, want)
+			}
+		}
+	})
+}
+
+func FuzzExcerpt(f *testing.F) {
+	f.Add("short")
+	f.Add(strings.Repeat("long ", 30))
+	f.Add(strings.Repeat("é", 60))
+	f.Add("\xff\xfe invalid")
+	f.Fuzz(func(t *testing.T, s string) {
+		quoted, cut := strings.CutSuffix(excerpt(s), "...")
+		if cut != (len(s) > maxExcerpt) {
+			t.Fatalf("excerpt(%q) = %q, cut %v", s, excerpt(s), cut)
+		}
+		unquoted, err := strconv.Unquote(quoted)
+		if err != nil {
+			t.Fatalf("excerpt(%q) = %q is not a quoted string: %v", s, quoted, err)
+		}
+		if !strings.HasPrefix(s, unquoted) || len(unquoted) > maxExcerpt {
+			t.Fatalf("excerpt(%q) quotes %q, which is not the start of it within %d bytes", s, unquoted, maxExcerpt)
+		}
+	})
+}
+
+func FuzzNotFoundHint(f *testing.F) {
+	f.Add("a\r\nb\nc\n", "a\nb")
+	f.Add("if x {\n\treturn\n}", "if x {\n    return\n}")
+	f.Add("something", "else")
+	f.Fuzz(func(t *testing.T, text, oldStr string) {
+		hint := notFoundHint(text, oldStr)
+		if !strings.HasPrefix(hint, "; ") {
+			t.Fatalf("notFoundHint(%q, %q) = %q does not continue the error message", text, oldStr, hint)
+		}
+		if strings.Contains(toLF(text), toLF(oldStr)) && !strings.Contains(hint, "line endings") {
+			t.Fatalf("notFoundHint(%q, %q) = %q does not mention line endings", text, oldStr, hint)
+		}
+	})
+}
+
+func FuzzMatchLines(f *testing.F) {
+	f.Add("a\nb\na\n", "a")
+	f.Add("x\nx\nx\nx\nx\nx\nx\n", "x\n")
+	f.Add("one\ntwo two\nthree", "two")
+	f.Add("aaaa", "aa")
+	f.Fuzz(func(t *testing.T, text, oldStr string) {
+		count := strings.Count(text, oldStr)
+		if oldStr == "" || count == 0 {
+			return
+		}
+		lines := strings.Split(matchLines(text, oldStr), ", ")
+		if len(lines) != min(count, 5)+boolInt(count > 5) {
+			t.Fatalf("matchLines(%q, %q) = %v for %d matches", text, oldStr, lines, count)
+		}
+		first := 1 + strings.Count(text[:strings.Index(text, oldStr)], "\n")
+		if lines[0] != strconv.Itoa(first) {
+			t.Fatalf("matchLines(%q, %q) starts with line %s, want %d", text, oldStr, lines[0], first)
+		}
+		for i := 1; i < min(count, 5); i++ {
+			previous, _ := strconv.Atoi(lines[i-1])
+			line, err := strconv.Atoi(lines[i])
+			if err != nil || line < previous {
+				t.Fatalf("matchLines(%q, %q) = %v is not in line order", text, oldStr, lines)
+			}
+		}
+	})
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package file
+
+import (
+	"path/filepath"
+	"testing"
+
+	"agent/internal/backup"
+	"agent/internal/tools/toolstest"
+)
+
+// fuzzFiles is the workspace the file tools are fuzzed in, holding the files
+// their usage examples name
+var fuzzFiles = map[string]string{
+	"main.go":           "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+	"server.go":         "package server\n\ntype Server struct{}\n\nfunc (s *Server) Serve() error {\n\treturn nil\n}\n",
+	"config.go":         "package config\n\ntype Config struct {\n\tName string\n}\n",
+	"app.py":            "import os\n\n\ndef main():\n    \"\"\"Run.\"\"\"\n    print(os.getcwd())\n",
+	"config.yml":        "version: 1.0\nname: myapp\n",
+	"data.txt":          "one\ntwo\r\nthree\n",
+	"tests/test_a.txt":  "a\n",
+	"vendor/lib/lib.go": "package lib\n",
+}
+
+func FuzzReadFile(f *testing.F) {
+	toolstest.Seed(f, ReadFileTool{}, `{"path": "data.txt", "offset": -1}`, `{"path": "../outside"}`, `{"path": "tests"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, ReadFileTool{}, toolstest.Context(t, fuzzFiles), input)
+	})
+}
+
+func FuzzListFiles(f *testing.F) {
+	toolstest.Seed(f, ListFilesTool{}, `{"max_depth": 1}`, `{"max_entries": 2, "cursor": "3"}`, `{"include_skipped": true}`, `{"path": "main.go"}`, `{"cursor": "-1"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, ListFilesTool{}, toolstest.Context(t, fuzzFiles), input)
+	})
+}
+
+func FuzzGlobSearch(f *testing.F) {
+	toolstest.Seed(f, GlobSearchTool{}, `{"pattern": "**/*.go"}`, `{"pattern": "["}`, `{"pattern": "/*"}`, `{"pattern": "*", "path": ".."}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, GlobSearchTool{}, toolstest.Context(t, fuzzFiles), input)
+	})
+}
+
+func FuzzWriteFile(f *testing.F) {
+	toolstest.Seed(f, WriteFileTool{}, `{"path": "main.go", "content": "package main\n"}`, `{"path": "tests", "content": "x"}`, `{"path": "../escape.txt", "content": "x"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, WriteFileTool{}, toolstest.Context(t, fuzzFiles), input)
+	})
+}
+
+func FuzzDeleteFile(f *testing.F) {
+	toolstest.Seed(f, DeleteFileTool{}, `{"path": "config.yml"}`, `{"path": "tests"}`, `{"path": "missing.txt"}`, `{"path": "/"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, DeleteFileTool{}, toolstest.Context(t, fuzzFiles), input)
+	})
+}
+
+func FuzzInsertAtAnchor(f *testing.F) {
+	toolstest.Seed(f, InsertAtAnchorTool{},
+		`{"path": "server.go", "anchor": "type", "name": "Server", "position": "start", "content": "\tname string"}`,
+		`{"path": "app.py", "anchor": "function", "name": "main", "position": "end", "content": "    return 0"}`,
+		`{"path": "config.go", "anchor": "function", "name": "Missing", "position": "after", "content": "x"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, InsertAtAnchorTool{}, toolstest.Context(t, fuzzFiles), input)
+	})
+}
+
+func FuzzRestoreBackup(f *testing.F) {
+	toolstest.Seed(f, RestoreBackupTool{}, `{"id": "../../etc/1"}`, `{"id": "/"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolCtx := toolstest.Context(t, fuzzFiles)
+		toolCtx.Backups = backup.NewStore(filepath.Join(t.TempDir(), "backups")).NewSession()
+		if _, err := toolCtx.Backups.Save("", filepath.Join(toolCtx.Workspace.Root(), "main.go"), []byte(fuzzFiles["main.go"]), 0o644, "write"); err != nil {
+			t.Fatal(err)
+		}
+		toolstest.Execute(t, RestoreBackupTool{}, toolCtx, input)
+	})
+}
@@ -1,17 +1,60 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"agent/internal/schema"
 	"agent/internal/tools"
 )
 
+// defaultMaxListEntries is how many entries list_files returns per call
+// unless max_entries says otherwise
+const defaultMaxListEntries = 1000
+
+// skippedDirs are listed but not descended into unless include_skipped is
+// set: they hold version control data and dependencies, often tens of
+// thousands of files the model rarely needs to see
+var skippedDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
 // ListFilesInput represents the input parameters for listing files
 type ListFilesInput struct {
-	Path string `json:"path,omitempty" jsonschema_description:"Optional relative path to list files from. Defaults to current directory if not provided."`
+	Path           string `json:"path,omitempty" jsonschema_description:"Optional relative path to list files from. Defaults to current directory if not provided."`
+	MaxDepth       int    `json:"max_depth,omitempty" jsonschema_description:"How many directory levels to descend: 1 lists only the directory's own entries. Unlimited if not provided."`
+	MaxEntries     int    `json:"max_entries,omitempty" jsonschema_description:"Maximum number of entries to return (default 1000). When there are more, the result ends with a cursor for the next page."`
+	Cursor         string `json:"cursor,omitempty" jsonschema_description:"Cursor from a previous call's result, to continue the listing where it stopped."`
+	IncludeSkipped bool   `json:"include_skipped,omitempty" jsonschema_description:"Also descend into .git, node_modules and vendor directories, which are otherwise listed but not expanded."`
+}
+
+// Validate implements input validation
+func (l *ListFilesInput) Validate() error {
+	if l.MaxDepth < 0 {
+		return fmt.Errorf("max_depth must not be negative, not %d", l.MaxDepth)
+	}
+	if l.MaxEntries < 0 {
+		return fmt.Errorf("max_entries must not be negative, not %d", l.MaxEntries)
+	}
+	if _, err := l.offset(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// offset is the number of entries earlier pages returned
+func (l *ListFilesInput) offset() (int, error) {
+	if l.Cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(l.Cursor)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q; pass the cursor of a previous list_files result", l.Cursor)
+	}
+	return offset, nil
 }
 
 // ListFilesTool implements the file listing functionality
@@ -20,18 +63,32 @@ type ListFilesTool struct{}
 // Definition returns the tool definition for the list files tool
 func (t ListFilesTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name:        "list_files",
-		Description: "List files and directories at a given path. If no path is provided, lists files in the current directory.",
+		Name:      "list_files",
+		Group:     "file",
+		Cacheable: true,
+		Description: `List files and directories at a given path. If no path is provided, lists files in the current directory.
+
+Directories end with "/". .git, node_modules and vendor are listed but not expanded unless include_skipped is set.
+Large trees are returned a page at a time: when a result ends with a cursor, pass it back to get the next page.
+Use max_depth to see only the top levels of a large repository.`,
 		InputSchema: schema.GenerateSchema[ListFilesInput](),
 	}
 }
 
 // Execute performs the file listing operation
-func (t ListFilesTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t ListFilesTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	var listFilesInput ListFilesInput
-	err := json.Unmarshal(input, &listFilesInput)
+	err := tools.DecodeInput(input, &listFilesInput)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
+	}
+	if err := listFilesInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	offset, _ := listFilesInput.offset()
+	maxEntries := listFilesInput.MaxEntries
+	if maxEntries == 0 {
+		maxEntries = defaultMaxListEntries
 	}
 
 	dir := "."
@@ -39,37 +96,92 @@ func (t ListFilesTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (s
 		dir = listFilesInput.Path
 	}
 
+	dir, shown, err := resolveDir(toolCtx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := fileSystem(toolCtx).Stat(dir); err == nil && !info.IsDir() {
+		return nil, tools.InvalidInput(fmt.Errorf("%s is a file, not a directory; use read_file to see its content", shown))
+	}
+
 	var files []string
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	var dirs []string // Directory mtimes change whenever entries are added, removed or renamed
+	seen, more := 0, false
+	err = fileSystem(toolCtx).Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		// Stop walking large trees as soon as the call is cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		relPath, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
 
+		// Hide protected and ignored paths from listings. Directories are
+		// skipped only when nothing inside them is accessible; otherwise
+		// their files are checked one by one.
+		if toolCtx != nil && toolCtx.Workspace != nil {
+			if info.IsDir() && (toolCtx.Workspace.CheckDirAccess(path) != nil || toolCtx.Workspace.Ignored(path, true)) {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && (toolCtx.Workspace.CheckAccess(path) != nil || toolCtx.Workspace.Ignored(path, false)) {
+				return nil
+			}
+		}
+
+		// Entries are counted even on pages before the cursor, so every
+		// page walks the same tree in the same order
 		if relPath != "." {
-			if info.IsDir() {
-				files = append(files, relPath+"/")
-			} else {
-				files = append(files, relPath)
+			if seen == offset+maxEntries {
+				more = true
+				return filepath.SkipAll
 			}
+			if seen >= offset {
+				entry := relPath
+				if info.IsDir() {
+					entry += "/"
+				}
+				files = append(files, entry)
+			}
+			seen++
+		}
+
+		if info.IsDir() {
+			expand := relPath == "." || ((listFilesInput.MaxDepth == 0 || depth(relPath) < listFilesInput.MaxDepth) &&
+				(listFilesInput.IncludeSkipped || !skippedDirs[info.Name()]))
+			if !expand {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
 		}
 		return nil
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	result, err := json.Marshal(files)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	text := string(result)
+	if more {
+		text += fmt.Sprintf("\n\nEntries %d to %d shown; there are more. Call list_files again with cursor %q for the next page, or narrow the listing with path or max_depth.",
+			offset+1, offset+len(files), strconv.Itoa(offset+len(files)))
+	}
+
+	return tools.NewTextResult(text).WithSources(dirs...), nil
+}
 
-	return string(result), nil
+// depth is the number of directory levels a relative path is below the
+// listed directory: 1 for its own entries
+func depth(relPath string) int {
+	return strings.Count(relPath, string(filepath.Separator)) + 1
 }
 
 func init() {
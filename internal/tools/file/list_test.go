@@ -0,0 +1,49 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"agent/internal/config"
+	"agent/internal/permissions"
+	"agent/internal/tools"
+	"agent/internal/tools/toolstest"
+)
+
+// TestListAndSearchUnderAllowRule starts a listing and a search from a
+// directory that no allow rule matches but which holds files one does
+func TestListAndSearchUnderAllowRule(t *testing.T) {
+	toolCtx := toolstest.Context(t, map[string]string{
+		"docs/api/index.md": "# API\n",
+		"docs/notes.txt":    "notes\n",
+		"main.go":           "package main\n",
+	})
+	rules, err := permissions.NewPathRules(config.PathsConfig{Allow: []string{"docs/api/*.md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolCtx.Workspace.SetPathChecker(rules)
+
+	tests := []struct {
+		tool  tools.Tool
+		input string
+	}{
+		{ListFilesTool{}, `{"path": "docs"}`},
+		{GlobSearchTool{}, `{"pattern": "**/*", "path": "docs"}`},
+	}
+	for _, tt := range tests {
+		result, err := toolstest.Execute(t, tt.tool, toolCtx, tt.input)
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.tool.Definition().Name, tt.input, err)
+			continue
+		}
+		text := result.Text()
+		if !strings.Contains(text, "index.md") || strings.Contains(text, "notes.txt") {
+			t.Errorf("%s %s = %s, want index.md without notes.txt", tt.tool.Definition().Name, tt.input, text)
+		}
+	}
+
+	if _, err := toolstest.Execute(t, ListFilesTool{}, toolCtx, `{"path": "src"}`); err == nil {
+		t.Errorf("listing a directory nothing in which is allowed succeeded")
+	}
+}
@@ -0,0 +1,534 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"agent/internal/tools"
+)
+
+// outline is a lightweight structural view of a source file, enough to
+// find its functions, types and imports without a parser for its language.
+// Strings and comments are blanked out of the code, so keywords and
+// brackets in them are not taken for code. Line numbers are 0-based
+// indexes into lines.
+type outline struct {
+	lines    []string // As in the file
+	code     []string // The lines with comments and the contents of strings replaced by spaces
+	inString []bool   // Whether the line starts inside a string spanning lines
+}
+
+// hashCommentExts are the extensions of languages whose comments start
+// with #; the others are taken to use // and /* */
+var hashCommentExts = map[string]bool{
+	".py": true, ".pyi": true, ".rb": true, ".sh": true, ".bash": true, ".zsh": true,
+	".pl": true, ".pm": true, ".r": true, ".jl": true, ".ex": true, ".exs": true,
+	".nim": true, ".cr": true, ".tcl": true, ".ps1": true, ".tf": true,
+	".yml": true, ".yaml": true, ".toml": true,
+}
+
+func newOutline(path string, lines []string) *outline {
+	o := &outline{lines: lines, code: make([]string, len(lines)), inString: make([]bool, len(lines))}
+	ext := strings.ToLower(filepath.Ext(path))
+	hashComments := hashCommentExts[ext]
+
+	closer, comment := "", false // What ends the string or block comment a line ends inside
+	for i, line := range lines {
+		o.inString[i] = closer != "" && !comment
+		code := []byte(line)
+		for j := 0; j < len(line); {
+			if closer != "" {
+				end := strings.Index(line[j:], closer)
+				if end < 0 {
+					blank(code, j, len(line))
+					break
+				}
+				end += j
+				if comment {
+					blank(code, j, end+len(closer))
+				} else {
+					blank(code, j, end)
+				}
+				j, closer, comment = end+len(closer), "", false
+				continue
+			}
+
+			rest := line[j:]
+			switch {
+			case hashComments && rest[0] == '#', !hashComments && strings.HasPrefix(rest, "//"):
+				blank(code, j, len(line))
+				j = len(line)
+			case !hashComments && strings.HasPrefix(rest, "/*"):
+				blank(code, j, j+2)
+				j, closer, comment = j+2, "*/", true
+			case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, "'''"):
+				j, closer = j+3, rest[:3]
+			case rest[0] == '`':
+				j, closer = j+1, "`"
+			case rest[0] == '"', rest[0] == '\'':
+				end := closingQuote(line, j)
+				if ext == ".rs" && rest[0] == '\'' && !rustChar.MatchString(rest) {
+					end = -1
+				}
+				switch {
+				case end >= 0:
+					blank(code, j+1, end)
+					j = end + 1
+				case rest[0] == '"':
+					// Taken to end with the line, as in languages without strings spanning lines
+					blank(code, j+1, len(line))
+					j = len(line)
+				default:
+					// A lifetime, or an apostrophe outside code
+					j++
+				}
+			default:
+				j++
+			}
+		}
+		o.code[i] = string(code)
+	}
+	return o
+}
+
+// rustChar matches a Rust character literal, which a quote starting
+// anything else, a lifetime such as 'a, is not
+var rustChar = regexp.MustCompile(`^'(?:[^'\\]|\\[^']*)'`)
+
+// blank replaces code[from:to] with spaces
+func blank(code []byte, from, to int) {
+	for i := from; i < to && i < len(code); i++ {
+		if code[i] != '\t' {
+			code[i] = ' '
+		}
+	}
+}
+
+// closingQuote returns the index of the quote ending the string that
+// starts at line[start], or -1 if it does not end on the line
+func closingQuote(line string, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case line[start]:
+			return i
+		}
+	}
+	return -1
+}
+
+const (
+	kindFunction = "function"
+	kindType     = "type"
+)
+
+// identifier matches any name, to list the definitions in a file
+const identifier = `[A-Za-z_$][\w$]*`
+
+// Patterns of definition headers, with %[1]s for the name. The first group
+// is a Go method's receiver or a type's keyword, the second the name.
+var (
+	functionPatterns = []string{
+		// func, def, fn, function and the like: Go (with a receiver), Python, Rust, JavaScript, Kotlin, PHP, Perl
+		`(?:^|[^\w.$])(?:func|def|fn|function|fun|sub|proc)\b\s*(?:\*\s*)?(\([^)]*\)\s*)?(%[1]s)\s*[(<\[]`,
+		// Functions assigned to a name or property in JavaScript
+		`(?:^|[^\w.$])()(%[1]s)\s*[:=]\s*(?:async\s+)?(?:function\b|(?:\([^)]*\)|[\w$]+)\s*=>)`,
+	}
+	// Functions and methods of C-like languages, named after their return
+	// type and modifiers, if any; only taken as definitions with a braced body
+	cLikeFunctionPattern = `^\s*((?:[\w$.<>\[\],?*&:]+\s+)*)[*&]*(%[1]s)\s*\(`
+	typePatterns         = []string{
+		`(?:^|[^\w.$])(type|struct|class|interface|enum|trait|union|record|object|protocol|impl|module|namespace)\s+(%[1]s)\b`,
+	}
+)
+
+// controlWords start statements that look like C-like function headers
+var controlWords = []string{
+	"if", "for", "while", "switch", "catch", "return", "new", "throw", "await", "yield",
+	"else", "case", "typeof", "delete", "go", "defer", "do", "try", "sizeof", "elif",
+	"with", "assert", "foreach", "using", "lock", "synchronized", "match", "when",
+}
+
+// maxHeaderLines is how far a definition's body may open below its name
+const maxHeaderLines = 20
+
+// definition is a function or type in an outline
+type definition struct {
+	name      string
+	qualifier string // A Go method's receiver, with its parentheses, or a type's keyword
+	start     int    // First line, including the comments, decorators and attributes right above the header
+	header    int    // Line of the name
+	open      int    // Line the body opens on: its "{", or the ":" ending a Python header; -1 without a body
+	end       int    // Last line: the closing brace's, or the last indented line of the body
+	braced    bool
+	balanced  bool // Whether the end of a braced body was found
+}
+
+// definitions finds the functions or types named name, or all of them when
+// name is empty
+func (o *outline) definitions(kind, name string) []definition {
+	nameExpr := identifier
+	if name != "" {
+		nameExpr = regexp.QuoteMeta(name)
+	}
+	var patterns []*regexp.Regexp
+	sources := typePatterns
+	if kind == kindFunction {
+		sources = functionPatterns
+	}
+	for _, source := range sources {
+		patterns = append(patterns, regexp.MustCompile(fmt.Sprintf(source, nameExpr)))
+	}
+	var cLike *regexp.Regexp
+	if kind == kindFunction {
+		cLike = regexp.MustCompile(fmt.Sprintf(cLikeFunctionPattern, nameExpr))
+	}
+
+	var found []definition
+	for i, code := range o.code {
+		if strings.TrimSpace(code) == "" {
+			continue
+		}
+		d, ok := o.match(i, patterns)
+		if !ok && cLike != nil {
+			d, ok = o.matchCLike(i, cLike)
+		}
+		if ok {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// match returns the definition whose header is on line i, if one of
+// patterns finds one there
+func (o *outline) match(i int, patterns []*regexp.Regexp) (definition, bool) {
+	for _, pattern := range patterns {
+		m := pattern.FindStringSubmatchIndex(o.code[i])
+		if m == nil {
+			continue
+		}
+		d := o.definition(i, m[4], m[5])
+		if m[2] >= 0 {
+			d.qualifier = o.code[i][m[2]:m[3]]
+		}
+		return d, true
+	}
+	return definition{}, false
+}
+
+// matchCLike returns the definition whose header is on line i if it looks
+// like a C-like function header and is followed by a braced body
+func (o *outline) matchCLike(i int, pattern *regexp.Regexp) (definition, bool) {
+	m := pattern.FindStringSubmatchIndex(o.code[i])
+	if m == nil {
+		return definition{}, false
+	}
+	name := o.code[i][m[4]:m[5]]
+	words := strings.Fields(o.code[i][m[2]:m[3]])
+	if slices.Contains(controlWords, name) || len(words) > 0 && slices.Contains(controlWords, words[0]) {
+		return definition{}, false
+	}
+	d := o.definition(i, m[4], m[5])
+	if !d.braced || !d.balanced {
+		return definition{}, false
+	}
+	// Calls passing a function literal open a brace too, but not right
+	// after the parameters
+	header := strings.Join(append([]string{o.code[i][m[5]:]}, o.code[i+1:d.open+1]...), " ")
+	between, _, _ := strings.Cut(afterParameters(header), "{")
+	if !headerTail.MatchString(between) {
+		return definition{}, false
+	}
+	return d, true
+}
+
+// headerTail matches what may come between a C-like function's parameters
+// and its body: qualifiers, a throws clause or a return type
+var headerTail = regexp.MustCompile(`^\s*(?:(?:const|override|final|noexcept|async|throws\s+[\w.,\s]+|->\s*[\w.<>\[\]&*:]+|:\s*[\w.<>\[\]|?, ]+)\s*)*$`)
+
+// afterParameters returns what follows the parenthesized parameter list
+// that text starts with
+func afterParameters(text string) string {
+	depth := 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return text[i+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// definition measures the definition named by line[from:to] of line header
+func (o *outline) definition(header, from, to int) definition {
+	d := definition{name: o.code[header][from:to], start: o.preamble(header), header: header, open: -1, end: header}
+	depth := 0 // Of parentheses and square brackets
+	for i := header; i < len(o.code) && i < header+maxHeaderLines; i++ {
+		code := o.code[i]
+		j := 0
+		if i == header {
+			j = to
+		}
+		for ; j < len(code); j++ {
+			switch code[j] {
+			case '(', '[':
+				depth++
+			case ')', ']':
+				depth--
+			case '{':
+				if depth == 0 {
+					d.open, d.braced = i, true
+					d.end, d.balanced = o.closingBrace(i, j)
+					return d
+				}
+			case ';':
+				if depth == 0 {
+					d.end = i
+					return d
+				}
+			}
+		}
+
+		trimmed := strings.TrimSpace(code)
+		switch {
+		case depth > 0, strings.HasSuffix(trimmed, `\`), strings.HasSuffix(trimmed, ","):
+			continue
+		case strings.HasSuffix(trimmed, ":"):
+			d.open, d.end = i, o.indentedEnd(header, i)
+			return d
+		}
+		if next := o.nextCode(i); next >= 0 && continuesHeader(strings.TrimSpace(o.code[next])) {
+			continue
+		}
+		d.end = i
+		return d
+	}
+	return d
+}
+
+// continuesHeader reports whether a line carries on a definition header
+// from the line before: a brace on its own line, a throws or where
+// clause, a return type or a constructor's initializer list
+func continuesHeader(line string) bool {
+	for _, prefix := range []string{"{", "throws ", "where ", "->", ":"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// closingBrace returns the line of the brace closing the one at column col
+// of line, and false if it is never closed
+func (o *outline) closingBrace(line, col int) (int, bool) {
+	depth := 0
+	for i := line; i < len(o.code); i++ {
+		j := 0
+		if i == line {
+			j = col
+		}
+		for ; j < len(o.code[i]); j++ {
+			switch o.code[i][j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return i, true
+				}
+			}
+		}
+	}
+	return len(o.code) - 1, false
+}
+
+// indentedEnd returns the last line of the body below line colon that is
+// indented further than line header, as Python bodies are
+func (o *outline) indentedEnd(header, colon int) int {
+	indent := indentation(o.lines[header])
+	end := colon
+	for i := colon + 1; i < len(o.lines); i++ {
+		if o.inString[i] {
+			end = i
+			continue
+		}
+		if strings.TrimSpace(o.code[i]) == "" {
+			continue
+		}
+		if indentation(o.lines[i]) <= indent {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// preamble returns the first of the comment, decorator and attribute lines
+// right above line header, or header if there are none
+func (o *outline) preamble(header int) int {
+	start := header
+	for i := header - 1; i >= 0; i-- {
+		code := strings.TrimSpace(o.code[i])
+		switch {
+		case strings.TrimSpace(o.lines[i]) == "", o.inString[i]:
+			return start
+		case code == "", strings.HasPrefix(code, "@"), strings.HasPrefix(code, "#["),
+			strings.HasPrefix(code, "[") && strings.HasSuffix(code, "]"):
+			start = i
+		default:
+			return start
+		}
+	}
+	return start
+}
+
+// nextCode returns the first line after i with code on it, or -1
+func (o *outline) nextCode(i int) int {
+	for i++; i < len(o.code); i++ {
+		if strings.TrimSpace(o.code[i]) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup finds the one function or type named name. A method can be named
+// with its type, as Type.Method: one declared in the type's body, or a Go
+// method on the type.
+func (o *outline) lookup(path, kind, name string) (definition, error) {
+	var found []definition
+	if typeName, method, ok := strings.Cut(name, "."); ok && kind == kindFunction {
+		methods := o.definitions(kindFunction, method)
+		receiver := regexp.MustCompile(`\b` + regexp.QuoteMeta(typeName) + `\b`)
+		for _, d := range methods {
+			if receiver.MatchString(d.qualifier) {
+				found = append(found, d)
+			}
+		}
+		for _, t := range o.definitions(kindType, typeName) {
+			for _, d := range methods {
+				if t.open >= 0 && d.header > t.open && d.header <= t.end {
+					found = append(found, d)
+				}
+			}
+		}
+	} else {
+		found = o.definitions(kind, name)
+	}
+	if kind == kindType && len(found) > 1 {
+		// A Rust type's impl blocks are found by its name too, but the
+		// type is its struct, enum or trait
+		declared := slices.DeleteFunc(slices.Clone(found), func(d definition) bool { return d.qualifier == "impl" })
+		if len(declared) > 0 {
+			found = declared
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return definition{}, tools.NotFound(fmt.Errorf("no %s %s found in %s; %s", kind, name, path, o.known(kind)))
+	case 1:
+		if found[0].braced && !found[0].balanced {
+			return definition{}, fmt.Errorf("the braces of %s %s in %s do not balance, so its end cannot be found; use edit_file", kind, name, path)
+		}
+		return found[0], nil
+	}
+	lines := make([]string, len(found))
+	for i, d := range found {
+		lines[i] = strconv.Itoa(d.header + 1)
+	}
+	hint := "use edit_file to insert next to one of them"
+	if kind == kindFunction && !strings.Contains(name, ".") {
+		hint = fmt.Sprintf("name a method with its type, as Type.%s, or use edit_file", name)
+	}
+	return definition{}, fmt.Errorf("%s %s is defined %d times in %s, on lines %s; %s", kind, name, len(found), path, strings.Join(lines, ", "), hint)
+}
+
+// maxKnown is how many names known lists
+const maxKnown = 30
+
+// known lists the names of a kind of definition, for an anchor that was
+// not found
+func (o *outline) known(kind string) string {
+	var names []string
+	for _, d := range o.definitions(kind, "") {
+		if !slices.Contains(names, d.name) {
+			names = append(names, d.name)
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return fmt.Sprintf("no %s definitions were recognized in the file, so use edit_file", kind)
+	case len(names) > maxKnown:
+		names = append(names[:maxKnown], "...")
+	}
+	return fmt.Sprintf("the %ss found are %s", kind, strings.Join(names, ", "))
+}
+
+// importPattern matches the first line of an import statement that is not
+// indented: Go, Python, JavaScript, Java and the like, Rust and PHP use, C
+// includes, C# using directives and CommonJS requires
+var importPattern = regexp.MustCompile(`^(?:import\b|from\s+\S+\s+import\b|(?:pub(?:\([^)]*\))?\s+)?use\s+\S|#\s*include\b|using\s+[\w.]+(?:\s*=\s*[\w.]+)?\s*;|(?:const|let|var)\s+[^=]+=\s*require\s*\()`)
+
+// importStatement is an import in an outline, which may span lines
+type importStatement struct {
+	start, end int
+	block      bool // A Go import block, with one import per line
+}
+
+// imports finds the import statements above the first definition
+func (o *outline) imports() []importStatement {
+	limit := len(o.code)
+	for _, kind := range []string{kindFunction, kindType} {
+		if found := o.definitions(kind, ""); len(found) > 0 {
+			limit = min(limit, found[0].start)
+		}
+	}
+
+	var imports []importStatement
+	for i := 0; i < limit; i++ {
+		if !importPattern.MatchString(o.code[i]) {
+			continue
+		}
+		end := o.statementEnd(i)
+		imports = append(imports, importStatement{start: i, end: end, block: strings.TrimSpace(o.code[i]) == "import ("})
+		i = end
+	}
+	return imports
+}
+
+// statementEnd returns the last line of the statement starting on line
+// start, which ends where its brackets are closed
+func (o *outline) statementEnd(start int) int {
+	depth := 0
+	for i := start; i < len(o.code); i++ {
+		for _, c := range o.code[i] {
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			}
+		}
+		if depth <= 0 && !strings.HasSuffix(strings.TrimSpace(o.code[i]), `\`) {
+			return i
+		}
+	}
+	return len(o.code) - 1
+}
+
+// indentation is the width of a line's leading whitespace
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
@@ -0,0 +1,127 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agent/internal/confirm"
+	"agent/internal/filecache"
+	"agent/internal/filestate"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants for concurrent modification checks
+const (
+	errMsgChangedUnderneath = "%s was modified by something else while this change was pending; read it again and retry"
+	errMsgChangedOnDisk     = "%s changed on disk since you last read it, probably in an editor outside this session; read it again and make the change to its current content, so those changes are not overwritten"
+)
+
+// resolvePath maps a tool path through the workspace jail when one is
+// configured, asking the user first when the path policy says to. shown is
+// the canonical form of the path, which results name the file by.
+func resolvePath(toolCtx *tools.ToolContext, path string) (resolved, shown string, err error) {
+	if toolCtx == nil || toolCtx.Workspace == nil {
+		return path, filepath.Clean(path), nil
+	}
+	return confirmPath(toolCtx, path, toolCtx.Workspace.ResolvePath)
+}
+
+// resolveDir is resolvePath for the directory a listing or search starts
+// from, which path rules only reject when nothing inside it is accessible
+func resolveDir(toolCtx *tools.ToolContext, dir string) (resolved, shown string, err error) {
+	if toolCtx == nil || toolCtx.Workspace == nil {
+		return dir, filepath.Clean(dir), nil
+	}
+	return confirmPath(toolCtx, dir, toolCtx.Workspace.ResolveDir)
+}
+
+// confirmPath resolves path and asks the user about it when the path
+// policy says to
+func confirmPath(toolCtx *tools.ToolContext, path string, resolve func(string) (workspace.Path, error)) (resolved, shown string, err error) {
+	p, err := resolve(path)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: policyActions[p.Ask], Path: p.Abs}) {
+		return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+	}
+	return p.Abs, p.Shown, nil
+}
+
+// policyActions describe the accesses the path policy asks about
+var policyActions = map[string]string{
+	workspace.AskAbsolute: "use an absolute path",
+	workspace.AskOutside:  "access a path outside the workspace",
+}
+
+// fileSystem returns the file system of the tool call's workspace, the
+// local one when there is none
+func fileSystem(toolCtx *tools.ToolContext) workspace.FS {
+	if toolCtx == nil {
+		return workspace.Local
+	}
+	return toolCtx.Workspace.FS()
+}
+
+// fileCache returns the tool call's cache of file contents, nil when there
+// is none
+func fileCache(toolCtx *tools.ToolContext) *filecache.Cache {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Files
+}
+
+// fileVersions returns the tool call's record of the file versions the
+// agent has seen, nil when there is none
+func fileVersions(toolCtx *tools.ToolContext) *filestate.Tracker {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Seen
+}
+
+// ensureSeen reports an error if the file at resolvedPath, which holds
+// content now, is not the version the agent last read or wrote: the
+// model's idea of it is out of date, and changing it would undo whatever
+// changed it
+func ensureSeen(toolCtx *tools.ToolContext, fsys workspace.FS, path, resolvedPath string, content []byte) error {
+	seen := fileVersions(toolCtx)
+	if seen == nil {
+		return nil
+	}
+	info, err := fsys.Stat(resolvedPath)
+	if err != nil {
+		return err
+	}
+	if seen.Changed(resolvedPath, info, content) {
+		return fmt.Errorf(errMsgChangedOnDisk, path)
+	}
+	return nil
+}
+
+// lockPath serializes modifications of a resolved path with other tool calls
+func lockPath(toolCtx *tools.ToolContext, resolvedPath string) (unlock func()) {
+	if toolCtx == nil {
+		return func() {}
+	}
+	return toolCtx.Locks.Lock(resolvedPath)
+}
+
+// ensureUnchanged reports an error if the file no longer has the content
+// (or existence) it had when the tool first read it. Locks only serialize
+// tool calls, so this catches editors and commands outside the agent.
+func ensureUnchanged(fsys workspace.FS, path, resolvedPath string, before []byte, existed bool) error {
+	current, err := fsys.ReadFile(resolvedPath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if exists != existed || !bytes.Equal(current, before) {
+		return fmt.Errorf(errMsgChangedUnderneath, path)
+	}
+	return nil
+}
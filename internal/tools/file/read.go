@@ -2,11 +2,15 @@ package file
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"strings"
 
+	"agent/internal/charset"
 	"agent/internal/schema"
 	"agent/internal/tools"
 )
@@ -18,12 +22,36 @@ const (
 	errMsgInvalidOffset  = "offset must be >= %d (line numbers are 1-based)"
 	errMsgInvalidLimit   = "limit must be >= %d"
 	errMsgOffsetTooLarge = "offset %d exceeds file length (%d lines)"
+	errMsgFileTooLarge   = "%s is %d bytes, more than read_file returns at once (%d bytes, limits.max_read_bytes); read it in parts with offset and limit"
+	errMsgRangeTooLarge  = "the lines asked for from %s are more than %d bytes (limits.max_read_bytes); ask for fewer with limit"
+	errMsgNotUTF8TooLong = "%s is %d bytes of %s, which read_file converts whole, and that is more than it reads at once (%d bytes, limits.max_read_bytes); convert it to UTF-8 or inspect it with a command"
 )
 
+// defaultMaxReadBytes is the cap on what read_file returns at once when
+// limits.max_read_bytes is unset
+const defaultMaxReadBytes = 256 * 1024
+
+// sniffBytes is how much of a file is looked at to detect its encoding
+// before its lines are streamed
+const sniffBytes = 4096
+
+// maxReadBytes is the cap in effect; see SetMaxReadBytes
+var maxReadBytes = defaultMaxReadBytes
+
+// SetMaxReadBytes sets the largest file read_file returns whole, from
+// limits.max_read_bytes; 0 restores the default. Call it before tools run.
+func SetMaxReadBytes(n int) {
+	if n <= 0 {
+		n = defaultMaxReadBytes
+	}
+	maxReadBytes = n
+}
+
 type ReadFileInput struct {
 	Path   string `json:"path" jsonschema:"required" jsonschema_description:"The relative path of a file in the working directory."`
 	Offset *int   `json:"offset,omitempty" jsonschema_description:"Starting line number (1-based). If provided, only reads from this line onwards."`
 	Limit  *int   `json:"limit,omitempty" jsonschema_description:"Maximum number of lines to read. If provided with offset, reads this many lines from the offset."`
+	Full   bool   `json:"full,omitempty" jsonschema_description:"Return the whole content even if it was read before in this session. Otherwise a re-read of content that changed returns only a diff against the earlier read."`
 }
 
 // Validate implements input validation
@@ -74,7 +102,10 @@ type ReadFileTool struct{}
 
 func (t ReadFileTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name: "read_file",
+		Name:      "read_file",
+		Group:     "file",
+		Cacheable: true,
+		Diffable:  true,
 		Description: `Read the contents of a file with optional line range support.
 
 Usage Examples:
@@ -86,37 +117,206 @@ Parameters:
 - path: File path to read (required)
 - offset: Starting line number (1-based, optional)
 - limit: Maximum number of lines to read (optional)
+- full: Return the whole content even if it was read before (optional)
 
 Note: Line numbers are 1-based. Use this when you want to see what's inside a file.
-Do not use this with directory names.`,
+Do not use this with directory names. Large files cannot be read whole; read them in parts with offset and limit.
+Reading the same file and range again returns only a diff against the content you were given last time, or a note that it is unchanged.
+Set full when you no longer have that content.`,
 		InputSchema: schema.GenerateSchema[ReadFileInput](),
 	}
 }
 
-func (t ReadFileTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t ReadFileTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	readInput, err := t.parseAndValidateInput(input)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
 	}
 
-	content, err := os.ReadFile(readInput.Path)
+	path, shown, err := resolvePath(toolCtx, readInput.Path)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	fsys := fileSystem(toolCtx)
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return nil, err
 	}
 
 	// If no offset/limit specified, return full content (backward compatibility)
 	if readInput.Offset == nil && readInput.Limit == nil {
-		return string(content), nil
+		if info.Size() > int64(maxReadBytes) {
+			return nil, fmt.Errorf(errMsgFileTooLarge, shown, info.Size(), maxReadBytes)
+		}
+		data, err := fileCache(toolCtx).ReadFile(fsys, path, info)
+		if err != nil {
+			return nil, err
+		}
+		content, _, err := decodeText(shown, data)
+		if err != nil {
+			return nil, err
+		}
+		fileVersions(toolCtx).SawContent(path, data)
+		return tools.NewTextResult(content).WithSources(path), nil
 	}
 
-	return t.extractLines(string(content), readInput)
+	// Files within the cap are read whole, and cached for the next read;
+	// larger ones are streamed
+	var file io.Reader
+	var data []byte
+	if info.Size() <= int64(maxReadBytes) {
+		if data, err = fileCache(toolCtx).ReadFile(fsys, path, info); err != nil {
+			return nil, err
+		}
+		file = bytes.NewReader(data)
+	} else {
+		opened, err := fsys.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer opened.Close()
+		file = opened
+	}
+	lines, err := t.readRange(file, shown, info.Size(), readInput)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		fileVersions(toolCtx).SawContent(path, data)
+	} else {
+		fileVersions(toolCtx).SawStat(path, info)
+	}
+	return tools.NewTextResult(lines).WithSources(path), nil
+}
+
+// readRange reads the lines offset and limit ask for, streaming the file
+// and stopping after the last of them, so a few lines of a large file are
+// read without loading all of it. Files not in UTF-8 are converted whole,
+// and are held to the size cap.
+func (t ReadFileTool) readRange(file io.Reader, path string, size int64, input *ReadFileInput) (string, error) {
+	reader := bufio.NewReaderSize(file, sniffBytes)
+
+	prefix, err := reader.Peek(sniffBytes)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch encoding := charset.DetectPrefix(prefix); encoding {
+	case charset.UTF8:
+	case charset.UTF8BOM:
+		reader.Discard(len("\uFEFF"))
+	default:
+		if size > int64(maxReadBytes) {
+			return "", fmt.Errorf(errMsgNotUTF8TooLong, path, size, encoding, maxReadBytes)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		content, _, err := decodeText(path, data)
+		if err != nil {
+			return "", err
+		}
+		return t.extractLines(content, input)
+	}
+
+	start, end := 0, -1 // Line indexes, 0-based; end is exclusive and -1 reads to the end
+	if input.Offset != nil {
+		start = *input.Offset - 1
+	}
+	if input.Limit != nil {
+		end = start + *input.Limit
+	}
+
+	var selected []string
+	count, budget := 0, maxReadBytes
+	for end < 0 || count < end {
+		var line []byte
+		var ok bool
+		if count < start {
+			ok, err = skipLine(reader)
+		} else {
+			line, ok, err = readLine(reader, budget)
+		}
+		if err != nil {
+			if errors.Is(err, errLineBudget) {
+				return "", fmt.Errorf(errMsgRangeTooLarge, path, maxReadBytes)
+			}
+			return "", err
+		}
+		if !ok {
+			break
+		}
+		if count >= start {
+			selected = append(selected, string(line))
+			budget -= len(line) + 1
+		}
+		count++
+	}
+
+	// Nothing is selected only when the offset is past the end, and then
+	// the whole file has been counted
+	if input.Offset != nil && len(selected) == 0 {
+		return "", fmt.Errorf(errMsgOffsetTooLarge, *input.Offset, count)
+	}
+	return strings.Join(selected, "\n"), nil
+}
+
+// errLineBudget is returned by readLine for lines longer than its budget
+var errLineBudget = errors.New("line longer than the budget")
+
+// readLine reads the next line without its line ending, as bufio.ScanLines
+// splits them, failing once it is longer than budget. ok is false at the
+// end of the file.
+func readLine(reader *bufio.Reader, budget int) (line []byte, ok bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(bytes.TrimRight(line, "\r\n")) > budget {
+			return nil, true, errLineBudget
+		}
+		switch err {
+		case nil:
+			return trimLineEnding(line), true, nil
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			return trimLineEnding(line), len(line) > 0, nil
+		default:
+			return nil, false, err
+		}
+	}
+}
+
+// skipLine reads past the next line without keeping it
+func skipLine(reader *bufio.Reader) (ok bool, err error) {
+	read := 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		read += len(chunk)
+		switch err {
+		case nil:
+			return true, nil
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			return read > 0, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+func trimLineEnding(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
 }
 
 // Helper methods for better separation of concerns
 func (t ReadFileTool) parseAndValidateInput(input json.RawMessage) (*ReadFileInput, error) {
 	var readInput ReadFileInput
-	if err := json.Unmarshal(input, &readInput); err != nil {
-		return nil, fmt.Errorf("invalid JSON input: %w", err)
+	if err := tools.DecodeInput(input, &readInput); err != nil {
+		return nil, err
 	}
 
 	if err := readInput.Validate(); err != nil {
@@ -0,0 +1,189 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/internal/backup"
+	"agent/internal/confirm"
+	"agent/internal/diff"
+	"agent/internal/schema"
+	"agent/internal/tools"
+)
+
+// RestoreBackupInput names the backup to restore
+type RestoreBackupInput struct {
+	ID string `json:"id,omitempty" jsonschema_description:"ID of the backup to restore, as given when the file was overwritten or deleted. Omit it to list this session's backups."`
+}
+
+// RestoreBackupTool puts back a file write or delete_file backed up before
+// changing it
+type RestoreBackupTool struct{}
+
+func (t RestoreBackupTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "restore_backup",
+		Group:    "file",
+		Mutating: true,
+		Confirms: true,
+		Paths:    restorePaths,
+		Description: `Restore a file from the backup taken before write overwrote it or delete_file deleted it.
+
+Usage Examples:
+- {} // List this session's backups, most recent first
+- {"id": "20240501-093000-ab12/3"} // Put the file back as it was
+
+The file's current content, if any, is backed up in turn, so a restore can itself be undone.`,
+		InputSchema: schema.GenerateSchema[RestoreBackupInput](),
+	}
+}
+
+// restorePaths returns the path of the file a restore writes back, which
+// the call names only by backup ID
+func restorePaths(toolCtx *tools.ToolContext, input json.RawMessage) []string {
+	var restoreInput RestoreBackupInput
+	_ = json.Unmarshal(input, &restoreInput)
+	if restoreInput.ID == "" || toolCtx == nil || toolCtx.Backups == nil {
+		return nil
+	}
+	entry, _, err := toolCtx.Backups.Store().Load(restoreInput.ID)
+	if err != nil {
+		return nil
+	}
+	return []string{entry.Path}
+}
+
+func (t RestoreBackupTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var restoreInput RestoreBackupInput
+	if err := tools.DecodeInput(input, &restoreInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if toolCtx == nil || toolCtx.Backups == nil {
+		return nil, fmt.Errorf("backups are off in this session (backups.enabled is false)")
+	}
+	if restoreInput.ID == "" {
+		return t.list(toolCtx)
+	}
+
+	entry, data, err := toolCtx.Backups.Store().Load(restoreInput.ID)
+	if err != nil {
+		return nil, tools.NotFound(err)
+	}
+	if entry.Host != toolCtx.Workspace.Host() {
+		return nil, fmt.Errorf("backup %s is of a file on %s, not in this workspace", entry.ID, orLocal(entry.Host))
+	}
+	path, shownPath, err := resolvePath(toolCtx, entry.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockPath(toolCtx, path)
+	defer unlock()
+	defer fileCache(toolCtx).Forget(path)
+
+	fsys := fileSystem(toolCtx)
+	current, readErr := fsys.ReadFile(path)
+	existed := readErr == nil
+
+	preview := diff.Unified(shownPath, string(current), string(data))
+	if preview == "" {
+		return tools.NewTextResult(fmt.Sprintf("%s already has the content of backup %s", shownPath, entry.ID)), nil
+	}
+	approved, shown := confirmChange(toolCtx, confirm.Request{
+		Tool:    "restore_backup",
+		Action:  "restore the file from backup " + entry.ID,
+		Path:    shownPath,
+		Preview: preview,
+	})
+	if !approved {
+		return tools.NewTextResult("Restore cancelled by user"), nil
+	}
+
+	if err := ensureUnchanged(fsys, shownPath, path, current, existed); err != nil {
+		return nil, err
+	}
+	note := ""
+	if existed {
+		id, err := backUp(toolCtx, path, current, backup.ReasonRestore)
+		if err != nil {
+			return nil, err
+		}
+		note = fmt.Sprintf("; its previous content is backup %s", id)
+	}
+	if err := fsys.MkdirAll(filepath.Dir(path), defaultDirPermissions); err != nil {
+		return nil, fmt.Errorf(errMsgOperationFailed, "create directory", err)
+	}
+	if err := fsys.WriteFileAtomic(path, data, entry.Mode); err != nil {
+		return nil, fmt.Errorf(errMsgOperationFailed, "restore file", err)
+	}
+	fileVersions(toolCtx).SawContent(path, data)
+	if !shown {
+		showChange(toolCtx, preview)
+	}
+
+	result := tools.NewTextResult(fmt.Sprintf("Restored %s from backup %s%s", shownPath, entry.ID, note))
+	if existed {
+		return result.WithFilesChanged(shownPath), nil
+	}
+	return result.WithFilesCreated(shownPath), nil
+}
+
+// list describes the session's backups for the model
+func (t RestoreBackupTool) list(toolCtx *tools.ToolContext) (*tools.ToolResult, error) {
+	entries, err := toolCtx.Backups.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return tools.NewTextResult("No files have been backed up in this session"), nil
+	}
+	var b strings.Builder
+	b.WriteString("Backups of this session, most recent first:\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "- %s: %s before %s, %d bytes, at %s\n",
+			entry.ID, toolCtx.Workspace.Display(entry.Path), entry.Reason, entry.Size, entry.Created.Local().Format(time.TimeOnly))
+	}
+	return tools.NewTextResult(strings.TrimSuffix(b.String(), "\n")), nil
+}
+
+// backUp keeps a copy of a file before it is overwritten or deleted and
+// returns the backup's ID, or "" when backups are off. A file that cannot
+// be backed up is left alone.
+func backUp(toolCtx *tools.ToolContext, path string, data []byte, reason string) (string, error) {
+	if toolCtx == nil || toolCtx.Backups == nil {
+		return "", nil
+	}
+	mode := fs.FileMode(defaultFilePermissions)
+	if info, err := fileSystem(toolCtx).Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	entry, err := toolCtx.Backups.Save(toolCtx.Workspace.Host(), path, data, mode, reason)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s, so it was not changed (set backups.enabled to false to go without): %w", path, err)
+	}
+	return entry.ID, nil
+}
+
+// backupNote mentions the backup of a file in a tool result
+func backupNote(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (backup %s; restore_backup puts it back)", id)
+}
+
+func orLocal(host string) string {
+	if host == "" {
+		return "this machine"
+	}
+	return host
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(RestoreBackupTool{})
+}
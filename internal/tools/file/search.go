@@ -1,10 +1,14 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"path/filepath"
+	"strings"
 
+	"agent/internal/permissions"
 	"agent/internal/schema"
 	"agent/internal/tools"
 )
@@ -52,7 +56,9 @@ type GlobSearchTool struct{}
 
 func (t GlobSearchTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name: "glob_search",
+		Name:      "glob_search",
+		Group:     "file",
+		Cacheable: true,
 		Description: `Find files matching a glob pattern.
 		
 Usage Examples:
@@ -63,32 +69,31 @@ Supported Patterns:
 - * matches any sequence of characters
 - ? matches any single character
 - [abc] matches any character in the set
-- Use forward slashes for paths on all platforms
-
-Note: Recursive patterns (**) support depends on Go's filepath.Glob implementation`,
+- ** matches any number of directories, e.g. src/**/*.js
+- Use forward slashes for paths on all platforms`,
 		InputSchema: schema.GenerateSchema[GlobSearchInput](),
 	}
 }
 
-func (t GlobSearchTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t GlobSearchTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	searchInput, err := t.parseAndValidateInput(input)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
 	}
 
-	result, err := t.performSearch(searchInput)
+	result, err := t.performSearch(ctx, toolCtx, searchInput)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return result.String(), nil
+	return tools.NewTextResult(result.String()), nil
 }
 
 // Helper methods for better separation of concerns
 func (t GlobSearchTool) parseAndValidateInput(input json.RawMessage) (*GlobSearchInput, error) {
 	var searchInput GlobSearchInput
-	if err := json.Unmarshal(input, &searchInput); err != nil {
-		return nil, fmt.Errorf("invalid JSON input: %w", err)
+	if err := tools.DecodeInput(input, &searchInput); err != nil {
+		return nil, err
 	}
 
 	if err := searchInput.Validate(); err != nil {
@@ -105,12 +110,77 @@ func (t GlobSearchTool) buildSearchPattern(input *GlobSearchInput) string {
 	return filepath.Join(input.Path, input.Pattern)
 }
 
-func (t GlobSearchTool) performSearch(input *GlobSearchInput) (*SearchResult, error) {
+// splitPattern splits a search pattern into the directory its leading
+// segments without wildcards name, where the walk starts, and the rest
+func (t GlobSearchTool) splitPattern(searchPattern string) (base, pattern string) {
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(searchPattern)), "/")
+	literal := 0
+	for literal < len(segments)-1 && !strings.ContainsAny(segments[literal], `*?[\`) {
+		literal++
+	}
+	base = strings.Join(segments[:literal], "/")
+	if base == "" && literal > 0 {
+		base = "/"
+	} else if base == "" {
+		base = "."
+	}
+	return filepath.FromSlash(base), strings.Join(segments[literal:], "/")
+}
+
+func (t GlobSearchTool) performSearch(ctx context.Context, toolCtx *tools.ToolContext, input *GlobSearchInput) (*SearchResult, error) {
 	searchPattern := t.buildSearchPattern(input)
+	base, pattern := t.splitPattern(searchPattern)
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf(errMsgInvalidPattern, searchPattern, err)
+		}
+	}
 
-	matches, err := filepath.Glob(searchPattern)
+	// Reject base directories outside the workspace before walking them, or
+	// ask about them as the path policy says
+	approved := false
+	if toolCtx != nil && toolCtx.Workspace != nil {
+		if resolved, err := toolCtx.Workspace.ResolveDir(base); err == nil {
+			approved = resolved.Ask != ""
+		}
+	}
+	root, _, err := resolveDir(toolCtx, base)
 	if err != nil {
-		return nil, fmt.Errorf(errMsgInvalidPattern, searchPattern, err)
+		return nil, err
+	}
+
+	var matches []string
+	err = fileSystem(toolCtx).Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil // A missing base directory, or an unreadable entry, matches nothing
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+
+		if info.IsDir() && toolCtx != nil && toolCtx.Workspace != nil &&
+			(toolCtx.Workspace.CheckDirAccess(path) != nil || toolCtx.Workspace.Ignored(path, true)) {
+			return filepath.SkipDir
+		}
+		if permissions.MatchGlob(pattern, rel) {
+			matches = append(matches, path)
+		}
+		// Only descend into directories the rest of the pattern can reach
+		if info.IsDir() && !permissions.MatchGlobBelow(pattern, rel) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if toolCtx != nil && toolCtx.Workspace != nil {
+		matches = t.filterToWorkspace(toolCtx, matches, approved)
 	}
 
 	return &SearchResult{
@@ -120,6 +190,25 @@ func (t GlobSearchTool) performSearch(input *GlobSearchInput) (*SearchResult, er
 	}, nil
 }
 
+// filterToWorkspace drops matches that escape the workspace (e.g. via
+// symlinks) or are ignored, and reports the rest relative to the workspace
+// root. Matches the path policy would ask about are kept only when the user
+// approved the base directory they were found under.
+func (t GlobSearchTool) filterToWorkspace(toolCtx *tools.ToolContext, matches []string, baseApproved bool) []string {
+	var filtered []string
+	for _, match := range matches {
+		resolved, err := toolCtx.Workspace.ResolvePath(toolCtx.Workspace.Display(match))
+		if err != nil || (resolved.Ask != "" && !baseApproved) {
+			continue
+		}
+		if info, err := toolCtx.Workspace.FS().Stat(resolved.Abs); err == nil && toolCtx.Workspace.Ignored(resolved.Abs, info.IsDir()) {
+			continue
+		}
+		filtered = append(filtered, toolCtx.Workspace.Display(match))
+	}
+	return filtered
+}
+
 func init() {
 	tools.DefaultRegistry.RegisterTool(GlobSearchTool{})
 }
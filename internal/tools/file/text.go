@@ -0,0 +1,27 @@
+package file
+
+import (
+	"fmt"
+
+	"agent/internal/charset"
+)
+
+// decodeText converts a file's content to UTF-8 for the model, returning
+// the encoding it is to be written back in
+func decodeText(path string, data []byte) (string, charset.Encoding, error) {
+	encoding := charset.Detect(data)
+	text, err := encoding.Decode(data)
+	if err != nil {
+		return "", encoding, fmt.Errorf("cannot read %s as %s: %w", path, encoding, err)
+	}
+	return text, encoding, nil
+}
+
+// encodeText converts text from the model back to the file's encoding
+func encodeText(path, text string, encoding charset.Encoding) ([]byte, error) {
+	data, err := encoding.Encode(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot write %s in its encoding, %s: %w", path, encoding, err)
+	}
+	return data, nil
+}
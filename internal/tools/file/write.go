@@ -1,13 +1,18 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 
+	"agent/internal/backup"
+	"agent/internal/charset"
+	"agent/internal/confirm"
+	"agent/internal/diff"
 	"agent/internal/schema"
 	"agent/internal/tools"
+	"agent/internal/workspace"
 )
 
 // Constants specific to write operations
@@ -34,10 +39,13 @@ type WriteFileTool struct{}
 
 func (t WriteFileTool) Definition() tools.ToolDefinition {
 	return tools.ToolDefinition{
-		Name: "write", // Changed from "write_file"
+		Name:     "write",
+		Group:    "file",
+		Mutating: true,
+		Confirms: true,
 		Description: `Write content to a file OR create an empty file.
-		
-IMPORTANT: This unified tool replaces both create_file and write_file.
+
+This is the only tool for creating or overwriting files (there is no separate create_file or write_file).
 
 Usage Examples:
 - {"path": "empty.txt", "content": ""} // Creates empty file
@@ -53,24 +61,86 @@ Behavior:
 	}
 }
 
-func (t WriteFileTool) Execute(ctx *tools.ToolContext, input json.RawMessage) (string, error) {
+func (t WriteFileTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
 	writeInput, err := t.parseAndValidateInput(input)
 	if err != nil {
-		return "", err
+		return nil, tools.InvalidInput(err)
+	}
+
+	path, shown, err := resolvePath(toolCtx, writeInput.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockPath(toolCtx, path)
+	defer unlock()
+	defer fileCache(toolCtx).Forget(path)
+
+	fsys := fileSystem(toolCtx)
+	oldContent, readErr := fsys.ReadFile(path)
+	existed := readErr == nil
+	if existed {
+		if err := ensureSeen(toolCtx, fsys, shown, path, oldContent); err != nil {
+			return nil, err
+		}
+	}
+
+	// A text file that is overwritten keeps its encoding
+	oldText, encoding := string(oldContent), charset.UTF8
+	if existed {
+		if text, detected, err := decodeText(shown, oldContent); err == nil && !isBinary([]byte(text)) {
+			oldText, encoding = text, detected
+		}
+	}
+	data, err := encodeText(shown, writeInput.Content, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	approved, preview, displayed := t.confirmWrite(toolCtx, shown, oldText, existed, writeInput.Content)
+	if !approved {
+		return tools.NewTextResult("File write cancelled by user"), nil
+	}
+
+	if err := ensureUnchanged(fsys, shown, path, oldContent, existed); err != nil {
+		return nil, err
+	}
+
+	backupID := ""
+	if existed {
+		if backupID, err = backUp(toolCtx, path, oldContent, backup.ReasonOverwrite); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.ensureDirectoryExists(fsys, path); err != nil {
+		return nil, err
 	}
 
-	if err := t.ensureDirectoryExists(writeInput.Path); err != nil {
-		return "", err
+	if err := t.writeFile(fsys, path, data); err != nil {
+		return nil, err
+	}
+	fileVersions(toolCtx).SawContent(path, data)
+	if !displayed {
+		showChange(toolCtx, preview)
 	}
 
-	return t.writeFile(writeInput.Path, writeInput.Content)
+	message := fmt.Sprintf("Successfully wrote content to file %s", shown)
+	if writeInput.Content == "" {
+		message = fmt.Sprintf("Created empty file %s", shown)
+	}
+	result := tools.NewTextResult(message + backupNote(backupID))
+	if existed {
+		return result.WithFilesChanged(shown), nil
+	}
+	return result.WithFilesCreated(shown), nil
 }
 
 // Helper methods for better separation of concerns
 func (t WriteFileTool) parseAndValidateInput(input json.RawMessage) (*WriteFileInput, error) {
 	var writeInput WriteFileInput
-	if err := json.Unmarshal(input, &writeInput); err != nil {
-		return nil, fmt.Errorf("invalid JSON input: %w", err)
+	if err := tools.DecodeInput(input, &writeInput); err != nil {
+		return nil, err
 	}
 
 	if err := writeInput.Validate(); err != nil {
@@ -80,35 +150,58 @@ func (t WriteFileTool) parseAndValidateInput(input json.RawMessage) (*WriteFileI
 	return &writeInput, nil
 }
 
-func (t WriteFileTool) ensureDirectoryExists(filePath string) error {
+// confirmWrite asks the user to approve the write, previewing it as a diff
+// against the current content. It also returns the preview and whether the
+// user saw it.
+func (t WriteFileTool) confirmWrite(toolCtx *tools.ToolContext, path, oldContent string, existed bool, content string) (approved bool, preview string, shown bool) {
+	action := "create the file"
+	if existed {
+		action = "overwrite the file"
+	}
+
+	preview = diff.Unified(path, oldContent, content)
+	if preview == "" {
+		preview = "(content unchanged)"
+	}
+
+	approved, shown = confirmChange(toolCtx, confirm.Request{
+		Tool:    "write",
+		Action:  action,
+		Path:    path,
+		Preview: preview,
+	})
+	return approved, preview, shown
+}
+
+func (t WriteFileTool) ensureDirectoryExists(fsys workspace.FS, filePath string) error {
 	dir := filepath.Dir(filePath)
 	if dir != "." {
-		if err := os.MkdirAll(dir, defaultDirPermissions); err != nil {
+		if err := fsys.MkdirAll(dir, defaultDirPermissions); err != nil {
 			return fmt.Errorf(errMsgOperationFailed, "create directory", err)
 		}
 	}
 	return nil
 }
 
-func (t WriteFileTool) writeFile(path, content string) (string, error) {
-	if content == "" {
-		// Create empty file (replaces create_file functionality)
-		file, err := os.Create(path)
-		if err != nil {
-			return "", fmt.Errorf(errMsgOperationFailed, "create empty file", err)
+// writeFile replaces the file atomically, so a crash or a full disk never
+// leaves it truncated
+func (t WriteFileTool) writeFile(fsys workspace.FS, path string, data []byte) error {
+	if len(data) == 0 {
+		// Create empty file (like touch)
+		if err := fsys.WriteFileAtomic(path, nil, defaultFilePermissions); err != nil {
+			return fmt.Errorf(errMsgOperationFailed, "create empty file", err)
 		}
-		file.Close()
-		return fmt.Sprintf("Created empty file %s", path), nil
+		return nil
 	}
 
 	// Write content to file
-	if err := os.WriteFile(path, []byte(content), defaultFilePermissions); err != nil {
-		return "", fmt.Errorf(errMsgOperationFailed, "write file", err)
+	if err := fsys.WriteFileAtomic(path, data, defaultFilePermissions); err != nil {
+		return fmt.Errorf(errMsgOperationFailed, "write file", err)
 	}
 
-	return fmt.Sprintf("Successfully wrote content to file %s", path), nil
+	return nil
 }
 
 func init() {
-	tools.DefaultRegistry.RegisterTool(WriteFileTool{}) // Only this line remains - CreateFileTool removed
+	tools.DefaultRegistry.RegisterTool(WriteFileTool{})
 }
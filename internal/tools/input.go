@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// DecodeInput unmarshals a tool call's JSON input into v, a pointer to the
+// tool's input struct. Models get inputs wrong in ways encoding/json reports
+// in terms of Go types, or not at all, so errors here name the parameter at
+// fault and what it should be. Parameters the tool does not take are left
+// to the registry, which checks every input against the tool's schema
+// before Execute. Empty input and null decode as {}.
+func DecodeInput(input json.RawMessage, v any) error {
+	trimmed := bytes.TrimSpace(input)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	if trimmed[0] != '{' && !bytes.Equal(trimmed, []byte("null")) {
+		return fmt.Errorf("the input must be a JSON object of named parameters (%s), not %s", parameterList(v), jsonKind(trimmed))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	err := decoder.Decode(v)
+	if err == nil {
+		// Decode stops at the end of the first value, so {"path": "a"} {"path": "b"}
+		// would otherwise pass as its first object
+		end := decoder.InputOffset()
+		if decoder.Decode(&json.RawMessage{}) != io.EOF {
+			return fmt.Errorf("the input is not valid JSON: unexpected data after the object ends at byte %d", end)
+		}
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("the input is not valid JSON: %s at byte %d", syntaxErr, syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("the input is not valid JSON: it ends before the object is closed")
+	case errors.As(err, &typeErr):
+		got, _, _ := strings.Cut(typeErr.Value, " ")
+		return fmt.Errorf("parameter %q must be %s, not a JSON %s", typeErr.Field, typeName(typeErr.Type), got)
+	}
+	return err
+}
+
+// PathParams returns a PathsFunc for tools whose paths are in the named
+// string or string array parameters. Parameters that are missing, empty or
+// of another type are skipped.
+func PathParams(names ...string) PathsFunc {
+	return func(_ *ToolContext, input json.RawMessage) []string {
+		var params map[string]json.RawMessage
+		if json.Unmarshal(input, &params) != nil {
+			return nil
+		}
+		var paths []string
+		for _, name := range names {
+			var one string
+			var many []string
+			switch {
+			case json.Unmarshal(params[name], &one) == nil && one != "":
+				paths = append(paths, one)
+			case json.Unmarshal(params[name], &many) == nil:
+				for _, path := range many {
+					if path != "" {
+						paths = append(paths, path)
+					}
+				}
+			}
+		}
+		return paths
+	}
+}
+
+// parameterList names the JSON fields of the struct v points to
+func parameterList(v any) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return "none"
+	}
+	var names []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// typeName describes a Go type as the JSON value it is decoded from
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// jsonKind names the kind of JSON value data starts with
+func jsonKind(data []byte) string {
+	switch data[0] {
+	case '[':
+		return "an array"
+	case '"':
+		return "a string"
+	case 't', 'f':
+		return "a boolean"
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return "a number"
+	}
+	return "invalid JSON"
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// decodeTarget has a field of each kind tool inputs use
+type decodeTarget struct {
+	Path    string            `json:"path"`
+	Offset  *int              `json:"offset,omitempty"`
+	Count   int               `json:"count,omitempty"`
+	Ratio   float64           `json:"ratio,omitempty"`
+	All     bool              `json:"all,omitempty"`
+	Targets []string          `json:"targets,omitempty"`
+	Vars    map[string]string `json:"vars,omitempty"`
+}
+
+func FuzzDecodeInput(f *testing.F) {
+	for _, input := range []string{
+		`{"path": "main.go", "offset": 10, "count": 2, "ratio": 0.5, "all": true, "targets": ["a"], "vars": {"k": "v"}}`,
+		`{"path": 1}`, `{"offset": "10"}`, `{"targets": "a"}`, `{"vars": []}`, `{"count": 1.5}`, `{"unknown": true}`,
+		``, `null`, `[]`, `"main.go"`, `{`, `{} {}`, `{}}`, `{"path": "a"} trailing`, ` { } `,
+	} {
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		var got decodeTarget
+		err := DecodeInput(json.RawMessage(input), &got)
+
+		// DecodeInput accepts exactly the objects encoding/json decodes as a whole
+		trimmed := bytes.TrimSpace([]byte(input))
+		if len(trimmed) == 0 {
+			if err != nil {
+				t.Fatalf("DecodeInput(%q) = %v, want empty input to decode as {}", input, err)
+			}
+			return
+		}
+		var want decodeTarget
+		object := trimmed[0] == '{' || string(trimmed) == "null"
+		if valid := object && json.Unmarshal(trimmed, &want) == nil; (err == nil) != valid {
+			t.Fatalf("DecodeInput(%q) = %v, but the input is a valid object: %v", input, err, valid)
+		}
+		if err != nil && err.Error() == "" {
+			t.Fatalf("DecodeInput(%q) failed without a message", input)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// Execute runs kubectl, so the tool is fuzzed up to the arguments it would
+// run it with
+func FuzzKubectlInput(f *testing.F) {
+	toolstest.Seed(f, KubectlTool{}, `{"verb": "get", "resource": "secrets", "output": "yaml"}`, `{"verb": "logs", "name": "api", "since": "--kubeconfig=/tmp/x"}`,
+		`{"verb": "get", "resource": "pods", "namespace": "-n"}`, `{"verb": "logs", "selector": "app=api", "tail": -1}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		var kubectlInput KubectlInput
+		if toolstest.Decode(t, KubectlTool{}, input, &kubectlInput) != nil || kubectlInput.Validate() != nil {
+			return
+		}
+		// Only the tool's own options may start with "-": values the model
+		// chose could otherwise name another kubeconfig or server
+		args := kubectlInput.args("staging")
+		for i, arg := range args {
+			if strings.HasPrefix(arg, "-") && !options[arg] {
+				t.Fatalf("args() = %q passes %q at %d", args, arg, i)
+			}
+		}
+		if args[0] != kubectlInput.Verb || args[0] != verbGet && args[0] != verbDescribe && args[0] != verbLogs {
+			t.Fatalf("args() = %q does not start with a read-only verb", args)
+		}
+	})
+}
+
+// options are the options args adds
+var options = map[string]bool{
+	"--context": true, "--namespace": true, "--all-namespaces": true, "--selector": true, "--output": true,
+	"--tail": true, "--container": true, "--previous": true, "--since": true, "--request-timeout": true,
+}
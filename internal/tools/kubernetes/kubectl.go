@@ -0,0 +1,329 @@
+// Package kubernetes provides a read-only kubectl tool for inspecting the
+// clusters of the kubeconfig contexts the user has allowed in config
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam     = "parameter %q is required"
+	errMsgNoContexts       = "no Kubernetes contexts are allowed; list the contexts kubectl may inspect under kubernetes.contexts in billdozer.yml"
+	errMsgContextNotListed = "context %q is not allowed; allowed contexts: %s"
+	errMsgChooseContext    = "parameter \"context\" is required when more than one context is allowed: %s"
+	errMsgOptionLike       = "parameter %q cannot start with \"-\""
+)
+
+// Verbs of the kubectl tool
+const (
+	verbGet      = "get"
+	verbDescribe = "describe"
+	verbLogs     = "logs"
+)
+
+const (
+	// timeout bounds each kubectl command
+	timeout = 60 * time.Second
+	// maxOutputBytes is how much of kubectl's output is returned
+	maxOutputBytes = 30000
+	// defaultTailLines is how many log lines are returned unless tail is set
+	defaultTailLines = 200
+)
+
+// settings is the kubernetes config in effect; see Configure
+var settings config.KubernetesConfig
+
+// Configure sets the contexts the tool may use and the kubeconfig it reads
+// them from. Call it before tools run.
+func Configure(cfg config.KubernetesConfig) {
+	settings = cfg
+}
+
+// KubectlInput represents the input parameters for the kubectl tool
+type KubectlInput struct {
+	Verb          string `json:"verb" jsonschema:"required,enum=get,enum=describe,enum=logs" jsonschema_description:"get lists resources, describe shows their details and recent events, logs shows a pod's logs"`
+	Context       string `json:"context,omitempty" jsonschema_description:"kubeconfig context to use; may be omitted when only one context is allowed"`
+	Namespace     string `json:"namespace,omitempty" jsonschema_description:"Namespace; the context's default when omitted"`
+	AllNamespaces bool   `json:"all_namespaces,omitempty" jsonschema_description:"get and describe: look in every namespace"`
+	Resource      string `json:"resource,omitempty" jsonschema_description:"get and describe: resource type, e.g. pods, deployments, events"`
+	Name          string `json:"name,omitempty" jsonschema_description:"Resource name; for logs the pod, or a type/name such as deployment/api"`
+	Selector      string `json:"selector,omitempty" jsonschema_description:"Label selector, e.g. app=api"`
+	Output        string `json:"output,omitempty" jsonschema:"enum=wide,enum=yaml,enum=json,enum=name" jsonschema_description:"get: output format"`
+	Container     string `json:"container,omitempty" jsonschema_description:"logs: container of the pod"`
+	Previous      bool   `json:"previous,omitempty" jsonschema_description:"logs: logs of the previous, crashed instance of the container"`
+	Tail          int    `json:"tail,omitempty" jsonschema_description:"logs: number of lines from the end (default 200)"`
+	Since         string `json:"since,omitempty" jsonschema_description:"logs: only newer logs, e.g. 10m or 1h"`
+}
+
+// Validate implements input validation
+func (i *KubectlInput) Validate() error {
+	switch i.Verb {
+	case "":
+		return fmt.Errorf(errMsgMissingParam, "verb")
+	case verbGet, verbDescribe:
+		if i.Resource == "" {
+			return fmt.Errorf("parameter \"resource\" is required with %s", i.Verb)
+		}
+		if i.Container != "" || i.Previous || i.Tail != 0 || i.Since != "" {
+			return fmt.Errorf("parameters \"container\", \"previous\", \"tail\" and \"since\" apply only to logs")
+		}
+		if i.Output != "" && i.Verb != verbGet {
+			return fmt.Errorf("parameter \"output\" applies only to get")
+		}
+		if isSecret(i.Resource) && (i.Output == "yaml" || i.Output == "json") {
+			return fmt.Errorf("secrets cannot be shown as %s, which includes their values; use describe", i.Output)
+		}
+	case verbLogs:
+		if i.Name == "" && i.Selector == "" {
+			return fmt.Errorf("parameter \"name\" or \"selector\" is required with logs")
+		}
+		if i.Resource != "" || i.Output != "" || i.AllNamespaces {
+			return fmt.Errorf("parameters \"resource\", \"output\" and \"all_namespaces\" do not apply to logs")
+		}
+		if i.Tail < 0 {
+			return fmt.Errorf("parameter \"tail\" cannot be negative")
+		}
+	default:
+		return fmt.Errorf("verb must be get, describe or logs, not %q", i.Verb)
+	}
+	if i.AllNamespaces && i.Namespace != "" {
+		return fmt.Errorf("set either \"namespace\" or \"all_namespaces\"")
+	}
+	// Values are separate arguments, so only a leading "-" could change
+	// what kubectl does, e.g. by naming another kubeconfig or server
+	for name, value := range map[string]string{
+		"context": i.Context, "namespace": i.Namespace, "resource": i.Resource, "name": i.Name,
+		"selector": i.Selector, "container": i.Container, "since": i.Since,
+	} {
+		if strings.HasPrefix(value, "-") {
+			return fmt.Errorf(errMsgOptionLike, name)
+		}
+	}
+	return nil
+}
+
+// isSecret reports whether a resource type names secrets
+func isSecret(resource string) bool {
+	for _, kind := range strings.Split(strings.ToLower(resource), ",") {
+		kind, _, _ = strings.Cut(kind, "/")
+		kind, _, _ = strings.Cut(kind, ".")
+		if kind == "secret" || kind == "secrets" {
+			return true
+		}
+	}
+	return false
+}
+
+// args returns kubectl's arguments for the input, with the context given
+func (i *KubectlInput) args(kubeContext string) []string {
+	args := []string{i.Verb}
+	if i.Resource != "" {
+		args = append(args, i.Resource)
+	}
+	if i.Name != "" {
+		args = append(args, i.Name)
+	}
+	args = append(args, "--context", kubeContext)
+	if i.Namespace != "" {
+		args = append(args, "--namespace", i.Namespace)
+	}
+	if i.AllNamespaces {
+		args = append(args, "--all-namespaces")
+	}
+	if i.Selector != "" {
+		args = append(args, "--selector", i.Selector)
+	}
+	if i.Output != "" {
+		args = append(args, "--output", i.Output)
+	}
+	if i.Verb == verbLogs {
+		tail := i.Tail
+		if tail == 0 {
+			tail = defaultTailLines
+		}
+		args = append(args, "--tail", strconv.Itoa(tail))
+		if i.Container != "" {
+			args = append(args, "--container", i.Container)
+		}
+		if i.Previous {
+			args = append(args, "--previous")
+		}
+		if i.Since != "" {
+			args = append(args, "--since", i.Since)
+		}
+	}
+	return append(args, "--request-timeout", "30s")
+}
+
+type KubectlTool struct{}
+
+func (t KubectlTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:  "kubectl",
+		Group: "kubernetes",
+		Description: `Inspect Kubernetes clusters with kubectl's read-only verbs: get, describe and logs.
+
+Usage Examples:
+- {"verb": "get", "resource": "pods", "namespace": "api", "output": "wide"} // List pods with their nodes
+- {"verb": "describe", "resource": "pod", "name": "api-7d9f8-x2k4q", "namespace": "api"} // Status, restarts and events
+- {"verb": "logs", "name": "api-7d9f8-x2k4q", "namespace": "api", "previous": true} // Logs of the crashed container
+- {"verb": "logs", "name": "deployment/api", "namespace": "api", "since": "10m"}
+- {"verb": "get", "resource": "events", "namespace": "api"} // Recent events, e.g. failed scheduling or image pulls
+
+Only the kubeconfig contexts allowed in the user's configuration can be used, and nothing
+in a cluster can be changed. To find why a pod is crash-looping, describe it for its last
+state and events, then read the logs of the previous container.`,
+		InputSchema: schema.GenerateSchema[KubectlInput](),
+	}
+}
+
+func (t KubectlTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var kubectlInput KubectlInput
+	if err := tools.DecodeInput(input, &kubectlInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := kubectlInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	kubeContext, err := allowedContext(kubectlInput.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	argv := append([]string{"kubectl"}, kubectlInput.args(kubeContext)...)
+	commandLine := strings.Join(argv, " ")
+	stdout, stderr, exitCode, err := run(ctx, workspaceOf(toolCtx).Runner(), argv)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return tools.NewErrorResult(fmt.Sprintf("kubectl failed (exit code %d):\n%s", exitCode, strings.TrimSpace(stderr))).WithCommands(commandLine), nil
+	}
+	output := strings.TrimRight(stdout, "\n")
+	if output == "" {
+		output = strings.TrimSpace(stderr) // "No resources found in api namespace."
+	}
+	// Logs end with what happened last; listings and descriptions start
+	// with what matters most
+	if kubectlInput.Verb == verbLogs {
+		output = lastBytes(output)
+	} else {
+		output = firstBytes(output)
+	}
+	return tools.NewTextResult(output).WithCommands(commandLine), nil
+}
+
+// allowedContext returns the context to use: the one asked for, which must
+// be allowed, or the only allowed one
+func allowedContext(asked string) (string, error) {
+	var allowed []string
+	for _, name := range settings.Contexts {
+		if name != "" {
+			allowed = append(allowed, name)
+		}
+	}
+	switch {
+	case len(allowed) == 0:
+		return "", tools.PermissionDenied(errors.New(errMsgNoContexts))
+	case asked == "" && len(allowed) == 1:
+		return allowed[0], nil
+	case asked == "":
+		return "", tools.InvalidInput(fmt.Errorf(errMsgChooseContext, strings.Join(allowed, ", ")))
+	case !slices.Contains(allowed, asked):
+		return "", tools.PermissionDenied(fmt.Errorf(errMsgContextNotListed, asked, strings.Join(allowed, ", ")))
+	}
+	return asked, nil
+}
+
+// run runs kubectl and returns its output and exit code; errors mean it
+// could not be run or did not finish in time
+func run(ctx context.Context, runner workspace.Runner, argv []string) (stdout, stderr string, exitCode int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// KUBECONFIG rather than --kubeconfig, so the path may use variables
+	// such as $HOME of where kubectl runs
+	var env map[string]string
+	if settings.Kubeconfig != "" {
+		env = map[string]string{"KUBECONFIG": settings.Kubeconfig}
+	}
+	var out, errOut bytes.Buffer
+	process, err := runner.Start(workspace.Cmd{Argv: argv, Env: env, Stdout: &out, Stderr: &errOut, Group: true})
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to run kubectl (is it installed and on the PATH?): %w", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- process.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		process.Kill()
+		<-done
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", "", 0, fmt.Errorf("kubectl %s timed out after %s", argv[1], timeout)
+		}
+		return "", "", 0, fmt.Errorf("kubectl %s cancelled: %w", argv[1], ctx.Err())
+	}
+
+	var exit workspace.ExitCoder
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		exitCode = exit.ExitCode()
+	default:
+		return "", "", 0, fmt.Errorf("kubectl %s failed: %w", argv[1], err)
+	}
+	return out.String(), errOut.String(), exitCode, nil
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of output within maxOutputBytes, up to the
+// end of a line
+func firstBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(output[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes of output omitted; narrow the query with namespace, name or selector] ...", output[:cut], len(output)-cut)
+}
+
+// lastBytes keeps the end of output within maxOutputBytes, from the start
+// of a line
+func lastBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := len(output) - maxOutputBytes
+	if i := strings.IndexByte(output[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return fmt.Sprintf("... [%d bytes of earlier logs omitted] ...\n%s", cut, output[cut:])
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(KubectlTool{})
+}
@@ -0,0 +1,72 @@
+package licenses
+
+import (
+	"regexp"
+	"strings"
+)
+
+// unknownLicense is what licenses that cannot be recognised are reported as
+const unknownLicense = "unknown"
+
+// licenseFile matches the names license files go by
+var licenseFile = regexp.MustCompile(`(?i)^(?:LICEN[CS]E|COPYING|UNLICENSE)(?:[-._][A-Za-z0-9.-]*)?$`)
+
+// spdxTag matches an SPDX header, e.g. SPDX-License-Identifier: MIT
+var spdxTag = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// signature is the phrases of a license's text that identify it and tell
+// its versions apart
+type signature struct {
+	id      string
+	phrases []string // All must appear
+}
+
+// signatures are checked in order, so the more specific come first: the
+// Lesser and Affero GPLs before the GPL, BSD-3-Clause before BSD-2-Clause,
+// ISC before 0BSD, which is ISC without the notice
+var signatures = []signature{
+	{id: "AGPL-3.0", phrases: []string{"gnu affero general public license"}},
+	{id: "LGPL-3.0", phrases: []string{"gnu lesser general public license", "version 3"}},
+	{id: "LGPL-2.1", phrases: []string{"gnu lesser general public license", "version 2.1"}},
+	{id: "LGPL-2.0", phrases: []string{"gnu library general public license"}},
+	{id: "GPL-3.0", phrases: []string{"gnu general public license", "version 3"}},
+	{id: "GPL-2.0", phrases: []string{"gnu general public license", "version 2"}},
+	{id: "MPL-2.0", phrases: []string{"mozilla public license", "2.0"}},
+	{id: "EPL-2.0", phrases: []string{"eclipse public license", "2.0"}},
+	{id: "EPL-1.0", phrases: []string{"eclipse public license"}},
+	{id: "Apache-2.0", phrases: []string{"apache license", "version 2.0"}},
+	{id: "BSL-1.0", phrases: []string{"boost software license"}},
+	{id: "CC0-1.0", phrases: []string{"cc0 1.0 universal"}},
+	{id: "Unlicense", phrases: []string{"this is free and unencumbered software released into the public domain"}},
+	{id: "Zlib", phrases: []string{"altered source versions must be plainly marked as such"}},
+	{id: "BSD-3-Clause", phrases: []string{"redistribution and use in source and binary forms", "endorse or promote products derived from this software"}},
+	{id: "BSD-2-Clause", phrases: []string{"redistribution and use in source and binary forms"}},
+	{id: "ISC", phrases: []string{"distribute this software for any purpose with or without fee is hereby granted", "this permission notice appear in all copies"}},
+	{id: "0BSD", phrases: []string{"distribute this software for any purpose with or without fee is hereby granted"}},
+	{id: "MIT", phrases: []string{"permission is hereby granted, free of charge, to any person obtaining a copy"}},
+}
+
+// classify names the license of a license file's text, by its SPDX header
+// or the phrases that identify common licenses, or unknownLicense
+func classify(text string) string {
+	if m := spdxTag.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	for _, s := range signatures {
+		if s.matches(normalized) {
+			return s.id
+		}
+	}
+	return unknownLicense
+}
+
+// matches reports whether normalized text has the signature
+func (s signature) matches(normalized string) bool {
+	for _, phrase := range s.phrases {
+		if !strings.Contains(normalized, phrase) {
+			return false
+		}
+	}
+	return true
+}
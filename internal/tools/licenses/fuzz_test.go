@@ -0,0 +1,37 @@
+package licenses
+
+import (
+	"strings"
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// Execute runs go list, so the tool is fuzzed up to the packages it would
+// list
+func FuzzLicenseAuditInput(f *testing.F) {
+	toolstest.Seed(f, LicenseAuditTool{}, `{"packages": ["-modfile=/tmp/go.mod"]}`, `{"module": "x", "all": true}`, `{"packages": [""]}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		var auditInput LicenseAuditInput
+		if toolstest.Decode(t, LicenseAuditTool{}, input, &auditInput) != nil || auditInput.Validate() != nil {
+			return
+		}
+		for _, pkg := range auditInput.Packages {
+			if strings.HasPrefix(pkg, "-") {
+				t.Fatalf("package %q is accepted but is an option of go list", pkg)
+			}
+		}
+	})
+}
+
+func FuzzClassify(f *testing.F) {
+	f.Add("SPDX-License-Identifier: Apache-2.0")
+	f.Add("Permission is hereby granted, free of charge, to any person obtaining a copy")
+	f.Add("Redistribution and use in source and binary forms, with or without modification")
+	f.Add("")
+	f.Fuzz(func(t *testing.T, text string) {
+		if license := classify(text); license == "" || strings.Contains(license, " OR ") {
+			t.Fatalf("classify(%q) = %q", text, license)
+		}
+	})
+}
@@ -0,0 +1,387 @@
+// Package licenses provides a tool that finds the licenses of a Go
+// project's dependencies and checks them against the allowed and denied
+// licenses of the project's config
+package licenses
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgInvalidPackage = "packages must be package paths or patterns such as ./..., not %q"
+	errMsgUnknownModule  = "%s is not a dependency of %s"
+)
+
+const (
+	// timeout bounds go list, which may download modules first, and
+	// go-licenses
+	timeout = 5 * time.Minute
+	// maxLicenseLines is how much of a license file a module's details show
+	maxLicenseLines = 40
+	// maxImporters is how many importers of a module are named
+	maxImporters = 8
+	// maxOutputBytes is how much of a report is returned
+	maxOutputBytes = 30000
+)
+
+// Verdicts of the policy on a module's license
+const (
+	verdictAllowed    = "allowed"
+	verdictDenied     = "denied"
+	verdictNotAllowed = "not allowed"
+	verdictUnknown    = "unknown"
+	verdictIgnored    = "ignored"
+)
+
+// settings is the license policy in effect; see Configure
+var settings config.LicensesConfig
+
+// Configure sets the licenses dependencies may and may not have. Call it
+// before tools run.
+func Configure(cfg config.LicensesConfig) {
+	settings = cfg
+}
+
+// LicenseAuditInput represents the input parameters for the license_audit tool
+type LicenseAuditInput struct {
+	Packages []string `json:"packages,omitempty" jsonschema_description:"Packages whose dependencies are audited, e.g. ./cmd/server; the configured ones, or ./..., when omitted"`
+	Module   string   `json:"module,omitempty" jsonschema_description:"Show one dependency in detail: its license files, the start of their text, and what imports it"`
+	All      bool     `json:"all,omitempty" jsonschema_description:"List every dependency with its license, not only those the policy flags"`
+}
+
+// Validate implements input validation
+func (i *LicenseAuditInput) Validate() error {
+	for _, pkg := range i.Packages {
+		if pkg == "" || strings.HasPrefix(pkg, "-") {
+			return fmt.Errorf(errMsgInvalidPackage, pkg)
+		}
+	}
+	if i.Module != "" && i.All {
+		return fmt.Errorf("parameters \"module\" and \"all\" cannot be combined")
+	}
+	return nil
+}
+
+type LicenseAuditTool struct{}
+
+func (t LicenseAuditTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:  "license_audit",
+		Group: "licenses",
+		Description: `Find the licenses of a Go project's dependencies and check them against the project's
+license policy: the modules whose licenses are denied, missing from the allowed list, or
+could not be recognised, with what imports each.
+
+Usage Examples:
+- {} // Audit the dependencies of ./...
+- {"packages": ["./cmd/server"]} // Only what the server binary links
+- {"module": "github.com/example/lib"} // Its license text and what pulls it in
+- {"all": true} // Every dependency and its license
+
+Licenses are SPDX identifiers read from each module's license files, or from go-licenses
+when configured. To fix a flagged dependency, suggest one under an allowed license with
+the same features; an indirect dependency goes away only when the dependencies that
+import it are replaced or upgraded. Read unknown licenses before judging them.`,
+		InputSchema: schema.GenerateSchema[LicenseAuditInput](),
+	}
+}
+
+func (t LicenseAuditTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var auditInput LicenseAuditInput
+	if err := tools.DecodeInput(input, &auditInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := auditInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	dir := "."
+	if ws := workspaceOf(toolCtx); ws != nil {
+		dir = ws.Root()
+	}
+	packages := auditInput.Packages
+	if len(packages) == 0 {
+		packages = settings.Packages
+	}
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	modules, err := listModules(ctx, toolCtx, dir, packages)
+	if err != nil {
+		return nil, err
+	}
+	commands := []string{"go list -deps " + strings.Join(packages, " ")}
+	if settings.Source == config.LicenseSourceGoLicenses {
+		if err := goLicenses(ctx, toolCtx, dir, packages, modules); err != nil {
+			return nil, err
+		}
+		commands = append(commands, "go-licenses report "+strings.Join(packages, " "))
+	} else {
+		readLicenses(workspaceOf(toolCtx).FS(), modules)
+	}
+
+	where := strings.Join(packages, " ")
+	var text string
+	switch {
+	case auditInput.Module != "":
+		text, err = moduleDetails(workspaceOf(toolCtx).FS(), modules, auditInput.Module, where)
+		if err != nil {
+			return nil, err
+		}
+	case auditInput.All:
+		text = summary(modules, where) + "\n\n" + table(modules)
+	default:
+		text = summary(modules, where) + flagged(modules)
+	}
+	return tools.NewTextResult(firstBytes(text)).WithCommands(commands...), nil
+}
+
+// verdict applies the policy to a module's license. A module offered under
+// a choice of licenses passes when one of them does.
+func verdict(m *module) string {
+	for _, ignored := range settings.Ignore {
+		if m.path == ignored || strings.HasPrefix(m.path, strings.TrimSuffix(ignored, "/")+"/") {
+			return verdictIgnored
+		}
+	}
+	if m.license == unknownLicense {
+		return verdictUnknown
+	}
+	alternatives := strings.Split(m.license, " OR ")
+	for _, license := range alternatives {
+		if !listed(settings.Deny, license) && (len(settings.Allow) == 0 || listed(settings.Allow, license)) {
+			return verdictAllowed
+		}
+	}
+	for _, license := range alternatives {
+		if !listed(settings.Deny, license) {
+			return verdictNotAllowed
+		}
+	}
+	return verdictDenied
+}
+
+// listed reports whether a license is among licenses, regardless of case
+func listed(licenses []string, license string) bool {
+	return slices.ContainsFunc(licenses, func(l string) bool { return strings.EqualFold(l, license) })
+}
+
+// summary counts the dependencies by license and says what the policy is
+func summary(modules []*module, where string) string {
+	byLicense := map[string]int{}
+	for _, m := range modules {
+		byLicense[m.license]++
+	}
+	licenses := make([]string, 0, len(byLicense))
+	for license := range byLicense {
+		licenses = append(licenses, license)
+	}
+	slices.SortFunc(licenses, func(a, b string) int {
+		if c := cmp.Compare(byLicense[b], byLicense[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	var counts []string
+	for _, license := range licenses {
+		counts = append(counts, fmt.Sprintf("%s %d", license, byLicense[license]))
+	}
+
+	source := "their license files"
+	if settings.Source == config.LicenseSourceGoLicenses {
+		source = "go-licenses"
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s used by %s, licenses from %s", count(len(modules), "dependency module"), where, source)
+	if len(modules) > 0 {
+		fmt.Fprintf(&text, ":\n%s", strings.Join(counts, ", "))
+	}
+	switch {
+	case len(settings.Allow) == 0 && len(settings.Deny) == 0:
+		text.WriteString("\nNo license policy is configured; add licenses.allow or licenses.deny to billdozer.yml to check dependencies against one")
+	case len(settings.Allow) == 0:
+		fmt.Fprintf(&text, "\nPolicy: any license but %s", strings.Join(settings.Deny, ", "))
+	default:
+		fmt.Fprintf(&text, "\nPolicy: %s allowed", strings.Join(settings.Allow, ", "))
+		if len(settings.Deny) > 0 {
+			fmt.Fprintf(&text, "; %s denied", strings.Join(settings.Deny, ", "))
+		}
+	}
+	return text.String()
+}
+
+// flagged lists the modules the policy denies, does not allow, or cannot
+// judge, each with what imports it
+func flagged(modules []*module) string {
+	groups := []struct {
+		verdict, heading string
+	}{
+		{verdictDenied, "Denied licenses"},
+		{verdictNotAllowed, "Licenses not in the allowed list"},
+		{verdictUnknown, "Licenses that could not be recognised"},
+	}
+	var text strings.Builder
+	found := 0
+	for _, group := range groups {
+		var members []*module
+		for _, m := range modules {
+			if verdict(m) == group.verdict {
+				members = append(members, m)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		found += len(members)
+		fmt.Fprintf(&text, "\n\n%s (%d):", group.heading, len(members))
+		for _, m := range members {
+			fmt.Fprintf(&text, "\n  %s %s  %s", m.path, m.version, m.license)
+			if len(m.files) > 0 {
+				fmt.Fprintf(&text, " (%s)", strings.Join(m.files, ", "))
+			} else if m.license == unknownLicense {
+				text.WriteString(" (no license file)")
+			}
+			fmt.Fprintf(&text, "\n    %s", usage(m))
+		}
+	}
+	if found == 0 {
+		return "\n\nEvery dependency passes the policy"
+	}
+	return text.String()
+}
+
+// usage says whether a module is a direct dependency and what imports it
+func usage(m *module) string {
+	importers := make([]string, 0, len(m.importers))
+	for importer := range m.importers {
+		importers = append(importers, importer)
+	}
+	slices.Sort(importers)
+	kind := "direct dependency"
+	if m.indirect {
+		kind = "indirect dependency"
+	}
+	if m.replacement != "" {
+		kind += ", replaced by " + m.replacement
+	}
+	if len(importers) == 0 {
+		return kind
+	}
+	shown := importers[:min(len(importers), maxImporters)]
+	text := fmt.Sprintf("%s, imported by %s", kind, strings.Join(shown, ", "))
+	if len(importers) > len(shown) {
+		text += fmt.Sprintf(" and %d more", len(importers)-len(shown))
+	}
+	return text
+}
+
+// table lists every module with its license and the policy's verdict
+func table(modules []*module) string {
+	var text strings.Builder
+	writer := tabwriter.NewWriter(&text, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "module\tversion\tlicense\tverdict")
+	for _, m := range modules {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", m.path, m.version, m.license, verdict(m))
+	}
+	writer.Flush()
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// moduleDetails describes one module: its license and verdict, what
+// imports it, and the start of its license files
+func moduleDetails(fsys workspace.FS, modules []*module, path, where string) (string, error) {
+	i := slices.IndexFunc(modules, func(m *module) bool { return m.path == path })
+	if i < 0 {
+		var similar []string
+		for _, m := range modules {
+			if strings.Contains(m.path, path) || strings.Contains(path, m.path) {
+				similar = append(similar, m.path)
+			}
+		}
+		err := fmt.Errorf(errMsgUnknownModule, path, where)
+		if len(similar) > 0 {
+			err = fmt.Errorf("%w. Similar modules: %s", err, strings.Join(similar, ", "))
+		}
+		return "", tools.NotFound(err)
+	}
+	m := modules[i]
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s %s\nLicense: %s (%s)\n%s", m.path, m.version, m.license, verdict(m), usage(m))
+	if m.dir != "" {
+		fmt.Fprintf(&text, "\nSource: %s", m.dir)
+	}
+	if len(m.files) == 0 {
+		text.WriteString("\n\nNo license file was found at the module's root")
+	}
+	for _, file := range m.files {
+		data, err := fsys.ReadFile(filepath.Join(m.dir, file))
+		if err != nil {
+			fmt.Fprintf(&text, "\n\n%s", file) // A URL from go-licenses
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		fmt.Fprintf(&text, "\n\n%s:\n%s", file, strings.Join(lines[:min(len(lines), maxLicenseLines)], "\n"))
+		if len(lines) > maxLicenseLines {
+			fmt.Fprintf(&text, "\n... [%d more lines]", len(lines)-maxLicenseLines)
+		}
+	}
+	return text.String(), nil
+}
+
+// count writes a number of things, e.g. "1 dependency module" or "3
+// dependency modules"
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of output within maxOutputBytes, up to the
+// end of a line
+func firstBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(output[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted; audit fewer packages] ...", output[:cut], len(output)-cut)
+}
+
+// lastBytes keeps the end of output within maxOutputBytes
+func lastBytes(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	return "..." + output[len(output)-maxOutputBytes:]
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(LicenseAuditTool{})
+}
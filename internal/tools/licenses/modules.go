@@ -0,0 +1,231 @@
+package licenses
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// module is a dependency and what is known of its license
+type module struct {
+	path, version string
+	dir           string // Where its source is, usually the module cache
+	indirect      bool   // Required by go.mod only for other dependencies
+	replacement   string // What go.mod replaces it with, e.g. ../fork
+	license       string // SPDX identifier, alternatives joined by " OR ", or unknownLicense
+	files         []string
+	// importers are the main module's packages, and the other modules,
+	// whose packages import the module's
+	importers map[string]bool
+}
+
+// listedPackage is the part of go list -json output that is used
+type listedPackage struct {
+	ImportPath string
+	Standard   bool
+	Imports    []string
+	Module     *listedModule
+}
+
+type listedModule struct {
+	Path     string
+	Version  string
+	Dir      string
+	Main     bool
+	Indirect bool
+	Replace  *listedModule
+}
+
+// listModules returns the modules that provide the packages, and those
+// they import, build, in path order
+func listModules(ctx context.Context, toolCtx *tools.ToolContext, dir string, packages []string) ([]*module, error) {
+	argv := append([]string{"go", "list", "-deps", "-json=ImportPath,Standard,Imports,Module"}, packages...)
+	stdout, stderr, exitCode, err := run(ctx, workspaceOf(toolCtx).Runner(), argv, dir)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("go list failed (exit code %d):\n%s", exitCode, lastBytes(stderr))
+	}
+
+	var listed []listedPackage
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	for {
+		var p listedPackage
+		err := decoder.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read go list output: %w", err)
+		}
+		listed = append(listed, p)
+	}
+
+	modules := map[string]*module{}
+	moduleOf := map[string]string{} // Import path to the dependency module providing it
+	for _, p := range listed {
+		if p.Standard || p.Module == nil || p.Module.Main {
+			continue
+		}
+		moduleOf[p.ImportPath] = p.Module.Path
+		if modules[p.Module.Path] != nil {
+			continue
+		}
+		m := &module{path: p.Module.Path, version: p.Module.Version, dir: p.Module.Dir, indirect: p.Module.Indirect, importers: map[string]bool{}}
+		if r := p.Module.Replace; r != nil {
+			m.replacement = strings.TrimSpace(r.Path + " " + r.Version)
+			m.version = r.Version
+			if r.Dir != "" {
+				m.dir = r.Dir
+			}
+		}
+		modules[m.path] = m
+	}
+	for _, p := range listed {
+		if p.Standard || p.Module == nil {
+			continue
+		}
+		importer := p.Module.Path
+		if p.Module.Main {
+			importer = p.ImportPath
+		}
+		for _, imported := range p.Imports {
+			if path, ok := moduleOf[imported]; ok && path != p.Module.Path {
+				modules[path].importers[importer] = true
+			}
+		}
+	}
+
+	sorted := make([]*module, 0, len(modules))
+	for _, m := range modules {
+		sorted = append(sorted, m)
+	}
+	slices.SortFunc(sorted, func(a, b *module) int { return strings.Compare(a.path, b.path) })
+	return sorted, nil
+}
+
+// readLicenses classifies the license files at the root of each module.
+// A module with several, such as LICENSE-MIT and LICENSE-APACHE, may be
+// used under any of them.
+func readLicenses(fsys workspace.FS, modules []*module) {
+	for _, m := range modules {
+		m.license = unknownLicense
+		if m.dir == "" {
+			continue
+		}
+		entries, err := fsys.Glob(filepath.Join(m.dir, "*"))
+		if err != nil {
+			continue
+		}
+		var found []string
+		for _, entry := range entries {
+			name := filepath.Base(entry)
+			if !licenseFile.MatchString(name) {
+				continue
+			}
+			data, err := fsys.ReadFile(entry)
+			if err != nil {
+				continue
+			}
+			m.files = append(m.files, name)
+			if license := classify(string(data)); license != unknownLicense && !slices.Contains(found, license) {
+				found = append(found, license)
+			}
+		}
+		if len(found) > 0 {
+			m.license = strings.Join(found, " OR ")
+		}
+	}
+}
+
+// goLicenses sets the licenses of modules from go-licenses' report, which
+// names each library, usually a module, with its license's URL and type
+func goLicenses(ctx context.Context, toolCtx *tools.ToolContext, dir string, packages []string, modules []*module) error {
+	argv := append([]string{"go-licenses", "report"}, packages...)
+	stdout, stderr, exitCode, err := run(ctx, workspaceOf(toolCtx).Runner(), argv, dir)
+	if err != nil {
+		return err
+	}
+	// go-licenses fails for libraries it cannot classify while reporting
+	// the rest, so only a report without rows is an error
+	rows, _ := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if len(rows) == 0 && exitCode != 0 {
+		return fmt.Errorf("go-licenses failed (exit code %d):\n%s", exitCode, lastBytes(stderr))
+	}
+	for _, m := range modules {
+		m.license = unknownLicense
+	}
+	for _, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+		m := owner(modules, row[0])
+		if m == nil {
+			continue
+		}
+		m.files = []string{row[1]}
+		if !strings.EqualFold(row[2], "Unknown") {
+			m.license = row[2]
+		}
+	}
+	return nil
+}
+
+// owner returns the module a library path is in: the one with the longest
+// path that is the library's or a prefix of it
+func owner(modules []*module, library string) *module {
+	var found *module
+	for _, m := range modules {
+		if (library == m.path || strings.HasPrefix(library, m.path+"/")) && (found == nil || len(m.path) > len(found.path)) {
+			found = m
+		}
+	}
+	return found
+}
+
+// run runs a command in dir and returns its output. A command that ran is
+// returned whatever its exit code; errors mean it could not be run or was
+// stopped.
+func run(ctx context.Context, runner workspace.Runner, argv []string, dir string) (stdout, stderr string, exitCode int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out, errOut bytes.Buffer
+	process, err := runner.Start(workspace.Cmd{Argv: argv, Dir: dir, Stdout: &out, Stderr: &errOut, Group: true})
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to run %s (is it installed and on the PATH?): %w", argv[0], err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- process.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		process.Kill()
+		<-done
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", "", 0, fmt.Errorf("%s timed out after %s", argv[0], timeout)
+		}
+		return "", "", 0, fmt.Errorf("%s cancelled: %w", argv[0], ctx.Err())
+	}
+
+	var exit workspace.ExitCoder
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		exitCode = exit.ExitCode()
+	default:
+		return "", "", 0, fmt.Errorf("%s failed: %w", argv[0], err)
+	}
+	return out.String(), errOut.String(), exitCode, nil
+}
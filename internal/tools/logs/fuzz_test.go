@@ -0,0 +1,29 @@
+package logs
+
+import (
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// fuzzLogs is the workspace the analyze_logs tool is fuzzed in, with the
+// formats it recognises
+var fuzzLogs = map[string]string{
+	"logs/app.log": `2024-05-21T22:59:58Z INFO starting worker id=17
+2024-05-21T23:00:01.123+02:00 ERROR request 4f1c failed: connection refused
+panic: runtime error: index out of range
+	main.go:42
+{"time": "2024-05-21T23:05:00Z", "level": "warn", "msg": "retrying in 5s"}
+`,
+	"logs/server.log": "May 21 23:10:00 host app[123]: fatal: out of memory\nno timestamp here\n",
+	"logs/access.log": `127.0.0.1 - - [21/May/2024:23:00:00 +0000] "GET /healthz HTTP/1.1" 200 2` + "\n" +
+		`127.0.0.1 - - [21/May/2024:23:00:01 +0000] "POST /api HTTP/1.1" 503 0` + "\n",
+}
+
+func FuzzAnalyzeLogs(f *testing.F) {
+	toolstest.Seed(f, AnalyzeLogsTool{}, `{"path": "logs/*.log", "level": "warn", "since": "2024-05-21 23:00", "until": "1d"}`,
+		`{"path": "logs/app.log", "pattern": "(", "ignore_case": true}`, `{"path": "logs/app.log", "since": "-5m"}`, `{"path": "logs/app.log", "tail": 1000}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, AnalyzeLogsTool{}, toolstest.Context(t, fuzzLogs), input)
+	})
+}
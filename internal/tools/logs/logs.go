@@ -0,0 +1,402 @@
+// Package logs provides a tool that searches large log files by pattern,
+// level and time range, and summarizes what matched instead of returning it
+package logs
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam = "parameter %q is required"
+	errMsgInvalidTime  = "parameter %q must be a time such as 2024-05-21 23:00 or 2024-05-21T23:00:00Z, or a duration before now such as 90m, 6h or 2d; got %q"
+	errMsgNoFiles      = "no files match %s"
+)
+
+const (
+	// defaultTail is how many of the last matching lines are shown
+	defaultTail = 20
+	// maxTail is the most matching lines that can be asked for
+	maxTail = 200
+	// maxFiles is how many files a glob may match
+	maxFiles = 50
+	// maxOutputBytes is how much of a summary is returned
+	maxOutputBytes = 30000
+	// checkEvery is how many lines are read between checks for cancellation
+	checkEvery = 10000
+)
+
+// AnalyzeLogsInput represents the input parameters for the analyze_logs tool
+type AnalyzeLogsInput struct {
+	Path       string `json:"path" jsonschema:"required" jsonschema_description:"Log file, or a glob of files in one directory such as /var/log/app/app.log*; .gz files are read too. Files are read oldest first"`
+	Pattern    string `json:"pattern,omitempty" jsonschema_description:"Regular expression lines must match, e.g. panic|timeout"`
+	Exclude    string `json:"exclude,omitempty" jsonschema_description:"Regular expression of lines to leave out, e.g. healthcheck"`
+	IgnoreCase bool   `json:"ignore_case,omitempty" jsonschema_description:"Match pattern and exclude regardless of case"`
+	Level      string `json:"level,omitempty" jsonschema:"enum=trace,enum=debug,enum=info,enum=warn,enum=error,enum=fatal" jsonschema_description:"Only lines of this level or more severe"`
+	Since      string `json:"since,omitempty" jsonschema_description:"Only lines from this time: 2024-05-21 23:00, an RFC 3339 time, or a duration before now such as 12h"`
+	Until      string `json:"until,omitempty" jsonschema_description:"Only lines before this time, in the same forms as since"`
+	Tail       *int   `json:"tail,omitempty" jsonschema_description:"How many of the last matching lines to show (default 20, at most 200; 0 for none)"`
+}
+
+// Validate implements input validation
+func (i *AnalyzeLogsInput) Validate() error {
+	if i.Path == "" {
+		return fmt.Errorf(errMsgMissingParam, "path")
+	}
+	if i.Tail != nil && (*i.Tail < 0 || *i.Tail > maxTail) {
+		return fmt.Errorf("parameter \"tail\" must be between 0 and %d", maxTail)
+	}
+	if i.Level != "" && parseLevel(i.Level) == levelNone {
+		return fmt.Errorf("level must be trace, debug, info, warn, error or fatal, not %q", i.Level)
+	}
+	return nil
+}
+
+// filter decides which lines match
+type filter struct {
+	pattern, exclude *regexp.Regexp
+	level            int
+	since, until     time.Time
+}
+
+// newFilter compiles the input's filters, with times taken in loc
+func newFilter(input *AnalyzeLogsInput, loc *time.Location, now time.Time) (*filter, error) {
+	f := &filter{level: parseLevel(input.Level)}
+	var err error
+	if f.pattern, err = compile("pattern", input.Pattern, input.IgnoreCase); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compile("exclude", input.Exclude, input.IgnoreCase); err != nil {
+		return nil, err
+	}
+	if f.since, err = parseBound("since", input.Since, loc, now); err != nil {
+		return nil, err
+	}
+	if f.until, err = parseBound("until", input.Until, loc, now); err != nil {
+		return nil, err
+	}
+	if !f.since.IsZero() && !f.until.IsZero() && !f.since.Before(f.until) {
+		return nil, fmt.Errorf("parameter \"since\" must be before \"until\"")
+	}
+	return f, nil
+}
+
+func compile(name, expression string, ignoreCase bool) (*regexp.Regexp, error) {
+	if expression == "" {
+		return nil, nil
+	}
+	if ignoreCase {
+		expression = "(?i)" + expression
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("parameter %q is not a valid regular expression: %w", name, err)
+	}
+	return re, nil
+}
+
+// parseBound parses a time range bound: a time, or a duration before now
+// such as 90m, 6h or 2d
+func parseBound(name, value string, loc *time.Location, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(errMsgInvalidTime, name, value)
+}
+
+// timed reports whether the filter has a time range
+func (f *filter) timed() bool {
+	return !f.since.IsZero() || !f.until.IsZero()
+}
+
+// describe writes the filters for the summary, e.g.
+// /panic/, level error or worse, from 2024-05-21 22:00:00
+func (f *filter) describe(loc *time.Location) string {
+	var parts []string
+	if f.pattern != nil {
+		parts = append(parts, "/"+f.pattern.String()+"/")
+	}
+	if f.exclude != nil {
+		parts = append(parts, "not /"+f.exclude.String()+"/")
+	}
+	if f.level != levelNone {
+		parts = append(parts, "level "+levelNames[f.level]+" or worse")
+	}
+	if !f.since.IsZero() {
+		parts = append(parts, "from "+f.since.In(loc).Format(time.DateTime))
+	}
+	if !f.until.IsZero() {
+		parts = append(parts, "until "+f.until.In(loc).Format(time.DateTime))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type AnalyzeLogsTool struct{}
+
+func (t AnalyzeLogsTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:      "analyze_logs",
+		Group:     "logs",
+		Cacheable: true,
+		Description: `Search log files, however large, and summarize what matches instead of returning it:
+the matching lines by level, a histogram of them over time, the most frequent messages
+(with numbers, IDs and addresses masked so repeats group together) and the last few lines.
+
+Usage Examples:
+- {"path": "/var/log/app/app.log*", "level": "error", "since": "12h"} // What went wrong overnight
+- {"path": "logs/server.log", "pattern": "panic|fatal|out of memory", "since": "2024-05-21 22:00", "until": "2024-05-22 02:00"}
+- {"path": "logs/access.log", "pattern": "\" 5\\d\\d ", "exclude": "/healthz"} // Server errors in an access log
+- {"path": "logs/server.log", "tail": 50} // The end of the log, summarized
+
+Timestamps are recognised in ISO 8601, syslog, web server and JSON (ts, time) logs;
+lines without one, such as stack traces, belong to the line before. Times without a zone
+are local. Narrow the search with level, since and pattern, then read the lines around an
+interesting time with read_file.`,
+		InputSchema: schema.GenerateSchema[AnalyzeLogsInput](),
+	}
+}
+
+func (t AnalyzeLogsTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var logsInput AnalyzeLogsInput
+	if err := tools.DecodeInput(input, &logsInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := logsInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	loc, now := time.Local, time.Now()
+	f, err := newFilter(&logsInput, loc, now)
+	if err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	tail := defaultTail
+	if logsInput.Tail != nil {
+		tail = *logsInput.Tail
+	}
+
+	files, err := logFiles(toolCtx, logsInput.Path)
+	if err != nil {
+		return nil, err
+	}
+	s := newSummary(tail)
+	fsys := workspaceOf(toolCtx).FS()
+	for _, file := range files {
+		// A file last written before the range has nothing in it
+		if !f.since.IsZero() && file.modTime.Before(f.since) {
+			continue
+		}
+		s.files = append(s.files, file.shown)
+		if err := scanFile(ctx, fsys, file, f, s, loc, now); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.files) == 0 {
+		return tools.NewTextResult(fmt.Sprintf("No lines since %s: every file matching %s was last written before then", f.since.In(loc).Format(time.DateTime), logsInput.Path)), nil
+	}
+
+	output := s.format(f.describe(loc), loc)
+	if len(output) > maxOutputBytes {
+		output = truncate(output, maxOutputBytes) + "\n... [summary cut short; narrow it with pattern, level or a time range] ..."
+	}
+	return tools.NewTextResult(output), nil
+}
+
+// logFile is a file to read
+type logFile struct {
+	path    string
+	shown   string
+	modTime time.Time
+	size    int64
+}
+
+// logFiles returns the files a path or glob names, oldest first, asking
+// the user first when the path policy says to
+func logFiles(toolCtx *tools.ToolContext, path string) ([]logFile, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	resolved, shown, err := resolvePath(toolCtx, dir)
+	if err != nil {
+		return nil, err
+	}
+	fsys := workspaceOf(toolCtx).FS()
+
+	names := []string{filepath.Join(resolved, base)}
+	if strings.ContainsAny(base, "*?[") {
+		if names, err = fsys.Glob(filepath.Join(resolved, base)); err != nil {
+			return nil, tools.InvalidInput(fmt.Errorf("invalid glob %q: %w", path, err))
+		}
+	} else if strings.ContainsAny(dir, "*?[") {
+		return nil, tools.InvalidInput(fmt.Errorf("only the file name of %q may have wildcards", path))
+	}
+
+	var files []logFile
+	for _, name := range names {
+		info, err := fsys.Stat(name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil, tools.NotFound(fmt.Errorf("%s does not exist", filepath.Join(shown, filepath.Base(name))))
+		case err != nil:
+			return nil, err
+		case info.IsDir():
+			if len(names) == 1 {
+				return nil, tools.InvalidInput(fmt.Errorf("%s is a directory; give a log file, or a glob such as %s", filepath.Join(shown, base), filepath.Join(shown, base, "*.log")))
+			}
+			continue
+		}
+		files = append(files, logFile{path: name, shown: filepath.Join(shown, filepath.Base(name)), modTime: info.ModTime(), size: info.Size()})
+	}
+	if len(files) == 0 {
+		return nil, tools.NotFound(fmt.Errorf(errMsgNoFiles, path))
+	}
+	if len(files) > maxFiles {
+		return nil, tools.InvalidInput(fmt.Errorf("%s matches %d files; narrow the glob to at most %d", path, len(files), maxFiles))
+	}
+	slices.SortStableFunc(files, func(a, b logFile) int { return a.modTime.Compare(b.modTime) })
+	return files, nil
+}
+
+// scanFile reads a file's lines into the summary. Lines without a
+// timestamp, such as stack traces, take the time and level of the line
+// before them.
+func scanFile(ctx context.Context, fsys workspace.FS, file logFile, f *filter, s *summary, loc *time.Location, now time.Time) error {
+	reader, err := fsys.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.shown, err)
+	}
+	defer reader.Close()
+
+	var source io.Reader = reader
+	lineNumber := 0 // Unknown once a search skips ahead
+	if strings.HasSuffix(file.path, ".gz") {
+		unzipped, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.shown, err)
+		}
+		defer unzipped.Close()
+		source = unzipped
+	} else if seeker, ok := reader.(io.ReadSeeker); ok && !f.since.IsZero() {
+		offset, err := seekSince(seeker, file.size, f.since, loc, now)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.shown, err)
+		}
+		if offset > 0 {
+			lineNumber = -1
+		}
+	}
+
+	lines := newLineReader(source)
+	var entryTime time.Time
+	entryDated, entryLevel := false, levelNone
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.shown, err)
+		}
+		s.scanned++
+		if lineNumber >= 0 {
+			lineNumber++
+		}
+		if s.scanned%checkEvery == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		head := header(line)
+		if t, ok := lineTime(head, loc, now); ok {
+			entryTime, entryDated, entryLevel = t, true, lineLevel(head)
+		} else if level := lineLevel(head); level != levelNone {
+			entryLevel = level
+		}
+		if entryDated && !f.until.IsZero() && !entryTime.Before(f.until) {
+			// Lines are in time order, so none after this one are in range
+			// either; other files may still have some
+			return nil
+		}
+
+		switch {
+		case f.level != levelNone && entryLevel < f.level:
+			continue
+		case f.pattern != nil && !f.pattern.MatchString(line):
+			continue
+		case f.exclude != nil && f.exclude.MatchString(line):
+			continue
+		case f.timed() && !entryDated:
+			s.undated++
+			continue
+		case !f.since.IsZero() && entryTime.Before(f.since):
+			continue
+		}
+		where := file.shown
+		if lineNumber > 0 {
+			where += ":" + strconv.Itoa(lineNumber)
+		}
+		s.add(line, where, entryTime, entryDated, entryLevel)
+	}
+}
+
+// resolvePath resolves a path, asking the user first when the path policy
+// says to; logs are often outside the workspace
+func resolvePath(toolCtx *tools.ToolContext, path string) (resolved, shown string, err error) {
+	ws := workspaceOf(toolCtx)
+	if ws == nil {
+		return path, filepath.Clean(path), nil
+	}
+	p, err := ws.ResolvePath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "read logs outside the workspace", Path: p.Abs}) {
+		return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+	}
+	return p.Abs, p.Shown, nil
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(AnalyzeLogsTool{})
+}
@@ -0,0 +1,242 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// headerBytes is how much of the start of a line is searched for its
+	// timestamp and level
+	headerBytes = 200
+	// maxLineBytes is how much of a line is kept; the rest is dropped
+	maxLineBytes = 64 << 10
+)
+
+// Timestamp formats recognised at the start of lines, or in JSON fields
+var (
+	// isoTime matches 2024-05-21T23:01:02.123Z, 2024-05-21 23:01:02,123 and
+	// Go's log package's 2024/05/21 23:01:02
+	isoTime = regexp.MustCompile(`(\d{4})[-/](\d{2})[-/](\d{2})[T ](\d{2}):(\d{2}):(\d{2})(?:[.,](\d{1,9}))?(?: ?(Z|[+-]\d{2}:?\d{2}))?`)
+	// syslogTime matches May 21 23:01:02, which has no year
+	syslogTime = regexp.MustCompile(`^(?:<\d+>)?([A-Z][a-z]{2}) {1,2}(\d{1,2}) (\d{2}):(\d{2}):(\d{2})`)
+	// commonLogTime matches web servers' [21/May/2024:23:01:02 +0000]
+	commonLogTime = regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`)
+	// epochTime matches JSON loggers' "ts":1716332462.123
+	epochTime = regexp.MustCompile(`"(?:ts|time|timestamp)":\s*(\d{10})(?:\.(\d{1,9}))?`)
+)
+
+// Levels, from least to most severe
+const (
+	levelNone = iota
+	levelTrace
+	levelDebug
+	levelInfo
+	levelWarn
+	levelError
+	levelFatal
+)
+
+// levelNames name the levels in results and input
+var levelNames = [...]string{"", "trace", "debug", "info", "warn", "error", "fatal"}
+
+var (
+	// levelField matches level=error, "level":"error" and severity: ERROR
+	levelField = regexp.MustCompile(`(?i)\b(?:level|severity|lvl)"?\s*[=:]\s*"?([a-z]+)`)
+	// levelBracket matches nginx's and Apache's [error]
+	levelBracket = regexp.MustCompile(`\[(?i:(trace|debug|info|notice|warn|warning|error|crit|alert|emerg|fatal))\]`)
+	// levelWord matches an upper case level, e.g. ERROR or WARN
+	levelWord = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|CRIT|CRITICAL|ALERT|EMERG|FATAL|PANIC|SEVERE)\b`)
+)
+
+// parseLevel returns a level's rank, levelNone for words that are not one
+func parseLevel(word string) int {
+	switch strings.ToLower(word) {
+	case "trace":
+		return levelTrace
+	case "debug", "dbug":
+		return levelDebug
+	case "info", "notice":
+		return levelInfo
+	case "warn", "warning":
+		return levelWarn
+	case "error", "err", "severe":
+		return levelError
+	case "fatal", "panic", "crit", "critical", "alert", "emerg", "dpanic":
+		return levelFatal
+	}
+	return levelNone
+}
+
+// lineLevel finds the level in the start of a line
+func lineLevel(header string) int {
+	for _, pattern := range []*regexp.Regexp{levelField, levelBracket, levelWord} {
+		if m := pattern.FindStringSubmatch(header); m != nil {
+			if level := parseLevel(m[1]); level != levelNone {
+				return level
+			}
+		}
+	}
+	return levelNone
+}
+
+// lineTime finds the timestamp in the start of a line. Times without a
+// zone are taken in loc; syslog's, without a year, in the year before now
+// when they would otherwise be in the future.
+func lineTime(header string, loc *time.Location, now time.Time) (time.Time, bool) {
+	if m := isoTime.FindStringSubmatch(header); m != nil {
+		zone := loc
+		switch {
+		case m[8] == "Z":
+			zone = time.UTC
+		case m[8] != "":
+			offset := strings.Replace(m[8], ":", "", 1)
+			hours, _ := strconv.Atoi(offset[1:3])
+			minutes, _ := strconv.Atoi(offset[3:5])
+			seconds := hours*3600 + minutes*60
+			if offset[0] == '-' {
+				seconds = -seconds
+			}
+			zone = time.FixedZone("", seconds)
+		}
+		return time.Date(atoi(m[1]), time.Month(atoi(m[2])), atoi(m[3]), atoi(m[4]), atoi(m[5]), atoi(m[6]), nanoseconds(m[7]), zone), true
+	}
+	if m := epochTime.FindStringSubmatch(header); m != nil {
+		return time.Unix(int64(atoi(m[1])), int64(nanoseconds(m[2]))), true
+	}
+	if m := commonLogTime.FindStringSubmatch(header); m != nil {
+		if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1]); err == nil {
+			return t, true
+		}
+	}
+	if m := syslogTime.FindStringSubmatch(header); m != nil {
+		month, err := time.Parse("Jan", m[1])
+		if err != nil {
+			return time.Time{}, false
+		}
+		t := time.Date(now.Year(), month.Month(), atoi(m[2]), atoi(m[3]), atoi(m[4]), atoi(m[5]), 0, loc)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// nanoseconds converts the digits of a fraction of a second
+func nanoseconds(fraction string) int {
+	if fraction == "" {
+		return 0
+	}
+	return atoi((fraction + "000000000")[:9])
+}
+
+// header returns the start of a line, where timestamps and levels are
+func header(line string) string {
+	if len(line) > headerBytes {
+		return line[:headerBytes]
+	}
+	return line
+}
+
+// lineReader reads lines of any length, keeping at most maxLineBytes of
+// each
+type lineReader struct {
+	reader *bufio.Reader
+	line   []byte
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// next returns the next line without its line ending, or io.EOF
+func (r *lineReader) next() (string, error) {
+	r.line = r.line[:0]
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if len(r.line) < maxLineBytes {
+			r.line = append(r.line, chunk[:min(len(chunk), maxLineBytes-len(r.line))]...)
+		}
+		switch err {
+		case nil:
+			return string(bytes.TrimRight(r.line, "\r\n")), nil
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			if len(r.line) > 0 {
+				return string(bytes.TrimRight(r.line, "\r\n")), nil
+			}
+			return "", io.EOF
+		default:
+			return "", err
+		}
+	}
+}
+
+// seekSince moves a file's read position close to the first line at or
+// after since, assuming its lines are in time order. It returns the offset
+// it moved to, 0 when the file is read from the start.
+func seekSince(file io.ReadSeeker, size int64, since time.Time, loc *time.Location, now time.Time) (int64, error) {
+	// Searching is not worth it for files read in a moment
+	const window = 1 << 20
+	low, high := int64(0), size
+	for high-low > window {
+		middle := low + (high-low)/2
+		t, found, err := firstTimeAfter(file, middle, loc, now)
+		if err != nil {
+			return 0, err
+		}
+		if found && t.Before(since) {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	if low == 0 {
+		_, err := file.Seek(0, io.SeekStart)
+		return 0, err
+	}
+	// Start at the line after low, which begins before since
+	if _, err := file.Seek(low, io.SeekStart); err != nil {
+		return 0, err
+	}
+	skipped, err := bufio.NewReader(io.LimitReader(file, maxLineBytes)).ReadSlice('\n')
+	if err != nil && err != bufio.ErrBufferFull {
+		return 0, err
+	}
+	offset := low + int64(len(skipped))
+	_, err = file.Seek(offset, io.SeekStart)
+	return offset, err
+}
+
+// firstTimeAfter returns the timestamp of the first line that starts after
+// offset and has one, looking at most 64 KB ahead
+func firstTimeAfter(file io.ReadSeeker, offset int64, loc *time.Location, now time.Time) (time.Time, bool, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return time.Time{}, false, err
+	}
+	lines := newLineReader(io.LimitReader(file, 64<<10))
+	if _, err := lines.next(); err != nil { // The rest of the line offset is in
+		return time.Time{}, false, nil
+	}
+	for {
+		line, err := lines.next()
+		if err != nil {
+			return time.Time{}, false, nil
+		}
+		if t, ok := lineTime(header(line), loc, now); ok {
+			return t, true, nil
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxPatterns is how many distinct messages are counted; more are
+	// counted together as others
+	maxPatterns = 5000
+	// topPatterns is how many of the most frequent messages are listed
+	topPatterns = 15
+	// maxPatternBytes is how much of a message is kept as its pattern
+	maxPatternBytes = 160
+	// maxSampleBytes is how much of a matching line is shown
+	maxSampleBytes = 400
+	// maxBuckets is about how many rows the histogram has
+	maxBuckets = 30
+)
+
+// bucketSizes are the histogram's row widths to choose from
+var bucketSizes = []time.Duration{
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// variable matches the parts of messages that differ between occurrences
+// of the same one: UUIDs, IP addresses with ports, hex values and numbers
+var variable = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b|\b0x[0-9a-fA-F]+\b|\b[0-9a-f]{12,}\b|\d+(?:\.\d+)?`)
+
+// pattern counts the lines of one message
+type pattern struct {
+	text        string
+	count       int
+	first, last time.Time
+	example     string
+}
+
+// sample is a matching line and where it is
+type sample struct {
+	where string // file:line, or the file when the line number is unknown
+	text  string
+}
+
+// summary counts the lines that match
+type summary struct {
+	files       []string
+	scanned     int
+	matched     int
+	undated     int // Lines left out of a time range for having no timestamp
+	first, last time.Time
+	levels      [levelFatal + 1]int
+	minutes     map[int64]int // Matching lines per minute since the epoch
+	patterns    map[string]*pattern
+	others      int // Lines whose message was not counted, past maxPatterns
+	tail        []sample
+	tailSize    int
+	tailNext    int
+}
+
+func newSummary(tailSize int) *summary {
+	return &summary{minutes: map[int64]int{}, patterns: map[string]*pattern{}, tailSize: tailSize}
+}
+
+// add counts a matching line
+func (s *summary) add(line, where string, t time.Time, dated bool, level int) {
+	s.matched++
+	s.levels[level]++
+	if dated {
+		if s.first.IsZero() || t.Before(s.first) {
+			s.first = t
+		}
+		if t.After(s.last) {
+			s.last = t
+		}
+		s.minutes[t.Unix()/60]++
+	}
+
+	text := messagePattern(line)
+	p := s.patterns[text]
+	switch {
+	case p != nil:
+	case len(s.patterns) < maxPatterns:
+		p = &pattern{text: text, example: line}
+		s.patterns[text] = p
+	default:
+		s.others++
+	}
+	if p != nil {
+		p.count++
+		if dated {
+			if p.first.IsZero() || t.Before(p.first) {
+				p.first = t
+			}
+			if t.After(p.last) {
+				p.last = t
+			}
+		}
+	}
+
+	if s.tailSize > 0 {
+		entry := sample{where: where, text: line}
+		if len(s.tail) < s.tailSize {
+			s.tail = append(s.tail, entry)
+		} else {
+			s.tail[s.tailNext] = entry
+			s.tailNext = (s.tailNext + 1) % s.tailSize
+		}
+	}
+}
+
+// messagePattern is a line without its timestamp, with the values that
+// differ between occurrences of the same message replaced by placeholders
+func messagePattern(line string) string {
+	head := header(line)
+	for _, timestamp := range []*regexp.Regexp{isoTime, commonLogTime, syslogTime} {
+		if loc := timestamp.FindStringIndex(head); loc != nil {
+			line = line[:loc[0]] + line[loc[1]:]
+			break
+		}
+	}
+	line = variable.ReplaceAllStringFunc(line, func(value string) string {
+		switch {
+		case strings.Count(value, "-") == 4:
+			return "<uuid>"
+		case strings.Count(value, ".") >= 3:
+			return "<ip>"
+		case strings.HasPrefix(value, "0x") || len(value) >= 12 && strings.ContainsAny(value, "abcdef"):
+			return "<hex>"
+		}
+		return "<n>"
+	})
+	line = strings.Join(strings.Fields(line), " ")
+	return truncate(line, maxPatternBytes)
+}
+
+// truncate shortens text to at most n bytes, on a character boundary
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !isRuneStart(text[n]) {
+		n--
+	}
+	return text[:n] + "..."
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// format writes the summary: what was read, the counts by level and over
+// time, the most frequent messages, and the last matching lines
+func (s *summary) format(filters string, loc *time.Location) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Scanned %s of %s", count(s.scanned, "line"), strings.Join(s.files, ", "))
+	if filters == "" {
+		filters = "all lines"
+	}
+	fmt.Fprintf(&text, "\nMatching %s: %s", filters, count(s.matched, "line"))
+	if !s.first.IsZero() {
+		fmt.Fprintf(&text, ", from %s to %s", s.first.In(loc).Format(time.DateTime), s.last.In(loc).Format(time.DateTime))
+	}
+	if s.undated > 0 {
+		fmt.Fprintf(&text, "\n%s without a timestamp left out of the time range", count(s.undated, "line"))
+	}
+	if s.matched == 0 {
+		return text.String()
+	}
+
+	var levels []string
+	for level := len(levelNames) - 1; level > levelNone; level-- {
+		if s.levels[level] > 0 {
+			levels = append(levels, fmt.Sprintf("%s %d", strings.ToUpper(levelNames[level]), s.levels[level]))
+		}
+	}
+	if len(levels) > 0 {
+		if s.levels[levelNone] > 0 {
+			levels = append(levels, fmt.Sprintf("no level %d", s.levels[levelNone]))
+		}
+		fmt.Fprintf(&text, "\n\nBy level: %s", strings.Join(levels, ", "))
+	}
+
+	if histogram := s.histogram(loc); histogram != "" {
+		text.WriteString("\n\n" + histogram)
+	}
+
+	patterns := make([]*pattern, 0, len(s.patterns))
+	for _, p := range s.patterns {
+		patterns = append(patterns, p)
+	}
+	slices.SortFunc(patterns, func(a, b *pattern) int {
+		if a.count != b.count {
+			return b.count - a.count
+		}
+		return strings.Compare(a.text, b.text)
+	})
+	fmt.Fprintf(&text, "\n\nMost frequent messages (%d distinct", len(patterns))
+	if s.others > 0 {
+		fmt.Fprintf(&text, ", and %d lines of others not counted", s.others)
+	}
+	text.WriteString("):")
+	for _, p := range patterns[:min(len(patterns), topPatterns)] {
+		fmt.Fprintf(&text, "\n%7d  %s", p.count, p.text)
+		if !p.first.IsZero() {
+			fmt.Fprintf(&text, "  (%s to %s)", p.first.In(loc).Format(time.DateTime), p.last.In(loc).Format(time.DateTime))
+		}
+		if p.count > 1 || p.example != p.text {
+			fmt.Fprintf(&text, "\n         e.g. %s", truncate(p.example, maxSampleBytes))
+		}
+	}
+
+	if len(s.tail) > 0 {
+		fmt.Fprintf(&text, "\n\nLast %s:", count(len(s.tail), "matching line"))
+		for i := range s.tail {
+			entry := s.tail[(s.tailNext+i)%len(s.tail)]
+			fmt.Fprintf(&text, "\n%s: %s", entry.where, truncate(entry.text, maxSampleBytes))
+		}
+	}
+	return text.String()
+}
+
+// histogram counts matching lines over time, in rows of a width that
+// keeps them to about maxBuckets. Empty rows are shown, so gaps stand out.
+func (s *summary) histogram(loc *time.Location) string {
+	if len(s.minutes) < 2 {
+		return ""
+	}
+	span := s.last.Sub(s.first)
+	size := bucketSizes[len(bucketSizes)-1]
+	for _, candidate := range bucketSizes {
+		if span/candidate < maxBuckets {
+			size = candidate
+			break
+		}
+	}
+
+	// Rows start at whole multiples of their width in loc, e.g. on the hour
+	_, offset := s.first.In(loc).Zone()
+	shift := int64(offset) / 60
+	width := int64(size / time.Minute)
+	counts := map[int64]int{}
+	for minute, n := range s.minutes {
+		counts[floorDiv(minute+shift, width)] += n
+	}
+	firstRow, lastRow := floorDiv(s.first.Unix()/60+shift, width), floorDiv(s.last.Unix()/60+shift, width)
+
+	peak := 0
+	for _, n := range counts {
+		peak = max(peak, n)
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "Over time (per %s):", formatDuration(size))
+	layout := time.DateTime[:len("2006-01-02 15:04")]
+	for row := firstRow; row <= lastRow; row++ {
+		start := time.Unix((row*width-shift)*60, 0).In(loc)
+		n := counts[row]
+		bar := strings.Repeat("#", (n*40+peak-1)/peak)
+		fmt.Fprintf(&text, "\n%s %7d %s", start.Format(layout), n, bar)
+	}
+	return text.String()
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// formatDuration writes a bucket width: "minute", "5 minutes", "hour", "day"
+func formatDuration(d time.Duration) string {
+	switch {
+	case d == time.Minute:
+		return "minute"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	case d == time.Hour:
+		return "hour"
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	case d == 24*time.Hour:
+		return "day"
+	}
+	return "week"
+}
+
+// count writes a number of things, e.g. "1 line" or "3 lines"
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,140 @@
+package openapi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// object is a JSON or YAML object that keeps its keys in document order,
+// so properties and paths are listed as the spec's authors wrote them
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+// get returns the value of key, nil when it is not set or o is nil
+func (o *object) get(key string) any {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+// object returns the value of key when it is an object
+func (o *object) object(key string) *object {
+	value, _ := o.get(key).(*object)
+	return value
+}
+
+// string returns the value of key when it is a string
+func (o *object) string(key string) string {
+	value, _ := o.get(key).(string)
+	return value
+}
+
+// list returns the value of key when it is a list
+func (o *object) list(key string) []any {
+	value, _ := o.get(key).([]any)
+	return value
+}
+
+// strings returns the strings in the list value of key
+func (o *object) strings(key string) []string {
+	var values []string
+	for _, value := range o.list(key) {
+		values = append(values, fmt.Sprint(value))
+	}
+	return values
+}
+
+// parseDocument parses a JSON or YAML document
+func parseDocument(data []byte) (*object, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("the document is empty")
+	}
+	document, ok := fromNode(root.Content[0]).(*object)
+	if !ok {
+		return nil, fmt.Errorf("the document is not an object")
+	}
+	return document, nil
+}
+
+// fromNode converts a YAML node to objects, lists and scalar values. Keys
+// are kept as written, so response codes stay strings such as "200".
+func fromNode(node *yaml.Node) any {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return fromNode(node.Alias)
+	case yaml.MappingNode:
+		o := &object{values: make(map[string]any, len(node.Content)/2)}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if _, exists := o.values[key]; !exists {
+				o.keys = append(o.keys, key)
+			}
+			o.values[key] = fromNode(node.Content[i+1])
+		}
+		return o
+	case yaml.SequenceNode:
+		values := make([]any, len(node.Content))
+		for i, item := range node.Content {
+			values[i] = fromNode(item)
+		}
+		return values
+	}
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	return value
+}
+
+// resolve follows $ref pointers within the document from value, returning
+// the object they lead to and the last reference followed. References to
+// other documents cannot be followed and are returned as they are.
+func (s *spec) resolve(value any) (*object, string) {
+	o, _ := value.(*object)
+	ref := ""
+	for range 32 { // Bounds chains of references to references
+		next := o.string("$ref")
+		if next == "" || !strings.HasPrefix(next, "#") {
+			return o, ref
+		}
+		target, ok := s.pointer(next).(*object)
+		if !ok {
+			return o, ref
+		}
+		o, ref = target, next
+	}
+	return o, ref
+}
+
+// pointer looks up a JSON pointer such as #/components/schemas/User
+func (s *spec) pointer(ref string) any {
+	fragment, err := url.PathUnescape(strings.TrimPrefix(ref, "#"))
+	if err != nil {
+		return nil
+	}
+	var value any = s.document
+	for _, token := range strings.Split(strings.TrimPrefix(fragment, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		o, ok := value.(*object)
+		if !ok {
+			return nil
+		}
+		value = o.get(token)
+	}
+	return value
+}
+
+// refName is the last part of a reference: User for #/components/schemas/User
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
@@ -0,0 +1,58 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// petstore is the spec the openapi tool is fuzzed with, from the workspace
+// and from any URL
+const petstore = `openapi: 3.0.3
+info: {title: Petstore, version: "1"}
+paths:
+  /pet/{petId}:
+    parameters:
+      - {name: petId, in: path, required: true, schema: {type: integer}}
+    get:
+      operationId: getPet
+      tags: [pet]
+      responses:
+        "200": {description: A pet, content: {application/json: {schema: {$ref: "#/components/schemas/Pet"}}}}
+  /invoices:
+    post:
+      operationId: createInvoice
+      summary: Create an invoice
+      requestBody: {content: {application/json: {schema: {$ref: "#/components/schemas/Invoice"}}}}
+      responses: {"201": {description: Created}}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: integer}
+        parent: {$ref: "#/components/schemas/Pet"}
+    Invoice:
+      allOf:
+        - $ref: "#/components/schemas/Pet"
+        - {type: object, properties: {total: {type: number}}}
+`
+
+// offline serves petstore for every URL, so fuzzing never reaches the network
+type offline struct{}
+
+func (offline) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(petstore)), Request: req}, nil
+}
+
+func FuzzOpenAPI(f *testing.F) {
+	SetHTTPClient(&http.Client{Transport: offline{}})
+	toolstest.Seed(f, OpenAPITool{}, `{"source": "api/openapi.yaml", "operation": "createInvoice"}`, `{"source": "api/openapi.yaml", "operation": "get /pet/{petId}"}`,
+		`{"source": "api/missing.yaml"}`, `{"source": "http://[::1"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, OpenAPITool{}, toolstest.Context(t, map[string]string{"api/openapi.yaml": petstore}), input)
+	})
+}
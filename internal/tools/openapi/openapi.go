@@ -0,0 +1,508 @@
+// Package openapi provides a tool that reads OpenAPI 3 and Swagger 2 specs,
+// from the workspace or a URL, and describes their operations: parameters,
+// request bodies and responses with their schemas
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam       = "parameter %q is required"
+	errMsgNotSpec            = "%s is not an OpenAPI 3 or Swagger 2 document: it has neither an \"openapi\" nor a \"swagger\" version"
+	errMsgUnknownOperation   = "%s has no operation %q"
+	errMsgAmbiguousOperation = "%q matches more than one operation: %s; use \"METHOD /path\""
+)
+
+const (
+	// fetchTimeout bounds downloading a spec
+	fetchTimeout = 30 * time.Second
+	// maxSpecBytes is the largest spec that is read
+	maxSpecBytes = 20 << 20
+	// maxOutputBytes is how much of a listing or operation is returned
+	maxOutputBytes = 30000
+	// maxSuggestions is how many operations an unknown one suggests
+	maxSuggestions = 10
+)
+
+// methods are the operations of a path item, in the order they are listed
+var methods = []string{"get", "put", "post", "patch", "delete", "head", "options", "trace"}
+
+// httpClient fetches specs given by URL; see SetHTTPClient
+var httpClient = http.DefaultClient
+
+// SetHTTPClient sets the client specs are fetched with, so they honor the
+// network config's proxy and CA settings. Call it before tools run.
+func SetHTTPClient(client *http.Client) {
+	if client != nil {
+		httpClient = client
+	}
+}
+
+// settings is the openapi config in effect; see Configure
+var settings config.OpenAPIConfig
+
+// approvedHosts are the hosts the user let specs be fetched from during the
+// session, in lower case
+var approvedHosts sync.Map
+
+// Configure sets the hosts specs are fetched from without asking. Call it
+// before tools run.
+func Configure(cfg config.OpenAPIConfig) {
+	settings = cfg
+}
+
+// OpenAPIInput represents the input parameters for the openapi tool
+type OpenAPIInput struct {
+	Source    string `json:"source" jsonschema:"required" jsonschema_description:"Path of the spec in the workspace, or its http(s) URL; JSON or YAML"`
+	Operation string `json:"operation,omitempty" jsonschema_description:"Operation to describe: its operationId, or METHOD /path as listed (e.g. POST /pets/{id}); lists the operations when omitted"`
+	Filter    string `json:"filter,omitempty" jsonschema_description:"When listing: only operations whose method, path, operationId, summary or tags contain this text"`
+}
+
+// Validate implements input validation
+func (i *OpenAPIInput) Validate() error {
+	if strings.TrimSpace(i.Source) == "" {
+		return fmt.Errorf(errMsgMissingParam, "source")
+	}
+	if i.Operation != "" && i.Filter != "" {
+		return fmt.Errorf("parameter \"filter\" applies only to listing, without \"operation\"")
+	}
+	return nil
+}
+
+type OpenAPITool struct{}
+
+func (t OpenAPITool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:      "openapi",
+		Group:     "openapi",
+		Cacheable: true,
+		Paths:     specPaths,
+		Description: `Read an OpenAPI 3 or Swagger 2 spec, JSON or YAML, from the workspace or a URL.
+Without an operation, lists the spec's operations; with one, describes it: its parameters,
+request body and responses, with their schemas written out in full.
+
+Usage Examples:
+- {"source": "api/openapi.yaml"} // List every operation
+- {"source": "api/openapi.yaml", "filter": "invoice"} // Only operations about invoices
+- {"source": "api/openapi.yaml", "operation": "createInvoice"} // By operationId
+- {"source": "https://petstore3.swagger.io/api/v3/openapi.json", "operation": "GET /pet/{petId}"}
+
+Describe an operation before writing a client call or handler for it, instead of guessing
+field names, types and required fields. References within the spec are followed; references
+to other files are named but not read.`,
+		InputSchema: schema.GenerateSchema[OpenAPIInput](),
+	}
+}
+
+func (t OpenAPITool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var openAPIInput OpenAPIInput
+	if err := tools.DecodeInput(input, &openAPIInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := openAPIInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	s, err := load(ctx, toolCtx, openAPIInput.Source)
+	if err != nil {
+		return nil, err
+	}
+	if openAPIInput.Operation == "" {
+		return tools.NewTextResult(s.fetchedFrom() + firstBytes(s.list(openAPIInput.Filter))), nil
+	}
+	op, err := s.find(openAPIInput.Operation)
+	if err != nil {
+		return nil, err
+	}
+	return tools.NewTextResult(s.fetchedFrom() + firstBytes(s.describe(op))), nil
+}
+
+// specPaths returns the spec file a call reads; URLs are not paths
+func specPaths(_ *tools.ToolContext, input json.RawMessage) []string {
+	var openAPIInput OpenAPIInput
+	_ = json.Unmarshal(input, &openAPIInput)
+	if openAPIInput.Source == "" || isURL(openAPIInput.Source) {
+		return nil
+	}
+	return []string{openAPIInput.Source}
+}
+
+// isURL reports whether a source is fetched rather than read
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// load reads and parses the spec at a URL or workspace path
+func load(ctx context.Context, toolCtx *tools.ToolContext, source string) (*spec, error) {
+	var data []byte
+	var err error
+	shown, host := source, ""
+	if isURL(source) {
+		data, host, err = fetch(ctx, toolCtx, source)
+	} else {
+		data, shown, err = readFile(toolCtx, source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := parseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", shown, err)
+	}
+	s := &spec{document: document, source: shown, host: host}
+	switch {
+	case strings.HasPrefix(fmt.Sprint(document.get("openapi")), "3"):
+		s.version = "OpenAPI " + fmt.Sprint(document.get("openapi"))
+	case fmt.Sprint(document.get("swagger")) == "2.0":
+		s.version, s.swagger = "Swagger 2.0", true
+	default:
+		return nil, tools.InvalidInput(fmt.Errorf(errMsgNotSpec, shown))
+	}
+	return s, nil
+}
+
+// fetch downloads a spec, once the user approves its host, and returns it
+// with the host it came from
+func fetch(ctx context.Context, toolCtx *tools.ToolContext, source string) ([]byte, string, error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return nil, "", tools.InvalidInput(fmt.Errorf("invalid URL %q", source))
+	}
+	host := strings.ToLower(u.Host)
+	if err := confirmHost(toolCtx, u); err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, "", tools.InvalidInput(fmt.Errorf("invalid URL %q: %w", source, err))
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.5")
+
+	// Approval is for a host, so redirects may not leave it
+	client := *httpClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !strings.EqualFold(req.URL.Host, host) {
+			return fmt.Errorf("redirected to %s, another host than the one approved", req.URL.Host)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch %s: %w", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, "", tools.NotFound(fmt.Errorf("failed to fetch %s: %s", source, resp.Status))
+		}
+		return nil, "", fmt.Errorf("failed to fetch %s: %s", source, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSpecBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch %s: %w", source, err)
+	}
+	if len(data) > maxSpecBytes {
+		return nil, "", fmt.Errorf("%s is larger than %d MB", source, maxSpecBytes>>20)
+	}
+	return data, host, nil
+}
+
+// confirmHost asks the user before the first fetch from a host that is not
+// in openapi.hosts. The request is forced: a model reading a spec must not
+// reach hosts of its choosing, the local network's included, because the
+// tool is allowed or the session auto-approves.
+func confirmHost(toolCtx *tools.ToolContext, u *url.URL) error {
+	host := strings.ToLower(u.Host)
+	if _, ok := approvedHosts.Load(host); ok {
+		return nil
+	}
+	for _, allowed := range settings.Hosts {
+		if strings.EqualFold(allowed, u.Host) || strings.EqualFold(allowed, u.Hostname()) {
+			return nil
+		}
+	}
+	approved := toolCtx.Confirm(confirm.Request{
+		Tool:    "openapi",
+		Action:  "fetch an API spec from " + host,
+		Preview: u.Redacted(),
+		Force:   true,
+	})
+	if !approved {
+		return tools.PermissionDenied(fmt.Errorf("permission denied: fetching from %s was not approved; list it under openapi.hosts to fetch from it without asking", u.Hostname()))
+	}
+	approvedHosts.Store(host, true)
+	return nil
+}
+
+// readFile reads a spec in the workspace, asking the user first when the
+// path policy says to
+func readFile(toolCtx *tools.ToolContext, path string) (data []byte, shown string, err error) {
+	resolved, shown := path, path
+	ws := workspaceOf(toolCtx)
+	if ws != nil {
+		p, err := ws.ResolvePath(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "read a spec outside the workspace", Path: p.Abs}) {
+			return nil, "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+		}
+		resolved, shown = p.Abs, p.Shown
+	}
+	fsys := ws.FS()
+	info, err := fsys.Stat(resolved)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, "", tools.NotFound(fmt.Errorf("%s does not exist", shown))
+	case err != nil:
+		return nil, "", err
+	case info.IsDir():
+		return nil, "", tools.InvalidInput(fmt.Errorf("%s is a directory; give the path of the spec file", shown))
+	case info.Size() > maxSpecBytes:
+		return nil, "", fmt.Errorf("%s is larger than %d MB", shown, maxSpecBytes>>20)
+	}
+	data, err = fsys.ReadFile(resolved)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", shown, err)
+	}
+	return data, shown, nil
+}
+
+// spec is a parsed OpenAPI 3 or Swagger 2 document
+type spec struct {
+	document *object
+	source   string // Path or URL the spec was read from
+	host     string // Host a spec given by URL was fetched from
+	version  string // e.g. OpenAPI 3.0.3
+	swagger  bool   // Swagger 2.0 rather than OpenAPI 3
+}
+
+// operation is one method of one path
+type operation struct {
+	method   string // Upper case
+	path     string
+	pathItem *object
+	op       *object
+}
+
+// id returns the operation's operationId, "" when it has none
+func (o operation) id() string {
+	return o.op.string("operationId")
+}
+
+// operations returns every operation, in the order of the spec's paths
+func (s *spec) operations() []operation {
+	var operations []operation
+	paths := s.document.object("paths")
+	if paths == nil {
+		return nil
+	}
+	for _, path := range paths.keys {
+		pathItem, _ := s.resolve(paths.get(path))
+		for _, method := range methods {
+			if op := pathItem.object(method); op != nil {
+				operations = append(operations, operation{method: strings.ToUpper(method), path: path, pathItem: pathItem, op: op})
+			}
+		}
+	}
+	return operations
+}
+
+// find returns the operation with an operationId, or of a method and path
+func (s *spec) find(name string) (operation, error) {
+	operations := s.operations()
+	method, path, isPath := strings.Cut(strings.TrimSpace(name), " ")
+	path = strings.TrimSpace(path)
+	var matches []operation
+	for _, op := range operations {
+		if isPath && strings.EqualFold(op.method, method) && op.path == path || op.id() == name {
+			return op, nil
+		}
+		if strings.EqualFold(op.id(), name) {
+			matches = append(matches, op)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+	default:
+		var names []string
+		for _, op := range matches {
+			names = append(names, op.method+" "+op.path)
+		}
+		return operation{}, tools.InvalidInput(fmt.Errorf(errMsgAmbiguousOperation, name, strings.Join(names, ", ")))
+	}
+
+	// Suggest the operations the name has a part of, e.g. its path
+	err := fmt.Errorf(errMsgUnknownOperation, s.source, name)
+	needle := strings.ToLower(name)
+	if isPath {
+		needle = strings.ToLower(path)
+	}
+	var similar []string
+	for _, op := range operations {
+		if strings.Contains(strings.ToLower(op.path+" "+op.id()), needle) && len(similar) < maxSuggestions {
+			similar = append(similar, op.line())
+		}
+	}
+	if len(similar) > 0 {
+		return operation{}, tools.NotFound(fmt.Errorf("%w. Similar operations:\n%s", err, strings.Join(similar, "\n")))
+	}
+	return operation{}, tools.NotFound(fmt.Errorf("%w; list the operations by leaving out \"operation\"", err))
+}
+
+// line is the operation's line in a listing:
+//
+//	POST /pets  createPet - Create a pet
+func (o operation) line() string {
+	line := o.method + " " + o.path
+	if id := o.id(); id != "" {
+		line += "  " + id
+	}
+	if summary := o.summary(); summary != "" {
+		line += " - " + summary
+	}
+	if o.op.get("deprecated") == true {
+		line += " (deprecated)"
+	}
+	return line
+}
+
+// summary returns the operation's summary, or the start of its description
+func (o operation) summary() string {
+	if summary := oneLine(o.op.string("summary")); summary != "" {
+		return summary
+	}
+	description, _, _ := strings.Cut(strings.TrimSpace(o.op.string("description")), "\n")
+	return oneLine(description)
+}
+
+// list lists the operations that contain filter
+func (s *spec) list(filter string) string {
+	info := s.document.object("info")
+	var text strings.Builder
+	title := info.string("title")
+	if title == "" {
+		title = s.source
+	}
+	fmt.Fprintf(&text, "%s", title)
+	if version := info.get("version"); version != nil {
+		fmt.Fprintf(&text, " %v", version)
+	}
+	fmt.Fprintf(&text, " (%s)", s.version)
+	if servers := s.servers(nil, nil); len(servers) > 0 {
+		fmt.Fprintf(&text, "\nBase URL: %s", strings.Join(servers, ", "))
+	}
+
+	operations := s.operations()
+	var lines []string
+	for _, op := range operations {
+		line := op.line()
+		if filter != "" && !strings.Contains(strings.ToLower(line+" "+strings.Join(op.op.strings("tags"), " ")), strings.ToLower(filter)) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	switch {
+	case len(operations) == 0:
+		text.WriteString("\n\nThe spec has no operations.")
+	case filter == "":
+		fmt.Fprintf(&text, "\n\n%d operations:\n%s", len(lines), strings.Join(lines, "\n"))
+	case len(lines) == 0:
+		fmt.Fprintf(&text, "\n\nNone of the %d operations match %q.", len(operations), filter)
+	default:
+		fmt.Fprintf(&text, "\n\n%d of %d operations match %q:\n%s", len(lines), len(operations), filter, strings.Join(lines, "\n"))
+	}
+	return text.String()
+}
+
+// servers returns the base URLs of an operation, or of the spec when op is
+// nil
+func (s *spec) servers(pathItem, op *object) []string {
+	if s.swagger {
+		host := s.document.string("host")
+		if host == "" {
+			return nil
+		}
+		scheme := "https"
+		if schemes := op.strings("schemes"); len(schemes) > 0 {
+			scheme = schemes[0]
+		} else if schemes := s.document.strings("schemes"); len(schemes) > 0 {
+			scheme = schemes[0]
+		}
+		return []string{scheme + "://" + host + s.document.string("basePath")}
+	}
+	list := op.list("servers")
+	if list == nil {
+		list = pathItem.list("servers")
+	}
+	if list == nil {
+		list = s.document.list("servers")
+	}
+	var urls []string
+	for _, server := range list {
+		if server, ok := server.(*object); ok && server.string("url") != "" {
+			urls = append(urls, server.string("url"))
+		}
+	}
+	return urls
+}
+
+// fetchedFrom names the host of a fetched spec, ahead of the output
+func (s *spec) fetchedFrom() string {
+	if s.host == "" {
+		return ""
+	}
+	return "Fetched from " + s.host + "\n\n"
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of output within maxOutputBytes, up to the
+// end of a line
+func firstBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(output[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted; a filter narrows listings] ...", output[:cut], len(output)-cut)
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(OpenAPITool{})
+}
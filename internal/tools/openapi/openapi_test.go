@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/tools"
+)
+
+// answer approves or declines every request and counts them
+type answer struct {
+	approve bool
+	asked   int
+}
+
+func (a *answer) Confirm(req confirm.Request) bool {
+	a.asked++
+	return a.approve
+}
+
+// counting serves petstore and counts the requests that reach it
+type counting struct {
+	requests int
+}
+
+func (c *counting) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return offline{}.RoundTrip(req)
+}
+
+func TestFetchAsksForHost(t *testing.T) {
+	transport := &counting{}
+	SetHTTPClient(&http.Client{Transport: transport})
+	Configure(config.OpenAPIConfig{Hosts: []string{"specs.example.com"}})
+	defer Configure(config.OpenAPIConfig{})
+
+	run := func(source string, confirmer confirm.Confirmer) (*tools.ToolResult, error) {
+		input, _ := json.Marshal(OpenAPIInput{Source: source})
+		return OpenAPITool{}.Execute(context.Background(), &tools.ToolContext{Confirmer: confirmer}, input)
+	}
+
+	declined := &answer{}
+	if _, err := run("http://169.254.169.254/openapi.json", declined); tools.Classify(err) != tools.ErrorPermissionDenied {
+		t.Errorf("declined fetch: err = %v, want permission denied", err)
+	}
+	if declined.asked != 1 || transport.requests != 0 {
+		t.Errorf("declined fetch asked %d times and made %d requests, want 1 and 0", declined.asked, transport.requests)
+	}
+
+	approved := &answer{approve: true}
+	for range 2 {
+		result, err := run("https://api.example.net/openapi.yaml", approved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(result.Text(), "Fetched from api.example.net\n") {
+			t.Errorf("result does not name the host:\n%s", result.Text())
+		}
+	}
+	if approved.asked != 1 {
+		t.Errorf("an approved host was asked about %d times, want once", approved.asked)
+	}
+
+	listed := &answer{}
+	if _, err := run("https://specs.example.com:8443/v1/openapi.yaml", listed); err != nil || listed.asked != 0 {
+		t.Errorf("fetch from openapi.hosts asked %d times, err = %v", listed.asked, err)
+	}
+}
@@ -0,0 +1,301 @@
+package openapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxOperationDescription is how much of an operation's description is kept
+const maxOperationDescription = 2000
+
+// describe writes out an operation: what it is, how it is authorized, its
+// parameters, request body and responses. Each named schema is written out
+// once; later uses refer back to it.
+func (s *spec) describe(o operation) string {
+	w := newSchemaWriter(s)
+	var text strings.Builder
+	text.WriteString(o.method + " " + o.path)
+	if id := o.id(); id != "" {
+		fmt.Fprintf(&text, "  (operationId %s)", id)
+	}
+	if o.op.get("deprecated") == true {
+		text.WriteString("  DEPRECATED")
+	}
+	if summary := oneLine(o.op.string("summary")); summary != "" {
+		text.WriteString("\n" + summary)
+	}
+	if description := strings.TrimSpace(o.op.string("description")); description != "" {
+		if len(description) > maxOperationDescription {
+			description = description[:maxOperationDescription] + "..."
+		}
+		text.WriteString("\n" + description)
+	}
+	if tags := o.op.strings("tags"); len(tags) > 0 {
+		fmt.Fprintf(&text, "\nTags: %s", strings.Join(tags, ", "))
+	}
+	if servers := s.servers(o.pathItem, o.op); len(servers) > 0 {
+		fmt.Fprintf(&text, "\nBase URL: %s", strings.Join(servers, ", "))
+	}
+	if security := s.security(o.op); security != "" {
+		fmt.Fprintf(&text, "\nAuthorization: %s", security)
+	}
+
+	var bodyParam, formParams []*object
+	parameters := s.parameters(o)
+	if len(parameters) > 0 {
+		var lines []string
+		for _, param := range parameters {
+			switch param.string("in") {
+			case "body":
+				bodyParam = append(bodyParam, param)
+				continue
+			case "formData":
+				formParams = append(formParams, param)
+				continue
+			}
+			lines = append(lines, s.parameter(w, param))
+		}
+		if len(lines) > 0 {
+			text.WriteString("\n\nParameters:\n" + strings.Join(lines, "\n"))
+		}
+	}
+
+	if s.swagger {
+		consumes := o.op.strings("consumes")
+		if consumes == nil {
+			consumes = s.document.strings("consumes")
+		}
+		switch {
+		case len(bodyParam) > 0:
+			text.WriteString("\n\nRequest body")
+			if bodyParam[0].get("required") == true {
+				text.WriteString(" (required)")
+			}
+			fmt.Fprintf(&text, ": %s\n  %s", mediaTypes(consumes, "application/json"), w.describe(bodyParam[0].get("schema"), "  ", nil))
+		case len(formParams) > 0:
+			fmt.Fprintf(&text, "\n\nRequest body: %s", mediaTypes(consumes, "application/x-www-form-urlencoded"))
+			for _, param := range formParams {
+				text.WriteString("\n" + s.parameter(w, param))
+			}
+		}
+	} else if body, _ := s.resolve(o.op.get("requestBody")); body != nil {
+		text.WriteString("\n\nRequest body")
+		if body.get("required") == true {
+			text.WriteString(" (required)")
+		}
+		if description := oneLine(body.string("description")); description != "" {
+			text.WriteString(" - " + description)
+		}
+		text.WriteString(":")
+		s.content(&text, w, body.object("content"))
+	}
+
+	responses := o.op.object("responses")
+	if responses != nil && len(responses.keys) > 0 {
+		text.WriteString("\n\nResponses:")
+		produces := o.op.strings("produces")
+		if produces == nil {
+			produces = s.document.strings("produces")
+		}
+		for _, code := range responses.keys {
+			response, _ := s.resolve(responses.get(code))
+			fmt.Fprintf(&text, "\n%s", code)
+			if description := oneLine(response.string("description")); description != "" {
+				text.WriteString(" - " + description)
+			}
+			if s.swagger {
+				if response.get("schema") != nil {
+					fmt.Fprintf(&text, ": %s\n  %s", mediaTypes(produces, "application/json"), w.describe(response.get("schema"), "  ", nil))
+				}
+			} else if content := response.object("content"); content != nil {
+				text.WriteString(":")
+				s.content(&text, w, content)
+			}
+			if headers := response.object("headers"); headers != nil {
+				for _, name := range headers.keys {
+					header, _ := s.resolve(headers.get(name))
+					fmt.Fprintf(&text, "\n  header %s: %s", name, s.paramSchema(w, header, "  "))
+				}
+			}
+		}
+	}
+	return text.String()
+}
+
+// parameters returns the parameters of an operation and its path, those of
+// the operation replacing those of the path with the same name and place
+func (s *spec) parameters(o operation) []*object {
+	var parameters []*object
+	index := map[string]int{}
+	for _, list := range [][]any{o.pathItem.list("parameters"), o.op.list("parameters")} {
+		for _, value := range list {
+			param, _ := s.resolve(value)
+			if param == nil {
+				continue
+			}
+			key := param.string("in") + " " + param.string("name")
+			if i, ok := index[key]; ok {
+				parameters[i] = param
+				continue
+			}
+			index[key] = len(parameters)
+			parameters = append(parameters, param)
+		}
+	}
+	return parameters
+}
+
+// parameter writes a parameter on one line, with its schema on the lines
+// after when that is an object:
+//
+//	query limit: integer, max 100, default 20 - Page size (required)
+func (s *spec) parameter(w *schemaWriter, param *object) string {
+	line := fmt.Sprintf("  %s %s: %s", param.string("in"), param.string("name"), s.paramSchema(w, param, "  "))
+	if description := oneLine(param.string("description")); description != "" {
+		line += " - " + description
+	}
+	if param.get("required") == true {
+		line += " (required)"
+	}
+	if param.get("deprecated") == true {
+		line += " (deprecated)"
+	}
+	return line
+}
+
+// paramSchema describes the schema of a parameter or header: in OpenAPI 3
+// its schema or content, in Swagger 2 the type, format and items the
+// parameter itself has
+func (s *spec) paramSchema(w *schemaWriter, param *object, indent string) string {
+	if schema := param.get("schema"); schema != nil {
+		return w.describe(schema, indent, nil)
+	}
+	if content := param.object("content"); content != nil && len(content.keys) > 0 {
+		media := content.object(content.keys[0])
+		return content.keys[0] + " " + w.describe(media.get("schema"), indent, nil)
+	}
+	described := w.describe(param, indent, nil)
+	if format := param.string("collectionFormat"); format != "" && format != "csv" {
+		described += ", " + format + " separated"
+	}
+	return described
+}
+
+// content writes the media types of a request body or response with their
+// schemas; media types with the same schema share it
+func (s *spec) content(text *strings.Builder, w *schemaWriter, content *object) {
+	if content == nil || len(content.keys) == 0 {
+		text.WriteString(" no content")
+		return
+	}
+	var groups [][]string
+	schemas := map[string]int{}
+	for _, media := range content.keys {
+		key := media
+		if schema, _ := content.object(media).get("schema").(*object); schema != nil {
+			if ref := schema.string("$ref"); ref != "" {
+				key = ref
+			} else {
+				key = fmt.Sprintf("%p", schema)
+			}
+		}
+		if i, ok := schemas[key]; ok {
+			groups[i] = append(groups[i], media)
+			continue
+		}
+		schemas[key] = len(groups)
+		groups = append(groups, []string{media})
+	}
+	for _, group := range groups {
+		fmt.Fprintf(text, "\n  %s\n    %s", strings.Join(group, ", "), w.describe(content.object(group[0]).get("schema"), "    ", nil))
+	}
+}
+
+// security describes how an operation is authorized: its own requirements,
+// or the spec's. Alternatives are joined with "or", requirements that all
+// apply with "and".
+func (s *spec) security(op *object) string {
+	requirements, ok := op.get("security").([]any)
+	if !ok {
+		requirements, _ = s.document.get("security").([]any)
+	}
+	if requirements == nil {
+		return ""
+	}
+	var alternatives []string
+	for _, value := range requirements {
+		requirement, _ := value.(*object)
+		if requirement == nil || len(requirement.keys) == 0 {
+			alternatives = append(alternatives, "none")
+			continue
+		}
+		var all []string
+		for _, name := range requirement.keys {
+			scheme := s.securityScheme(name)
+			if scopes := requirement.strings(name); len(scopes) > 0 {
+				scheme += fmt.Sprintf(" with scopes %s", strings.Join(scopes, ", "))
+			}
+			all = append(all, scheme)
+		}
+		alternatives = append(alternatives, strings.Join(all, " and "))
+	}
+	if len(alternatives) == 0 {
+		return "none"
+	}
+	return strings.Join(alternatives, " or ")
+}
+
+// securityScheme describes a named security scheme, e.g. "bearerAuth
+// (bearer token in the Authorization header)"
+func (s *spec) securityScheme(name string) string {
+	var scheme *object
+	if s.swagger {
+		scheme, _ = s.resolve(s.document.object("securityDefinitions").get(name))
+	} else {
+		scheme, _ = s.resolve(s.document.object("components").object("securitySchemes").get(name))
+	}
+	if scheme == nil {
+		return name
+	}
+	how := ""
+	switch scheme.string("type") {
+	case "apiKey":
+		how = fmt.Sprintf("API key in the %s %s", scheme.string("name"), scheme.string("in"))
+	case "http":
+		switch strings.ToLower(scheme.string("scheme")) {
+		case "bearer":
+			how = "bearer token in the Authorization header"
+			if format := scheme.string("bearerFormat"); format != "" {
+				how = format + " " + how
+			}
+		case "basic":
+			how = "HTTP basic authentication"
+		default:
+			how = "HTTP " + scheme.string("scheme") + " authentication"
+		}
+	case "basic":
+		how = "HTTP basic authentication"
+	case "oauth2":
+		how = "OAuth 2"
+	case "openIdConnect":
+		how = "OpenID Connect"
+		if url := scheme.string("openIdConnectUrl"); url != "" {
+			how += " via " + url
+		}
+	case "mutualTLS":
+		how = "client TLS certificate"
+	}
+	if how == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, how)
+}
+
+// mediaTypes joins the media types of a Swagger 2 operation, fallback
+// when it declares none
+func mediaTypes(types []string, fallback string) string {
+	if len(types) == 0 {
+		return fallback
+	}
+	return strings.Join(types, ", ")
+}
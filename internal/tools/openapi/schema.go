@@ -0,0 +1,254 @@
+package openapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// maxSchemaDepth is how deeply nested schemas are written out
+	maxSchemaDepth = 12
+	// maxDescription is how much of a description is kept on a schema line
+	maxDescription = 160
+)
+
+// schemaWriter writes schemas in a compact, indented notation:
+//
+//	User object {
+//	  id: integer, format int64, read-only (required)
+//	  tags: array of string - labels shown on the profile
+//	}
+//
+// A named schema is written out the first time it appears in an operation
+// and referred to by name after that.
+type schemaWriter struct {
+	spec    *spec
+	written map[string]bool // References already written out
+}
+
+func newSchemaWriter(s *spec) *schemaWriter {
+	return &schemaWriter{spec: s, written: make(map[string]bool)}
+}
+
+// describe returns a schema as text whose first line continues the current
+// line and whose other lines start with indent
+func (w *schemaWriter) describe(value any, indent string, stack []string) string {
+	schema, _ := value.(*object)
+	if schema == nil {
+		return "any"
+	}
+	if ref := schema.string("$ref"); ref != "" {
+		resolved, last := w.spec.resolve(schema)
+		if last == "" {
+			return fmt.Sprintf("see %s (not in this document)", ref)
+		}
+		name := refName(last)
+		switch {
+		case slices.Contains(stack, last):
+			return name + " (recursive)"
+		case w.written[last]:
+			return name + " (as above)"
+		case len(stack) >= maxSchemaDepth:
+			return name + " (not expanded further)"
+		}
+		w.written[last] = true
+		return name + " " + w.describe(resolved, indent, append(stack, last))
+	}
+	if len(stack) >= maxSchemaDepth {
+		return "... (not expanded further)"
+	}
+
+	if parts := schema.list("allOf"); len(parts) > 0 {
+		return w.describe(w.merge(schema, parts), indent, stack)
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		variants := schema.list(keyword)
+		if len(variants) == 0 {
+			continue
+		}
+		var text strings.Builder
+		text.WriteString(strings.ToLower(keyword[:3]) + " of")
+		if discriminator := schema.object("discriminator").string("propertyName"); discriminator != "" {
+			fmt.Fprintf(&text, " (told apart by %s)", discriminator)
+		}
+		text.WriteString(":")
+		for _, variant := range variants {
+			fmt.Fprintf(&text, "\n%s  - %s", indent, w.describe(variant, indent+"    ", stack))
+		}
+		return text.String()
+	}
+
+	types := schemaTypes(schema)
+	switch {
+	case slices.Contains(types, "array") || schema.get("items") != nil:
+		return "array of " + w.describe(schema.get("items"), indent, stack) + notes(schema, types)
+	case slices.Contains(types, "object") || schema.object("properties") != nil || schema.get("additionalProperties") != nil:
+		return w.describeObject(schema, types, indent, stack)
+	case len(types) == 0:
+		return "any" + notes(schema, types)
+	}
+	return strings.Join(withoutNull(types), " or ") + notes(schema, types)
+}
+
+// describeObject writes an object's properties one per line
+func (w *schemaWriter) describeObject(schema *object, types []string, indent string, stack []string) string {
+	properties := schema.object("properties")
+	additional := schema.get("additionalProperties")
+	if properties == nil || len(properties.keys) == 0 {
+		if extra, ok := additional.(*object); ok {
+			return "map of string to " + w.describe(extra, indent, stack) + notes(schema, types)
+		}
+		return "object" + notes(schema, types)
+	}
+
+	required := schema.strings("required")
+	var text strings.Builder
+	text.WriteString("object {")
+	for _, name := range properties.keys {
+		property := properties.get(name)
+		described := w.describe(property, indent+"  ", stack)
+		fmt.Fprintf(&text, "\n%s  %s: %s", indent, name, described)
+		// Descriptions of referenced schemas belong to the schema, and would
+		// follow the closing brace of a multi-line one
+		if p, _ := property.(*object); p != nil && p.string("$ref") == "" && !strings.Contains(described, "\n") {
+			if description := oneLine(p.string("description")); description != "" {
+				fmt.Fprintf(&text, " - %s", description)
+			}
+		}
+		if slices.Contains(required, name) {
+			text.WriteString(" (required)")
+		}
+	}
+	if extra, ok := additional.(*object); ok {
+		fmt.Fprintf(&text, "\n%s  <other keys>: %s", indent, w.describe(extra, indent+"  ", stack))
+	}
+	fmt.Fprintf(&text, "\n%s}%s", indent, notes(schema, types))
+	return text.String()
+}
+
+// notes returns a schema's format, constraints and flags, e.g.
+// ", format date-time, nullable"
+func notes(schema *object, types []string) string {
+	var notes []string
+	if format := schema.string("format"); format != "" {
+		notes = append(notes, "format "+format)
+	}
+	if values := schema.list("enum"); len(values) > 0 {
+		var names []string
+		for _, value := range values {
+			names = append(names, fmt.Sprint(value))
+		}
+		notes = append(notes, "one of: "+strings.Join(names, ", "))
+	}
+	for _, limit := range []struct{ key, name string }{
+		{"minimum", "min"}, {"maximum", "max"}, {"minLength", "min length"}, {"maxLength", "max length"},
+		{"minItems", "min items"}, {"maxItems", "max items"}, {"pattern", "pattern"}, {"default", "default"},
+	} {
+		if value := schema.get(limit.key); value != nil {
+			notes = append(notes, fmt.Sprintf("%s %v", limit.name, value))
+		}
+	}
+	for _, flag := range []struct{ key, name string }{
+		{"nullable", "nullable"}, {"readOnly", "read-only"}, {"writeOnly", "write-only"}, {"deprecated", "deprecated"},
+	} {
+		if schema.get(flag.key) == true {
+			notes = append(notes, flag.name)
+		}
+	}
+	if slices.Contains(types, "null") {
+		notes = append(notes, "nullable")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(notes, ", ")
+}
+
+// merge combines the parts of an allOf, and the schema holding it, into one
+// object schema
+func (w *schemaWriter) merge(schema *object, parts []any) *object {
+	merged := &object{values: map[string]any{}}
+	properties := &object{values: map[string]any{}}
+	var required []any
+	add := func(part *object) {
+		for _, key := range part.keys {
+			switch key {
+			case "properties":
+				props := part.object("properties")
+				for _, name := range props.keys {
+					if _, exists := properties.values[name]; !exists {
+						properties.keys = append(properties.keys, name)
+					}
+					properties.values[name] = props.get(name)
+				}
+			case "required":
+				required = append(required, part.list("required")...)
+			case "allOf", "$ref":
+			default:
+				if _, exists := merged.values[key]; !exists {
+					merged.keys = append(merged.keys, key)
+				}
+				merged.values[key] = part.get(key)
+			}
+		}
+	}
+	for _, part := range parts {
+		resolved, ref := w.spec.resolve(part)
+		if resolved == nil {
+			continue
+		}
+		if nested := resolved.list("allOf"); len(nested) > 0 && ref != "" {
+			resolved = w.merge(resolved, nested)
+		}
+		add(resolved)
+	}
+	add(schema)
+	merged.keys = append(merged.keys, "properties", "required")
+	merged.values["properties"] = properties
+	merged.values["required"] = required
+	if _, ok := merged.values["type"]; !ok {
+		merged.keys = append(merged.keys, "type")
+		merged.values["type"] = "object"
+	}
+	return merged
+}
+
+// schemaTypes returns the type or types of a schema; OpenAPI 3.1 allows a
+// list such as [string, "null"]
+func schemaTypes(schema *object) []string {
+	switch value := schema.get("type").(type) {
+	case string:
+		return []string{value}
+	case []any:
+		return schema.strings("type")
+	}
+	return nil
+}
+
+func withoutNull(types []string) []string {
+	var kept []string
+	for _, t := range types {
+		if t != "null" {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		return []string{"null"}
+	}
+	return kept
+}
+
+// oneLine shortens a description to one line of at most maxDescription
+// characters
+func oneLine(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= maxDescription {
+		return text
+	}
+	cut := strings.LastIndexByte(text[:maxDescription], ' ')
+	if cut <= 0 {
+		cut = maxDescription
+	}
+	return text[:cut] + "..."
+}
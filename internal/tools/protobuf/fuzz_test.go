@@ -0,0 +1,60 @@
+package protobuf
+
+import (
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// protoFiles is the workspace the protobuf tool is fuzzed in. No
+// generators are configured, so generate fails before it would run one.
+var protoFiles = map[string]string{
+	"api/pets/v1/pets.proto": `syntax = "proto3";
+
+package pets.v1;
+
+import "google/protobuf/timestamp.proto";
+
+// PetService manages pets
+service PetService {
+  rpc GetPet(GetPetRequest) returns (Pet) {
+    option (google.api.http) = { get: "/v1/pets/{id}" };
+  }
+  rpc ListPets(stream GetPetRequest) returns (stream Pet);
+}
+
+message GetPetRequest { string id = 1; }
+
+message Pet {
+  string id = 1;
+  Kind kind = 2 [deprecated = true];
+  map<string, string> labels = 3;
+  oneof owner { string person = 4; string shelter = 5; }
+  message Tag { string name = 1; }
+  repeated Tag tags = 6;
+  google.protobuf.Timestamp born = 7;
+  reserved 8 to 10;
+}
+
+enum Kind { KIND_UNSPECIFIED = 0; KIND_DOG = 1; }
+`,
+}
+
+func FuzzProtobuf(f *testing.F) {
+	toolstest.Seed(f, ProtobufTool{}, `{"action": "show", "name": "Pet.Tag"}`, `{"action": "show", "name": "Kind"}`, `{"action": "list", "path": "../.."}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		toolstest.Execute(t, ProtobufTool{}, toolstest.Context(t, protoFiles), input)
+	})
+}
+
+// FuzzParse checks the .proto parser, which reads whatever files the
+// workspace holds, on its own
+func FuzzParse(f *testing.F) {
+	f.Add(protoFiles["api/pets/v1/pets.proto"])
+	f.Add("message A { message B { enum C { D = 0; } } }")
+	f.Add("service S { rpc M(")
+	f.Add("/* unterminated")
+	f.Fuzz(func(t *testing.T, src string) {
+		parseFile("fuzz.proto", src)
+	})
+}
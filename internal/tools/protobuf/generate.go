@@ -0,0 +1,246 @@
+package protobuf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+const (
+	// timeout bounds each generator, which may download plugins first
+	timeout = 10 * time.Minute
+	// maxChangedFiles is how many changed files a result names
+	maxChangedFiles = 200
+)
+
+// generate runs a configured generator in its directory after the user
+// confirms it, and reports the files it changed
+func generate(ctx context.Context, toolCtx *tools.ToolContext, name string) (*tools.ToolResult, error) {
+	generator, err := findGenerator(name)
+	if err != nil {
+		return nil, err
+	}
+	dir := generator.Dir
+	if dir == "" {
+		dir = "."
+	}
+	resolved, shown, err := resolvePath(toolCtx, dir)
+	if err != nil {
+		return nil, err
+	}
+	commandLine := strings.Join(generator.Command, " ")
+
+	approved := toolCtx.Confirm(confirm.Request{
+		Tool:    "protobuf",
+		Action:  fmt.Sprintf("run the %s generator", generator.Name),
+		Path:    resolved,
+		Preview: fmt.Sprintf("$ %s\n(in %s)", commandLine, shown),
+	})
+	if !approved {
+		return tools.NewTextResult("Generation cancelled by user"), nil
+	}
+
+	// Generators write wherever their config says, so the whole workspace
+	// is compared before and after
+	ws := workspaceOf(toolCtx)
+	root := "."
+	if ws != nil {
+		root = ws.Root()
+	}
+	before := snapshot(ws.FS(), root)
+	output, exitCode, err := run(ctx, toolCtx, generator.Command, resolved)
+	if err != nil {
+		return nil, err
+	}
+	changed := snapshot(ws.FS(), root).changedSince(before)
+	shownChanged := make([]string, len(changed))
+	for i, file := range changed {
+		shownChanged[i] = displayPath(file, ".", root)
+	}
+
+	if exitCode != 0 {
+		text := fmt.Sprintf("%s failed (exit code %d):\n%s", commandLine, exitCode, lastBytes(output))
+		if len(changed) > 0 {
+			text += "\n\nFiles it changed before failing: " + strings.Join(limitFiles(shownChanged), ", ")
+		}
+		return tools.NewErrorResult(text).WithFilesChanged(shownChanged...).WithCommands(commandLine), nil
+	}
+	var text strings.Builder
+	if len(changed) == 0 {
+		fmt.Fprintf(&text, "Ran the %s generator; no files changed, so the generated code was up to date", generator.Name)
+	} else {
+		fmt.Fprintf(&text, "Ran the %s generator, which changed %s:\n%s", generator.Name, count(len(changed), "file"), strings.Join(limitFiles(shownChanged), "\n"))
+	}
+	if output = lastBytes(output); output != "" {
+		text.WriteString("\n\nOutput:\n" + output)
+	}
+	return tools.NewTextResult(text.String()).WithFilesChanged(shownChanged...).WithCommands(commandLine), nil
+}
+
+// findGenerator returns the generator of the given name, or the only one
+func findGenerator(name string) (config.ProtobufGenerator, error) {
+	names := make([]string, len(settings.Generate))
+	for i, generator := range settings.Generate {
+		names[i] = generator.Name
+	}
+	switch {
+	case len(settings.Generate) == 0:
+		return config.ProtobufGenerator{}, tools.NotFound(errors.New("no generators are configured; add the buf or protoc commands that generate code under protobuf.generate in billdozer.yml"))
+	case name == "" && len(settings.Generate) == 1:
+		return settings.Generate[0], nil
+	case name == "":
+		return config.ProtobufGenerator{}, tools.InvalidInput(fmt.Errorf("parameter \"generator\" is required when more than one generator is configured: %s", strings.Join(names, ", ")))
+	}
+	for _, generator := range settings.Generate {
+		if generator.Name == name {
+			return generator, nil
+		}
+	}
+	return config.ProtobufGenerator{}, tools.NotFound(fmt.Errorf("unknown generator %q. Configured generators: %s", name, strings.Join(names, ", ")))
+}
+
+// run runs a generator in dir, showing its output to the user as it
+// arrives. A command that ran is returned whatever its exit code; errors
+// mean it could not be run or was stopped.
+func run(ctx context.Context, toolCtx *tools.ToolContext, argv []string, dir string) (output string, exitCode int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var progress io.Writer
+	if toolCtx != nil && toolCtx.Output != nil {
+		progress = toolCtx.Output
+	}
+	buffer := &outputBuffer{progress: progress}
+	process, err := workspaceOf(toolCtx).Runner().Start(workspace.Cmd{Argv: argv, Dir: dir, Stdout: buffer, Stderr: buffer, Group: true})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to run %s (is it installed and on the PATH?): %w", argv[0], err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+	select {
+	case err = <-exited:
+	case <-ctx.Done():
+		process.Kill()
+		<-exited
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", 0, fmt.Errorf("%s timed out after %s", argv[0], timeout)
+		}
+		return "", 0, fmt.Errorf("%s cancelled: %w", argv[0], ctx.Err())
+	}
+
+	var exit workspace.ExitCoder
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		exitCode = exit.ExitCode()
+	default:
+		return "", 0, fmt.Errorf("%s failed: %w", argv[0], err)
+	}
+	return buffer.String(), exitCode, nil
+}
+
+// fileStamp is what tells a file's versions apart
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// fileStamps are the files under a directory
+type fileStamps map[string]fileStamp
+
+// snapshot records the size and modification time of the files under root
+func snapshot(fsys workspace.FS, root string) fileStamps {
+	stamps := fileStamps{}
+	fsys.Walk(root, func(file string, info fs.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case info.IsDir() && file != root && slices.Contains(skippedDirs, info.Name()):
+			return filepath.SkipDir
+		case !info.IsDir():
+			stamps[file] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// changedSince returns the files created, changed or deleted since before,
+// sorted
+func (s fileStamps) changedSince(before fileStamps) []string {
+	var changed []string
+	for file, stamp := range s {
+		if previous, ok := before[file]; !ok || previous.size != stamp.size || !previous.modTime.Equal(stamp.modTime) {
+			changed = append(changed, file)
+		}
+	}
+	for file := range before {
+		if _, ok := s[file]; !ok {
+			changed = append(changed, file)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// limitFiles keeps the first maxChangedFiles names, noting how many more
+// there are
+func limitFiles(files []string) []string {
+	if len(files) <= maxChangedFiles {
+		return files
+	}
+	return append(slices.Clip(files[:maxChangedFiles]), fmt.Sprintf("and %d more", len(files)-maxChangedFiles))
+}
+
+// outputBuffer collects a command's output while copying it to a live
+// display. Display errors are ignored so a closed terminal never fails
+// the command.
+type outputBuffer struct {
+	mutex    sync.Mutex
+	buffer   bytes.Buffer
+	progress io.Writer
+}
+
+// Write implements io.Writer
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.buffer.Write(p)
+	if b.progress != nil {
+		b.progress.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *outputBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// lastBytes keeps the end of output within maxOutputBytes, from the start
+// of a line
+func lastBytes(output string) string {
+	output = strings.TrimRight(strings.TrimLeft(output, "\n"), " \n")
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := len(output) - maxOutputBytes
+	if i := strings.IndexByte(output[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return fmt.Sprintf("... [%d bytes of output omitted] ...\n%s", cut, output[cut:])
+}
@@ -0,0 +1,337 @@
+package protobuf
+
+import (
+	"strings"
+)
+
+// protoFile is what the tool knows of a .proto file: enough to list and
+// find its definitions, whose text is shown as written
+type protoFile struct {
+	path        string // As shown, relative to the workspace root
+	source      string
+	pkg         string
+	goPackage   string
+	definitions []*definition
+}
+
+// definition is a message, enum or service
+type definition struct {
+	kind     string // message, enum or service
+	name     string // Qualified within the file's package, e.g. Pet.Owner
+	fullName string // With the package, e.g. pets.v1.Pet.Owner
+	line     int    // Of the keyword, from 1
+	start    int    // Offset of the definition, comments before it included
+	end      int    // Offset just past its closing brace
+	rpcs     []rpc
+}
+
+// rpc is a method of a service
+type rpc struct {
+	name          string
+	request       string
+	response      string
+	clientStreams bool
+	serverStreams bool
+	httpRule      string // e.g. GET /v1/pets/{id}, from a google.api.http option
+}
+
+// String writes the method as it is declared, with its HTTP mapping
+func (r rpc) String() string {
+	request, response := r.request, r.response
+	if r.clientStreams {
+		request = "stream " + request
+	}
+	if r.serverStreams {
+		response = "stream " + response
+	}
+	text := "rpc " + r.name + "(" + request + ") returns (" + response + ")"
+	if r.httpRule != "" {
+		text += "  " + r.httpRule
+	}
+	return text
+}
+
+// token is a word, string or punctuation character of a .proto file
+type token struct {
+	text   string
+	offset int
+	line   int
+	quoted bool // A string literal, text without its quotes
+}
+
+// tokenize splits a .proto file into tokens, leaving out comments. Dotted
+// names such as google.api.http are one token.
+func tokenize(source string) []token {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				end = len(source) - i - 4
+			}
+			line += strings.Count(source[i:i+end+4], "\n")
+			i += end + 4
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			for i < len(source) && source[i] != c && source[i] != '\n' {
+				if source[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			value := source[start+1 : min(i, len(source))]
+			tokens = append(tokens, token{text: value, offset: start, line: line, quoted: true})
+			if i < len(source) && source[i] == c {
+				i++
+			}
+		case isWordByte(c):
+			start := i
+			for i < len(source) && (isWordByte(source[i]) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{text: source[start:i], offset: start, line: line})
+		default:
+			tokens = append(tokens, token{text: string(c), offset: i, line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// parser walks the tokens of one file
+type parser struct {
+	file   *protoFile
+	tokens []token
+	pos    int
+}
+
+// parseFile finds the package, go_package and definitions of a .proto file
+func parseFile(path, source string) *protoFile {
+	p := &parser{file: &protoFile{path: path, source: source}, tokens: tokenize(source)}
+	p.body("", true)
+	return p.file
+}
+
+func (p *parser) peek(ahead int) token {
+	if p.pos+ahead < len(p.tokens) {
+		return p.tokens[p.pos+ahead]
+	}
+	return token{}
+}
+
+// body reads statements up to the closing brace of a block, or the end of
+// the file at the top level. prefix qualifies the names defined in it.
+func (p *parser) body(prefix string, topLevel bool) {
+	for p.pos < len(p.tokens) {
+		t := p.peek(0)
+		switch {
+		case t.text == "}" && !t.quoted:
+			p.pos++
+			return
+		case t.text == "package" && topLevel:
+			p.file.pkg = p.peek(1).text
+			p.skipStatement()
+		case t.text == "option" && topLevel && p.peek(1).text == "go_package":
+			if value := p.peek(3); value.quoted {
+				p.file.goPackage = value.text
+			}
+			p.skipStatement()
+		case (t.text == "message" || t.text == "enum" || t.text == "service") && p.peek(2).text == "{":
+			p.definition(t, prefix)
+		default:
+			p.skipStatement()
+		}
+	}
+}
+
+// definition reads a message, enum or service, and the messages and enums
+// nested in a message
+func (p *parser) definition(keyword token, prefix string) {
+	def := &definition{kind: keyword.text, name: prefix + p.peek(1).text, line: keyword.line, start: p.commentStart(keyword.offset)}
+	def.fullName = def.name
+	if p.file.pkg != "" {
+		def.fullName = p.file.pkg + "." + def.name
+	}
+	p.file.definitions = append(p.file.definitions, def)
+	p.pos += 3
+
+	switch def.kind {
+	case "message":
+		p.body(def.name+".", false)
+	case "service":
+		for p.pos < len(p.tokens) && p.peek(0).text != "}" {
+			if p.peek(0).text == "rpc" {
+				def.rpcs = append(def.rpcs, p.rpc())
+			} else {
+				p.skipStatement()
+			}
+		}
+		p.pos++
+	default:
+		p.skipBlock()
+	}
+	if p.pos > 0 && p.pos <= len(p.tokens) {
+		def.end = p.tokens[p.pos-1].offset + 1
+	}
+}
+
+// rpc reads a method: rpc Name (stream Request) returns (stream Response)
+// followed by ";" or a block of options
+func (p *parser) rpc() rpc {
+	r := rpc{name: p.peek(1).text}
+	p.pos += 2
+	r.request, r.clientStreams = p.messageType()
+	if p.peek(0).text == "returns" {
+		p.pos++
+		r.response, r.serverStreams = p.messageType()
+	}
+	if p.peek(0).text != "{" {
+		p.skipStatement()
+		return r
+	}
+	start := p.pos
+	p.pos++
+	p.skipBlock()
+	if p.peek(0).text == ";" {
+		p.pos++
+	}
+	r.httpRule = httpRule(p.tokens[start:p.pos])
+	return r
+}
+
+// messageType reads a parenthesized, possibly streamed, message type
+func (p *parser) messageType() (name string, stream bool) {
+	if p.peek(0).text != "(" {
+		return "", false
+	}
+	p.pos++
+	if p.peek(0).text == "stream" && p.peek(1).text != ")" {
+		stream = true
+		p.pos++
+	}
+	name = p.peek(0).text
+	for p.pos < len(p.tokens) && p.peek(0).text != ")" {
+		p.pos++
+	}
+	p.pos++
+	return name, stream
+}
+
+// httpRule finds the pattern of a google.api.http option among the tokens
+// of a method's options: option (google.api.http) = { get: "/v1/pets" }
+func httpRule(tokens []token) string {
+	for i, t := range tokens {
+		if t.text != "google.api.http" {
+			continue
+		}
+		for j := i + 1; j+2 < len(tokens); j++ {
+			verb := strings.ToUpper(tokens[j].text)
+			switch verb {
+			case "GET", "PUT", "POST", "DELETE", "PATCH":
+				if tokens[j+1].text == ":" && tokens[j+2].quoted {
+					return verb + " " + tokens[j+2].text
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// skipStatement moves past a statement: up to its ";", or past the closing
+// brace of a block such as extend Foo { ... }. Braces within brackets, as
+// in field options, do not end it.
+func (p *parser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		p.pos++
+		if t.quoted {
+			continue
+		}
+		switch t.text {
+		case "{", "[", "(":
+			depth++
+		case "]", ")":
+			depth--
+		case "}":
+			if depth == 0 {
+				// The end of the enclosing block: leave it to its reader
+				p.pos--
+				return
+			}
+			depth--
+			if depth == 0 {
+				if p.peek(0).text == ";" {
+					p.pos++
+				}
+				return
+			}
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipBlock moves past the closing brace of the block it is in
+func (p *parser) skipBlock() {
+	depth := 1
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		p.pos++
+		if t.quoted {
+			continue
+		}
+		switch t.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// commentStart returns where the comment lines directly above offset
+// start, or the start of offset's line when there are none
+func (p *parser) commentStart(offset int) int {
+	source := p.file.source
+	start := strings.LastIndexByte(source[:offset], '\n') + 1
+	for start > 0 {
+		previous := strings.LastIndexByte(source[:start-1], '\n') + 1
+		line := strings.TrimSpace(source[previous : start-1])
+		if !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "*") && !strings.HasPrefix(line, "/*") {
+			break
+		}
+		start = previous
+	}
+	return start
+}
+
+// text returns a definition as written, with the comments above it
+func (f *protoFile) text(def *definition) string {
+	if def.end <= def.start || def.end > len(f.source) {
+		return ""
+	}
+	return f.source[def.start:def.end]
+}
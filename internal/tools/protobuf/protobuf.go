@@ -0,0 +1,314 @@
+// Package protobuf provides a tool that lists the services, messages and
+// enums of a project's .proto files, shows their definitions, and runs the
+// buf or protoc commands configured to regenerate code from them
+package protobuf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agent/internal/config"
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam      = "parameter %q is required"
+	errMsgNoProtoFiles      = "no .proto files in %s"
+	errMsgUnknownDefinition = "no message, enum or service is named %q; list them with {\"action\": \"list\"}"
+)
+
+// Actions of the protobuf tool
+const (
+	actionList     = "list"
+	actionShow     = "show"
+	actionGenerate = "generate"
+)
+
+const (
+	// maxProtoFiles is how many .proto files are read
+	maxProtoFiles = 2000
+	// maxOutputBytes is how much of a listing or command output is returned
+	maxOutputBytes = 30000
+)
+
+// skippedDirs are not searched for .proto files or changed files: they hold
+// other projects' code, or none
+var skippedDirs = []string{".git", "node_modules", "vendor"}
+
+// settings is the protobuf config in effect; see Configure
+var settings config.ProtobufConfig
+
+// Configure sets where .proto files are and the commands that generate
+// code from them. Call it before tools run.
+func Configure(cfg config.ProtobufConfig) {
+	settings = cfg
+}
+
+// ProtobufInput represents the input parameters for the protobuf tool
+type ProtobufInput struct {
+	Action    string `json:"action" jsonschema:"required,enum=list,enum=show,enum=generate" jsonschema_description:"list shows the services with their rpcs, messages and enums of each file; show prints definitions as written; generate runs a configured code generator"`
+	Path      string `json:"path,omitempty" jsonschema_description:"list: a .proto file or directory to list instead of the configured ones"`
+	Name      string `json:"name,omitempty" jsonschema_description:"show: message, enum or service, by its name (Pet), nested name (Pet.Owner) or full name (pets.v1.Pet)"`
+	Generator string `json:"generator,omitempty" jsonschema_description:"generate: name of the configured generator; may be omitted when only one is configured"`
+}
+
+// Validate implements input validation
+func (i *ProtobufInput) Validate() error {
+	switch i.Action {
+	case "":
+		return fmt.Errorf(errMsgMissingParam, "action")
+	case actionList:
+		if i.Name != "" || i.Generator != "" {
+			return fmt.Errorf("parameters \"name\" and \"generator\" do not apply to list")
+		}
+	case actionShow:
+		if i.Name == "" {
+			return fmt.Errorf(errMsgMissingParam, "name")
+		}
+		if i.Generator != "" {
+			return fmt.Errorf("parameter \"generator\" does not apply to show")
+		}
+	case actionGenerate:
+		if i.Path != "" || i.Name != "" {
+			return fmt.Errorf("parameters \"path\" and \"name\" do not apply to generate")
+		}
+	default:
+		return fmt.Errorf("action must be list, show or generate, not %q", i.Action)
+	}
+	return nil
+}
+
+type ProtobufTool struct{}
+
+func (t ProtobufTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "protobuf",
+		Group:    "protobuf",
+		Mutating: true,
+		Confirms: true,
+		Description: `Work with a project's Protocol Buffers: list the services, rpcs, messages and enums of
+its .proto files, show definitions as written, and run the configured buf or protoc
+commands that regenerate code from them.
+
+Usage Examples:
+- {"action": "list"} // Every .proto file's package, services with their rpcs, messages and enums
+- {"action": "list", "path": "api/pets/v1"}
+- {"action": "show", "name": "PetService"} // The service as written, with its comments and options
+- {"action": "show", "name": "pets.v1.Pet"}
+- {"action": "generate", "generator": "go"} // Run a configured generator; the user confirms it
+
+After changing a .proto file, run generate before changing the code that uses it, and
+implement every rpc of a service in its handlers. Generate runs only the commands the
+user configured, and reports the files they changed.`,
+		InputSchema: schema.GenerateSchema[ProtobufInput](),
+	}
+}
+
+func (t ProtobufTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var protobufInput ProtobufInput
+	if err := tools.DecodeInput(input, &protobufInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := protobufInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	if protobufInput.Action == actionGenerate {
+		return generate(ctx, toolCtx, protobufInput.Generator)
+	}
+	files, where, err := readProtoFiles(toolCtx, protobufInput.Path)
+	if err != nil {
+		return nil, err
+	}
+	if protobufInput.Action == actionShow {
+		return show(files, protobufInput.Name)
+	}
+	return tools.NewTextResult(firstBytes(list(files, where))), nil
+}
+
+// list writes the package and definitions of each file:
+//
+//	api/pets/v1/pets.proto  package pets.v1  go_package example.com/gen/pets/v1
+//	  service PetService
+//	    rpc GetPet(GetPetRequest) returns (Pet)  GET /v1/pets/{id}
+//	  message Pet
+func list(files []*protoFile, where string) string {
+	var text strings.Builder
+	services, messages := 0, 0
+	for _, file := range files {
+		for _, def := range file.definitions {
+			switch def.kind {
+			case "service":
+				services++
+			case "message":
+				messages++
+			}
+		}
+	}
+	fmt.Fprintf(&text, "%s in %s, with %s and %s", count(len(files), ".proto file"), where, count(services, "service"), count(messages, "message"))
+	for _, file := range files {
+		text.WriteString("\n\n" + file.path)
+		if file.pkg != "" {
+			text.WriteString("  package " + file.pkg)
+		}
+		if file.goPackage != "" {
+			text.WriteString("  go_package " + file.goPackage)
+		}
+		for _, def := range file.definitions {
+			fmt.Fprintf(&text, "\n  %s %s", def.kind, def.name)
+			for _, method := range def.rpcs {
+				fmt.Fprintf(&text, "\n    %s", method)
+			}
+		}
+	}
+	return text.String()
+}
+
+// show returns the text of the definitions name matches, which may be
+// several when packages define the same name
+func show(files []*protoFile, name string) (*tools.ToolResult, error) {
+	name = strings.TrimPrefix(name, ".")
+	var parts []string
+	for _, file := range files {
+		for _, def := range file.definitions {
+			if def.fullName == name || def.name == name || strings.HasSuffix(def.fullName, "."+name) {
+				parts = append(parts, fmt.Sprintf("%s:%d  %s %s\n%s", file.path, def.line, def.kind, def.fullName, file.text(def)))
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return nil, tools.NotFound(fmt.Errorf(errMsgUnknownDefinition, name))
+	}
+	return tools.NewTextResult(firstBytes(strings.Join(parts, "\n\n"))), nil
+}
+
+// readProtoFiles reads and parses the .proto files at path, or in the
+// configured directories, or in the whole workspace. where describes what
+// was searched.
+func readProtoFiles(toolCtx *tools.ToolContext, path string) (files []*protoFile, where string, err error) {
+	ws := workspaceOf(toolCtx)
+	dirs := settings.Dirs
+	if path != "" {
+		dirs = []string{path}
+	}
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var shownDirs []string
+	for _, dir := range dirs {
+		resolved, shown, err := resolvePath(toolCtx, dir)
+		if err != nil {
+			return nil, "", err
+		}
+		shownDirs = append(shownDirs, shown)
+		err = ws.FS().Walk(resolved, func(file string, info fs.FileInfo, err error) error {
+			switch {
+			case err != nil:
+				if file == resolved {
+					return err
+				}
+				return nil // Unreadable entries are left out
+			case info.IsDir() && file != resolved && slices.Contains(skippedDirs, info.Name()):
+				return filepath.SkipDir
+			case info.IsDir() || filepath.Ext(file) != ".proto":
+				return nil
+			case len(files) >= maxProtoFiles:
+				return filepath.SkipAll
+			}
+			data, err := ws.FS().ReadFile(file)
+			if err != nil {
+				return nil
+			}
+			files = append(files, parseFile(displayPath(file, shown, resolved), string(data)))
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, "", tools.NotFound(fmt.Errorf("%s does not exist", shown))
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %w", shown, err)
+		}
+	}
+
+	where = strings.Join(shownDirs, ", ")
+	if where == "." {
+		where = "the workspace"
+	}
+	if len(files) == 0 {
+		return nil, "", tools.NotFound(fmt.Errorf(errMsgNoProtoFiles, where))
+	}
+	return files, where, nil
+}
+
+// resolvePath resolves a path within the workspace, asking the user first
+// when the path policy says to
+func resolvePath(toolCtx *tools.ToolContext, path string) (resolved, shown string, err error) {
+	ws := workspaceOf(toolCtx)
+	if ws == nil {
+		return path, filepath.Clean(path), nil
+	}
+	p, err := ws.ResolvePath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "read .proto files outside the workspace", Path: p.Abs}) {
+		return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+	}
+	return p.Abs, p.Shown, nil
+}
+
+// displayPath names a file found under root, which is shown as shown
+func displayPath(file, shown, root string) string {
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == "." {
+		return shown
+	}
+	if shown == "." {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filepath.Join(shown, rel))
+}
+
+// count writes a number of things, e.g. "1 service" or "3 services"
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// firstBytes keeps the start of output within maxOutputBytes, up to the
+// end of a line
+func firstBytes(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := maxOutputBytes
+	if i := strings.LastIndexByte(output[:cut], '\n'); i >= 0 {
+		cut = i
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted; list a directory with path] ...", output[:cut], len(output)-cut)
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(ProtobufTool{})
+}
@@ -1,56 +1,324 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"agent/internal/schema"
+)
+
+// Error message constants
+const (
+	errMsgToolNotFound = "tool not found: %s"
+	errMsgReadOnly     = "tool %s modifies the workspace and is disabled in read-only mode"
+	errMsgDisabled     = "tool %s is disabled by configuration"
+	errMsgUnknownName  = "unknown tool or group %q in tool configuration"
+	errMsgEmptyName    = "cannot register a tool with an empty name"
+	errMsgNoFunction   = "tool %s has no Function"
+	errMsgDuplicate    = "tool %q is already registered (use Override to replace it deliberately)"
+	errMsgNoOverride   = "cannot override tool %q: no tool with that name is registered"
+	errMsgEmptyDesc    = "description override for tool %q is empty"
 )
 
-// Registry manages tool registration and retrieval
+// Registry manages tool registration and retrieval. Tools are indexed by
+// name, and listings are always returned in a deterministic order so the
+// tool list sent to the model is stable across runs (which keeps prompt
+// caching effective).
 type Registry struct {
-	tools []ToolDefinition
-	mutex sync.RWMutex
+	tools      map[string]ToolDefinition
+	order      []string // Tool names sorted by Priority (descending), then name
+	middleware []Middleware
+	enabled    map[string]bool // Tool or group names; empty means all tools are enabled
+	disabled   map[string]bool // Tool or group names; takes precedence over enabled
+	readOnly   bool
+	mutex      sync.RWMutex
 }
 
-// Register adds a tool to the registry
-func (r *Registry) Register(tool ToolDefinition) {
+// Register adds a tool to the registry. Tool names must be unique.
+func (r *Registry) Register(tool ToolDefinition) error {
+	if err := validateDefinition(tool); err != nil {
+		return err
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.tools = append(r.tools, tool)
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf(errMsgDuplicate, tool.Name)
+	}
+	if r.tools == nil {
+		r.tools = make(map[string]ToolDefinition)
+	}
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	r.sortOrder()
+	return nil
 }
 
-// RegisterTool adds a Tool interface implementation to the registry
+// RegisterTool adds a Tool interface implementation to the registry.
+// It is called from init functions, so a conflicting registration panics
+// at startup rather than silently shadowing another tool.
 func (r *Registry) RegisterTool(tool Tool) {
-	r.Register(ToolAdapter(tool))
+	if err := r.Register(ToolAdapter(tool)); err != nil {
+		panic(err)
+	}
+}
+
+// Override replaces an already registered tool with the same name. Use it
+// to deliberately swap an implementation; registering a new tool with an
+// existing name through Register is an error.
+func (r *Registry) Override(tool ToolDefinition) error {
+	if err := validateDefinition(tool); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.tools[tool.Name]; !exists {
+		return fmt.Errorf(errMsgNoOverride, tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.sortOrder()
+	return nil
 }
 
-// GetAll returns all registered tools
+// SetDescription replaces the description of a registered tool, so the
+// text the model sees can be tuned from config without recompiling
+func (r *Registry) SetDescription(name, description string) error {
+	if strings.TrimSpace(description) == "" {
+		return fmt.Errorf(errMsgEmptyDesc, name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	tool, exists := r.tools[name]
+	if !exists {
+		return fmt.Errorf(errMsgToolNotFound, name)
+	}
+	tool.Description = description
+	r.tools[name] = tool
+	return nil
+}
+
+// sortOrder orders tools by priority hint, then name. Callers must hold the lock.
+func (r *Registry) sortOrder() {
+	sort.Slice(r.order, func(i, j int) bool {
+		a, b := r.tools[r.order[i]], r.tools[r.order[j]]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.Name < b.Name
+	})
+}
+
+func validateDefinition(tool ToolDefinition) error {
+	if tool.Name == "" {
+		return errors.New(errMsgEmptyName)
+	}
+	if tool.Function == nil {
+		return fmt.Errorf(errMsgNoFunction, tool.Name)
+	}
+	return nil
+}
+
+// Use appends middleware to the chain applied to every tool. The first
+// middleware registered is the outermost wrapper.
+func (r *Registry) Use(middleware ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// wrap returns a copy of tool whose Function validates the input against the
+// tool's schema and then runs through the middleware chain. Validation comes
+// first so malformed calls are rejected before any prompt or side effect.
+// Callers must hold the read lock.
+func (r *Registry) wrap(tool ToolDefinition) ToolDefinition {
+	fn := tool.Function
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		fn = r.middleware[i](fn)
+	}
+
+	def := tool
+	def.Function = func(ctx context.Context, toolCtx *ToolContext, input json.RawMessage) (*ToolResult, error) {
+		if toolCtx == nil {
+			toolCtx = &ToolContext{}
+		}
+		if toolCtx.Tool == nil {
+			toolCtx.Tool = &def
+		}
+
+		if err := schema.ValidateInput(def.InputSchema, input); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		result, err := fn(ctx, toolCtx, input)
+		if result != nil && result.Metadata.Duration == 0 {
+			result.Metadata.Duration = time.Since(start)
+		}
+		return result, err
+	}
+	return def
+}
+
+// GetAll returns all available tools in presentation order, excluding
+// disabled tools and mutating tools in read-only mode
 func (r *Registry) GetAll() []ToolDefinition {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	// Return a copy to prevent external modification
-	result := make([]ToolDefinition, len(r.tools))
-	copy(result, r.tools)
+	// Return copies to prevent external modification
+	result := make([]ToolDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		if r.readOnly && tool.Mutating {
+			continue
+		}
+		if !r.isEnabled(tool) {
+			continue
+		}
+		result = append(result, r.wrap(tool))
+	}
 	return result
 }
 
-// GetByName returns a tool by its name, or nil if not found
-func (r *Registry) GetByName(name string) *ToolDefinition {
+// All returns every registered tool in presentation order, including
+// disabled tools and mutating tools blocked by read-only mode
+func (r *Registry) All() []ToolDefinition {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
+	result := make([]ToolDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		result = append(result, r.tools[name])
+	}
+	return result
+}
+
+// Resolve returns the tool to execute for a call, or an error if it is unknown or blocked
+func (r *Registry) Resolve(name string) (*ToolDefinition, error) {
+	tool := r.GetByName(name)
+	if tool == nil {
+		return nil, NotFound(fmt.Errorf(errMsgToolNotFound, name))
+	}
+	if tool.Mutating && r.ReadOnly() {
+		return nil, PermissionDenied(fmt.Errorf(errMsgReadOnly, name))
+	}
+
+	r.mutex.RLock()
+	enabled := r.isEnabled(*tool)
+	r.mutex.RUnlock()
+	if !enabled {
+		return nil, PermissionDenied(fmt.Errorf(errMsgDisabled, name))
+	}
+	return tool, nil
+}
+
+// SetEnabled restricts which tools are offered. Both lists accept tool names
+// and group names; disabled entries win over enabled ones, and an empty
+// enabled list enables every tool not disabled. Unknown names are an error.
+func (r *Registry) SetEnabled(enabled, disabled []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	known := make(map[string]bool)
 	for _, tool := range r.tools {
-		if tool.Name == name {
-			// Return a copy to prevent external modification
-			toolCopy := tool
-			return &toolCopy
+		known[tool.Name] = true
+		if tool.Group != "" {
+			known[tool.Group] = true
+		}
+	}
+
+	toSet := func(names []string) (map[string]bool, error) {
+		set := make(map[string]bool)
+		for _, name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf(errMsgUnknownName, name)
+			}
+			set[name] = true
 		}
+		return set, nil
 	}
+
+	enabledSet, err := toSet(enabled)
+	if err != nil {
+		return err
+	}
+	disabledSet, err := toSet(disabled)
+	if err != nil {
+		return err
+	}
+
+	r.enabled = enabledSet
+	r.disabled = disabledSet
 	return nil
 }
 
+// Groups returns the names of all registered tool groups
+func (r *Registry) Groups() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	seen := make(map[string]bool)
+	var groups []string
+	for _, tool := range r.tools {
+		if tool.Group != "" && !seen[tool.Group] {
+			seen[tool.Group] = true
+			groups = append(groups, tool.Group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// isEnabled applies the enable/disable configuration. Callers must hold the read lock.
+func (r *Registry) isEnabled(tool ToolDefinition) bool {
+	if r.disabled[tool.Name] || (tool.Group != "" && r.disabled[tool.Group]) {
+		return false
+	}
+	if len(r.enabled) == 0 {
+		return true
+	}
+	return r.enabled[tool.Name] || (tool.Group != "" && r.enabled[tool.Group])
+}
+
+// SetReadOnly enables or disables read-only mode
+func (r *Registry) SetReadOnly(readOnly bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.readOnly = readOnly
+}
+
+// ReadOnly reports whether mutating tools are currently blocked
+func (r *Registry) ReadOnly() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.readOnly
+}
+
+// GetByName returns a tool by its name, or nil if not found
+func (r *Registry) GetByName(name string) *ToolDefinition {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	tool, exists := r.tools[name]
+	if !exists {
+		return nil
+	}
+	// Return a wrapped copy to prevent external modification
+	toolCopy := r.wrap(tool)
+	return &toolCopy
+}
+
 // Clear removes all tools from the registry (useful for testing)
 func (r *Registry) Clear() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.tools = r.tools[:0]
+	r.tools = nil
+	r.order = nil
 }
 
 // DefaultRegistry is the global registry instance
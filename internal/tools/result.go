@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"strings"
+	"time"
+)
+
+// ContentType identifies the kind of a result content block
+type ContentType string
+
+const (
+	ContentText  ContentType = "text"
+	ContentImage ContentType = "image"
+)
+
+// ContentBlock is one piece of tool output sent back to the model
+type ContentBlock struct {
+	Type      ContentType
+	Text      string // Set for text blocks
+	MediaType string // Set for image blocks, e.g. "image/png"
+	Data      []byte // Raw image bytes; base64-encoded when sent to the API
+}
+
+// ResultMetadata describes side effects and cost of a tool call
+type ResultMetadata struct {
+	FilesChanged []string
+	FilesCreated []string // The changed files that did not exist before
+	FilesDeleted []string // The changed files that no longer exist
+	Commands     []string // Command lines the tool ran
+	Sources      []string // Absolute paths the result was derived from, used to detect staleness
+	Duration     time.Duration
+}
+
+// ToolResult is the structured outcome of a tool call
+type ToolResult struct {
+	Content   []ContentBlock
+	IsError   bool
+	ErrorKind ErrorKind // Optional classification of an error result
+	Metadata  ResultMetadata
+}
+
+// NewTextResult creates a successful result with a single text block
+func NewTextResult(text string) *ToolResult {
+	return &ToolResult{Content: []ContentBlock{{Type: ContentText, Text: text}}}
+}
+
+// NewErrorResult creates a failed result with a single text block. Use it when
+// a tool has useful output to return alongside the failure (e.g. command output).
+func NewErrorResult(text string) *ToolResult {
+	result := NewTextResult(text)
+	result.IsError = true
+	return result
+}
+
+// NewImageResult creates a successful result with a single image block
+func NewImageResult(mediaType string, data []byte) *ToolResult {
+	return &ToolResult{Content: []ContentBlock{{Type: ContentImage, MediaType: mediaType, Data: data}}}
+}
+
+// WithFilesChanged records paths the tool created, modified or deleted
+func (r *ToolResult) WithFilesChanged(paths ...string) *ToolResult {
+	r.Metadata.FilesChanged = append(r.Metadata.FilesChanged, paths...)
+	return r
+}
+
+// WithFilesCreated records paths the tool created
+func (r *ToolResult) WithFilesCreated(paths ...string) *ToolResult {
+	r.Metadata.FilesCreated = append(r.Metadata.FilesCreated, paths...)
+	return r.WithFilesChanged(paths...)
+}
+
+// WithFilesDeleted records paths the tool deleted
+func (r *ToolResult) WithFilesDeleted(paths ...string) *ToolResult {
+	r.Metadata.FilesDeleted = append(r.Metadata.FilesDeleted, paths...)
+	return r.WithFilesChanged(paths...)
+}
+
+// WithCommands records the command lines the tool ran
+func (r *ToolResult) WithCommands(lines ...string) *ToolResult {
+	r.Metadata.Commands = append(r.Metadata.Commands, lines...)
+	return r
+}
+
+// WithSources records absolute paths whose modification time and size
+// determine whether the result is still current
+func (r *ToolResult) WithSources(paths ...string) *ToolResult {
+	r.Metadata.Sources = append(r.Metadata.Sources, paths...)
+	return r
+}
+
+// Text returns the concatenated text of all text blocks
+func (r *ToolResult) Text() string {
+	var parts []string
+	for _, block := range r.Content {
+		if block.Type == ContentText {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,25 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// summaryKeys are the input fields that identify what a call works on, in order of preference
+var summaryKeys = []string{"path", "command", "pattern", "name", "query"}
+
+// SummarizeInput renders tool input on one line for progress displays,
+// preferring the field that names the target, e.g. "main.go" for
+// {"path": "main.go"}
+func SummarizeInput(input json.RawMessage) string {
+	var fields map[string]any
+	if err := json.Unmarshal(input, &fields); err == nil {
+		for _, key := range summaryKeys {
+			if value, ok := fields[key]; ok {
+				return strings.Join(strings.Fields(fmt.Sprint(value)), " ")
+			}
+		}
+	}
+	return strings.Join(strings.Fields(string(input)), " ")
+}
@@ -0,0 +1,30 @@
+package terraform
+
+import (
+	"testing"
+
+	"agent/internal/tools/toolstest"
+)
+
+// Execute runs terraform, so the tool is fuzzed up to the arguments it
+// would run it with
+func FuzzTerraformInput(f *testing.F) {
+	toolstest.Seed(f, TerraformTool{}, `{"action": "plan", "vars": {"a": "-destroy", "-b": "1"}, "targets": ["-lock=false"]}`, `{"action": "fmt", "vars": {"a": "b"}}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		var terraformInput TerraformInput
+		if toolstest.Decode(t, TerraformTool{}, input, &terraformInput) != nil || terraformInput.Validate() != nil {
+			return
+		}
+		// Every value follows its own -var or -target, so none can be taken
+		// for an option
+		args := terraformInput.planArgs()
+		if len(args) != 2*(len(terraformInput.Vars)+len(terraformInput.Targets)) {
+			t.Fatalf("planArgs() = %q for %d vars and %d targets", args, len(terraformInput.Vars), len(terraformInput.Targets))
+		}
+		for i := 0; i < len(args); i += 2 {
+			if args[i] != "-var" && args[i] != "-target" {
+				t.Fatalf("planArgs() = %q has %q where an option belongs", args, args[i])
+			}
+		}
+	})
+}
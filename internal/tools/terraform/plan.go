@@ -0,0 +1,132 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxListedChanges is how many resource changes a summary lists by address
+const maxListedChanges = 50
+
+// planSummary counts the resource changes of a plan the way terraform's
+// "Plan:" line does, a replacement counting as an add and a destroy
+type planSummary struct {
+	add, change, destroy int
+	changes              []resourceChange // Nil when the counts came from terraform's text output
+	unknown              bool             // Neither form of the plan could be read
+}
+
+// resourceChange is a change a plan makes to one resource
+type resourceChange struct {
+	address string
+	action  string // create, update, replace or delete
+}
+
+// planPattern matches the counts terraform prints at the end of a plan
+var planPattern = regexp.MustCompile(`Plan: (\d+) to add, (\d+) to change, (\d+) to destroy`)
+
+// parsePlanJSON summarizes the output of terraform show -json for a saved plan
+func parsePlanJSON(data []byte) (*planSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan: %w", err)
+	}
+
+	summary := &planSummary{changes: []resourceChange{}}
+	for _, resource := range plan.ResourceChanges {
+		var action string
+		switch actions := resource.Change.Actions; {
+		case slices.Equal(actions, []string{"create"}):
+			action = "create"
+			summary.add++
+		case slices.Equal(actions, []string{"update"}):
+			action = "update"
+			summary.change++
+		case slices.Equal(actions, []string{"delete"}):
+			action = "delete"
+			summary.destroy++
+		case slices.Contains(actions, "create") && slices.Contains(actions, "delete"):
+			action = "replace"
+			summary.add++
+			summary.destroy++
+		default:
+			continue // no-op and read
+		}
+		summary.changes = append(summary.changes, resourceChange{address: resource.Address, action: action})
+	}
+	return summary, nil
+}
+
+// parsePlanText summarizes a plan from terraform's text output
+func parsePlanText(output string) *planSummary {
+	summary := &planSummary{}
+	if match := planPattern.FindStringSubmatch(output); match != nil {
+		summary.add, _ = strconv.Atoi(match[1])
+		summary.change, _ = strconv.Atoi(match[2])
+		summary.destroy, _ = strconv.Atoi(match[3])
+	} else if !strings.Contains(output, "No changes.") {
+		summary.unknown = true
+	}
+	return summary
+}
+
+// empty reports whether the plan is known to change no resources
+func (s *planSummary) empty() bool {
+	return !s.unknown && s.add == 0 && s.change == 0 && s.destroy == 0
+}
+
+// counts returns the counts as terraform words them
+func (s *planSummary) counts() string {
+	return fmt.Sprintf("%d to add, %d to change, %d to destroy", s.add, s.change, s.destroy)
+}
+
+// String returns the counts followed by the changed resources, destroys
+// and replacements first so they are not missed
+func (s *planSummary) String() string {
+	if s.unknown {
+		return "Plan: the changes could not be counted; see terraform's output."
+	}
+	if s.empty() {
+		return "Plan: no changes. The infrastructure matches the configuration."
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "Plan: %s.", s.counts())
+	changes := slices.Clone(s.changes)
+	slices.SortStableFunc(changes, func(a, b resourceChange) int {
+		return actionOrder[a.action] - actionOrder[b.action]
+	})
+	for i, change := range changes {
+		if i == maxListedChanges {
+			fmt.Fprintf(&text, "\n... and %d more", len(changes)-i)
+			break
+		}
+		fmt.Fprintf(&text, "\n%3s %s (%s)", actionSymbols[change.action], change.address, change.action)
+	}
+	return text.String()
+}
+
+// actionOrder sorts the changes of a summary
+var actionOrder = map[string]int{"delete": 0, "replace": 1, "update": 2, "create": 3}
+
+// actionSymbols are the markers terraform shows changes with
+var actionSymbols = map[string]string{"create": "+", "update": "~", "replace": "-/+", "delete": "-"}
+
+// planOutput drops the lines terraform prints after a saved plan about
+// applying it: the plan file is removed, and apply goes through this tool
+func planOutput(output string) string {
+	if i := strings.Index(output, "Saved the plan to:"); i >= 0 {
+		output = output[:i]
+	}
+	return strings.TrimRight(strings.TrimRight(strings.TrimRight(output, " \n"), "─"), " \n")
+}
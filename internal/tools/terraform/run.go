@@ -0,0 +1,140 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+const (
+	// timeout bounds each terraform command; applies that create databases
+	// or clusters can take many minutes
+	timeout = 30 * time.Minute
+	// maxOutputBytes is how much of a command's output is returned, kept
+	// from the end, where terraform reports results and errors
+	maxOutputBytes = 30000
+)
+
+// environment keeps terraform from prompting, which nothing would answer,
+// and from suggesting commands to run next
+var environment = map[string]string{
+	"TF_IN_AUTOMATION": "1",
+	"TF_INPUT":         "0",
+}
+
+// commandRun is the outcome of a terraform command that ran
+type commandRun struct {
+	command  string
+	exitCode int
+	output   string // stdout and stderr, interleaved
+}
+
+// errorResult reports a failed run with its output
+func (r *commandRun) errorResult(message string) *tools.ToolResult {
+	text := fmt.Sprintf("%s (exit code %d):\n%s", message, r.exitCode, lastBytes(r.output))
+	return tools.NewErrorResult(text).WithCommands(r.command)
+}
+
+// run runs terraform with args in the module's directory, showing its
+// output to the user as it arrives. A command that ran is returned whatever
+// its exit code; errors mean it could not be run or was stopped.
+func (m *module) run(ctx context.Context, args ...string) (*commandRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	argv := append([]string{"terraform"}, args...)
+	var progress io.Writer
+	if m.toolCtx != nil && m.toolCtx.Output != nil && args[0] != "show" {
+		progress = m.toolCtx.Output
+	}
+	output := &outputBuffer{progress: progress}
+	process, err := workspaceOf(m.toolCtx).Runner().Start(workspace.Cmd{Argv: argv, Dir: m.dir, Env: environment, Stdout: output, Stderr: output, Group: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run terraform (is it installed and on the PATH?): %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+	select {
+	case err = <-exited:
+	case <-ctx.Done():
+		// An interrupt lets terraform release its state lock before exiting
+		process.Terminate()
+		select {
+		case <-exited:
+		case <-time.After(30 * time.Second):
+			process.Kill()
+			<-exited
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("terraform %s timed out after %s", args[0], timeout)
+		}
+		return nil, fmt.Errorf("terraform %s cancelled: %w", args[0], ctx.Err())
+	}
+
+	run := &commandRun{command: strings.Join(argv, " "), output: output.String()}
+	var exit workspace.ExitCoder
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		run.exitCode = exit.ExitCode()
+	default:
+		return nil, fmt.Errorf("terraform %s failed: %w", args[0], err)
+	}
+	return run, nil
+}
+
+// workspaceOf returns the workspace of a tool call, nil when there is none
+func workspaceOf(toolCtx *tools.ToolContext) *workspace.Workspace {
+	if toolCtx == nil {
+		return nil
+	}
+	return toolCtx.Workspace
+}
+
+// outputBuffer collects a command's output while copying it to a live
+// display. Display errors are ignored so a closed terminal never fails
+// the command.
+type outputBuffer struct {
+	mutex    sync.Mutex
+	buffer   bytes.Buffer
+	progress io.Writer
+}
+
+// Write implements io.Writer
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.buffer.Write(p)
+	if b.progress != nil {
+		b.progress.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *outputBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// lastBytes keeps the end of output within maxOutputBytes, from the start
+// of a line
+func lastBytes(output string) string {
+	output = strings.TrimRight(strings.TrimLeft(output, "\n"), " \n")
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	cut := len(output) - maxOutputBytes
+	if i := strings.IndexByte(output[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return fmt.Sprintf("... [%d bytes of output omitted] ...\n%s", cut, output[cut:])
+}
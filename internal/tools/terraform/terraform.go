@@ -0,0 +1,347 @@
+// Package terraform provides a tool for working on Terraform configurations:
+// formatting, validating and planning them, and applying a plan only after
+// the user has seen what it changes and approved it.
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/internal/confirm"
+	"agent/internal/schema"
+	"agent/internal/tools"
+)
+
+// Error message constants
+const (
+	errMsgMissingParam = "parameter %q is required"
+	errMsgNotDirectory = "%s is not a directory"
+	errMsgDirNotFound  = "directory %q not found"
+)
+
+// Actions of the terraform tool
+const (
+	actionFmt      = "fmt"
+	actionValidate = "validate"
+	actionInit     = "init"
+	actionPlan     = "plan"
+	actionApply    = "apply"
+)
+
+// TerraformInput represents the input parameters for the terraform tool
+type TerraformInput struct {
+	Action  string            `json:"action" jsonschema:"required,enum=fmt,enum=validate,enum=init,enum=plan,enum=apply" jsonschema_description:"fmt formats the configuration's files, validate checks it, init installs its providers and modules, plan shows what applying it would change, and apply makes those changes after the user approves the plan"`
+	Path    string            `json:"path,omitempty" jsonschema_description:"Directory of the Terraform configuration, relative to the workspace root; defaults to the root"`
+	Vars    map[string]string `json:"vars,omitempty" jsonschema_description:"Input variables for plan and apply, e.g. {\"environment\": \"staging\"}"`
+	Targets []string          `json:"targets,omitempty" jsonschema_description:"Resource addresses to limit plan and apply to, e.g. [\"aws_s3_bucket.logs\"]"`
+}
+
+// Validate implements input validation
+func (i *TerraformInput) Validate() error {
+	switch i.Action {
+	case "":
+		return fmt.Errorf(errMsgMissingParam, "action")
+	case actionFmt, actionValidate, actionInit:
+		if len(i.Vars) > 0 || len(i.Targets) > 0 {
+			return fmt.Errorf("parameters \"vars\" and \"targets\" apply only to plan and apply")
+		}
+		return nil
+	case actionPlan, actionApply:
+		return nil
+	}
+	return fmt.Errorf("action must be fmt, validate, init, plan or apply, not %q", i.Action)
+}
+
+// planArgs returns the -var and -target options of a plan
+func (i *TerraformInput) planArgs() []string {
+	var args []string
+	names := make([]string, 0, len(i.Vars))
+	for name := range i.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-var", name+"="+i.Vars[name])
+	}
+	for _, target := range i.Targets {
+		args = append(args, "-target", target)
+	}
+	return args
+}
+
+type TerraformTool struct{}
+
+func (t TerraformTool) Definition() tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     "terraform",
+		Group:    "terraform",
+		Mutating: true,
+		Confirms: true,
+		Description: `Format, validate, plan and apply Terraform configurations.
+
+Usage Examples:
+- {"action": "fmt", "path": "infra"} // Format the .tf files of a directory
+- {"action": "validate", "path": "infra"} // Check the configuration for errors
+- {"action": "init", "path": "infra"} // Install providers and modules (needed before validate and plan)
+- {"action": "plan", "path": "infra", "vars": {"environment": "staging"}} // Show what would change
+- {"action": "apply", "path": "infra"} // Plan, ask the user to approve the plan, then apply exactly that plan
+
+Plans start with a summary of the resources they add, change, replace and destroy,
+followed by Terraform's own output. apply always asks the user, even when the tool is
+allowed by the permission policy, and applies only the plan the user approved.
+Prefer plan to check a change; use apply only when the user asks for the change to be made.`,
+		InputSchema: schema.GenerateSchema[TerraformInput](),
+	}
+}
+
+func (t TerraformTool) Execute(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+	var terraformInput TerraformInput
+	if err := tools.DecodeInput(input, &terraformInput); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+	if err := terraformInput.Validate(); err != nil {
+		return nil, tools.InvalidInput(err)
+	}
+
+	dir, shown, err := resolveDir(toolCtx, terraformInput.Path)
+	if err != nil {
+		return nil, err
+	}
+	module := &module{toolCtx: toolCtx, dir: dir, shown: shown}
+
+	switch terraformInput.Action {
+	case actionFmt:
+		return module.format(ctx)
+	case actionValidate:
+		return module.validate(ctx)
+	case actionInit:
+		return module.init(ctx)
+	case actionPlan:
+		return module.plan(ctx, &terraformInput)
+	default:
+		return module.apply(ctx, &terraformInput)
+	}
+}
+
+// resolveDir resolves the directory of a configuration within the workspace
+func resolveDir(toolCtx *tools.ToolContext, path string) (resolved, shown string, err error) {
+	if path == "" {
+		path = "."
+	}
+	resolved, shown = path, filepath.Clean(path)
+	ws := workspaceOf(toolCtx)
+	if ws != nil {
+		p, err := ws.ResolvePath(path)
+		if err != nil {
+			return "", "", err
+		}
+		if p.Ask != "" && !toolCtx.Confirm(confirm.Request{Tool: p.Ask, Action: "use a directory outside the workspace", Path: p.Abs}) {
+			return "", "", tools.PermissionDenied(fmt.Errorf("permission denied: user declined access to %s", p.Abs))
+		}
+		resolved, shown = p.Abs, p.Shown
+	}
+	info, err := ws.FS().Stat(resolved)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", tools.NotFound(fmt.Errorf(errMsgDirNotFound, shown))
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if !info.IsDir() {
+		return "", "", tools.InvalidInput(fmt.Errorf(errMsgNotDirectory, shown))
+	}
+	return resolved, shown, nil
+}
+
+// module is the Terraform configuration in one directory
+type module struct {
+	toolCtx *tools.ToolContext
+	dir     string
+	shown   string
+}
+
+// name describes the module's directory for messages
+func (m *module) name() string {
+	if m.shown == "." {
+		return "the workspace root"
+	}
+	return m.shown
+}
+
+// format shows the user the changes terraform fmt would make and makes them
+// once approved
+func (m *module) format(ctx context.Context) (*tools.ToolResult, error) {
+	// -check exits with 3 when files need formatting, and -diff shows how
+	check, err := m.run(ctx, "fmt", "-check", "-diff", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	if check.exitCode != 0 && check.exitCode != 3 {
+		return check.errorResult("terraform fmt failed"), nil
+	}
+	if strings.TrimSpace(check.output) == "" {
+		return tools.NewTextResult(fmt.Sprintf("The Terraform files in %s are already formatted", m.name())).WithCommands(check.command), nil
+	}
+
+	approved := m.toolCtx.Confirm(confirm.Request{
+		Tool:    "terraform",
+		Action:  fmt.Sprintf("format the Terraform files in %s", m.name()),
+		Path:    m.dir,
+		Preview: check.output,
+	})
+	if !approved {
+		return tools.NewTextResult("Formatting cancelled by user"), nil
+	}
+
+	// -list prints the name of each file it rewrites
+	formatted, err := m.run(ctx, "fmt", "-list=true", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	if formatted.exitCode != 0 {
+		return formatted.errorResult("terraform fmt failed"), nil
+	}
+	var files []string
+	for _, name := range strings.Fields(formatted.output) {
+		files = append(files, filepath.Join(m.shown, name))
+	}
+	result := tools.NewTextResult("Formatted " + strings.Join(files, ", "))
+	return result.WithFilesChanged(files...).WithCommands(check.command, formatted.command), nil
+}
+
+// validate reports whether the configuration is valid
+func (m *module) validate(ctx context.Context) (*tools.ToolResult, error) {
+	run, err := m.run(ctx, "validate", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	if run.exitCode != 0 {
+		return run.errorResult("terraform validate found problems"), nil
+	}
+	return tools.NewTextResult(strings.TrimSpace(run.output)).WithCommands(run.command), nil
+}
+
+// init installs the configuration's providers and modules and sets up its
+// backend, once approved
+func (m *module) init(ctx context.Context) (*tools.ToolResult, error) {
+	approved := m.toolCtx.Confirm(confirm.Request{
+		Tool:    "terraform",
+		Action:  fmt.Sprintf("initialize the Terraform configuration in %s", m.name()),
+		Path:    m.dir,
+		Preview: "terraform init -input=false",
+	})
+	if !approved {
+		return tools.NewTextResult("Initialization cancelled by user"), nil
+	}
+	run, err := m.run(ctx, "init", "-input=false", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	if run.exitCode != 0 {
+		return run.errorResult("terraform init failed"), nil
+	}
+	return tools.NewTextResult(lastBytes(run.output)).WithCommands(run.command), nil
+}
+
+// plan reports what applying the configuration would change
+func (m *module) plan(ctx context.Context, input *TerraformInput) (*tools.ToolResult, error) {
+	plan, failed, err := m.makePlan(ctx, input)
+	if err != nil || failed != nil {
+		return failed, err
+	}
+	defer m.removePlan(plan.file)
+	return tools.NewTextResult(plan.String()).WithCommands(plan.commands...), nil
+}
+
+// apply plans, shows the plan to the user and applies exactly that plan once
+// approved. The confirmation is forced: permission rules and "always"
+// answers never approve an apply.
+func (m *module) apply(ctx context.Context, input *TerraformInput) (*tools.ToolResult, error) {
+	plan, failed, err := m.makePlan(ctx, input)
+	if err != nil || failed != nil {
+		return failed, err
+	}
+	defer m.removePlan(plan.file)
+	if plan.summary.empty() {
+		return tools.NewTextResult(plan.String() + "\n\nNothing to apply.").WithCommands(plan.commands...), nil
+	}
+
+	approved := m.toolCtx.Confirm(confirm.Request{
+		Tool:    "terraform",
+		Action:  fmt.Sprintf("apply the Terraform plan for %s", m.name()),
+		Path:    m.dir,
+		Preview: plan.String(),
+		Force:   true,
+	})
+	if !approved {
+		return tools.NewTextResult("Apply cancelled by user; nothing was changed"), nil
+	}
+
+	run, err := m.run(ctx, "apply", "-input=false", "-no-color", plan.file)
+	if err != nil {
+		return nil, err
+	}
+	commands := append(plan.commands, run.command)
+	if run.exitCode != 0 {
+		return run.errorResult("terraform apply failed; some changes may have been made").WithCommands(commands...), nil
+	}
+	return tools.NewTextResult(fmt.Sprintf("Applied the plan for %s.\n\n%s", m.name(), lastBytes(run.output))).WithCommands(commands...), nil
+}
+
+// makePlan saves a plan of the configuration to a file in its directory and
+// summarizes it. A plan that terraform could not make is returned as an
+// error result.
+func (m *module) makePlan(ctx context.Context, input *TerraformInput) (*savedPlan, *tools.ToolResult, error) {
+	file := fmt.Sprintf(".billdozer-%d.tfplan", time.Now().UnixNano())
+	args := append([]string{"plan", "-input=false", "-no-color", "-out=" + file}, input.planArgs()...)
+	run, err := m.run(ctx, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if run.exitCode != 0 {
+		m.removePlan(file)
+		return nil, run.errorResult("terraform plan failed"), nil
+	}
+
+	plan := &savedPlan{file: file, output: run.output, commands: []string{run.command}}
+	// The JSON form of a saved plan lists every resource change; the text
+	// output's "Plan:" line is the fallback when it cannot be read
+	show, err := m.run(ctx, "show", "-json", "-no-color", file)
+	if err == nil && show.exitCode == 0 {
+		plan.summary, err = parsePlanJSON([]byte(show.output))
+	}
+	if err != nil || show.exitCode != 0 {
+		plan.summary = parsePlanText(run.output)
+	}
+	return plan, nil, nil
+}
+
+// removePlan deletes a saved plan file, which holds variable values and
+// may hold secrets
+func (m *module) removePlan(file string) {
+	_ = workspaceOf(m.toolCtx).FS().Remove(filepath.Join(m.dir, file))
+}
+
+// savedPlan is a plan saved to a file, with its summary
+type savedPlan struct {
+	file     string
+	output   string
+	summary  *planSummary
+	commands []string
+}
+
+// String returns the summary followed by terraform's output
+func (p *savedPlan) String() string {
+	return p.summary.String() + "\n\nterraform plan output:\n" + lastBytes(planOutput(p.output))
+}
+
+func init() {
+	tools.DefaultRegistry.RegisterTool(TerraformTool{})
+}
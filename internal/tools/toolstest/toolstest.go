@@ -0,0 +1,124 @@
+// Package toolstest helps test tools: it seeds fuzz targets with a tool's
+// usage examples and runs tools in a scratch workspace they cannot leave
+package toolstest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/internal/tools"
+	"agent/internal/workspace"
+)
+
+// timeout bounds each call of Execute, so a tool that hangs on some input
+// fails the test instead of stalling the fuzzer
+const timeout = 10 * time.Second
+
+// malformed are inputs every tool must reject with an error, not a panic
+var malformed = []string{"", "null", "[]", `"path"`, "42", "{", `{"path": 1}`, `{"path": null}`, `{} {}`, `{}}`}
+
+// Examples returns the inputs of the usage examples in a tool's
+// description: the JSON object on each line starting with "- {"
+func Examples(def tools.ToolDefinition) []string {
+	var examples []string
+	for _, line := range strings.Split(def.Description, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "- {") {
+			continue
+		}
+		var example json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(line[2:])).Decode(&example); err == nil {
+			examples = append(examples, string(example))
+		}
+	}
+	return examples
+}
+
+// Seed adds a tool's usage examples, the given inputs, {} and common
+// malformed inputs to a fuzz target's corpus
+func Seed(f *testing.F, tool tools.Tool, inputs ...string) {
+	f.Helper()
+	inputs = append(append(Examples(tool.Definition()), inputs...), "{}")
+	for _, input := range append(inputs, malformed...) {
+		f.Add(input)
+	}
+}
+
+// Context returns a tool context whose workspace is a new temporary
+// directory holding files, keyed by slash-separated path. The workspace
+// denies paths outside it, so a tool under test touches nothing else.
+func Context(tb testing.TB, files map[string]string) *tools.ToolContext {
+	tb.Helper()
+	root := tb.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	ws, err := workspace.New(root, false)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ws.SetPathPolicy(workspace.PathPolicy{Outside: workspace.PathDeny})
+	return &tools.ToolContext{Workspace: ws}
+}
+
+// Execute runs a tool the way the agent does, through a registry, which
+// checks the input against the tool's schema before Execute sees it. It
+// fails the test when the tool neither returns a result nor an error, or
+// does not return in time.
+func Execute(t *testing.T, tool tools.Tool, toolCtx *tools.ToolContext, input string) (*tools.ToolResult, error) {
+	t.Helper()
+	def := register(t, tools.ToolAdapter(tool))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	result, err := def.Function(ctx, toolCtx, json.RawMessage(input))
+	if result == nil && err == nil {
+		t.Fatalf("%s returned neither a result nor an error for %q", def.Name, input)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("%s did not return within %s for %q", def.Name, timeout, input)
+	}
+	return result, err
+}
+
+// Decode runs a tool's input through a registry, as Execute does, up to the
+// first step of the tool's Execute, tools.DecodeInput into v. It is for
+// tools whose Execute starts processes. The test fails when DecodeInput
+// accepts input that is not one JSON value.
+func Decode(t *testing.T, tool tools.Tool, input string, v any) error {
+	t.Helper()
+	def := tool.Definition()
+	def.Function = func(_ context.Context, _ *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+		return nil, tools.DecodeInput(input, v)
+	}
+	_, err := register(t, def).Function(context.Background(), nil, json.RawMessage(input))
+	if err == nil && strings.TrimSpace(input) != "" && !json.Valid([]byte(input)) {
+		t.Fatalf("%s accepted invalid JSON %q", def.Name, input)
+	}
+	return err
+}
+
+// register adds a tool to a registry of its own and returns it as the
+// registry hands it out, with its input validation around it
+func register(t *testing.T, def tools.ToolDefinition) *tools.ToolDefinition {
+	t.Helper()
+	var registry tools.Registry
+	if err := registry.Register(def); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := registry.Resolve(def.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
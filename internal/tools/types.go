@@ -1,28 +1,74 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"time"
 
+	"agent/internal/backup"
+	"agent/internal/confirm"
+	"agent/internal/filecache"
+	"agent/internal/filelock"
+	"agent/internal/filestate"
+	"agent/internal/workspace"
 	"github.com/anthropics/anthropic-sdk-go"
 )
 
 // ToolContext provides runtime context for tool execution
 type ToolContext struct {
 	GetUserInput UserInputFunction
+	Workspace    *workspace.Workspace // Confines file paths; nil means no restriction
+	Confirmer    confirm.Confirmer    // Approves destructive operations; nil approves everything
+	Locks        *filelock.Manager    // Serializes modifications to the same file; nil disables locking
+	Backups      *backup.Session      // Keeps files before they are overwritten or deleted; nil disables backups
+	Files        *filecache.Cache     // Contents of files read earlier in the session; nil reads every time
+	Seen         *filestate.Tracker   // Versions of files the agent last read or wrote; nil allows changing files modified since
+	Output       io.Writer            // Live progress output (e.g. command output) for the user; nil discards it
+	Tool         *ToolDefinition      // Definition of the tool being executed, set by the registry
+}
+
+// Confirm asks the configured confirmer to approve an operation
+func (c *ToolContext) Confirm(req confirm.Request) bool {
+	if c == nil || c.Confirmer == nil {
+		return true
+	}
+	return c.Confirmer.Confirm(req)
 }
 
 // ToolDefinition represents a tool that can be called by the agent
 type ToolDefinition struct {
 	Name        string                         `json:"name"`
 	Description string                         `json:"description"`
+	Group       string                         `json:"-"` // Namespace such as "file" or "command", used to enable/disable related tools
+	Priority    int                            `json:"-"` // Ordering hint; higher values are presented to the model first
 	InputSchema anthropic.ToolInputSchemaParam `json:"input_schema"`
-	Function    func(ctx *ToolContext, input json.RawMessage) (string, error)
+	Mutating    bool                           `json:"-"` // Modifies files or runs commands; hidden in read-only mode
+	Confirms    bool                           `json:"-"` // Requests confirmation itself (with a preview) via ToolContext.Confirm
+	Cacheable   bool                           `json:"-"` // Idempotent read; repeated identical results are replaced with an "unchanged" marker
+	Diffable    bool                           `json:"-"` // With Cacheable, a changed repeated result is sent as a diff against the previous one unless the input sets "full"
+	Timeout     time.Duration                  `json:"-"` // Optional execution deadline; zero means no limit
+	Paths       PathsFunc                      `json:"-"` // Workspace paths a call uses, checked against permission rules; nil means its "path" parameter
+	Function    ToolFunc
 }
 
+// ToolFunc executes a tool call. ctx is cancelled when the user interrupts
+// the call or its deadline expires.
+// A returned error is reported to the model as a failed result.
+type ToolFunc func(ctx context.Context, toolCtx *ToolContext, input json.RawMessage) (*ToolResult, error)
+
+// PathsFunc returns the paths a tool call will read or write, as given in
+// its input, for the permission rules to match. See PathParams.
+type PathsFunc func(toolCtx *ToolContext, input json.RawMessage) []string
+
+// Middleware wraps a ToolFunc to add cross-cutting behavior (logging,
+// permissions, redaction, ...) around every tool call
+type Middleware func(next ToolFunc) ToolFunc
+
 // Tool interface that all tools must implement
 type Tool interface {
 	Definition() ToolDefinition
-	Execute(ctx *ToolContext, input json.RawMessage) (string, error)
+	Execute(ctx context.Context, toolCtx *ToolContext, input json.RawMessage) (*ToolResult, error)
 }
 
 // ToolAdapter adapts a Tool interface to a ToolDefinition
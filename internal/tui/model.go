@@ -0,0 +1,298 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"agent/internal/usage"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// panelRows is how many recent tool calls the expanded panel shows
+const panelRows = 8
+
+// Messages sent to the program from the session
+type (
+	outputMsg  struct{} // The transcript grew
+	waitingMsg struct{} // The session is waiting for input
+	doneMsg    struct{ err error }
+	usageMsg   struct {
+		model  string
+		totals usage.Totals
+	}
+	toolStartMsg struct {
+		id          int
+		name, input string
+	}
+	toolDoneMsg struct {
+		id       int
+		duration time.Duration
+		failed   bool
+	}
+	activityMsg struct {
+		description string // "" when the activity ended
+		started     time.Time
+	}
+	tickMsg struct{ generation int } // Redraws the elapsed time of an activity
+)
+
+// toolCall is one row of the tool panel
+type toolCall struct {
+	id       int
+	name     string
+	input    string
+	running  bool
+	failed   bool
+	duration time.Duration
+}
+
+var (
+	statusStyle  = lipgloss.NewStyle().Reverse(true)
+	panelStyle   = lipgloss.NewStyle().Faint(true)
+	failedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	runningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	doneStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+)
+
+// model is the Bubble Tea state of the UI
+type model struct {
+	ui         *UI
+	transcript viewport.Model
+	input      textinput.Model
+	calls      []toolCall
+	expanded   bool // Tool panel shows recent calls rather than a one-line count
+	waiting    bool
+	modelName  string
+	totals     usage.Totals
+	activity   string
+	started    time.Time
+	generation int // Identifies the current activity so ticks of finished ones stop
+	width      int
+	height     int
+	ready      bool
+}
+
+func newModel(u *UI, modelName string) *model {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "Working..."
+	return &model{ui: u, input: input, modelName: modelName, expanded: true}
+}
+
+func (m *model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		if !m.ready {
+			m.transcript = viewport.New(msg.Width, 1)
+			m.ready = true
+		}
+		m.input.Width = msg.Width - len(m.input.Prompt) - 1
+		m.layout()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyCtrlD:
+			if m.waiting {
+				// End the prompt line so later output starts on its own line
+				m.ui.appendText("\n")
+			}
+			return m, tea.Quit
+		case tea.KeyCtrlT:
+			m.expanded = !m.expanded
+			m.layout()
+			return m, nil
+		case tea.KeyPgUp, tea.KeyPgDown, tea.KeyUp, tea.KeyDown:
+			var cmd tea.Cmd
+			m.transcript, cmd = m.transcript.Update(msg)
+			return m, cmd
+		case tea.KeyEnter:
+			if !m.waiting {
+				return m, nil
+			}
+			line := m.input.Value()
+			m.input.Reset()
+			m.waiting = false
+			m.input.Placeholder = "Working..."
+			// Echo the answer after the prompt the session printed
+			m.ui.appendText(line + "\n")
+			m.refresh()
+			m.ui.submit(line)
+			return m, nil
+		}
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.transcript, cmd = m.transcript.Update(msg)
+		return m, cmd
+
+	case outputMsg:
+		m.refresh()
+		return m, nil
+
+	case waitingMsg:
+		m.waiting = true
+		m.input.Placeholder = "Type a message, Enter to send"
+		return m, m.input.Focus()
+
+	case activityMsg:
+		m.activity, m.started = msg.description, msg.started
+		m.generation++
+		if m.activity == "" {
+			return m, nil
+		}
+		return m, tick(m.generation)
+
+	case tickMsg:
+		if msg.generation != m.generation || m.activity == "" {
+			return m, nil
+		}
+		return m, tick(m.generation)
+
+	case usageMsg:
+		m.modelName, m.totals = msg.model, msg.totals
+		return m, nil
+
+	case toolStartMsg:
+		m.calls = append(m.calls, toolCall{id: msg.id, name: msg.name, input: msg.input, running: true})
+		m.layout()
+		return m, nil
+
+	case toolDoneMsg:
+		for i := range m.calls {
+			if m.calls[i].id == msg.id {
+				m.calls[i].running = false
+				m.calls[i].failed = msg.failed
+				m.calls[i].duration = msg.duration
+			}
+		}
+		return m, nil
+
+	case doneMsg:
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// layout sizes the transcript to the space the other parts leave
+func (m *model) layout() {
+	if !m.ready {
+		return
+	}
+	m.transcript.Width = m.width
+	m.transcript.Height = max(m.height-m.panelHeight()-2, 1)
+	m.refresh()
+}
+
+// refresh re-renders the transcript, following the end unless the user scrolled up
+func (m *model) refresh() {
+	if !m.ready {
+		return
+	}
+	follow := m.transcript.AtBottom()
+	m.transcript.SetContent(lipgloss.NewStyle().Width(m.width).Render(m.ui.text()))
+	if follow {
+		m.transcript.GotoBottom()
+	}
+}
+
+func (m *model) panelHeight() int {
+	if len(m.calls) == 0 {
+		return 0
+	}
+	if !m.expanded {
+		return 1
+	}
+	return min(len(m.calls), panelRows) + 1
+}
+
+func (m *model) View() string {
+	if !m.ready {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(m.transcript.View())
+	b.WriteString("\n")
+	if panel := m.panelView(); panel != "" {
+		b.WriteString(panel)
+		b.WriteString("\n")
+	}
+	b.WriteString(m.input.View())
+	b.WriteString("\n")
+	b.WriteString(m.statusView())
+	return b.String()
+}
+
+// panelView lists the most recent tool calls, or counts them when collapsed
+func (m *model) panelView() string {
+	if len(m.calls) == 0 {
+		return ""
+	}
+	running := 0
+	for _, call := range m.calls {
+		if call.running {
+			running++
+		}
+	}
+	header := fmt.Sprintf("tools: %d calls, %d running (ctrl+t to %s)", len(m.calls), running, map[bool]string{true: "collapse", false: "expand"}[m.expanded])
+	if !m.expanded {
+		return panelStyle.Render(truncate(header, m.width))
+	}
+
+	lines := []string{panelStyle.Render(truncate(header, m.width))}
+	for _, call := range m.calls[max(len(m.calls)-panelRows, 0):] {
+		mark, style, took := "✓", doneStyle, call.duration.Round(time.Millisecond).String()
+		switch {
+		case call.running:
+			mark, style, took = "…", runningStyle, "running"
+		case call.failed:
+			mark, style = "✗", failedStyle
+		}
+		text := truncate(fmt.Sprintf("%s %s %s (%s)", mark, call.name, call.input, took), m.width)
+		lines = append(lines, style.Render(text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// statusView shows the model, token usage, cost and what the session is doing
+func (m *model) statusView() string {
+	state := "working"
+	switch {
+	case m.waiting:
+		state = "waiting for input"
+	case m.activity != "":
+		state = fmt.Sprintf("%s %ds", m.activity, int(time.Since(m.started).Seconds()))
+	}
+	status := fmt.Sprintf(" %s | context %d%% | %d in / %d out tokens | %s | %s | PgUp/PgDn scroll, ctrl+c quit",
+		m.modelName, int(m.totals.ContextShare(m.modelName)*100), m.totals.InputTokens, m.totals.OutputTokens, m.totals.CostText(), state)
+	return statusStyle.Render(truncate(status, m.width) + strings.Repeat(" ", max(m.width-len([]rune(status)), 0)))
+}
+
+// tick schedules a redraw of the elapsed time in a second
+func tick(generation int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return tickMsg{generation: generation} })
+}
+
+// truncate shortens plain text to width columns
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return text
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
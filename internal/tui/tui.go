@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/internal/tools"
+	"agent/internal/usage"
+	"github.com/anthropics/anthropic-sdk-go"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// Supported reports whether stdin and stdout are terminals that can show
+// the TUI. Dumb terminals and pipes use the plain line mode instead.
+func Supported() bool {
+	termName := os.Getenv("TERM")
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) &&
+		termName != "" && termName != "dumb"
+}
+
+// UI is a full-screen interface for a chat session: a scrollable
+// transcript, an input box, a collapsible panel of tool calls and a status
+// bar with the model, token usage and estimated cost. Everything the
+// session prints becomes part of the transcript.
+type UI struct {
+	model   *model
+	program *tea.Program // Created by Run
+	usage   *usage.Tracker
+	input   chan string
+	closed  sync.Once
+
+	mutex      sync.Mutex
+	transcript strings.Builder
+	nextID     int
+}
+
+// New creates a UI for a session that starts with model. Token usage is
+// added to tracker, which may be shared with other reports.
+func New(model string, tracker *usage.Tracker) *UI {
+	u := &UI{usage: tracker, input: make(chan string, 1)}
+	u.model = newModel(u, model)
+	return u
+}
+
+// ReadLine waits for the user to submit the input box. It is the input
+// function for both messages and confirmations. The bool is false once the
+// user quits.
+func (u *UI) ReadLine() (string, bool) {
+	u.program.Send(waitingMsg{})
+	line, ok := <-u.input
+	return line, ok
+}
+
+// Start shows what the session is busy with in the status bar
+func (u *UI) Start(description string) {
+	u.program.Send(activityMsg{description: description, started: time.Now()})
+}
+
+// Stop clears the activity from the status bar
+func (u *UI) Stop() {
+	u.program.Send(activityMsg{})
+}
+
+// RecordUsage adds the token usage of a response; use it with agent.WithUsage
+func (u *UI) RecordUsage(model string, response anthropic.Usage) {
+	u.usage.Add(model, response)
+	u.program.Send(usageMsg{model: model, totals: u.usage.Totals()})
+}
+
+// Middleware reports every tool call to the tool panel
+func (u *UI) Middleware() tools.Middleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, toolCtx *tools.ToolContext, input json.RawMessage) (*tools.ToolResult, error) {
+			if toolCtx == nil || toolCtx.Tool == nil {
+				return next(ctx, toolCtx, input)
+			}
+			u.mutex.Lock()
+			u.nextID++
+			id := u.nextID
+			u.mutex.Unlock()
+
+			u.program.Send(toolStartMsg{id: id, name: toolCtx.Tool.Name, input: tools.SummarizeInput(input)})
+			start := time.Now()
+			result, err := next(ctx, toolCtx, input)
+			u.program.Send(toolDoneMsg{id: id, duration: time.Since(start), failed: err != nil || (result != nil && result.IsError)})
+			return result, err
+		}
+	}
+}
+
+// Run shows the UI while run executes. Standard output is captured into
+// the transcript for the duration and the transcript is printed to the
+// terminal afterwards, so the conversation stays in the scrollback.
+// Quitting the UI cancels the context passed to run.
+func (u *UI) Run(ctx context.Context, run func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	// The program draws to the real terminal while everything else is captured
+	original := os.Stdout
+	os.Stdout = writer
+	u.program = tea.NewProgram(u.model, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithOutput(original))
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		buf := make([]byte, 4096)
+		for {
+			n, err := reader.Read(buf)
+			if n > 0 {
+				u.write(string(buf[:n]))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	runErr := make(chan error, 1)
+	go func() {
+		err := run(ctx)
+		u.program.Send(doneMsg{err: err})
+		runErr <- err
+	}()
+
+	_, err = u.program.Run()
+	u.quit()
+	cancel()
+	sessionErr := <-runErr
+
+	os.Stdout = original
+	writer.Close()
+	<-copied
+	reader.Close()
+
+	transcript := u.text()
+	fmt.Fprint(original, transcript)
+	if transcript != "" && !strings.HasSuffix(transcript, "\n") {
+		fmt.Fprintln(original)
+	}
+	if err != nil {
+		return err
+	}
+	if errors.Is(sessionErr, context.Canceled) {
+		return nil
+	}
+	return sessionErr
+}
+
+// write appends session output to the transcript and tells the program
+func (u *UI) write(text string) {
+	u.appendText(text)
+	u.program.Send(outputMsg{})
+}
+
+// appendText appends to the transcript; the program's own updates use it
+// directly since sending to the program from inside an update deadlocks
+func (u *UI) appendText(text string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.transcript.WriteString(text)
+}
+
+// text returns the transcript so far
+func (u *UI) text() string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.transcript.String()
+}
+
+// quit ends pending and future reads
+func (u *UI) quit() {
+	u.closed.Do(func() { close(u.input) })
+}
+
+// submit hands a line from the input box to ReadLine
+func (u *UI) submit(line string) {
+	select {
+	case u.input <- line:
+	default:
+	}
+}
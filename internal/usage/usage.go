@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"agent/internal/models"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ContextWindow returns how many tokens fit in the context of model
+func ContextWindow(model string) int64 {
+	return models.Lookup(model).ContextWindow
+}
+
+// nearLimit is the share of the context window past which the status line warns
+const nearLimit = 0.8
+
+// Totals are the tokens used so far and their estimated cost
+type Totals struct {
+	InputTokens  int64 // Including cache reads and writes
+	OutputTokens int64
+	Cost         float64 // USD, only for models with a known price
+	Unpriced     bool    // Some usage was for a model without a known price
+	Context      int64   // Tokens in the conversation as of the latest response
+	Responses    int     // Model responses received
+}
+
+// ContextShare returns the fraction of model's context window in use
+func (t Totals) ContextShare(model string) float64 {
+	return float64(t.Context) / float64(ContextWindow(model))
+}
+
+// CostText formats the cost, with a + when some usage could not be priced
+func (t Totals) CostText() string {
+	cost := fmt.Sprintf("$%.4f", t.Cost)
+	if t.Unpriced {
+		cost += "+"
+	}
+	return cost
+}
+
+// StatusLine summarizes the session for model on one line, e.g.
+// "claude-sonnet-4-20250514 | context 12% (24.1k/200k) | 48.2k tokens | $0.0231"
+func (t Totals) StatusLine(model string) string {
+	share := t.ContextShare(model)
+	line := fmt.Sprintf("%s | context %d%% (%s/%s) | %s tokens | %s", model, int(share*100),
+		Tokens(t.Context), Tokens(ContextWindow(model)), Tokens(t.InputTokens+t.OutputTokens), t.CostText())
+	if share >= nearLimit {
+		line += " | nearing the context limit"
+	}
+	return line
+}
+
+// Tokens formats a token count compactly: 950, 24.1k or 1.2M
+func Tokens(n int64) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprint(n)
+	case n < 1_000_000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e3), ".0") + "k"
+	default:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	}
+}
+
+// Tracker accumulates token usage across the requests of a session
+type Tracker struct {
+	mutex  sync.Mutex
+	totals Totals
+}
+
+// New creates an empty tracker
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Add records the usage reported for one response from model
+func (t *Tracker) Add(model string, u anthropic.Usage) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.totals.InputTokens += u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	t.totals.OutputTokens += u.OutputTokens
+	t.totals.Responses++
+	// The next request sends everything this one did plus the response
+	t.totals.Context = u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens + u.OutputTokens
+
+	price := models.Lookup(model).Price
+	if price == nil {
+		t.totals.Unpriced = true
+		return
+	}
+	// Cache reads cost a tenth of the input price and cache writes a quarter more
+	input := float64(u.InputTokens) + 0.1*float64(u.CacheReadInputTokens) + 1.25*float64(u.CacheCreationInputTokens)
+	t.totals.Cost += (input*price.Input + float64(u.OutputTokens)*price.Output) / 1e6
+}
+
+// Totals returns the usage recorded so far
+func (t *Tracker) Totals() Totals {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.totals
+}
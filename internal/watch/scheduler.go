@@ -0,0 +1,115 @@
+package watch
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"agent/internal/config"
+	"agent/internal/permissions"
+)
+
+// Runner runs a task for the changed files that triggered it
+type Runner func(ctx context.Context, task config.WatchTask, changed []string)
+
+// Scheduler decides which tasks a batch of changes triggers and runs them,
+// at most a fixed number at once. A task never runs twice concurrently:
+// changes that trigger it while it runs are coalesced into one more run
+// once it finishes.
+type Scheduler struct {
+	tasks   []config.WatchTask
+	run     Runner
+	slots   chan struct{}
+	running sync.WaitGroup
+
+	mutex  sync.Mutex
+	active map[string]bool     // Tasks running or waiting for a slot, by name
+	queued map[string][]string // Changes that arrived while a task was active
+}
+
+// NewScheduler runs tasks with run, maxConcurrent at a time
+func NewScheduler(tasks []config.WatchTask, maxConcurrent int, run Runner) *Scheduler {
+	return &Scheduler{
+		tasks:  tasks,
+		run:    run,
+		slots:  make(chan struct{}, max(maxConcurrent, 1)),
+		active: map[string]bool{},
+		queued: map[string][]string{},
+	}
+}
+
+// Trigger starts every task that one of the changed paths matches, or
+// queues another run of those already active. It does not wait for them.
+func (s *Scheduler) Trigger(ctx context.Context, changed []string) {
+	for _, task := range s.tasks {
+		matched := slices.DeleteFunc(slices.Clone(changed), func(path string) bool { return !Matches(task, path) })
+		if len(matched) == 0 {
+			continue
+		}
+
+		s.mutex.Lock()
+		if s.active[task.Name] {
+			queued := append(s.queued[task.Name], matched...)
+			slices.Sort(queued)
+			s.queued[task.Name] = slices.Compact(queued)
+			s.mutex.Unlock()
+			continue
+		}
+		s.active[task.Name] = true
+		s.mutex.Unlock()
+
+		s.running.Add(1)
+		go s.loop(ctx, task, matched)
+	}
+}
+
+// loop runs task until no more changes are queued for it
+func (s *Scheduler) loop(ctx context.Context, task config.WatchTask, changed []string) {
+	defer s.running.Done()
+	for {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			s.mutex.Lock()
+			delete(s.active, task.Name)
+			delete(s.queued, task.Name)
+			s.mutex.Unlock()
+			return
+		}
+		s.run(ctx, task, changed)
+		<-s.slots
+
+		s.mutex.Lock()
+		next, ok := s.queued[task.Name]
+		delete(s.queued, task.Name)
+		if !ok || ctx.Err() != nil {
+			delete(s.active, task.Name)
+			s.mutex.Unlock()
+			return
+		}
+		s.mutex.Unlock()
+		changed = next
+	}
+}
+
+// Wait blocks until every task started by Trigger has finished
+func (s *Scheduler) Wait() {
+	s.running.Wait()
+}
+
+// Matches reports whether a change to path, relative to the workspace
+// root, triggers task: it matches one of the task's paths and none of its
+// ignore globs
+func Matches(task config.WatchTask, path string) bool {
+	for _, pattern := range task.Ignore {
+		if permissions.MatchPath(pattern, path) {
+			return false
+		}
+	}
+	for _, pattern := range task.Paths {
+		if permissions.MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
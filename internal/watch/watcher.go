@@ -0,0 +1,132 @@
+// Package watch reports changes to the files of a workspace and runs the
+// tasks they trigger. Files are polled rather than watched through the
+// operating system, so it behaves the same on every platform and file
+// system, at the cost of noticing changes up to PollInterval late.
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// PollInterval is how often the workspace is scanned for changes
+const PollInterval = 500 * time.Millisecond
+
+// Skip reports whether a slash-separated path relative to the root is left
+// out of the scan; skipped directories are not entered
+type Skip func(rel string, isDir bool) bool
+
+// stamp identifies a version of a file by size and modification time
+type stamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher finds the files under a root that were created, modified or
+// deleted since the previous scan. The .git directory is always skipped.
+type Watcher struct {
+	root   string
+	skip   Skip
+	stamps map[string]stamp
+}
+
+// New watches the files under root; skip may be nil
+func New(root string, skip Skip) *Watcher {
+	return &Watcher{root: root, skip: skip}
+}
+
+// Run scans the root until ctx is done. Changes are collected until none
+// has been seen for debounce, then sent as one batch of sorted,
+// slash-separated paths relative to the root. Files that exist when Run
+// starts are not reported.
+func (w *Watcher) Run(ctx context.Context, debounce time.Duration, changes chan<- []string) {
+	w.stamps = w.scan()
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	pending := map[string]bool{}
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := w.scan()
+		changed := w.compare(current)
+		w.stamps = current
+		for _, path := range changed {
+			pending[path] = true
+		}
+		if len(changed) > 0 {
+			lastChange = time.Now()
+		}
+		if len(pending) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+
+		batch := make([]string, 0, len(pending))
+		for path := range pending {
+			batch = append(batch, path)
+		}
+		slices.Sort(batch)
+		clear(pending)
+		select {
+		case changes <- batch:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scan stamps every file under the root. Files that vanish or cannot be
+// read during the scan are left out, and reported as deleted if they were
+// seen before.
+func (w *Watcher) scan() map[string]stamp {
+	stamps := map[string]stamp{}
+	filepath.WalkDir(w.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == w.root {
+			return nil
+		}
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if entry.Name() == ".git" || (w.skip != nil && w.skip(rel, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.skip != nil && w.skip(rel, false) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		stamps[rel] = stamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return stamps
+}
+
+// compare lists the files that differ between the previous scan and current
+func (w *Watcher) compare(current map[string]stamp) []string {
+	var changed []string
+	for path, now := range current {
+		if before, ok := w.stamps[path]; !ok || before.size != now.size || !before.modTime.Equal(now.modTime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.stamps {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,99 @@
+package workspace
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is the file system a workspace lives on: the local disk, or another
+// machine's for remote workspaces. Paths are absolute and clean. Errors for
+// missing files satisfy errors.Is(err, fs.ErrNotExist).
+type FS interface {
+	ReadFile(path string) ([]byte, error)
+	// Open opens a file for reading, for callers that stream it
+	Open(path string) (io.ReadCloser, error)
+	WriteFile(path string, data []byte, perm fs.FileMode) error
+	// WriteFileAtomic replaces path with data so that readers, and the file
+	// after a crash or a full disk, have either the old content or the new.
+	// A file that is replaced keeps its mode and, where the user may set
+	// them, its owner and group; perm applies to new files.
+	WriteFileAtomic(path string, data []byte, perm fs.FileMode) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Remove(path string) error
+	Stat(path string) (fs.FileInfo, error)
+	Lstat(path string) (fs.FileInfo, error)
+	EvalSymlinks(path string) (string, error)
+	// Walk visits the tree under root like filepath.Walk
+	Walk(root string, fn filepath.WalkFunc) error
+	// Glob returns the paths matching pattern, with filepath.Match syntax
+	Glob(pattern string) ([]string, error)
+}
+
+// Local is the file system of the machine billdozer runs on
+var Local FS = localFS{}
+
+type localFS struct{}
+
+func (localFS) ReadFile(path string) ([]byte, error)    { return os.ReadFile(path) }
+func (localFS) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+func (localFS) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+func (localFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (localFS) Remove(path string) error                     { return os.Remove(path) }
+func (localFS) Stat(path string) (fs.FileInfo, error)        { return os.Stat(path) }
+func (localFS) Lstat(path string) (fs.FileInfo, error)       { return os.Lstat(path) }
+func (localFS) EvalSymlinks(path string) (string, error)     { return filepath.EvalSymlinks(path) }
+func (localFS) Walk(root string, fn filepath.WalkFunc) error {
+	return WalkParallel(root, os.Lstat, os.ReadDir, fn)
+}
+func (localFS) Glob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
+
+// WriteFileAtomic writes a temporary file in the same directory, syncs it
+// to disk and renames it over path, then syncs the directory so the rename
+// itself survives a crash
+func (localFS) WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	original, err := os.Stat(path)
+	if err == nil {
+		perm = original.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+	if _, err := temp.Write(data); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), perm); err != nil {
+		return err
+	}
+	if original != nil {
+		keepOwner(temp.Name(), original)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+	renamed = true
+	// Directories cannot be synced on every platform; the rename is done either way
+	if handle, err := os.Open(dir); err == nil {
+		handle.Sync()
+		handle.Close()
+	}
+	return nil
+}
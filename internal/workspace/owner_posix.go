@@ -0,0 +1,19 @@
+//go:build !windows
+
+package workspace
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// keepOwner gives the replacement file at path the owner and group of the
+// file it replaces. Only root may give a file away, and other users only
+// groups they belong to, so failing is not an error: the file is then
+// owned by whoever replaced it, as with any other write.
+func keepOwner(path string, original fs.FileInfo) {
+	if stat, ok := original.Sys().(*syscall.Stat_t); ok {
+		os.Chown(path, int(stat.Uid), int(stat.Gid))
+	}
+}
@@ -0,0 +1,7 @@
+package workspace
+
+import "io/fs"
+
+// keepOwner is a no-op on Windows, where a file replaced by rename keeps
+// the owner of the process that wrote it
+func keepOwner(path string, original fs.FileInfo) {}
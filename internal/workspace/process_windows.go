@@ -0,0 +1,19 @@
+package workspace
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; terminate and kill use taskkill /T instead
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate asks the command and its child processes to exit
+func terminate(cmd *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// kill forcibly stops the command and its child processes
+func kill(cmd *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
@@ -0,0 +1,108 @@
+package workspace
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+)
+
+// Cmd is a command to run where a workspace's files are
+type Cmd struct {
+	Argv   []string
+	Dir    string            // Working directory; the runner's default when empty
+	Env    map[string]string // Added to the inherited environment; values may reference inherited variables, e.g. "$PATH:./bin"
+	Stdout io.Writer
+	Stderr io.Writer
+	Group  bool // Run in a process group of its own, so Terminate and Kill also stop what it started
+}
+
+// Process is a started command
+type Process interface {
+	// Wait waits for the command to exit. The error of a command that ran
+	// and failed implements ExitCoder.
+	Wait() error
+	// Terminate asks the command to exit
+	Terminate()
+	// Kill stops the command forcibly
+	Kill()
+	// ID describes the process for the user, e.g. "pid 123"
+	ID() string
+}
+
+// ExitCoder is implemented by the errors of commands that exited
+// unsuccessfully; the code is -1 when the command was killed by a signal
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// Runner starts commands: locally, or on a remote workspace's host
+type Runner interface {
+	Start(cmd Cmd) (Process, error)
+}
+
+// LocalRunner runs commands on the machine billdozer runs on
+var LocalRunner Runner = localRunner{}
+
+type localRunner struct{}
+
+func (localRunner) Start(c Cmd) (Process, error) {
+	cmd := exec.Command(c.Argv[0], c.Argv[1:]...)
+	cmd.Dir = c.Dir
+	cmd.Env = localEnv(c.Env)
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	if c.Group {
+		setProcessGroup(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &localProcess{cmd: cmd, group: c.Group}, nil
+}
+
+// localEnv returns the inherited environment with vars added, or nil to
+// inherit it unchanged
+func localEnv(vars map[string]string) []string {
+	if len(vars) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := os.Environ()
+	for _, name := range names {
+		env = append(env, name+"="+os.ExpandEnv(vars[name]))
+	}
+	return env
+}
+
+// localProcess is a command started with os/exec
+type localProcess struct {
+	cmd   *exec.Cmd
+	group bool
+}
+
+func (p *localProcess) Wait() error { return p.cmd.Wait() }
+
+func (p *localProcess) Terminate() {
+	if p.group {
+		terminate(p.cmd)
+		return
+	}
+	p.cmd.Process.Signal(os.Interrupt)
+}
+
+func (p *localProcess) Kill() {
+	if p.group {
+		kill(p.cmd)
+		return
+	}
+	p.cmd.Process.Kill()
+}
+
+func (p *localProcess) ID() string { return "pid " + strconv.Itoa(p.cmd.Process.Pid) }
@@ -0,0 +1,130 @@
+package workspace
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// walkWorkers is how many directories WalkParallel reads at once
+const walkWorkers = 8
+
+// errWalkDone abandons directory reads still queued when a walk ends
+var errWalkDone = errors.New("walk finished")
+
+// WalkParallel visits the tree under root like filepath.Walk: in lexical
+// order, one callback at a time, honouring filepath.SkipDir and
+// filepath.SkipAll. Directories are read ahead of the callbacks by a pool
+// of workers, so where every read waits on a disk or a round trip the walk
+// is not held up by one directory read at a time. When the reads are
+// served from memory it is a little slower than filepath.Walk; see
+// BenchmarkWalkParallel.
+// When the walk enters a directory it starts reading all of its
+// subdirectories, which bounds the read-ahead to the directories the walk
+// has reached. readDir lists a directory in any order.
+func WalkParallel(root string, lstat func(string) (fs.FileInfo, error), readDir func(string) ([]fs.DirEntry, error), fn filepath.WalkFunc) error {
+	w := &parallelWalker{
+		readDir: readDir,
+		slots:   make(chan struct{}, walkWorkers),
+		done:    make(chan struct{}),
+	}
+	defer close(w.done)
+
+	info, err := lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(root, info, w.read(root, info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type parallelWalker struct {
+	readDir func(string) ([]fs.DirEntry, error)
+	slots   chan struct{} // One per worker reading a directory
+	done    chan struct{} // Closed when the walk returns
+}
+
+// listing is a directory being read ahead of the walk
+type listing struct {
+	ready   chan struct{} // Closed once entries and err are set
+	entries []walkEntry
+	err     error
+}
+
+type walkEntry struct {
+	name string
+	info fs.FileInfo
+	err  error // From reading the entry's info, which filepath.Walk hands to fn
+}
+
+// read starts reading a directory, or returns nil for anything else
+func (w *parallelWalker) read(path string, info fs.FileInfo) *listing {
+	if !info.IsDir() {
+		return nil
+	}
+	l := &listing{ready: make(chan struct{})}
+	go func() {
+		defer close(l.ready)
+		select {
+		case w.slots <- struct{}{}:
+		case <-w.done:
+			l.err = errWalkDone
+			return
+		}
+		defer func() { <-w.slots }()
+
+		entries, err := w.readDir(path)
+		l.entries = make([]walkEntry, len(entries))
+		for i, entry := range entries {
+			info, err := entry.Info()
+			l.entries[i] = walkEntry{name: entry.Name(), info: info, err: err}
+		}
+		slices.SortFunc(l.entries, func(a, b walkEntry) int { return strings.Compare(a.name, b.name) })
+		l.err = err
+	}()
+	return l
+}
+
+// walk mirrors filepath.Walk's walk, with the directory's listing read
+// ahead
+func (w *parallelWalker) walk(path string, info fs.FileInfo, l *listing, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	<-l.ready
+	err := fn(path, info, l.err)
+	// As in filepath.Walk, a directory that could not be read is reported
+	// to fn and not descended into
+	if l.err != nil || err != nil {
+		return err
+	}
+
+	children := make([]*listing, len(l.entries))
+	for i, entry := range l.entries {
+		if entry.err == nil {
+			children[i] = w.read(filepath.Join(path, entry.name), entry.info)
+		}
+	}
+	for i, entry := range l.entries {
+		filename := filepath.Join(path, entry.name)
+		if entry.err != nil {
+			if err := fn(filename, entry.info, entry.err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.walk(filename, entry.info, children[i], fn); err != nil {
+			if !entry.info.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
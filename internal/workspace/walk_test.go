@@ -0,0 +1,109 @@
+package workspace
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readLatency stands in for the round trip of reading a directory on a
+// remote workspace
+const readLatency = 200 * time.Microsecond
+
+// slowFS is a file system whose directory reads take readLatency, for
+// fs.WalkDir to walk the way filepath.Walk would over SFTP
+type slowFS struct{ fs.FS }
+
+func (s slowFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	time.Sleep(readLatency)
+	return fs.ReadDir(s.FS, name)
+}
+
+// deepTree creates a tree of depth levels, each directory holding fanout
+// subdirectories and files, and returns its root
+func deepTree(b *testing.B, depth, fanout int) string {
+	root := b.TempDir()
+	var create func(dir string, level int)
+	create = func(dir string, level int) {
+		for i := range fanout {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.go", i)), []byte("package p\n"), 0o644); err != nil {
+				b.Fatal(err)
+			}
+			if level == depth {
+				continue
+			}
+			sub := filepath.Join(dir, fmt.Sprintf("dir%d", i))
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				b.Fatal(err)
+			}
+			create(sub, level+1)
+		}
+	}
+	create(root, 1)
+	return root
+}
+
+func BenchmarkWalkParallel(b *testing.B) {
+	root := deepTree(b, 5, 4)
+	count := func(entries *int) filepath.WalkFunc {
+		return func(path string, info fs.FileInfo, err error) error {
+			*entries++
+			return err
+		}
+	}
+
+	var want int
+	if err := filepath.Walk(root, count(&want)); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("WalkParallel", func(b *testing.B) {
+		for b.Loop() {
+			entries := 0
+			if err := WalkParallel(root, os.Lstat, os.ReadDir, count(&entries)); err != nil {
+				b.Fatal(err)
+			}
+			if entries != want {
+				b.Fatalf("walked %d entries, filepath.Walk walks %d", entries, want)
+			}
+		}
+	})
+	b.Run("filepath.Walk", func(b *testing.B) {
+		for b.Loop() {
+			entries := 0
+			if err := filepath.Walk(root, count(&entries)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// With a round trip per directory, as on remote workspaces, reading
+	// ahead is what WalkParallel is for
+	slowReadDir := func(path string) ([]fs.DirEntry, error) {
+		time.Sleep(readLatency)
+		return os.ReadDir(path)
+	}
+	b.Run("WalkParallel/latency", func(b *testing.B) {
+		for b.Loop() {
+			entries := 0
+			if err := WalkParallel(root, os.Lstat, slowReadDir, count(&entries)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("sequential/latency", func(b *testing.B) {
+		fsys := slowFS{os.DirFS(root)}
+		for b.Loop() {
+			entries := 0
+			err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+				entries++
+				return err
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
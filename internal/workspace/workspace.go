@@ -0,0 +1,376 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Error message constants
+const (
+	errMsgOutsideWorkspace = "path %q is outside the workspace %s (set paths.outside to ask or allow, or start billdozer with --allow-outside-workspace, to disable this check)"
+	errMsgSymlinkEscape    = "path %q leads outside the workspace %s through a symlink, to %s (set paths.outside to ask or allow to follow it)"
+	errMsgAbsolutePath     = "path %q is absolute; use a path relative to the workspace root, such as %q (paths.absolute is deny)"
+	errMsgInvalidRoot      = "invalid workspace root %q: %w"
+	errMsgDriveRelative    = "path %q is relative to the current directory of drive %s; use a path relative to the workspace root or a full path such as %s\\"
+)
+
+// Path policy actions
+const (
+	PathAllow = "allow"
+	PathAsk   = "ask"
+	PathDeny  = "deny"
+)
+
+// PathPolicy decides what happens to tool paths that are absolute, or that
+// lead outside the root through "..", an absolute path or a symlink. Empty
+// fields take the defaults.
+type PathPolicy struct {
+	Absolute string // allow (the default), ask or deny
+	Outside  string // deny (the default), ask or allow; allow when the workspace is unrestricted
+}
+
+// Reasons the path policy asks before a path is used. They are also the
+// names the user's "always allow" answers are remembered under.
+const (
+	AskAbsolute = "absolute_path"
+	AskOutside  = "outside_workspace"
+)
+
+// Path is a tool path resolved by the workspace
+type Path struct {
+	Abs   string // Absolute, with symlinks resolved
+	Shown string // Canonical form for tool results; see Display
+	Ask   string // AskAbsolute or AskOutside when the path policy wants the user to approve the access; empty otherwise
+}
+
+// ErrAccessDenied matches (with errors.Is) every error returned because a
+// path is outside the workspace or protected by a path rule
+var ErrAccessDenied = errors.New("access denied")
+
+// accessError marks an error as an access denial without changing its message
+type accessError struct {
+	err error
+}
+
+func (e accessError) Error() string        { return e.err.Error() }
+func (e accessError) Unwrap() error        { return e.err }
+func (e accessError) Is(target error) bool { return target == ErrAccessDenied }
+
+// PathChecker decides whether a workspace-relative path may be accessed
+type PathChecker interface {
+	Check(path string) error
+	// CheckDir fails only when no path inside the directory may be accessed
+	CheckDir(path string) error
+}
+
+// IgnoreMatcher decides whether a workspace-relative path is hidden from listings and searches
+type IgnoreMatcher interface {
+	Match(path string, isDir bool) bool
+}
+
+// Workspace confines tool file access to a root directory, on the local
+// machine or on a remote host
+type Workspace struct {
+	root         string
+	unrestricted bool
+	checker      PathChecker
+	ignore       IgnoreMatcher
+	fs           FS
+	runner       Runner
+	host         string // Remote host; empty for local workspaces
+
+	policyMutex sync.RWMutex
+	policy      PathPolicy
+}
+
+// New creates a workspace rooted at root. When unrestricted is true, paths are
+// still normalized but escapes are not rejected.
+func New(root string, unrestricted bool) (*Workspace, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgInvalidRoot, root, err)
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgInvalidRoot, root, err)
+	}
+
+	return &Workspace{root: resolvedRoot, unrestricted: unrestricted, fs: Local, runner: LocalRunner}, nil
+}
+
+// NewRemote creates a workspace rooted at the absolute path root of host,
+// whose files are reached through fsys and whose commands run through runner
+func NewRemote(host, root string, fsys FS, runner Runner, unrestricted bool) (*Workspace, error) {
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf(errMsgInvalidRoot, root, errors.New("the path must be absolute"))
+	}
+	resolvedRoot, err := fsys.EvalSymlinks(filepath.Clean(root))
+	if err != nil {
+		return nil, fmt.Errorf(errMsgInvalidRoot, root, err)
+	}
+	if info, err := fsys.Stat(resolvedRoot); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf(errMsgInvalidRoot, root, errors.New("not a directory"))
+	}
+	return &Workspace{root: resolvedRoot, unrestricted: unrestricted, fs: fsys, runner: runner, host: host}, nil
+}
+
+// FS returns the file system the workspace lives on; a nil workspace uses
+// the local one
+func (w *Workspace) FS() FS {
+	if w == nil {
+		return Local
+	}
+	return w.fs
+}
+
+// Runner returns what runs commands next to the workspace's files; a nil
+// workspace runs them locally
+func (w *Workspace) Runner() Runner {
+	if w == nil {
+		return LocalRunner
+	}
+	return w.runner
+}
+
+// Host returns the remote host of the workspace, or "" when it is local
+func (w *Workspace) Host() string {
+	if w == nil {
+		return ""
+	}
+	return w.host
+}
+
+// String describes the workspace: its root, prefixed by the host when remote
+func (w *Workspace) String() string {
+	if w.host != "" {
+		return w.host + ":" + w.root
+	}
+	return w.root
+}
+
+// SetPathChecker installs additional path rules applied to every resolved path
+func (w *Workspace) SetPathChecker(checker PathChecker) {
+	w.checker = checker
+}
+
+// SetPathPolicy sets how absolute paths and paths outside the root are
+// treated; it may be called while tools run, e.g. after a config reload
+func (w *Workspace) SetPathPolicy(policy PathPolicy) {
+	w.policyMutex.Lock()
+	defer w.policyMutex.Unlock()
+	w.policy = policy
+}
+
+func (w *Workspace) pathPolicy() PathPolicy {
+	w.policyMutex.RLock()
+	defer w.policyMutex.RUnlock()
+	return w.policy
+}
+
+// SetIgnore installs the matcher for paths listings and searches skip
+func (w *Workspace) SetIgnore(matcher IgnoreMatcher) {
+	w.ignore = matcher
+}
+
+// Ignored reports whether an absolute, resolved path inside the workspace is
+// excluded from listings and searches. Reading an ignored path directly is
+// still allowed; use path rules to deny access.
+func (w *Workspace) Ignored(path string, isDir bool) bool {
+	if w.ignore == nil || !w.Contains(path) {
+		return false
+	}
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return w.ignore.Match(filepath.ToSlash(rel), isDir)
+}
+
+// Root returns the absolute, symlink-resolved workspace root
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Resolve normalizes a tool path to an absolute path with symlinks resolved,
+// rejecting paths that escape the workspace root. Relative paths are
+// interpreted relative to the root. Paths the policy would ask about are
+// rejected too; tools that can ask the user use ResolvePath.
+func (w *Workspace) Resolve(path string) (string, error) {
+	resolved, err := w.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved.Ask != "" {
+		return "", accessError{fmt.Errorf("path %q needs the user's approval (%s)", path, resolved.Ask)}
+	}
+	return resolved.Abs, nil
+}
+
+// ResolvePath is Resolve applying the path policy: absolute paths and
+// paths outside the root are used, rejected or marked for the user's
+// approval as the policy says
+func (w *Workspace) ResolvePath(path string) (Path, error) {
+	return w.resolvePath(path, w.CheckAccess)
+}
+
+// ResolveDir is ResolvePath for a directory a listing or search starts
+// from, checked with CheckDirAccess: with an allow rule for
+// "docs/api/*.md", docs can be listed although it matches no rule itself.
+func (w *Workspace) ResolveDir(path string) (Path, error) {
+	return w.resolvePath(path, w.CheckDirAccess)
+}
+
+// resolvePath resolves path and applies the path rules with check
+func (w *Workspace) resolvePath(path string, check func(string) error) (Path, error) {
+	if path == "" {
+		path = "."
+	}
+	policy := w.pathPolicy()
+
+	// On Windows "C:foo" is neither absolute nor relative to the root: it
+	// names foo in whatever directory drive C: was last in
+	if volume := filepath.VolumeName(path); volume != "" && !filepath.IsAbs(path) {
+		return Path{}, fmt.Errorf(errMsgDriveRelative, path, volume, volume)
+	}
+
+	ask := ""
+	absPath := path
+	if filepath.IsAbs(absPath) {
+		switch policy.Absolute {
+		case PathDeny:
+			return Path{}, accessError{fmt.Errorf(errMsgAbsolutePath, path, w.Display(filepath.Clean(path)))}
+		case PathAsk:
+			ask = AskAbsolute
+		}
+	} else {
+		absPath = filepath.Join(w.root, absPath)
+	}
+	absPath = filepath.Clean(absPath)
+
+	resolved, err := w.resolveSymlinks(absPath)
+	if err != nil {
+		return Path{}, err
+	}
+
+	if !w.Contains(resolved) {
+		outside := policy.Outside
+		if w.unrestricted {
+			outside = PathAllow
+		}
+		switch outside {
+		case PathAllow:
+		case PathAsk:
+			ask = AskOutside
+		default:
+			if w.Contains(absPath) {
+				return Path{}, accessError{fmt.Errorf(errMsgSymlinkEscape, path, w.root, resolved)}
+			}
+			return Path{}, accessError{fmt.Errorf(errMsgOutsideWorkspace, path, w.root)}
+		}
+	}
+
+	if err := check(resolved); err != nil {
+		return Path{}, err
+	}
+
+	return Path{Abs: resolved, Shown: w.Display(resolved), Ask: ask}, nil
+}
+
+// Display returns the canonical form of a resolved path for tool results
+// and transcripts: relative to the root inside the workspace, and absolute
+// outside it
+func (w *Workspace) Display(path string) string {
+	if !w.Contains(path) {
+		return path
+	}
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// CheckAccess applies the configured path rules to an absolute, resolved path.
+// Paths inside the workspace are matched relative to the root.
+func (w *Workspace) CheckAccess(path string) error {
+	if w.checker == nil {
+		return nil
+	}
+
+	if w.Contains(path) {
+		if rel, err := filepath.Rel(w.root, path); err == nil {
+			path = rel
+		}
+	}
+
+	// The root itself is always accessible so listings and searches can start there
+	if path == "." {
+		return nil
+	}
+	if err := w.checker.Check(filepath.ToSlash(path)); err != nil {
+		return accessError{err}
+	}
+	return nil
+}
+
+// CheckDirAccess applies the configured path rules to an absolute, resolved
+// directory that a listing or search is about to descend into. It fails
+// only when nothing inside the directory is accessible, so a directory that
+// CheckAccess rejects, such as pkg under an allow rule for "*.md", is still
+// walked and its files are checked one by one.
+func (w *Workspace) CheckDirAccess(path string) error {
+	if w.checker == nil {
+		return nil
+	}
+
+	if w.Contains(path) {
+		if rel, err := filepath.Rel(w.root, path); err == nil {
+			path = rel
+		}
+	}
+	if path == "." {
+		return nil
+	}
+	if err := w.checker.CheckDir(filepath.ToSlash(path)); err != nil {
+		return accessError{err}
+	}
+	return nil
+}
+
+// Contains reports whether an absolute, resolved path lies inside the root
+func (w *Workspace) Contains(path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveSymlinks evaluates symlinks in the longest existing prefix of path,
+// so paths to files that do not exist yet can still be checked.
+func (w *Workspace) resolveSymlinks(path string) (string, error) {
+	existing := path
+	var missing []string
+	for {
+		if _, err := w.fs.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+
+	resolved, err := w.fs.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", existing, err)
+	}
+
+	return filepath.Join(append([]string{resolved}, missing...)...), nil
+}
@@ -1,40 +1,32 @@
 package main
 
 import (
-	"bufio"
-	"context"
-	"fmt"
+	"errors"
 	"os"
 
-	"agent/internal/agent"
-	"agent/internal/tools"
-	"github.com/anthropics/anthropic-sdk-go"
+	"agent/internal/cli"
 
 	// Import tool packages to register them
+	_ "agent/internal/tools/benchmark"
+	_ "agent/internal/tools/codemetrics"
 	_ "agent/internal/tools/command"
+	_ "agent/internal/tools/database"
 	_ "agent/internal/tools/file"
+	_ "agent/internal/tools/kubernetes"
+	_ "agent/internal/tools/licenses"
+	_ "agent/internal/tools/logs"
+	_ "agent/internal/tools/openapi"
+	_ "agent/internal/tools/protobuf"
+	_ "agent/internal/tools/terraform"
 )
 
 // main is the application entry point
 func main() {
-	client := anthropic.NewClient()
-
-	// Set up user input scanner
-	scanner := bufio.NewScanner(os.Stdin)
-	getUserMessage := func() (string, bool) {
-		if !scanner.Scan() {
-			return "", false
+	if err := cli.Execute(); err != nil {
+		var exit *cli.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.Code)
 		}
-		return scanner.Text(), true
-	}
-
-	// Get all registered tools from the registry
-	registeredTools := tools.DefaultRegistry.GetAll()
-
-	// Initialize and start agent
-	agentInstance := agent.NewAgent(&client, getUserMessage, registeredTools)
-	err := agentInstance.Run(context.TODO())
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
 	}
 }